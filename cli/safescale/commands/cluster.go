@@ -18,6 +18,7 @@ package commands
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -35,9 +36,13 @@ import (
 	clusterpropsv1 "github.com/CS-SI/SafeScale/lib/server/cluster/control/properties/v1"
 	clusterpropsv2 "github.com/CS-SI/SafeScale/lib/server/cluster/control/properties/v2"
 	"github.com/CS-SI/SafeScale/lib/server/cluster/enums/complexity"
+	"github.com/CS-SI/SafeScale/lib/server/cluster/enums/exportformat"
 	"github.com/CS-SI/SafeScale/lib/server/cluster/enums/flavor"
 	"github.com/CS-SI/SafeScale/lib/server/cluster/enums/property"
+	"github.com/CS-SI/SafeScale/lib/server/cluster/profile"
 	"github.com/CS-SI/SafeScale/lib/server/install"
+	"github.com/CS-SI/SafeScale/lib/server/install/repository"
+	"github.com/CS-SI/SafeScale/lib/system"
 	"github.com/CS-SI/SafeScale/lib/utils"
 	clitools "github.com/CS-SI/SafeScale/lib/utils/cli"
 	"github.com/CS-SI/SafeScale/lib/utils/cli/enums/exitcode"
@@ -65,8 +70,13 @@ var ClusterCommand = cli.Command{
 	Subcommands: []cli.Command{
 		clusterNodeCommand,
 		clusterMasterCommand,
+		clusterPoolCommand,
+		clusterAutoscaleCommand,
+		clusterHealthCommand,
 		clusterListCommand,
+		clusterProfileCommand,
 		clusterCreateCommand,
+		clusterCloneCommand,
 		clusterDeleteCommand,
 		clusterInspectCommand,
 		clusterStateCommand,
@@ -74,20 +84,28 @@ var ClusterCommand = cli.Command{
 		//clusterSshCommand,
 		clusterStartCommand,
 		clusterStopCommand,
+		clusterUpgradeCommand,
+		clusterBackupCommand,
+		clusterRestoreCommand,
+		clusterExportCommand,
 		clusterExpandCommand,
 		clusterShrinkCommand,
 		clusterDcosCommand,
 		clusterKubectlCommand,
+		clusterNomadCommand,
 		clusterHelmCommand,
 		clusterListFeaturesCommand,
 		clusterCheckFeatureCommand,
 		clusterAddFeatureCommand,
 		clusterDeleteFeatureCommand,
+		clusterUpgradeFeatureCommand,
+		clusterFeatureLogCommand,
+		clusterTunnelCommand,
 	},
 }
 
 func extractClusterArgument(c *cli.Context) error {
-	if !c.Command.HasName("list") || strings.HasSuffix(c.App.Name, " node") || strings.HasSuffix(c.App.Name, " master") {
+	if !c.Command.HasName("list") || strings.HasSuffix(c.App.Name, " node") || strings.HasSuffix(c.App.Name, " master") || strings.HasSuffix(c.App.Name, " pool") || strings.HasSuffix(c.App.Name, " backup") {
 		if c.NArg() < 1 {
 			_ = cli.ShowSubcommandHelp(c)
 			return clitools.ExitOnInvalidArgument("Missing mandatory argument CLUSTERNAME.")
@@ -218,7 +236,22 @@ func convertToMap(c api.Cluster) (map[string]interface{}, error) {
 
 	properties := c.GetProperties(concurrency.RootTask())
 	err := properties.LockForRead(property.CompositeV1).ThenUse(func(clonable data.Clonable) error {
-		result["tenant"] = clonable.(*clusterpropsv1.Composite).Tenants[0]
+		composite := clonable.(*clusterpropsv1.Composite)
+		result["tenant"] = composite.Tenants[0]
+		if len(composite.Sites) > 0 {
+			result["tenants"] = composite.Tenants
+			sites := map[string]interface{}{}
+			for tenant, site := range composite.Sites {
+				sites[tenant] = map[string]interface{}{
+					"network_id": site.NetworkID,
+					"cidr":       site.CIDR,
+					"gateway_id": site.GatewayID,
+					"gateway_ip": site.GatewayIP,
+					"public_ip":  site.PublicIP,
+				}
+			}
+			result["sites"] = sites
+		}
 		return nil
 	})
 	if err != nil {
@@ -278,6 +311,11 @@ func convertToMap(c api.Cluster) (map[string]interface{}, error) {
 	if err != nil {
 		return nil, err
 	}
+	pools, err := c.ListNodePools(concurrency.RootTask())
+	if err != nil {
+		return nil, err
+	}
+	result["node_pools"] = pools
 	err = properties.LockForRead(property.FeaturesV1).ThenUse(func(clonable data.Clonable) error {
 		result["features"] = clonable.(*clusterpropsv1.Features)
 		return nil
@@ -286,6 +324,21 @@ func convertToMap(c api.Cluster) (map[string]interface{}, error) {
 		return nil, err
 	}
 
+	upgrade, err := c.GetUpgrade(concurrency.RootTask())
+	if err != nil {
+		return nil, err
+	}
+	if upgrade != nil {
+		result["upgrade"] = upgrade
+	}
+	backups, err := c.ListBackups(concurrency.RootTask())
+	if err != nil {
+		return nil, err
+	}
+	if len(backups) > 0 {
+		result["backups"] = backups
+	}
+
 	err = properties.LockForRead(property.StateV1).ThenUse(func(clonable data.Clonable) error {
 		state := clonable.(*clusterpropsv1.State).State
 		result["last_state"] = state
@@ -340,7 +393,7 @@ var clusterCreateCommand = cli.Command{
 		cli.StringFlag{
 			Name:  "flavor, F",
 			Value: "K8S",
-			Usage: "Defines the type of the cluster; can be BOH, SWARM, OHPC, DCOS, K8S",
+			Usage: "Defines the type of the cluster; can be BOH, SWARM, OHPC, DCOS, K8S, K3S, NOMAD",
 		},
 		cli.BoolFlag{
 			Name:  "keep-on-failure, k",
@@ -357,7 +410,7 @@ var clusterCreateCommand = cli.Command{
 	Accepted features are:
 		remotedesktop (all flavors), reverseproxy (all flavors),
 		gateway-failover (all flavors with Normal or Large complexity),
-		hardening (flavor K8S), helm (flavor K8S)`,
+		hardening (flavor K8S), helm (flavors K8S and K3S)`,
 		},
 		cli.StringFlag{
 			Name:  "os",
@@ -411,6 +464,10 @@ var clusterCreateCommand = cli.Command{
 			Name:  "disk",
 			Usage: "DEPRECATED! use --sizing and friends instead! Defines the size of system disk of masters and nodes (in GB)",
 		},
+		cli.StringFlag{
+			Name:  "profile",
+			Usage: "Use the cluster profile stored under this name, or the profile in this local JSON file; the options explicitly set take precedence over the content of the profile",
+		},
 	},
 
 	Action: func(c *cli.Context) (err error) {
@@ -420,102 +477,19 @@ var clusterCreateCommand = cli.Command{
 			return clitools.FailureResponse(err)
 		}
 
-		complexityStr := c.String("complexity")
-		clusterComplexity, err := complexity.Parse(complexityStr)
-		if err != nil {
-			msg := fmt.Sprintf("Invalid option --complexity|-C: %s\n", err.Error())
-			return clitools.FailureResponse(clitools.ExitOnInvalidOption(msg))
-		}
-
-		flavorStr := c.String("flavor")
-		clusterFlavor, err := flavor.Parse(flavorStr)
-		if err != nil {
-			msg := fmt.Sprintf("Invalid option --flavor|-F: %s\n", err.Error())
-			return clitools.FailureResponse(clitools.ExitOnInvalidOption(msg))
-		}
-
-		keep := c.Bool("keep-on-failure")
-
-		cidr := c.String("cidr")
-
-		disable := c.StringSlice("disable")
-		disableFeatures := map[string]struct{}{}
-		for _, v := range disable {
-			disableFeatures[strings.ToLower(v)] = struct{}{}
-		}
-
-		los := c.String("os")
-		if clusterFlavor == flavor.DCOS {
-			// DCOS forces to use RHEL/CentOS/CoreOS, and we've chosen to use CentOS, so ignore --os option
-			los = ""
-		}
-
-		var (
-			gatewaysDef *pb.HostDefinition
-			mastersDef  *pb.HostDefinition
-			nodesDef    *pb.HostDefinition
-		)
-		if c.IsSet("sizing") {
-			nodesDef, err = constructPBHostDefinitionFromCLI(c, "sizing")
-			if err != nil {
-				return err
-			}
-			gatewaysDef = nodesDef
-			mastersDef = nodesDef
-		}
-		if c.IsSet("gw-sizing") {
-			gatewaysDef, err = constructPBHostDefinitionFromCLI(c, "gw-sizing")
-			if err != nil {
-				return err
-			}
-		}
-		if c.IsSet("master-sizing") {
-			mastersDef, err = constructPBHostDefinitionFromCLI(c, "master-sizing")
-			if err != nil {
-				return err
-			}
-		}
-		if c.IsSet("node-sizing") {
-			nodesDef, err = constructPBHostDefinitionFromCLI(c, "node-sizing")
+		var prof *profile.Profile
+		if c.IsSet("profile") {
+			prof, err = loadClusterProfile(c.String("profile"))
 			if err != nil {
-				return err
+				return clitools.FailureResponse(err)
 			}
 		}
 
-		if gatewaysDef == nil && mastersDef == nil && nodesDef == nil {
-			cpu := int32(c.Uint("cpu"))
-			ram := float32(c.Float64("ram"))
-			disk := int32(c.Uint("disk"))
-			gpu := int32(c.Uint("gpu"))
-
-			if cpu > 0 || ram > 0.0 || disk > 0 || los != "" {
-				nodesDef = &pb.HostDefinition{
-					ImageId: los,
-					Sizing: &pb.HostSizing{
-						MinCpuCount: cpu,
-						MaxCpuCount: cpu * 2,
-						MinRamSize:  ram,
-						MaxRamSize:  ram * 2.0,
-						MinDiskSize: disk,
-						GpuCount:    gpu,
-					},
-				}
-				gatewaysDef = nodesDef
-				gatewaysDef.Sizing.GpuCount = -1 // Neither GPU for gateways by default ...
-				mastersDef = gatewaysDef         // ... nor for masters
-			}
-		}
-		clusterInstance, err := cluster.Create(concurrency.RootTask(), control.Request{
-			Name:                    clusterName,
-			Complexity:              clusterComplexity,
-			CIDR:                    cidr,
-			Flavor:                  clusterFlavor,
-			KeepOnFailure:           keep,
-			GatewaysDef:             gatewaysDef,
-			MastersDef:              mastersDef,
-			NodesDef:                nodesDef,
-			DisabledDefaultFeatures: disableFeatures,
-		})
+		req, err := constructClusterRequestFromCLI(c, clusterName, prof)
+		if err != nil {
+			return err
+		}
+		clusterInstance, err := cluster.Create(concurrency.RootTask(), req)
 		if err != nil {
 			if clusterInstance != nil {
 				cluDel := clusterInstance.Delete(concurrency.RootTask())
@@ -531,6 +505,14 @@ var clusterCreateCommand = cli.Command{
 			return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.Run, msg))
 		}
 
+		if prof != nil {
+			err = applyClusterProfile(clusterInstance, prof)
+			if err != nil {
+				msg := fmt.Sprintf("cluster '%s' created, but failed to apply profile '%s': %s", clusterName, prof.Name, err.Error())
+				return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.Run, msg))
+			}
+		}
+
 		toFormat, err := convertToMap(clusterInstance)
 		if err != nil {
 			return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.Run, err.Error()))
@@ -544,30 +526,236 @@ var clusterCreateCommand = cli.Command{
 	},
 }
 
-// clusterDeleteCmd handles 'deploy cluster <clustername> delete'
-var clusterDeleteCommand = cli.Command{
-	Name:      "delete",
-	Aliases:   []string{"destroy", "remove", "rm"},
-	Usage:     "delete CLUSTERNAME",
-	ArgsUsage: "CLUSTERNAME",
+// optionOrProfile returns the value of the option 'name' if it is explicitly set or if 'fromProfile' is empty,
+// 'fromProfile' otherwise
+func optionOrProfile(c *cli.Context, name, fromProfile string) string {
+	if c.IsSet(name) || fromProfile == "" {
+		return c.String(name)
+	}
+	return fromProfile
+}
 
-	// 	Help: &cli.HelpContent{
-	// 		Usage: `
-	// Usage: deploy [options] cluster <clustername> delete|destroy|remove|rm [-y]`,
-	// 		Options: []string{`
-	// options:
-	//   -y,--assume-yes  Don't ask for confirmation`,
-	// 		},
-	// 		Description: `
-	// Delete a cluster.`,
-	// 	},
+// constructClusterRequestFromCLI builds the request of creation of cluster 'name' from the options of the command,
+// completed by the content of the profile 'prof' if not nil
+func constructClusterRequestFromCLI(c *cli.Context, name string, prof *profile.Profile) (control.Request, error) {
+	fromProfile := profile.Profile{}
+	if prof != nil {
+		fromProfile = *prof
+	}
+
+	complexityStr := optionOrProfile(c, "complexity", fromProfile.Complexity)
+	clusterComplexity, err := complexity.Parse(complexityStr)
+	if err != nil {
+		msg := fmt.Sprintf("Invalid option --complexity|-C: %s\n", err.Error())
+		return control.Request{}, clitools.FailureResponse(clitools.ExitOnInvalidOption(msg))
+	}
+
+	flavorStr := optionOrProfile(c, "flavor", fromProfile.Flavor)
+	clusterFlavor, err := flavor.Parse(flavorStr)
+	if err != nil {
+		msg := fmt.Sprintf("Invalid option --flavor|-F: %s\n", err.Error())
+		return control.Request{}, clitools.FailureResponse(clitools.ExitOnInvalidOption(msg))
+	}
+
+	keep := c.Bool("keep-on-failure")
+
+	cidr := optionOrProfile(c, "cidr", fromProfile.CIDR)
+
+	disable := append(c.StringSlice("disable"), fromProfile.Disabled...)
+	disableFeatures := map[string]struct{}{}
+	for _, v := range disable {
+		disableFeatures[strings.ToLower(v)] = struct{}{}
+	}
+
+	los := optionOrProfile(c, "os", fromProfile.Image)
+	if clusterFlavor == flavor.DCOS {
+		// DCOS forces to use RHEL/CentOS/CoreOS, and we've chosen to use CentOS, so ignore --os option
+		los = ""
+	}
+
+	var (
+		gatewaysDef *pb.HostDefinition
+		mastersDef  *pb.HostDefinition
+		nodesDef    *pb.HostDefinition
+	)
+	sizingSet := c.IsSet("sizing") || c.IsSet("gw-sizing") || c.IsSet("master-sizing") || c.IsSet("node-sizing") ||
+		c.IsSet("cpu") || c.IsSet("ram") || c.IsSet("disk")
+	if prof != nil && !sizingSet {
+		gatewaysDef = &pb.HostDefinition{ImageId: los}
+		gatewaysDef.Sizing, err = constructPBHostSizing(fromProfile.GatewaySizing)
+		if err != nil {
+			return control.Request{}, err
+		}
+		mastersDef = &pb.HostDefinition{ImageId: los}
+		mastersDef.Sizing, err = constructPBHostSizing(fromProfile.MasterSizing)
+		if err != nil {
+			return control.Request{}, err
+		}
+		nodesDef = &pb.HostDefinition{ImageId: los}
+		nodesDef.Sizing, err = constructPBHostSizing(fromProfile.NodeSizing)
+		if err != nil {
+			return control.Request{}, err
+		}
+	}
+	if c.IsSet("sizing") {
+		nodesDef, err = constructPBHostDefinitionFromCLI(c, "sizing")
+		if err != nil {
+			return control.Request{}, err
+		}
+		gatewaysDef = nodesDef
+		mastersDef = nodesDef
+	}
+	if c.IsSet("gw-sizing") {
+		gatewaysDef, err = constructPBHostDefinitionFromCLI(c, "gw-sizing")
+		if err != nil {
+			return control.Request{}, err
+		}
+	}
+	if c.IsSet("master-sizing") {
+		mastersDef, err = constructPBHostDefinitionFromCLI(c, "master-sizing")
+		if err != nil {
+			return control.Request{}, err
+		}
+	}
+	if c.IsSet("node-sizing") {
+		nodesDef, err = constructPBHostDefinitionFromCLI(c, "node-sizing")
+		if err != nil {
+			return control.Request{}, err
+		}
+	}
+
+	if gatewaysDef == nil && mastersDef == nil && nodesDef == nil {
+		cpu := int32(c.Uint("cpu"))
+		ram := float32(c.Float64("ram"))
+		disk := int32(c.Uint("disk"))
+		gpu := int32(c.Uint("gpu"))
+
+		if cpu > 0 || ram > 0.0 || disk > 0 || los != "" {
+			nodesDef = &pb.HostDefinition{
+				ImageId: los,
+				Sizing: &pb.HostSizing{
+					MinCpuCount: cpu,
+					MaxCpuCount: cpu * 2,
+					MinRamSize:  ram,
+					MaxRamSize:  ram * 2.0,
+					MinDiskSize: disk,
+					GpuCount:    gpu,
+				},
+			}
+			gatewaysDef = nodesDef
+			gatewaysDef.Sizing.GpuCount = -1 // Neither GPU for gateways by default ...
+			mastersDef = gatewaysDef         // ... nor for masters
+		}
+	}
+	return control.Request{
+		Name:                    name,
+		Complexity:              clusterComplexity,
+		CIDR:                    cidr,
+		Flavor:                  clusterFlavor,
+		KeepOnFailure:           keep,
+		GatewaysDef:             gatewaysDef,
+		MastersDef:              mastersDef,
+		NodesDef:                nodesDef,
+		DisabledDefaultFeatures: disableFeatures,
+	}, nil
+}
+
+// loadClusterProfile loads the profile 'ref', being either a local JSON file or the name of a profile stored in tenant
+func loadClusterProfile(ref string) (*profile.Profile, error) {
+	if _, err := os.Stat(ref); err == nil {
+		prof, err := profile.LoadFile(ref)
+		if err != nil {
+			return nil, clitools.ExitOnInvalidOption(fmt.Sprintf("Invalid option --profile: %s\n", err.Error()))
+		}
+		return prof, nil
+	}
+	prof, err := profile.Load(ref)
+	if err != nil {
+		if _, ok := err.(scerr.ErrNotFound); ok {
+			return nil, clitools.ExitOnErrorWithMessage(exitcode.NotFound, fmt.Sprintf("Cluster profile '%s' not found.\n", ref))
+		}
+		return nil, clitools.ExitOnRPC(err.Error())
+	}
+	return prof, nil
+}
+
+// applyClusterProfile creates the node pools and adds the features described by the profile to the cluster
+func applyClusterProfile(instance api.Cluster, prof *profile.Profile) error {
+	task := concurrency.RootTask()
+	for _, v := range prof.Pools {
+		if v.Name == control.DefaultNodePoolName {
+			_, err := instance.ResizeNodePool(task, v.Name, v.Count)
+			if err != nil {
+				return fmt.Errorf("failed to resize node pool '%s': %s", v.Name, err.Error())
+			}
+			continue
+		}
+		sizing, err := constructPBHostSizing(v.Sizing)
+		if err != nil {
+			return fmt.Errorf("invalid sizing of node pool '%s': %s", v.Name, err.Error())
+		}
+		def := &pb.HostDefinition{
+			ImageId: v.Image,
+			Public:  v.Public,
+			Sizing:  sizing,
+		}
+		_, err = instance.AddNodePool(task, v.Name, v.Count, def, v.Labels, v.Taints, "", "")
+		if err != nil {
+			return fmt.Errorf("failed to create node pool '%s': %s", v.Name, err.Error())
+		}
+	}
+
+	if len(prof.Features) == 0 {
+		return nil
+	}
+	target, err := install.NewClusterTarget(task, instance)
+	if err != nil {
+		return err
+	}
+	for _, v := range prof.Features {
+		feature, err := install.NewFeature(task, v.Name)
+		if err != nil {
+			return err
+		}
+		if feature == nil {
+			return fmt.Errorf("failed to find a feature named '%s'", v.Name)
+		}
+		values := install.Variables{}
+		for k, p := range v.Params {
+			values[k] = p
+		}
+		results, err := feature.Add(target, values, install.Settings{})
+		if err != nil {
+			return fmt.Errorf("error installing feature '%s': %s", v.Name, err.Error())
+		}
+		if !results.Successful() {
+			if Debug || Verbose {
+				return fmt.Errorf("failed to install feature '%s':\n%s", v.Name, results.AllErrorMessages())
+			}
+			return fmt.Errorf("failed to install feature '%s'", v.Name)
+		}
+		err = instance.RegisterFeature(task, feature.DisplayName(), feature.Version(), feature.SealParameters(instance.GetService(task), values))
+		if err != nil {
+			logrus.Warnf("failed to record feature '%s' in cluster metadata: %v", v.Name, err)
+		}
+	}
+	return nil
+}
+
+// clusterCloneCommand handles 'safescale cluster clone SRCCLUSTERNAME DSTCLUSTERNAME'
+var clusterCloneCommand = cli.Command{
+	Name:      "clone",
+	Usage:     "clone SRCCLUSTERNAME DSTCLUSTERNAME",
+	ArgsUsage: "SRCCLUSTERNAME DSTCLUSTERNAME",
 
 	Flags: []cli.Flag{
-		cli.BoolFlag{
-			Name: "assume-yes, yes, y",
+		cli.StringFlag{
+			Name:  "cidr, N",
+			Usage: "Defines the CIDR of the network of the new cluster (default: the CIDR of the network of the source cluster)",
 		},
 		cli.BoolFlag{
-			Name: "force, f",
+			Name:  "keep-on-failure, k",
+			Usage: "If used, the resources are not deleted on failure (default: not set)",
 		},
 	},
 
@@ -577,254 +765,384 @@ var clusterDeleteCommand = cli.Command{
 		if err != nil {
 			return clitools.FailureResponse(err)
 		}
-		yes := c.Bool("assume-yes")
-		force := c.Bool("force")
-
-		if !yes && !utils.UserConfirmed(fmt.Sprintf("Are you sure you want to delete Cluster '%s'", clusterName)) {
-			return clitools.SuccessResponse("Aborted")
+		dstName := c.Args().Get(1)
+		if dstName == "" {
+			_ = cli.ShowSubcommandHelp(c)
+			return clitools.FailureResponse(clitools.ExitOnInvalidArgument("Missing mandatory argument DSTCLUSTERNAME."))
 		}
-		if force {
-			logrus.Println("'-f,--force' does nothing yet")
+		_, err = cluster.Load(concurrency.RootTask(), dstName)
+		if err == nil {
+			return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.Duplicate, fmt.Sprintf("Cluster '%s' already exists.\n", dstName)))
+		}
+		if _, ok := err.(scerr.ErrNotFound); !ok {
+			return clitools.FailureResponse(clitools.ExitOnRPC(fmt.Sprintf("failed to query for cluster '%s': %s\n", dstName, err.Error())))
 		}
 
-		err = clusterInstance.Delete(concurrency.RootTask())
+		prof, err := profile.FromCluster(concurrency.RootTask(), clusterInstance)
 		if err != nil {
 			return clitools.FailureResponse(clitools.ExitOnRPC(err.Error()))
 		}
-		return clitools.SuccessResponse(nil)
+		req, err := constructClusterRequestFromCLI(c, dstName, prof)
+		if err != nil {
+			return err
+		}
+		dstInstance, err := cluster.Create(concurrency.RootTask(), req)
+		if err != nil {
+			if dstInstance != nil {
+				cluDel := dstInstance.Delete(concurrency.RootTask())
+				if cluDel != nil {
+					logrus.Warnf("Error deleting cluster instance: %s", cluDel)
+				}
+			}
+			msg := fmt.Sprintf("failed to create cluster: %s", err.Error())
+			return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.Run, msg))
+		}
+		err = applyClusterProfile(dstInstance, prof)
+		if err != nil {
+			msg := fmt.Sprintf("cluster '%s' created, but failed to reproduce the node pools and features of cluster '%s': %s", dstName, clusterName, err.Error())
+			return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.Run, msg))
+		}
+
+		toFormat, err := convertToMap(dstInstance)
+		if err != nil {
+			return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.Run, err.Error()))
+		}
+		formatted := formatClusterConfig(toFormat, true)
+		if !Debug {
+			delete(formatted, "defaults")
+		}
+		return clitools.SuccessResponse(formatted)
 	},
 }
 
-// clusterStopCmd handles 'deploy cluster <clustername> stop'
-var clusterStopCommand = cli.Command{
-	Name:      "stop",
-	Aliases:   []string{"freeze", "halt"},
-	Usage:     "stop CLUSTERNAME",
-	ArgsUsage: "CLUSTERNAME",
+// clusterProfileCommand handles 'safescale cluster profile'
+var clusterProfileCommand = cli.Command{
+	Name:      "profile",
+	Aliases:   []string{"template"},
+	Usage:     "manage the profiles used to create clusters",
+	ArgsUsage: "COMMAND",
 
-	// 	Help: &cli.HelpContent{
-	// 		Usage: `
-	// Usage: deploy [options] cluster <clustername> stop|freeze`,
-	// 		Description: `
-	// Stop the cluster (make it unavailable for duty).`,
-	// 	},
+	Subcommands: []cli.Command{
+		clusterProfileListCommand,
+		clusterProfileInspectCommand,
+		clusterProfileSaveCommand,
+		clusterProfileDeleteCommand,
+	},
+}
+
+// extractProfileArgument returns the name of the profile passed as first argument
+func extractProfileArgument(c *cli.Context) (string, error) {
+	name := c.Args().First()
+	if name == "" {
+		_ = cli.ShowSubcommandHelp(c)
+		return "", clitools.ExitOnInvalidArgument("Missing mandatory argument PROFILENAME.")
+	}
+	return name, nil
+}
+
+// clusterProfileListCommand handles 'safescale cluster profile list'
+var clusterProfileListCommand = cli.Command{
+	Name:    "list",
+	Aliases: []string{"ls"},
+	Usage:   "list",
 
 	Action: func(c *cli.Context) error {
 		logrus.Tracef("SafeScale command: {%s}, {%s} with args {%s}", clusterCommandName, c.Command.Name, c.Args())
-		err := extractClusterArgument(c)
+		list, err := profile.List()
+		if err != nil {
+			return clitools.FailureResponse(clitools.ExitOnRPC(err.Error()))
+		}
+		var out []map[string]interface{}
+		for _, v := range list {
+			out = append(out, map[string]interface{}{
+				"name":        v.Name,
+				"description": v.Description,
+				"flavor":      v.Flavor,
+				"complexity":  v.Complexity,
+			})
+		}
+		return clitools.SuccessResponse(out)
+	},
+}
+
+// clusterProfileInspectCommand handles 'safescale cluster profile inspect PROFILENAME'
+var clusterProfileInspectCommand = cli.Command{
+	Name:      "inspect",
+	Aliases:   []string{"show", "get"},
+	Usage:     "inspect PROFILENAME",
+	ArgsUsage: "PROFILENAME",
+
+	Action: func(c *cli.Context) error {
+		logrus.Tracef("SafeScale command: {%s}, {%s} with args {%s}", clusterCommandName, c.Command.Name, c.Args())
+		name, err := extractProfileArgument(c)
 		if err != nil {
 			return clitools.FailureResponse(err)
 		}
-		err = clusterInstance.Stop(concurrency.RootTask())
+		prof, err := profile.Load(name)
 		if err != nil {
+			if _, ok := err.(scerr.ErrNotFound); ok {
+				return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.NotFound, fmt.Sprintf("Cluster profile '%s' not found.\n", name)))
+			}
 			return clitools.FailureResponse(clitools.ExitOnRPC(err.Error()))
 		}
-		return clitools.SuccessResponse(nil)
+		return clitools.SuccessResponse(prof)
 	},
 }
 
-var clusterStartCommand = cli.Command{
-	Name:      "start",
-	Aliases:   []string{"unfreeze"},
-	Usage:     "start CLUSTERNAME",
-	ArgsUsage: "CLUSTERNAME",
-	// 	Help: &cli.HelpContent{
-	// 		Usage: `
-	// Usage: {{.ProgName}} [options] cluster <clustername> start|unfreeze`,
-	// 		Options: []string{`
-	// options:
-	//   --force, -f Force Don't ask for confirmation`,
-	// 		},
-	// 		Description: `
-	// Start the cluster (make it available for duty).`,
-	// 	},
+// clusterProfileSaveCommand handles 'safescale cluster profile save PROFILENAME'
+var clusterProfileSaveCommand = cli.Command{
+	Name:      "save",
+	Aliases:   []string{"create", "set"},
+	Usage:     "save PROFILENAME",
+	ArgsUsage: "PROFILENAME",
+
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "description",
+			Usage: "Describe the profile",
+		},
+		cli.StringFlag{
+			Name:  "from-file",
+			Usage: "Read the content of the profile from this local JSON file",
+		},
+		cli.StringFlag{
+			Name:  "from-cluster",
+			Usage: "Build the profile from the configuration of this cluster (flavor, complexity, sizings, node pools and features)",
+		},
+		cli.StringFlag{
+			Name:  "complexity, C",
+			Usage: "Defines the sizing of the cluster: Small, Normal, Large",
+		},
+		cli.StringFlag{
+			Name:  "flavor, F",
+			Usage: "Defines the type of the cluster; can be BOH, SWARM, OHPC, DCOS, K8S, K3S, NOMAD",
+		},
+		cli.StringFlag{
+			Name:  "cidr, N",
+			Usage: "Defines the CIDR of the network to use with cluster",
+		},
+		cli.StringFlag{
+			Name:  "os",
+			Usage: "Defines the operating system to use",
+		},
+		cli.StringSliceFlag{
+			Name:  "disable",
+			Usage: "Disables the addition of a default feature (cf. 'cluster create --disable')",
+		},
+		cli.StringFlag{
+			Name:  "sizing",
+			Usage: `Describe sizing for any type of host in format "<component><operator><value>[,...]" (cf. 'cluster create --sizing' for details)`,
+		},
+		cli.StringFlag{
+			Name:  "gw-sizing",
+			Usage: `Describe gateway sizing in format "<component><operator><value>[,...]" (cf. 'cluster create --sizing' for details)`,
+		},
+		cli.StringFlag{
+			Name:  "master-sizing",
+			Usage: `Describe master sizing in format "<component><operator><value>[,...]" (cf. 'cluster create --sizing' for details)`,
+		},
+		cli.StringFlag{
+			Name:  "node-sizing",
+			Usage: `Describe node sizing in format "<component><operator><value>[,...]" (cf. 'cluster create --sizing' for details)`,
+		},
+		cli.StringSliceFlag{
+			Name:  "feature",
+			Usage: "Adds this feature once the cluster is created (can be repeated)",
+		},
+	},
+
 	Action: func(c *cli.Context) error {
 		logrus.Tracef("SafeScale command: {%s}, {%s} with args {%s}", clusterCommandName, c.Command.Name, c.Args())
-		err := extractClusterArgument(c)
+		name, err := extractProfileArgument(c)
 		if err != nil {
 			return clitools.FailureResponse(err)
 		}
-		err = clusterInstance.Start(concurrency.RootTask())
+
+		prof := &profile.Profile{}
+		switch {
+		case c.IsSet("from-file") && c.IsSet("from-cluster"):
+			return clitools.FailureResponse(clitools.ExitOnInvalidOption("cannot use simultaneously --from-file and --from-cluster"))
+		case c.IsSet("from-file"):
+			prof, err = profile.LoadFile(c.String("from-file"))
+			if err != nil {
+				return clitools.FailureResponse(clitools.ExitOnInvalidOption(fmt.Sprintf("Invalid option --from-file: %s\n", err.Error())))
+			}
+		case c.IsSet("from-cluster"):
+			instance, err := cluster.Load(concurrency.RootTask(), c.String("from-cluster"))
+			if err != nil {
+				if _, ok := err.(scerr.ErrNotFound); ok {
+					return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.NotFound, fmt.Sprintf("Cluster '%s' not found.\n", c.String("from-cluster"))))
+				}
+				return clitools.FailureResponse(clitools.ExitOnRPC(err.Error()))
+			}
+			prof, err = profile.FromCluster(concurrency.RootTask(), instance)
+			if err != nil {
+				return clitools.FailureResponse(clitools.ExitOnRPC(err.Error()))
+			}
+		}
+
+		prof.Name = name
+		prof.Description = optionOrProfile(c, "description", prof.Description)
+		prof.Complexity = optionOrProfile(c, "complexity", prof.Complexity)
+		prof.Flavor = optionOrProfile(c, "flavor", prof.Flavor)
+		prof.CIDR = optionOrProfile(c, "cidr", prof.CIDR)
+		prof.Image = optionOrProfile(c, "os", prof.Image)
+		if c.IsSet("sizing") {
+			prof.GatewaySizing = c.String("sizing")
+			prof.MasterSizing = c.String("sizing")
+			prof.NodeSizing = c.String("sizing")
+		}
+		prof.GatewaySizing = optionOrProfile(c, "gw-sizing", prof.GatewaySizing)
+		prof.MasterSizing = optionOrProfile(c, "master-sizing", prof.MasterSizing)
+		prof.NodeSizing = optionOrProfile(c, "node-sizing", prof.NodeSizing)
+		for _, v := range []string{prof.GatewaySizing, prof.MasterSizing, prof.NodeSizing} {
+			if _, err = constructPBHostSizing(v); err != nil {
+				return err
+			}
+		}
+		prof.Disabled = append(prof.Disabled, c.StringSlice("disable")...)
+		for _, v := range c.StringSlice("feature") {
+			prof.Features = append(prof.Features, profile.Feature{Name: v})
+		}
+
+		err = prof.Validate()
+		if err != nil {
+			return clitools.FailureResponse(clitools.ExitOnInvalidArgument(err.Error()))
+		}
+		err = profile.Save(prof)
 		if err != nil {
 			return clitools.FailureResponse(clitools.ExitOnRPC(err.Error()))
 		}
-		return clitools.SuccessResponse(nil)
+		return clitools.SuccessResponse(prof)
 	},
 }
 
-// clusterStateCmd handles 'deploy cluster <clustername> state'
-var clusterStateCommand = cli.Command{
-	Name:      "state",
-	Usage:     "state CLUSTERNAME",
-	ArgsUsage: "CLUSTERNAME",
+// clusterProfileDeleteCommand handles 'safescale cluster profile delete PROFILENAME'
+var clusterProfileDeleteCommand = cli.Command{
+	Name:      "delete",
+	Aliases:   []string{"rm", "remove"},
+	Usage:     "delete PROFILENAME",
+	ArgsUsage: "PROFILENAME",
 
-	// 	Help: &cli.HelpContent{
-	// 		Usage: `
-	// Usage: {{.ProgName}} [options] cluster <clustername> state`,
-	// 		Description: `
-	// Get the cluster state.`,
-	// 	},
 	Action: func(c *cli.Context) error {
 		logrus.Tracef("SafeScale command: {%s}, {%s} with args {%s}", clusterCommandName, c.Command.Name, c.Args())
-		err := extractClusterArgument(c)
+		name, err := extractProfileArgument(c)
 		if err != nil {
 			return clitools.FailureResponse(err)
 		}
-		state, err := clusterInstance.GetState(concurrency.RootTask())
+		err = profile.Delete(name)
 		if err != nil {
-			msg := fmt.Sprintf("failed to get cluster state: %s", err.Error())
-			return clitools.FailureResponse(clitools.ExitOnRPC(msg))
+			if _, ok := err.(scerr.ErrNotFound); ok {
+				return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.NotFound, fmt.Sprintf("Cluster profile '%s' not found.\n", name)))
+			}
+			return clitools.FailureResponse(clitools.ExitOnRPC(err.Error()))
 		}
-		return clitools.SuccessResponse(map[string]interface{}{
-			"Name":       clusterName,
-			"State":      state,
-			"StateLabel": state.String(),
-		})
+		return clitools.SuccessResponse(nil)
 	},
 }
 
-// clusterExpandCmd handles 'deploy cluster <clustername> expand'
-var clusterExpandCommand = cli.Command{
-	Name:      "expand",
-	Usage:     "expand CLUSTERNAME",
+// clusterDeleteCmd handles 'deploy cluster <clustername> delete'
+var clusterDeleteCommand = cli.Command{
+	Name:      "delete",
+	Aliases:   []string{"destroy", "remove", "rm"},
+	Usage:     "delete CLUSTERNAME",
 	ArgsUsage: "CLUSTERNAME",
 
 	// 	Help: &cli.HelpContent{
 	// 		Usage: `
-	// Usage: deploy [options] cluster <clustername> expand [{command options}] [{host options}]`,
-	// 		Options: []string{
-	// 			`
-	// command options:
-	//   --count,-n <number of nodes> Instructs to expand cluster with <number> of new nodes
-	//   --public,-p                  Allocates public IP address(es) to node(s)`,
-	// 			`
-	// host options:
-	//   --os <operating system> (default: Ubuntu 18.04)
-	//   --cpu <number of cpus> (default: 4)
-	//   --ram <ram size) (default: 15 GB)
-	//   --disk <disk size> (default: 100 GB)`,
+	// Usage: deploy [options] cluster <clustername> delete|destroy|remove|rm [-y]`,
+	// 		Options: []string{`
+	// options:
+	//   -y,--assume-yes  Don't ask for confirmation`,
 	// 		},
 	// 		Description: `
-	// Expand the cluster by adding nodes.`,
+	// Delete a cluster.`,
 	// 	},
 
 	Flags: []cli.Flag{
-		cli.UintFlag{
-			Name:  "count, n",
-			Usage: "Define the number of nodes wanted (default: 1)",
-			Value: 1,
-		},
-		cli.StringFlag{
-			Name:  "os",
-			Usage: "Define the Operating System wanted",
-		},
-		cli.UintFlag{
-			Name:  "cpu",
-			Usage: "Define the number of cpu for new node(s); default: number used at cluster creation",
-			Value: 0,
-		},
-		cli.Float64Flag{
-			Name:  "ram",
-			Usage: "Define the size of RAM for new node(s) (in GB); default: size used at cluster creation",
-			Value: 0.0,
-		},
-		cli.UintFlag{
-			Name:  "disk",
-			Usage: "Define the size of system disk for new node(s) (in GB); default: size used at cluster creation",
-			Value: 0,
-		},
 		cli.BoolFlag{
-			Name:   "gpu",
-			Usage:  "Ask for gpu capable host; default: no",
-			Hidden: true,
+			Name: "assume-yes, yes, y",
 		},
-		cli.StringFlag{
-			Name: "node-sizing",
-			Usage: `Describe node sizing in format "<component><operator><value>[,...]" where:
-	<component> can be cpu, cpufreq, gpu, ram, disk, os
-	<operator> can be =,<,> (except for disk where valid operators are only = or >)
-	<value> can be an integer (for cpu and disk) or a float (for ram) or an including interval "[<lower value>-<upper value>]"`,
+		cli.BoolFlag{
+			Name: "force, f",
 		},
 	},
+
 	Action: func(c *cli.Context) error {
 		logrus.Tracef("SafeScale command: {%s}, {%s} with args {%s}", clusterCommandName, c.Command.Name, c.Args())
 		err := extractClusterArgument(c)
 		if err != nil {
 			return clitools.FailureResponse(err)
 		}
+		yes := c.Bool("assume-yes")
+		force := c.Bool("force")
 
-		count := int(c.Uint("count"))
-		if count == 0 {
-			count = 1
-		}
-		los := c.String("os")
-
-		var nodesDef *pb.HostDefinition
-		//		if c.IsSet("node-sizing") {
-		nodesDef, err = constructPBHostDefinitionFromCLI(c, "node-sizing")
-		if err != nil {
-			return err
+		if !yes && !utils.UserConfirmed(fmt.Sprintf("Are you sure you want to delete Cluster '%s'", clusterName)) {
+			return clitools.SuccessResponse("Aborted")
 		}
-		//		}
-
-		if nodesDef == nil {
-			cpu := int32(c.Uint("cpu"))
-			ram := float32(c.Float64("ram"))
-			disk := int32(c.Uint("disk"))
-			gpu := int32(c.Uint("gpu"))
-			if gpu == 0 {
-				gpu = -1
-			}
-			if cpu > 0 || ram > 0.0 || disk > 0 || gpu >= 1 || los != "" {
-				nodesDef = &pb.HostDefinition{
-					ImageId: los,
-					Sizing: &pb.HostSizing{
-						MinCpuCount: cpu,
-						MaxCpuCount: cpu * 2,
-						MinRamSize:  ram,
-						MaxRamSize:  ram * 2.0,
-						MinDiskSize: disk,
-						GpuCount:    gpu,
-					},
-				}
-			}
+		if force {
+			logrus.Println("'-f,--force' does nothing yet")
 		}
 
-		hosts, err := clusterInstance.AddNodes(concurrency.RootTask(), count, nodesDef)
+		err = clusterInstance.Delete(concurrency.RootTask())
 		if err != nil {
 			return clitools.FailureResponse(clitools.ExitOnRPC(err.Error()))
 		}
-		return clitools.SuccessResponse(hosts)
+		return clitools.SuccessResponse(nil)
 	},
 }
 
-// clusterShrinkCommand handles 'deploy cluster <clustername> shrink'
-var clusterShrinkCommand = cli.Command{
-	Name:      "shrink",
-	Usage:     "shrink CLUSTERNAME",
+// clusterStopCmd handles 'deploy cluster <clustername> stop'
+var clusterStopCommand = cli.Command{
+	Name:      "stop",
+	Aliases:   []string{"freeze", "halt"},
+	Usage:     "stop CLUSTERNAME",
 	ArgsUsage: "CLUSTERNAME",
 
 	// 	Help: &cli.HelpContent{
 	// 		Usage: `
-	// Usage: {{.ProgName}} [options] cluster <clustername> shrink [{command options}]`,
-	// 		Options: []string{
-	// 			`
-	// command options:
-	//   -n,--count <number of nodes>  Number of nodes to delete from the cluster`,
-	// 		},
+	// Usage: deploy [options] cluster <clustername> stop|freeze`,
 	// 		Description: `
-	// Shrink cluster by removing last added node(s).`,
+	// Stop the cluster (make it unavailable for duty).`,
 	// 	},
 
+	Action: func(c *cli.Context) error {
+		logrus.Tracef("SafeScale command: {%s}, {%s} with args {%s}", clusterCommandName, c.Command.Name, c.Args())
+		err := extractClusterArgument(c)
+		if err != nil {
+			return clitools.FailureResponse(err)
+		}
+		err = clusterInstance.Stop(concurrency.RootTask())
+		if err != nil {
+			return clitools.FailureResponse(clitools.ExitOnRPC(err.Error()))
+		}
+		return clitools.SuccessResponse(nil)
+	},
+}
+
+// clusterUpgradeCommand handles 'safescale cluster upgrade CLUSTERNAME'
+var clusterUpgradeCommand = cli.Command{
+	Name:      "upgrade",
+	Usage:     "upgrade CLUSTERNAME",
+	ArgsUsage: "CLUSTERNAME",
+
 	Flags: []cli.Flag{
-		cli.UintFlag{
-			Name:  "count, n",
-			Usage: "Define the number of nodes to remove; default: 1",
+		cli.BoolFlag{
+			Name:  "os-patches",
+			Usage: "Upgrades the packages of the OS of the masters and nodes (and reboots them)",
+		},
+		cli.StringFlag{
+			Name:  "k8s-version, platform-version",
+			Usage: "Upgrades the cluster manager (Kubernetes, Docker, ...) to this version",
+		},
+		cli.IntFlag{
+			Name:  "batch",
 			Value: 1,
+			Usage: "Number of nodes upgraded at the same time (masters are upgraded one at a time)",
 		},
 		cli.BoolFlag{
-			Name:  "assume-yes, yes, y",
-			Usage: "Don't ask deletion confirmation",
+			Name:  "status",
+			Usage: "Displays the progress of the last upgrade instead of upgrading",
 		},
 	},
 
@@ -835,61 +1153,168 @@ var clusterShrinkCommand = cli.Command{
 			return clitools.FailureResponse(err)
 		}
 
-		count := c.Uint("count")
-		yes := c.Bool("yes")
+		if c.Bool("status") {
+			upgrade, err := clusterInstance.GetUpgrade(concurrency.RootTask())
+			if err != nil {
+				return clitools.FailureResponse(clitools.ExitOnRPC(err.Error()))
+			}
+			return clitools.SuccessResponse(upgrade)
+		}
 
-		var countS string
-		if count > 1 {
-			countS = "s"
+		osPatches := c.Bool("os-patches")
+		version := c.String("k8s-version")
+		if !osPatches && version == "" {
+			_ = cli.ShowSubcommandHelp(c)
+			return clitools.FailureResponse(clitools.ExitOnInvalidArgument("Missing mandatory option --os-patches and/or --k8s-version."))
 		}
-		present, err := clusterInstance.CountNodes(concurrency.RootTask())
+		batch := c.Int("batch")
+		if batch <= 0 {
+			return clitools.FailureResponse(clitools.ExitOnInvalidArgument("Invalid value of option --batch: must be greater than 0."))
+		}
+
+		upgrade, err := clusterInstance.Upgrade(concurrency.RootTask(), osPatches, version, batch)
+		if err != nil {
+			msg := err.Error()
+			if upgrade != nil {
+				msg += fmt.Sprintf("\nRun 'safescale cluster upgrade %s' with the same options to resume the upgrade.", clusterName)
+			}
+			return clitools.FailureResponse(clitools.ExitOnRPC(msg))
+		}
+		return clitools.SuccessResponse(upgrade)
+	},
+}
+
+// clusterBackupCommand handles 'safescale cluster backup CLUSTERNAME' and 'safescale cluster backup ...'
+var clusterBackupCommand = cli.Command{
+	Name:      "backup",
+	Usage:     "backup --bucket BUCKET [--keep N] CLUSTERNAME",
+	ArgsUsage: "CLUSTERNAME|COMMAND",
+
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "bucket",
+			Usage: "Define the bucket where the backup is stored (mandatory, cannot be the metadata bucket of the tenant)",
+		},
+		cli.UintFlag{
+			Name:  "keep",
+			Usage: "Delete the oldest backups to keep only this number of backups (default: keep all)",
+		},
+	},
+
+	Subcommands: []cli.Command{
+		clusterBackupListCommand,
+		clusterBackupDeleteCommand,
+		clusterBackupScheduleCommand,
+	},
+
+	Action: func(c *cli.Context) error {
+		logrus.Tracef("SafeScale command: {%s}, {%s} with args {%s}", clusterCommandName, c.Command.Name, c.Args())
+		err := extractClusterArgument(c)
 		if err != nil {
 			return clitools.FailureResponse(err)
 		}
-		if count > present {
-			msg := fmt.Sprintf("cannot delete %d node%s, the cluster contains only %d of them", count, countS, present)
-			return clitools.FailureResponse(clitools.ExitOnInvalidOption(msg))
+
+		if c.String("bucket") == "" {
+			_ = cli.ShowSubcommandHelp(c)
+			return clitools.FailureResponse(clitools.ExitOnInvalidOption("Missing mandatory option --bucket."))
 		}
 
-		if !yes {
-			msg := fmt.Sprintf("Are you sure you want to delete %d node%s from Cluster %s", count, countS, clusterName)
-			if !utils.UserConfirmed(msg) {
-				return clitools.SuccessResponse("Aborted")
+		backup, err := clusterInstance.Backup(concurrency.RootTask(), c.String("bucket"))
+		if err != nil {
+			return clitools.FailureResponse(clitools.ExitOnRPC(err.Error()))
+		}
+		if keep := int(c.Uint("keep")); keep > 0 {
+			_, err = clusterInstance.PruneBackups(concurrency.RootTask(), keep)
+			if err != nil {
+				return clitools.FailureResponse(clitools.ExitOnRPC(err.Error()))
 			}
 		}
+		return clitools.SuccessResponse(backup)
+	},
+}
 
-		// fmt.Printf("Deleting %d node%s from Cluster '%s' (this may take a while)...\n", count, countS, clusterName)
-		var msgs []string
-		availableMaster, err := clusterInstance.FindAvailableMaster(concurrency.RootTask())
+// clusterBackupListCommand handles 'safescale cluster backup list CLUSTERNAME'
+var clusterBackupListCommand = cli.Command{
+	Name:      "list",
+	Aliases:   []string{"ls"},
+	Usage:     "list CLUSTERNAME",
+	ArgsUsage: "CLUSTERNAME",
+
+	Action: func(c *cli.Context) error {
+		logrus.Tracef("SafeScale command: {%s}, {%s} with args {%s}", clusterCommandName, c.Command.Name, c.Args())
+		err := extractClusterArgument(c)
 		if err != nil {
 			return clitools.FailureResponse(err)
 		}
-		for i := uint(0); i < count; i++ {
-			err := clusterInstance.DeleteLastNode(concurrency.RootTask(), availableMaster)
-			if err != nil {
-				msgs = append(msgs, fmt.Sprintf("failed to delete node #%d: %s", i+1, err.Error()))
-			}
+
+		list, err := clusterInstance.ListBackups(concurrency.RootTask())
+		if err != nil {
+			return clitools.FailureResponse(clitools.ExitOnRPC(err.Error()))
 		}
-		if len(msgs) > 0 {
-			return clitools.FailureResponse(clitools.ExitOnRPC(strings.Join(msgs, "\n")))
+		return clitools.SuccessResponse(list)
+	},
+}
+
+// clusterBackupDeleteCommand handles 'safescale cluster backup delete CLUSTERNAME BACKUPID'
+var clusterBackupDeleteCommand = cli.Command{
+	Name:      "delete",
+	Aliases:   []string{"rm", "remove"},
+	Usage:     "delete CLUSTERNAME BACKUPID",
+	ArgsUsage: "CLUSTERNAME BACKUPID",
+
+	Action: func(c *cli.Context) error {
+		logrus.Tracef("SafeScale command: {%s}, {%s} with args {%s}", clusterCommandName, c.Command.Name, c.Args())
+		err := extractClusterArgument(c)
+		if err != nil {
+			return clitools.FailureResponse(err)
+		}
+		backupID := c.Args().Get(1)
+		if backupID == "" {
+			_ = cli.ShowSubcommandHelp(c)
+			return clitools.FailureResponse(clitools.ExitOnInvalidArgument("Missing mandatory argument BACKUPID."))
+		}
+
+		err = clusterInstance.DeleteBackup(concurrency.RootTask(), backupID)
+		if err != nil {
+			return clitools.FailureResponse(clitools.ExitOnRPC(err.Error()))
 		}
 		return clitools.SuccessResponse(nil)
 	},
 }
 
-var clusterDcosCommand = cli.Command{
-	Name:      "dcos",
-	Category:  "Administrative commands",
-	Usage:     "dcos CLUSTERNAME [COMMAND ...]",
+// clusterBackupScheduleCommand handles 'safescale cluster backup schedule ...'
+var clusterBackupScheduleCommand = cli.Command{
+	Name:      "schedule",
+	Usage:     "manage the periodic backups of clusters run by safescaled",
+	ArgsUsage: "COMMAND",
+
+	Subcommands: []cli.Command{
+		clusterBackupScheduleEnableCommand,
+		clusterBackupScheduleDisableCommand,
+		clusterBackupScheduleListCommand,
+	},
+}
+
+// clusterBackupScheduleEnableCommand handles 'safescale cluster backup schedule enable CLUSTERNAME'
+var clusterBackupScheduleEnableCommand = cli.Command{
+	Name:      "enable",
+	Usage:     "enable CLUSTERNAME",
 	ArgsUsage: "CLUSTERNAME",
 
-	// 	Help: &cli.HelpContent{
-	// 		Usage: `
-	// Usage: deploy [options] cluster <clustername> dcos [<arg>...]`,
-	// 		Description: `
-	// Executes DCOS cli on an available DCOS master.
-	// Is meaningful only for a cluster using DCOS flavor.`,
-	// 	},
+	Flags: []cli.Flag{
+		cli.UintFlag{
+			Name:  "interval",
+			Usage: "Define the delay in seconds between 2 backups (default: 86400)",
+		},
+		cli.UintFlag{
+			Name:  "keep",
+			Usage: "Define the number of backups kept (default: 7)",
+		},
+		cli.StringFlag{
+			Name:  "bucket",
+			Usage: "Define the bucket where the backups are stored (mandatory, cannot be the metadata bucket of the tenant)",
+		},
+	},
 
 	Action: func(c *cli.Context) error {
 		logrus.Tracef("SafeScale command: {%s}, {%s} with args {%s}", clusterCommandName, c.Command.Name, c.Args())
@@ -898,32 +1323,31 @@ var clusterDcosCommand = cli.Command{
 			return clitools.FailureResponse(err)
 		}
 
-		identity := clusterInstance.GetIdentity(concurrency.RootTask())
-		if identity.Flavor != flavor.DCOS {
-			msg := fmt.Sprintf("Can't call dcos on this cluster, its flavor isn't DCOS (%s).\n", identity.Flavor.String())
-			return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.NotApplicable, msg))
+		if c.String("bucket") == "" {
+			_ = cli.ShowSubcommandHelp(c)
+			return clitools.FailureResponse(clitools.ExitOnInvalidOption("Missing mandatory option --bucket."))
 		}
 
-		args := c.Args().Tail()
-		cmdStr := "sudo -u cladm -i dcos " + strings.Join(args, " ")
-		return executeCommand(cmdStr, nil, outputs.DISPLAY)
+		policy := pb.BackupPolicy{
+			Cluster:  clusterName,
+			Interval: int32(c.Uint("interval")),
+			Keep:     int32(c.Uint("keep")),
+			Bucket:   c.String("bucket"),
+		}
+		bs, err := client.New().BackupScheduler.Enable(policy, temporal.GetExecutionTimeout())
+		if err != nil {
+			return clitools.FailureResponse(clitools.ExitOnRPC(err.Error()))
+		}
+		return clitools.SuccessResponse(bs)
 	},
 }
 
-var clusterKubectlCommand = cli.Command{
-	Name:      "kubectl",
-	Category:  "Administrative commands",
-	Usage:     "kubectl CLUSTERNAME [KUBECTL_COMMAND]... [-- [KUBECTL_OPTIONS]...]",
+// clusterBackupScheduleDisableCommand handles 'safescale cluster backup schedule disable CLUSTERNAME'
+var clusterBackupScheduleDisableCommand = cli.Command{
+	Name:      "disable",
+	Usage:     "disable CLUSTERNAME",
 	ArgsUsage: "CLUSTERNAME",
 
-	// 	Help: &cli.HelpContent{
-	// 		Usage: `
-	// Usage: deploy [options] cluster <clustername> kubectl [-- <arg>...]`,
-	// 		Description: `
-	// Executes kubectl cli on the cluster.
-	// Is meaningful only for a cluster where Kubernetes service is installed and running.`,
-	// 	},
-
 	Action: func(c *cli.Context) error {
 		logrus.Tracef("SafeScale command: {%s}, {%s} with args {%s}", clusterCommandName, c.Command.Name, c.Args())
 		err := extractClusterArgument(c)
@@ -931,183 +1355,1458 @@ var clusterKubectlCommand = cli.Command{
 			return clitools.FailureResponse(err)
 		}
 
-		clientID := GenerateClientIdentity()
-		args := c.Args().Tail()
-		var filteredArgs []string
-		ignoreNext := false
-		valuesOnRemote := &RemoteFilesHandler{}
-		urlRegex := regexp.MustCompile("^(http|ftp)[s]?://")
-		for idx, arg := range args {
-			if ignoreNext {
-				ignoreNext = false
-				continue
-			}
-			ignore := false
-			switch arg {
-			case "--":
-				ignore = true
-			case "-f":
-				if idx+1 < len(args) {
-					localFile := args[idx+1]
-					if localFile != "" {
-						// If it's an URL, propagate as-is
-						if urlRegex.MatchString(localFile) {
-							filteredArgs = append(filteredArgs, "-f")
-							filteredArgs = append(filteredArgs, localFile)
-							ignore = true
-							ignoreNext = true
-							continue
-						}
+		err = client.New().BackupScheduler.Disable(clusterName, temporal.GetExecutionTimeout())
+		if err != nil {
+			return clitools.FailureResponse(clitools.ExitOnRPC(err.Error()))
+		}
+		return clitools.SuccessResponse(nil)
+	},
+}
 
-						// Check for file
-						st, err := os.Stat(localFile)
-						if err != nil {
-							return cli.NewExitError(err.Error(), 1)
-						}
-						// If it's a link, get the target of it
-						if st.Mode()&os.ModeSymlink == os.ModeSymlink {
-							link, err := filepath.EvalSymlinks(localFile)
-							if err != nil {
-								return cli.NewExitError(err.Error(), 1)
-							}
-							_, err = os.Stat(link)
-							if err != nil {
-								return cli.NewExitError(err.Error(), 1)
-							}
-						}
+// clusterBackupScheduleListCommand handles 'safescale cluster backup schedule list'
+var clusterBackupScheduleListCommand = cli.Command{
+	Name:    "list",
+	Aliases: []string{"ls"},
+	Usage:   "list the backup schedulers running, with their last results",
 
-						if localFile != "-" {
-							rfi := RemoteFileItem{
-								Local:  localFile,
-								Remote: fmt.Sprintf("%s/helm_values_%d.%s.%d.tmp", utils.TempFolder, idx+1, clientID, time.Now().UnixNano()),
-							}
-							valuesOnRemote.Add(&rfi)
-							filteredArgs = append(filteredArgs, "-f")
-							filteredArgs = append(filteredArgs, rfi.Remote)
-						} else {
-							// data comes from the standard input
-							return clitools.FailureResponse(fmt.Errorf("'-f -' is not yet supported"))
-						}
-						ignoreNext = true
-					}
-				}
-				ignore = true
-			}
-			if !ignore {
-				filteredArgs = append(filteredArgs, arg)
-			}
+	Action: func(c *cli.Context) error {
+		logrus.Tracef("SafeScale command: {%s}, {%s} with args {%s}", clusterCommandName, c.Command.Name, c.Args())
+
+		list, err := client.New().BackupScheduler.List(temporal.GetExecutionTimeout())
+		if err != nil {
+			return clitools.FailureResponse(clitools.ExitOnRPC(err.Error()))
 		}
-		cmdStr := "sudo -u cladm -i kubectl"
-		if len(filteredArgs) > 0 {
-			cmdStr += ` ` + strings.Join(filteredArgs, " ")
+		return clitools.SuccessResponse(list.GetBackupSchedulers())
+	},
+}
+
+// clusterRestoreCommand handles 'safescale cluster restore CLUSTERNAME BACKUPID'
+var clusterRestoreCommand = cli.Command{
+	Name:      "restore",
+	Usage:     "restore CLUSTERNAME BACKUPID",
+	ArgsUsage: "CLUSTERNAME BACKUPID",
+
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "yes, assume-yes, y",
+			Usage: "If set, respond automatically yes to all questions",
+		},
+	},
+
+	Action: func(c *cli.Context) error {
+		logrus.Tracef("SafeScale command: {%s}, {%s} with args {%s}", clusterCommandName, c.Command.Name, c.Args())
+		err := extractClusterArgument(c)
+		if err != nil {
+			return clitools.FailureResponse(err)
 		}
-		return executeCommand(cmdStr, valuesOnRemote, outputs.DISPLAY)
+		backupID := c.Args().Get(1)
+		if backupID == "" {
+			_ = cli.ShowSubcommandHelp(c)
+			return clitools.FailureResponse(clitools.ExitOnInvalidArgument("Missing mandatory argument BACKUPID."))
+		}
+		if !c.Bool("yes") && !utils.UserConfirmed(fmt.Sprintf("Are you sure you want to replace the masters of the cluster '%s' and restore the backup '%s'", clusterName, backupID)) {
+			return clitools.SuccessResponse("Aborted")
+		}
+
+		err = clusterInstance.RestoreBackup(concurrency.RootTask(), backupID)
+		if err != nil {
+			return clitools.FailureResponse(clitools.ExitOnRPC(err.Error()))
+		}
+		return clitools.SuccessResponse(nil)
 	},
 }
 
-var clusterHelmCommand = cli.Command{
-	Name:      "helm",
-	Category:  "Administrative commands",
-	Usage:     "helm CLUSTERNAME COMMAND [[--][PARAMS ...]]",
+var clusterExportCommand = cli.Command{
+	Name:      "export",
+	Usage:     "export [command_options] CLUSTERNAME",
 	ArgsUsage: "CLUSTERNAME",
 
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "format, f",
+			Value: "ssh-config",
+			Usage: "Format of the configuration: kubeconfig (flavors K8S and K3S), ssh-config, ansible-inventory or json",
+		},
+		cli.StringFlag{
+			Name:  "key-dir",
+			Usage: "Directory where the private keys and known hosts of the hosts are written (default: $HOME/.safescale/clusters/CLUSTERNAME)",
+		},
+		cli.StringFlag{
+			Name:  "output, o",
+			Usage: "File where the configuration is written (default: standard output)",
+		},
+	},
+
 	Action: func(c *cli.Context) error {
 		logrus.Tracef("SafeScale command: {%s}, {%s} with args {%s}", clusterCommandName, c.Command.Name, c.Args())
 		err := extractClusterArgument(c)
 		if err != nil {
 			return clitools.FailureResponse(err)
 		}
+		format, err := exportformat.Parse(c.String("format"))
+		if err != nil {
+			return clitools.FailureResponse(clitools.ExitOnInvalidOption(err.Error()))
+		}
+		keyDir := c.String("key-dir")
+		if keyDir == "" {
+			keyDir = "$HOME/.safescale/clusters/" + clusterName
+		}
+		keyDir = utils.AbsPathify(keyDir)
 
-		clientID := GenerateClientIdentity()
-		useTLS := " --tls"
-		var filteredArgs []string
-		args := c.Args().Tail()
-		ignoreNext := false
-		urlRegex := regexp.MustCompile("^(http|ftp)[s]?://")
-		valuesOnRemote := &RemoteFilesHandler{}
-		for idx, arg := range args {
-			if ignoreNext {
-				ignoreNext = false
-				continue
-			}
-			ignore := false
-			switch arg {
-			case "init":
-				if idx == 0 {
-					return cli.NewExitError("helm init is forbidden", int(exitcode.InvalidArgument))
-				}
-			case "search", "repo":
-				if idx == 0 {
-					useTLS = ""
+		export, err := clusterInstance.Export(concurrency.RootTask(), format, keyDir)
+		if err != nil {
+			return clitools.FailureResponse(clitools.ExitOnRPC(err.Error()))
+		}
+		if len(export.Files) > 0 {
+			err = os.MkdirAll(keyDir, 0700)
+			if err != nil {
+				return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.Run, err.Error()))
+			}
+			for path, content := range export.Files {
+				err = ioutil.WriteFile(path, []byte(content), 0600)
+				if err != nil {
+					return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.Run, err.Error()))
 				}
-			case "--":
-				ignore = true
-			case "-f", "--values":
-				if idx+1 < len(args) {
-					localFile := args[idx+1]
-					if localFile != "" {
-						// If it's an URL, filter as-is
-						if urlRegex.MatchString(localFile) {
-							filteredArgs = append(filteredArgs, "-f")
-							filteredArgs = append(filteredArgs, localFile)
-							ignore = true
-							ignoreNext = true
-							continue
-						}
+			}
+		}
 
-						// Check for file
-						st, err := os.Stat(localFile)
-						if err != nil {
-							return cli.NewExitError(err.Error(), 1)
-						}
-						// If it's a link, get the target of it
-						if st.Mode()&os.ModeSymlink == os.ModeSymlink {
-							link, err := filepath.EvalSymlinks(localFile)
-							if err != nil {
-								return cli.NewExitError(err.Error(), 1)
-							}
-							_, err = os.Stat(link)
-							if err != nil {
-								return cli.NewExitError(err.Error(), 1)
-							}
-						}
+		output := c.String("output")
+		if output == "" {
+			fmt.Print(export.Content)
+			return nil
+		}
+		err = ioutil.WriteFile(utils.AbsPathify(output), []byte(export.Content), 0600)
+		if err != nil {
+			return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.Run, err.Error()))
+		}
+		return clitools.SuccessResponse(nil)
+	},
+}
 
-						if localFile != "-" {
-							rfc := RemoteFileItem{
-								Local:  localFile,
-								Remote: fmt.Sprintf("%s/helm_values_%d.%s.%d.tmp", utils.TempFolder, idx+1, clientID, time.Now().UnixNano()),
-							}
-							valuesOnRemote.Add(&rfc)
-							filteredArgs = append(filteredArgs, "-f")
-							filteredArgs = append(filteredArgs, rfc.Remote)
-						} else {
-							// data comes from the standard input
-							return clitools.ExitOnErrorWithMessage(exitcode.NotImplemented, "'-f -' is not yet supported")
-						}
-						ignoreNext = true
-					}
-				}
-				ignore = true
+var clusterStartCommand = cli.Command{
+	Name:      "start",
+	Aliases:   []string{"unfreeze"},
+	Usage:     "start CLUSTERNAME",
+	ArgsUsage: "CLUSTERNAME",
+	// 	Help: &cli.HelpContent{
+	// 		Usage: `
+	// Usage: {{.ProgName}} [options] cluster <clustername> start|unfreeze`,
+	// 		Options: []string{`
+	// options:
+	//   --force, -f Force Don't ask for confirmation`,
+	// 		},
+	// 		Description: `
+	// Start the cluster (make it available for duty).`,
+	// 	},
+	Action: func(c *cli.Context) error {
+		logrus.Tracef("SafeScale command: {%s}, {%s} with args {%s}", clusterCommandName, c.Command.Name, c.Args())
+		err := extractClusterArgument(c)
+		if err != nil {
+			return clitools.FailureResponse(err)
+		}
+		err = clusterInstance.Start(concurrency.RootTask())
+		if err != nil {
+			return clitools.FailureResponse(clitools.ExitOnRPC(err.Error()))
+		}
+		return clitools.SuccessResponse(nil)
+	},
+}
+
+// clusterStateCmd handles 'deploy cluster <clustername> state'
+var clusterStateCommand = cli.Command{
+	Name:      "state",
+	Usage:     "state CLUSTERNAME",
+	ArgsUsage: "CLUSTERNAME",
+
+	// 	Help: &cli.HelpContent{
+	// 		Usage: `
+	// Usage: {{.ProgName}} [options] cluster <clustername> state`,
+	// 		Description: `
+	// Get the cluster state.`,
+	// 	},
+	Action: func(c *cli.Context) error {
+		logrus.Tracef("SafeScale command: {%s}, {%s} with args {%s}", clusterCommandName, c.Command.Name, c.Args())
+		err := extractClusterArgument(c)
+		if err != nil {
+			return clitools.FailureResponse(err)
+		}
+		state, err := clusterInstance.GetState(concurrency.RootTask())
+		if err != nil {
+			msg := fmt.Sprintf("failed to get cluster state: %s", err.Error())
+			return clitools.FailureResponse(clitools.ExitOnRPC(msg))
+		}
+		return clitools.SuccessResponse(map[string]interface{}{
+			"Name":       clusterName,
+			"State":      state,
+			"StateLabel": state.String(),
+		})
+	},
+}
+
+// clusterExpandCmd handles 'deploy cluster <clustername> expand'
+var clusterExpandCommand = cli.Command{
+	Name:      "expand",
+	Usage:     "expand CLUSTERNAME",
+	ArgsUsage: "CLUSTERNAME",
+
+	// 	Help: &cli.HelpContent{
+	// 		Usage: `
+	// Usage: deploy [options] cluster <clustername> expand [{command options}] [{host options}]`,
+	// 		Options: []string{
+	// 			`
+	// command options:
+	//   --count,-n <number of nodes> Instructs to expand cluster with <number> of new nodes
+	//   --public,-p                  Allocates public IP address(es) to node(s)`,
+	// 			`
+	// host options:
+	//   --os <operating system> (default: Ubuntu 18.04)
+	//   --cpu <number of cpus> (default: 4)
+	//   --ram <ram size) (default: 15 GB)
+	//   --disk <disk size> (default: 100 GB)`,
+	// 		},
+	// 		Description: `
+	// Expand the cluster by adding nodes.`,
+	// 	},
+
+	Flags: []cli.Flag{
+		cli.UintFlag{
+			Name:  "count, n",
+			Usage: "Define the number of nodes wanted (default: 1)",
+			Value: 1,
+		},
+		cli.StringFlag{
+			Name:  "pool",
+			Usage: "Define the node pool where to add the nodes (default: the default pool)",
+		},
+		cli.StringFlag{
+			Name:  "os",
+			Usage: "Define the Operating System wanted",
+		},
+		cli.UintFlag{
+			Name:  "cpu",
+			Usage: "Define the number of cpu for new node(s); default: number used at cluster creation",
+			Value: 0,
+		},
+		cli.Float64Flag{
+			Name:  "ram",
+			Usage: "Define the size of RAM for new node(s) (in GB); default: size used at cluster creation",
+			Value: 0.0,
+		},
+		cli.UintFlag{
+			Name:  "disk",
+			Usage: "Define the size of system disk for new node(s) (in GB); default: size used at cluster creation",
+			Value: 0,
+		},
+		cli.BoolFlag{
+			Name:   "gpu",
+			Usage:  "Ask for gpu capable host; default: no",
+			Hidden: true,
+		},
+		cli.StringFlag{
+			Name: "node-sizing",
+			Usage: `Describe node sizing in format "<component><operator><value>[,...]" where:
+	<component> can be cpu, cpufreq, gpu, ram, disk, os
+	<operator> can be =,<,> (except for disk where valid operators are only = or >)
+	<value> can be an integer (for cpu and disk) or a float (for ram) or an including interval "[<lower value>-<upper value>]"`,
+		},
+	},
+	Action: func(c *cli.Context) error {
+		logrus.Tracef("SafeScale command: {%s}, {%s} with args {%s}", clusterCommandName, c.Command.Name, c.Args())
+		err := extractClusterArgument(c)
+		if err != nil {
+			return clitools.FailureResponse(err)
+		}
+
+		count := int(c.Uint("count"))
+		if count == 0 {
+			count = 1
+		}
+		los := c.String("os")
+
+		var nodesDef *pb.HostDefinition
+		//		if c.IsSet("node-sizing") {
+		nodesDef, err = constructPBHostDefinitionFromCLI(c, "node-sizing")
+		if err != nil {
+			return err
+		}
+		//		}
+
+		if nodesDef == nil {
+			cpu := int32(c.Uint("cpu"))
+			ram := float32(c.Float64("ram"))
+			disk := int32(c.Uint("disk"))
+			gpu := int32(c.Uint("gpu"))
+			if gpu == 0 {
+				gpu = -1
 			}
-			if !ignore {
-				filteredArgs = append(filteredArgs, arg)
+			if cpu > 0 || ram > 0.0 || disk > 0 || gpu >= 1 || los != "" {
+				nodesDef = &pb.HostDefinition{
+					ImageId: los,
+					Sizing: &pb.HostSizing{
+						MinCpuCount: cpu,
+						MaxCpuCount: cpu * 2,
+						MinRamSize:  ram,
+						MaxRamSize:  ram * 2.0,
+						MinDiskSize: disk,
+						GpuCount:    gpu,
+					},
+				}
 			}
 		}
-		cmdStr := `sudo -u cladm -i helm ` + strings.Join(filteredArgs, " ") + useTLS
-		return executeCommand(cmdStr, valuesOnRemote, outputs.DISPLAY)
+
+		hosts, err := clusterInstance.AddNodes(concurrency.RootTask(), c.String("pool"), count, nodesDef)
+		if err != nil {
+			return clitools.FailureResponse(clitools.ExitOnRPC(err.Error()))
+		}
+		return clitools.SuccessResponse(hosts)
 	},
 }
 
-var clusterRunCommand = cli.Command{
-	Name:      "run",
-	Aliases:   []string{"execute", "exec"},
-	Usage:     "run CLUSTERNAME COMMAND",
+// clusterShrinkCommand handles 'deploy cluster <clustername> shrink'
+var clusterShrinkCommand = cli.Command{
+	Name:      "shrink",
+	Usage:     "shrink CLUSTERNAME",
 	ArgsUsage: "CLUSTERNAME",
 
 	// 	Help: &cli.HelpContent{
 	// 		Usage: `
-	// Usage: {{.ProgName}} [options] cluster list,ls
-	//        {{.ProgName}} [options] cluster <clustername> run args[,args...]`,
+	// Usage: {{.ProgName}} [options] cluster <clustername> shrink [{command options}]`,
+	// 		Options: []string{
+	// 			`
+	// command options:
+	//   -n,--count <number of nodes>  Number of nodes to delete from the cluster`,
+	// 		},
+	// 		Description: `
+	// Shrink cluster by removing last added node(s).`,
+	// 	},
+
+	Flags: []cli.Flag{
+		cli.UintFlag{
+			Name:  "count, n",
+			Usage: "Define the number of nodes to remove; default: 1",
+			Value: 1,
+		},
+		cli.StringFlag{
+			Name:  "pool",
+			Usage: "Define the node pool where to remove the nodes (default: the default pool)",
+		},
+		cli.BoolFlag{
+			Name:  "assume-yes, yes, y",
+			Usage: "Don't ask deletion confirmation",
+		},
+	},
+
+	Action: func(c *cli.Context) error {
+		logrus.Tracef("SafeScale command: {%s}, {%s} with args {%s}", clusterCommandName, c.Command.Name, c.Args())
+		err := extractClusterArgument(c)
+		if err != nil {
+			return clitools.FailureResponse(err)
+		}
+
+		count := c.Uint("count")
+		yes := c.Bool("yes")
+		poolName := c.String("pool")
+		if poolName == "" {
+			poolName = control.DefaultNodePoolName
+		}
+
+		var countS string
+		if count > 1 {
+			countS = "s"
+		}
+		pool, err := findClusterNodePool(poolName)
+		if err != nil {
+			return clitools.FailureResponse(err)
+		}
+		present := uint(len(pool.Nodes))
+		if count > present {
+			msg := fmt.Sprintf("cannot delete %d node%s, the node pool '%s' contains only %d of them", count, countS, poolName, present)
+			return clitools.FailureResponse(clitools.ExitOnInvalidOption(msg))
+		}
+
+		if !yes {
+			msg := fmt.Sprintf("Are you sure you want to delete %d node%s from Cluster %s", count, countS, clusterName)
+			if !utils.UserConfirmed(msg) {
+				return clitools.SuccessResponse("Aborted")
+			}
+		}
+
+		// fmt.Printf("Deleting %d node%s from Cluster '%s' (this may take a while)...\n", count, countS, clusterName)
+		var msgs []string
+		availableMaster, err := clusterInstance.FindAvailableMaster(concurrency.RootTask())
+		if err != nil {
+			return clitools.FailureResponse(err)
+		}
+		for i := uint(0); i < count; i++ {
+			err := clusterInstance.DeleteLastNode(concurrency.RootTask(), poolName, availableMaster)
+			if err != nil {
+				msgs = append(msgs, fmt.Sprintf("failed to delete node #%d: %s", i+1, err.Error()))
+			}
+		}
+		if len(msgs) > 0 {
+			return clitools.FailureResponse(clitools.ExitOnRPC(strings.Join(msgs, "\n")))
+		}
+		return clitools.SuccessResponse(nil)
+	},
+}
+
+var clusterDcosCommand = cli.Command{
+	Name:      "dcos",
+	Category:  "Administrative commands",
+	Usage:     "dcos CLUSTERNAME [COMMAND ...]",
+	ArgsUsage: "CLUSTERNAME",
+
+	// 	Help: &cli.HelpContent{
+	// 		Usage: `
+	// Usage: deploy [options] cluster <clustername> dcos [<arg>...]`,
+	// 		Description: `
+	// Executes DCOS cli on an available DCOS master.
+	// Is meaningful only for a cluster using DCOS flavor.`,
+	// 	},
+
+	Action: func(c *cli.Context) error {
+		logrus.Tracef("SafeScale command: {%s}, {%s} with args {%s}", clusterCommandName, c.Command.Name, c.Args())
+		err := extractClusterArgument(c)
+		if err != nil {
+			return clitools.FailureResponse(err)
+		}
+
+		identity := clusterInstance.GetIdentity(concurrency.RootTask())
+		if identity.Flavor != flavor.DCOS {
+			msg := fmt.Sprintf("Can't call dcos on this cluster, its flavor isn't DCOS (%s).\n", identity.Flavor.String())
+			return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.NotApplicable, msg))
+		}
+
+		args := c.Args().Tail()
+		cmdStr := "sudo -u cladm -i dcos " + strings.Join(args, " ")
+		return executeCommand(cmdStr, nil, outputs.DISPLAY)
+	},
+}
+
+var clusterKubectlCommand = cli.Command{
+	Name:      "kubectl",
+	Category:  "Administrative commands",
+	Usage:     "kubectl CLUSTERNAME [KUBECTL_COMMAND]... [-- [KUBECTL_OPTIONS]...]",
+	ArgsUsage: "CLUSTERNAME",
+
+	// 	Help: &cli.HelpContent{
+	// 		Usage: `
+	// Usage: deploy [options] cluster <clustername> kubectl [-- <arg>...]`,
+	// 		Description: `
+	// Executes kubectl cli on the cluster.
+	// Is meaningful only for a cluster where Kubernetes service is installed and running.`,
+	// 	},
+
+	Action: func(c *cli.Context) error {
+		logrus.Tracef("SafeScale command: {%s}, {%s} with args {%s}", clusterCommandName, c.Command.Name, c.Args())
+		err := extractClusterArgument(c)
+		if err != nil {
+			return clitools.FailureResponse(err)
+		}
+
+		clientID := GenerateClientIdentity()
+		args := c.Args().Tail()
+		var filteredArgs []string
+		ignoreNext := false
+		valuesOnRemote := &RemoteFilesHandler{}
+		urlRegex := regexp.MustCompile("^(http|ftp)[s]?://")
+		for idx, arg := range args {
+			if ignoreNext {
+				ignoreNext = false
+				continue
+			}
+			ignore := false
+			switch arg {
+			case "--":
+				ignore = true
+			case "-f":
+				if idx+1 < len(args) {
+					localFile := args[idx+1]
+					if localFile != "" {
+						// If it's an URL, propagate as-is
+						if urlRegex.MatchString(localFile) {
+							filteredArgs = append(filteredArgs, "-f")
+							filteredArgs = append(filteredArgs, localFile)
+							ignore = true
+							ignoreNext = true
+							continue
+						}
+
+						// Check for file
+						st, err := os.Stat(localFile)
+						if err != nil {
+							return cli.NewExitError(err.Error(), 1)
+						}
+						// If it's a link, get the target of it
+						if st.Mode()&os.ModeSymlink == os.ModeSymlink {
+							link, err := filepath.EvalSymlinks(localFile)
+							if err != nil {
+								return cli.NewExitError(err.Error(), 1)
+							}
+							_, err = os.Stat(link)
+							if err != nil {
+								return cli.NewExitError(err.Error(), 1)
+							}
+						}
+
+						if localFile != "-" {
+							rfi := RemoteFileItem{
+								Local:  localFile,
+								Remote: fmt.Sprintf("%s/helm_values_%d.%s.%d.tmp", utils.TempFolder, idx+1, clientID, time.Now().UnixNano()),
+							}
+							valuesOnRemote.Add(&rfi)
+							filteredArgs = append(filteredArgs, "-f")
+							filteredArgs = append(filteredArgs, rfi.Remote)
+						} else {
+							// data comes from the standard input
+							return clitools.FailureResponse(fmt.Errorf("'-f -' is not yet supported"))
+						}
+						ignoreNext = true
+					}
+				}
+				ignore = true
+			}
+			if !ignore {
+				filteredArgs = append(filteredArgs, arg)
+			}
+		}
+		cmdStr := "sudo -u cladm -i kubectl"
+		if len(filteredArgs) > 0 {
+			cmdStr += ` ` + strings.Join(filteredArgs, " ")
+		}
+		return executeCommand(cmdStr, valuesOnRemote, outputs.DISPLAY)
+	},
+}
+
+var clusterNomadCommand = cli.Command{
+	Name:      "nomad",
+	Category:  "Administrative commands",
+	Usage:     "nomad CLUSTERNAME [NOMAD_COMMAND]... [-- [NOMAD_OPTIONS]...]",
+	ArgsUsage: "CLUSTERNAME",
+
+	Action: func(c *cli.Context) error {
+		logrus.Tracef("SafeScale command: {%s}, {%s} with args {%s}", clusterCommandName, c.Command.Name, c.Args())
+		err := extractClusterArgument(c)
+		if err != nil {
+			return clitools.FailureResponse(err)
+		}
+
+		identity := clusterInstance.GetIdentity(concurrency.RootTask())
+		if identity.Flavor != flavor.NOMAD {
+			msg := fmt.Sprintf("Can't call nomad on this cluster, its flavor isn't NOMAD (%s).\n", identity.Flavor.String())
+			return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.NotApplicable, msg))
+		}
+
+		clientID := GenerateClientIdentity()
+		args := c.Args().Tail()
+		var filteredArgs []string
+		ignoreNext := false
+		valuesOnRemote := &RemoteFilesHandler{}
+		for idx, arg := range args {
+			if ignoreNext {
+				ignoreNext = false
+				continue
+			}
+			localFile := ""
+			switch {
+			case arg == "--":
+				continue
+			case arg == "-var-file":
+				if idx+1 < len(args) {
+					filteredArgs = append(filteredArgs, arg)
+					localFile = args[idx+1]
+					ignoreNext = true
+				}
+			case strings.HasSuffix(arg, ".nomad") || strings.HasSuffix(arg, ".hcl") || strings.HasSuffix(arg, ".json"):
+				// job specifications are files on the local host, except if they don't exist there
+				if _, err := os.Stat(arg); err == nil {
+					localFile = arg
+				}
+			}
+			if localFile == "" {
+				filteredArgs = append(filteredArgs, arg)
+				continue
+			}
+
+			if localFile == "-" {
+				// data comes from the standard input
+				return clitools.FailureResponse(fmt.Errorf("'-' as file is not yet supported"))
+			}
+			// If it's a link, get the target of it
+			link, err := filepath.EvalSymlinks(localFile)
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+			rfi := RemoteFileItem{
+				Local:  link,
+				Remote: fmt.Sprintf("%s/nomad_%d.%s.%d%s", utils.TempFolder, idx+1, clientID, time.Now().UnixNano(), filepath.Ext(localFile)),
+			}
+			valuesOnRemote.Add(&rfi)
+			filteredArgs = append(filteredArgs, rfi.Remote)
+		}
+		cmdStr := "sudo -u cladm -i nomad"
+		if len(filteredArgs) > 0 {
+			cmdStr += ` ` + strings.Join(filteredArgs, " ")
+		}
+		return executeCommand(cmdStr, valuesOnRemote, outputs.DISPLAY)
+	},
+}
+
+var clusterHelmCommand = cli.Command{
+	Name:      "helm",
+	Category:  "Administrative commands",
+	Usage:     "helm CLUSTERNAME COMMAND [[--][PARAMS ...]]",
+	ArgsUsage: "CLUSTERNAME",
+
+	Action: func(c *cli.Context) error {
+		logrus.Tracef("SafeScale command: {%s}, {%s} with args {%s}", clusterCommandName, c.Command.Name, c.Args())
+		err := extractClusterArgument(c)
+		if err != nil {
+			return clitools.FailureResponse(err)
+		}
+
+		clientID := GenerateClientIdentity()
+		useTLS := " --tls"
+		var filteredArgs []string
+		args := c.Args().Tail()
+		ignoreNext := false
+		urlRegex := regexp.MustCompile("^(http|ftp)[s]?://")
+		valuesOnRemote := &RemoteFilesHandler{}
+		for idx, arg := range args {
+			if ignoreNext {
+				ignoreNext = false
+				continue
+			}
+			ignore := false
+			switch arg {
+			case "init":
+				if idx == 0 {
+					return cli.NewExitError("helm init is forbidden", int(exitcode.InvalidArgument))
+				}
+			case "search", "repo":
+				if idx == 0 {
+					useTLS = ""
+				}
+			case "--":
+				ignore = true
+			case "-f", "--values":
+				if idx+1 < len(args) {
+					localFile := args[idx+1]
+					if localFile != "" {
+						// If it's an URL, filter as-is
+						if urlRegex.MatchString(localFile) {
+							filteredArgs = append(filteredArgs, "-f")
+							filteredArgs = append(filteredArgs, localFile)
+							ignore = true
+							ignoreNext = true
+							continue
+						}
+
+						// Check for file
+						st, err := os.Stat(localFile)
+						if err != nil {
+							return cli.NewExitError(err.Error(), 1)
+						}
+						// If it's a link, get the target of it
+						if st.Mode()&os.ModeSymlink == os.ModeSymlink {
+							link, err := filepath.EvalSymlinks(localFile)
+							if err != nil {
+								return cli.NewExitError(err.Error(), 1)
+							}
+							_, err = os.Stat(link)
+							if err != nil {
+								return cli.NewExitError(err.Error(), 1)
+							}
+						}
+
+						if localFile != "-" {
+							rfc := RemoteFileItem{
+								Local:  localFile,
+								Remote: fmt.Sprintf("%s/helm_values_%d.%s.%d.tmp", utils.TempFolder, idx+1, clientID, time.Now().UnixNano()),
+							}
+							valuesOnRemote.Add(&rfc)
+							filteredArgs = append(filteredArgs, "-f")
+							filteredArgs = append(filteredArgs, rfc.Remote)
+						} else {
+							// data comes from the standard input
+							return clitools.ExitOnErrorWithMessage(exitcode.NotImplemented, "'-f -' is not yet supported")
+						}
+						ignoreNext = true
+					}
+				}
+				ignore = true
+			}
+			if !ignore {
+				filteredArgs = append(filteredArgs, arg)
+			}
+		}
+		cmdStr := `sudo -u cladm -i helm ` + strings.Join(filteredArgs, " ") + useTLS
+		return executeCommand(cmdStr, valuesOnRemote, outputs.DISPLAY)
+	},
+}
+
+var clusterRunCommand = cli.Command{
+	Name:      "run",
+	Aliases:   []string{"execute", "exec"},
+	Usage:     "run CLUSTERNAME COMMAND",
+	ArgsUsage: "CLUSTERNAME",
+
+	// 	Help: &cli.HelpContent{
+	// 		Usage: `
+	// Usage: {{.ProgName}} [options] cluster list,ls
+	//        {{.ProgName}} [options] cluster <clustername> run args[,args...]`,
+	// 	},
+
+	Action: func(c *cli.Context) error {
+		logrus.Tracef("SafeScale command: {%s}, {%s} with args {%s}", clusterCommandName, c.Command.Name, c.Args())
+		err := extractClusterArgument(c)
+		if err != nil {
+			return clitools.FailureResponse(err)
+		}
+
+		return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.NotImplemented, "clusterRunCmd not yet implemented"))
+	},
+}
+
+func executeCommand(command string, files *RemoteFilesHandler, outs outputs.Enum) error {
+	logrus.Debugf("command=[%s]", command)
+	master, err := clusterInstance.FindAvailableMaster(concurrency.RootTask())
+	if err != nil {
+		msg := fmt.Sprintf("No masters found available for the cluster '%s': %v", clusterInstance.GetIdentity(concurrency.RootTask()).Name, err.Error())
+		return clitools.ExitOnErrorWithMessage(exitcode.RPC, msg)
+	}
+
+	if files != nil && files.Count() > 0 {
+		if !Debug {
+			defer files.Cleanup(master)
+		}
+		err = files.Upload(master)
+		if err != nil {
+			return clitools.ExitOnErrorWithMessage(exitcode.RPC, err.Error())
+		}
+	}
+
+	safescalessh := client.New().SSH
+	retcode, stdout, stderr, err := safescalessh.Run(master, command, outs, temporal.GetConnectionTimeout(), temporal.GetExecutionTimeout())
+	if err != nil {
+		msg := fmt.Sprintf("failed to execute command on master '%s': %s", master, err.Error())
+		return clitools.ExitOnErrorWithMessage(exitcode.RPC, msg)
+	}
+	if retcode != 0 {
+		msg := fmt.Sprintf("command executed on master '%s' with failure: %s", master, stdout)
+		if stderr != "" {
+			if stdout != "" {
+				msg += "\n"
+			}
+			msg += stderr
+		}
+		return cli.NewExitError(msg, retcode)
+	}
+	return nil
+}
+
+// clusterCheckFeaturesCommand handles 'safescale cluster <cluster name or id> list-features'
+var clusterListFeaturesCommand = cli.Command{
+	Name:      "list-features",
+	Aliases:   []string{"list-available-features"},
+	Usage:     "list-features [--outdated CLUSTERNAME]",
+	ArgsUsage: "[CLUSTERNAME]",
+
+	Flags: []cli.Flag{
+		cli.StringSliceFlag{
+			Name:  "param, p",
+			Usage: "Allow to define content of feature parameters",
+		},
+		cli.BoolFlag{
+			Name:  "outdated",
+			Usage: "List the features installed on CLUSTERNAME for which a newer version is available",
+		},
+	},
+
+	Action: func(c *cli.Context) error {
+		logrus.Tracef("SafeScale command: {%s}, {%s} with args {%s}", clusterCommandName, c.Command.Name, c.Args())
+		if c.Bool("outdated") {
+			err := extractClusterArgument(c)
+			if err != nil {
+				return clitools.FailureResponse(err)
+			}
+			featuresV1, err := clusterInstalledFeatures(clusterInstance)
+			if err != nil {
+				return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.Run, err.Error()))
+			}
+			return clitools.SuccessResponse(outdatedFeatures(featuresV1.Installed))
+		}
+
+		features, err := install.ListFeatures("cluster")
+		if err != nil {
+			return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.Run, err.Error()))
+		}
+		return clitools.SuccessResponse(features)
+	},
+}
+
+// clusterAddFeatureCommand handles 'deploy cluster add-feature CLUSTERNAME FEATURENAME'
+var clusterAddFeatureCommand = cli.Command{
+	Name:      "add-feature",
+	Aliases:   []string{"install-feature"},
+	Usage:     "add-feature CLUSTERNAME FEATURENAME",
+	ArgsUsage: "CLUSTERNAME FEATURENAME",
+
+	Flags: []cli.Flag{
+		cli.StringSliceFlag{
+			Name:  "param, p",
+			Usage: "Allow to define content of feature parameters",
+		},
+		cli.BoolFlag{
+			Name:  "skip-proxy",
+			Usage: "Disables reverse proxy rules",
+		},
+		cli.BoolFlag{
+			Name:  "dry-run",
+			Usage: "Display what would be done (requirements, hosts, scripts, reverse proxy rules) without touching any host",
+		},
+	},
+
+	Action: func(c *cli.Context) error {
+		logrus.Tracef("SafeScale command: {%s}, {%s} with args {%s}", clusterCommandName, c.Command.Name, c.Args())
+		err := extractClusterArgument(c)
+		if err != nil {
+			return clitools.FailureResponse(err)
+		}
+		err = extractFeatureArgument(c)
+		if err != nil {
+			return clitools.FailureResponse(err)
+		}
+
+		feature, err := install.NewFeature(concurrency.RootTask(), featureName)
+		if err != nil {
+			return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.Run, err.Error()))
+		}
+		if feature == nil {
+			msg := fmt.Sprintf("failed to find a feature named '%s'.\n", featureName)
+			return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.NotFound, msg))
+		}
+
+		values := install.Variables{}
+		params := c.StringSlice("param")
+		for _, k := range params {
+			res := strings.Split(k, "=")
+			if len(res[0]) > 0 {
+				values[res[0]] = strings.Join(res[1:], "=")
+			}
+		}
+
+		settings := install.Settings{}
+		settings.SkipProxy = c.Bool("skip-proxy")
+
+		target, err := install.NewClusterTarget(concurrency.RootTask(), clusterInstance)
+		if err != nil {
+			return clitools.FailureResponse(err)
+		}
+		if c.Bool("dry-run") {
+			report, err := feature.DryRun(target, values, settings)
+			if err != nil {
+				msg := fmt.Sprintf("failed to resolve what adding feature '%s' on cluster '%s' would do: %s", featureName, clusterName, err.Error())
+				return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.Run, msg))
+			}
+			return clitools.SuccessResponse(report)
+		}
+		results, err := feature.Add(target, values, settings)
+		if err != nil {
+			msg := fmt.Sprintf("error installing feature '%s' on cluster '%s': %s\n", featureName, clusterName, err.Error())
+			return clitools.FailureResponse(clitools.ExitOnRPC(msg))
+		}
+		if !results.Successful() {
+			msg := fmt.Sprintf("failed to install feature '%s' on cluster '%s'", featureName, clusterName)
+			if Debug || Verbose {
+				msg += fmt.Sprintf(":\n%s", results.AllErrorMessages())
+			}
+			return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.Run, msg))
+		}
+		// Records the feature to install it on the nodes replacing failing ones
+		err = clusterInstance.RegisterFeature(concurrency.RootTask(), feature.DisplayName(), feature.Version(), feature.SealParameters(clusterInstance.GetService(concurrency.RootTask()), values))
+		if err != nil {
+			logrus.Warnf("failed to record feature '%s' in cluster metadata: %v", feature.DisplayName(), err)
+		}
+		return clitools.SuccessResponse(nil)
+	},
+}
+
+// clusterCheckFeatureCommand handles 'deploy cluster check-feature CLUSTERNAME FEATURENAME'
+var clusterCheckFeatureCommand = cli.Command{
+	Name:      "check-feature",
+	Aliases:   []string{"verify-feature"},
+	Usage:     "check-feature [--drift|--reconcile] CLUSTERNAME FEATURENAME",
+	ArgsUsage: "CLUSTERNAME FEATURENAME",
+	Flags: append([]cli.Flag{
+		cli.StringSliceFlag{
+			Name:  "param, p",
+			Usage: "Allow to define content of feature parameters",
+		},
+	}, featureVerifyFlags...),
+	Action: func(c *cli.Context) error {
+		logrus.Tracef("SafeScale command: {%s}, {%s} with args {%s}", clusterCommandName, c.Command.Name, c.Args())
+		err := extractClusterArgument(c)
+		if err != nil {
+			return clitools.FailureResponse(err)
+		}
+		err = extractFeatureArgument(c)
+		if err != nil {
+			return clitools.FailureResponse(err)
+		}
+		feature, err := install.NewFeature(concurrency.RootTask(), featureName)
+		if err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err.Error())
+			return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.Run, err.Error()))
+		}
+		if feature == nil {
+			msg := fmt.Sprintf("failed to find a feature named '%s'.\n", featureName)
+			return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.NotFound, msg))
+		}
+
+		values := install.Variables{}
+		params := c.StringSlice("param")
+		for _, k := range params {
+			res := strings.Split(k, "=")
+			if len(res[0]) > 0 {
+				values[res[0]] = strings.Join(res[1:], "=")
+			}
+		}
+
+		settings := install.Settings{}
+
+		target, err := install.NewClusterTarget(concurrency.RootTask(), clusterInstance)
+		if err != nil {
+			return clitools.FailureResponse(err)
+		}
+		results, err := feature.Check(target, values, settings)
+		if err != nil {
+			msg := fmt.Sprintf("error checking if feature '%s' is installed on '%s': %s\n", featureName, clusterName, err.Error())
+			return clitools.FailureResponse(clitools.ExitOnRPC(msg))
+		}
+
+		if !results.Successful() {
+			msg := fmt.Sprintf("Feature '%s' not found on cluster '%s'", featureName, clusterName)
+			if Verbose || Debug {
+				msg += fmt.Sprintf(":\n%s", results.AllErrorMessages())
+			}
+			return clitools.FailureResponse(clitools.ExitOnNotFound(msg))
+		}
+		if c.Bool("drift") || c.Bool("reconcile") {
+			return verifyFeature(c, feature, target, values)
+		}
+		msg := fmt.Sprintf("Feature '%s' found on cluster '%s'", featureName, clusterName)
+		return clitools.SuccessResponse(msg)
+	},
+}
+
+// clusterFeatureDeleteCommand handles 'deploy host <host name or id> package <pkgname> delete'
+var clusterDeleteFeatureCommand = cli.Command{
+	Name:      "delete-feature",
+	Aliases:   []string{"destroy-feature", "remove-feature", "rm-feature", "uninstall-feature"},
+	Usage:     "delete-feature CLUSTERNAME FEATURENAME",
+	ArgsUsage: "CLUSTERNAME FEATURENAME",
+	Flags: []cli.Flag{
+		cli.StringSliceFlag{
+			Name:  "param, p",
+			Usage: "Allow to define content of feature parameters",
+		},
+		cli.BoolFlag{
+			Name:  "cascade",
+			Usage: "Remove also the installed features requiring the feature",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		logrus.Tracef("SafeScale command: {%s}, {%s} with args {%s}", clusterCommandName, c.Command.Name, c.Args())
+		err := extractClusterArgument(c)
+		if err != nil {
+			return clitools.FailureResponse(err)
+		}
+		err = extractFeatureArgument(c)
+		if err != nil {
+			return clitools.FailureResponse(err)
+		}
+		feature, err := install.NewFeature(concurrency.RootTask(), featureName)
+		if err != nil {
+			return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.Run, err.Error()))
+		}
+		if feature == nil {
+			msg := fmt.Sprintf("failed to find a feature named '%s'.\n", featureName)
+			return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.NotFound, msg))
+		}
+
+		values := install.Variables{}
+		params := c.StringSlice("param")
+		for _, k := range params {
+			res := strings.Split(k, "=")
+			if len(res[0]) > 0 {
+				values[res[0]] = strings.Join(res[1:], "=")
+			}
+		}
+
+		settings := install.Settings{}
+		// TODO: Reverse proxy rules are not yet purged when feature is removed, but current code
+		// will try to apply them... Quick fix: Setting SkipProxy to true prevent this
+		settings.SkipProxy = true
+		settings.Cascade = c.Bool("cascade")
+
+		target, err := install.NewClusterTarget(concurrency.RootTask(), clusterInstance)
+		if err != nil {
+			return clitools.FailureResponse(err)
+		}
+		dependents, err := feature.Dependents(target)
+		if err != nil {
+			return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.Run, err.Error()))
+		}
+		results, err := feature.Remove(target, values, settings)
+		if err != nil {
+			if _, ok := err.(scerr.ErrNotAvailable); ok {
+				return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.NotApplicable, err.Error()))
+			}
+			msg := fmt.Sprintf("error uninstalling feature '%s' on '%s': %s\n", featureName, clusterName, err.Error())
+			return clitools.FailureResponse(clitools.ExitOnRPC(msg))
+		}
+		if !results.Successful() {
+			msg := fmt.Sprintf("failed to delete feature '%s' from cluster '%s'", featureName, clusterName)
+			if Verbose || Debug {
+				msg += fmt.Sprintf(":\n%s\n", results.AllErrorMessages())
+			}
+			return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.Run, msg))
+		}
+		for _, name := range append(dependents, feature.DisplayName()) {
+			err = clusterInstance.UnregisterFeature(concurrency.RootTask(), name)
+			if err != nil {
+				logrus.Warnf("failed to remove feature '%s' from cluster metadata: %v", name, err)
+			}
+		}
+		return clitools.SuccessResponse(nil)
+	},
+}
+
+// clusterUpgradeFeatureCommand handles 'safescale cluster upgrade-feature CLUSTERNAME FEATURENAME'
+var clusterUpgradeFeatureCommand = cli.Command{
+	Name:      "upgrade-feature",
+	Usage:     "upgrade-feature CLUSTERNAME FEATURENAME",
+	ArgsUsage: "CLUSTERNAME FEATURENAME",
+
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "to",
+			Usage: "Version to upgrade to (default: the latest version available)",
+		},
+		cli.StringSliceFlag{
+			Name:  "param, p",
+			Usage: "Define value of feature parameter, overriding the one used at installation (can be used multiple times)",
+		},
+	},
+
+	Action: func(c *cli.Context) error {
+		logrus.Tracef("SafeScale command: {%s}, {%s} with args {%s}", clusterCommandName, c.Command.Name, c.Args())
+		err := extractClusterArgument(c)
+		if err != nil {
+			return clitools.FailureResponse(err)
+		}
+		err = extractFeatureArgument(c)
+		if err != nil {
+			return clitools.FailureResponse(err)
+		}
+
+		feature, err := install.NewFeature(concurrency.RootTask(), featureUpgradeReference(featureName, c.String("to")))
+		if err != nil {
+			return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.Run, err.Error()))
+		}
+		if feature == nil {
+			msg := fmt.Sprintf("failed to find a feature named '%s'.\n", featureName)
+			return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.NotFound, msg))
+		}
+
+		featuresV1, err := clusterInstalledFeatures(clusterInstance)
+		if err != nil {
+			return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.Run, err.Error()))
+		}
+		version, ok := featuresV1.Installed[feature.DisplayName()]
+		if !ok {
+			msg := fmt.Sprintf("feature '%s' is not recorded as installed on cluster '%s'", feature.DisplayName(), clusterName)
+			return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.NotFound, msg))
+		}
+		if c.String("to") == "" && feature.Version() != "" && version != "" && repository.CompareVersions(feature.Version(), version) <= 0 {
+			return clitools.SuccessResponse(map[string]string{"feature": feature.DisplayName(), "version": version})
+		}
+		recorded, err := install.UnsealParameters(clusterInstance.GetService(concurrency.RootTask()), featuresV1.Parameters[feature.DisplayName()])
+		if err != nil {
+			return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.Run, err.Error()))
+		}
+		values := featureUpgradeValues(recorded, c.StringSlice("param"))
+
+		target, err := install.NewClusterTarget(concurrency.RootTask(), clusterInstance)
+		if err != nil {
+			return clitools.FailureResponse(err)
+		}
+		results, err := feature.Upgrade(target, values, install.Settings{})
+		if err != nil {
+			if _, ok := err.(scerr.ErrNotAvailable); ok {
+				return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.NotApplicable, err.Error()))
+			}
+			msg := fmt.Sprintf("error upgrading feature '%s' on cluster '%s': %s\n", featureName, clusterName, err.Error())
+			return clitools.FailureResponse(clitools.ExitOnRPC(msg))
+		}
+		if !results.Successful() {
+			msg := fmt.Sprintf("failed to upgrade feature '%s' on cluster '%s'", featureName, clusterName)
+			if Debug || Verbose {
+				msg += fmt.Sprintf(":\n%s", results.AllErrorMessages())
+			}
+			return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.Run, msg))
+		}
+		err = clusterInstance.RegisterFeature(concurrency.RootTask(), feature.DisplayName(), feature.Version(), feature.SealParameters(clusterInstance.GetService(concurrency.RootTask()), values))
+		if err != nil {
+			logrus.Warnf("failed to record feature '%s' in cluster metadata: %v", feature.DisplayName(), err)
+		}
+		return clitools.SuccessResponse(map[string]string{"feature": feature.DisplayName(), "version": feature.Version()})
+	},
+}
+
+// clusterInstalledFeatures returns a copy of the features recorded as installed on the cluster
+func clusterInstalledFeatures(c api.Cluster) (*clusterpropsv1.Features, error) {
+	var featuresV1 *clusterpropsv1.Features
+	err := c.GetProperties(concurrency.RootTask()).LockForRead(property.FeaturesV1).ThenUse(func(clonable data.Clonable) error {
+		featuresV1 = clonable.(*clusterpropsv1.Features).Clone().(*clusterpropsv1.Features)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return featuresV1, nil
+}
+
+// clusterTunnelCommand handles 'safescale cluster tunnel CLUSTERNAME FEATURENAME [ENDPOINT]'
+var clusterTunnelCommand = cli.Command{
+	Name:      "tunnel",
+	Usage:     "tunnel CLUSTERNAME FEATURENAME [ENDPOINT]",
+	ArgsUsage: "CLUSTERNAME FEATURENAME [ENDPOINT]",
+
+	Flags: []cli.Flag{
+		cli.IntFlag{
+			Name:  "local",
+			Usage: "local port of the tunnel (only when a single endpoint is concerned); default is chosen by safescaled",
+		},
+	},
+
+	Action: func(c *cli.Context) error {
+		logrus.Tracef("SafeScale command: {%s}, {%s} with args {%s}", clusterCommandName, c.Command.Name, c.Args())
+		err := extractClusterArgument(c)
+		if err != nil {
+			return clitools.FailureResponse(err)
+		}
+		err = extractFeatureArgument(c)
+		if err != nil {
+			return clitools.FailureResponse(err)
+		}
+		endpointName := c.Args().Get(2)
+
+		feature, err := install.NewFeature(concurrency.RootTask(), featureName)
+		if err != nil {
+			return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.Run, err.Error()))
+		}
+		if feature == nil {
+			msg := fmt.Sprintf("failed to find a feature named '%s'.\n", featureName)
+			return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.NotFound, msg))
+		}
+		endpoints, err := feature.Endpoints()
+		if err != nil {
+			return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.Run, err.Error()))
+		}
+		var selected []install.FeatureEndpoint
+		for _, e := range endpoints {
+			if endpointName == "" || e.Name == endpointName {
+				selected = append(selected, e)
+			}
+		}
+		if len(selected) == 0 {
+			msg := fmt.Sprintf("feature '%s' doesn't declare endpoint", featureName)
+			if endpointName != "" {
+				msg += fmt.Sprintf(" named '%s'", endpointName)
+			}
+			return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.NotFound, msg))
+		}
+		localPort := c.Int("local")
+		if localPort != 0 && len(selected) > 1 {
+			return clitools.FailureResponse(clitools.ExitOnInvalidOption("--local can be used only when a single endpoint is concerned"))
+		}
+		if 0 > localPort || localPort > 65535 {
+			return clitools.FailureResponse(clitools.ExitOnInvalidOption(fmt.Sprintf("local port value is wrong, %d is not a valid port", localPort)))
+		}
+
+		clientSession := client.New()
+		var tunnels []*pb.Tunnel
+		for _, e := range selected {
+			hostID, err := clusterEndpointHost(e)
+			if err != nil {
+				return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.Run, err.Error()))
+			}
+			host, err := clientSession.Host.Inspect(hostID, temporal.GetExecutionTimeout())
+			if err != nil {
+				return clitools.FailureResponse(clitools.ExitOnRPC(err.Error()))
+			}
+			def := pb.TunnelDefinition{
+				Name:   fmt.Sprintf("%s-%s-%s", clusterName, featureName, e.Name),
+				Host:   &pb.Reference{Id: host.GetId(), Name: host.GetName()},
+				Mode:   string(system.ForwardLocal),
+				Bind:   fmt.Sprintf("127.0.0.1:%d", localPort),
+				Target: fmt.Sprintf("%s:%d", host.GetPrivateIp(), e.Port),
+			}
+			tunnel, err := clientSession.Tunnel.Create(def, temporal.GetExecutionTimeout())
+			if err != nil {
+				msg := fmt.Sprintf("failed to open tunnel to endpoint '%s' of feature '%s': %s", e.Name, featureName, err.Error())
+				return clitools.FailureResponse(clitools.ExitOnRPC(msg))
+			}
+			tunnels = append(tunnels, tunnel)
+		}
+		return clitools.SuccessResponse(tunnels)
+	},
+}
+
+// clusterEndpointHost returns the ID of the host of the cluster running the endpoint
+func clusterEndpointHost(e install.FeatureEndpoint) (string, error) {
+	task := concurrency.RootTask()
+	switch e.Target {
+	case "gateway":
+		netCfg, err := clusterInstance.GetNetworkConfig(task)
+		if err != nil {
+			return "", err
+		}
+		return netCfg.GatewayID, nil
+	case "node":
+		nodes := clusterInstance.ListNodeIDs(task)
+		if len(nodes) == 0 {
+			return "", fmt.Errorf("no node found in cluster '%s'", clusterName)
+		}
+		return nodes[0], nil
+	case "master":
+		return clusterInstance.FindAvailableMaster(task)
+	default:
+		return "", fmt.Errorf("endpoint '%s' is not provided by a cluster host", e.Name)
+	}
+}
+
+// clusterNodeCommand handles 'deploy cluster <name> node'
+var clusterNodeCommand = cli.Command{
+	Name:      "node",
+	Usage:     "manage cluster nodes",
+	ArgsUsage: "COMMAND",
+
+	Subcommands: []cli.Command{
+		clusterNodeListCommand,
+		clusterNodeInspectCommand,
+		clusterNodeStartCommand,
+		clusterNodeStopCommand,
+		clusterNodeStateCommand,
+		clusterNodeDeleteCommand,
+	},
+
+	// 	Help: &cli.HelpContent{
+	// 		Usage: `
+	// Usage: {{.ProgName}} [options] cluster <clustername> node <nodename> COMMAND
+	//        {{.ProgName}} [options] cluster <clustername> node list|ls`,
+	// 		Commands: `
+	//   list|ls         Lists nodes in cluster
+	//   inspect         Displays information about the node
+	//   stop|freeze     Stops the node
+	//   start|unfreeze  Delete a Cluster
+	//   state           Returns current state of the node`,
+	// 		Description: `
+	// Deploy a new cluster <clustername> or something on the cluster <clustername>.`,
+	// 		Footer: `
+	// Run 'deploy cluster COMMAND --help' for more information on a command.`,
+	// 	},
+}
+
+// clusterNodeListCommand handles 'deploy cluster node list CLUSTERNAME'
+var clusterNodeListCommand = cli.Command{
+	Name:      "list",
+	Aliases:   []string{"ls"},
+	Usage:     "list CLUSTERNAME",
+	ArgsUsage: "CLUSTERNAME",
+
+	// 	Help: &cli.HelpContent{
+	// 		Usage: `
+	// Usage: {{.ProgName}} [options] cluster <clustername> node list|ls`,
+	// 		Description: `
+	// List nodes in the clusters.`,
+	// 	},
+
+	Action: func(c *cli.Context) error {
+		logrus.Tracef("SafeScale command: {%s}, {%s} with args {%s}", clusterCommandName, c.Command.Name, c.Args())
+		err := extractClusterArgument(c)
+		if err != nil {
+			return clitools.FailureResponse(err)
+		}
+		hostClt := client.New().Host
+		var formatted []map[string]interface{}
+
+		list := clusterInstance.ListNodeIDs(concurrency.RootTask())
+		for _, i := range list {
+			host, err := hostClt.Inspect(i, temporal.GetExecutionTimeout())
+			if err != nil {
+				msg := fmt.Sprintf("failed to get data for node '%s': %s. Ignoring.", i, err.Error())
+				//fmt.Println(msg)
+				logrus.Warnln(msg)
+				continue
+			}
+			formatted = append(formatted, map[string]interface{}{
+				"name": host.Name,
+			})
+		}
+		return clitools.SuccessResponse(formatted)
+	},
+}
+
+// // formatNodeConfig...
+// func formatNodeConfig(value interface{}) map[string]interface{} {
+// 	core := value.(map[string]interface{})
+// 	return core
+// }
+
+// clusterNodeInspectCmd handles 'deploy cluster <clustername> inspect'
+var clusterNodeInspectCommand = cli.Command{
+	Name:      "inspect",
+	Usage:     "node inspect CLUSTERNAME HOSTNAME",
+	ArgsUsage: "CLUSTERNAME is the name of the cluster\nHOSTNAME is the hostname of the host resource inside the cluster (ie. for a cluster called 'demo', hostname is 'node-1' and host resourcename is 'demo-node-1')",
+
+	// 	Help: &cli.HelpContent{
+	// 		Usage: `
+	// Usage: {{.ProgName}} [options] cluster <clustername> node <hostname> inspect`,
+	// 		Description: `
+	// Displays information about the node <hostname> of cluster <clustername>.`,
+	// 	},
+
+	Action: func(c *cli.Context) error {
+		logrus.Tracef("SafeScale command: {%s}, {%s} with args {%s}", clusterCommandName, c.Command.Name, c.Args())
+		err := extractClusterArgument(c)
+		if err != nil {
+			return clitools.FailureResponse(err)
+		}
+		err = extractHostArgument(c, 1)
+		if err != nil {
+			return clitools.FailureResponse(err)
+		}
+
+		host, err := client.New().Host.Inspect(hostName, temporal.GetExecutionTimeout())
+		if err != nil {
+			return clitools.FailureResponse(clitools.ExitOnRPC(err.Error()))
+		}
+		return clitools.SuccessResponse(host)
+	},
+}
+
+// clusterNodeDeleteCmd handles 'deploy cluster <clustername> delete'
+var clusterNodeDeleteCommand = cli.Command{
+	Name:    "delete",
+	Aliases: []string{"destroy", "remove", "rm"},
+
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "yes, assume-yes, y",
+			Usage: "If set, respond automatically yes to all questions",
+		},
+		cli.BoolFlag{
+			Name:  "force, f",
+			Usage: "If set, force node deletion no matter what (ie. metadata inconsistency)",
+		},
+	},
+
+	Action: func(c *cli.Context) error {
+		logrus.Tracef("SafeScale command: {%s}, {%s} with args {%s}", clusterCommandName, c.Command.Name, c.Args())
+		err := extractClusterArgument(c)
+		if err != nil {
+			return clitools.FailureResponse(err)
+		}
+		err = extractHostArgument(c, 1)
+		if err != nil {
+			return clitools.FailureResponse(err)
+		}
+
+		yes := c.Bool("yes")
+		force := c.Bool("force")
+
+		if !yes && !utils.UserConfirmed(fmt.Sprintf("Are you sure you want to delete the node '%s' of the cluster '%s'", hostName, clusterName)) {
+			return clitools.SuccessResponse("Aborted")
+		}
+		if force {
+			logrus.Println("'-f,--force' does nothing yet")
+		}
+
+		return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.NotImplemented, "Not yet implemented"))
+	},
+}
+
+// clusterNodeStopCmd handles 'deploy cluster <clustername> node <nodename> stop'
+var clusterNodeStopCommand = cli.Command{
+	Name:    "stop",
+	Aliases: []string{"freeze"},
+	Usage:   "node stop CLUSTERNAME HOSTNAME",
+
+	// 	Help: &cli.HelpContent{
+	// 		Usage: `
+	// Usage: deploy [options] cluster <clustername> stop|freeze`,
+	// 		Description: `
+	// Stop the cluster (make it unavailable for duty).`,
+	// 	},
+
+	Action: func(c *cli.Context) error {
+		logrus.Tracef("SafeScale command: {%s}, {%s} with args {%s}", clusterCommandName, c.Command.Name, c.Args())
+		err := extractClusterArgument(c)
+		if err != nil {
+			return clitools.FailureResponse(err)
+		}
+		err = extractHostArgument(c, 1)
+		if err != nil {
+			return clitools.FailureResponse(err)
+		}
+		return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.NotImplemented, "Not yet implemented"))
+	},
+}
+
+// clusterNodeStartCmd handles 'deploy cluster <clustername> node <nodename> start'
+var clusterNodeStartCommand = cli.Command{
+	Name:    "start",
+	Aliases: []string{"unfreeze"},
+	Usage:   "node start CLUSTERNAME HOSTNAME",
+
+	//Help: &cli.HelpContent{
+	// 		Usage: `
+	// Usage: {{.ProgName}} [options] cluster <clustername> node <nodename> start|unfreeze`,
+	// 		Options: []string{`
+	// options:
+	//   --force, -f Force Don't ask for confirmation`,
+	// 		},
+	// 		Description: `
+	// Start the node <nodename> of the cluster <clustername>.`,
+	// 	},
+
+	Action: func(c *cli.Context) error {
+		logrus.Tracef("SafeScale command: {%s}, {%s} with args {%s}", clusterCommandName, c.Command.Name, c.Args())
+		err := extractClusterArgument(c)
+		if err != nil {
+			return clitools.FailureResponse(err)
+		}
+		err = extractHostArgument(c, 1)
+		if err != nil {
+			return clitools.FailureResponse(err)
+		}
+		return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.NotImplemented, "Not yet implemented"))
+	},
+}
+
+// clusterNodeStateCmd handles 'deploy cluster <clustername> state'
+var clusterNodeStateCommand = cli.Command{
+	Name:  "state",
+	Usage: "node state CLUSTERNAME HOSTNAME",
+
+	// 	Help: &cli.HelpContent{
+	// 		Usage: `
+	// Usage: {{.ProgName}} [options] cluster <clustername> node <nodename> state`,
+	// 		Description: `
+	// Get the state of the node <nodename> of the cluster <clustername>.`,
 	// 	},
 
 	Action: func(c *cli.Context) error {
@@ -1116,87 +2815,118 @@ var clusterRunCommand = cli.Command{
 		if err != nil {
 			return clitools.FailureResponse(err)
 		}
+		err = extractHostArgument(c, 1)
+		if err != nil {
+			return clitools.FailureResponse(err)
+		}
+		return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.NotImplemented, "Not yet implemented"))
+	},
+}
 
-		return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.NotImplemented, "clusterRunCmd not yet implemented"))
+// clusterMasterCommand handles 'safescale cluster master ...
+var clusterPoolCommand = cli.Command{
+	Name:      "pool",
+	Usage:     "manage cluster node pools",
+	ArgsUsage: "COMMAND",
+
+	Subcommands: []cli.Command{
+		clusterPoolListCommand,
+		clusterPoolAddCommand,
+		clusterPoolResizeCommand,
+		clusterPoolDeleteCommand,
 	},
 }
 
-func executeCommand(command string, files *RemoteFilesHandler, outs outputs.Enum) error {
-	logrus.Debugf("command=[%s]", command)
-	master, err := clusterInstance.FindAvailableMaster(concurrency.RootTask())
+// findClusterNodePool returns the node pool named poolName of the current cluster
+func findClusterNodePool(poolName string) (*clusterpropsv1.NodePool, error) {
+	pools, err := clusterInstance.ListNodePools(concurrency.RootTask())
 	if err != nil {
-		msg := fmt.Sprintf("No masters found available for the cluster '%s': %v", clusterInstance.GetIdentity(concurrency.RootTask()).Name, err.Error())
-		return clitools.ExitOnErrorWithMessage(exitcode.RPC, msg)
+		return nil, clitools.ExitOnRPC(err.Error())
 	}
-
-	if files != nil && files.Count() > 0 {
-		if !Debug {
-			defer files.Cleanup(master)
-		}
-		err = files.Upload(master)
-		if err != nil {
-			return clitools.ExitOnErrorWithMessage(exitcode.RPC, err.Error())
+	for _, p := range pools {
+		if p.Name == poolName {
+			return p, nil
 		}
 	}
+	return nil, clitools.ExitOnErrorWithMessage(exitcode.NotFound, fmt.Sprintf("Node pool '%s' not found in cluster '%s'.\n", poolName, clusterName))
+}
 
-	safescalessh := client.New().SSH
-	retcode, stdout, stderr, err := safescalessh.Run(master, command, outs, temporal.GetConnectionTimeout(), temporal.GetExecutionTimeout())
-	if err != nil {
-		msg := fmt.Sprintf("failed to execute command on master '%s': %s", master, err.Error())
-		return clitools.ExitOnErrorWithMessage(exitcode.RPC, msg)
+// extractPoolArgument returns the name of the node pool passed as second argument
+func extractPoolArgument(c *cli.Context) (string, error) {
+	if c.NArg() < 2 {
+		_ = cli.ShowSubcommandHelp(c)
+		return "", clitools.ExitOnInvalidArgument("Missing mandatory argument POOLNAME.")
 	}
-	if retcode != 0 {
-		msg := fmt.Sprintf("command executed on master '%s' with failure: %s", master, stdout)
-		if stderr != "" {
-			if stdout != "" {
-				msg += "\n"
-			}
-			msg += stderr
-		}
-		return cli.NewExitError(msg, retcode)
+	poolName := c.Args().Get(1)
+	if poolName == "" {
+		_ = cli.ShowSubcommandHelp(c)
+		return "", clitools.ExitOnInvalidArgument("Invalid argument POOLNAME.")
 	}
-	return nil
+	return poolName, nil
 }
 
-// clusterCheckFeaturesCommand handles 'safescale cluster <cluster name or id> list-features'
-var clusterListFeaturesCommand = cli.Command{
-	Name:      "list-features",
-	Aliases:   []string{"list-available-features"},
-	Usage:     "list-features",
-	ArgsUsage: "",
-
-	Flags: []cli.Flag{
-		cli.StringSliceFlag{
-			Name:  "param, p",
-			Usage: "Allow to define content of feature parameters",
-		},
-	},
+// clusterPoolListCommand handles 'safescale cluster pool list CLUSTERNAME'
+var clusterPoolListCommand = cli.Command{
+	Name:      "list",
+	Aliases:   []string{"ls"},
+	Usage:     "list CLUSTERNAME",
+	ArgsUsage: "CLUSTERNAME",
 
 	Action: func(c *cli.Context) error {
 		logrus.Tracef("SafeScale command: {%s}, {%s} with args {%s}", clusterCommandName, c.Command.Name, c.Args())
-		features, err := install.ListFeatures("cluster")
+		err := extractClusterArgument(c)
 		if err != nil {
-			return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.Run, err.Error()))
+			return clitools.FailureResponse(err)
 		}
-		return clitools.SuccessResponse(features)
+
+		pools, err := clusterInstance.ListNodePools(concurrency.RootTask())
+		if err != nil {
+			return clitools.FailureResponse(clitools.ExitOnRPC(err.Error()))
+		}
+		return clitools.SuccessResponse(pools)
 	},
 }
 
-// clusterAddFeatureCommand handles 'deploy cluster add-feature CLUSTERNAME FEATURENAME'
-var clusterAddFeatureCommand = cli.Command{
-	Name:      "add-feature",
-	Aliases:   []string{"install-feature"},
-	Usage:     "add-feature CLUSTERNAME FEATURENAME",
-	ArgsUsage: "CLUSTERNAME FEATURENAME",
+// clusterPoolAddCommand handles 'safescale cluster pool add CLUSTERNAME POOLNAME'
+var clusterPoolAddCommand = cli.Command{
+	Name:      "add",
+	Aliases:   []string{"create"},
+	Usage:     "add CLUSTERNAME POOLNAME",
+	ArgsUsage: "CLUSTERNAME POOLNAME",
 
 	Flags: []cli.Flag{
-		cli.StringSliceFlag{
-			Name:  "param, p",
-			Usage: "Allow to define content of feature parameters",
+		cli.UintFlag{
+			Name:  "count, n",
+			Usage: "Define the number of nodes wanted in the pool (default: 1)",
+			Value: 1,
+		},
+		cli.StringFlag{
+			Name:  "os",
+			Usage: "Define the Operating System wanted; default: the one used at cluster creation",
+		},
+		cli.StringFlag{
+			Name:  "sizing",
+			Usage: `Describe node sizing in format "<component><operator><value>[,...]" (cf. 'cluster create --sizing' for details); default: sizing used at cluster creation`,
 		},
 		cli.BoolFlag{
-			Name:  "skip-proxy",
-			Usage: "Disables reverse proxy rules",
+			Name:  "public",
+			Usage: "Give a public IP address to the nodes of the pool",
+		},
+		cli.StringSliceFlag{
+			Name:  "label",
+			Usage: "Set a label on the nodes of the pool, in format <key>=<value> (can be repeated; used by flavors K8S and K3S)",
+		},
+		cli.StringSliceFlag{
+			Name:  "taint",
+			Usage: "Set a taint on the nodes of the pool, in format <key>[=<value>]:<effect> (can be repeated; used by flavors K8S and K3S)",
+		},
+		cli.StringFlag{
+			Name:  "tenant",
+			Usage: "Create the nodes of the pool in this tenant instead of the tenant of the cluster; the network of the tenant is connected to the one of the cluster by a site-to-site tunnel",
+		},
+		cli.StringFlag{
+			Name:  "cidr",
+			Usage: "CIDR of the network created in the tenant set by --tenant, if the cluster has no node there yet; must not overlap the networks of the cluster",
 		},
 	},
 
@@ -1206,227 +2936,227 @@ var clusterAddFeatureCommand = cli.Command{
 		if err != nil {
 			return clitools.FailureResponse(err)
 		}
-		err = extractFeatureArgument(c)
+		poolName, err := extractPoolArgument(c)
 		if err != nil {
 			return clitools.FailureResponse(err)
 		}
 
-		feature, err := install.NewFeature(concurrency.RootTask(), featureName)
-		if err != nil {
-			return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.Run, err.Error()))
-		}
-		if feature == nil {
-			msg := fmt.Sprintf("failed to find a feature named '%s'.\n", featureName)
-			return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.NotFound, msg))
-		}
-
-		values := install.Variables{}
-		params := c.StringSlice("param")
-		for _, k := range params {
-			res := strings.Split(k, "=")
-			if len(res[0]) > 0 {
-				values[res[0]] = strings.Join(res[1:], "=")
+		labels := map[string]string{}
+		for _, v := range c.StringSlice("label") {
+			splitted := strings.SplitN(v, "=", 2)
+			if len(splitted) != 2 || splitted[0] == "" {
+				return clitools.FailureResponse(clitools.ExitOnInvalidOption(fmt.Sprintf("Invalid option --label '%s': must be in format <key>=<value>\n", v)))
 			}
+			labels[splitted[0]] = splitted[1]
 		}
 
-		settings := install.Settings{}
-		settings.SkipProxy = c.Bool("skip-proxy")
-
-		target, err := install.NewClusterTarget(concurrency.RootTask(), clusterInstance)
+		nodesDef, err := constructPBHostDefinitionFromCLI(c, "sizing")
 		if err != nil {
-			return clitools.FailureResponse(err)
+			return err
 		}
-		results, err := feature.Add(target, values, settings)
+
+		hosts, err := clusterInstance.AddNodePool(concurrency.RootTask(), poolName, int(c.Uint("count")), nodesDef, labels, c.StringSlice("taint"), c.String("tenant"), c.String("cidr"))
 		if err != nil {
-			msg := fmt.Sprintf("error installing feature '%s' on cluster '%s': %s\n", featureName, clusterName, err.Error())
-			return clitools.FailureResponse(clitools.ExitOnRPC(msg))
-		}
-		if !results.Successful() {
-			msg := fmt.Sprintf("failed to install feature '%s' on cluster '%s'", featureName, clusterName)
-			if Debug || Verbose {
-				msg += fmt.Sprintf(":\n%s", results.AllErrorMessages())
-			}
-			return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.Run, msg))
+			return clitools.FailureResponse(clitools.ExitOnRPC(err.Error()))
 		}
-		return clitools.SuccessResponse(nil)
+		return clitools.SuccessResponse(hosts)
 	},
 }
 
-// clusterCheckFeatureCommand handles 'deploy cluster check-feature CLUSTERNAME FEATURENAME'
-var clusterCheckFeatureCommand = cli.Command{
-	Name:      "check-feature",
-	Aliases:   []string{"verify-feature"},
-	Usage:     "check-feature CLUSTERNAME FEATURENAME",
-	ArgsUsage: "CLUSTERNAME FEATURENAME",
+// clusterPoolResizeCommand handles 'safescale cluster pool resize CLUSTERNAME POOLNAME'
+var clusterPoolResizeCommand = cli.Command{
+	Name:      "resize",
+	Usage:     "resize CLUSTERNAME POOLNAME",
+	ArgsUsage: "CLUSTERNAME POOLNAME",
+
 	Flags: []cli.Flag{
-		cli.StringSliceFlag{
-			Name:  "param, p",
-			Usage: "Allow to define content of feature parameters",
+		cli.IntFlag{
+			Name:  "count, n",
+			Usage: "Define the number of nodes wanted in the pool",
+			Value: -1,
+		},
+		cli.BoolFlag{
+			Name:  "assume-yes, yes, y",
+			Usage: "Don't ask confirmation when nodes have to be deleted",
 		},
 	},
+
 	Action: func(c *cli.Context) error {
 		logrus.Tracef("SafeScale command: {%s}, {%s} with args {%s}", clusterCommandName, c.Command.Name, c.Args())
 		err := extractClusterArgument(c)
 		if err != nil {
 			return clitools.FailureResponse(err)
 		}
-		err = extractFeatureArgument(c)
+		poolName, err := extractPoolArgument(c)
 		if err != nil {
 			return clitools.FailureResponse(err)
 		}
-		feature, err := install.NewFeature(concurrency.RootTask(), featureName)
-		if err != nil {
-			_, _ = fmt.Fprintln(os.Stderr, err.Error())
-			return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.Run, err.Error()))
-		}
-		if feature == nil {
-			msg := fmt.Sprintf("failed to find a feature named '%s'.\n", featureName)
-			return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.NotFound, msg))
-		}
-
-		values := install.Variables{}
-		params := c.StringSlice("param")
-		for _, k := range params {
-			res := strings.Split(k, "=")
-			if len(res[0]) > 0 {
-				values[res[0]] = strings.Join(res[1:], "=")
-			}
+		count := c.Int("count")
+		if count < 0 {
+			return clitools.FailureResponse(clitools.ExitOnInvalidOption("Missing or invalid option --count|-n: must be an integer >= 0\n"))
 		}
 
-		settings := install.Settings{}
-
-		target, err := install.NewClusterTarget(concurrency.RootTask(), clusterInstance)
+		pool, err := findClusterNodePool(poolName)
 		if err != nil {
 			return clitools.FailureResponse(err)
 		}
-		results, err := feature.Check(target, values, settings)
-		if err != nil {
-			msg := fmt.Sprintf("error checking if feature '%s' is installed on '%s': %s\n", featureName, clusterName, err.Error())
-			return clitools.FailureResponse(clitools.ExitOnRPC(msg))
+		if count < len(pool.Nodes) && !c.Bool("yes") {
+			removed := len(pool.Nodes) - count
+			msg := fmt.Sprintf("Are you sure you want to delete %d node%s from node pool '%s' of cluster '%s'", removed, utils.Plural(removed), poolName, clusterName)
+			if !utils.UserConfirmed(msg) {
+				return clitools.SuccessResponse("Aborted")
+			}
 		}
 
-		if !results.Successful() {
-			msg := fmt.Sprintf("Feature '%s' not found on cluster '%s'", featureName, clusterName)
-			if Verbose || Debug {
-				msg += fmt.Sprintf(":\n%s", results.AllErrorMessages())
-			}
-			return clitools.FailureResponse(clitools.ExitOnNotFound(msg))
+		hosts, err := clusterInstance.ResizeNodePool(concurrency.RootTask(), poolName, count)
+		if err != nil {
+			return clitools.FailureResponse(clitools.ExitOnRPC(err.Error()))
 		}
-		msg := fmt.Sprintf("Feature '%s' found on cluster '%s'", featureName, clusterName)
-		return clitools.SuccessResponse(msg)
+		return clitools.SuccessResponse(hosts)
 	},
 }
 
-// clusterFeatureDeleteCommand handles 'deploy host <host name or id> package <pkgname> delete'
-var clusterDeleteFeatureCommand = cli.Command{
-	Name:      "delete-feature",
-	Aliases:   []string{"destroy-feature", "remove-feature", "rm-feature", "uninstall-feature"},
-	Usage:     "delete-feature CLUSTERNAME FEATURENAME",
-	ArgsUsage: "CLUSTERNAME FEATURENAME",
+// clusterPoolDeleteCommand handles 'safescale cluster pool delete CLUSTERNAME POOLNAME'
+var clusterPoolDeleteCommand = cli.Command{
+	Name:      "delete",
+	Aliases:   []string{"destroy", "remove", "rm"},
+	Usage:     "delete CLUSTERNAME POOLNAME",
+	ArgsUsage: "CLUSTERNAME POOLNAME",
+
 	Flags: []cli.Flag{
-		cli.StringSliceFlag{
-			Name:  "param, p",
-			Usage: "Allow to define content of feature parameters",
+		cli.BoolFlag{
+			Name:  "assume-yes, yes, y",
+			Usage: "Don't ask deletion confirmation",
 		},
 	},
+
 	Action: func(c *cli.Context) error {
 		logrus.Tracef("SafeScale command: {%s}, {%s} with args {%s}", clusterCommandName, c.Command.Name, c.Args())
 		err := extractClusterArgument(c)
 		if err != nil {
 			return clitools.FailureResponse(err)
 		}
-		err = extractFeatureArgument(c)
+		poolName, err := extractPoolArgument(c)
 		if err != nil {
 			return clitools.FailureResponse(err)
 		}
-		feature, err := install.NewFeature(concurrency.RootTask(), featureName)
-		if err != nil {
-			return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.Run, err.Error()))
-		}
-		if feature == nil {
-			msg := fmt.Sprintf("failed to find a feature named '%s'.\n", featureName)
-			return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.NotFound, msg))
+		if poolName == control.DefaultNodePoolName {
+			return clitools.FailureResponse(clitools.ExitOnInvalidArgument("The default node pool cannot be deleted."))
 		}
 
-		values := install.Variables{}
-		params := c.StringSlice("param")
-		for _, k := range params {
-			res := strings.Split(k, "=")
-			if len(res[0]) > 0 {
-				values[res[0]] = strings.Join(res[1:], "=")
+		if !c.Bool("yes") {
+			msg := fmt.Sprintf("Are you sure you want to delete the node pool '%s' of cluster '%s' and all its nodes", poolName, clusterName)
+			if !utils.UserConfirmed(msg) {
+				return clitools.SuccessResponse("Aborted")
 			}
 		}
 
-		settings := install.Settings{}
-		// TODO: Reverse proxy rules are not yet purged when feature is removed, but current code
-		// will try to apply them... Quick fix: Setting SkipProxy to true prevent this
-		settings.SkipProxy = true
-
-		target, err := install.NewClusterTarget(concurrency.RootTask(), clusterInstance)
-		if err != nil {
-			return clitools.FailureResponse(err)
-		}
-		results, err := feature.Remove(target, values, settings)
+		err = clusterInstance.DeleteNodePool(concurrency.RootTask(), poolName)
 		if err != nil {
-			msg := fmt.Sprintf("error uninstalling feature '%s' on '%s': %s\n", featureName, clusterName, err.Error())
-			return clitools.FailureResponse(clitools.ExitOnRPC(msg))
-		}
-		if !results.Successful() {
-			msg := fmt.Sprintf("failed to delete feature '%s' from cluster '%s'", featureName, clusterName)
-			if Verbose || Debug {
-				msg += fmt.Sprintf(":\n%s\n", results.AllErrorMessages())
+			if _, ok := err.(scerr.ErrNotFound); ok {
+				return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.NotFound, err.Error()))
 			}
-			return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.Run, msg))
+			return clitools.FailureResponse(clitools.ExitOnRPC(err.Error()))
 		}
 		return clitools.SuccessResponse(nil)
 	},
-}
+}
+
+// clusterAutoscaleCommand handles 'safescale cluster autoscale ...'
+var clusterAutoscaleCommand = cli.Command{
+	Name:      "autoscale",
+	Usage:     "manage autoscaling of cluster node pools by safescaled",
+	ArgsUsage: "COMMAND",
+
+	Subcommands: []cli.Command{
+		clusterAutoscaleEnableCommand,
+		clusterAutoscaleDisableCommand,
+		clusterAutoscaleListCommand,
+	},
+}
+
+// clusterAutoscaleEnableCommand handles 'safescale cluster autoscale enable CLUSTERNAME'
+var clusterAutoscaleEnableCommand = cli.Command{
+	Name:      "enable",
+	Usage:     "enable CLUSTERNAME",
+	ArgsUsage: "CLUSTERNAME",
+
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "pool",
+			Usage: "Define the node pool to autoscale (default: the default node pool)",
+		},
+		cli.UintFlag{
+			Name:  "min",
+			Usage: "Define the minimum number of nodes in the pool",
+			Value: 1,
+		},
+		cli.UintFlag{
+			Name:  "max",
+			Usage: "Define the maximum number of nodes in the pool (mandatory)",
+		},
+		cli.UintFlag{
+			Name:  "cooldown",
+			Usage: "Define the minimum delay in seconds between 2 scaling actions",
+			Value: 300,
+		},
+		cli.UintFlag{
+			Name:  "interval",
+			Usage: "Define the delay in seconds between 2 evaluations of the workload",
+			Value: 60,
+		},
+		cli.Float64Flag{
+			Name:  "scale-up-load",
+			Usage: "Define the average load per cpu of the nodes above which a node is added",
+			Value: 0.8,
+		},
+		cli.Float64Flag{
+			Name:  "scale-down-load",
+			Usage: "Define the average load per cpu of the nodes under which the least loaded node is removed",
+			Value: 0.2,
+		},
+	},
 
-// clusterNodeCommand handles 'deploy cluster <name> node'
-var clusterNodeCommand = cli.Command{
-	Name:      "node",
-	Usage:     "manage cluster nodes",
-	ArgsUsage: "COMMAND",
+	Action: func(c *cli.Context) error {
+		logrus.Tracef("SafeScale command: {%s}, {%s} with args {%s}", clusterCommandName, c.Command.Name, c.Args())
+		err := extractClusterArgument(c)
+		if err != nil {
+			return clitools.FailureResponse(err)
+		}
+		if c.Uint("max") == 0 {
+			return clitools.FailureResponse(clitools.ExitOnInvalidOption("Missing mandatory option --max.\n"))
+		}
 
-	Subcommands: []cli.Command{
-		clusterNodeListCommand,
-		clusterNodeInspectCommand,
-		clusterNodeStartCommand,
-		clusterNodeStopCommand,
-		clusterNodeStateCommand,
-		clusterNodeDeleteCommand,
+		policy := pb.AutoscalePolicy{
+			Cluster:       clusterName,
+			Pool:          c.String("pool"),
+			MinNodes:      int32(c.Uint("min")),
+			MaxNodes:      int32(c.Uint("max")),
+			Cooldown:      int32(c.Uint("cooldown")),
+			Interval:      int32(c.Uint("interval")),
+			ScaleUpLoad:   float32(c.Float64("scale-up-load")),
+			ScaleDownLoad: float32(c.Float64("scale-down-load")),
+		}
+		as, err := client.New().Autoscaler.Enable(policy, temporal.GetExecutionTimeout())
+		if err != nil {
+			return clitools.FailureResponse(clitools.ExitOnRPC(err.Error()))
+		}
+		return clitools.SuccessResponse(as)
 	},
-
-	// 	Help: &cli.HelpContent{
-	// 		Usage: `
-	// Usage: {{.ProgName}} [options] cluster <clustername> node <nodename> COMMAND
-	//        {{.ProgName}} [options] cluster <clustername> node list|ls`,
-	// 		Commands: `
-	//   list|ls         Lists nodes in cluster
-	//   inspect         Displays information about the node
-	//   stop|freeze     Stops the node
-	//   start|unfreeze  Delete a Cluster
-	//   state           Returns current state of the node`,
-	// 		Description: `
-	// Deploy a new cluster <clustername> or something on the cluster <clustername>.`,
-	// 		Footer: `
-	// Run 'deploy cluster COMMAND --help' for more information on a command.`,
-	// 	},
 }
 
-// clusterNodeListCommand handles 'deploy cluster node list CLUSTERNAME'
-var clusterNodeListCommand = cli.Command{
-	Name:      "list",
-	Aliases:   []string{"ls"},
-	Usage:     "list CLUSTERNAME",
+// clusterAutoscaleDisableCommand handles 'safescale cluster autoscale disable CLUSTERNAME'
+var clusterAutoscaleDisableCommand = cli.Command{
+	Name:      "disable",
+	Usage:     "disable CLUSTERNAME",
 	ArgsUsage: "CLUSTERNAME",
 
-	// 	Help: &cli.HelpContent{
-	// 		Usage: `
-	// Usage: {{.ProgName}} [options] cluster <clustername> node list|ls`,
-	// 		Description: `
-	// List nodes in the clusters.`,
-	// 	},
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "pool",
+			Usage: "Define the node pool to stop autoscaling (default: the default node pool)",
+		},
+	},
 
 	Action: func(c *cli.Context) error {
 		logrus.Tracef("SafeScale command: {%s}, {%s} with args {%s}", clusterCommandName, c.Command.Name, c.Args())
@@ -1434,44 +3164,52 @@ var clusterNodeListCommand = cli.Command{
 		if err != nil {
 			return clitools.FailureResponse(err)
 		}
-		hostClt := client.New().Host
-		var formatted []map[string]interface{}
 
-		list := clusterInstance.ListNodeIDs(concurrency.RootTask())
-		for _, i := range list {
-			host, err := hostClt.Inspect(i, temporal.GetExecutionTimeout())
-			if err != nil {
-				msg := fmt.Sprintf("failed to get data for node '%s': %s. Ignoring.", i, err.Error())
-				//fmt.Println(msg)
-				logrus.Warnln(msg)
-				continue
-			}
-			formatted = append(formatted, map[string]interface{}{
-				"name": host.Name,
-			})
+		err = client.New().Autoscaler.Disable(clusterName, c.String("pool"), temporal.GetExecutionTimeout())
+		if err != nil {
+			return clitools.FailureResponse(clitools.ExitOnRPC(err.Error()))
 		}
-		return clitools.SuccessResponse(formatted)
+		return clitools.SuccessResponse(nil)
 	},
 }
 
-// // formatNodeConfig...
-// func formatNodeConfig(value interface{}) map[string]interface{} {
-// 	core := value.(map[string]interface{})
-// 	return core
-// }
+// clusterAutoscaleListCommand handles 'safescale cluster autoscale list'
+var clusterAutoscaleListCommand = cli.Command{
+	Name:    "list",
+	Aliases: []string{"ls"},
+	Usage:   "list the autoscalers running, with their last decisions",
 
-// clusterNodeInspectCmd handles 'deploy cluster <clustername> inspect'
-var clusterNodeInspectCommand = cli.Command{
-	Name:      "inspect",
-	Usage:     "node inspect CLUSTERNAME HOSTNAME",
-	ArgsUsage: "CLUSTERNAME is the name of the cluster\nHOSTNAME is the hostname of the host resource inside the cluster (ie. for a cluster called 'demo', hostname is 'node-1' and host resourcename is 'demo-node-1')",
+	Action: func(c *cli.Context) error {
+		logrus.Tracef("SafeScale command: {%s}, {%s} with args {%s}", clusterCommandName, c.Command.Name, c.Args())
 
-	// 	Help: &cli.HelpContent{
-	// 		Usage: `
-	// Usage: {{.ProgName}} [options] cluster <clustername> node <hostname> inspect`,
-	// 		Description: `
-	// Displays information about the node <hostname> of cluster <clustername>.`,
-	// 	},
+		list, err := client.New().Autoscaler.List(temporal.GetExecutionTimeout())
+		if err != nil {
+			return clitools.FailureResponse(clitools.ExitOnRPC(err.Error()))
+		}
+		return clitools.SuccessResponse(list.GetAutoscalers())
+	},
+}
+
+// clusterHealthCommand handles 'safescale cluster health ...'
+var clusterHealthCommand = cli.Command{
+	Name:      "health",
+	Usage:     "check and repair the masters and nodes of clusters",
+	ArgsUsage: "COMMAND",
+
+	Subcommands: []cli.Command{
+		clusterHealthCheckCommand,
+		clusterHealthRepairCommand,
+		clusterHealthEnableCommand,
+		clusterHealthDisableCommand,
+		clusterHealthListCommand,
+	},
+}
+
+// clusterHealthCheckCommand handles 'safescale cluster health check CLUSTERNAME'
+var clusterHealthCheckCommand = cli.Command{
+	Name:      "check",
+	Usage:     "check CLUSTERNAME",
+	ArgsUsage: "CLUSTERNAME",
 
 	Action: func(c *cli.Context) error {
 		logrus.Tracef("SafeScale command: {%s}, {%s} with args {%s}", clusterCommandName, c.Command.Name, c.Args())
@@ -1479,33 +3217,27 @@ var clusterNodeInspectCommand = cli.Command{
 		if err != nil {
 			return clitools.FailureResponse(err)
 		}
-		err = extractHostArgument(c, 1)
-		if err != nil {
-			return clitools.FailureResponse(err)
-		}
 
-		host, err := client.New().Host.Inspect(hostName, temporal.GetExecutionTimeout())
+		list, err := clusterInstance.CheckHealth(concurrency.RootTask())
 		if err != nil {
 			return clitools.FailureResponse(clitools.ExitOnRPC(err.Error()))
 		}
-		return clitools.SuccessResponse(host)
+		return clitools.SuccessResponse(list)
 	},
 }
 
-// clusterNodeDeleteCmd handles 'deploy cluster <clustername> delete'
-var clusterNodeDeleteCommand = cli.Command{
-	Name:    "delete",
-	Aliases: []string{"destroy", "remove", "rm"},
+// clusterHealthRepairCommand handles 'safescale cluster health repair CLUSTERNAME HOSTNAME'
+var clusterHealthRepairCommand = cli.Command{
+	Name:      "repair",
+	Aliases:   []string{"replace"},
+	Usage:     "repair CLUSTERNAME HOSTNAME",
+	ArgsUsage: "CLUSTERNAME HOSTNAME",
 
 	Flags: []cli.Flag{
 		cli.BoolFlag{
 			Name:  "yes, assume-yes, y",
 			Usage: "If set, respond automatically yes to all questions",
 		},
-		cli.BoolFlag{
-			Name:  "force, f",
-			Usage: "If set, force node deletion no matter what (ie. metadata inconsistency)",
-		},
 	},
 
 	Action: func(c *cli.Context) error {
@@ -1514,37 +3246,57 @@ var clusterNodeDeleteCommand = cli.Command{
 		if err != nil {
 			return clitools.FailureResponse(err)
 		}
-		err = extractHostArgument(c, 1)
-		if err != nil {
-			return clitools.FailureResponse(err)
+		nodeRef := c.Args().Get(1)
+		if nodeRef == "" {
+			_ = cli.ShowSubcommandHelp(c)
+			return clitools.FailureResponse(clitools.ExitOnInvalidArgument("Missing mandatory argument HOSTNAME."))
 		}
 
-		yes := c.Bool("yes")
-		force := c.Bool("force")
-
-		if !yes && !utils.UserConfirmed(fmt.Sprintf("Are you sure you want to delete the node '%s' of the cluster '%s'", hostName, clusterName)) {
-			return clitools.SuccessResponse("Aborted")
+		// The node may be dead, so it is searched in cluster metadata instead of being inspected
+		var nodeID string
+		for _, n := range clusterInstance.ListNodes(concurrency.RootTask()) {
+			if n.ID == nodeRef || n.Name == nodeRef {
+				nodeID = n.ID
+				break
+			}
 		}
-		if force {
-			logrus.Println("'-f,--force' does nothing yet")
+		if nodeID == "" {
+			msg := fmt.Sprintf("Node '%s' not found in cluster '%s'.\n", nodeRef, clusterName)
+			return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.NotFound, msg))
+		}
+		if !c.Bool("yes") && !utils.UserConfirmed(fmt.Sprintf("Are you sure you want to replace the node '%s' of the cluster '%s'", nodeRef, clusterName)) {
+			return clitools.SuccessResponse("Aborted")
 		}
 
-		return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.NotImplemented, "Not yet implemented"))
+		newID, err := clusterInstance.RepairNode(concurrency.RootTask(), nodeID)
+		if err != nil {
+			return clitools.FailureResponse(clitools.ExitOnRPC(err.Error()))
+		}
+		return clitools.SuccessResponse(newID)
 	},
 }
 
-// clusterNodeStopCmd handles 'deploy cluster <clustername> node <nodename> stop'
-var clusterNodeStopCommand = cli.Command{
-	Name:    "stop",
-	Aliases: []string{"freeze"},
-	Usage:   "node stop CLUSTERNAME HOSTNAME",
+// clusterHealthEnableCommand handles 'safescale cluster health enable CLUSTERNAME'
+var clusterHealthEnableCommand = cli.Command{
+	Name:      "enable",
+	Usage:     "enable CLUSTERNAME",
+	ArgsUsage: "CLUSTERNAME",
 
-	// 	Help: &cli.HelpContent{
-	// 		Usage: `
-	// Usage: deploy [options] cluster <clustername> stop|freeze`,
-	// 		Description: `
-	// Stop the cluster (make it unavailable for duty).`,
-	// 	},
+	Flags: []cli.Flag{
+		cli.UintFlag{
+			Name:  "interval",
+			Usage: "Define the delay in seconds between 2 checks (default: state collect interval of the cluster, or 60)",
+		},
+		cli.BoolFlag{
+			Name:  "repair",
+			Usage: "Replace the nodes failing too many consecutive checks",
+		},
+		cli.UintFlag{
+			Name:  "max-failures",
+			Usage: "Define the number of consecutive failed checks after which a node is replaced",
+			Value: 3,
+		},
+	},
 
 	Action: func(c *cli.Context) error {
 		logrus.Tracef("SafeScale command: {%s}, {%s} with args {%s}", clusterCommandName, c.Command.Name, c.Args())
@@ -1552,30 +3304,26 @@ var clusterNodeStopCommand = cli.Command{
 		if err != nil {
 			return clitools.FailureResponse(err)
 		}
-		err = extractHostArgument(c, 1)
+
+		policy := pb.HealthCheckPolicy{
+			Cluster:     clusterName,
+			Interval:    int32(c.Uint("interval")),
+			Repair:      c.Bool("repair"),
+			MaxFailures: int32(c.Uint("max-failures")),
+		}
+		hc, err := client.New().HealthChecker.Enable(policy, temporal.GetExecutionTimeout())
 		if err != nil {
-			return clitools.FailureResponse(err)
+			return clitools.FailureResponse(clitools.ExitOnRPC(err.Error()))
 		}
-		return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.NotImplemented, "Not yet implemented"))
+		return clitools.SuccessResponse(hc)
 	},
 }
 
-// clusterNodeStartCmd handles 'deploy cluster <clustername> node <nodename> start'
-var clusterNodeStartCommand = cli.Command{
-	Name:    "start",
-	Aliases: []string{"unfreeze"},
-	Usage:   "node start CLUSTERNAME HOSTNAME",
-
-	//Help: &cli.HelpContent{
-	// 		Usage: `
-	// Usage: {{.ProgName}} [options] cluster <clustername> node <nodename> start|unfreeze`,
-	// 		Options: []string{`
-	// options:
-	//   --force, -f Force Don't ask for confirmation`,
-	// 		},
-	// 		Description: `
-	// Start the node <nodename> of the cluster <clustername>.`,
-	// 	},
+// clusterHealthDisableCommand handles 'safescale cluster health disable CLUSTERNAME'
+var clusterHealthDisableCommand = cli.Command{
+	Name:      "disable",
+	Usage:     "disable CLUSTERNAME",
+	ArgsUsage: "CLUSTERNAME",
 
 	Action: func(c *cli.Context) error {
 		logrus.Tracef("SafeScale command: {%s}, {%s} with args {%s}", clusterCommandName, c.Command.Name, c.Args())
@@ -1583,41 +3331,32 @@ var clusterNodeStartCommand = cli.Command{
 		if err != nil {
 			return clitools.FailureResponse(err)
 		}
-		err = extractHostArgument(c, 1)
+
+		err = client.New().HealthChecker.Disable(clusterName, temporal.GetExecutionTimeout())
 		if err != nil {
-			return clitools.FailureResponse(err)
+			return clitools.FailureResponse(clitools.ExitOnRPC(err.Error()))
 		}
-		return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.NotImplemented, "Not yet implemented"))
+		return clitools.SuccessResponse(nil)
 	},
 }
 
-// clusterNodeStateCmd handles 'deploy cluster <clustername> state'
-var clusterNodeStateCommand = cli.Command{
-	Name:  "state",
-	Usage: "node state CLUSTERNAME HOSTNAME",
-
-	// 	Help: &cli.HelpContent{
-	// 		Usage: `
-	// Usage: {{.ProgName}} [options] cluster <clustername> node <nodename> state`,
-	// 		Description: `
-	// Get the state of the node <nodename> of the cluster <clustername>.`,
-	// 	},
+// clusterHealthListCommand handles 'safescale cluster health list'
+var clusterHealthListCommand = cli.Command{
+	Name:    "list",
+	Aliases: []string{"ls"},
+	Usage:   "list the health checkers running, with their last results",
 
 	Action: func(c *cli.Context) error {
 		logrus.Tracef("SafeScale command: {%s}, {%s} with args {%s}", clusterCommandName, c.Command.Name, c.Args())
-		err := extractClusterArgument(c)
-		if err != nil {
-			return clitools.FailureResponse(err)
-		}
-		err = extractHostArgument(c, 1)
+
+		list, err := client.New().HealthChecker.List(temporal.GetExecutionTimeout())
 		if err != nil {
-			return clitools.FailureResponse(err)
+			return clitools.FailureResponse(clitools.ExitOnRPC(err.Error()))
 		}
-		return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.NotImplemented, "Not yet implemented"))
+		return clitools.SuccessResponse(list.GetHealthCheckers())
 	},
 }
 
-// clusterMasterCommand handles 'safescale cluster master ...
 var clusterMasterCommand = cli.Command{
 	Name:      "master",
 	Usage:     "manage cluster masters",
@@ -1669,3 +3408,84 @@ var clusterMasterListCommand = cli.Command{
 		return clitools.SuccessResponse(formatted)
 	},
 }
+
+// clusterFeatureLogCommand handles 'safescale cluster feature-log CLUSTERNAME FEATURENAME'
+var clusterFeatureLogCommand = cli.Command{
+	Name:      "feature-log",
+	Aliases:   []string{"feature-logs"},
+	Usage:     "feature-log CLUSTERNAME FEATURENAME",
+	ArgsUsage: "CLUSTERNAME FEATURENAME",
+	Flags: append([]cli.Flag{
+		cli.StringFlag{
+			Name:  "host",
+			Usage: "Display the logs of this host of the cluster only",
+		},
+	}, featureLogFlags...),
+
+	Action: func(c *cli.Context) error {
+		logrus.Tracef("SafeScale command: {%s}, {%s} with args {%s}", clusterCommandName, c.Command.Name, c.Args())
+		err := extractClusterArgument(c)
+		if err != nil {
+			return clitools.FailureResponse(err)
+		}
+		err = extractFeatureArgument(c)
+		if err != nil {
+			return clitools.FailureResponse(err)
+		}
+
+		hosts, err := clusterHosts(clusterInstance)
+		if err != nil {
+			return clitools.FailureResponse(clitools.ExitOnRPC(err.Error()))
+		}
+		if name := c.String("host"); name != "" {
+			var selected []*pb.Host
+			for _, h := range hosts {
+				if h.Name == name || h.Id == name {
+					selected = append(selected, h)
+				}
+			}
+			if len(selected) == 0 {
+				msg := fmt.Sprintf("host '%s' is not a host of cluster '%s'", name, clusterName)
+				return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.NotFound, msg))
+			}
+			hosts = selected
+		}
+
+		logs, err := featureLogs(c, hosts, featureName)
+		if err != nil {
+			msg := fmt.Sprintf("failed to read the logs of feature '%s' on cluster '%s': %s", featureName, clusterName, err.Error())
+			return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.Run, msg))
+		}
+		if len(logs) == 0 {
+			msg := fmt.Sprintf("no log of feature '%s' found on cluster '%s'", featureName, clusterName)
+			return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.NotFound, msg))
+		}
+		return clitools.SuccessResponse(logs)
+	},
+}
+
+// clusterHosts returns the gateways, the masters and the nodes of the cluster
+func clusterHosts(c api.Cluster) ([]*pb.Host, error) {
+	task := concurrency.RootTask()
+	netCfg, err := c.GetNetworkConfig(task)
+	if err != nil {
+		return nil, err
+	}
+	ids := []string{netCfg.GatewayID}
+	if netCfg.SecondaryGatewayID != "" {
+		ids = append(ids, netCfg.SecondaryGatewayID)
+	}
+	ids = append(ids, c.ListMasterIDs(task)...)
+	ids = append(ids, c.ListNodeIDs(task)...)
+
+	var hosts []*pb.Host
+	clientHost := client.New().Host
+	for _, id := range ids {
+		host, err := clientHost.Inspect(id, temporal.GetExecutionTimeout())
+		if err != nil {
+			return nil, err
+		}
+		hosts = append(hosts, host)
+	}
+	return hosts, nil
+}
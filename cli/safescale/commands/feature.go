@@ -0,0 +1,453 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package commands
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+
+	pb "github.com/CS-SI/SafeScale/lib"
+	"github.com/CS-SI/SafeScale/lib/server/install"
+	"github.com/CS-SI/SafeScale/lib/server/install/repository"
+	clitools "github.com/CS-SI/SafeScale/lib/utils/cli"
+	"github.com/CS-SI/SafeScale/lib/utils/cli/enums/exitcode"
+	"github.com/CS-SI/SafeScale/lib/utils/concurrency"
+	"github.com/CS-SI/SafeScale/lib/utils/scerr"
+)
+
+var featureCmdName = "feature"
+
+// FeatureCmd command
+var FeatureCmd = cli.Command{
+	Name:  "feature",
+	Usage: "feature COMMAND",
+	Subcommands: []cli.Command{
+		featureRepoCommand,
+		featureSearchCommand,
+		featureInspectCommand,
+		featureTestCommand,
+	},
+}
+
+// featureRepoCommand handles 'safescale feature repo'
+var featureRepoCommand = cli.Command{
+	Name:      "repo",
+	Aliases:   []string{"repository"},
+	Usage:     "manage the repositories of features",
+	ArgsUsage: "COMMAND",
+
+	Subcommands: []cli.Command{
+		featureRepoAddCommand,
+		featureRepoListCommand,
+		featureRepoUpdateCommand,
+		featureRepoDeleteCommand,
+	},
+}
+
+// featureRepoAddCommand handles 'safescale feature repo add REPONAME URL'
+var featureRepoAddCommand = cli.Command{
+	Name:      "add",
+	Usage:     "add REPONAME URL",
+	ArgsUsage: "REPONAME URL",
+
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "kind",
+			Usage: "Kind of repository: git, https or bucket (default: guessed from URL)",
+		},
+		cli.StringFlag{
+			Name:  "ref",
+			Usage: "Branch or tag to use (kind git only; default: the default branch of the repository)",
+		},
+		cli.StringFlag{
+			Name:  "public-key",
+			Usage: "Ed25519 public key (encoded in base64, or file containing it) used to verify the signature of the index of the repository",
+		},
+		cli.BoolFlag{
+			Name:  "no-update",
+			Usage: "Do not fetch the content of the repository",
+		},
+	},
+
+	Action: func(c *cli.Context) error {
+		logrus.Tracef("SafeScale command: {%s}, {%s} with args {%s}", featureCmdName, c.Command.Name, c.Args())
+		if c.NArg() != 2 {
+			_ = cli.ShowSubcommandHelp(c)
+			return clitools.FailureResponse(clitools.ExitOnInvalidArgument("Missing mandatory arguments REPONAME and URL."))
+		}
+
+		publicKey := c.String("public-key")
+		if _, err := os.Stat(publicKey); publicKey != "" && err == nil {
+			content, err := ioutil.ReadFile(publicKey)
+			if err != nil {
+				return clitools.FailureResponse(clitools.ExitOnInvalidOption(fmt.Sprintf("Invalid option --public-key: %s\n", err.Error())))
+			}
+			publicKey = strings.TrimSpace(string(content))
+		}
+
+		repo := &repository.Repository{
+			Name:      c.Args().First(),
+			URL:       c.Args().Get(1),
+			Kind:      strings.ToLower(c.String("kind")),
+			Ref:       c.String("ref"),
+			PublicKey: publicKey,
+		}
+		err := repository.Add(repo)
+		if err != nil {
+			switch err.(type) {
+			case scerr.ErrDuplicate:
+				return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.Duplicate, err.Error()))
+			case scerr.ErrInvalidParameter:
+				return clitools.FailureResponse(clitools.ExitOnInvalidArgument(err.Error()))
+			default:
+				return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.Run, err.Error()))
+			}
+		}
+		if c.Bool("no-update") {
+			return clitools.SuccessResponse(repo)
+		}
+
+		index, err := repository.Update(repo.Name)
+		if err != nil {
+			msg := fmt.Sprintf("repository '%s' added, but failed to fetch its content: %s", repo.Name, err.Error())
+			return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.Run, msg))
+		}
+		return clitools.SuccessResponse(map[string]interface{}{
+			"name":     repo.Name,
+			"features": len(index.Features),
+		})
+	},
+}
+
+// featureRepoListCommand handles 'safescale feature repo list'
+var featureRepoListCommand = cli.Command{
+	Name:    "list",
+	Aliases: []string{"ls"},
+	Usage:   "list",
+
+	Action: func(c *cli.Context) error {
+		logrus.Tracef("SafeScale command: {%s}, {%s} with args {%s}", featureCmdName, c.Command.Name, c.Args())
+		list, err := repository.List()
+		if err != nil {
+			return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.Run, err.Error()))
+		}
+		var out []map[string]interface{}
+		for _, v := range list {
+			entry := map[string]interface{}{
+				"name":   v.Name,
+				"kind":   v.Kind,
+				"url":    v.URL,
+				"signed": v.PublicKey != "",
+			}
+			if v.Ref != "" {
+				entry["ref"] = v.Ref
+			}
+			if !v.UpdatedAt.IsZero() {
+				entry["updated_at"] = v.UpdatedAt
+			}
+			out = append(out, entry)
+		}
+		return clitools.SuccessResponse(out)
+	},
+}
+
+// featureRepoUpdateCommand handles 'safescale feature repo update [REPONAME...]'
+var featureRepoUpdateCommand = cli.Command{
+	Name:      "update",
+	Aliases:   []string{"refresh"},
+	Usage:     "update [REPONAME...]",
+	ArgsUsage: "[REPONAME...]",
+
+	Action: func(c *cli.Context) error {
+		logrus.Tracef("SafeScale command: {%s}, {%s} with args {%s}", featureCmdName, c.Command.Name, c.Args())
+		names := []string(c.Args())
+		if len(names) == 0 {
+			list, err := repository.List()
+			if err != nil {
+				return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.Run, err.Error()))
+			}
+			for _, v := range list {
+				names = append(names, v.Name)
+			}
+		}
+
+		out := map[string]interface{}{}
+		var errors []string
+		for _, name := range names {
+			index, err := repository.Update(name)
+			if err != nil {
+				errors = append(errors, fmt.Sprintf("%s: %s", name, err.Error()))
+				continue
+			}
+			out[name] = len(index.Features)
+		}
+		if len(errors) > 0 {
+			msg := fmt.Sprintf("failed to update feature repositories:\n%s", strings.Join(errors, "\n"))
+			return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.Run, msg))
+		}
+		return clitools.SuccessResponse(out)
+	},
+}
+
+// featureRepoDeleteCommand handles 'safescale feature repo delete REPONAME'
+var featureRepoDeleteCommand = cli.Command{
+	Name:      "delete",
+	Aliases:   []string{"rm", "remove"},
+	Usage:     "delete REPONAME",
+	ArgsUsage: "REPONAME",
+
+	Action: func(c *cli.Context) error {
+		logrus.Tracef("SafeScale command: {%s}, {%s} with args {%s}", featureCmdName, c.Command.Name, c.Args())
+		if c.NArg() != 1 {
+			_ = cli.ShowSubcommandHelp(c)
+			return clitools.FailureResponse(clitools.ExitOnInvalidArgument("Missing mandatory argument REPONAME."))
+		}
+		err := repository.Remove(c.Args().First())
+		if err != nil {
+			if _, ok := err.(scerr.ErrNotFound); ok {
+				return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.NotFound, err.Error()))
+			}
+			return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.Run, err.Error()))
+		}
+		return clitools.SuccessResponse(nil)
+	},
+}
+
+// featureSearchCommand handles 'safescale feature search [PATTERN]'
+var featureSearchCommand = cli.Command{
+	Name:      "search",
+	Usage:     "search [PATTERN]",
+	ArgsUsage: "[PATTERN]",
+
+	Action: func(c *cli.Context) error {
+		logrus.Tracef("SafeScale command: {%s}, {%s} with args {%s}", featureCmdName, c.Command.Name, c.Args())
+		entries, err := repository.Search(c.Args().First())
+		if err != nil {
+			return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.Run, err.Error()))
+		}
+		var out []map[string]interface{}
+		for _, e := range entries {
+			out = append(out, map[string]interface{}{
+				"feature":     e.Name + "@" + e.Version,
+				"description": e.Description,
+				"repository":  e.Repository,
+			})
+		}
+		return clitools.SuccessResponse(out)
+	},
+}
+
+// featureInspectCommand handles 'safescale feature inspect FEATURENAME'
+var featureInspectCommand = cli.Command{
+	Name:      "inspect",
+	Aliases:   []string{"show"},
+	Usage:     "inspect FEATURENAME",
+	ArgsUsage: "FEATURENAME",
+
+	Action: func(c *cli.Context) error {
+		logrus.Tracef("SafeScale command: {%s}, {%s} with args {%s}", featureCmdName, c.Command.Name, c.Args())
+		if c.NArg() != 1 {
+			_ = cli.ShowSubcommandHelp(c)
+			return clitools.FailureResponse(clitools.ExitOnInvalidArgument("Missing mandatory argument FEATURENAME."))
+		}
+		feature, err := install.NewFeature(concurrency.RootTask(), c.Args().First())
+		if err != nil {
+			if _, ok := err.(scerr.ErrNotFound); ok {
+				return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.NotFound, err.Error()))
+			}
+			return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.Run, err.Error()))
+		}
+		params, err := feature.Parameters()
+		if err != nil {
+			return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.Run, err.Error()))
+		}
+		for _, p := range params {
+			if p.Secret && p.Default != nil && *p.Default != "" {
+				masked := "********"
+				p.Default = &masked
+			}
+		}
+
+		specs := feature.Specs()
+		out := map[string]interface{}{
+			"name":       feature.DisplayName(),
+			"file":       feature.DisplayFilename(),
+			"parameters": params,
+		}
+		if v := feature.Version(); v != "" {
+			out["version"] = v
+		}
+		if specs.IsSet("feature.suitableFor") {
+			out["suitable_for"] = specs.GetStringMapString("feature.suitableFor")
+		}
+		if specs.IsSet("feature.requirements.features") {
+			out["requires"] = specs.GetStringSlice("feature.requirements.features")
+		}
+		return clitools.SuccessResponse(out)
+	},
+}
+
+// featureUpgradeReference returns the reference of the feature to use to upgrade to version 'to'
+// (the latest version available if 'to' is empty)
+func featureUpgradeReference(name, to string) string {
+	if to == "" {
+		return name
+	}
+	name, _ = repository.SplitReference(name)
+	return name + "@" + to
+}
+
+// featureUpgradeValues returns the values of the parameters to use to upgrade a feature: the ones recorded
+// at installation, overridden by the ones passed with --param
+func featureUpgradeValues(recorded map[string]string, params []string) install.Variables {
+	values := install.Variables{}
+	for k, v := range recorded {
+		values[k] = v
+	}
+	for _, k := range params {
+		res := strings.Split(k, "=")
+		if len(res[0]) > 0 {
+			values[res[0]] = strings.Join(res[1:], "=")
+		}
+	}
+	return values
+}
+
+// outdatedFeatures compares the versions of the installed features (indexed by name) with the versions available,
+// and returns the ones for which a newer version exists
+func outdatedFeatures(installed map[string]string) []map[string]interface{} {
+	var names []string
+	for k := range installed {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	out := []map[string]interface{}{}
+	for _, name := range names {
+		latest, err := install.LatestVersion(concurrency.RootTask(), name)
+		if err != nil {
+			logrus.Warnf("failed to determine the latest version of feature '%s': %v", name, err)
+			continue
+		}
+		if latest == "" || repository.CompareVersions(latest, installed[name]) <= 0 {
+			continue
+		}
+		current := installed[name]
+		if current == "" {
+			current = "unknown"
+		}
+		out = append(out, map[string]interface{}{
+			"feature":   name,
+			"installed": current,
+			"available": latest,
+		})
+	}
+	return out
+}
+
+// featureLogFlags are the flags of the commands 'host feature-log' and 'cluster feature-log'
+var featureLogFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "step",
+		Usage: "Display the log of this step only",
+	},
+	cli.StringFlag{
+		Name:  "action",
+		Usage: "Display the logs of this action only (check, add, remove or upgrade)",
+	},
+	cli.BoolFlag{
+		Name:  "all",
+		Usage: "Display the logs of all the executions kept, not only the most recent one",
+	},
+}
+
+// featureLogs returns the logs of the steps of the feature run on the hosts, selected by the flags, with the
+// content of their log file
+func featureLogs(c *cli.Context, hosts []*pb.Host, feature string) ([]map[string]interface{}, error) {
+	filter := install.FeatureLogFilter{
+		Feature: feature,
+		Action:  c.String("action"),
+		Step:    c.String("step"),
+		AllRuns: c.Bool("all"),
+	}
+	out := []map[string]interface{}{}
+	for _, h := range hosts {
+		entries, err := install.HostFeatureLogs(h, filter)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			item := map[string]interface{}{
+				"host":       h.Name,
+				"run":        e.Run,
+				"action":     e.Action,
+				"step":       e.Step,
+				"target":     e.TargetType + " " + e.Target,
+				"started_at": e.StartedAt,
+				"duration":   e.Duration.String(),
+				"exit_code":  e.ExitCode,
+				"log_file":   e.LogFile,
+			}
+			if e.Error != "" {
+				item["error"] = e.Error
+			}
+			content, err := install.ReadFeatureLog(h, e)
+			if err != nil {
+				item["output_error"] = err.Error()
+			} else {
+				item["output"] = content
+			}
+			out = append(out, item)
+		}
+	}
+	return out, nil
+}
+
+// featureVerifyFlags are the flags of the commands 'host check-feature' and 'cluster check-feature' verifying the
+// configuration of the feature
+var featureVerifyFlags = []cli.Flag{
+	cli.BoolFlag{
+		Name:  "drift",
+		Usage: "Verify also the configuration declared by the steps of the feature (files, services, ports) and report the drift per host",
+	},
+	cli.BoolFlag{
+		Name:  "reconcile",
+		Usage: "Re-apply the steps whose configuration drifted, on the hosts drifting only (implies --drift)",
+	},
+}
+
+// verifyFeature verifies the configuration of the feature on the target and returns the response of the command:
+// the report if no drift remains, a failure listing the drifts otherwise
+func verifyFeature(c *cli.Context, feature *install.Feature, target install.Target, values install.Variables) error {
+	settings := install.Settings{Reconcile: c.Bool("reconcile")}
+	report, err := feature.Verify(target, values, settings)
+	if err != nil {
+		msg := fmt.Sprintf("failed to verify the configuration of feature '%s' on %s '%s': %s", feature.DisplayName(), target.Type(), target.Name(), err.Error())
+		return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.Run, msg))
+	}
+	if report.Drifting() {
+		msg := fmt.Sprintf("configuration of feature '%s' drifted on %s '%s':\n%s", feature.DisplayName(), target.Type(), target.Name(), report.Summary())
+		return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.Run, msg))
+	}
+	return clitools.SuccessResponse(report)
+}
@@ -0,0 +1,535 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+
+	pb "github.com/CS-SI/SafeScale/lib"
+	"github.com/CS-SI/SafeScale/lib/client"
+	"github.com/CS-SI/SafeScale/lib/server/cluster"
+	"github.com/CS-SI/SafeScale/lib/server/cluster/control"
+	"github.com/CS-SI/SafeScale/lib/server/cluster/enums/complexity"
+	"github.com/CS-SI/SafeScale/lib/server/cluster/enums/flavor"
+	"github.com/CS-SI/SafeScale/lib/server/install"
+	clitools "github.com/CS-SI/SafeScale/lib/utils/cli"
+	"github.com/CS-SI/SafeScale/lib/utils/cli/enums/exitcode"
+	"github.com/CS-SI/SafeScale/lib/utils/concurrency"
+	"github.com/CS-SI/SafeScale/lib/utils/scerr"
+	"github.com/CS-SI/SafeScale/lib/utils/temporal"
+)
+
+// Phases of the test of a feature on a throwaway target, in order
+const (
+	featureTestCreate          = "create"
+	featureTestCheckBefore     = "check"
+	featureTestAdd             = "add"
+	featureTestCheckAdded      = "check-added"
+	featureTestAssertAdded     = "assert-added"
+	featureTestRemove          = "remove"
+	featureTestCheckRemoved    = "check-removed"
+	featureTestAssertRemoved   = "assert-removed"
+	featureTestCleanup         = "cleanup"
+	featureTestStatusOK        = "ok"
+	featureTestStatusFailed    = "FAILED"
+	featureTestStatusSkipped   = "-"
+	featureTestStatusKept      = "kept"
+	featureTestResourcePrefix  = "sft"
+	featureTestDisabledFeature = "remotedesktop"
+)
+
+var featureTestPhases = []string{
+	featureTestCreate, featureTestCheckBefore, featureTestAdd, featureTestCheckAdded, featureTestAssertAdded,
+	featureTestRemove, featureTestCheckRemoved, featureTestAssertRemoved, featureTestCleanup,
+}
+
+var (
+	// featureTestAborts contains, by name of throwaway target, the functions deleting the resources created by the
+	// tests in progress, used if safescale is interrupted
+	featureTestAborts     = map[string]func(){}
+	featureTestAbortsLock sync.Mutex
+)
+
+// featureTestCommand handles 'safescale feature test FILE'
+var featureTestCommand = cli.Command{
+	Name:      "test",
+	Usage:     "Test a feature on throwaway hosts or clusters: check, add, check, remove, check",
+	ArgsUsage: "FILE|FEATURENAME",
+
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "os",
+			Usage: "Comma-separated list of the images to test the feature on (default: key 'os' of section 'tests' of the feature, or \"Ubuntu 18.04\")",
+		},
+		cli.StringSliceFlag{
+			Name:  "param, p",
+			Usage: "Allow to define content of feature parameters (override the ones of section 'tests' of the feature)",
+		},
+		cli.StringFlag{
+			Name:  "target",
+			Usage: "Kind of target to create, 'host' or 'cluster' (default: key 'target' of section 'tests' of the feature)",
+		},
+		cli.StringFlag{
+			Name:  "flavor, F",
+			Usage: "Flavor of the cluster to create (default: key 'flavor' of section 'tests' of the feature)",
+		},
+		cli.StringFlag{
+			Name:  "sizing, S",
+			Usage: "Sizing of the hosts to create, in format \"<component><operator><value>[,...]\" (see 'host create')",
+		},
+		cli.BoolFlag{
+			Name:  "keep-on-failure, k",
+			Usage: "Keep the hosts or clusters on which the test failed",
+		},
+	},
+
+	Action: func(c *cli.Context) error {
+		logrus.Tracef("SafeScale command: {%s}, {%s} with args {%s}", featureCmdName, c.Command.Name, c.Args())
+		if c.NArg() != 1 {
+			_ = cli.ShowSubcommandHelp(c)
+			return clitools.FailureResponse(clitools.ExitOnInvalidArgument("Missing mandatory argument FILE|FEATURENAME."))
+		}
+
+		var (
+			feature *install.Feature
+			err     error
+		)
+		ref := c.Args().First()
+		if _, serr := os.Stat(ref); serr == nil {
+			feature, err = install.NewFeatureFromFile(concurrency.RootTask(), ref)
+		} else {
+			feature, err = install.NewFeature(concurrency.RootTask(), ref)
+		}
+		if err != nil {
+			if _, ok := err.(scerr.ErrNotFound); ok {
+				return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.NotFound, err.Error()))
+			}
+			return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.Run, err.Error()))
+		}
+
+		tests, err := feature.Tests()
+		if err != nil {
+			return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.Run, err.Error()))
+		}
+		if c.IsSet("os") {
+			tests.OS = nil
+			for _, v := range strings.Split(c.String("os"), ",") {
+				if v = strings.TrimSpace(v); v != "" {
+					tests.OS = append(tests.OS, v)
+				}
+			}
+		}
+		if c.IsSet("target") {
+			tests.Target = strings.ToLower(c.String("target"))
+		}
+		if c.IsSet("flavor") {
+			tests.Flavor = strings.ToLower(c.String("flavor"))
+		}
+		if c.IsSet("sizing") {
+			tests.Sizing = c.String("sizing")
+		}
+		switch tests.Target {
+		case "host":
+		case "cluster":
+			if tests.Flavor == "" {
+				tests.Flavor = "boh"
+			}
+			if _, err := flavor.Parse(tests.Flavor); err != nil {
+				return clitools.FailureResponse(clitools.ExitOnInvalidOption(fmt.Sprintf("Invalid option --flavor|-F: %s\n", err.Error())))
+			}
+		default:
+			return clitools.FailureResponse(clitools.ExitOnInvalidOption("Invalid option --target: must be 'host' or 'cluster'"))
+		}
+		if len(tests.OS) == 0 {
+			return clitools.FailureResponse(clitools.ExitOnInvalidOption("Invalid option --os: no image given"))
+		}
+		sizing, err := constructPBHostSizing(tests.Sizing)
+		if err != nil {
+			return err
+		}
+
+		values := install.Variables{}
+		for _, k := range append(tests.Parameters, c.StringSlice("param")...) {
+			res := strings.Split(k, "=")
+			if len(res[0]) > 0 {
+				values[res[0]] = strings.Join(res[1:], "=")
+			}
+		}
+
+		report := &featureTestReport{
+			Feature: feature.DisplayName(),
+			File:    feature.DisplayFilename(),
+			Target:  tests.Target,
+			Flavor:  tests.Flavor,
+		}
+		if tests.Target != "cluster" {
+			report.Flavor = ""
+		}
+		for i, image := range tests.OS {
+			result := runFeatureTest(feature, tests, image, i, sizing, values, c.Bool("keep-on-failure"))
+			report.Results = append(report.Results, result)
+		}
+		if !report.Successful() {
+			msg := fmt.Sprintf("test of feature '%s' failed:\n%s", feature.DisplayName(), report.Matrix())
+			return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.Run, msg))
+		}
+		return clitools.SuccessResponse(report)
+	},
+}
+
+// featureTestReport contains the results of the test of a feature on each image
+type featureTestReport struct {
+	Feature string               `json:"feature"`
+	File    string               `json:"file"`
+	Target  string               `json:"target"`
+	Flavor  string               `json:"flavor,omitempty"`
+	Results []*featureTestResult `json:"results"`
+}
+
+// featureTestResult contains the result of the test of a feature on an image
+type featureTestResult struct {
+	OS string `json:"os"`
+	// Name is the name of the throwaway host or cluster
+	Name       string                    `json:"name"`
+	Phases     []featureTestPhase        `json:"phases"`
+	Assertions []install.AssertionResult `json:"assertions,omitempty"`
+	Success    bool                      `json:"success"`
+}
+
+// featureTestPhase is the result of a phase of the test
+type featureTestPhase struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+// Successful tells if the test succeeded on all the images
+func (r *featureTestReport) Successful() bool {
+	for _, v := range r.Results {
+		if !v.Success {
+			return false
+		}
+	}
+	return true
+}
+
+// Matrix returns the status of each phase for each image, followed by the failures
+func (r *featureTestReport) Matrix() string {
+	buffer := bytes.NewBufferString("")
+	w := tabwriter.NewWriter(buffer, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "OS\t"+strings.Join(featureTestPhases, "\t"))
+	var failures []string
+	for _, v := range r.Results {
+		line := []string{v.OS}
+		for _, name := range featureTestPhases {
+			phase := v.phase(name)
+			if phase == nil {
+				line = append(line, featureTestStatusSkipped)
+				continue
+			}
+			line = append(line, phase.Status)
+			if phase.Status == featureTestStatusFailed {
+				failures = append(failures, fmt.Sprintf("%s: %s: %s", v.OS, phase.Name, phase.Message))
+			}
+		}
+		_, _ = fmt.Fprintln(w, strings.Join(line, "\t"))
+	}
+	_ = w.Flush()
+	if len(failures) > 0 {
+		buffer.WriteString("\n" + strings.Join(failures, "\n"))
+	}
+	return buffer.String()
+}
+
+func (r *featureTestResult) phase(name string) *featureTestPhase {
+	for i := range r.Phases {
+		if r.Phases[i].Name == name {
+			return &r.Phases[i]
+		}
+	}
+	return nil
+}
+
+// record records the result of a phase; returns true if the phase succeeded
+func (r *featureTestResult) record(name string, err error) bool {
+	phase := featureTestPhase{Name: name, Status: featureTestStatusOK}
+	if err != nil {
+		phase.Status = featureTestStatusFailed
+		phase.Message = err.Error()
+		r.Success = false
+	}
+	r.Phases = append(r.Phases, phase)
+	return err == nil
+}
+
+// runFeatureTest creates a throwaway target with the image, runs the phases of the test on it, then deletes it
+// (unless the test failed and 'keepOnFailure' is set)
+func runFeatureTest(feature *install.Feature, tests *install.FeatureTests, image string, index int, sizing *pb.HostSizing, values install.Variables, keepOnFailure bool) *featureTestResult {
+	result := &featureTestResult{
+		OS:      image,
+		Name:    featureTestResourceName(feature.DisplayName(), image),
+		Success: true,
+	}
+	logrus.Infof("Testing feature '%s' on %s '%s' (%s)", feature.DisplayName(), tests.Target, result.Name, image)
+	defer setFeatureTestAbort(result.Name, nil)
+
+	var (
+		target  install.Target
+		cleanup func() error
+		err     error
+	)
+	if tests.Target == "cluster" {
+		target, cleanup, err = createFeatureTestCluster(result.Name, tests.Flavor, image, index, sizing)
+	} else {
+		target, cleanup, err = createFeatureTestHost(result.Name, image, index, sizing)
+	}
+	if result.record(featureTestCreate, err) {
+		runFeatureTestPhases(feature, target, values, result)
+	}
+
+	if cleanup != nil {
+		if result.Success || !keepOnFailure {
+			result.record(featureTestCleanup, cleanup())
+		} else {
+			result.Phases = append(result.Phases, featureTestPhase{Name: featureTestCleanup, Status: featureTestStatusKept})
+		}
+	}
+	return result
+}
+
+// setFeatureTestAbort records the function deleting the resources of the target 'name' if safescale is interrupted;
+// if 'abort' is nil, forgets it
+func setFeatureTestAbort(name string, abort func()) {
+	featureTestAbortsLock.Lock()
+	defer featureTestAbortsLock.Unlock()
+	if abort == nil {
+		delete(featureTestAborts, name)
+	} else {
+		featureTestAborts[name] = abort
+	}
+}
+
+// AbortFeatureTests deletes the hosts, networks and clusters created by the tests of features in progress (even
+// with --keep-on-failure); called when safescale is interrupted
+func AbortFeatureTests() {
+	featureTestAbortsLock.Lock()
+	defer featureTestAbortsLock.Unlock()
+	for name, abort := range featureTestAborts {
+		fmt.Printf("Deleting '%s', created to test a feature...\n", name)
+		abort()
+		delete(featureTestAborts, name)
+	}
+}
+
+// runFeatureTestPhases runs check, add, check, remove and check, and evaluates the assertions of the feature;
+// stops at the first phase failing
+func runFeatureTestPhases(feature *install.Feature, target install.Target, values install.Variables, result *featureTestResult) {
+	settings := install.Settings{}
+
+	check := func(wanted bool) error {
+		results, err := feature.Check(target, values, settings)
+		if err != nil {
+			return err
+		}
+		if results.Successful() != wanted {
+			if wanted {
+				return fmt.Errorf("feature not found on %s '%s'", target.Type(), target.Name())
+			}
+			return fmt.Errorf("feature found on %s '%s'", target.Type(), target.Name())
+		}
+		return nil
+	}
+	assert := func(after string) error {
+		results, err := feature.Assert(target, values, after)
+		result.Assertions = append(result.Assertions, results...)
+		if err != nil {
+			return err
+		}
+		var failed []string
+		for _, v := range results {
+			if !v.Success {
+				failed = append(failed, fmt.Sprintf("'%s' on '%s' (%s)", v.Name, v.Host, v.Message))
+			}
+		}
+		if len(failed) > 0 {
+			return fmt.Errorf("assertions failed: %s", strings.Join(failed, ", "))
+		}
+		return nil
+	}
+
+	if !result.record(featureTestCheckBefore, check(false)) {
+		return
+	}
+	results, err := feature.Add(target, values, settings)
+	if err == nil && !results.Successful() {
+		err = fmt.Errorf("%s", results.AllErrorMessages())
+	}
+	if !result.record(featureTestAdd, err) {
+		return
+	}
+	if !result.record(featureTestCheckAdded, check(true)) {
+		return
+	}
+	if !result.record(featureTestAssertAdded, assert(install.AssertAfterAdd)) {
+		return
+	}
+	results, err = feature.Remove(target, values, settings)
+	if err == nil && !results.Successful() {
+		err = fmt.Errorf("%s", results.AllErrorMessages())
+	}
+	if !result.record(featureTestRemove, err) {
+		return
+	}
+	if !result.record(featureTestCheckRemoved, check(false)) {
+		return
+	}
+	result.record(featureTestAssertRemoved, assert(install.AssertAfterRemove))
+}
+
+// createFeatureTestHost creates a network and a host on it; the cleanup function returned (even on failure, if
+// something has been created) deletes them
+func createFeatureTestHost(name, image string, index int, sizing *pb.HostSizing) (install.Target, func() error, error) {
+	networkName := name + "-net"
+	// On interruption, the host and the network may exist even if their creation isn't completed
+	setFeatureTestAbort(name, func() {
+		if err := client.New().Host.Delete([]string{name}, temporal.GetExecutionTimeout()); err != nil {
+			logrus.Warnf("failed to delete host '%s': %v", name, err)
+		}
+		if err := client.New().Network.Delete([]string{networkName}, temporal.GetExecutionTimeout()); err != nil {
+			logrus.Warnf("failed to delete network '%s': %v", networkName, err)
+		}
+	})
+
+	_, err := client.New().Network.Create(pb.NetworkDefinition{
+		Name: networkName,
+		Cidr: fmt.Sprintf("192.168.%d.0/24", 200+index%50),
+		Gateway: &pb.GatewayDefinition{
+			ImageId: image,
+			Sizing:  sizing,
+		},
+	}, temporal.GetExecutionTimeout())
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create network '%s': %s", networkName, client.DecorateError(err, "creation of network", true).Error())
+	}
+
+	var hostCreated bool
+	cleanup := func() error {
+		var errs []string
+		if hostCreated {
+			if err := client.New().Host.Delete([]string{name}, temporal.GetExecutionTimeout()); err != nil {
+				errs = append(errs, fmt.Sprintf("failed to delete host '%s': %s", name, err.Error()))
+			}
+		}
+		if err := client.New().Network.Delete([]string{networkName}, temporal.GetExecutionTimeout()); err != nil {
+			errs = append(errs, fmt.Sprintf("failed to delete network '%s': %s", networkName, err.Error()))
+		}
+		if len(errs) > 0 {
+			return fmt.Errorf("%s", strings.Join(errs, "; "))
+		}
+		return nil
+	}
+
+	host, err := client.New().Host.Create(pb.HostDefinition{
+		Name:    name,
+		ImageId: image,
+		Network: networkName,
+		Sizing:  sizing,
+	}, temporal.GetExecutionTimeout())
+	if err != nil {
+		return nil, cleanup, fmt.Errorf("failed to create host '%s': %s", name, client.DecorateError(err, "creation of host", true).Error())
+	}
+	hostCreated = true
+
+	err = client.New().SSH.WaitReady(host.Id, temporal.GetConnectionTimeout())
+	if err != nil {
+		return nil, cleanup, fmt.Errorf("failed to reach '%s': %s", name, client.DecorateError(err, "waiting ssh on host", false).Error())
+	}
+	target, err := install.NewHostTarget(host)
+	if err != nil {
+		return nil, cleanup, err
+	}
+	return target, cleanup, nil
+}
+
+// createFeatureTestCluster creates a cluster of small complexity (without remote desktop); the cleanup function
+// returned (even on failure, if something has been created) deletes it
+func createFeatureTestCluster(name, flavorName, image string, index int, sizing *pb.HostSizing) (install.Target, func() error, error) {
+	clusterFlavor, err := flavor.Parse(flavorName)
+	if err != nil {
+		return nil, nil, err
+	}
+	// On interruption, the cluster may exist even if its creation isn't completed
+	setFeatureTestAbort(name, func() {
+		instance, err := cluster.Load(concurrency.RootTask(), name)
+		if err != nil {
+			logrus.Warnf("failed to load cluster '%s': %v", name, err)
+			return
+		}
+		if err := instance.Delete(concurrency.RootTask()); err != nil {
+			logrus.Warnf("failed to delete cluster '%s': %v", name, err)
+		}
+	})
+
+	instance, err := cluster.Create(concurrency.RootTask(), control.Request{
+		Name:                    name,
+		CIDR:                    fmt.Sprintf("10.%d.0.0/16", 200+index%50),
+		Complexity:              complexity.Small,
+		Flavor:                  clusterFlavor,
+		GatewaysDef:             &pb.HostDefinition{ImageId: image, Sizing: sizing},
+		MastersDef:              &pb.HostDefinition{ImageId: image, Sizing: sizing},
+		NodesDef:                &pb.HostDefinition{ImageId: image, Sizing: sizing},
+		DisabledDefaultFeatures: map[string]struct{}{featureTestDisabledFeature: {}},
+	})
+	var cleanup func() error
+	if instance != nil {
+		cleanup = func() error {
+			return instance.Delete(concurrency.RootTask())
+		}
+	}
+	if err != nil {
+		return nil, cleanup, fmt.Errorf("failed to create cluster '%s': %s", name, err.Error())
+	}
+	if instance == nil {
+		return nil, nil, fmt.Errorf("failed to create cluster '%s': unknown reason", name)
+	}
+	target, err := install.NewClusterTarget(concurrency.RootTask(), instance)
+	if err != nil {
+		return nil, cleanup, err
+	}
+	return target, cleanup, nil
+}
+
+var featureTestNameRegexp = regexp.MustCompile(`[^a-z0-9]+`)
+
+// featureTestResourceName returns a name for the resources created to test the feature on the image
+func featureTestResourceName(feature, image string) string {
+	slug := func(s string) string {
+		return strings.Trim(featureTestNameRegexp.ReplaceAllString(strings.ToLower(s), "-"), "-")
+	}
+	return fmt.Sprintf("%s-%s-%s-%s", featureTestResourcePrefix, slug(feature), slug(image), strconv.FormatInt(time.Now().Unix()%1679616, 36))
+}
@@ -29,10 +29,12 @@ import (
 	pb "github.com/CS-SI/SafeScale/lib"
 	"github.com/CS-SI/SafeScale/lib/client"
 	"github.com/CS-SI/SafeScale/lib/server/install"
+	"github.com/CS-SI/SafeScale/lib/server/install/repository"
 	"github.com/CS-SI/SafeScale/lib/utils"
 	clitools "github.com/CS-SI/SafeScale/lib/utils/cli"
 	"github.com/CS-SI/SafeScale/lib/utils/cli/enums/exitcode"
 	"github.com/CS-SI/SafeScale/lib/utils/concurrency"
+	"github.com/CS-SI/SafeScale/lib/utils/scerr"
 	"github.com/CS-SI/SafeScale/lib/utils/temporal"
 )
 
@@ -56,7 +58,9 @@ var HostCmd = cli.Command{
 		hostCheckFeatureCommand,
 		hostAddFeatureCommand,
 		hostDeleteFeatureCommand,
+		hostUpgradeFeatureCommand,
 		hostListFeaturesCommand,
+		hostFeatureLogCommand,
 	},
 }
 
@@ -401,6 +405,10 @@ var hostAddFeatureCommand = cli.Command{
 			Name:  "skip-proxy",
 			Usage: "Disable reverse proxy rules",
 		},
+		cli.BoolFlag{
+			Name:  "dry-run",
+			Usage: "Display what would be done (requirements, hosts, scripts, reverse proxy rules) without touching any host",
+		},
 	},
 
 	Action: func(c *cli.Context) error {
@@ -435,6 +443,19 @@ var hostAddFeatureCommand = cli.Command{
 		settings := install.Settings{}
 		settings.SkipProxy = c.Bool("skip-proxy")
 
+		if c.Bool("dry-run") {
+			target, err := install.NewHostTarget(hostInstance)
+			if err != nil {
+				return clitools.FailureResponse(err)
+			}
+			report, err := feature.DryRun(target, values, settings)
+			if err != nil {
+				msg := fmt.Sprintf("failed to resolve what adding feature '%s' on host '%s' would do: %s", featureName, hostName, err.Error())
+				return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.Run, msg))
+			}
+			return clitools.SuccessResponse(report)
+		}
+
 		// Wait for SSH service on remote host first
 		err = client.New().SSH.WaitReady(hostInstance.Id, temporal.GetConnectionTimeout())
 		if err != nil {
@@ -458,6 +479,11 @@ var hostAddFeatureCommand = cli.Command{
 			}
 			return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.Run, msg))
 		}
+		// Records the feature, its version and its parameters, to be able to upgrade it later
+		err = install.RegisterHostFeature(hostInstance, feature, values)
+		if err != nil {
+			logrus.Warnf("failed to record feature '%s' in host metadata: %v", feature.DisplayName(), err)
+		}
 		return clitools.SuccessResponse(nil)
 	},
 }
@@ -466,18 +492,42 @@ var hostAddFeatureCommand = cli.Command{
 var hostListFeaturesCommand = cli.Command{
 	Name:      "list-features",
 	Aliases:   []string{"list-available-features"},
-	Usage:     "list-features",
-	ArgsUsage: "",
+	Usage:     "list-features [--outdated HOSTNAME]",
+	ArgsUsage: "[HOSTNAME]",
 
 	Flags: []cli.Flag{
 		cli.StringSliceFlag{
 			Name:  "param, p",
 			Usage: "Allow to define content of feature parameters",
 		},
+		cli.BoolFlag{
+			Name:  "outdated",
+			Usage: "List the features installed on HOSTNAME for which a newer version is available",
+		},
 	},
 
 	Action: func(c *cli.Context) error {
 		logrus.Tracef("SafeScale command: {%s}, {%s} with args {%s}", hostCmdName, c.Command.Name, c.Args())
+		if c.Bool("outdated") {
+			if c.NArg() != 1 {
+				_ = cli.ShowSubcommandHelp(c)
+				return clitools.FailureResponse(clitools.ExitOnInvalidArgument("Missing mandatory argument HOSTNAME."))
+			}
+			err := extractHostArgument(c, 0)
+			if err != nil {
+				return clitools.FailureResponse(err)
+			}
+			installed, err := install.HostInstalledFeatures(hostInstance)
+			if err != nil {
+				return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.Run, err.Error()))
+			}
+			versions := map[string]string{}
+			for k, v := range installed {
+				versions[k] = v.Version
+			}
+			return clitools.SuccessResponse(outdatedFeatures(versions))
+		}
+
 		features, err := install.ListFeatures("host")
 		if err != nil {
 			return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.Run, err.Error()))
@@ -490,15 +540,15 @@ var hostListFeaturesCommand = cli.Command{
 var hostCheckFeatureCommand = cli.Command{
 	Name:      "check-feature",
 	Aliases:   []string{"verify-feature"},
-	Usage:     "check-feature HOSTNAME FEATURENAME",
+	Usage:     "check-feature [--drift|--reconcile] HOSTNAME FEATURENAME",
 	ArgsUsage: "HOSTNAME FEATURENAME",
 
-	Flags: []cli.Flag{
+	Flags: append([]cli.Flag{
 		cli.StringSliceFlag{
 			Name:  "param, p",
 			Usage: "Allow to define content of feature parameters",
 		},
-	},
+	}, featureVerifyFlags...),
 
 	Action: func(c *cli.Context) error {
 		logrus.Tracef("SafeScale command: {%s}, {%s} with args {%s}", hostCmdName, c.Command.Name, c.Args())
@@ -553,6 +603,9 @@ var hostCheckFeatureCommand = cli.Command{
 			}
 			return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.NotFound, msg))
 		}
+		if c.Bool("drift") || c.Bool("reconcile") {
+			return verifyFeature(c, feature, target, values)
+		}
 		return clitools.SuccessResponse(nil)
 	},
 }
@@ -569,6 +622,10 @@ var hostDeleteFeatureCommand = cli.Command{
 			Name:  "param, p",
 			Usage: "Define value of feature parameter (can be used multiple times)",
 		},
+		cli.BoolFlag{
+			Name:  "cascade",
+			Usage: "Remove also the installed features requiring the feature",
+		},
 	},
 
 	Action: func(c *cli.Context) error {
@@ -612,8 +669,15 @@ var hostDeleteFeatureCommand = cli.Command{
 		if err != nil {
 			return clitools.FailureResponse(err)
 		}
-		results, err := feature.Remove(target, values, install.Settings{})
+		dependents, err := feature.Dependents(target)
+		if err != nil {
+			return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.Run, err.Error()))
+		}
+		results, err := feature.Remove(target, values, install.Settings{Cascade: c.Bool("cascade")})
 		if err != nil {
+			if _, ok := err.(scerr.ErrNotAvailable); ok {
+				return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.NotApplicable, err.Error()))
+			}
 			msg := fmt.Sprintf("error uninstalling feature '%s' on '%s': %s\n", featureName, hostName, err.Error())
 			return clitools.FailureResponse(clitools.ExitOnRPC(msg))
 		}
@@ -624,10 +688,102 @@ var hostDeleteFeatureCommand = cli.Command{
 			}
 			return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.Run, msg))
 		}
+		for _, name := range append(dependents, feature.DisplayName()) {
+			err = install.UnregisterHostFeature(hostInstance, name)
+			if err != nil {
+				logrus.Warnf("failed to remove feature '%s' from host metadata: %v", name, err)
+			}
+		}
 		return clitools.SuccessResponse(nil)
 	},
 }
 
+// hostUpgradeFeatureCommand handles 'safescale host upgrade-feature HOSTNAME FEATURENAME'
+var hostUpgradeFeatureCommand = cli.Command{
+	Name:      "upgrade-feature",
+	Usage:     "Upgrade a feature installed on host.",
+	ArgsUsage: "HOSTNAME FEATURENAME",
+
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "to",
+			Usage: "Version to upgrade to (default: the latest version available)",
+		},
+		cli.StringSliceFlag{
+			Name:  "param, p",
+			Usage: "Define value of feature parameter, overriding the one used at installation (can be used multiple times)",
+		},
+	},
+
+	Action: func(c *cli.Context) error {
+		logrus.Tracef("SafeScale command: {%s}, {%s} with args {%s}", hostCmdName, c.Command.Name, c.Args())
+		err := extractHostArgument(c, 0)
+		if err != nil {
+			return clitools.FailureResponse(err)
+		}
+
+		err = extractFeatureArgument(c)
+		if err != nil {
+			return clitools.FailureResponse(err)
+		}
+
+		feature, err := install.NewFeature(concurrency.RootTask(), featureUpgradeReference(featureName, c.String("to")))
+		if err != nil {
+			return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.Run, err.Error()))
+		}
+		if feature == nil {
+			msg := fmt.Sprintf("failed to find a feature named '%s'.", featureName)
+			return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.NotFound, msg))
+		}
+
+		installed, err := install.HostInstalledFeatures(hostInstance)
+		if err != nil {
+			return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.Run, err.Error()))
+		}
+		record, ok := installed[feature.DisplayName()]
+		if !ok {
+			msg := fmt.Sprintf("feature '%s' is not recorded as installed on host '%s'", feature.DisplayName(), hostName)
+			return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.NotFound, msg))
+		}
+		if c.String("to") == "" && feature.Version() != "" && record.Version != "" && repository.CompareVersions(feature.Version(), record.Version) <= 0 {
+			return clitools.SuccessResponse(map[string]string{"feature": feature.DisplayName(), "version": record.Version})
+		}
+		values := featureUpgradeValues(record.Parameters, c.StringSlice("param"))
+
+		// Wait for SSH service on remote host first
+		err = client.New().SSH.WaitReady(hostInstance.Id, temporal.GetConnectionTimeout())
+		if err != nil {
+			msg := fmt.Sprintf("failed to reach '%s': %s", hostName, client.DecorateError(err, "waiting ssh on host", false))
+			return clitools.FailureResponse(clitools.ExitOnRPC(msg))
+		}
+
+		target, err := install.NewHostTarget(hostInstance)
+		if err != nil {
+			return clitools.FailureResponse(err)
+		}
+		results, err := feature.Upgrade(target, values, install.Settings{})
+		if err != nil {
+			if _, ok := err.(scerr.ErrNotAvailable); ok {
+				return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.NotApplicable, err.Error()))
+			}
+			msg := fmt.Sprintf("error upgrading feature '%s' on host '%s': %s", featureName, hostName, err.Error())
+			return clitools.FailureResponse(clitools.ExitOnRPC(msg))
+		}
+		if !results.Successful() {
+			msg := fmt.Sprintf("failed to upgrade feature '%s' on host '%s'", featureName, hostName)
+			if Debug || Verbose {
+				msg += fmt.Sprintf(":\n%s", results.AllErrorMessages())
+			}
+			return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.Run, msg))
+		}
+		err = install.RegisterHostFeature(hostInstance, feature, values)
+		if err != nil {
+			logrus.Warnf("failed to record feature '%s' in host metadata: %v", feature.DisplayName(), err)
+		}
+		return clitools.SuccessResponse(map[string]string{"feature": feature.DisplayName(), "version": feature.Version()})
+	},
+}
+
 // constructPBHostDefinitionFromCLI ...
 func constructPBHostDefinitionFromCLI(c *cli.Context, key string) (*pb.HostDefinition, error) {
 	var sizing string
@@ -653,9 +809,9 @@ func constructPBHostDefinitionFromCLI(c *cli.Context, key string) (*pb.HostDefin
 			sizing += fmt.Sprintf("disk >= %.01f,", c.Float64("disk"))
 		}
 	}
-	tokens, err := clitools.ParseParameter(sizing)
+	hostSizing, err := constructPBHostSizing(sizing)
 	if err != nil {
-		return nil, clitools.FailureResponse(clitools.ExitOnInvalidArgument(err.Error()))
+		return nil, err
 	}
 
 	def := pb.HostDefinition{
@@ -664,8 +820,19 @@ func constructPBHostDefinitionFromCLI(c *cli.Context, key string) (*pb.HostDefin
 		Network: c.String("net"),
 		Public:  c.Bool("public"),
 		Force:   c.Bool("force"),
-		Sizing:  &pb.HostSizing{},
+		Sizing:  hostSizing,
 	}
+	return &def, nil
+}
+
+// constructPBHostSizing converts a sizing in format "<component><operator><value>[,...]" to a pb.HostSizing
+func constructPBHostSizing(sizing string) (*pb.HostSizing, error) {
+	tokens, err := clitools.ParseParameter(sizing)
+	if err != nil {
+		return nil, clitools.FailureResponse(clitools.ExitOnInvalidArgument(err.Error()))
+	}
+
+	hostSizing := &pb.HostSizing{}
 	if t, ok := tokens["cpu"]; ok {
 		min, max, err := t.Validate()
 		if err != nil {
@@ -673,11 +840,11 @@ func constructPBHostDefinitionFromCLI(c *cli.Context, key string) (*pb.HostDefin
 		}
 		if min != "" {
 			val, _ := strconv.ParseFloat(min, 64)
-			def.Sizing.MinCpuCount = int32(val)
+			hostSizing.MinCpuCount = int32(val)
 		}
 		if max != "" {
 			val, _ := strconv.Atoi(max)
-			def.Sizing.MaxCpuCount = int32(val)
+			hostSizing.MaxCpuCount = int32(val)
 		}
 	}
 	if t, ok := tokens["cpufreq"]; ok {
@@ -687,7 +854,7 @@ func constructPBHostDefinitionFromCLI(c *cli.Context, key string) (*pb.HostDefin
 		}
 		if min != "" {
 			val, _ := strconv.ParseFloat(min, 64)
-			def.Sizing.MinCpuFreq = float32(val)
+			hostSizing.MinCpuFreq = float32(val)
 		}
 	}
 	if t, ok := tokens["gpu"]; ok {
@@ -697,10 +864,10 @@ func constructPBHostDefinitionFromCLI(c *cli.Context, key string) (*pb.HostDefin
 		}
 		if min != "" {
 			val, _ := strconv.Atoi(min)
-			def.Sizing.GpuCount = int32(val)
+			hostSizing.GpuCount = int32(val)
 		}
 	} else {
-		def.Sizing.GpuCount = -1
+		hostSizing.GpuCount = -1
 	}
 	if t, ok := tokens["ram"]; ok {
 		min, max, err := t.Validate()
@@ -709,11 +876,11 @@ func constructPBHostDefinitionFromCLI(c *cli.Context, key string) (*pb.HostDefin
 		}
 		if min != "" {
 			val, _ := strconv.ParseFloat(min, 64)
-			def.Sizing.MinRamSize = float32(val)
+			hostSizing.MinRamSize = float32(val)
 		}
 		if max != "" {
 			val, _ := strconv.ParseFloat(max, 64)
-			def.Sizing.MaxRamSize = float32(val)
+			hostSizing.MaxRamSize = float32(val)
 		}
 	}
 	if t, ok := tokens["disk"]; ok {
@@ -723,8 +890,40 @@ func constructPBHostDefinitionFromCLI(c *cli.Context, key string) (*pb.HostDefin
 		}
 		if min != "" {
 			val, _ := strconv.Atoi(min)
-			def.Sizing.MinDiskSize = int32(val)
+			hostSizing.MinDiskSize = int32(val)
 		}
 	}
-	return &def, nil
+	return hostSizing, nil
+}
+
+// hostFeatureLogCommand handles 'safescale host feature-log HOSTNAME FEATURENAME'
+var hostFeatureLogCommand = cli.Command{
+	Name:      "feature-log",
+	Aliases:   []string{"feature-logs"},
+	Usage:     "Display the logs of the steps of a feature run on host.",
+	ArgsUsage: "HOSTNAME FEATURENAME",
+	Flags:     featureLogFlags,
+
+	Action: func(c *cli.Context) error {
+		logrus.Tracef("SafeScale command: {%s}, {%s} with args {%s}", hostCmdName, c.Command.Name, c.Args())
+		err := extractHostArgument(c, 0)
+		if err != nil {
+			return clitools.FailureResponse(err)
+		}
+		err = extractFeatureArgument(c)
+		if err != nil {
+			return clitools.FailureResponse(err)
+		}
+
+		logs, err := featureLogs(c, []*pb.Host{hostInstance}, featureName)
+		if err != nil {
+			msg := fmt.Sprintf("failed to read the logs of feature '%s' on host '%s': %s", featureName, hostName, err.Error())
+			return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.Run, msg))
+		}
+		if len(logs) == 0 {
+			msg := fmt.Sprintf("no log of feature '%s' found on host '%s'", featureName, hostName)
+			return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.NotFound, msg))
+		}
+		return clitools.SuccessResponse(logs)
+	},
 }
@@ -18,14 +18,18 @@ package commands
 
 import (
 	"encoding/json"
+	"strings"
 
 	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli"
 
 	pb "github.com/CS-SI/SafeScale/lib"
 	"github.com/CS-SI/SafeScale/lib/client"
+	"github.com/CS-SI/SafeScale/lib/server/install"
 	"github.com/CS-SI/SafeScale/lib/utils"
 	clitools "github.com/CS-SI/SafeScale/lib/utils/cli"
+	"github.com/CS-SI/SafeScale/lib/utils/cli/enums/exitcode"
+	"github.com/CS-SI/SafeScale/lib/utils/scerr"
 	"github.com/CS-SI/SafeScale/lib/utils/temporal"
 )
 
@@ -41,6 +45,8 @@ var NetworkCmd = cli.Command{
 		networkDelete,
 		networkInspect,
 		networkList,
+		networkProxyRulesCommand,
+		networkReverseProxyCommand,
 	},
 }
 
@@ -246,3 +252,121 @@ var networkCreate = cli.Command{
 		return clitools.SuccessResponse(network)
 	},
 }
+
+// networkProxyRulesCommand handles 'safescale network proxy-rules COMMAND'
+var networkProxyRulesCommand = cli.Command{
+	Name:    "proxy-rules",
+	Aliases: []string{"proxy-rule"},
+	Usage:   "proxy-rules COMMAND",
+	Subcommands: []cli.Command{
+		networkProxyRulesListCommand,
+		networkProxyRulesDeleteCommand,
+	},
+}
+
+// networkProxyRulesListCommand handles 'safescale network proxy-rules list NETWORKNAME'
+var networkProxyRulesListCommand = cli.Command{
+	Name:      "list",
+	Aliases:   []string{"ls"},
+	Usage:     "List the rules published by features in the reverse proxy of the network",
+	ArgsUsage: "NETWORKNAME",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "feature, f",
+			Usage: "List only the rules published by the feature",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		logrus.Tracef("SafeScale command: {%s}, {%s} with args {%s}", networkCmdName, c.Command.Name, c.Args())
+		if c.NArg() != 1 {
+			_ = cli.ShowSubcommandHelp(c)
+			return clitools.FailureResponse(clitools.ExitOnInvalidArgument("Missing mandatory argument NETWORKNAME."))
+		}
+
+		rules, kind, err := install.ProxyRules(c.Args().First(), c.String("feature"))
+		if err != nil {
+			if _, ok := err.(scerr.ErrNotFound); ok {
+				return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.NotFound, err.Error()))
+			}
+			return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.Run, err.Error()))
+		}
+		return clitools.SuccessResponse(map[string]interface{}{
+			"kind":  kind,
+			"rules": rules,
+		})
+	},
+}
+
+// networkProxyRulesDeleteCommand handles 'safescale network proxy-rules delete NETWORKNAME RULENAME'
+var networkProxyRulesDeleteCommand = cli.Command{
+	Name:      "delete",
+	Aliases:   []string{"rm", "remove"},
+	Usage:     "Delete a rule from the reverse proxy of the network",
+	ArgsUsage: "NETWORKNAME RULENAME",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "host",
+			Usage: "Delete only the rule applied for this host",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		logrus.Tracef("SafeScale command: {%s}, {%s} with args {%s}", networkCmdName, c.Command.Name, c.Args())
+		switch c.NArg() {
+		case 0:
+			_ = cli.ShowSubcommandHelp(c)
+			return clitools.FailureResponse(clitools.ExitOnInvalidArgument("Missing mandatory argument NETWORKNAME."))
+		case 1:
+			_ = cli.ShowSubcommandHelp(c)
+			return clitools.FailureResponse(clitools.ExitOnInvalidArgument("Missing mandatory argument RULENAME."))
+		}
+
+		deleted, err := install.DeleteProxyRules(c.Args().First(), c.Args().Get(1), c.String("host"))
+		if err != nil {
+			if _, ok := err.(scerr.ErrNotFound); ok {
+				return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.NotFound, err.Error()))
+			}
+			return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.Run, err.Error()))
+		}
+		return clitools.SuccessResponse(deleted)
+	},
+}
+
+// networkReverseProxyCommand handles 'safescale network reverse-proxy NETWORKNAME [KIND]'
+var networkReverseProxyCommand = cli.Command{
+	Name:      "reverse-proxy",
+	Usage:     "Show or select the kind of reverse proxy of the network (" + strings.Join(install.ReverseProxyKinds, ", ") + ")",
+	ArgsUsage: "NETWORKNAME [KIND]",
+	Action: func(c *cli.Context) error {
+		logrus.Tracef("SafeScale command: {%s}, {%s} with args {%s}", networkCmdName, c.Command.Name, c.Args())
+		if c.NArg() < 1 || c.NArg() > 2 {
+			_ = cli.ShowSubcommandHelp(c)
+			return clitools.FailureResponse(clitools.ExitOnInvalidArgument("Missing mandatory argument NETWORKNAME."))
+		}
+
+		networkRef := c.Args().First()
+		if c.NArg() == 2 {
+			err := install.SetReverseProxyKind(networkRef, strings.ToLower(c.Args().Get(1)))
+			if err != nil {
+				switch err.(type) {
+				case scerr.ErrNotFound:
+					return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.NotFound, err.Error()))
+				case scerr.ErrInvalidParameter:
+					return clitools.FailureResponse(clitools.ExitOnInvalidArgument(err.Error()))
+				case scerr.ErrNotAvailable:
+					return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.NotApplicable, err.Error()))
+				default:
+					return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.Run, err.Error()))
+				}
+			}
+		}
+
+		kind, err := install.ReverseProxyKind(networkRef)
+		if err != nil {
+			if _, ok := err.(scerr.ErrNotFound); ok {
+				return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.NotFound, err.Error()))
+			}
+			return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.Run, err.Error()))
+		}
+		return clitools.SuccessResponse(map[string]string{"network": networkRef, "kind": kind})
+	},
+}
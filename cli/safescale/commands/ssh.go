@@ -18,20 +18,28 @@ package commands
 
 import (
 	"fmt"
+	"io"
+	"net"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
+	"text/tabwriter"
 	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli"
+	"golang.org/x/crypto/ssh/terminal"
 
+	pb "github.com/CS-SI/SafeScale/lib"
 	"github.com/CS-SI/SafeScale/lib/client"
+	"github.com/CS-SI/SafeScale/lib/server/cluster"
 	"github.com/CS-SI/SafeScale/lib/system"
 	"github.com/CS-SI/SafeScale/lib/utils"
 	clitools "github.com/CS-SI/SafeScale/lib/utils/cli"
 	"github.com/CS-SI/SafeScale/lib/utils/cli/enums/exitcode"
-	"github.com/CS-SI/SafeScale/lib/utils/cli/enums/outputs"
+	"github.com/CS-SI/SafeScale/lib/utils/concurrency"
+	"github.com/CS-SI/SafeScale/lib/utils/scerr"
 	"github.com/CS-SI/SafeScale/lib/utils/temporal"
 )
 
@@ -46,14 +54,15 @@ var SSHCmd = cli.Command{
 		sshCopy,
 		sshConnect,
 		sshTunnel,
+		sshTunnels,
 		sshClose,
 	},
 }
 
 var sshRun = cli.Command{
 	Name:      "run",
-	Usage:     "Run a command on the host",
-	ArgsUsage: "<Host_name|Host_ID>",
+	Usage:     "Run a command on the host, or in parallel on a group of hosts",
+	ArgsUsage: "<Host_name|Host_ID> | --hosts <Host_name,...> | --cluster <Cluster_name> [--role <role>] | --network <Network_name>",
 	Flags: []cli.Flag{
 		cli.StringFlag{
 			Name:  "c",
@@ -64,13 +73,44 @@ var sshRun = cli.Command{
 			Value: "5",
 			Usage: "timeout in minutes",
 		},
+		cli.StringFlag{
+			Name:  "hosts",
+			Usage: "Comma-separated list of hosts on which to run the command",
+		},
+		cli.StringFlag{
+			Name:  "cluster",
+			Usage: "Name of the cluster on which hosts to run the command",
+		},
+		cli.StringFlag{
+			Name:  "role",
+			Value: "all",
+			Usage: "Role of the hosts of the cluster on which to run the command (masters, nodes, gateways or all)",
+		},
+		cli.StringFlag{
+			Name:  "network",
+			Usage: "Name of the network on which hosts to run the command",
+		},
+		cli.IntFlag{
+			Name:  "parallel",
+			Value: 10,
+			Usage: "Maximum number of hosts running the command at the same time",
+		},
+		cli.IntFlag{
+			Name:  "batch",
+			Value: 0,
+			Usage: "Run the command by successive batches of this number of hosts (0 for all at once)",
+		},
+		cli.BoolFlag{
+			Name:  "fail-fast",
+			Usage: "Don't run the command on the remaining hosts as soon as it failed on one",
+		},
+		cli.BoolFlag{
+			Name:  "json",
+			Usage: "Display the report of a run on several hosts in JSON instead of a table",
+		},
 	},
 	Action: func(c *cli.Context) error {
 		logrus.Tracef("SafeScale command: {%s}, {%s} with args {%s}", sshCmdName, c.Command.Name, c.Args())
-		if c.NArg() != 1 {
-			_ = cli.ShowSubcommandHelp(c)
-			return clitools.FailureResponse(clitools.ExitOnInvalidArgument("Missing mandatory argument <Host_name>."))
-		}
 
 		var timeout time.Duration
 		if c.IsSet("timeout") {
@@ -79,7 +119,26 @@ var sshRun = cli.Command{
 			timeout = temporal.GetHostTimeout()
 
 		}
-		retcode, _, _, err := client.New().SSH.Run(c.Args().Get(0), c.String("c"), outputs.DISPLAY, temporal.GetConnectionTimeout(), timeout)
+
+		if c.IsSet("hosts") || c.IsSet("cluster") || c.IsSet("network") {
+			if c.NArg() != 0 {
+				_ = cli.ShowSubcommandHelp(c)
+				return clitools.FailureResponse(clitools.ExitOnInvalidArgument("<Host_name> cannot be used with --hosts, --cluster or --network."))
+			}
+			return runOnHosts(c, timeout)
+		}
+
+		if c.NArg() != 1 {
+			_ = cli.ShowSubcommandHelp(c)
+			return clitools.FailureResponse(clitools.ExitOnInvalidArgument("Missing mandatory argument <Host_name>."))
+		}
+
+		// Local input is forwarded only if it doesn't come from a terminal
+		var stdin io.Reader
+		if !terminal.IsTerminal(int(os.Stdin.Fd())) {
+			stdin = os.Stdin
+		}
+		retcode, err := client.New().SSH.Exec(c.Args().Get(0), c.String("c"), stdin, os.Stdout, os.Stderr, timeout)
 		if err != nil {
 			return clitools.FailureResponse(clitools.ExitOnRPC(utils.Capitalize(client.DecorateError(err, "ssh run", false).Error())))
 		}
@@ -90,6 +149,149 @@ var sshRun = cli.Command{
 	},
 }
 
+// runOnHosts runs the command on the group of hosts designated by the flags, and displays the report
+func runOnHosts(c *cli.Context, timeout time.Duration) error {
+	if c.String("c") == "" {
+		return clitools.FailureResponse(clitools.ExitOnInvalidArgument("Missing command to run (-c)."))
+	}
+
+	hosts, err := resolveHostGroup(c)
+	if err != nil {
+		return clitools.FailureResponse(err)
+	}
+	if len(hosts) == 0 {
+		return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.NotFound, "No host found matching the selection."))
+	}
+
+	results, err := client.New().SSH.RunOnHosts(hosts, c.String("c"), c.Int("parallel"), c.Int("batch"), c.Bool("fail-fast"), timeout)
+	if err != nil {
+		return clitools.FailureResponse(clitools.ExitOnRPC(utils.Capitalize(client.DecorateError(err, "ssh run", false).Error())))
+	}
+
+	failures := 0
+	for _, r := range results {
+		if r.Status != "ok" {
+			failures++
+		}
+	}
+
+	if c.Bool("json") {
+		if failures > 0 {
+			_ = clitools.SuccessResponse(results)
+			return cli.NewExitError("", int(exitcode.Run))
+		}
+		return clitools.SuccessResponse(results)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "HOST\tSTATUS\tEXIT CODE\tDURATION\tOUTPUT")
+	for _, r := range results {
+		output := r.Error
+		if output == "" {
+			output = lastLine(r.Stdout)
+			if r.Status == "failed" {
+				if errLine := lastLine(r.Stderr); errLine != "" {
+					output = errLine
+				}
+			}
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\n", r.Host, r.Status, r.ExitCode, r.Duration.Round(time.Millisecond), output)
+	}
+	_ = w.Flush()
+	fmt.Printf("\n%d host(s), %d failure(s)\n", len(results), failures)
+	if failures > 0 {
+		return cli.NewExitError("", int(exitcode.Run))
+	}
+	return nil
+}
+
+// lastLine returns the last non-empty line of out
+func lastLine(out string) string {
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+// resolveHostGroup returns the names of the hosts designated by --hosts, --cluster/--role or --network
+func resolveHostGroup(c *cli.Context) ([]string, error) {
+	switch {
+	case c.IsSet("hosts"):
+		var hosts []string
+		for _, h := range strings.Split(c.String("hosts"), ",") {
+			if h = strings.TrimSpace(h); h != "" {
+				hosts = append(hosts, h)
+			}
+		}
+		return hosts, nil
+
+	case c.IsSet("cluster"):
+		clusterName := c.String("cluster")
+		instance, err := cluster.Load(concurrency.RootTask(), clusterName)
+		if err != nil {
+			if _, ok := err.(scerr.ErrNotFound); ok {
+				return nil, clitools.ExitOnErrorWithMessage(exitcode.NotFound, fmt.Sprintf("Cluster '%s' not found.", clusterName))
+			}
+			return nil, clitools.ExitOnRPC(fmt.Sprintf("failed to query for cluster '%s': %s", clusterName, err.Error()))
+		}
+		task := concurrency.RootTask()
+		var hosts []string
+		role := c.String("role")
+		if role == "gateways" || role == "all" {
+			netCfg, err := instance.GetNetworkConfig(task)
+			if err != nil {
+				return nil, clitools.ExitOnRPC(err.Error())
+			}
+			for _, id := range []string{netCfg.GatewayID, netCfg.SecondaryGatewayID} {
+				if id == "" {
+					continue
+				}
+				gw, err := client.New().Host.Inspect(id, temporal.GetExecutionTimeout())
+				if err != nil {
+					return nil, clitools.ExitOnRPC(utils.Capitalize(client.DecorateError(err, "inspection of gateway", false).Error()))
+				}
+				hosts = append(hosts, gw.GetName())
+			}
+		}
+		switch role {
+		case "masters":
+			hosts = append(hosts, instance.ListMasterNames(task)...)
+		case "nodes":
+			hosts = append(hosts, instance.ListNodeNames(task)...)
+		case "all":
+			hosts = append(hosts, instance.ListMasterNames(task)...)
+			hosts = append(hosts, instance.ListNodeNames(task)...)
+		case "gateways":
+		default:
+			return nil, clitools.ExitOnInvalidArgument(fmt.Sprintf("Invalid role '%s': must be masters, nodes, gateways or all.", role))
+		}
+		return hosts, nil
+
+	default:
+		// Hosts of a network are the gateways of the network and the hosts using one of them as default gateway
+		networkName := c.String("network")
+		network, err := client.New().Network.Inspect(networkName, temporal.GetExecutionTimeout())
+		if err != nil {
+			return nil, clitools.ExitOnRPC(utils.Capitalize(client.DecorateError(err, "inspection of network", false).Error()))
+		}
+		gateways := map[string]bool{}
+		for _, id := range []string{network.GetGatewayId(), network.GetSecondaryGatewayId()} {
+			if id != "" {
+				gateways[id] = true
+			}
+		}
+		list, err := client.New().Host.List(false, temporal.GetExecutionTimeout())
+		if err != nil {
+			return nil, clitools.ExitOnRPC(utils.Capitalize(client.DecorateError(err, "list of hosts", false).Error()))
+		}
+		var hosts []string
+		for _, h := range list.GetHosts() {
+			if gateways[h.GetId()] || gateways[h.GetGatewayId()] {
+				hosts = append(hosts, h.GetName())
+			}
+		}
+		return hosts, nil
+	}
+}
+
 func normalizeFileName(fileName string) string {
 	absPath, _ := filepath.Abs(fileName)
 	if _, err := os.Stat(absPath); err != nil {
@@ -100,14 +302,18 @@ func normalizeFileName(fileName string) string {
 
 var sshCopy = cli.Command{
 	Name:      "copy",
-	Usage:     "Copy a local file/directory to an host or copy from host to local",
-	ArgsUsage: "from to  Ex: /my/local/file.txt host1:/remote/path/",
+	Usage:     "Copy recursively local files/directories to an host, from an host to local, or between 2 hosts; an interrupted copy is resumed",
+	ArgsUsage: "from to  Ex: /my/local/file.txt host1:/remote/path/ or host1:/remote/path host2:/other/path",
 	Flags: []cli.Flag{
 		cli.StringFlag{
 			Name:  "timeout",
 			Value: "5",
 			Usage: "timeout in minutes",
 		},
+		cli.BoolFlag{
+			Name:  "no-progress",
+			Usage: "Don't display the progress of the copy",
+		},
 	},
 	Action: func(c *cli.Context) error {
 		logrus.Tracef("SafeScale command: {%s}, {%s} with args {%s}", sshCmdName, c.Command.Name, c.Args())
@@ -122,17 +328,49 @@ var sshCopy = cli.Command{
 		} else {
 			timeout = temporal.GetHostTimeout()
 		}
-		retcode, _, _, err := client.New().SSH.Copy(normalizeFileName(c.Args().Get(0)), normalizeFileName(c.Args().Get(1)), temporal.GetConnectionTimeout(), timeout)
+
+		var progress func(system.TransferProgress)
+		if !c.Bool("no-progress") {
+			progress = displayTransferProgress
+		}
+		err := client.New().SSH.CopyFiles(normalizeFileName(c.Args().Get(0)), normalizeFileName(c.Args().Get(1)), progress, timeout)
+		if progress != nil {
+			fmt.Fprintln(os.Stderr)
+		}
 		if err != nil {
 			return clitools.FailureResponse(clitools.ExitOnRPC(utils.Capitalize(client.DecorateError(err, "ssh copy", true).Error())))
 		}
-		if retcode != 0 {
-			return clitools.FailureResponse(clitools.ExitOnErrorWithMessage(exitcode.Run, fmt.Sprintf("copy failed: retcode=%d (%s)", retcode, system.SSHErrorString(retcode))))
-		}
 		return clitools.SuccessResponse(nil)
 	},
 }
 
+// displayTransferProgress displays on stderr the advancement of a copy
+func displayTransferProgress(p system.TransferProgress) {
+	percent := 100
+	if p.TotalSize > 0 {
+		percent = int(p.TotalDone * 100 / p.TotalSize)
+	}
+	name := p.Path
+	if name == "" {
+		name = "."
+	}
+	fmt.Fprintf(os.Stderr, "\r\033[K%3d%% %s/%s %s", percent, humanSize(p.TotalDone), humanSize(p.TotalSize), name)
+}
+
+// humanSize formats a size in bytes with binary unit prefixes
+func humanSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%dB", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
 var sshConnect = cli.Command{
 	Name:      "connect",
 	Usage:     "Connect to the host with interactive shell",
@@ -174,18 +412,39 @@ var sshConnect = cli.Command{
 
 var sshTunnel = cli.Command{
 	Name:      "tunnel",
-	Usage:     "Create a ssh tunnel between admin host and a host in the cloud",
-	ArgsUsage: "<Host_name|Host_ID --local local_port  --remote remote_port>",
+	Usage:     "Create a ssh tunnel between admin host and a host in the cloud, kept open by safescaled until closed",
+	ArgsUsage: "<Host_name|Host_ID> [--local local_port] [--remote remote_port] [--target address] [--reverse] [--socks port]",
 	Flags: []cli.Flag{
 		cli.IntFlag{
 			Name:  "local",
 			Value: 8080,
-			Usage: "local tunnel's port, if not set all",
+			Usage: "local tunnel's port (0 to let safescaled choose one)",
 		},
 		cli.IntFlag{
 			Name:  "remote",
 			Value: 8080,
-			Usage: "remote tunnel's port, if not set all",
+			Usage: "remote tunnel's port",
+		},
+		cli.StringFlag{
+			Name:  "target",
+			Usage: "address the connections are relayed to, as seen from the host (or from admin host with --reverse); default is 127.0.0.1 on remote (or local) port",
+		},
+		cli.StringFlag{
+			Name:  "bind-address",
+			Value: "127.0.0.1",
+			Usage: "local address the tunnel listens on",
+		},
+		cli.BoolFlag{
+			Name:  "reverse",
+			Usage: "listen on remote port of the host and relay the connections to local port of admin host",
+		},
+		cli.IntFlag{
+			Name:  "socks",
+			Usage: "open a SOCKS5 proxy on this local port, relaying the connections from the host",
+		},
+		cli.StringFlag{
+			Name:  "name",
+			Usage: "name of the tunnel; default is built from host, mode and port",
 		},
 		cli.StringFlag{
 			Name:  "timeout",
@@ -206,34 +465,77 @@ var sshTunnel = cli.Command{
 		}
 
 		remotePort := c.Int("remote")
-		if 0 > localPort || localPort > 65535 {
+		if 0 > remotePort || remotePort > 65535 {
 			return clitools.FailureResponse(clitools.ExitOnInvalidOption(fmt.Sprintf("remote port value is wrong, %d is not a valid port\n", remotePort)))
 		}
 
-		timeout := time.Duration(c.Float64("timeout")) * time.Minute
+		socksPort := c.Int("socks")
+		if 0 > socksPort || socksPort > 65535 {
+			return clitools.FailureResponse(clitools.ExitOnInvalidOption(fmt.Sprintf("socks port value is wrong, %d is not a valid port\n", socksPort)))
+		}
+
+		def := pb.TunnelDefinition{
+			Name: c.String("name"),
+			Host: &pb.Reference{Name: c.Args().Get(0)},
+		}
+		bindAddress := c.String("bind-address")
+		switch {
+		case c.IsSet("socks"):
+			if c.Bool("reverse") {
+				return clitools.FailureResponse(clitools.ExitOnInvalidOption("--socks and --reverse are mutually exclusive"))
+			}
+			def.Mode = string(system.ForwardDynamic)
+			def.Bind = net.JoinHostPort(bindAddress, strconv.Itoa(socksPort))
+		case c.Bool("reverse"):
+			def.Mode = string(system.ForwardRemote)
+			def.Bind = net.JoinHostPort("127.0.0.1", strconv.Itoa(remotePort))
+			def.Target = c.String("target")
+			if def.Target == "" {
+				def.Target = net.JoinHostPort("127.0.0.1", strconv.Itoa(localPort))
+			}
+		default:
+			def.Mode = string(system.ForwardLocal)
+			def.Bind = net.JoinHostPort(bindAddress, strconv.Itoa(localPort))
+			def.Target = c.String("target")
+			if def.Target == "" {
+				def.Target = net.JoinHostPort("127.0.0.1", strconv.Itoa(remotePort))
+			}
+		}
 
-		//c.GlobalInt("port") is the grpc port aka. 50051
-		err := client.New().SSH.CreateTunnel(c.Args().Get(0), localPort, remotePort, timeout)
+		timeout := time.Duration(c.Float64("timeout")) * time.Minute
+		tunnel, err := client.New().Tunnel.Create(def, timeout)
 		if err != nil {
 			return clitools.FailureResponse(clitools.ExitOnRPC(utils.Capitalize(client.DecorateError(err, "ssh tunnel", false).Error())))
 		}
-		return clitools.SuccessResponse(nil)
+		return clitools.SuccessResponse(tunnel)
+	},
+}
+
+var sshTunnels = cli.Command{
+	Name:    "tunnels",
+	Aliases: []string{"list-tunnels"},
+	Usage:   "List the ssh tunnels kept open by safescaled",
+	Action: func(c *cli.Context) error {
+		logrus.Tracef("SafeScale command: {%s}, {%s} with args {%s}", sshCmdName, c.Command.Name, c.Args())
+		list, err := client.New().Tunnel.List(0)
+		if err != nil {
+			return clitools.FailureResponse(clitools.ExitOnRPC(utils.Capitalize(client.DecorateError(err, "ssh tunnels", false).Error())))
+		}
+		return clitools.SuccessResponse(list.GetTunnels())
 	},
 }
 
 var sshClose = cli.Command{
 	Name:      "close",
-	Usage:     "Close one or several ssh tunnel",
-	ArgsUsage: "<Host_name|Host_ID> --local local_port --remote remote_port",
+	Usage:     "Close one or several ssh tunnels",
+	ArgsUsage: "<Tunnel_name|Tunnel_ID|Host_name|Host_ID> [--local local_port] [--remote remote_port]",
 	Flags: []cli.Flag{
 		cli.StringFlag{
 			Name:  "local",
-			Value: ".*",
 			Usage: "local tunnel's port, if not set all",
 		},
 		cli.StringFlag{
 			Name:  "remote",
-			Value: ".*",
 			Usage: "remote tunnel's port, if not set all",
 		},
 		cli.StringFlag{
@@ -246,29 +548,66 @@ var sshClose = cli.Command{
 		logrus.Tracef("SafeScale command: {%s}, {%s} with args {%s}", sshCmdName, c.Command.Name, c.Args())
 		if c.NArg() != 1 {
 			_ = cli.ShowSubcommandHelp(c)
-			return clitools.FailureResponse(clitools.ExitOnInvalidArgument("Missing mandatory argument <Host_name>."))
+			return clitools.FailureResponse(clitools.ExitOnInvalidArgument("Missing mandatory argument <Tunnel_name|Host_name>."))
 		}
 
 		strLocalPort := c.String("local")
-		if c.IsSet("local") {
+		if strLocalPort != "" {
 			localPort, err := strconv.Atoi(strLocalPort)
 			if err != nil || 0 > localPort || localPort > 65535 {
-				return clitools.FailureResponse(clitools.ExitOnInvalidOption(fmt.Sprintf("local port value is wrong, %d is not a valid port\n", localPort)))
+				return clitools.FailureResponse(clitools.ExitOnInvalidOption(fmt.Sprintf("local port value is wrong, %s is not a valid port\n", strLocalPort)))
 			}
 		}
 		strRemotePort := c.String("remote")
-		if c.IsSet("remote") {
+		if strRemotePort != "" {
 			remotePort, err := strconv.Atoi(strRemotePort)
 			if err != nil || 0 > remotePort || remotePort > 65535 {
-				return clitools.FailureResponse(clitools.ExitOnInvalidOption(fmt.Sprintf("remote port value is wrong, %d is not a valid port\n", remotePort)))
+				return clitools.FailureResponse(clitools.ExitOnInvalidOption(fmt.Sprintf("remote port value is wrong, %s is not a valid port\n", strRemotePort)))
 			}
 		}
 
 		timeout := time.Duration(c.Float64("timeout")) * time.Minute
-		err := client.New().SSH.CloseTunnels(c.Args().Get(0), strLocalPort, strRemotePort, timeout)
+		clientSession := client.New()
+		list, err := clientSession.Tunnel.List(timeout)
 		if err != nil {
 			return clitools.FailureResponse(clitools.ExitOnRPC(utils.Capitalize(client.DecorateError(err, "ssh close", false).Error())))
 		}
+
+		ref := c.Args().Get(0)
+		var selected []*pb.Tunnel
+		for _, t := range list.GetTunnels() {
+			if t.GetId() == ref || t.GetName() == ref {
+				selected = []*pb.Tunnel{t}
+				break
+			}
+			if t.GetHost().GetName() != ref && t.GetHost().GetId() != ref {
+				continue
+			}
+			localPort, remotePort := tunnelPorts(t)
+			if (strLocalPort == "" || strLocalPort == localPort) && (strRemotePort == "" || strRemotePort == remotePort) {
+				selected = append(selected, t)
+			}
+		}
+		if len(selected) == 0 {
+			return clitools.FailureResponse(clitools.ExitOnNotFound(fmt.Sprintf("no tunnel matching '%s'", ref)))
+		}
+
+		for _, t := range selected {
+			err = clientSession.Tunnel.Close(t.GetId(), timeout)
+			if err != nil {
+				return clitools.FailureResponse(clitools.ExitOnRPC(utils.Capitalize(client.DecorateError(err, "ssh close", false).Error())))
+			}
+		}
 		return clitools.SuccessResponse(nil)
 	},
 }
+
+// tunnelPorts returns the local and remote ports of a tunnel; remote port is empty for a SOCKS tunnel
+func tunnelPorts(t *pb.Tunnel) (string, string) {
+	_, bindPort, _ := net.SplitHostPort(t.GetBind())
+	_, targetPort, _ := net.SplitHostPort(t.GetTarget())
+	if t.GetMode() == string(system.ForwardRemote) {
+		return targetPort, bindPort
+	}
+	return bindPort, targetPort
+}
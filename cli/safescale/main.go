@@ -58,6 +58,8 @@ func cleanup(onAbort bool) {
 				fmt.Printf("failed to stop the process %v\n", err)
 			}
 		}
+		// The process exits anyway, the throwaway targets of the tests of features in progress mustn't survive it
+		commands.AbortFeatureTests()
 	}
 	profileCloseFunc()
 	os.Exit(0)
@@ -187,6 +189,9 @@ func main() {
 	app.Commands = append(app.Commands, commands.ClusterCommand)
 	sort.Sort(cli.CommandsByName(commands.ClusterCommand.Subcommands))
 
+	app.Commands = append(app.Commands, commands.FeatureCmd)
+	sort.Sort(cli.CommandsByName(commands.FeatureCmd.Subcommands))
+
 	sort.Sort(cli.CommandsByName(app.Commands))
 
 	// err := app.Run(os.Args)
@@ -33,6 +33,7 @@ import (
 	"google.golang.org/grpc/reflection"
 
 	pb "github.com/CS-SI/SafeScale/lib"
+	"github.com/CS-SI/SafeScale/lib/server/handlers"
 	"github.com/CS-SI/SafeScale/lib/server/iaas"
 	"github.com/CS-SI/SafeScale/lib/server/listeners"
 	"github.com/CS-SI/SafeScale/lib/server/utils"
@@ -106,6 +107,10 @@ func work() {
 	pb.RegisterSshServiceServer(s, &listeners.SSHListener{})
 	pb.RegisterTemplateServiceServer(s, &listeners.TemplateListener{})
 	pb.RegisterTenantServiceServer(s, &listeners.TenantListener{})
+	pb.RegisterTunnelServiceServer(s, &listeners.TunnelListener{})
+	pb.RegisterAutoscalerServiceServer(s, &listeners.AutoscalerListener{})
+	pb.RegisterHealthCheckerServiceServer(s, &listeners.HealthCheckerListener{})
+	pb.RegisterBackupSchedulerServiceServer(s, &listeners.BackupSchedulerListener{})
 	pb.RegisterVolumeServiceServer(s, &listeners.VolumeListener{})
 
 	// logrus.Println("Initializing service factory")
@@ -114,6 +119,9 @@ func work() {
 	// Register reflection service on gRPC server.
 	reflection.Register(s)
 
+	logrus.Infoln("Restoring scheduled tasks of clusters")
+	go handlers.RestoreSchedules()
+
 	version := Version + ", build " + Revision + " (" + BuildDate + ")"
 	fmt.Printf("Safescaled version: %s\nReady to serve :-)\n", version)
 	if err := s.Serve(lis); err != nil {
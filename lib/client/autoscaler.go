@@ -0,0 +1,82 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package client
+
+import (
+	"time"
+
+	googleprotobuf "github.com/golang/protobuf/ptypes/empty"
+
+	pb "github.com/CS-SI/SafeScale/lib"
+	"github.com/CS-SI/SafeScale/lib/server/utils"
+)
+
+// autoscaler is the part of the safescale client handling the autoscalers of clusters run by safescaled
+type autoscaler struct {
+	session *Session
+}
+
+// Enable starts the autoscaling of a node pool of a cluster following the policy
+func (a *autoscaler) Enable(policy pb.AutoscalePolicy, timeout time.Duration) (*pb.Autoscaler, error) {
+	a.session.Connect()
+	defer a.session.Disconnect()
+	service := pb.NewAutoscalerServiceClient(a.session.connection)
+	ctx, err := utils.GetContext(true)
+	if err != nil {
+		return nil, err
+	}
+
+	as, err := service.Enable(ctx, &policy)
+	if err != nil {
+		return nil, DecorateError(err, "enabling of autoscaling", true)
+	}
+	return as, nil
+}
+
+// Disable stops the autoscaling of the node pool poolName of the cluster clusterName
+func (a *autoscaler) Disable(clusterName, poolName string, timeout time.Duration) error {
+	a.session.Connect()
+	defer a.session.Disconnect()
+	service := pb.NewAutoscalerServiceClient(a.session.connection)
+	ctx, err := utils.GetContext(true)
+	if err != nil {
+		return err
+	}
+
+	_, err = service.Disable(ctx, &pb.AutoscalePolicy{Cluster: clusterName, Pool: poolName})
+	if err != nil {
+		return DecorateError(err, "disabling of autoscaling", true)
+	}
+	return nil
+}
+
+// List returns the autoscalers running
+func (a *autoscaler) List(timeout time.Duration) (*pb.AutoscalerList, error) {
+	a.session.Connect()
+	defer a.session.Disconnect()
+	service := pb.NewAutoscalerServiceClient(a.session.connection)
+	ctx, err := utils.GetContext(true)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := service.List(ctx, &googleprotobuf.Empty{})
+	if err != nil {
+		return nil, DecorateError(err, "list of autoscalers", true)
+	}
+	return list, nil
+}
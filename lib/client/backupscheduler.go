@@ -0,0 +1,82 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package client
+
+import (
+	"time"
+
+	googleprotobuf "github.com/golang/protobuf/ptypes/empty"
+
+	pb "github.com/CS-SI/SafeScale/lib"
+	"github.com/CS-SI/SafeScale/lib/server/utils"
+)
+
+// backupScheduler is the part of the safescale client handling the scheduled backups of clusters run by safescaled
+type backupScheduler struct {
+	session *Session
+}
+
+// Enable starts the periodic backup of a cluster following the policy
+func (b *backupScheduler) Enable(policy pb.BackupPolicy, timeout time.Duration) (*pb.BackupScheduler, error) {
+	b.session.Connect()
+	defer b.session.Disconnect()
+	service := pb.NewBackupSchedulerServiceClient(b.session.connection)
+	ctx, err := utils.GetContext(true)
+	if err != nil {
+		return nil, err
+	}
+
+	bs, err := service.Enable(ctx, &policy)
+	if err != nil {
+		return nil, DecorateError(err, "enabling of scheduled backups", true)
+	}
+	return bs, nil
+}
+
+// Disable stops the periodic backup of the cluster clusterName
+func (b *backupScheduler) Disable(clusterName string, timeout time.Duration) error {
+	b.session.Connect()
+	defer b.session.Disconnect()
+	service := pb.NewBackupSchedulerServiceClient(b.session.connection)
+	ctx, err := utils.GetContext(true)
+	if err != nil {
+		return err
+	}
+
+	_, err = service.Disable(ctx, &pb.BackupPolicy{Cluster: clusterName})
+	if err != nil {
+		return DecorateError(err, "disabling of scheduled backups", true)
+	}
+	return nil
+}
+
+// List returns the backup schedulers running
+func (b *backupScheduler) List(timeout time.Duration) (*pb.BackupSchedulerList, error) {
+	b.session.Connect()
+	defer b.session.Disconnect()
+	service := pb.NewBackupSchedulerServiceClient(b.session.connection)
+	ctx, err := utils.GetContext(true)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := service.List(ctx, &googleprotobuf.Empty{})
+	if err != nil {
+		return nil, DecorateError(err, "list of backup schedulers", true)
+	}
+	return list, nil
+}
@@ -21,6 +21,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 
 	logr "github.com/sirupsen/logrus"
 
@@ -28,23 +29,28 @@ import (
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 
+	pb "github.com/CS-SI/SafeScale/lib"
 	"github.com/CS-SI/SafeScale/lib/server/utils"
 	"github.com/CS-SI/SafeScale/lib/utils/temporal"
 )
 
 // Session units the different resources proposed by safescaled as safescale client
 type Session struct {
-	Bucket     *bucket
-	Data       *data
-	Host       *host
-	Image      *image
-	JobManager *jobManager
-	Network    *network
-	Share      *share
-	SSH        *ssh
-	Template   *template
-	Tenant     *tenant
-	Volume     *volume
+	Bucket          *bucket
+	Data            *data
+	Host            *host
+	Image           *image
+	JobManager      *jobManager
+	Network         *network
+	Share           *share
+	SSH             *ssh
+	Template        *template
+	Tenant          *tenant
+	Tunnel          *tunnel
+	Autoscaler      *autoscaler
+	HealthChecker   *healthChecker
+	BackupScheduler *backupScheduler
+	Volume          *volume
 
 	safescaledHost string
 	safescaledPort int
@@ -88,10 +94,60 @@ func New() Client {
 	s.SSH = &ssh{session: s}
 	s.Template = &template{session: s}
 	s.Tenant = &tenant{session: s}
+	s.Tunnel = &tunnel{session: s}
+	s.Autoscaler = &autoscaler{session: s}
+	s.HealthChecker = &healthChecker{session: s}
+	s.BackupScheduler = &backupScheduler{session: s}
 	s.Volume = &volume{session: s}
 	return s
 }
 
+// NewOnTenant returns an instance of safescale Client whose requests on hosts target the tenant 'tenant'
+// instead of the current tenant of safescaled
+func NewOnTenant(tenant string) Client {
+	s := New()
+	s.tenantName = tenant
+	return s
+}
+
+var (
+	// hostTenants contains the tenants of the hosts not living in the current tenant of safescaled, indexed by host ID or name
+	hostTenants     = map[string]string{}
+	hostTenantsLock sync.RWMutex
+)
+
+// SetHostTenant records that the host referenced by ref (ID or name) lives in the tenant 'tenant', so the
+// requests on this host from any Client target this tenant; an empty tenant forgets the record
+func SetHostTenant(ref, tenant string) {
+	hostTenantsLock.Lock()
+	defer hostTenantsLock.Unlock()
+
+	if tenant == "" {
+		delete(hostTenants, ref)
+	} else {
+		hostTenants[ref] = tenant
+	}
+}
+
+// GetHostTenant returns the tenant recorded for the host referenced by ref (ID or name), or an empty string if
+// the host is reached in the current tenant of safescaled
+func GetHostTenant(ref string) string {
+	hostTenantsLock.RLock()
+	defer hostTenantsLock.RUnlock()
+
+	return hostTenants[ref]
+}
+
+// hostReference returns the reference of the host 'ref', in the tenant of the session if set, or else
+// in the tenant recorded for the host
+func (s *Session) hostReference(ref string) *pb.Reference {
+	tenant := s.tenantName
+	if tenant == "" {
+		tenant = GetHostTenant(ref)
+	}
+	return &pb.Reference{Name: ref, TenantId: tenant}
+}
+
 // Connect establishes connection with safescaled
 func (s *Session) Connect() {
 	if s.connection == nil {
@@ -0,0 +1,82 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package client
+
+import (
+	"time"
+
+	googleprotobuf "github.com/golang/protobuf/ptypes/empty"
+
+	pb "github.com/CS-SI/SafeScale/lib"
+	"github.com/CS-SI/SafeScale/lib/server/utils"
+)
+
+// healthChecker is the part of the safescale client handling the health checkers of clusters run by safescaled
+type healthChecker struct {
+	session *Session
+}
+
+// Enable starts the periodic health check of a cluster following the policy
+func (h *healthChecker) Enable(policy pb.HealthCheckPolicy, timeout time.Duration) (*pb.HealthChecker, error) {
+	h.session.Connect()
+	defer h.session.Disconnect()
+	service := pb.NewHealthCheckerServiceClient(h.session.connection)
+	ctx, err := utils.GetContext(true)
+	if err != nil {
+		return nil, err
+	}
+
+	hc, err := service.Enable(ctx, &policy)
+	if err != nil {
+		return nil, DecorateError(err, "enabling of health check", true)
+	}
+	return hc, nil
+}
+
+// Disable stops the periodic health check of the cluster clusterName
+func (h *healthChecker) Disable(clusterName string, timeout time.Duration) error {
+	h.session.Connect()
+	defer h.session.Disconnect()
+	service := pb.NewHealthCheckerServiceClient(h.session.connection)
+	ctx, err := utils.GetContext(true)
+	if err != nil {
+		return err
+	}
+
+	_, err = service.Disable(ctx, &pb.HealthCheckPolicy{Cluster: clusterName})
+	if err != nil {
+		return DecorateError(err, "disabling of health check", true)
+	}
+	return nil
+}
+
+// List returns the health checkers running
+func (h *healthChecker) List(timeout time.Duration) (*pb.HealthCheckerList, error) {
+	h.session.Connect()
+	defer h.session.Disconnect()
+	service := pb.NewHealthCheckerServiceClient(h.session.connection)
+	ctx, err := utils.GetContext(true)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := service.List(ctx, &googleprotobuf.Empty{})
+	if err != nil {
+		return nil, DecorateError(err, "list of health checkers", true)
+	}
+	return list, nil
+}
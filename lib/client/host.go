@@ -58,7 +58,7 @@ func (h *host) Inspect(name string, timeout time.Duration) (*pb.Host, error) {
 		return nil, err
 	}
 
-	return service.Inspect(ctx, &pb.Reference{Name: name})
+	return service.Inspect(ctx, h.session.hostReference(name))
 
 }
 
@@ -72,7 +72,7 @@ func (h *host) Status(name string, timeout time.Duration) (*pb.HostStatus, error
 		return nil, err
 	}
 
-	return service.Status(ctx, &pb.Reference{Name: name})
+	return service.Status(ctx, h.session.hostReference(name))
 }
 
 // Reboots host
@@ -85,7 +85,7 @@ func (h *host) Reboot(name string, timeout time.Duration) error {
 		return err
 	}
 
-	_, err = service.Reboot(ctx, &pb.Reference{Name: name})
+	_, err = service.Reboot(ctx, h.session.hostReference(name))
 	return err
 }
 
@@ -99,7 +99,7 @@ func (h *host) Start(name string, timeout time.Duration) error {
 		return err
 	}
 
-	_, err = service.Start(ctx, &pb.Reference{Name: name})
+	_, err = service.Start(ctx, h.session.hostReference(name))
 	return err
 }
 
@@ -112,7 +112,7 @@ func (h *host) Stop(name string, timeout time.Duration) error {
 		return err
 	}
 
-	_, err = service.Stop(ctx, &pb.Reference{Name: name})
+	_, err = service.Stop(ctx, h.session.hostReference(name))
 	return err
 }
 
@@ -126,6 +126,9 @@ func (h *host) Create(def pb.HostDefinition, timeout time.Duration) (*pb.Host, e
 		return nil, err
 	}
 
+	if def.Tenant == "" {
+		def.Tenant = h.session.tenantName
+	}
 	return service.Create(ctx, &def)
 }
 
@@ -147,7 +150,7 @@ func (h *host) Delete(names []string, timeout time.Duration) error {
 
 	hostDeleter := func(aname string) {
 		defer wg.Done()
-		_, err := service.Delete(ctx, &pb.Reference{Name: aname})
+		_, err := service.Delete(ctx, h.session.hostReference(aname))
 		if err != nil {
 			mutex.Lock()
 			errs = append(errs, err.Error())
@@ -181,7 +184,7 @@ func (h *host) SSHConfig(name string) (*system.SSHConfig, error) {
 		return nil, err
 	}
 
-	pbSSHCfg, err := service.SSH(ctx, &pb.Reference{Name: name})
+	pbSSHCfg, err := service.SSH(ctx, h.session.hostReference(name))
 	if err != nil {
 		return nil, err
 	}
@@ -66,7 +66,7 @@ func (n *network) Delete(names []string, timeout time.Duration) error {
 
 	networkDeleter := func(aname string) {
 		defer wg.Done()
-		_, err := service.Delete(ctx, &pb.Reference{Name: aname})
+		_, err := service.Delete(ctx, &pb.Reference{Name: aname, TenantId: n.session.tenantName})
 
 		if err != nil {
 			mutex.Lock()
@@ -106,7 +106,7 @@ func (n *network) Destroy(names []string, timeout time.Duration) error {
 
 	networkDeleter := func(aname string) {
 		defer wg.Done()
-		_, err := service.Destroy(ctx, &pb.Reference{Name: aname})
+		_, err := service.Destroy(ctx, &pb.Reference{Name: aname, TenantId: n.session.tenantName})
 
 		if err != nil {
 			mutex.Lock()
@@ -138,7 +138,7 @@ func (n *network) Inspect(name string, timeout time.Duration) (*pb.Network, erro
 		return nil, err
 	}
 
-	return service.Inspect(ctx, &pb.Reference{Name: name})
+	return service.Inspect(ctx, &pb.Reference{Name: name, TenantId: n.session.tenantName})
 
 }
 
@@ -152,6 +152,9 @@ func (n *network) Create(def pb.NetworkDefinition, timeout time.Duration) (*pb.N
 		return nil, err
 	}
 
+	if def.Tenant == "" {
+		def.Tenant = n.session.tenantName
+	}
 	return service.Create(ctx, &def)
 
 }
@@ -17,19 +17,29 @@
 package client
 
 import (
+	"bytes"
+	"context"
 	"fmt"
-	"os/exec"
-	"reflect"
-	"strconv"
+	"io"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/ssh/terminal"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	pb "github.com/CS-SI/SafeScale/lib"
 	"github.com/CS-SI/SafeScale/lib/server/utils"
 	"github.com/CS-SI/SafeScale/lib/system"
 	"github.com/CS-SI/SafeScale/lib/utils/cli/enums/outputs"
+	"github.com/CS-SI/SafeScale/lib/utils/concurrency"
 	"github.com/CS-SI/SafeScale/lib/utils/retry"
 	"github.com/CS-SI/SafeScale/lib/utils/retry/enums/verdict"
 	"github.com/CS-SI/SafeScale/lib/utils/scerr"
@@ -265,125 +275,673 @@ func (s *ssh) getSSHConfigFromName(name string, timeout time.Duration) (*system.
 	}
 	service := pb.NewHostServiceClient(s.session.connection)
 
-	sshConfig, err := service.SSH(ctx, &pb.Reference{Name: name})
+	sshConfig, err := service.SSH(ctx, s.session.hostReference(name))
 	if err != nil {
 		return nil, err
 	}
 	return utils.ToSystemSSHConfig(sshConfig), nil
 }
 
-// Connect ...
+// Connect opens an interactive shell on the host through safescaled, as user username if set
 func (s *ssh) Connect(hostname, username, shell string, timeout time.Duration) error {
-	sshCfg, err := s.getSSHConfigFromName(hostname, timeout)
+	start := &pb.SshExecStart{
+		Host:     s.session.hostReference(hostname),
+		Username: username,
+		Shell:    shell,
+	}
+
+	var stdin io.Reader = os.Stdin
+	fd := int(os.Stdin.Fd())
+	if terminal.IsTerminal(fd) {
+		state, err := terminal.MakeRaw(fd)
+		if err != nil {
+			return fmt.Errorf("unable to set terminal in raw mode: %s", err.Error())
+		}
+		defer func() {
+			_ = terminal.Restore(fd, state)
+		}()
+
+		width, height, err := terminal.GetSize(fd)
+		if err != nil {
+			width, height = 80, 24
+		}
+		start.Tty = true
+		start.Term = os.Getenv("TERM")
+		start.Window = &pb.SshWindowSize{Width: int32(width), Height: int32(height)}
+	}
+
+	_, err := s.exec(start, stdin, os.Stdout, os.Stderr, timeout)
+	return err
+}
+
+// Exec executes command on the host through safescaled, streaming the outputs live; stdin is forwarded to the
+// command if not nil. Returns the exit status of the command.
+func (s *ssh) Exec(hostName, command string, stdin io.Reader, stdout, stderr io.Writer, timeout time.Duration) (int, error) {
+	start := &pb.SshExecStart{
+		Host:    s.session.hostReference(hostName),
+		Command: command,
+	}
+	return s.exec(start, stdin, stdout, stderr, timeout)
+}
+
+// exec runs the execution described by start, connecting its standard streams to stdin, stdout and stderr
+func (s *ssh) exec(start *pb.SshExecStart, stdin io.Reader, stdout, stderr io.Writer, timeout time.Duration) (int, error) {
+	s.session.Connect()
+	defer s.session.Disconnect()
+	service := pb.NewSshServiceClient(s.session.connection)
+
+	var (
+		ctx    context.Context
+		cancel context.CancelFunc
+		err    error
+	)
+	if timeout > 0 {
+		ctx, cancel, err = utils.GetTimeoutContext(timeout)
+	} else {
+		ctx, err = utils.GetContext(true)
+		if err == nil {
+			ctx, cancel = context.WithCancel(ctx)
+		}
+	}
 	if err != nil {
-		return err
+		return -1, err
 	}
-	return retry.WhileUnsuccessfulWhereRetcode255Delay5SecondsWithNotify(
-		func() error {
-			return sshCfg.Enter(username, shell)
-		},
-		temporal.GetConnectSSHTimeout(),
-		func(t retry.Try, v verdict.Enum) {
-			if v == verdict.Retry {
-				log.Infof("Remote SSH service on host '%s' isn't ready, retrying...\n", hostname)
+	defer cancel()
+
+	stream, err := service.Exec(ctx)
+	if err != nil {
+		return -1, err
+	}
+
+	// grpc doesn't allow concurrent calls to Send
+	var sendLock sync.Mutex
+	send := func(req *pb.SshExecRequest) error {
+		sendLock.Lock()
+		defer sendLock.Unlock()
+		return stream.Send(req)
+	}
+
+	err = send(&pb.SshExecRequest{Payload: &pb.SshExecRequest_Start{Start: start}})
+	if err != nil {
+		return -1, err
+	}
+
+	// Forwards local input
+	if stdin != nil {
+		go func() {
+			buf := make([]byte, 32*1024)
+			for {
+				n, rerr := stdin.Read(buf)
+				if n > 0 {
+					data := make([]byte, n)
+					copy(data, buf[:n])
+					if send(&pb.SshExecRequest{Payload: &pb.SshExecRequest_Stdin{Stdin: data}}) != nil {
+						return
+					}
+				}
+				if rerr != nil {
+					_ = send(&pb.SshExecRequest{Payload: &pb.SshExecRequest_StdinClose{StdinClose: true}})
+					return
+				}
 			}
-		},
-	)
+		}()
+	} else {
+		err = send(&pb.SshExecRequest{Payload: &pb.SshExecRequest_StdinClose{StdinClose: true}})
+		if err != nil {
+			return -1, err
+		}
+	}
+
+	// Forwards local window size changes (with a terminal) or interruptions (without)
+	sigs := make(chan os.Signal, 1)
+	if start.GetTty() {
+		signal.Notify(sigs, syscall.SIGWINCH)
+	} else {
+		signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	}
+	defer signal.Stop(sigs)
+	go func() {
+		fd := int(os.Stdin.Fd())
+		for {
+			select {
+			case sig := <-sigs:
+				var req *pb.SshExecRequest
+				switch sig {
+				case syscall.SIGWINCH:
+					width, height, err := terminal.GetSize(fd)
+					if err != nil {
+						continue
+					}
+					req = &pb.SshExecRequest{Payload: &pb.SshExecRequest_Resize{Resize: &pb.SshWindowSize{Width: int32(width), Height: int32(height)}}}
+				case syscall.SIGTERM:
+					req = &pb.SshExecRequest{Payload: &pb.SshExecRequest_Signal{Signal: "TERM"}}
+				default:
+					req = &pb.SshExecRequest{Payload: &pb.SshExecRequest_Signal{Signal: "INT"}}
+				}
+				_ = send(req)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return -1, fmt.Errorf("execution ended without exit status")
+		}
+		if err != nil {
+			return -1, err
+		}
+		switch payload := resp.GetPayload().(type) {
+		case *pb.SshExecResponse_Stdout:
+			_, _ = stdout.Write(payload.Stdout)
+		case *pb.SshExecResponse_Stderr:
+			_, _ = stderr.Write(payload.Stderr)
+		case *pb.SshExecResponse_ExitStatus:
+			return int(payload.ExitStatus), nil
+		}
+	}
 }
 
-func (s *ssh) CreateTunnel(name string, localPort int, remotePort int, timeout time.Duration) error {
-	sshCfg, err := s.getSSHConfigFromName(name, timeout)
+// WaitReady waits the SSH service of remote host is ready, for 'timeout' duration
+func (s *ssh) WaitReady(hostName string, timeout time.Duration) error {
+	if timeout < temporal.GetHostTimeout() {
+		timeout = temporal.GetHostTimeout()
+	}
+	sshCfg, err := s.getHostSSHConfig(hostName)
 	if err != nil {
 		return err
 	}
 
-	if sshCfg.GatewayConfig == nil {
-		sshCfg.GatewayConfig = &system.SSHConfig{
-			User:          sshCfg.User,
-			Host:          sshCfg.Host,
-			PrivateKey:    sshCfg.PrivateKey,
-			Port:          sshCfg.Port,
-			GatewayConfig: nil,
+	_, err = sshCfg.WaitServerReady("ready", timeout)
+	return err
+}
+
+// isNotFound tells if the error returned by safescaled means the resource doesn't exist
+func isNotFound(err error) bool {
+	return status.Code(err) == codes.NotFound
+}
+
+// Stat lists the files of remotePath on the host (remotePath itself if it is a file)
+func (s *ssh) Stat(hostName, remotePath string, withChecksum bool, timeout time.Duration) ([]system.FileInfo, bool, error) {
+	s.session.Connect()
+	defer s.session.Disconnect()
+	service := pb.NewSshServiceClient(s.session.connection)
+	ctx, cancel, err := utils.GetTimeoutContext(timeout)
+	if err != nil {
+		return nil, false, err
+	}
+	defer cancel()
+
+	resp, err := service.Stat(ctx, &pb.SshStatRequest{Host: s.session.hostReference(hostName), Path: remotePath, Checksum: withChecksum})
+	if err != nil {
+		return nil, false, err
+	}
+	files := make([]system.FileInfo, 0, len(resp.GetFiles()))
+	for _, f := range resp.GetFiles() {
+		files = append(files, utils.ToSystemFileInfo(f))
+	}
+	return files, resp.GetIsDir(), nil
+}
+
+// Upload copies recursively localPath to remotePath on the host, resuming the files partially transferred
+// by a previous attempt; progress is notified through the callback progress (may be nil)
+func (s *ssh) Upload(hostName, localPath, remotePath string, progress func(system.TransferProgress), timeout time.Duration) error {
+	files, isDir, err := system.ListLocalFiles(localPath, true)
+	if err != nil {
+		return err
+	}
+
+	// Resolves destination like cp does: an existing folder receives the source inside it
+	root := remotePath
+	existing, dstIsDir, err := s.Stat(hostName, root, true, timeout)
+	if err != nil && !isNotFound(err) {
+		return err
+	}
+	if dstIsDir {
+		root = path.Join(remotePath, filepath.Base(localPath))
+		existing, dstIsDir, err = s.Stat(hostName, root, true, timeout)
+		if err != nil && !isNotFound(err) {
+			return err
 		}
 	}
-	sshCfg.Host = "127.0.0.1"
-	sshCfg.Port = remotePort
-	sshCfg.LocalPort = localPort
+	if len(existing) > 0 && isDir != dstIsDir {
+		return fmt.Errorf("cannot copy '%s' on '%s': one is a folder, the other is a file", localPath, root)
+	}
+	existingByPath := map[string]system.FileInfo{}
+	for _, f := range existing {
+		existingByPath[f.Path] = f
+	}
 
-	return retry.WhileUnsuccessfulWhereRetcode255Delay5SecondsWithNotify(
-		func() error {
+	s.session.Connect()
+	defer s.session.Disconnect()
+	service := pb.NewSshServiceClient(s.session.connection)
+	ctx, cancel, err := utils.GetTimeoutContext(timeout)
+	if err != nil {
+		return err
+	}
+	defer cancel()
 
-			tunnels, _, err := sshCfg.CreateTunneling()
-			if err != nil {
-				for _, t := range tunnels {
-					nerr := t.Close()
-					if nerr != nil {
-						log.Errorf("error closing ssh tunnel: %v", nerr)
-					}
-				}
-				return fmt.Errorf("unable to create command : %s", err.Error())
+	stream, err := service.Upload(ctx)
+	if err != nil {
+		return err
+	}
+	err = stream.Send(&pb.SshUploadChunk{Host: s.session.hostReference(hostName), Destination: root})
+	if err != nil {
+		return err
+	}
+
+	state := system.TransferProgress{}
+	for _, f := range files {
+		state.TotalSize += f.Size
+	}
+	buf := make([]byte, system.TransferChunkSize)
+	for _, f := range files {
+		var offset int64
+		if e, ok := existingByPath[f.Path]; ok {
+			offset = system.ResumeOffset(f, &e)
+		}
+		state.Path, state.Size, state.Done = f.Path, f.Size, offset
+		state.TotalDone += offset
+		if offset == f.Size {
+			// already on destination
+			if progress != nil {
+				progress(state)
 			}
+			continue
+		}
 
-			return nil
-		},
-		temporal.GetConnectSSHTimeout(),
-		func(t retry.Try, v verdict.Enum) {
-			if v == verdict.Retry {
-				log.Infof("Remote SSH service on host '%s' isn't ready, retrying...\n", name)
+		err = s.uploadFile(stream, filepath.Join(localPath, filepath.FromSlash(f.Path)), f, offset, buf, func(n int64) {
+			state.Done += n
+			state.TotalDone += n
+			if progress != nil {
+				progress(state)
 			}
-		},
-	)
+		})
+		if err != nil {
+			if err == io.EOF {
+				// the server closed the stream, the reason is given by CloseAndRecv
+				_, err = stream.CloseAndRecv()
+			}
+			return err
+		}
+	}
+
+	_, err = stream.CloseAndRecv()
+	return err
 }
 
-func (s *ssh) CloseTunnels(name string, localPort string, remotePort string, timeout time.Duration) error {
-	sshCfg, err := s.getSSHConfigFromName(name, timeout)
+// uploadFile sends the content of the local file from offset in the upload stream
+func (s *ssh) uploadFile(stream pb.SshService_UploadClient, localPath string, f system.FileInfo, offset int64, buf []byte, notify func(int64)) error {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	_, err = file.Seek(offset, io.SeekStart)
 	if err != nil {
 		return err
 	}
 
-	if sshCfg.GatewayConfig == nil {
-		sshCfg.GatewayConfig = &system.SSHConfig{
-			User:          sshCfg.User,
-			Host:          sshCfg.Host,
-			PrivateKey:    sshCfg.PrivateKey,
-			Port:          sshCfg.Port,
-			GatewayConfig: nil,
+	chunk := &pb.SshFileChunk{Info: utils.ToPBSshFileInfo(f), Offset: offset}
+	for {
+		n, rerr := io.ReadFull(file, buf)
+		if rerr == io.EOF || rerr == io.ErrUnexpectedEOF {
+			chunk.Last = true
+		} else if rerr != nil {
+			return rerr
 		}
-		sshCfg.Host = "127.0.0.1"
+		chunk.Data = buf[:n]
+		err = stream.Send(&pb.SshUploadChunk{Chunk: chunk})
+		if err != nil {
+			return err
+		}
+		notify(int64(n))
+		if chunk.Last {
+			return nil
+		}
+		offset += int64(n)
+		chunk = &pb.SshFileChunk{Offset: offset}
 	}
+}
 
-	cmdString := fmt.Sprintf("ssh .* %s:%s:%s %s@%s .*", localPort, sshCfg.Host, remotePort, sshCfg.GatewayConfig.User, sshCfg.GatewayConfig.Host)
+// Download copies recursively remotePath of the host to localPath, resuming the files partially transferred
+// by a previous attempt; progress is notified through the callback progress (may be nil)
+func (s *ssh) Download(hostName, remotePath, localPath string, progress func(system.TransferProgress), timeout time.Duration) error {
+	files, isDir, err := s.Stat(hostName, remotePath, true, timeout)
+	if err != nil {
+		return err
+	}
 
-	bytes, err := exec.Command("pgrep", "-f", cmdString).Output()
-	if err == nil {
-		portStrs := strings.Split(strings.Trim(string(bytes), "\n"), "\n")
-		for _, portStr := range portStrs {
-			_, err = strconv.Atoi(portStr)
+	// Resolves destination like cp does: an existing folder receives the source inside it
+	root := localPath
+	if info, err := os.Stat(localPath); err == nil && info.IsDir() {
+		root = filepath.Join(localPath, path.Base(remotePath))
+	}
+	existing, dstIsDir, err := system.ListLocalFiles(root, true)
+	if err != nil {
+		if _, ok := err.(scerr.ErrNotFound); !ok {
+			return err
+		}
+	}
+	if len(existing) > 0 && isDir != dstIsDir {
+		return fmt.Errorf("cannot copy '%s' on '%s': one is a folder, the other is a file", remotePath, root)
+	}
+	existingByPath := map[string]system.FileInfo{}
+	for _, f := range existing {
+		existingByPath[f.Path] = f
+	}
+
+	state := system.TransferProgress{}
+	offsets := map[string]int64{}
+	for _, f := range files {
+		state.TotalSize += f.Size
+		if e, ok := existingByPath[f.Path]; ok {
+			offsets[f.Path] = system.ResumeOffset(f, &e)
+		}
+	}
+
+	s.session.Connect()
+	defer s.session.Disconnect()
+	service := pb.NewSshServiceClient(s.session.connection)
+	ctx, cancel, err := utils.GetTimeoutContext(timeout)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	stream, err := service.Download(ctx, &pb.SshDownloadRequest{Host: s.session.hostReference(hostName), Source: remotePath, Offsets: offsets})
+	if err != nil {
+		return err
+	}
+
+	var (
+		file    *os.File
+		current system.FileInfo
+		target  string
+	)
+	defer func() {
+		if file != nil {
+			_ = file.Close()
+		}
+	}()
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if chunk.GetInfo() != nil {
+			current = utils.ToSystemFileInfo(chunk.GetInfo())
+			target = root
+			if current.Path != "" {
+				target = filepath.Join(root, filepath.FromSlash(current.Path))
+			}
+			err = os.MkdirAll(filepath.Dir(target), 0755)
+			if err != nil {
+				return err
+			}
+			file, err = os.OpenFile(target, os.O_WRONLY|os.O_CREATE, current.Mode)
+			if err != nil {
+				return err
+			}
+			err = file.Truncate(chunk.GetOffset())
+			if err == nil {
+				_, err = file.Seek(chunk.GetOffset(), io.SeekStart)
+			}
+			if err != nil {
+				return err
+			}
+			state.Path, state.Size, state.Done = current.Path, current.Size, chunk.GetOffset()
+			state.TotalDone += chunk.GetOffset()
+		}
+		if file == nil {
+			return fmt.Errorf("received data without file information")
+		}
+
+		n, err := file.Write(chunk.GetData())
+		if err != nil {
+			return err
+		}
+		state.Done += int64(n)
+		state.TotalDone += int64(n)
+		if progress != nil {
+			progress(state)
+		}
+
+		if chunk.GetLast() {
+			err = file.Close()
+			file = nil
 			if err != nil {
-				log.Errorf("atoi failed on pid: %s", reflect.TypeOf(err).String())
-				return fmt.Errorf("unable to close tunnel :%s", err.Error())
+				return err
 			}
-			err = exec.Command("kill", "-9", portStr).Run()
+			err = verifyLocalChecksum(target, current.Checksum)
 			if err != nil {
-				log.Errorf("kill -9 failed: %s\n", reflect.TypeOf(err).String())
-				return fmt.Errorf("unable to close tunnel :%s", err.Error())
+				return err
 			}
 		}
 	}
+	return nil
+}
 
+// verifyLocalChecksum checks the local file has the expected checksum; if not, the file is removed so
+// that the next attempt transfers it from the beginning
+func verifyLocalChecksum(localPath, checksum string) error {
+	if checksum == "" {
+		return nil
+	}
+	file, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	sum, _, err := system.Checksum(file)
+	_ = file.Close()
+	if err != nil {
+		return err
+	}
+	if sum != checksum {
+		_ = os.Remove(localPath)
+		return fmt.Errorf("checksum mismatch for '%s'", localPath)
+	}
 	return nil
 }
 
-// WaitReady waits the SSH service of remote host is ready, for 'timeout' duration
-func (s *ssh) WaitReady(hostName string, timeout time.Duration) error {
-	if timeout < temporal.GetHostTimeout() {
-		timeout = temporal.GetHostTimeout()
+// Transfer copies recursively srcPath of the host srcHost to dstPath of the host dstHost; data doesn't go through
+// the local host. Progress is notified through the callback progress (may be nil)
+func (s *ssh) Transfer(srcHost, srcPath, dstHost, dstPath string, progress func(system.TransferProgress), timeout time.Duration) error {
+	s.session.Connect()
+	defer s.session.Disconnect()
+	service := pb.NewSshServiceClient(s.session.connection)
+	ctx, cancel, err := utils.GetTimeoutContext(timeout)
+	if err != nil {
+		return err
 	}
-	sshCfg, err := s.getHostSSHConfig(hostName)
+	defer cancel()
+
+	stream, err := service.Transfer(ctx, &pb.SshTransferRequest{
+		SourceHost:      s.session.hostReference(srcHost),
+		Source:          srcPath,
+		DestinationHost: s.session.hostReference(dstHost),
+		Destination:     dstPath,
+	})
 	if err != nil {
 		return err
 	}
+	for {
+		p, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if progress != nil {
+			progress(system.TransferProgress{
+				Path:      p.GetPath(),
+				Done:      p.GetDone(),
+				Size:      p.GetSize(),
+				TotalDone: p.GetTotalDone(),
+				TotalSize: p.GetTotalSize(),
+			})
+		}
+	}
+}
 
-	_, err = sshCfg.WaitServerReady("ready", timeout)
-	return err
+// CopyFiles copies recursively files between local host and a host, or between 2 hosts, with from and to in
+// the form [<host>:]<path>. An interrupted copy is resumed on retry; progress is notified through the callback
+// progress (may be nil)
+func (s *ssh) CopyFiles(from, to string, progress func(system.TransferProgress), timeout time.Duration) error {
+	hostFrom, err := extracthostName(from)
+	if err != nil {
+		return err
+	}
+	hostTo, err := extracthostName(to)
+	if err != nil {
+		return err
+	}
+	if hostFrom == "" && hostTo == "" {
+		return fmt.Errorf("no host name specified neither in from nor to")
+	}
+	fromPath, err := extractPath(from)
+	if err != nil {
+		return err
+	}
+	toPath, err := extractPath(to)
+	if err != nil {
+		return err
+	}
+
+	return retry.WhileUnsuccessfulDelay5Seconds(
+		func() error {
+			var err error
+			switch {
+			case hostFrom != "" && hostTo != "":
+				err = s.Transfer(hostFrom, fromPath, hostTo, toPath, progress, timeout)
+			case hostFrom != "":
+				err = s.Download(hostFrom, fromPath, toPath, progress, timeout)
+			default:
+				err = s.Upload(hostTo, fromPath, toPath, progress, timeout)
+			}
+			if err != nil {
+				switch status.Code(err) {
+				case codes.NotFound, codes.InvalidArgument, codes.FailedPrecondition:
+					// retrying won't help
+					return retry.AbortedError("", err)
+				}
+				if _, ok := err.(scerr.ErrNotFound); ok {
+					return retry.AbortedError("", err)
+				}
+				log.Warnf("copy interrupted, resuming: %v", err)
+			}
+			return err
+		},
+		timeout,
+	)
+}
+
+// HostRunResult contains the result of the execution of a command on one host of a group
+type HostRunResult struct {
+	Host     string        `json:"host"`
+	Status   string        `json:"status"` // one of "ok", "failed" (non-zero exit code), "error" (execution not possible) or "skipped"
+	ExitCode int           `json:"exit_code"`
+	Stdout   string        `json:"stdout"`
+	Stderr   string        `json:"stderr"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// RunOnHosts executes command on each host of hosts, at most parallelism at the same time (all if <= 0).
+// If batch > 0, hosts are processed by successive batches of this size, each batch waiting for the end of the
+// previous one. If failFast is true, the hosts not started yet are skipped as soon as a command fails.
+// Results are returned in the order of hosts.
+func (s *ssh) RunOnHosts(hosts []string, command string, parallelism, batch int, failFast bool, timeout time.Duration) ([]HostRunResult, error) {
+	if len(hosts) == 0 {
+		return nil, scerr.InvalidParameterError("hosts", "cannot be empty slice")
+	}
+	if command == "" {
+		return nil, scerr.InvalidParameterError("command", "cannot be empty string")
+	}
+	if parallelism <= 0 || parallelism > len(hosts) {
+		parallelism = len(hosts)
+	}
+	if batch <= 0 || batch > len(hosts) {
+		batch = len(hosts)
+	}
+
+	var (
+		results = make([]HostRunResult, len(hosts))
+		lock    sync.Mutex
+		failed  bool
+	)
+	slots := make(chan struct{}, parallelism)
+
+	for begin := 0; begin < len(hosts); begin += batch {
+		end := begin + batch
+		if end > len(hosts) {
+			end = len(hosts)
+		}
+
+		tg, err := concurrency.NewTaskGroup(nil)
+		if err != nil {
+			return nil, err
+		}
+		for i := begin; i < end; i++ {
+			_, err = tg.Start(func(t concurrency.Task, p concurrency.TaskParameters) (concurrency.TaskResult, error) {
+				index := p.(int)
+				slots <- struct{}{}
+				defer func() { <-slots }()
+
+				result := HostRunResult{Host: hosts[index], ExitCode: -1}
+				lock.Lock()
+				skip := failFast && failed
+				lock.Unlock()
+				if skip {
+					result.Status = "skipped"
+				} else {
+					// each execution uses its own connection to safescaled
+					sub := &ssh{session: &Session{safescaledHost: s.session.safescaledHost, safescaledPort: s.session.safescaledPort}}
+					var stdout, stderr bytes.Buffer
+					begins := time.Now()
+					retcode, err := sub.Exec(hosts[index], command, nil, &stdout, &stderr, timeout)
+					result.Duration = time.Since(begins)
+					result.Stdout, result.Stderr = stdout.String(), stderr.String()
+					switch {
+					case err != nil:
+						result.Status = "error"
+						result.Error = DecorateError(err, "ssh run", false).Error()
+					case retcode != 0:
+						result.Status = "failed"
+						result.ExitCode = retcode
+					default:
+						result.Status = "ok"
+						result.ExitCode = 0
+					}
+					if result.Status != "ok" {
+						lock.Lock()
+						failed = true
+						lock.Unlock()
+					}
+				}
+
+				lock.Lock()
+				results[index] = result
+				lock.Unlock()
+				return nil, nil
+			}, i)
+			if err != nil {
+				return nil, err
+			}
+		}
+		_, err = tg.WaitGroup()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
 }
@@ -0,0 +1,99 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package client
+
+import (
+	"time"
+
+	googleprotobuf "github.com/golang/protobuf/ptypes/empty"
+
+	pb "github.com/CS-SI/SafeScale/lib"
+	"github.com/CS-SI/SafeScale/lib/server/utils"
+)
+
+// tunnel is the part of the safescale client handling the tunnels kept open by safescaled
+type tunnel struct {
+	session *Session
+}
+
+// Create opens a tunnel through an host
+func (t *tunnel) Create(def pb.TunnelDefinition, timeout time.Duration) (*pb.Tunnel, error) {
+	t.session.Connect()
+	defer t.session.Disconnect()
+	service := pb.NewTunnelServiceClient(t.session.connection)
+	ctx, err := utils.GetContext(true)
+	if err != nil {
+		return nil, err
+	}
+
+	tun, err := service.Create(ctx, &def)
+	if err != nil {
+		return nil, DecorateError(err, "creation of tunnel", true)
+	}
+	return tun, nil
+}
+
+// List returns the tunnels opened
+func (t *tunnel) List(timeout time.Duration) (*pb.TunnelList, error) {
+	t.session.Connect()
+	defer t.session.Disconnect()
+	service := pb.NewTunnelServiceClient(t.session.connection)
+	ctx, err := utils.GetContext(true)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := service.List(ctx, &googleprotobuf.Empty{})
+	if err != nil {
+		return nil, DecorateError(err, "list of tunnels", true)
+	}
+	return list, nil
+}
+
+// Inspect returns the tunnel whose name or id is ref
+func (t *tunnel) Inspect(ref string, timeout time.Duration) (*pb.Tunnel, error) {
+	t.session.Connect()
+	defer t.session.Disconnect()
+	service := pb.NewTunnelServiceClient(t.session.connection)
+	ctx, err := utils.GetContext(true)
+	if err != nil {
+		return nil, err
+	}
+
+	tun, err := service.Inspect(ctx, &pb.Reference{Name: ref})
+	if err != nil {
+		return nil, DecorateError(err, "inspection of tunnel", true)
+	}
+	return tun, nil
+}
+
+// Close closes the tunnel whose name or id is ref
+func (t *tunnel) Close(ref string, timeout time.Duration) error {
+	t.session.Connect()
+	defer t.session.Disconnect()
+	service := pb.NewTunnelServiceClient(t.session.connection)
+	ctx, err := utils.GetContext(true)
+	if err != nil {
+		return err
+	}
+
+	_, err = service.Close(ctx, &pb.Reference{Name: ref})
+	if err != nil {
+		return DecorateError(err, "closing of tunnel", true)
+	}
+	return nil
+}
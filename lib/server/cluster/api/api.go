@@ -21,6 +21,7 @@ import (
 	propsv1 "github.com/CS-SI/SafeScale/lib/server/cluster/control/properties/v1"
 	propsv2 "github.com/CS-SI/SafeScale/lib/server/cluster/control/properties/v2"
 	"github.com/CS-SI/SafeScale/lib/server/cluster/enums/clusterstate"
+	"github.com/CS-SI/SafeScale/lib/server/cluster/enums/exportformat"
 	"github.com/CS-SI/SafeScale/lib/server/cluster/identity"
 	"github.com/CS-SI/SafeScale/lib/server/iaas"
 	"github.com/CS-SI/SafeScale/lib/utils/concurrency"
@@ -48,10 +49,10 @@ type Cluster interface {
 	GetState(concurrency.Task) (clusterstate.Enum, error)
 	// AddNode adds a node
 	AddNode(concurrency.Task, *pb.HostDefinition) (string, error)
-	// AddNodes adds several nodes
-	AddNodes(concurrency.Task, int, *pb.HostDefinition) ([]string, error)
-	// DeleteLastNode deletes a node
-	DeleteLastNode(concurrency.Task, string) error
+	// AddNodes adds several nodes in a node pool (the default pool if empty string)
+	AddNodes(concurrency.Task, string, int, *pb.HostDefinition) ([]string, error)
+	// DeleteLastNode deletes the last node added in a node pool (the default pool if empty string)
+	DeleteLastNode(concurrency.Task, string, string) error
 	// DeleteSpecificNode deletes a node identified by its ID
 	DeleteSpecificNode(concurrency.Task, string, string) error
 	// ListMasters lists the masters (if there is such masters in the flavor...)
@@ -81,6 +82,93 @@ type Cluster interface {
 	// CountNodes counts the nodes of the cluster
 	CountNodes(concurrency.Task) (uint, error)
 
+	// ListNodePools lists the node pools of the cluster
+	ListNodePools(concurrency.Task) ([]*propsv1.NodePool, error)
+	// AddNodePool creates a node pool (name, count, definition of the nodes, labels, taints, tenant and CIDR of
+	// the site hosting the nodes if not the tenant of the cluster) and its nodes
+	AddNodePool(concurrency.Task, string, int, *pb.HostDefinition, map[string]string, []string, string, string) ([]string, error)
+	// ResizeNodePool adds or deletes nodes of a node pool to reach the count wanted
+	ResizeNodePool(concurrency.Task, string, int) ([]string, error)
+	// DeleteNodePool deletes a node pool and its nodes
+	DeleteNodePool(concurrency.Task, string) error
+	// GetWorkload returns the current workload of the nodes of a node pool (the default pool if empty string)
+	GetWorkload(concurrency.Task, string) (*Workload, error)
+
+	// CheckHealth probes the masters and the nodes, records their health and updates the state of the cluster
+	CheckHealth(concurrency.Task) ([]*propsv1.NodeHealth, error)
+	// RepairNode replaces a failing node by a new node of the same node pool, and returns the ID of the new node
+	RepairNode(concurrency.Task, string) (string, error)
+	// RegisterFeature records a feature installed on the cluster, with its version and the values of its parameters
+	RegisterFeature(concurrency.Task, string, string, map[string]string) error
+	// UnregisterFeature records a feature removed from the cluster
+	UnregisterFeature(concurrency.Task, string) error
+	// Upgrade upgrades the OS (if true) and/or the cluster manager (to the version if not empty) of the masters,
+	// then of the nodes by batches (of the size given), resuming an interrupted upgrade
+	Upgrade(concurrency.Task, bool, string, int) (*propsv1.Upgrade, error)
+	// GetUpgrade returns the progress of the last upgrade of the cluster, or nil if never upgraded
+	GetUpgrade(concurrency.Task) (*propsv1.Upgrade, error)
+	// Backup saves the control plane of the cluster, encrypted, in the bucket (which cannot be the metadata bucket)
+	Backup(concurrency.Task, string) (*propsv1.Backup, error)
+	// ListBackups returns the backups of the control plane of the cluster, the oldest first
+	ListBackups(concurrency.Task) ([]*propsv1.Backup, error)
+	// DeleteBackup deletes a backup of the control plane of the cluster
+	DeleteBackup(concurrency.Task, string) error
+	// PruneBackups deletes the oldest backups to keep only the number of backups given, and returns the IDs of the deleted ones
+	PruneBackups(concurrency.Task, int) ([]string, error)
+	// RestoreBackup rebuilds the masters of the cluster and restores the control plane from a backup
+	RestoreBackup(concurrency.Task, string) error
+	// GetSchedules returns the periodic tasks (autoscaling, ...) recorded for the cluster
+	GetSchedules(concurrency.Task) (*propsv1.Schedules, error)
+	// UpdateSchedules applies a function to the periodic tasks recorded for the cluster and saves them
+	UpdateSchedules(concurrency.Task, func(*propsv1.Schedules)) error
+	// PinTenant binds the cluster to the tenant it has been loaded from, whatever the current tenant of safescaled
+	PinTenant(concurrency.Task, string) error
+	// Export returns the configuration to access the cluster in the format given, referencing the private keys and
+	// known hosts as files of the directory given
+	Export(concurrency.Task, exportformat.Enum, string) (*Export, error)
+
 	// Delete allows to destroy infrastructure of cluster
 	Delete(concurrency.Task) error
 }
+
+// Workload describes the activity of the nodes of a node pool, used to take scaling decisions
+type Workload struct {
+	// Pending is the number of units of work waiting for resources (pods, jobs, ...), or -1 if the flavor cannot tell
+	Pending int
+	// Loads contains the load of each node (load average on 1 minute divided by the number of cpus), indexed by host ID
+	Loads map[string]float64
+}
+
+// AverageLoad returns the average load of the nodes, or 0 if there is no node
+func (w *Workload) AverageLoad() float64 {
+	if len(w.Loads) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, v := range w.Loads {
+		sum += v
+	}
+	return sum / float64(len(w.Loads))
+}
+
+// LeastLoaded returns the ID of the least loaded node, or empty string if there is no node
+func (w *Workload) LeastLoaded() string {
+	var (
+		id  string
+		min float64
+	)
+	for k, v := range w.Loads {
+		if id == "" || v < min || (v == min && k < id) {
+			id, min = k, v
+		}
+	}
+	return id
+}
+
+// Export contains the access configuration of a cluster
+type Export struct {
+	// Content is the configuration in the format requested
+	Content string
+	// Files contains the content of the files referenced by Content (private keys, known hosts), indexed by path
+	Files map[string]string
+}
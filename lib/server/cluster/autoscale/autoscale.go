@@ -0,0 +1,122 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package autoscale
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/CS-SI/SafeScale/lib/server/cluster/api"
+	"github.com/CS-SI/SafeScale/lib/utils/scerr"
+)
+
+const (
+	// DefaultInterval is the default delay between 2 evaluations of a policy
+	DefaultInterval = time.Minute
+	// DefaultCooldown is the default minimum delay between 2 scaling actions
+	DefaultCooldown = 5 * time.Minute
+	// DefaultScaleUpLoad is the default average load above which a node is added
+	DefaultScaleUpLoad = 0.8
+	// DefaultScaleDownLoad is the default average load under which a node is removed
+	DefaultScaleDownLoad = 0.2
+)
+
+// Policy defines how a node pool of a cluster is scaled
+type Policy struct {
+	MinNodes      int           // MinNodes is the minimum number of nodes in the pool
+	MaxNodes      int           // MaxNodes is the maximum number of nodes in the pool
+	Cooldown      time.Duration // Cooldown is the minimum delay between 2 scaling actions
+	Interval      time.Duration // Interval is the delay between 2 evaluations of the policy
+	ScaleUpLoad   float64       // ScaleUpLoad is the average load (per cpu) above which a node is added
+	ScaleDownLoad float64       // ScaleDownLoad is the average load (per cpu) under which a node is removed
+}
+
+// Validate checks the consistency of the policy and sets default values of the fields not set
+func (p *Policy) Validate() error {
+	if p.MinNodes < 0 {
+		return scerr.InvalidParameterError("MinNodes", "must be an int >= 0")
+	}
+	if p.MaxNodes < p.MinNodes || p.MaxNodes == 0 {
+		return scerr.InvalidParameterError("MaxNodes", "must be an int > 0 and >= MinNodes")
+	}
+	if p.Interval <= 0 {
+		p.Interval = DefaultInterval
+	}
+	if p.Cooldown <= 0 {
+		p.Cooldown = DefaultCooldown
+	}
+	if p.ScaleUpLoad <= 0 {
+		p.ScaleUpLoad = DefaultScaleUpLoad
+	}
+	if p.ScaleDownLoad <= 0 {
+		p.ScaleDownLoad = DefaultScaleDownLoad
+	}
+	if p.ScaleDownLoad >= p.ScaleUpLoad {
+		return scerr.InvalidParameterError("ScaleDownLoad", fmt.Sprintf("must be lower than ScaleUpLoad (%.2f)", p.ScaleUpLoad))
+	}
+	return nil
+}
+
+// Action is the kind of scaling action decided
+type Action string
+
+const (
+	// None means nothing has to be done
+	None Action = "none"
+	// ScaleUp means nodes have to be added
+	ScaleUp Action = "scale-up"
+	// ScaleDown means a node has to be removed
+	ScaleDown Action = "scale-down"
+)
+
+// Decision is the result of the evaluation of a policy
+type Decision struct {
+	Action Action
+	Count  int    // Count is the number of nodes to add, when scaling up
+	NodeID string // NodeID is the ID of the node to remove, when scaling down
+	Reason string
+}
+
+// Decide evaluates the policy against the workload of the 'nodes' nodes of the pool and returns the action to take.
+// Bounds of the policy are enforced even during the cooldown following the last scaling action; when scaling down,
+// the least loaded node is chosen.
+func Decide(p Policy, nodes int, w *api.Workload, lastScaling, now time.Time) Decision {
+	if nodes < p.MinNodes {
+		return Decision{Action: ScaleUp, Count: p.MinNodes - nodes, Reason: fmt.Sprintf("%d node(s), below minimum of %d", nodes, p.MinNodes)}
+	}
+	if nodes > p.MaxNodes {
+		if id := w.LeastLoaded(); id != "" {
+			return Decision{Action: ScaleDown, NodeID: id, Reason: fmt.Sprintf("%d node(s), above maximum of %d", nodes, p.MaxNodes)}
+		}
+		return Decision{Action: None, Reason: fmt.Sprintf("%d node(s), above maximum of %d, but no node is reachable", nodes, p.MaxNodes)}
+	}
+	if now.Before(lastScaling.Add(p.Cooldown)) {
+		return Decision{Action: None, Reason: "cooling down after last scaling"}
+	}
+
+	load := w.AverageLoad()
+	if w.Pending > 0 && nodes < p.MaxNodes {
+		return Decision{Action: ScaleUp, Count: 1, Reason: fmt.Sprintf("%d pending unit(s) of work", w.Pending)}
+	}
+	if len(w.Loads) > 0 && load > p.ScaleUpLoad && nodes < p.MaxNodes {
+		return Decision{Action: ScaleUp, Count: 1, Reason: fmt.Sprintf("average load %.2f above %.2f", load, p.ScaleUpLoad)}
+	}
+	if w.Pending <= 0 && len(w.Loads) > 0 && load < p.ScaleDownLoad && nodes > p.MinNodes {
+		return Decision{Action: ScaleDown, NodeID: w.LeastLoaded(), Reason: fmt.Sprintf("average load %.2f under %.2f", load, p.ScaleDownLoad)}
+	}
+	return Decision{Action: None, Reason: fmt.Sprintf("average load %.2f within thresholds", load)}
+}
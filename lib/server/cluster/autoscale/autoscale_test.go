@@ -0,0 +1,58 @@
+package autoscale
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/CS-SI/SafeScale/lib/server/cluster/api"
+)
+
+func TestPolicy_Validate(t *testing.T) {
+	p := Policy{MinNodes: 1, MaxNodes: 3}
+	assert.Nil(t, p.Validate())
+	assert.Equal(t, DefaultInterval, p.Interval)
+	assert.Equal(t, DefaultCooldown, p.Cooldown)
+	assert.Equal(t, DefaultScaleUpLoad, p.ScaleUpLoad)
+	assert.Equal(t, DefaultScaleDownLoad, p.ScaleDownLoad)
+
+	p = Policy{MinNodes: 3, MaxNodes: 1}
+	assert.NotNil(t, p.Validate())
+
+	p = Policy{MinNodes: 1, MaxNodes: 3, ScaleUpLoad: 0.5, ScaleDownLoad: 0.6}
+	assert.NotNil(t, p.Validate())
+}
+
+func TestDecide(t *testing.T) {
+	p := Policy{MinNodes: 1, MaxNodes: 3}
+	assert.Nil(t, p.Validate())
+	now := time.Now()
+	longAgo := now.Add(-time.Hour)
+
+	tests := []struct {
+		name        string
+		nodes       int
+		workload    api.Workload
+		lastScaling time.Time
+		want        Decision
+	}{
+		{"below minimum", 0, api.Workload{Pending: -1}, now, Decision{Action: ScaleUp, Count: 1}},
+		{"above maximum", 4, api.Workload{Pending: -1, Loads: map[string]float64{"a": 0.9, "b": 0.1, "c": 0.5, "d": 0.7}}, now, Decision{Action: ScaleDown, NodeID: "b"}},
+		{"cooldown", 2, api.Workload{Pending: 5, Loads: map[string]float64{"a": 0.9, "b": 0.9}}, now.Add(-time.Minute), Decision{Action: None}},
+		{"pending work", 2, api.Workload{Pending: 5, Loads: map[string]float64{"a": 0.1, "b": 0.1}}, longAgo, Decision{Action: ScaleUp, Count: 1}},
+		{"pending work at maximum", 3, api.Workload{Pending: 5, Loads: map[string]float64{"a": 0.5, "b": 0.5, "c": 0.5}}, longAgo, Decision{Action: None}},
+		{"high load", 2, api.Workload{Pending: -1, Loads: map[string]float64{"a": 0.9, "b": 1.2}}, longAgo, Decision{Action: ScaleUp, Count: 1}},
+		{"low load", 3, api.Workload{Pending: 0, Loads: map[string]float64{"a": 0.1, "b": 0.05, "c": 0.2}}, longAgo, Decision{Action: ScaleDown, NodeID: "b"}},
+		{"low load at minimum", 1, api.Workload{Pending: 0, Loads: map[string]float64{"a": 0.0}}, longAgo, Decision{Action: None}},
+		{"nodes unreachable", 2, api.Workload{Pending: -1}, longAgo, Decision{Action: None}},
+		{"within thresholds", 2, api.Workload{Pending: 0, Loads: map[string]float64{"a": 0.5, "b": 0.4}}, longAgo, Decision{Action: None}},
+	}
+	for _, tt := range tests {
+		got := Decide(p, tt.nodes, &tt.workload, tt.lastScaling, now)
+		assert.Equal(t, tt.want.Action, got.Action, tt.name)
+		assert.Equal(t, tt.want.Count, got.Count, tt.name)
+		assert.Equal(t, tt.want.NodeID, got.NodeID, tt.name)
+		assert.NotEmpty(t, got.Reason, tt.name)
+	}
+}
@@ -0,0 +1,545 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package control
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	pb "github.com/CS-SI/SafeScale/lib"
+	clusterpropsv1 "github.com/CS-SI/SafeScale/lib/server/cluster/control/properties/v1"
+	clusterpropsv2 "github.com/CS-SI/SafeScale/lib/server/cluster/control/properties/v2"
+	"github.com/CS-SI/SafeScale/lib/server/cluster/enums/property"
+	"github.com/CS-SI/SafeScale/lib/server/iaas/objectstorage"
+	srvutils "github.com/CS-SI/SafeScale/lib/server/utils"
+	"github.com/CS-SI/SafeScale/lib/utils/concurrency"
+	"github.com/CS-SI/SafeScale/lib/utils/crypt"
+	"github.com/CS-SI/SafeScale/lib/utils/data"
+	"github.com/CS-SI/SafeScale/lib/utils/scerr"
+	"github.com/CS-SI/SafeScale/lib/utils/temporal"
+)
+
+const (
+	// backupFolderName is the folder of the bucket containing the backups, one subfolder per cluster
+	backupFolderName = "cluster-backups"
+	// backupChunkSize is the size of the parts of the backups written in Object Storage
+	backupChunkSize = 10 * 1024 * 1024
+	// backupIDFormat is the layout of the date used as ID of a backup
+	backupIDFormat = "20060102T150405Z"
+)
+
+// Backup saves the state of the control plane of the cluster from an available master, encrypts it and stores it
+// in the bucket bucketName.
+// The encryption key is generated on first backup and kept in the metadata of the cluster, so the metadata bucket
+// cannot be used to store the backups.
+func (c *Controller) Backup(task concurrency.Task, bucketName string) (backup *clusterpropsv1.Backup, err error) {
+	if c == nil {
+		return nil, scerr.InvalidInstanceError()
+	}
+	if task == nil {
+		task = concurrency.RootTask()
+	}
+
+	tracer := concurrency.NewTracer(task, fmt.Sprintf("('%s')", bucketName), true).WithStopwatch().GoingIn()
+	defer tracer.OnExitTrace()()
+	defer scerr.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	if !c.foreman.canBackupControlPlane() {
+		return nil, scerr.NotAvailableError(fmt.Sprintf("flavor '%s' of cluster '%s' does not support backup of its control plane", c.GetIdentity(task).Flavor.String(), c.Name))
+	}
+	bucket, err := c.getBackupTargetBucket(task, bucketName)
+	if err != nil {
+		return nil, err
+	}
+	key, err := c.getBackupKey(task)
+	if err != nil {
+		return nil, err
+	}
+
+	masterID, err := c.FindAvailableMaster(task)
+	if err != nil {
+		return nil, err
+	}
+	master, err := c.hostClient(task, masterID).Host.Inspect(masterID, temporal.GetExecutionTimeout())
+	if err != nil {
+		return nil, err
+	}
+
+	log.Infof("[cluster %s] saving control plane from master '%s'...", c.Name, master.Name)
+	var buffer bytes.Buffer
+	version, err := c.foreman.backupControlPlane(task, master, &buffer)
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := crypt.Encrypt(buffer.Bytes(), key)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	backup = &clusterpropsv1.Backup{
+		ID:      now.Format(backupIDFormat),
+		Bucket:  bucket.GetName(),
+		Size:    int64(len(encrypted)),
+		Master:  master.Name,
+		Version: version,
+		Created: now,
+	}
+	backup.Object = backupFolderName + "/" + c.Name + "/" + backup.ID
+	_, err = bucket.WriteMultiPartObject(backup.Object, bytes.NewReader(encrypted), backup.Size, backupChunkSize, objectstorage.ObjectMetadata{
+		"cluster": c.Name,
+		"master":  master.Name,
+		"version": version,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.UpdateMetadata(task, func() error {
+		return c.Properties.LockForWrite(property.BackupsV1).ThenUse(func(clonable data.Clonable) error {
+			backupsV1 := clonable.(*clusterpropsv1.Backups)
+			if backupsV1.Find(backup.ID) != nil {
+				return scerr.DuplicateError(fmt.Sprintf("a backup '%s' of cluster '%s' already exists", backup.ID, c.Name))
+			}
+			backupsV1.List = append(backupsV1.List, backup.Clone())
+			return nil
+		})
+	})
+	if err != nil {
+		derr := bucket.DeleteObject(backup.Object)
+		return nil, scerr.AddConsequence(err, derr)
+	}
+	log.Infof("[cluster %s] control plane saved in backup '%s'", c.Name, backup.ID)
+	return backup, nil
+}
+
+// ListBackups returns the backups of the control plane of the cluster, the oldest first
+func (c *Controller) ListBackups(task concurrency.Task) (list []*clusterpropsv1.Backup, err error) {
+	if c == nil {
+		return nil, scerr.InvalidInstanceError()
+	}
+	if task == nil {
+		task = concurrency.RootTask()
+	}
+	if !c.Properties.Lookup(property.BackupsV1) {
+		return []*clusterpropsv1.Backup{}, nil
+	}
+
+	c.RLock(task)
+	defer c.RUnlock(task)
+	err = c.Properties.LockForRead(property.BackupsV1).ThenUse(func(clonable data.Clonable) error {
+		for _, v := range clonable.(*clusterpropsv1.Backups).List {
+			list = append(list, v.Clone())
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// DeleteBackup deletes the backup identified by id
+func (c *Controller) DeleteBackup(task concurrency.Task, id string) (err error) {
+	if c == nil {
+		return scerr.InvalidInstanceError()
+	}
+	if id == "" {
+		return scerr.InvalidParameterError("id", "cannot be empty string")
+	}
+	if task == nil {
+		task = concurrency.RootTask()
+	}
+
+	tracer := concurrency.NewTracer(task, fmt.Sprintf("('%s')", id), true).GoingIn()
+	defer tracer.OnExitTrace()()
+	defer scerr.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	backup, err := c.findBackup(task, id)
+	if err != nil {
+		return err
+	}
+	bucket, err := c.getBackupBucket(task, backup.Bucket)
+	if err != nil {
+		return err
+	}
+	err = bucket.DeleteObject(backup.Object)
+	if err != nil {
+		return err
+	}
+
+	return c.UpdateMetadata(task, func() error {
+		return c.Properties.LockForWrite(property.BackupsV1).ThenUse(func(clonable data.Clonable) error {
+			clonable.(*clusterpropsv1.Backups).Remove(id)
+			return nil
+		})
+	})
+}
+
+// PruneBackups deletes the oldest backups to keep only the keep most recent ones
+// Returns the IDs of the backups deleted.
+func (c *Controller) PruneBackups(task concurrency.Task, keep int) (deleted []string, err error) {
+	if c == nil {
+		return nil, scerr.InvalidInstanceError()
+	}
+	if keep <= 0 {
+		return nil, scerr.InvalidParameterError("keep", "must be greater than 0")
+	}
+	if task == nil {
+		task = concurrency.RootTask()
+	}
+
+	list, err := c.ListBackups(task)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < len(list)-keep; i++ {
+		err = c.DeleteBackup(task, list[i].ID)
+		if err != nil {
+			return deleted, err
+		}
+		deleted = append(deleted, list[i].ID)
+	}
+	return deleted, nil
+}
+
+// RestoreBackup rebuilds the control plane of the cluster from the backup identified by id: the same number of masters
+// is created and configured as during the creation of the cluster, the state saved in the backup is restored on them
+// and the nodes are joined again. The former masters are deleted only once the restoration succeeded; if it fails,
+// the new masters are deleted and the former ones kept.
+func (c *Controller) RestoreBackup(task concurrency.Task, id string) (err error) {
+	if c == nil {
+		return scerr.InvalidInstanceError()
+	}
+	if id == "" {
+		return scerr.InvalidParameterError("id", "cannot be empty string")
+	}
+	if task == nil {
+		task = concurrency.RootTask()
+	}
+
+	tracer := concurrency.NewTracer(task, fmt.Sprintf("('%s')", id), true).WithStopwatch().GoingIn()
+	defer tracer.OnExitTrace()()
+	defer scerr.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	if !c.foreman.canRestoreControlPlane() {
+		return scerr.NotAvailableError(fmt.Sprintf("flavor '%s' of cluster '%s' does not support restoration of its control plane", c.GetIdentity(task).Flavor.String(), c.Name))
+	}
+
+	// Reads and decrypts the backup before touching anything
+	backup, err := c.findBackup(task, id)
+	if err != nil {
+		return err
+	}
+	bucket, err := c.getBackupBucket(task, backup.Bucket)
+	if err != nil {
+		return err
+	}
+	key, err := c.getBackupKey(task)
+	if err != nil {
+		return err
+	}
+	var buffer bytes.Buffer
+	_, err = bucket.ReadObject(backup.Object, &buffer, 0, 0)
+	if err != nil {
+		return err
+	}
+	archive, err := crypt.Decrypt(buffer.Bytes(), key)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt backup '%s': %v", id, err)
+	}
+	masterDef, err := c.getMasterDefinition(task)
+	if err != nil {
+		return err
+	}
+
+	// The former masters are set aside, not deleted, until the control plane is restored on the new ones
+	formerMasters, formerVIPHosts, err := c.setMastersAside(task)
+	if err != nil {
+		return err
+	}
+	restored := false
+	defer func() {
+		if err != nil && !restored {
+			derr := c.bringMastersBack(task, formerMasters, formerVIPHosts)
+			err = scerr.AddConsequence(err, derr)
+		}
+	}()
+	var formerNames []string
+	for _, m := range formerMasters {
+		formerNames = append(formerNames, m.Name)
+	}
+	count := len(formerMasters)
+	if count == 0 {
+		count, _, _ = c.foreman.determineRequiredNodes(task)
+	}
+	log.Infof("[cluster %s] creating %d masters to restore backup '%s'...", c.Name, count, id)
+	_, err = c.foreman.taskCreateMasters(task, data.Map{
+		"count":     count,
+		"masterDef": masterDef,
+		"nokeep":    true,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = c.foreman.taskConfigureMasters(task, nil)
+	if err != nil {
+		return err
+	}
+	err = c.foreman.installTimeServer(task)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("[cluster %s] restoring control plane from backup '%s'...", c.Name, id)
+	err = c.foreman.restoreControlPlane(task, bytes.NewReader(archive), backup.Version, formerNames)
+	if err != nil {
+		return err
+	}
+	restored = true
+
+	// The control plane runs on the new masters, the former ones can be deleted
+	for _, m := range formerMasters {
+		c.deleteFormerMaster(task, m)
+	}
+
+	var remoteDesktop bool
+	c.RLock(task)
+	err = c.Properties.LockForRead(property.FeaturesV1).ThenUse(func(clonable data.Clonable) error {
+		_, disabled := clonable.(*clusterpropsv1.Features).Disabled["remotedesktop"]
+		remoteDesktop = !disabled
+		return nil
+	})
+	c.RUnlock(task)
+	if err != nil {
+		return err
+	}
+	if remoteDesktop {
+		err = c.foreman.installRemoteDesktop(task)
+		if err != nil {
+			return err
+		}
+	}
+	err = c.reinstallFeatures(task)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.ForceGetState(task)
+	if err != nil {
+		log.Warnf("[cluster %s] failed to get state after restoration: %v", c.Name, err)
+	}
+	log.Infof("[cluster %s] control plane restored from backup '%s'", c.Name, id)
+	return nil
+}
+
+// setMastersAside removes the masters from the metadata of the cluster without deleting them, and returns them
+// with the hosts bound to the VIP of the control plane (if any)
+func (c *Controller) setMastersAside(task concurrency.Task) (masters []*clusterpropsv1.Node, vipHosts []string, err error) {
+	err = c.UpdateMetadata(task, func() error {
+		innerErr := c.Properties.LockForWrite(property.NodesV1).ThenUse(func(clonable data.Clonable) error {
+			nodesV1 := clonable.(*clusterpropsv1.Nodes)
+			masters = nodesV1.Masters
+			nodesV1.Masters = []*clusterpropsv1.Node{}
+			return nil
+		})
+		if innerErr != nil {
+			return innerErr
+		}
+		return c.Properties.LockForRead(property.ControlPlaneV1).ThenUse(func(clonable data.Clonable) error {
+			if vip := clonable.(*clusterpropsv1.ControlPlane).VirtualIP; vip != nil {
+				vipHosts = append([]string{}, vip.Hosts...)
+			}
+			return nil
+		})
+	})
+	return masters, vipHosts, err
+}
+
+// bringMastersBack deletes the masters created during a failed restoration and puts back the former masters in the
+// metadata of the cluster
+func (c *Controller) bringMastersBack(task concurrency.Task, formerMasters []*clusterpropsv1.Node, formerVIPHosts []string) error {
+	log.Warnf("[cluster %s] restoration failed, deleting the new masters and keeping the former ones", c.Name)
+	var errs []error
+	for _, id := range c.ListMasterIDs(task) {
+		err := c.deleteMaster(task, id)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	err := c.UpdateMetadata(task, func() error {
+		innerErr := c.Properties.LockForWrite(property.NodesV1).ThenUse(func(clonable data.Clonable) error {
+			nodesV1 := clonable.(*clusterpropsv1.Nodes)
+			nodesV1.Masters = append(nodesV1.Masters, formerMasters...)
+			return nil
+		})
+		if innerErr != nil {
+			return innerErr
+		}
+		return c.Properties.LockForWrite(property.ControlPlaneV1).ThenUse(func(clonable data.Clonable) error {
+			if vip := clonable.(*clusterpropsv1.ControlPlane).VirtualIP; vip != nil {
+				vip.Hosts = formerVIPHosts
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		return scerr.ErrListError(errs)
+	}
+	return nil
+}
+
+// deleteFormerMaster deletes the host of a master set aside during a restoration, in the tenant of the cluster;
+// failures are only logged, the restoration being done
+func (c *Controller) deleteFormerMaster(task concurrency.Task, master *clusterpropsv1.Node) {
+	clientHost := c.hostClient(task, master.ID).Host
+	err := clientHost.Delete([]string{master.ID}, temporal.GetLongOperationTimeout())
+	if err == nil {
+		return
+	}
+	if _, ierr := clientHost.Inspect(master.ID, temporal.GetExecutionTimeout()); ierr != nil {
+		log.Warnf("[cluster %s] former master '%s' not found, forgetting it", c.Name, master.Name)
+		return
+	}
+	log.Warnf("[cluster %s] failed to delete former master '%s', delete it manually: %v", c.Name, master.Name, err)
+}
+
+// getMasterDefinition returns the definition of the masters recorded at the creation of the cluster
+func (c *Controller) getMasterDefinition(task concurrency.Task) (*pb.HostDefinition, error) {
+	// Converts DefaultsV1 to DefaultsV2 if needed
+	_, _, err := c.getImageAndNodeDescriptionUsedInClusterFromMetadata(&task)
+	if err != nil {
+		return nil, err
+	}
+
+	def := &pb.HostDefinition{}
+	c.RLock(task)
+	defer c.RUnlock(task)
+	err = c.Properties.LockForRead(property.DefaultsV2).ThenUse(func(clonable data.Clonable) error {
+		defaultsV2 := clonable.(*clusterpropsv2.Defaults)
+		sizing := srvutils.ToPBHostSizing(defaultsV2.MasterSizing)
+		def.Sizing = &sizing
+		def.ImageId = defaultsV2.Image
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return def, nil
+}
+
+// findBackup returns the backup identified by id
+func (c *Controller) findBackup(task concurrency.Task, id string) (*clusterpropsv1.Backup, error) {
+	list, err := c.ListBackups(task)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range list {
+		if v.ID == id {
+			return v, nil
+		}
+	}
+	return nil, scerr.NotFoundError(fmt.Sprintf("failed to find backup '%s' of cluster '%s'", id, c.Name))
+}
+
+// getBackupBucket returns the bucket named bucketName containing a backup, or the metadata bucket if bucketName is
+// empty
+func (c *Controller) getBackupBucket(task concurrency.Task, bucketName string) (objectstorage.Bucket, error) {
+	svc := c.GetService(task)
+	metadataBucket := svc.GetMetadataBucket()
+	if bucketName == "" || bucketName == metadataBucket.GetName() {
+		return metadataBucket, nil
+	}
+	return svc.GetBucket(bucketName)
+}
+
+// getBackupTargetBucket returns the bucket named bucketName to store a new backup in; the metadata bucket is refused,
+// as it contains the encryption key of the backups
+func (c *Controller) getBackupTargetBucket(task concurrency.Task, bucketName string) (objectstorage.Bucket, error) {
+	if bucketName == "" {
+		return nil, scerr.InvalidParameterError("bucketName", "cannot be empty string")
+	}
+	svc := c.GetService(task)
+	if bucketName == svc.GetMetadataBucket().GetName() {
+		return nil, scerr.InvalidRequestError(fmt.Sprintf("cannot store backups of cluster '%s' in the metadata bucket '%s', which contains their encryption key", c.Name, bucketName))
+	}
+	return svc.GetBucket(bucketName)
+}
+
+// getBackupKey returns the encryption key of the backups, generating it if needed
+func (c *Controller) getBackupKey(task concurrency.Task) (*crypt.Key, error) {
+	var encoded string
+	err := c.UpdateMetadata(task, func() error {
+		return c.Properties.LockForWrite(property.BackupsV1).ThenUse(func(clonable data.Clonable) error {
+			backupsV1 := clonable.(*clusterpropsv1.Backups)
+			if backupsV1.Key == "" {
+				key, err := crypt.NewEncryptionKey(nil)
+				if err != nil {
+					return err
+				}
+				backupsV1.Key = base64.StdEncoding.EncodeToString(key[:])
+			}
+			encoded = backupsV1.Key
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, scerr.InconsistentError(fmt.Sprintf("invalid encryption key of backups of cluster '%s': %v", c.Name, err))
+	}
+	if len(raw) != len(crypt.Key{}) {
+		return nil, scerr.InconsistentError(fmt.Sprintf("invalid encryption key of backups of cluster '%s': wrong size", c.Name))
+	}
+	return crypt.NewEncryptionKey(raw)
+}
+
+// canBackupControlPlane tells if the flavor knows how to save its control plane
+func (b *foreman) canBackupControlPlane() bool {
+	return b.makers.BackupControlPlane != nil
+}
+
+// backupControlPlane writes to w the archive of the control plane saved from the master
+func (b *foreman) backupControlPlane(task concurrency.Task, pbHost *pb.Host, w io.Writer) (string, error) {
+	if b.makers.BackupControlPlane != nil {
+		return b.makers.BackupControlPlane(task, b, pbHost, w)
+	}
+	return "", fmt.Errorf("no maker defined for 'BackupControlPlane'")
+}
+
+// canRestoreControlPlane tells if the flavor knows how to restore its control plane
+func (b *foreman) canRestoreControlPlane() bool {
+	return b.makers.RestoreControlPlane != nil
+}
+
+// restoreControlPlane restores the control plane on the masters from the archive
+func (b *foreman) restoreControlPlane(task concurrency.Task, r io.Reader, version string, formerMasters []string) error {
+	if b.makers.RestoreControlPlane != nil {
+		return b.makers.RestoreControlPlane(task, b, r, version, formerMasters)
+	}
+	return fmt.Errorf("no maker defined for 'RestoreControlPlane'")
+}
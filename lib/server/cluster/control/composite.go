@@ -0,0 +1,679 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package control
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+
+	pb "github.com/CS-SI/SafeScale/lib"
+	"github.com/CS-SI/SafeScale/lib/client"
+	clusterpropsv1 "github.com/CS-SI/SafeScale/lib/server/cluster/control/properties/v1"
+	clusterpropsv2 "github.com/CS-SI/SafeScale/lib/server/cluster/control/properties/v2"
+	"github.com/CS-SI/SafeScale/lib/server/cluster/enums/property"
+	"github.com/CS-SI/SafeScale/lib/server/iaas"
+	srvutils "github.com/CS-SI/SafeScale/lib/server/utils"
+	"github.com/CS-SI/SafeScale/lib/utils/cli/enums/outputs"
+	"github.com/CS-SI/SafeScale/lib/utils/concurrency"
+	"github.com/CS-SI/SafeScale/lib/utils/data"
+	"github.com/CS-SI/SafeScale/lib/utils/scerr"
+	"github.com/CS-SI/SafeScale/lib/utils/temporal"
+)
+
+const (
+	// siteTunnelInterface is the name of the WireGuard interface connecting the sites of a multi-tenant cluster
+	siteTunnelInterface = "wg-safescale"
+	// siteTunnelPort is the UDP port used by the site-to-site tunnels
+	siteTunnelPort = 51820
+	// siteTunnelKeyFile is the private key of the tunnel end on a gateway
+	siteTunnelKeyFile = "/etc/wireguard/safescale.key"
+)
+
+var (
+	// tenantServices contains the Services of the tenants hosting clusters managed whatever the current tenant
+	// (sites of multi-tenant clusters, clusters of the tasks run periodically by safescaled), indexed by tenant name
+	tenantServices     = map[string]iaas.Service{}
+	tenantServicesLock sync.Mutex
+)
+
+// GetTenantService returns the Service of the tenant 'tenant', created once and reused to avoid authenticating
+// again with the provider each time
+func GetTenantService(tenant string) (iaas.Service, error) {
+	tenantServicesLock.Lock()
+	defer tenantServicesLock.Unlock()
+
+	if svc, ok := tenantServices[tenant]; ok {
+		return svc, nil
+	}
+	svc, err := iaas.UseService(tenant)
+	if err != nil {
+		return nil, err
+	}
+	tenantServices[tenant] = svc
+	return svc, nil
+}
+
+// getComposite returns a copy of the Composite property of the cluster, or nil if not set
+func (c *Controller) getComposite(task concurrency.Task) *clusterpropsv1.Composite {
+	c.RLock(task)
+	defer c.RUnlock(task)
+
+	if !c.Properties.Lookup(property.CompositeV1) {
+		return nil
+	}
+	var composite *clusterpropsv1.Composite
+	err := c.Properties.LockForRead(property.CompositeV1).ThenUse(func(clonable data.Clonable) error {
+		composite = clonable.Clone().(*clusterpropsv1.Composite)
+		return nil
+	})
+	if err != nil {
+		log.Errorf("failed to read composite information of cluster '%s': %v", c.Name, err)
+		return nil
+	}
+	return composite
+}
+
+// getMainTenant returns the name of the tenant hosting the network, the gateways and the masters of the cluster
+func (c *Controller) getMainTenant(task concurrency.Task) string {
+	if composite := c.getComposite(task); composite != nil && len(composite.Tenants) > 0 {
+		return composite.Tenants[0]
+	}
+	return ""
+}
+
+// getHostTenant returns the tenant hosting the host identified by hostID, or an empty string if it's the main tenant
+func (c *Controller) getHostTenant(task concurrency.Task, hostID string) string {
+	composite := c.getComposite(task)
+	if composite == nil || len(composite.Sites) == 0 {
+		return ""
+	}
+	for _, site := range composite.Sites {
+		if site.GatewayID == hostID {
+			return site.Tenant
+		}
+	}
+
+	c.RLock(task)
+	defer c.RUnlock(task)
+
+	if len(composite.Tenants) == 0 || !c.Properties.Lookup(property.NodePoolsV1) {
+		return ""
+	}
+	var tenant string
+	_ = c.Properties.LockForRead(property.NodePoolsV1).ThenUse(func(clonable data.Clonable) error {
+		if pool := clonable.(*clusterpropsv1.NodePools).FindNode(hostID); pool != nil {
+			tenant = pool.Tenant
+		}
+		return nil
+	})
+	if tenant == composite.Tenants[0] {
+		return ""
+	}
+	return tenant
+}
+
+// getHostSite returns the site hosting the node identified by hostID, or nil if the node is hosted by the main tenant
+func (c *Controller) getHostSite(task concurrency.Task, hostID string) *clusterpropsv1.Site {
+	tenant := c.getHostTenant(task, hostID)
+	if tenant == "" {
+		return nil
+	}
+	if composite := c.getComposite(task); composite != nil {
+		return composite.Sites[tenant]
+	}
+	return nil
+}
+
+// getHostService returns the Service of the tenant hosting the host identified by hostID
+func (c *Controller) getHostService(task concurrency.Task, hostID string) (iaas.Service, error) {
+	tenant := c.getHostTenant(task, hostID)
+	if tenant == "" {
+		return c.service, nil
+	}
+	return GetTenantService(tenant)
+}
+
+// registerSiteHosts records, for the safescale client, the tenant of the nodes and gateways hosted by the sites
+// of the cluster, so that the requests on these hosts reach the right tenant
+func (c *Controller) registerSiteHosts(task concurrency.Task) {
+	composite := c.getComposite(task)
+	if composite == nil || len(composite.Sites) == 0 {
+		return
+	}
+	for _, site := range composite.Sites {
+		client.SetHostTenant(site.GatewayID, site.Tenant)
+	}
+
+	c.RLock(task)
+	defer c.RUnlock(task)
+
+	if len(composite.Tenants) == 0 || !c.Properties.Lookup(property.NodePoolsV1) {
+		return
+	}
+	names := map[string]string{}
+	_ = c.Properties.LockForRead(property.NodesV1).ThenUse(func(clonable data.Clonable) error {
+		for _, node := range clonable.(*clusterpropsv1.Nodes).PrivateNodes {
+			names[node.ID] = node.Name
+		}
+		return nil
+	})
+	_ = c.Properties.LockForRead(property.NodePoolsV1).ThenUse(func(clonable data.Clonable) error {
+		for _, pool := range clonable.(*clusterpropsv1.NodePools).ByName {
+			if pool.Tenant == "" || pool.Tenant == composite.Tenants[0] {
+				continue
+			}
+			for _, id := range pool.Nodes {
+				client.SetHostTenant(id, pool.Tenant)
+				if name, ok := names[id]; ok {
+					client.SetHostTenant(name, pool.Tenant)
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// PinTenant binds the controller to tenant, the tenant of its Service, whatever the current tenant of safescaled:
+// the hosts of the cluster not hosted by a site (gateways, masters and nodes) are recorded for the safescale client
+// as living in tenant, and the nodes added in the main tenant are created in tenant.
+// Used by the tasks run periodically by safescaled, which load the clusters of any tenant.
+func (c *Controller) PinTenant(task concurrency.Task, tenant string) error {
+	if c == nil {
+		return scerr.InvalidInstanceError()
+	}
+	if tenant == "" {
+		return scerr.InvalidParameterError("tenant", "cannot be empty string")
+	}
+	if task == nil {
+		task = concurrency.RootTask()
+	}
+
+	c.Lock(task)
+	c.pinnedTenant = tenant
+	c.localTenant = tenant
+	c.Unlock(task)
+
+	netCfg, err := c.GetNetworkConfig(task)
+	if err != nil {
+		return err
+	}
+	// names of the hosts, indexed by ID (the names of the gateways aren't recorded in the metadata of the cluster)
+	hosts := map[string]string{}
+	for _, id := range []string{netCfg.GatewayID, netCfg.SecondaryGatewayID} {
+		if id != "" {
+			hosts[id] = ""
+		}
+	}
+	c.RLock(task)
+	err = c.Properties.LockForRead(property.NodesV1).ThenUse(func(clonable data.Clonable) error {
+		nodesV1 := clonable.(*clusterpropsv1.Nodes)
+		for _, list := range [][]*clusterpropsv1.Node{nodesV1.Masters, nodesV1.PrivateNodes} {
+			for _, node := range list {
+				hosts[node.ID] = node.Name
+			}
+		}
+		return nil
+	})
+	c.RUnlock(task)
+	if err != nil {
+		return err
+	}
+
+	for id, name := range hosts {
+		// The hosts of the sites are already recorded in their own tenant
+		if c.getHostTenant(task, id) != "" {
+			continue
+		}
+		client.SetHostTenant(id, tenant)
+		if name != "" {
+			client.SetHostTenant(name, tenant)
+		}
+	}
+	return nil
+}
+
+// getPinnedTenant returns the tenant the controller is bound to by PinTenant, or an empty string if it follows
+// the current tenant of safescaled
+func (c *Controller) getPinnedTenant(task concurrency.Task) string {
+	c.RLock(task)
+	defer c.RUnlock(task)
+	return c.pinnedTenant
+}
+
+// hostClient returns a safescale client reaching the host identified by hostID in its tenant: the tenant of its
+// site, or else the tenant the controller is pinned to (the current tenant of safescaled if not pinned)
+func (c *Controller) hostClient(task concurrency.Task, hostID string) client.Client {
+	tenant := c.getHostTenant(task, hostID)
+	if tenant == "" {
+		tenant = c.getPinnedTenant(task)
+	}
+	return client.NewOnTenant(tenant)
+}
+
+// getLocalTenant returns the name of the tenant of the Service used by the controller
+func (c *Controller) getLocalTenant(task concurrency.Task) (string, error) {
+	c.Lock(task)
+	defer c.Unlock(task)
+
+	if c.localTenant == "" {
+		tenant, err := client.New().Tenant.Get(temporal.GetExecutionTimeout())
+		if err != nil {
+			return "", err
+		}
+		c.localTenant = tenant.Name
+	}
+	return c.localTenant, nil
+}
+
+// replicateMetadata writes the metadata of the cluster in the Object Storage of each tenant of the cluster
+// other than the one of the controller, allowing to manage the cluster from any of them
+// Replication is best-effort: failures are logged, the metadata of the controller being the reference.
+func (c *Controller) replicateMetadata(task concurrency.Task) {
+	composite := c.getComposite(task)
+	if composite == nil || len(composite.Tenants) < 2 {
+		return
+	}
+	local, err := c.getLocalTenant(task)
+	if err != nil {
+		log.Warnf("failed to replicate metadata of cluster '%s': %v", c.Name, err)
+		return
+	}
+	for _, tenant := range composite.Tenants {
+		if tenant == local {
+			continue
+		}
+		svc, err := GetTenantService(tenant)
+		if err == nil {
+			var m *Metadata
+			m, err = NewMetadata(svc)
+			if err == nil {
+				err = m.Carry(task, c).Write()
+			}
+		}
+		if err != nil {
+			log.Warnf("failed to replicate metadata of cluster '%s' in tenant '%s': %v", c.Name, tenant, err)
+		}
+	}
+}
+
+// deleteMetadataReplica removes the replica of the metadata of the cluster from the Object Storage of tenant
+func (c *Controller) deleteMetadataReplica(task concurrency.Task, tenant string) error {
+	svc, err := GetTenantService(tenant)
+	if err != nil {
+		return err
+	}
+	m, err := NewMetadata(svc)
+	if err != nil {
+		return err
+	}
+	err = m.Carry(task, c).Delete()
+	if _, ok := err.(scerr.ErrNotFound); ok {
+		return nil
+	}
+	return err
+}
+
+// ensureSite returns the site of the cluster hosted by tenant, creating it if needed with the network cidr,
+// then connected to the network of the cluster with a site-to-site tunnel
+// Returns nil if tenant is the main tenant of the cluster.
+func (c *Controller) ensureSite(task concurrency.Task, tenant, cidr string) (site *clusterpropsv1.Site, err error) {
+	tracer := concurrency.NewTracer(task, fmt.Sprintf("('%s', '%s')", tenant, cidr), true).GoingIn()
+	defer tracer.OnExitTrace()()
+	defer scerr.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	mainTenant := c.getMainTenant(task)
+	if mainTenant == "" {
+		mainTenant, err = c.getLocalTenant(task)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if tenant == "" || tenant == mainTenant {
+		return nil, nil
+	}
+	if composite := c.getComposite(task); composite != nil {
+		if existing, ok := composite.Sites[tenant]; ok {
+			if cidr != "" && cidr != existing.CIDR {
+				return nil, scerr.InvalidParameterError("cidr", fmt.Sprintf("the site of tenant '%s' already uses CIDR '%s'", tenant, existing.CIDR))
+			}
+			return existing, nil
+		}
+	}
+	if cidr == "" {
+		return nil, scerr.InvalidParameterError("cidr", fmt.Sprintf("cannot be empty string: no site in tenant '%s' yet", tenant))
+	}
+	err = c.checkSiteCIDR(task, cidr)
+	if err != nil {
+		return nil, err
+	}
+
+	// Creates the network of the site, with a single gateway sized like the ones of the cluster
+	var gwSizing pb.HostSizing
+	c.RLock(task)
+	err = c.Properties.LockForRead(property.DefaultsV2).ThenUse(func(clonable data.Clonable) error {
+		gwSizing = srvutils.ToPBHostSizing(clonable.(*clusterpropsv2.Defaults).GatewaySizing)
+		return nil
+	})
+	c.RUnlock(task)
+	if err != nil {
+		return nil, err
+	}
+	hostImage, _, err := c.getImageAndNodeDescriptionUsedInClusterFromMetadata(&task)
+	if err != nil {
+		return nil, err
+	}
+	networkName := fmt.Sprintf("net-%s-%s", c.Name, strings.ToLower(tenant))
+	siteClient := client.NewOnTenant(tenant)
+	network, err := siteClient.Network.Create(pb.NetworkDefinition{
+		Name:    networkName,
+		Cidr:    cidr,
+		Gateway: &pb.GatewayDefinition{ImageId: hostImage, Sizing: &gwSizing},
+	}, temporal.GetExecutionTimeout())
+	if err != nil {
+		return nil, err
+	}
+	registered := false
+	defer func() {
+		if err != nil && !registered {
+			derr := siteClient.Network.Delete([]string{network.Id}, temporal.GetExecutionTimeout())
+			err = scerr.AddConsequence(err, derr)
+		}
+	}()
+
+	gateway, err := siteClient.Host.Inspect(network.GatewayId, temporal.GetExecutionTimeout())
+	if err != nil {
+		return nil, err
+	}
+	client.SetHostTenant(gateway.Id, tenant)
+	err = siteClient.SSH.WaitReady(gateway.Id, temporal.GetExecutionTimeout())
+	if err != nil {
+		return nil, client.DecorateError(err, "wait for remote ssh service to be ready", false)
+	}
+
+	site = &clusterpropsv1.Site{
+		Tenant:    tenant,
+		NetworkID: network.Id,
+		CIDR:      cidr,
+		GatewayID: gateway.Id,
+		GatewayIP: gateway.PrivateIp,
+		PublicIP:  gateway.PublicIp,
+	}
+	site.TunnelKey, err = c.prepareTunnelEnd(task, gateway.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.UpdateMetadata(task, func() error {
+		return c.Properties.LockForWrite(property.CompositeV1).ThenUse(func(clonable data.Clonable) error {
+			composite := clonable.(*clusterpropsv1.Composite)
+			if len(composite.Tenants) == 0 {
+				composite.Tenants = []string{mainTenant}
+			}
+			if composite.Sites == nil {
+				composite.Sites = map[string]*clusterpropsv1.Site{}
+			}
+			composite.Tenants = append(composite.Tenants, tenant)
+			composite.Sites[tenant] = site
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	registered = true
+
+	// Once registered, the site is removed as a whole on failure
+	err = c.connectSites(task)
+	if err != nil {
+		derr := c.removeSite(task, tenant)
+		return nil, scerr.AddConsequence(err, derr)
+	}
+	return site, nil
+}
+
+// checkSiteCIDR verifies that cidr doesn't overlap the network of the cluster nor the networks of its sites
+func (c *Controller) checkSiteCIDR(task concurrency.Task, cidr string) error {
+	_, candidate, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return scerr.InvalidParameterError("cidr", fmt.Sprintf("'%s' is not a valid CIDR", cidr))
+	}
+	netCfg, err := c.GetNetworkConfig(task)
+	if err != nil {
+		return err
+	}
+	used := []string{netCfg.CIDR}
+	if composite := c.getComposite(task); composite != nil {
+		for _, site := range composite.Sites {
+			used = append(used, site.CIDR)
+		}
+	}
+	for _, u := range used {
+		_, other, err := net.ParseCIDR(u)
+		if err != nil {
+			continue
+		}
+		if other.Contains(candidate.IP) || candidate.Contains(other.IP) {
+			return scerr.InvalidParameterError("cidr", fmt.Sprintf("'%s' overlaps network '%s' of the cluster", cidr, u))
+		}
+	}
+	return nil
+}
+
+// removeSite deletes the network of the site hosted by tenant and the replica of the metadata in this tenant,
+// then reconnects the remaining sites
+func (c *Controller) removeSite(task concurrency.Task, tenant string) (err error) {
+	tracer := concurrency.NewTracer(task, fmt.Sprintf("('%s')", tenant), true).GoingIn()
+	defer tracer.OnExitTrace()()
+	defer scerr.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	composite := c.getComposite(task)
+	if composite == nil {
+		return nil
+	}
+	site, ok := composite.Sites[tenant]
+	if !ok {
+		return nil
+	}
+
+	err = client.NewOnTenant(tenant).Network.Delete([]string{site.NetworkID}, temporal.GetExecutionTimeout())
+	if err != nil {
+		return err
+	}
+	client.SetHostTenant(site.GatewayID, "")
+	err = c.deleteMetadataReplica(task, tenant)
+	if err != nil {
+		log.Warnf("failed to delete metadata replica of cluster '%s' in tenant '%s': %v", c.Name, tenant, err)
+	}
+
+	err = c.UpdateMetadata(task, func() error {
+		return c.Properties.LockForWrite(property.CompositeV1).ThenUse(func(clonable data.Clonable) error {
+			composite := clonable.(*clusterpropsv1.Composite)
+			delete(composite.Sites, tenant)
+			composite.Tenants = removeString(composite.Tenants, tenant)
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+	return c.connectSites(task)
+}
+
+// removeUnusedSite removes the site hosted by tenant if no node pool uses it anymore
+func (c *Controller) removeUnusedSite(task concurrency.Task, tenant string) error {
+	if tenant == "" {
+		return nil
+	}
+	pools, err := c.ListNodePools(task)
+	if err != nil {
+		return err
+	}
+	for _, pool := range pools {
+		if pool.Tenant == tenant {
+			return nil
+		}
+	}
+	return c.removeSite(task, tenant)
+}
+
+// deleteSiteNetworks deletes the networks of all the sites of the cluster (used when the cluster is destroyed)
+func (c *Controller) deleteSiteNetworks(task concurrency.Task) []error {
+	composite := c.getComposite(task)
+	if composite == nil {
+		return nil
+	}
+	var errs []error
+	for tenant, site := range composite.Sites {
+		err := client.NewOnTenant(tenant).Network.Delete([]string{site.NetworkID}, temporal.GetExecutionTimeout())
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// prepareTunnelEnd installs WireGuard on the gateway hostID if needed, generates its key if not done yet
+// and returns its public key
+func (c *Controller) prepareTunnelEnd(task concurrency.Task, hostID string) (string, error) {
+	cmd := "command -v wg >/dev/null || (sudo apt-get update -qq && sudo DEBIAN_FRONTEND=noninteractive apt-get install -qqy wireguard) >/dev/null 2>&1; " +
+		fmt.Sprintf("sudo mkdir -p /etc/wireguard && (sudo test -f %[1]s || (wg genkey | sudo tee %[1]s >/dev/null && sudo chmod 600 %[1]s)) && sudo cat %[1]s | wg pubkey", siteTunnelKeyFile)
+	retcode, stdout, stderr, err := client.New().SSH.Run(hostID, cmd, outputs.COLLECT, client.DefaultConnectionTimeout, temporal.GetLongOperationTimeout())
+	if err != nil {
+		return "", err
+	}
+	if retcode != 0 {
+		return "", fmt.Errorf("failed to prepare tunnel end on host '%s': retcode=%d, %s", hostID, retcode, stderr)
+	}
+	return strings.TrimSpace(stdout), nil
+}
+
+// tunnelPeer describes the remote end of a site-to-site tunnel
+type tunnelPeer struct {
+	key        string
+	endpoint   string
+	allowedIPs []string
+}
+
+// buildTunnelConfig returns the WireGuard configuration of a tunnel end connected to peers
+func buildTunnelConfig(peers []tunnelPeer) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[Interface]\nListenPort = %d\nPostUp = wg set %%i private-key %s\n", siteTunnelPort, siteTunnelKeyFile)
+	for _, p := range peers {
+		fmt.Fprintf(&b, "\n[Peer]\nPublicKey = %s\nEndpoint = %s:%d\nAllowedIPs = %s\nPersistentKeepalive = 25\n",
+			p.key, p.endpoint, siteTunnelPort, strings.Join(p.allowedIPs, ", "))
+	}
+	return b.String()
+}
+
+// applyTunnelConfig writes the WireGuard configuration on the gateway hostID and (re)starts the tunnel,
+// or stops it if there is no peer anymore
+func (c *Controller) applyTunnelConfig(task concurrency.Task, hostID string, peers []tunnelPeer) error {
+	var cmd string
+	if len(peers) == 0 {
+		cmd = fmt.Sprintf("sudo systemctl disable --now wg-quick@%s >/dev/null 2>&1; sudo rm -f /etc/wireguard/%s.conf", siteTunnelInterface, siteTunnelInterface)
+	} else {
+		content := base64.StdEncoding.EncodeToString([]byte(buildTunnelConfig(peers)))
+		cmd = fmt.Sprintf("echo %s | base64 -d | sudo tee /etc/wireguard/%[2]s.conf >/dev/null && sudo chmod 600 /etc/wireguard/%[2]s.conf && ", content, siteTunnelInterface) +
+			"sudo sysctl -qw net.ipv4.ip_forward=1 && " +
+			fmt.Sprintf("(command -v firewall-cmd >/dev/null && sudo firewall-cmd --quiet --permanent --add-port=%d/udp && sudo firewall-cmd --quiet --permanent --zone=trusted --add-interface=%s && sudo firewall-cmd --quiet --reload || true) && ", siteTunnelPort, siteTunnelInterface) +
+			fmt.Sprintf("sudo systemctl enable wg-quick@%[1]s >/dev/null 2>&1 && sudo systemctl restart wg-quick@%[1]s", siteTunnelInterface)
+	}
+	retcode, _, stderr, err := client.New().SSH.Run(hostID, cmd, outputs.COLLECT, client.DefaultConnectionTimeout, temporal.GetLongOperationTimeout())
+	if err != nil {
+		return err
+	}
+	if retcode != 0 {
+		return fmt.Errorf("failed to configure tunnel on host '%s': retcode=%d, %s", hostID, retcode, stderr)
+	}
+	return nil
+}
+
+// connectSites (re)configures the site-to-site tunnels of the cluster: the primary gateway of the main tenant
+// is the hub, connected to the gateway of each site; the traffic between sites goes through the hub
+func (c *Controller) connectSites(task concurrency.Task) (err error) {
+	tracer := concurrency.NewTracer(task, "", true).GoingIn()
+	defer tracer.OnExitTrace()()
+	defer scerr.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	composite := c.getComposite(task)
+	if composite == nil {
+		return nil
+	}
+	netCfg, err := c.GetNetworkConfig(task)
+	if err != nil {
+		return err
+	}
+
+	if len(composite.Sites) > 0 && composite.HubKey == "" {
+		hubKey, err := c.prepareTunnelEnd(task, netCfg.GatewayID)
+		if err != nil {
+			return err
+		}
+		err = c.UpdateMetadata(task, func() error {
+			return c.Properties.LockForWrite(property.CompositeV1).ThenUse(func(clonable data.Clonable) error {
+				clonable.(*clusterpropsv1.Composite).HubKey = hubKey
+				return nil
+			})
+		})
+		if err != nil {
+			return err
+		}
+		composite.HubKey = hubKey
+	}
+
+	tenants := make([]string, 0, len(composite.Sites))
+	for tenant := range composite.Sites {
+		tenants = append(tenants, tenant)
+	}
+	sort.Strings(tenants)
+
+	var hubPeers []tunnelPeer
+	for _, tenant := range tenants {
+		site := composite.Sites[tenant]
+		hubPeers = append(hubPeers, tunnelPeer{key: site.TunnelKey, endpoint: site.PublicIP, allowedIPs: []string{site.CIDR}})
+	}
+	hubEndpoint := netCfg.PrimaryPublicIP
+	if hubEndpoint == "" {
+		hubEndpoint = netCfg.EndpointIP
+	}
+	err = c.applyTunnelConfig(task, netCfg.GatewayID, hubPeers)
+	if err != nil {
+		return err
+	}
+
+	for _, tenant := range tenants {
+		site := composite.Sites[tenant]
+		// Everything not local to the site goes through the hub
+		allowed := []string{netCfg.CIDR}
+		for _, other := range tenants {
+			if other != tenant {
+				allowed = append(allowed, composite.Sites[other].CIDR)
+			}
+		}
+		err = c.applyTunnelConfig(task, site.GatewayID, []tunnelPeer{{key: composite.HubKey, endpoint: hubEndpoint, allowedIPs: allowed}})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
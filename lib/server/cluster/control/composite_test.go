@@ -0,0 +1,104 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package control
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/CS-SI/SafeScale/lib/client"
+	clusterpropsv1 "github.com/CS-SI/SafeScale/lib/server/cluster/control/properties/v1"
+	clusterpropsv2 "github.com/CS-SI/SafeScale/lib/server/cluster/control/properties/v2"
+	"github.com/CS-SI/SafeScale/lib/server/cluster/enums/property"
+	"github.com/CS-SI/SafeScale/lib/utils/concurrency"
+	"github.com/CS-SI/SafeScale/lib/utils/data"
+	"github.com/CS-SI/SafeScale/lib/utils/serialize"
+)
+
+// newTestController returns a controller of a cluster of the tenants 'tenant-a' (main) and 'tenant-b', without service
+func newTestController(t *testing.T) *Controller {
+	c := &Controller{
+		Properties: serialize.NewJSONProperties("clusters"),
+		TaskedLock: concurrency.NewTaskedLock(),
+	}
+	c.Name = "test"
+	props := c.Properties
+	require.NoError(t, props.LockForWrite(property.NetworkV2).ThenUse(func(clonable data.Clonable) error {
+		clonable.(*clusterpropsv2.Network).GatewayID = "gw-a"
+		return nil
+	}))
+	require.NoError(t, props.LockForWrite(property.NodesV1).ThenUse(func(clonable data.Clonable) error {
+		nodesV1 := clonable.(*clusterpropsv1.Nodes)
+		nodesV1.Masters = []*clusterpropsv1.Node{{ID: "master-id-1", Name: "test-master-1"}}
+		nodesV1.PrivateNodes = []*clusterpropsv1.Node{
+			{ID: "node-id-1", Name: "test-node-1"},
+			{ID: "node-id-2", Name: "test-node-2"},
+		}
+		return nil
+	}))
+	require.NoError(t, props.LockForWrite(property.NodePoolsV1).ThenUse(func(clonable data.Clonable) error {
+		pools := clonable.(*clusterpropsv1.NodePools)
+		pools.ByName[DefaultNodePoolName] = &clusterpropsv1.NodePool{Name: DefaultNodePoolName, Count: 1, Nodes: []string{"node-id-1"}}
+		pools.ByName["remote"] = &clusterpropsv1.NodePool{Name: "remote", Count: 1, Nodes: []string{"node-id-2"}, Tenant: "tenant-b"}
+		return nil
+	}))
+	require.NoError(t, props.LockForWrite(property.CompositeV1).ThenUse(func(clonable data.Clonable) error {
+		composite := clonable.(*clusterpropsv1.Composite)
+		composite.Tenants = []string{"tenant-a", "tenant-b"}
+		composite.Sites = map[string]*clusterpropsv1.Site{"tenant-b": {Tenant: "tenant-b", GatewayID: "gw-b"}}
+		return nil
+	}))
+	return c
+}
+
+func TestController_PinTenant(t *testing.T) {
+	task := concurrency.RootTask()
+	c := newTestController(t)
+	refs := []string{"gw-a", "gw-b", "master-id-1", "test-master-1", "node-id-1", "test-node-1", "node-id-2", "test-node-2"}
+	defer func() {
+		for _, ref := range refs {
+			client.SetHostTenant(ref, "")
+		}
+	}()
+
+	// Loaded by a user request, the hosts of the main tenant are reached in the current tenant of safescaled
+	c.registerSiteHosts(task)
+	assert.Equal(t, "", client.GetHostTenant("master-id-1"))
+	assert.Equal(t, "", client.GetHostTenant("node-id-1"))
+	assert.Equal(t, "tenant-b", client.GetHostTenant("node-id-2"))
+	assert.Equal(t, "", c.getPinnedTenant(task))
+
+	// Loaded by a scheduled task, all the hosts are reached in their tenant, even if the current tenant of
+	// safescaled is switched to another one while the task runs
+	require.NoError(t, c.PinTenant(task, "tenant-a"))
+	for _, ref := range []string{"gw-a", "master-id-1", "test-master-1", "node-id-1", "test-node-1"} {
+		assert.Equal(t, "tenant-a", client.GetHostTenant(ref), ref)
+	}
+	assert.Equal(t, "tenant-b", client.GetHostTenant("gw-b"))
+	assert.Equal(t, "tenant-b", client.GetHostTenant("node-id-2"))
+	assert.Equal(t, "tenant-b", client.GetHostTenant("test-node-2"))
+
+	// The nodes added in the main tenant are created in the tenant the cluster is pinned to
+	assert.Equal(t, "tenant-a", c.getPinnedTenant(task))
+	local, err := c.getLocalTenant(task)
+	require.NoError(t, err)
+	assert.Equal(t, "tenant-a", local)
+
+	assert.Error(t, c.PinTenant(task, ""))
+}
@@ -53,6 +53,8 @@ type Controller struct {
 	service  iaas.Service
 
 	lastStateCollection time.Time
+	localTenant         string // name of the tenant of service, known once needed
+	pinnedTenant        string // tenant the controller is bound to by PinTenant, whatever the current tenant of safescaled
 
 	concurrency.TaskedLock
 }
@@ -92,8 +94,11 @@ func (c *Controller) Restore(task concurrency.Task, f Foreman) (err error) {
 	}
 
 	c.Lock(task)
-	defer c.Unlock(task)
 	c.foreman = f.(*foreman)
+	c.Unlock(task)
+
+	// Hosts of the sites of a multi-tenant cluster have to be reached in their tenant
+	c.registerSiteHosts(task)
 	return nil
 }
 
@@ -591,7 +596,12 @@ func (c *Controller) UpdateMetadata(task concurrency.Task, updatefn func() error
 			return err
 		}
 	}
-	return c.metadata.Write()
+	err = c.metadata.Write()
+	if err != nil {
+		return err
+	}
+	c.replicateMetadata(task)
+	return nil
 }
 
 // DeleteMetadata removes Cluster metadata from Object Storage
@@ -613,9 +623,37 @@ func (c *Controller) DeleteMetadata(task concurrency.Task) (err error) {
 	c.metadata.Acquire()
 	defer c.metadata.Release()
 
+	// Removes the replicas of the metadata in the other tenants of a multi-tenant cluster
+	if composite := c.getComposite(task); composite != nil && len(composite.Tenants) > 1 {
+		local, err := c.getLocalTenant(task)
+		if err != nil {
+			return err
+		}
+		for _, tenant := range composite.Tenants {
+			if tenant == local {
+				continue
+			}
+			err = c.deleteMetadataReplica(task, tenant)
+			if err != nil {
+				log.Warnf("failed to delete metadata replica of cluster '%s' in tenant '%s': %v", c.Name, tenant, err)
+			}
+		}
+	}
+
 	return c.metadata.Delete()
 }
 
+// removeString returns list without the occurrences of value
+func removeString(list []string, value string) []string {
+	out := make([]string, 0, len(list))
+	for _, v := range list {
+		if v != value {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
 func contains(list []*clusterpropsv1.Node, hostID string) (bool, int) {
 	var idx int
 	found := false
@@ -643,7 +681,7 @@ func (c *Controller) Deserialize(buf []byte) error {
 func (c *Controller) AddNode(task concurrency.Task, req *pb.HostDefinition) (string, error) {
 	// No log enforcement here, delegated to AddNodes()
 
-	hosts, err := c.AddNodes(task, 1, req)
+	hosts, err := c.AddNodes(task, "", 1, req)
 	if err != nil {
 		return "", err
 	}
@@ -689,8 +727,9 @@ func (c *Controller) getImageAndNodeDescriptionUsedInClusterFromMetadata(task *c
 	return hostImage, nodeDef, nil
 }
 
-// AddNodes adds <count> nodes
-func (c *Controller) AddNodes(task concurrency.Task, count int, req *pb.HostDefinition) (hosts []string, err error) {
+// AddNodes adds <count> nodes in the node pool <poolName> (the default pool if empty string)
+// The nodes are created from the definition of the pool, complemented by req if not nil
+func (c *Controller) AddNodes(task concurrency.Task, poolName string, count int, req *pb.HostDefinition) (hosts []string, err error) {
 	if c == nil {
 		return nil, scerr.InvalidInstanceError()
 	}
@@ -700,21 +739,32 @@ func (c *Controller) AddNodes(task concurrency.Task, count int, req *pb.HostDefi
 	if task == nil {
 		task = concurrency.RootTask()
 	}
+	if poolName == "" {
+		poolName = DefaultNodePoolName
+	}
 
-	tracer := concurrency.NewTracer(task, fmt.Sprintf("(%d)", count), true)
+	tracer := concurrency.NewTracer(task, fmt.Sprintf("('%s', %d)", poolName, count), true)
 	defer tracer.GoingIn().OnExitTrace()()
 	defer scerr.OnExitLogError(tracer.TraceMessage(""), &err)()
 
 	// retrieve cluster characteristics
-	hostImage, nodeDef, err := c.getImageAndNodeDescriptionUsedInClusterFromMetadata(&task)
+	hostImage, _, err := c.getImageAndNodeDescriptionUsedInClusterFromMetadata(&task)
 	if err != nil {
 		return hosts, err
 	}
-
-	nodeDef = complementHostDefinition(req, *nodeDef)
+	pool, err := c.getNodePool(task, poolName)
+	if err != nil {
+		return hosts, err
+	}
+	sizing := srvutils.ToPBHostSizing(pool.Sizing)
+	nodeDef := complementHostDefinition(req, pb.HostDefinition{
+		Sizing:  &sizing,
+		ImageId: pool.Image,
+	})
 	if nodeDef.ImageId == "" {
 		nodeDef.ImageId = hostImage
 	}
+	nodeDef.Public = nodeDef.Public || pool.Public
 
 	var (
 		// nodeType    NodeType.Enum
@@ -726,6 +776,15 @@ func (c *Controller) AddNodes(task concurrency.Task, count int, req *pb.HostDefi
 		return nil, err
 	}
 	nodeDef.Network = netCfg.NetworkID
+	// Nodes of a pool hosted by another tenant are created in the network of the site of this tenant
+	if pool.Tenant != "" && pool.Tenant != c.getMainTenant(task) {
+		site, err := c.ensureSite(task, pool.Tenant, "")
+		if err != nil {
+			return nil, err
+		}
+		nodeDef.Tenant = pool.Tenant
+		nodeDef.Network = site.NetworkID
+	}
 
 	timeout := temporal.GetExecutionTimeout() + time.Duration(count)*time.Minute
 
@@ -743,6 +802,7 @@ func (c *Controller) AddNodes(task concurrency.Task, count int, req *pb.HostDefi
 			"nodeDef": nodeDef,
 			"timeout": timeout,
 			"nokeep":  true,
+			"pool":    poolName,
 		})
 		if err != nil {
 			log.Warnf("failure creating node: %v", err)
@@ -823,6 +883,19 @@ func (c *Controller) AddNodes(task concurrency.Task, count int, req *pb.HostDefi
 		return nil, err
 	}
 
+	// Sets the labels and taints of the pool on the new nodes
+	err = c.foreman.labelNodesFromList(task, hosts, pool)
+	if err != nil {
+		log.Debugf("failure labeling nodes after successful join...")
+		return nil, err
+	}
+
+	err = c.updateNodePool(task, poolName, func(np *clusterpropsv1.NodePool) {
+		np.Count += len(hosts)
+	})
+	if err != nil {
+		return nil, err
+	}
 	return hosts, nil
 }
 
@@ -974,26 +1047,41 @@ func (c *Controller) deleteMaster(task concurrency.Task, hostID string) (err err
 	return nil
 }
 
-// DeleteLastNode deletes the last Agent node added
-func (c *Controller) DeleteLastNode(task concurrency.Task, selectedMaster string) (err error) {
+// DeleteLastNode deletes the last node added in the node pool <poolName> (the default pool if empty string)
+func (c *Controller) DeleteLastNode(task concurrency.Task, poolName string, selectedMaster string) (err error) {
 	if c == nil {
 		return scerr.InvalidInstanceError()
 	}
 	if task == nil {
 		task = concurrency.RootTask()
 	}
+	if poolName == "" {
+		poolName = DefaultNodePoolName
+	}
 
-	tracer := concurrency.NewTracer(task, fmt.Sprintf("('%s')", selectedMaster), true).GoingIn()
+	tracer := concurrency.NewTracer(task, fmt.Sprintf("('%s', '%s')", poolName, selectedMaster), true).GoingIn()
 	defer tracer.OnExitTrace()()
 	defer scerr.OnExitLogError(tracer.TraceMessage(""), &err)()
 
+	pool, err := c.getNodePool(task, poolName)
+	if err != nil {
+		return err
+	}
+	if len(pool.Nodes) == 0 {
+		return scerr.NotFoundError(fmt.Sprintf("node pool '%s' doesn't contain any node", poolName))
+	}
+	hostID := pool.Nodes[len(pool.Nodes)-1]
+
 	var node *clusterpropsv1.Node
 
-	// Removed reference of the node from cluster metadata
 	c.RLock(task)
 	err = c.Properties.LockForRead(property.NodesV1).ThenUse(func(clonable data.Clonable) error {
 		nodesV1 := clonable.(*clusterpropsv1.Nodes)
-		node = nodesV1.PrivateNodes[len(nodesV1.PrivateNodes)-1]
+		found, idx := contains(nodesV1.PrivateNodes, hostID)
+		if !found {
+			return scerr.InconsistentError(fmt.Sprintf("node '%s' of pool '%s' isn't registered as a node of the cluster", hostID, poolName))
+		}
+		node = nodesV1.PrivateNodes[idx]
 		return nil
 	})
 	c.RUnlock(task)
@@ -1063,7 +1151,7 @@ func (c *Controller) DeleteSpecificNode(task concurrency.Task, hostID string, se
 	return c.deleteNode(task, node, selectedMaster)
 }
 
-// deleteNode deletes the node specified by its ID
+// deleteNode deletes the node specified by its ID, and decrements the count of nodes wanted in its node pool
 func (c *Controller) deleteNode(task concurrency.Task, node *clusterpropsv1.Node, selectedMaster string) (err error) {
 	if c == nil {
 		return scerr.InvalidInstanceError()
@@ -1080,8 +1168,9 @@ func (c *Controller) deleteNode(task concurrency.Task, node *clusterpropsv1.Node
 	defer scerr.OnExitLogError(tracer.TraceMessage(""), &err)()
 
 	// Removes node from cluster metadata (done before really deleting node to prevent operations on the node in parallel)
+	var poolName string
 	err = c.UpdateMetadata(task, func() error {
-		return c.Properties.LockForWrite(property.NodesV1).ThenUse(func(clonable data.Clonable) error {
+		err := c.Properties.LockForWrite(property.NodesV1).ThenUse(func(clonable data.Clonable) error {
 			nodesV1 := clonable.(*clusterpropsv1.Nodes)
 			length := len(nodesV1.PrivateNodes)
 			_, idx := contains(nodesV1.PrivateNodes, node.ID)
@@ -1092,6 +1181,20 @@ func (c *Controller) deleteNode(task concurrency.Task, node *clusterpropsv1.Node
 			}
 			return nil
 		})
+		if err != nil || !c.Properties.Lookup(property.NodePoolsV1) {
+			return err
+		}
+		return c.Properties.LockForWrite(property.NodePoolsV1).ThenUse(func(clonable data.Clonable) error {
+			pool := clonable.(*clusterpropsv1.NodePools).FindNode(node.ID)
+			if pool != nil {
+				poolName = pool.Name
+				pool.Nodes = removeString(pool.Nodes, node.ID)
+				if pool.Count > 0 {
+					pool.Count--
+				}
+			}
+			return nil
+		})
 	})
 	if err != nil {
 		return err
@@ -1101,11 +1204,21 @@ func (c *Controller) deleteNode(task concurrency.Task, node *clusterpropsv1.Node
 	defer func() {
 		if err != nil {
 			derr := c.UpdateMetadata(task, func() error {
-				return c.Properties.LockForWrite(property.NodesV1).ThenUse(func(clonable data.Clonable) error {
+				err := c.Properties.LockForWrite(property.NodesV1).ThenUse(func(clonable data.Clonable) error {
 					nodesV1 := clonable.(*clusterpropsv1.Nodes)
 					nodesV1.PrivateNodes = append(nodesV1.PrivateNodes, node)
 					return nil
 				})
+				if err != nil || poolName == "" {
+					return err
+				}
+				return c.Properties.LockForWrite(property.NodePoolsV1).ThenUse(func(clonable data.Clonable) error {
+					if pool, ok := clonable.(*clusterpropsv1.NodePools).ByName[poolName]; ok {
+						pool.Nodes = append(pool.Nodes, node.ID)
+						pool.Count++
+					}
+					return nil
+				})
 			})
 			if derr != nil {
 				log.Errorf("failed to restore node ownership in cluster")
@@ -1131,13 +1244,14 @@ func (c *Controller) deleteNode(task concurrency.Task, node *clusterpropsv1.Node
 	// Finally delete host
 	err = client.New().Host.Delete([]string{node.ID}, temporal.GetLongOperationTimeout())
 	if err != nil {
-		if _, ok := err.(scerr.ErrNotFound); ok {
-			// host seems already deleted, so it's a success :-)
-			return nil
+		if _, ok := err.(scerr.ErrNotFound); !ok {
+			return err
 		}
-		return err
+		// host seems already deleted, so it's a success :-)
+		err = nil
 	}
-
+	client.SetHostTenant(node.ID, "")
+	client.SetHostTenant(node.Name, "")
 	return nil
 }
 
@@ -1244,6 +1358,11 @@ func (c *Controller) Stop(task concurrency.Task) (err error) {
 	if secondaryGatewayID != "" {
 		_, _ = taskGroup.Start(c.asyncStopHost, secondaryGatewayID)
 	}
+	if composite := c.getComposite(task); composite != nil {
+		for _, site := range composite.Sites {
+			_, _ = taskGroup.Start(c.asyncStopHost, site.GatewayID)
+		}
+	}
 
 	_, err = taskGroup.WaitGroup()
 	if err != nil {
@@ -1261,7 +1380,11 @@ func (c *Controller) Stop(task concurrency.Task) (err error) {
 }
 
 func (c *Controller) asyncStopHost(task concurrency.Task, params concurrency.TaskParameters) (concurrency.TaskResult, error) {
-	return nil, c.service.StopHost(params.(string))
+	svc, err := c.getHostService(task, params.(string))
+	if err != nil {
+		return nil, err
+	}
+	return nil, svc.StopHost(params.(string))
 }
 
 // Start starts the Cluster
@@ -1341,6 +1464,11 @@ func (c *Controller) Start(task concurrency.Task) (err error) {
 	if secondaryGatewayID != "" {
 		_, _ = taskGroup.Start(c.asyncStartHost, secondaryGatewayID)
 	}
+	if composite := c.getComposite(task); composite != nil {
+		for _, site := range composite.Sites {
+			_, _ = taskGroup.Start(c.asyncStartHost, site.GatewayID)
+		}
+	}
 	// Start masters
 	for _, n := range masters {
 		_, _ = taskGroup.Start(c.asyncStopHost, n.ID)
@@ -1364,7 +1492,11 @@ func (c *Controller) Start(task concurrency.Task) (err error) {
 }
 
 func (c *Controller) asyncStartHost(task concurrency.Task, params concurrency.TaskParameters) (concurrency.TaskResult, error) {
-	return nil, c.service.StartHost(params.(string))
+	svc, err := c.getHostService(task, params.(string))
+	if err != nil {
+		return nil, err
+	}
+	return nil, svc.StartHost(params.(string))
 }
 
 // // sanitize tries to rebuild manager struct based on what is available on ObjectStorage
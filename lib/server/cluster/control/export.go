@@ -0,0 +1,344 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package control
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/CS-SI/SafeScale/lib/client"
+	"github.com/CS-SI/SafeScale/lib/server/cluster/api"
+	clusterpropsv1 "github.com/CS-SI/SafeScale/lib/server/cluster/control/properties/v1"
+	"github.com/CS-SI/SafeScale/lib/server/cluster/enums/exportformat"
+	"github.com/CS-SI/SafeScale/lib/server/cluster/enums/flavor"
+	"github.com/CS-SI/SafeScale/lib/server/cluster/enums/property"
+	"github.com/CS-SI/SafeScale/lib/utils/cli/enums/outputs"
+	"github.com/CS-SI/SafeScale/lib/utils/concurrency"
+	"github.com/CS-SI/SafeScale/lib/utils/data"
+	"github.com/CS-SI/SafeScale/lib/utils/scerr"
+	"github.com/CS-SI/SafeScale/lib/utils/temporal"
+)
+
+// kubeconfigServerRegex matches the URL of the API server in a kubeconfig, capturing indentation, host and port
+var kubeconfigServerRegex = regexp.MustCompile(`(?m)^([ \t]*)server: https://([^:/\s]+)(:[0-9]+)?[ \t]*$`)
+
+// exportedHost describes how to reach a host of the cluster
+type exportedHost struct {
+	Name      string `json:"name"`
+	ID        string `json:"id"`
+	Role      string `json:"role"`           // gateway, master or node
+	Pool      string `json:"pool,omitempty"` // node pool of the node
+	PrivateIP string `json:"private_ip"`
+	PublicIP  string `json:"public_ip,omitempty"`
+	AccessIP  string `json:"access_ip"` // IP used by SSH (the public IP if any)
+	User      string `json:"user"`
+	Port      int    `json:"port"`
+	KeyFile   string `json:"key_file"`
+	ProxyJump string `json:"proxy_jump,omitempty"` // name of the gateway to go through, if the host has no public IP
+
+	privateKey string
+	hostKey    string
+}
+
+// exportedCluster describes the cluster and how to reach its hosts
+type exportedCluster struct {
+	Name       string          `json:"name"`
+	Flavor     string          `json:"flavor"`
+	Complexity string          `json:"complexity"`
+	EndpointIP string          `json:"endpoint_ip"`
+	AdminUser  string          `json:"admin_user"`
+	KnownHosts string          `json:"known_hosts,omitempty"` // file containing the SSH host keys pinned by SafeScale
+	Hosts      []*exportedHost `json:"hosts"`
+}
+
+// Export returns the configuration to access the cluster in format: the kubeconfig of the cluster admin with the API
+// server reached through the endpoint of the cluster (gateway public IP or VIP), an OpenSSH client configuration
+// (hosts without public IP reached through their gateway), an Ansible inventory (groups gateways, masters, nodes and
+// pool_<name>) or a JSON description of the hosts.
+// The private keys of the hosts and the known hosts are referenced as files of keyDir, whose content is returned in Files.
+func (c *Controller) Export(task concurrency.Task, format exportformat.Enum, keyDir string) (_ *api.Export, err error) {
+	if c == nil {
+		return nil, scerr.InvalidInstanceError()
+	}
+	if task == nil {
+		task = concurrency.RootTask()
+	}
+
+	tracer := concurrency.NewTracer(task, fmt.Sprintf("(%s, '%s')", format.String(), keyDir), true).GoingIn()
+	defer tracer.OnExitTrace()()
+	defer scerr.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	export := &api.Export{Files: map[string]string{}}
+	if format == exportformat.Kubeconfig {
+		export.Content, err = c.exportKubeconfig(task)
+		if err != nil {
+			return nil, err
+		}
+		return export, nil
+	}
+
+	if keyDir == "" {
+		return nil, scerr.InvalidParameterError("keyDir", "cannot be empty string")
+	}
+	cluster, err := c.listExportedHosts(task, keyDir)
+	if err != nil {
+		return nil, err
+	}
+	var knownHosts []string
+	for _, h := range cluster.Hosts {
+		export.Files[h.KeyFile] = h.privateKey
+		if h.hostKey != "" {
+			knownHosts = append(knownHosts, h.AccessIP+" "+strings.TrimSpace(h.hostKey))
+		}
+	}
+	if len(knownHosts) > 0 {
+		cluster.KnownHosts = filepath.Join(keyDir, "known_hosts")
+		export.Files[cluster.KnownHosts] = strings.Join(knownHosts, "\n") + "\n"
+	}
+
+	switch format {
+	case exportformat.SSHConfig:
+		export.Content = cluster.sshConfig()
+	case exportformat.AnsibleInventory:
+		export.Content = cluster.ansibleInventory()
+	case exportformat.JSON:
+		out, err := json.MarshalIndent(cluster, "", "    ")
+		if err != nil {
+			return nil, err
+		}
+		export.Content = string(out) + "\n"
+	default:
+		return nil, scerr.InvalidParameterError("format", fmt.Sprintf("unsupported export format '%d'", format))
+	}
+	return export, nil
+}
+
+// exportKubeconfig returns the kubeconfig of the cluster admin, rewritten to reach the API server through the endpoint
+// of the cluster
+func (c *Controller) exportKubeconfig(task concurrency.Task) (string, error) {
+	if f := c.GetIdentity(task).Flavor; f != flavor.K8S && f != flavor.K3S {
+		return "", scerr.NotAvailableError(fmt.Sprintf("flavor '%s' of cluster '%s' has no kubeconfig", f.String(), c.Name))
+	}
+	netCfg, err := c.GetNetworkConfig(task)
+	if err != nil {
+		return "", err
+	}
+	endpoint := netCfg.EndpointIP
+	if endpoint == "" {
+		endpoint = netCfg.PrimaryPublicIP
+	}
+
+	masterID, err := c.FindAvailableMaster(task)
+	if err != nil {
+		return "", err
+	}
+	retcode, stdout, stderr, err := client.New().SSH.Run(masterID, "sudo cat ~cladm/.kube/config", outputs.COLLECT, client.DefaultConnectionTimeout, client.DefaultExecutionTimeout)
+	if err != nil {
+		return "", err
+	}
+	if retcode != 0 {
+		return "", fmt.Errorf("failed to read kubeconfig on master '%s': errorcode %d, %s", masterID, retcode, stderr)
+	}
+	return rewriteKubeconfigServer(stdout, endpoint), nil
+}
+
+// rewriteKubeconfigServer replaces the host of the API server by endpoint, keeping the former host as the name
+// expected in the certificate of the API server
+func rewriteKubeconfigServer(kubeconfig, endpoint string) string {
+	return kubeconfigServerRegex.ReplaceAllString(kubeconfig, "${1}server: https://"+endpoint+"${3}\n${1}tls-server-name: ${2}")
+}
+
+// listExportedHosts returns the gateways, masters and nodes of the cluster with their SSH configuration
+func (c *Controller) listExportedHosts(task concurrency.Task, keyDir string) (*exportedCluster, error) {
+	identity := c.GetIdentity(task)
+	netCfg, err := c.GetNetworkConfig(task)
+	if err != nil {
+		return nil, err
+	}
+	cluster := &exportedCluster{
+		Name:       identity.Name,
+		Flavor:     identity.Flavor.String(),
+		Complexity: identity.Complexity.String(),
+		EndpointIP: netCfg.EndpointIP,
+		AdminUser:  "cladm",
+	}
+	if cluster.EndpointIP == "" {
+		cluster.EndpointIP = netCfg.PrimaryPublicIP
+	}
+
+	pools, err := c.ListNodePools(task)
+	if err != nil {
+		return nil, err
+	}
+	poolOfNode := map[string]string{}
+	for _, p := range pools {
+		for _, id := range p.Nodes {
+			poolOfNode[id] = p.Name
+		}
+	}
+
+	clt := client.New()
+	gatewayIDs := []string{netCfg.GatewayID}
+	if netCfg.SecondaryGatewayID != "" {
+		gatewayIDs = append(gatewayIDs, netCfg.SecondaryGatewayID)
+	}
+	for _, id := range gatewayIDs {
+		pbHost, err := clt.Host.Inspect(id, temporal.GetExecutionTimeout())
+		if err != nil {
+			return nil, err
+		}
+		cluster.Hosts = append(cluster.Hosts, &exportedHost{
+			Name:      pbHost.Name,
+			ID:        id,
+			Role:      "gateway",
+			PrivateIP: pbHost.PrivateIp,
+			PublicIP:  pbHost.PublicIp,
+		})
+	}
+
+	var nodesV1 *clusterpropsv1.Nodes
+	c.RLock(task)
+	err = c.Properties.LockForRead(property.NodesV1).ThenUse(func(clonable data.Clonable) error {
+		nodesV1 = clonable.(*clusterpropsv1.Nodes).Clone().(*clusterpropsv1.Nodes)
+		return nil
+	})
+	c.RUnlock(task)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range nodesV1.Masters {
+		cluster.Hosts = append(cluster.Hosts, &exportedHost{Name: m.Name, ID: m.ID, Role: "master", PrivateIP: m.PrivateIP, PublicIP: m.PublicIP})
+	}
+	for _, n := range append(nodesV1.PrivateNodes, nodesV1.PublicNodes...) {
+		cluster.Hosts = append(cluster.Hosts, &exportedHost{Name: n.Name, ID: n.ID, Role: "node", Pool: poolOfNode[n.ID], PrivateIP: n.PrivateIP, PublicIP: n.PublicIP})
+	}
+
+	gatewayByIP := map[string]string{}
+	for _, h := range cluster.Hosts {
+		sshCfg, err := clt.Host.SSHConfig(h.ID)
+		if err != nil {
+			return nil, err
+		}
+		h.AccessIP = sshCfg.Host
+		h.User = sshCfg.User
+		h.Port = sshCfg.Port
+		h.KeyFile = filepath.Join(keyDir, h.Name+".pem")
+		h.privateKey = sshCfg.PrivateKey
+		h.hostKey = sshCfg.HostKey
+		if h.Role == "gateway" {
+			gatewayByIP[h.AccessIP] = h.Name
+			continue
+		}
+		if h.PublicIP == "" && sshCfg.GatewayConfig != nil {
+			h.ProxyJump = gatewayByIP[sshCfg.GatewayConfig.Host]
+			if h.ProxyJump == "" {
+				h.ProxyJump = cluster.Hosts[0].Name
+			}
+		}
+	}
+	return cluster, nil
+}
+
+// findHost returns the exported host named name
+func (ec *exportedCluster) findHost(name string) *exportedHost {
+	for _, h := range ec.Hosts {
+		if h.Name == name {
+			return h
+		}
+	}
+	return nil
+}
+
+// sshConfig returns the OpenSSH client configuration of the hosts of the cluster
+func (ec *exportedCluster) sshConfig() string {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "# Hosts of cluster '%s' (%s)\n", ec.Name, ec.Flavor)
+	for _, h := range ec.Hosts {
+		fmt.Fprintf(&b, "\nHost %s\n", h.Name)
+		fmt.Fprintf(&b, "    HostName %s\n", h.AccessIP)
+		fmt.Fprintf(&b, "    User %s\n", h.User)
+		fmt.Fprintf(&b, "    Port %d\n", h.Port)
+		fmt.Fprintf(&b, "    IdentityFile %s\n", h.KeyFile)
+		fmt.Fprintf(&b, "    IdentitiesOnly yes\n")
+		if ec.KnownHosts != "" {
+			fmt.Fprintf(&b, "    UserKnownHostsFile %s\n", ec.KnownHosts)
+		}
+		if h.ProxyJump != "" {
+			fmt.Fprintf(&b, "    ProxyJump %s\n", h.ProxyJump)
+		}
+	}
+	return b.String()
+}
+
+// ansibleInventory returns the inventory of the hosts of the cluster in INI format, with groups gateways, masters,
+// nodes and one group per node pool
+func (ec *exportedCluster) ansibleInventory() string {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "# Hosts of cluster '%s' (%s)\n", ec.Name, ec.Flavor)
+	pools := map[string][]string{}
+	for _, group := range []string{"gateway", "master", "node"} {
+		fmt.Fprintf(&b, "\n[%ss]\n", group)
+		for _, h := range ec.Hosts {
+			if h.Role != group {
+				continue
+			}
+			if h.Pool != "" {
+				pools[h.Pool] = append(pools[h.Pool], h.Name)
+			}
+			fmt.Fprintf(&b, "%s ansible_host=%s ansible_port=%d ansible_user=%s ansible_ssh_private_key_file=%s", h.Name, h.AccessIP, h.Port, h.User, h.KeyFile)
+			if args := ec.ansibleSSHArgs(h); args != "" {
+				fmt.Fprintf(&b, " ansible_ssh_common_args='%s'", args)
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	var names []string
+	for name := range pools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	nonAlnum := regexp.MustCompile(`[^A-Za-z0-9_]`)
+	for _, name := range names {
+		fmt.Fprintf(&b, "\n[pool_%s]\n", nonAlnum.ReplaceAllString(name, "_"))
+		for _, host := range pools[name] {
+			b.WriteString(host + "\n")
+		}
+	}
+	return b.String()
+}
+
+// ansibleSSHArgs returns the options of ssh needed to reach the host: known hosts and jump through the gateway
+func (ec *exportedCluster) ansibleSSHArgs(h *exportedHost) string {
+	var args []string
+	if ec.KnownHosts != "" {
+		args = append(args, "-o UserKnownHostsFile="+ec.KnownHosts)
+	}
+	if gw := ec.findHost(h.ProxyJump); gw != nil {
+		proxy := fmt.Sprintf("ssh -W %%h:%%p -q -i %s -p %d", gw.KeyFile, gw.Port)
+		if ec.KnownHosts != "" {
+			proxy += " -o UserKnownHostsFile=" + ec.KnownHosts
+		}
+		args = append(args, fmt.Sprintf(`-o ProxyCommand="%s %s@%s"`, proxy, gw.User, gw.AccessIP))
+	}
+	return strings.Join(args, " ")
+}
@@ -20,6 +20,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -97,6 +98,16 @@ type Makers struct {
 	UnconfigureCluster          func(task concurrency.Task, f Foreman) error
 	JoinMasterToCluster         func(task concurrency.Task, f Foreman, pbHost *pb.Host) error
 	JoinNodeToCluster           func(task concurrency.Task, f Foreman, pbHost *pb.Host) error
+	LabelNode                   func(task concurrency.Task, f Foreman, pbHost *pb.Host, labels map[string]string, taints []string) error
+	CountPendingWork            func(task concurrency.Task, f Foreman) (int, error)                                                    // returns the number of units of work waiting for resources
+	GetNodesReadiness           func(task concurrency.Task, f Foreman) (map[string]bool, error)                                        // returns the readiness of the hosts known by the cluster manager, indexed by hostname
+	DrainNode                   func(task concurrency.Task, f Foreman, pbHost *pb.Host) error                                          // moves the workload away from the host and prevents scheduling on it
+	UncordonNode                func(task concurrency.Task, f Foreman, pbHost *pb.Host) error                                          // allows again scheduling on the host
+	GetPlatformVersion          func(task concurrency.Task, f Foreman, pbHost *pb.Host) (string, error)                                // returns the version of the cluster manager installed on the host
+	UpgradePlatform             func(task concurrency.Task, f Foreman, pbHost *pb.Host, version string, master bool, first bool) error // upgrades the cluster manager on the host; first is set for the first master upgraded
+	RollbackPlatform            func(task concurrency.Task, f Foreman, pbHost *pb.Host, version string) error                          // restores the previous version of the cluster manager on a node
+	BackupControlPlane          func(task concurrency.Task, f Foreman, pbHost *pb.Host, w io.Writer) (string, error)                   // writes to w an archive of the state of the control plane saved from the master, returns the version of the cluster manager
+	RestoreControlPlane         func(task concurrency.Task, f Foreman, r io.Reader, version string, formerMasters []string) error      // rebuilds the control plane (in version if known) on the (new) masters from the archive read from r, forgetting formerMasters
 	LeaveMasterFromCluster      func(task concurrency.Task, f Foreman, pbHost *pb.Host) error
 	LeaveNodeFromCluster        func(task concurrency.Task, f Foreman, pbHost *pb.Host, selectedMaster string) error
 	GetState                    func(task concurrency.Task, f Foreman) (clusterstate.Enum, error)
@@ -389,6 +400,7 @@ func (b *foreman) construct(task concurrency.Task, req Request) (err error) {
 	b.cluster.Identity.Complexity = req.Complexity
 	b.cluster.Identity.Keypair = kp
 	b.cluster.Identity.AdminPassword = cladmPassword
+	masterCount, privateNodeCount, _ := b.determineRequiredNodes(task)
 	err = b.cluster.UpdateMetadata(task, func() error {
 		err := b.cluster.GetProperties(task).LockForWrite(property.DefaultsV2).ThenUse(func(clonable data.Clonable) error {
 			defaultsV2 := clonable.(*clusterpropsv2.Defaults)
@@ -402,6 +414,20 @@ func (b *foreman) construct(task concurrency.Task, req Request) (err error) {
 			return err
 		}
 
+		err = b.cluster.GetProperties(task).LockForWrite(property.NodePoolsV1).ThenUse(func(clonable data.Clonable) error {
+			clonable.(*clusterpropsv1.NodePools).ByName[DefaultNodePoolName] = &clusterpropsv1.NodePool{
+				Name:   DefaultNodePoolName,
+				Sizing: srvutils.FromPBHostSizing(*nodesDef.Sizing),
+				Image:  nodesDef.ImageId,
+				Public: nodesDef.Public,
+				Count:  privateNodeCount,
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
 		err = b.cluster.GetProperties(task).LockForWrite(property.StateV1).ThenUse(func(clonable data.Clonable) error {
 			clonable.(*clusterpropsv1.State).State = clusterstate.Creating
 			return nil
@@ -454,7 +480,6 @@ func (b *foreman) construct(task concurrency.Task, req Request) (err error) {
 		}
 	}()
 
-	masterCount, privateNodeCount, _ := b.determineRequiredNodes(task)
 	var (
 		primaryGatewayStatus   error
 		secondaryGatewayStatus error
@@ -504,6 +529,7 @@ func (b *foreman) construct(task concurrency.Task, req Request) (err error) {
 		"public":  false,
 		"nodeDef": nodesDef,
 		"nokeep":  !req.KeepOnFailure,
+		"pool":    DefaultNodePoolName,
 	})
 	if err != nil {
 		return err
@@ -631,6 +657,14 @@ func (b *foreman) construct(task concurrency.Task, req Request) (err error) {
 		return err
 	}
 
+	// Step 7: adds the additional node pools requested, once the cluster is operational
+	for _, v := range req.NodePools {
+		_, err = b.cluster.AddNodePool(task, v.Name, v.Count, v.NodeDef, v.Labels, v.Taints, v.Tenant, v.CIDR)
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -737,7 +771,8 @@ func (b *foreman) destruct(task concurrency.Task) (err error) {
 		return scerr.ErrListError(cleaningErrors)
 	}
 
-	// Deletes the network
+	// Deletes the networks of the sites hosted by other tenants, then the network of the cluster
+	cleaningErrors = append(cleaningErrors, cluster.deleteSiteNetworks(task)...)
 	clientNetwork := client.New().Network
 	retryErr := retry.WhileUnsuccessfulDelay5SecondsTimeout(
 		func() error {
@@ -904,8 +939,8 @@ func (b *foreman) configureCluster(task concurrency.Task, params concurrency.Tas
 		return scerr.InvalidParameterError("params[Request]", "missing or not of type 'Request'")
 	}
 
-	// Configure docker Swarm except if flavor is K8S (Kubernetes)
-	if req.Flavor != flavor.K8S {
+	// Configure docker Swarm except if flavor is K8S or K3S (Kubernetes), or NOMAD (Nomad schedules docker itself)
+	if req.Flavor != flavor.K8S && req.Flavor != flavor.K3S && req.Flavor != flavor.NOMAD {
 		err = b.createSwarm(task, params)
 		if err != nil {
 			return err
@@ -1241,6 +1276,36 @@ func (b *foreman) joinNodesFromList(task concurrency.Task, hosts []string) error
 	return nil
 }
 
+// labelNodesFromList sets the labels and the taints of the node pool on the nodes from a list,
+// if the flavor supports it
+func (b *foreman) labelNodesFromList(task concurrency.Task, hosts []string, pool *clusterpropsv1.NodePool) error {
+	if b.makers.LabelNode == nil {
+		if len(pool.Labels) > 0 || len(pool.Taints) > 0 {
+			logrus.Warnf("[cluster %s] flavor doesn't support labels and taints on nodes, those of node pool '%s' are ignored", b.cluster.GetIdentity(task).Name, pool.Name)
+		}
+		return nil
+	}
+
+	// Nodes are always labeled with the name of their pool, to be selectable
+	labels := map[string]string{"safescale.io/node-pool": pool.Name}
+	for k, v := range pool.Labels {
+		labels[k] = v
+	}
+
+	clientHost := client.New().Host
+	for _, hostID := range hosts {
+		pbHost, err := clientHost.Inspect(hostID, temporal.GetExecutionTimeout())
+		if err != nil {
+			return err
+		}
+		err = b.makers.LabelNode(task, b, pbHost, labels, pool.Taints)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // leaveMastersFromList makes masters from a list leave the cluster
 func (b *foreman) leaveMastersFromList(task concurrency.Task, public bool, hosts []string) error {
 	if b.makers.LeaveMasterFromCluster == nil {
@@ -1296,7 +1361,7 @@ func (b *foreman) leaveNodesFromList(task concurrency.Task, hosts []string, sele
 			}
 		}
 
-		if b.cluster.GetIdentity(task).Flavor != flavor.K8S {
+		if f := b.cluster.GetIdentity(task).Flavor; f != flavor.K8S && f != flavor.K3S && f != flavor.NOMAD {
 			// Docker Swarm is always installed, even if the cluster type is not SWARM (for now, may evolve in the future)
 			// So removing a Node implies removing also from Swarm
 			err = b.leaveNodeFromSwarm(task, pbHost, selectedMaster)
@@ -1532,6 +1597,13 @@ func (b *foreman) installNodeRequirements(task concurrency.Task, nodeType nodety
 	if netCfg.SecondaryGatewayIP != "" {
 		params["SecondaryGatewayIP"] = netCfg.SecondaryGatewayIP
 	}
+	// A node hosted by another tenant is routed through the gateway of its site
+	if site := b.cluster.getHostSite(task, pbHost.Id); site != nil {
+		params["DefaultRouteIP"] = site.GatewayIP
+		params["GatewayIP"] = site.GatewayIP
+		params["PrimaryGatewayIP"] = site.GatewayIP
+		delete(params, "SecondaryGatewayIP")
+	}
 	retcode, _, _, err := b.ExecuteScript(box, funcMap, script, params, pbHost.Id)
 	if err != nil {
 		return err
@@ -1724,9 +1796,15 @@ func (b *foreman) taskCreateMaster(t concurrency.Task, params concurrency.TaskPa
 
 	hostDef.Network = netCfg.NetworkID
 	hostDef.Public = false
-	clientHost := client.New().Host
+	// Masters (re)created by a scheduled task, restoring a backup, are created in the tenant the cluster is pinned to
+	tenant := b.cluster.getPinnedTenant(t)
+	clientHost := client.NewOnTenant(tenant).Host
 	pbHost, err := clientHost.Create(hostDef, timeout)
 	if pbHost != nil {
+		if tenant != "" {
+			client.SetHostTenant(pbHost.Id, tenant)
+			client.SetHostTenant(pbHost.Name, tenant)
+		}
 		// Updates cluster metadata to keep track of created host, before testing if an error occurred during the creation
 		mErr := b.cluster.UpdateMetadata(t, func() error {
 			// Locks for write the NodesV1 extension...
@@ -1924,8 +2002,10 @@ func (b *foreman) taskCreateNodes(t concurrency.Task, params concurrency.TaskPar
 	if nokeep, ok = p["nokeep"].(bool); !ok {
 		return nil, scerr.InvalidParameterError("params[nokeep]", "is missing or not a bool")
 	}
+	// pool is optional
+	pool, _ := p["pool"].(string)
 
-	tracer := concurrency.NewTracer(t, fmt.Sprintf("(%d, %v)", count, public), true).WithStopwatch().GoingIn()
+	tracer := concurrency.NewTracer(t, fmt.Sprintf("(%d, %v, '%s')", count, public, pool), true).WithStopwatch().GoingIn()
 	defer tracer.OnExitTrace()()
 	defer scerr.OnExitLogError(tracer.TraceMessage(""), &err)()
 
@@ -1950,6 +2030,7 @@ func (b *foreman) taskCreateNodes(t concurrency.Task, params concurrency.TaskPar
 			"nodeDef": def,
 			"timeout": timeout,
 			"nokeep":  nokeep,
+			"pool":    pool,
 		})
 		if err != nil {
 			return nil, err
@@ -2008,8 +2089,10 @@ func (b *foreman) taskCreateNode(t concurrency.Task, params concurrency.TaskPara
 	if nokeep, ok = p["nokeep"].(bool); !ok {
 		return nil, scerr.InvalidParameterError("params[nokeep]", "is missing or not a bool")
 	}
+	// pool is optional; if set, the node is registered in this node pool
+	pool, _ := p["pool"].(string)
 
-	tracer := concurrency.NewTracer(t, fmt.Sprintf("(%d)", index), true).WithStopwatch().GoingIn()
+	tracer := concurrency.NewTracer(t, fmt.Sprintf("(%d, '%s')", index, pool), true).WithStopwatch().GoingIn()
 	defer tracer.OnExitTrace()()
 	defer scerr.OnExitLogError(tracer.TraceMessage(""), &err)()
 
@@ -2027,15 +2110,25 @@ func (b *foreman) taskCreateNode(t concurrency.Task, params concurrency.TaskPara
 	if err != nil {
 		return nil, err
 	}
-	hostDef.Network = netCfg.NetworkID
+	// A node hosted by another tenant is created in the network of its site, already set in def; the other ones
+	// are created in the tenant the cluster is pinned to, if any
+	tenant := hostDef.Tenant
+	if tenant == "" {
+		hostDef.Network = netCfg.NetworkID
+		tenant = b.cluster.getPinnedTenant(t)
+	}
 	if timeout < temporal.GetLongOperationTimeout() {
 		timeout = temporal.GetLongOperationTimeout()
 	}
 
-	clientHost := client.New().Host
+	clientHost := client.NewOnTenant(tenant).Host
 	var node *clusterpropsv1.Node
 	pbHost, err := clientHost.Create(hostDef, timeout)
 	if pbHost != nil {
+		if tenant != "" {
+			client.SetHostTenant(pbHost.Id, tenant)
+			client.SetHostTenant(pbHost.Name, tenant)
+		}
 		defer func() {
 			if err != nil {
 				derr := clientHost.Delete([]string{pbHost.Id}, temporal.GetLongOperationTimeout())
@@ -2046,7 +2139,7 @@ func (b *foreman) taskCreateNode(t concurrency.Task, params concurrency.TaskPara
 		}()
 		mErr := b.cluster.UpdateMetadata(t, func() error {
 			// Locks for write the NodesV1 extension...
-			return b.cluster.GetProperties(t).LockForWrite(property.NodesV1).ThenUse(func(clonable data.Clonable) error {
+			err := b.cluster.GetProperties(t).LockForWrite(property.NodesV1).ThenUse(func(clonable data.Clonable) error {
 				nodesV1 := clonable.(*clusterpropsv1.Nodes)
 				// Registers the new Agent in the swarmCluster struct
 				node = &clusterpropsv1.Node{
@@ -2058,6 +2151,18 @@ func (b *foreman) taskCreateNode(t concurrency.Task, params concurrency.TaskPara
 				nodesV1.PrivateNodes = append(nodesV1.PrivateNodes, node)
 				return nil
 			})
+			if err != nil || pool == "" {
+				return err
+			}
+			// ... then the NodePoolsV1 extension
+			return b.cluster.GetProperties(t).LockForWrite(property.NodePoolsV1).ThenUse(func(clonable data.Clonable) error {
+				nodePool, ok := clonable.(*clusterpropsv1.NodePools).ByName[pool]
+				if !ok {
+					return scerr.NotFoundError(fmt.Sprintf("failed to find node pool '%s'", pool))
+				}
+				nodePool.Nodes = append(nodePool.Nodes, pbHost.Id)
+				return nil
+			})
 		})
 		if mErr != nil && nokeep {
 			derr := clientHost.Delete([]string{pbHost.Id}, temporal.GetLongOperationTimeout())
@@ -0,0 +1,388 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package control
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/CS-SI/SafeScale/lib/client"
+	clusterpropsv1 "github.com/CS-SI/SafeScale/lib/server/cluster/control/properties/v1"
+	"github.com/CS-SI/SafeScale/lib/server/cluster/enums/clusterstate"
+	"github.com/CS-SI/SafeScale/lib/server/cluster/enums/flavor"
+	"github.com/CS-SI/SafeScale/lib/server/cluster/enums/nodestate"
+	"github.com/CS-SI/SafeScale/lib/server/cluster/enums/property"
+	"github.com/CS-SI/SafeScale/lib/server/iaas/resources/enums/hoststate"
+	"github.com/CS-SI/SafeScale/lib/server/install"
+	"github.com/CS-SI/SafeScale/lib/utils/cli/enums/outputs"
+	"github.com/CS-SI/SafeScale/lib/utils/concurrency"
+	"github.com/CS-SI/SafeScale/lib/utils/data"
+	"github.com/CS-SI/SafeScale/lib/utils/scerr"
+)
+
+// CheckHealth probes the masters and the nodes of the cluster (state of the host in the Stack, reachability by SSH
+// and readiness in the cluster manager of the flavor), records their health and updates the state of the cluster:
+// failing hosts are marked nodestate.Disabled and the cluster becomes clusterstate.Degraded.
+// Returns the health of the masters and nodes, sorted by name.
+func (c *Controller) CheckHealth(task concurrency.Task) (list []*clusterpropsv1.NodeHealth, err error) {
+	if c == nil {
+		return nil, scerr.InvalidInstanceError()
+	}
+	if task == nil {
+		task = concurrency.RootTask()
+	}
+
+	tracer := concurrency.NewTracer(task, "", true).GoingIn()
+	defer tracer.OnExitTrace()()
+	defer scerr.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	var state clusterstate.Enum
+	c.RLock(task)
+	err = c.Properties.LockForRead(property.StateV1).ThenUse(func(clonable data.Clonable) error {
+		state = clonable.(*clusterpropsv1.State).State
+		return nil
+	})
+	c.RUnlock(task)
+	if err != nil {
+		return nil, err
+	}
+	switch state {
+	case clusterstate.Nominal, clusterstate.Degraded, clusterstate.Error, clusterstate.Unknown:
+	default:
+		return nil, scerr.NotAvailableError(fmt.Sprintf("cluster '%s' is %s, health cannot be checked", c.Name, state.String()))
+	}
+	// Hosts being upgraded are drained or rebooting, they must not be considered failing
+	if c.isUpgrading(task) {
+		return nil, scerr.NotAvailableError(fmt.Sprintf("cluster '%s' is being upgraded, health cannot be checked", c.Name))
+	}
+
+	readiness, err := c.foreman.getNodesReadiness(task)
+	if err != nil {
+		log.Warnf("[cluster %s] failed to get readiness of nodes from cluster manager: %v", c.Name, err)
+		readiness = nil
+	}
+
+	var hosts []*clusterpropsv1.NodeHealth
+	for _, m := range c.ListMasters(task) {
+		hosts = append(hosts, &clusterpropsv1.NodeHealth{ID: m.ID, Name: m.Name, Master: true})
+	}
+	for _, n := range c.ListNodes(task) {
+		hosts = append(hosts, &clusterpropsv1.NodeHealth{ID: n.ID, Name: n.Name})
+	}
+	for _, h := range hosts {
+		h.Reason = c.probeHost(task, h.ID, h.Name, readiness)
+		h.LastCheck = time.Now()
+	}
+
+	state = clusterstate.Nominal
+	err = c.UpdateMetadata(task, func() error {
+		innerErr := c.Properties.LockForWrite(property.NodesHealthV1).ThenUse(func(clonable data.Clonable) error {
+			nodesHealthV1 := clonable.(*clusterpropsv1.NodesHealth)
+			previous := nodesHealthV1.ByID
+			nodesHealthV1.ByID = make(map[string]*clusterpropsv1.NodeHealth, len(hosts))
+			for _, h := range hosts {
+				if h.Reason == "" {
+					h.State = nodestate.Started
+				} else {
+					h.State = nodestate.Disabled
+					h.Failures = 1
+					if p, ok := previous[h.ID]; ok {
+						h.Failures += p.Failures
+					}
+					state = clusterstate.Degraded
+				}
+				nodesHealthV1.ByID[h.ID] = h
+				list = append(list, h.Clone())
+			}
+			return nil
+		})
+		if innerErr != nil {
+			return innerErr
+		}
+		return c.Properties.LockForWrite(property.StateV1).ThenUse(func(clonable data.Clonable) error {
+			clonable.(*clusterpropsv1.State).State = state
+			c.lastStateCollection = time.Now()
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	return list, nil
+}
+
+// probeHost checks the host and returns the reason of the failure, or empty string if the host is healthy
+func (c *Controller) probeHost(task concurrency.Task, hostID, hostName string, readiness map[string]bool) string {
+	svc, err := c.getHostService(task, hostID)
+	if err != nil {
+		return fmt.Sprintf("failed to get service of host: %v", err)
+	}
+	state, err := svc.GetHostState(hostID)
+	if err != nil {
+		return fmt.Sprintf("failed to get host state: %v", err)
+	}
+	if state != hoststate.STARTED {
+		return fmt.Sprintf("host is %s", state.String())
+	}
+
+	retcode, _, stderr, err := client.New().SSH.Run(hostID, "true", outputs.COLLECT, client.DefaultConnectionTimeout, client.DefaultExecutionTimeout)
+	if err != nil {
+		return fmt.Sprintf("unreachable by SSH: %v", err)
+	}
+	if retcode != 0 {
+		return fmt.Sprintf("unreachable by SSH: errorcode %d, %s", retcode, strings.TrimSpace(stderr))
+	}
+
+	// Hosts unknown by the cluster manager are not considered failing, their hostname may differ from the name of the host
+	if ready, ok := readiness[hostName]; ok && !ready {
+		return "not ready in cluster manager"
+	}
+	return ""
+}
+
+// RepairNode replaces the node identified by hostID by a new node of the same node pool: the node is removed
+// from the cluster manager (if possible) and deleted, then a node is created with the sizing of the pool and the
+// features registered on the cluster are installed again (with their default parameters).
+// Returns the ID of the new node. Masters cannot be replaced.
+func (c *Controller) RepairNode(task concurrency.Task, hostID string) (newID string, err error) {
+	if c == nil {
+		return "", scerr.InvalidInstanceError()
+	}
+	if hostID == "" {
+		return "", scerr.InvalidParameterError("hostID", "cannot be empty string")
+	}
+	if task == nil {
+		task = concurrency.RootTask()
+	}
+
+	tracer := concurrency.NewTracer(task, fmt.Sprintf("('%s')", hostID), true).GoingIn()
+	defer tracer.OnExitTrace()()
+	defer scerr.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	if found, _ := contains(c.ListMasters(task), hostID); found {
+		return "", scerr.InvalidParameterError("hostID", "masters cannot be replaced")
+	}
+	found, idx := contains(c.ListNodes(task), hostID)
+	if !found {
+		return "", scerr.NotFoundError(fmt.Sprintf("failed to find node '%s' in cluster '%s'", hostID, c.Name))
+	}
+	node := c.ListNodes(task)[idx]
+
+	err = c.ensureNodePools(task)
+	if err != nil {
+		return "", err
+	}
+	var poolName string
+	c.RLock(task)
+	err = c.Properties.LockForRead(property.NodePoolsV1).ThenUse(func(clonable data.Clonable) error {
+		if pool := clonable.(*clusterpropsv1.NodePools).FindNode(hostID); pool != nil {
+			poolName = pool.Name
+		}
+		return nil
+	})
+	c.RUnlock(task)
+	if err != nil {
+		return "", err
+	}
+	if poolName == "" {
+		poolName = DefaultNodePoolName
+	}
+
+	// The node is probably dead, so failing to make it leave the cluster manager does not prevent its replacement
+	selectedMaster, err := c.FindAvailableMaster(task)
+	if err == nil {
+		err = c.foreman.leaveNodesFromList(task, []string{hostID}, selectedMaster)
+	}
+	if err != nil {
+		log.Warnf("[cluster %s] failed to make node '%s' leave the cluster: %v", c.Name, node.Name, err)
+	}
+	// deleteNode decrements the count of nodes wanted in the pool, and AddNodes increments it back
+	err = c.deleteNode(task, node, "")
+	if err != nil {
+		return "", err
+	}
+	hosts, err := c.AddNodes(task, poolName, 1, nil)
+	if err != nil {
+		return "", err
+	}
+	if len(hosts) != 1 {
+		return "", scerr.InconsistentError(fmt.Sprintf("%d nodes created to replace node '%s'", len(hosts), node.Name))
+	}
+
+	err = c.reinstallFeatures(task)
+	if err != nil {
+		return hosts[0], err
+	}
+	return hosts[0], nil
+}
+
+// reinstallFeatures adds again the features registered on the cluster, to install them on new nodes
+// Features already installed on a host are skipped by the installer.
+func (c *Controller) reinstallFeatures(task concurrency.Task) error {
+	var (
+		names    []string
+		versions map[string]string
+		params   map[string]map[string]string
+	)
+	c.RLock(task)
+	err := c.Properties.LockForRead(property.FeaturesV1).ThenUse(func(clonable data.Clonable) error {
+		featuresV1 := clonable.(*clusterpropsv1.Features)
+		versions = make(map[string]string, len(featuresV1.Installed))
+		for k, v := range featuresV1.Installed {
+			names = append(names, k)
+			versions[k] = v
+		}
+		params = featuresV1.Clone().(*clusterpropsv1.Features).Parameters
+		return nil
+	})
+	c.RUnlock(task)
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+
+	target, err := install.NewClusterTarget(task, c)
+	if err != nil {
+		return err
+	}
+	var errs []string
+	for _, name := range names {
+		// Uses the version recorded if it can still be found, the current one otherwise
+		var (
+			feat *install.Feature
+			err  error
+		)
+		if versions[name] != "" {
+			feat, err = install.NewFeature(task, name+"@"+versions[name])
+		}
+		if feat == nil || err != nil {
+			feat, err = install.NewFeature(task, name)
+		}
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		recorded, err := install.UnsealParameters(c.GetService(task), params[name])
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("feature '%s': %s", name, err.Error()))
+			continue
+		}
+		values := install.Variables{}
+		for k, v := range recorded {
+			values[k] = v
+		}
+		results, err := feat.Add(target, values, install.Settings{})
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		if !results.Successful() {
+			errs = append(errs, fmt.Sprintf("failed to add feature '%s': %s", name, results.AllErrorMessages()))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("errors occurred re-installing features on cluster '%s': %s", c.Name, strings.Join(errs, "\n"))
+	}
+	return nil
+}
+
+// RegisterFeature records that the feature has been installed (or upgraded) on the cluster, with its version and
+// the values of its parameters, to install it on replacement nodes
+func (c *Controller) RegisterFeature(task concurrency.Task, name, version string, params map[string]string) error {
+	if c == nil {
+		return scerr.InvalidInstanceError()
+	}
+	if name == "" {
+		return scerr.InvalidParameterError("name", "cannot be empty string")
+	}
+
+	return c.UpdateMetadata(task, func() error {
+		return c.Properties.LockForWrite(property.FeaturesV1).ThenUse(func(clonable data.Clonable) error {
+			featuresV1 := clonable.(*clusterpropsv1.Features)
+			featuresV1.Installed[name] = version
+			if featuresV1.Parameters == nil {
+				featuresV1.Parameters = map[string]map[string]string{}
+			}
+			featuresV1.Parameters[name] = make(map[string]string, len(params))
+			for k, v := range params {
+				featuresV1.Parameters[name][k] = v
+			}
+			delete(featuresV1.Disabled, name)
+			return nil
+		})
+	})
+}
+
+// UnregisterFeature records that the feature has been removed from the cluster
+func (c *Controller) UnregisterFeature(task concurrency.Task, name string) error {
+	if c == nil {
+		return scerr.InvalidInstanceError()
+	}
+	if name == "" {
+		return scerr.InvalidParameterError("name", "cannot be empty string")
+	}
+
+	return c.UpdateMetadata(task, func() error {
+		return c.Properties.LockForWrite(property.FeaturesV1).ThenUse(func(clonable data.Clonable) error {
+			featuresV1 := clonable.(*clusterpropsv1.Features)
+			delete(featuresV1.Installed, name)
+			delete(featuresV1.Parameters, name)
+			return nil
+		})
+	})
+}
+
+// getNodesReadiness returns the readiness of the hosts known by the cluster manager, indexed by hostname,
+// or nil if the flavor cannot tell
+// Flavors without maker use Docker Swarm.
+func (b *foreman) getNodesReadiness(task concurrency.Task) (map[string]bool, error) {
+	if b.makers.GetNodesReadiness != nil {
+		return b.makers.GetNodesReadiness(task, b)
+	}
+	if f := b.cluster.GetIdentity(task).Flavor; f == flavor.K8S || f == flavor.K3S {
+		return nil, nil
+	}
+
+	selectedMaster, err := b.cluster.FindAvailableMaster(task)
+	if err != nil {
+		return nil, err
+	}
+	cmd := "docker node ls --format '{{.Hostname}} {{.Status}}'"
+	retcode, stdout, stderr, err := client.New().SSH.Run(selectedMaster, cmd, outputs.COLLECT, client.DefaultConnectionTimeout, client.DefaultExecutionTimeout)
+	if err != nil {
+		return nil, err
+	}
+	if retcode != 0 {
+		return nil, fmt.Errorf("error listing swarm nodes: errorcode %d, %s", retcode, stderr)
+	}
+	readiness := map[string]bool{}
+	for _, line := range strings.Split(stdout, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 {
+			readiness[fields[0]] = fields[1] == "Ready"
+		}
+	}
+	return readiness, nil
+}
@@ -0,0 +1,338 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package control
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	pb "github.com/CS-SI/SafeScale/lib"
+	clusterpropsv1 "github.com/CS-SI/SafeScale/lib/server/cluster/control/properties/v1"
+	"github.com/CS-SI/SafeScale/lib/server/cluster/enums/property"
+	srvutils "github.com/CS-SI/SafeScale/lib/server/utils"
+	"github.com/CS-SI/SafeScale/lib/utils/concurrency"
+	"github.com/CS-SI/SafeScale/lib/utils/data"
+	"github.com/CS-SI/SafeScale/lib/utils/scerr"
+)
+
+// DefaultNodePoolName is the name of the pool containing the nodes created with the cluster
+const DefaultNodePoolName = "default"
+
+// ensureNodePools creates the default node pool from cluster defaults and existing nodes
+// if the cluster has been created before node pools were introduced
+func (c *Controller) ensureNodePools(task concurrency.Task) error {
+	c.RLock(task)
+	found := c.Properties.Lookup(property.NodePoolsV1)
+	c.RUnlock(task)
+	if found {
+		return nil
+	}
+
+	hostImage, nodeDef, err := c.getImageAndNodeDescriptionUsedInClusterFromMetadata(&task)
+	if err != nil {
+		return err
+	}
+	nodeIDs := c.ListNodeIDs(task)
+	return c.UpdateMetadata(task, func() error {
+		return c.Properties.LockForWrite(property.NodePoolsV1).ThenUse(func(clonable data.Clonable) error {
+			nodePoolsV1 := clonable.(*clusterpropsv1.NodePools)
+			if _, ok := nodePoolsV1.ByName[DefaultNodePoolName]; !ok {
+				nodePoolsV1.ByName[DefaultNodePoolName] = &clusterpropsv1.NodePool{
+					Name:   DefaultNodePoolName,
+					Sizing: srvutils.FromPBHostSizing(*nodeDef.Sizing),
+					Image:  hostImage,
+					Count:  len(nodeIDs),
+					Nodes:  nodeIDs,
+				}
+			}
+			return nil
+		})
+	})
+}
+
+// getNodePool returns a copy of the node pool named 'name'
+func (c *Controller) getNodePool(task concurrency.Task, name string) (pool *clusterpropsv1.NodePool, err error) {
+	err = c.ensureNodePools(task)
+	if err != nil {
+		return nil, err
+	}
+
+	c.RLock(task)
+	defer c.RUnlock(task)
+	err = c.Properties.LockForRead(property.NodePoolsV1).ThenUse(func(clonable data.Clonable) error {
+		found, ok := clonable.(*clusterpropsv1.NodePools).ByName[name]
+		if !ok {
+			return scerr.NotFoundError(fmt.Sprintf("failed to find node pool '%s' in cluster '%s'", name, c.Name))
+		}
+		pool = found.Clone()
+		return nil
+	})
+	return pool, err
+}
+
+// updateNodePool applies updatefn to the node pool named 'name' and saves cluster metadata
+func (c *Controller) updateNodePool(task concurrency.Task, name string, updatefn func(*clusterpropsv1.NodePool)) error {
+	return c.UpdateMetadata(task, func() error {
+		return c.Properties.LockForWrite(property.NodePoolsV1).ThenUse(func(clonable data.Clonable) error {
+			pool, ok := clonable.(*clusterpropsv1.NodePools).ByName[name]
+			if !ok {
+				return scerr.NotFoundError(fmt.Sprintf("failed to find node pool '%s' in cluster '%s'", name, c.Name))
+			}
+			updatefn(pool)
+			return nil
+		})
+	})
+}
+
+// ListNodePools returns the node pools of the cluster, sorted by name
+func (c *Controller) ListNodePools(task concurrency.Task) (list []*clusterpropsv1.NodePool, err error) {
+	if c == nil {
+		return nil, scerr.InvalidInstanceError()
+	}
+	if task == nil {
+		task = concurrency.RootTask()
+	}
+
+	tracer := concurrency.NewTracer(task, "", true).GoingIn()
+	defer tracer.OnExitTrace()()
+	defer scerr.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	err = c.ensureNodePools(task)
+	if err != nil {
+		return nil, err
+	}
+
+	c.RLock(task)
+	defer c.RUnlock(task)
+	err = c.Properties.LockForRead(property.NodePoolsV1).ThenUse(func(clonable data.Clonable) error {
+		for _, v := range clonable.(*clusterpropsv1.NodePools).ByName {
+			list = append(list, v.Clone())
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	return list, nil
+}
+
+// AddNodePool creates the node pool 'name' from the definition 'req' (completed with cluster defaults),
+// then adds 'count' nodes in it. Labels and taints are set on the nodes if the flavor supports it.
+// If tenant is set and isn't the main tenant of the cluster, the nodes are hosted by this tenant, in a site
+// connected to the network of the cluster (created with the network 'cidr' if it doesn't exist yet).
+func (c *Controller) AddNodePool(
+	task concurrency.Task, name string, count int, req *pb.HostDefinition, labels map[string]string, taints []string,
+	tenant, cidr string,
+) (hosts []string, err error) {
+	if c == nil {
+		return nil, scerr.InvalidInstanceError()
+	}
+	if name == "" {
+		return nil, scerr.InvalidParameterError("name", "cannot be empty string")
+	}
+	if count < 0 {
+		return nil, scerr.InvalidParameterError("count", "must be an int >= 0")
+	}
+	for _, t := range taints {
+		if !strings.Contains(t, ":") {
+			return nil, scerr.InvalidParameterError("taints", fmt.Sprintf("'%s' is not in format 'key[=value]:effect'", t))
+		}
+	}
+	if task == nil {
+		task = concurrency.RootTask()
+	}
+
+	tracer := concurrency.NewTracer(task, fmt.Sprintf("('%s', %d, '%s')", name, count, tenant), true).GoingIn()
+	defer tracer.OnExitTrace()()
+	defer scerr.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	err = c.ensureNodePools(task)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = c.getNodePool(task, name); err == nil {
+		return nil, scerr.DuplicateError(fmt.Sprintf("node pool '%s' already exists in cluster '%s'", name, c.Name))
+	}
+
+	site, err := c.ensureSite(task, tenant, cidr)
+	if err != nil {
+		return nil, err
+	}
+	if site == nil {
+		tenant = ""
+	}
+	defer func() {
+		if err != nil && tenant != "" {
+			derr := c.removeUnusedSite(task, tenant)
+			err = scerr.AddConsequence(err, derr)
+		}
+	}()
+
+	hostImage, nodeDef, err := c.getImageAndNodeDescriptionUsedInClusterFromMetadata(&task)
+	if err != nil {
+		return nil, err
+	}
+	nodeDef = complementHostDefinition(req, *nodeDef)
+	if nodeDef.ImageId == "" {
+		nodeDef.ImageId = hostImage
+	}
+
+	pool := &clusterpropsv1.NodePool{
+		Name:   name,
+		Sizing: srvutils.FromPBHostSizing(*nodeDef.Sizing),
+		Image:  nodeDef.ImageId,
+		Public: nodeDef.Public,
+		Labels: labels,
+		Taints: taints,
+		Tenant: tenant,
+	}
+	err = c.UpdateMetadata(task, func() error {
+		return c.Properties.LockForWrite(property.NodePoolsV1).ThenUse(func(clonable data.Clonable) error {
+			nodePoolsV1 := clonable.(*clusterpropsv1.NodePools)
+			if _, ok := nodePoolsV1.ByName[name]; ok {
+				return scerr.DuplicateError(fmt.Sprintf("node pool '%s' already exists in cluster '%s'", name, c.Name))
+			}
+			nodePoolsV1.ByName[name] = pool
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if count == 0 {
+		return nil, nil
+	}
+	hosts, err = c.AddNodes(task, name, count, nil)
+	if err != nil {
+		derr := c.UpdateMetadata(task, func() error {
+			return c.Properties.LockForWrite(property.NodePoolsV1).ThenUse(func(clonable data.Clonable) error {
+				delete(clonable.(*clusterpropsv1.NodePools).ByName, name)
+				return nil
+			})
+		})
+		err = scerr.AddConsequence(err, derr)
+		return nil, err
+	}
+	return hosts, nil
+}
+
+// ResizeNodePool adds or deletes (the last added first) nodes of the node pool 'name' to reach 'count' nodes
+// Returns the IDs of the nodes added, if any.
+func (c *Controller) ResizeNodePool(task concurrency.Task, name string, count int) (hosts []string, err error) {
+	if c == nil {
+		return nil, scerr.InvalidInstanceError()
+	}
+	if name == "" {
+		return nil, scerr.InvalidParameterError("name", "cannot be empty string")
+	}
+	if count < 0 {
+		return nil, scerr.InvalidParameterError("count", "must be an int >= 0")
+	}
+	if task == nil {
+		task = concurrency.RootTask()
+	}
+
+	tracer := concurrency.NewTracer(task, fmt.Sprintf("('%s', %d)", name, count), true).GoingIn()
+	defer tracer.OnExitTrace()()
+	defer scerr.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	pool, err := c.getNodePool(task, name)
+	if err != nil {
+		return nil, err
+	}
+
+	current := len(pool.Nodes)
+	switch {
+	case count > current:
+		hosts, err = c.AddNodes(task, name, count-current, nil)
+		if err != nil {
+			return nil, err
+		}
+	case count < current:
+		selectedMaster, err := c.FindAvailableMaster(task)
+		if err != nil {
+			return nil, err
+		}
+		var errs []string
+		for i := 0; i < current-count; i++ {
+			err = c.DeleteLastNode(task, name, selectedMaster)
+			if err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+		if len(errs) > 0 {
+			return nil, fmt.Errorf("errors occurred on resize of node pool '%s': %s", name, strings.Join(errs, "\n"))
+		}
+	}
+
+	// Makes sure the count wanted is recorded, even if the pool was not in sync
+	err = c.updateNodePool(task, name, func(np *clusterpropsv1.NodePool) {
+		np.Count = count
+	})
+	if err != nil {
+		return nil, err
+	}
+	return hosts, nil
+}
+
+// DeleteNodePool deletes the nodes of the node pool 'name', then the pool itself
+// The default pool cannot be deleted.
+func (c *Controller) DeleteNodePool(task concurrency.Task, name string) (err error) {
+	if c == nil {
+		return scerr.InvalidInstanceError()
+	}
+	if name == "" {
+		return scerr.InvalidParameterError("name", "cannot be empty string")
+	}
+	if name == DefaultNodePoolName {
+		return scerr.InvalidParameterError("name", "cannot delete the default node pool")
+	}
+	if task == nil {
+		task = concurrency.RootTask()
+	}
+
+	tracer := concurrency.NewTracer(task, fmt.Sprintf("('%s')", name), true).GoingIn()
+	defer tracer.OnExitTrace()()
+	defer scerr.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	pool, err := c.getNodePool(task, name)
+	if err != nil {
+		return err
+	}
+	_, err = c.ResizeNodePool(task, name, 0)
+	if err != nil {
+		return err
+	}
+
+	err = c.UpdateMetadata(task, func() error {
+		return c.Properties.LockForWrite(property.NodePoolsV1).ThenUse(func(clonable data.Clonable) error {
+			delete(clonable.(*clusterpropsv1.NodePools).ByName, name)
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	// The site hosting the pool is removed if no other pool uses it
+	return c.removeUnusedSite(task, pool.Tenant)
+}
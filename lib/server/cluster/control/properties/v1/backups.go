@@ -0,0 +1,108 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package propertiesv1
+
+import (
+	"time"
+
+	"github.com/CS-SI/SafeScale/lib/server/cluster/enums/property"
+	"github.com/CS-SI/SafeScale/lib/utils/data"
+	"github.com/CS-SI/SafeScale/lib/utils/serialize"
+)
+
+// Backup describes a backup of the control plane of the cluster stored in Object Storage
+// not FROZEN yet
+// Note: if tagged as FROZEN, must not be changed ever.
+//       Create a new version instead with updated/additional fields
+type Backup struct {
+	ID      string    `json:"id"`                // ID of the backup, also used to name the object
+	Bucket  string    `json:"bucket"`            // Bucket containing the backup
+	Object  string    `json:"object"`            // Object containing the encrypted backup
+	Size    int64     `json:"size"`              // Size of the object
+	Master  string    `json:"master"`            // Master is the name of the master saved
+	Version string    `json:"version,omitempty"` // Version of the cluster manager when the backup was made
+	Created time.Time `json:"created"`           // Created is the date of the backup
+}
+
+// Clone returns a copy of the backup
+func (b *Backup) Clone() *Backup {
+	out := *b
+	return &out
+}
+
+// Backups contains the backups of the control plane of the cluster, sorted by date of creation
+// not FROZEN yet
+// Note: if tagged as FROZEN, must not be changed ever.
+//       Create a new version instead with updated/additional fields
+type Backups struct {
+	Key  string    `json:"key,omitempty"` // Key is the encryption key of the backups, encoded in base64
+	List []*Backup `json:"list"`          // List of the backups, the oldest first
+}
+
+func newBackups() *Backups {
+	return &Backups{
+		List: []*Backup{},
+	}
+}
+
+// Content ...
+// satisfies interface data.Clonable
+func (b *Backups) Content() data.Clonable {
+	return b
+}
+
+// Clone ...
+// satisfies interface data.Clonable
+func (b *Backups) Clone() data.Clonable {
+	return newBackups().Replace(b)
+}
+
+// Replace ...
+// satisfies interface data.Clonable
+func (b *Backups) Replace(p data.Clonable) data.Clonable {
+	src := p.(*Backups)
+	b.Key = src.Key
+	b.List = make([]*Backup, 0, len(src.List))
+	for _, v := range src.List {
+		b.List = append(b.List, v.Clone())
+	}
+	return b
+}
+
+// Find returns the backup identified by id, or nil if not found
+func (b *Backups) Find(id string) *Backup {
+	for _, v := range b.List {
+		if v.ID == id {
+			return v
+		}
+	}
+	return nil
+}
+
+// Remove removes the backup identified by id from the list
+func (b *Backups) Remove(id string) {
+	for i, v := range b.List {
+		if v.ID == id {
+			b.List = append(b.List[:i], b.List[i+1:]...)
+			return
+		}
+	}
+}
+
+func init() {
+	serialize.PropertyTypeRegistry.Register("clusters", property.BackupsV1, newBackups())
+}
@@ -0,0 +1,39 @@
+package propertiesv1
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackups_Clone(t *testing.T) {
+	ct := newBackups()
+	ct.Key = "c2VjcmV0"
+	ct.List = append(ct.List, &Backup{ID: "20200520T091203Z", Bucket: "bucket", Object: "cluster-backups/mycluster/20200520T091203Z", Master: "mycluster-master-1"})
+
+	clonedCt, ok := ct.Clone().(*Backups)
+	if !ok {
+		t.Fail()
+	}
+
+	assert.Equal(t, ct, clonedCt)
+	clonedCt.Find("20200520T091203Z").Size = 1024
+
+	areEqual := reflect.DeepEqual(ct, clonedCt)
+	if areEqual {
+		t.Error("It's a shallow clone !")
+		t.Fail()
+	}
+	assert.Equal(t, int64(0), ct.Find("20200520T091203Z").Size)
+}
+
+func TestBackups_Remove(t *testing.T) {
+	ct := newBackups()
+	ct.List = append(ct.List, &Backup{ID: "a"}, &Backup{ID: "b"}, &Backup{ID: "c"})
+
+	ct.Remove("b")
+	assert.Equal(t, 2, len(ct.List))
+	assert.Nil(t, ct.Find("b"))
+	assert.NotNil(t, ct.Find("c"))
+}
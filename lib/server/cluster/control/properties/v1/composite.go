@@ -27,13 +27,31 @@ import (
 // Note: if tagged as FROZEN, must not be changed ever.
 //       Create a new version instead with needed supplemental fields
 type Composite struct {
-	// Array of tenants hosting a multi-tenant cluster (multi starting from 1)
+	// Array of tenants hosting a multi-tenant cluster (multi starting from 1); the first one is the main tenant,
+	// hosting the network, the gateways and the masters of the cluster
 	Tenants []string `json:"tenants"`
+	// Sites contains the parts of the cluster hosted by the other tenants, indexed by tenant name
+	Sites map[string]*Site `json:"sites,omitempty"`
+	// HubKey is the public key of the site-to-site tunnel end on the primary gateway of the main tenant
+	HubKey string `json:"hub_key,omitempty"`
+}
+
+// Site describes the part of a multi-tenant cluster hosted by a tenant other than the main one;
+// its network is connected to the network of the cluster by a site-to-site tunnel between the gateways
+type Site struct {
+	Tenant    string `json:"tenant"`               // Tenant hosting the site
+	NetworkID string `json:"network_id"`           // ID of the network of the site
+	CIDR      string `json:"cidr"`                 // CIDR of the network of the site, not overlapping the other ones
+	GatewayID string `json:"gateway_id"`           // ID of the gateway of the site
+	GatewayIP string `json:"gateway_ip"`           // Private IP of the gateway of the site
+	PublicIP  string `json:"public_ip"`            // Public IP of the gateway of the site, end of the tunnel
+	TunnelKey string `json:"tunnel_key,omitempty"` // Public key of the tunnel end on the gateway of the site
 }
 
 func newComposite() *Composite {
 	return &Composite{
 		Tenants: []string{},
+		Sites:   map[string]*Site{},
 	}
 }
 
@@ -55,6 +73,12 @@ func (c *Composite) Replace(p data.Clonable) data.Clonable {
 	src := p.(*Composite)
 	c.Tenants = make([]string, len(src.Tenants))
 	copy(c.Tenants, src.Tenants)
+	c.Sites = make(map[string]*Site, len(src.Sites))
+	for k, v := range src.Sites {
+		site := *v
+		c.Sites[k] = &site
+	}
+	c.HubKey = src.HubKey
 	return c
 }
 
@@ -25,3 +25,23 @@ func TestComposite_Clone(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestComposite_CloneSites(t *testing.T) {
+	ct := newComposite()
+	ct.Tenants = append(ct.Tenants, "google", "amazon")
+	ct.Sites["amazon"] = &Site{Tenant: "amazon", CIDR: "192.168.100.0/24", PublicIP: "1.2.3.4"}
+
+	clonedCt, ok := ct.Clone().(*Composite)
+	if !ok {
+		t.Fail()
+	}
+
+	assert.Equal(t, ct, clonedCt)
+	clonedCt.Sites["amazon"].CIDR = "192.168.200.0/24"
+
+	areEqual := reflect.DeepEqual(ct, clonedCt)
+	if areEqual {
+		t.Error("It's a shallow clone !")
+		t.Fail()
+	}
+}
@@ -27,8 +27,11 @@ import (
 // Note: if tagged as FROZEN, must not be changed ever.
 //       Create a new version instead with updated/additional fields
 type Features struct {
-	// Installed ...
+	// Installed contains the installed features, indexed by name, with their version as value
+	// (empty string if unknown)
 	Installed map[string]string `json:"installed"`
+	// Parameters contains the values of the parameters used to install (or upgrade) each feature, indexed by feature name
+	Parameters map[string]map[string]string `json:"parameters,omitempty"`
 	// Disabled keeps track of features normally automatically added with cluster creation,
 	// but explicitly disabled; if a disabled feature is added, must be removed from this property
 	Disabled map[string]struct{} `json:"disabled"`
@@ -36,8 +39,9 @@ type Features struct {
 
 func newFeatures() *Features {
 	return &Features{
-		Installed: map[string]string{},
-		Disabled:  map[string]struct{}{},
+		Installed:  map[string]string{},
+		Disabled:   map[string]struct{}{},
+		Parameters: map[string]map[string]string{},
 	}
 }
 
@@ -65,6 +69,14 @@ func (f *Features) Replace(p data.Clonable) data.Clonable {
 	for k, v := range src.Disabled {
 		f.Disabled[k] = v
 	}
+	f.Parameters = make(map[string]map[string]string, len(src.Parameters))
+	for k, v := range src.Parameters {
+		params := make(map[string]string, len(v))
+		for pk, pv := range v {
+			params[pk] = pv
+		}
+		f.Parameters[k] = params
+	}
 	return f
 }
 
@@ -10,6 +10,7 @@ func TestFeatures_Clone(t *testing.T) {
 	ct := newFeatures()
 	ct.Installed["fair"] = "something"
 	ct.Disabled["kind"] = struct{}{}
+	ct.Parameters["fair"] = map[string]string{"Version": "1.0"}
 
 	clonedCt, ok := ct.Clone().(*Features)
 	if !ok {
@@ -18,6 +19,7 @@ func TestFeatures_Clone(t *testing.T) {
 
 	assert.Equal(t, ct, clonedCt)
 	clonedCt.Installed["fair"] = "commitment"
+	clonedCt.Parameters["fair"]["Version"] = "2.0"
 
 	areEqual := reflect.DeepEqual(ct, clonedCt)
 	if areEqual {
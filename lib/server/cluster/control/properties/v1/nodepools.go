@@ -0,0 +1,107 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package propertiesv1
+
+import (
+	"github.com/CS-SI/SafeScale/lib/server/cluster/enums/property"
+	"github.com/CS-SI/SafeScale/lib/server/iaas/resources"
+	"github.com/CS-SI/SafeScale/lib/utils/data"
+	"github.com/CS-SI/SafeScale/lib/utils/serialize"
+)
+
+// NodePool describes a group of nodes of the cluster created from the same definition
+// not FROZEN yet
+// Note: if tagged as FROZEN, must not be changed ever.
+//       Create a new version instead with updated/additional fields
+type NodePool struct {
+	Name   string                       `json:"name"`             // Name of the pool
+	Sizing resources.SizingRequirements `json:"sizing"`           // Sizing of the nodes of the pool
+	Image  string                       `json:"image"`            // Image used to create the nodes of the pool
+	Public bool                         `json:"public,omitempty"` // Public tells if the nodes of the pool have a public IP
+	Count  int                          `json:"count"`            // Count is the number of nodes wanted in the pool
+	Labels map[string]string            `json:"labels,omitempty"` // Labels to set on the nodes (if the flavor supports it)
+	Taints []string                     `json:"taints,omitempty"` // Taints to set on the nodes, as 'key=value:effect' (if the flavor supports it)
+	Nodes  []string                     `json:"nodes,omitempty"`  // Nodes contains the IDs of the nodes of the pool, in creation order
+	Tenant string                       `json:"tenant,omitempty"` // Tenant hosting the nodes of the pool, if not the main tenant of the cluster
+}
+
+// Clone returns a deep copy of the pool
+func (np *NodePool) Clone() *NodePool {
+	out := *np
+	out.Labels = make(map[string]string, len(np.Labels))
+	for k, v := range np.Labels {
+		out.Labels[k] = v
+	}
+	out.Taints = make([]string, len(np.Taints))
+	copy(out.Taints, np.Taints)
+	out.Nodes = make([]string, len(np.Nodes))
+	copy(out.Nodes, np.Nodes)
+	return &out
+}
+
+// NodePools contains the node pools of the cluster
+// not FROZEN yet
+// Note: if tagged as FROZEN, must not be changed ever.
+//       Create a new version instead with updated/additional fields
+type NodePools struct {
+	ByName map[string]*NodePool `json:"by_name"` // ByName contains the pools indexed by their name
+}
+
+func newNodePools() *NodePools {
+	return &NodePools{
+		ByName: map[string]*NodePool{},
+	}
+}
+
+// FindNode returns the pool containing the node identified by hostID, or nil if not found
+func (np *NodePools) FindNode(hostID string) *NodePool {
+	for _, pool := range np.ByName {
+		for _, id := range pool.Nodes {
+			if id == hostID {
+				return pool
+			}
+		}
+	}
+	return nil
+}
+
+// Content ...
+// satisfies interface data.Clonable
+func (np *NodePools) Content() data.Clonable {
+	return np
+}
+
+// Clone ...
+// satisfies interface data.Clonable
+func (np *NodePools) Clone() data.Clonable {
+	return newNodePools().Replace(np)
+}
+
+// Replace ...
+// satisfies interface data.Clonable
+func (np *NodePools) Replace(p data.Clonable) data.Clonable {
+	src := p.(*NodePools)
+	np.ByName = make(map[string]*NodePool, len(src.ByName))
+	for k, v := range src.ByName {
+		np.ByName[k] = v.Clone()
+	}
+	return np
+}
+
+func init() {
+	serialize.PropertyTypeRegistry.Register("clusters", property.NodePoolsV1, newNodePools())
+}
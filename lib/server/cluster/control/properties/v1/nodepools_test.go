@@ -0,0 +1,39 @@
+package propertiesv1
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNodePools_Clone(t *testing.T) {
+	pool := &NodePool{
+		Name:   "gpu",
+		Image:  "Ubuntu 18.04",
+		Count:  1,
+		Labels: map[string]string{"accelerator": "gpu"},
+		Taints: []string{"gpu=true:NoSchedule"},
+		Nodes:  []string{"abcdef"},
+	}
+	ct := newNodePools()
+	ct.ByName[pool.Name] = pool
+
+	clonedCt, ok := ct.Clone().(*NodePools)
+	if !ok {
+		t.Fail()
+	}
+
+	assert.Equal(t, ct, clonedCt)
+	clonedCt.ByName["gpu"].Labels["accelerator"] = "none"
+	clonedCt.ByName["gpu"].Nodes[0] = "ghijkl"
+
+	areEqual := reflect.DeepEqual(ct, clonedCt)
+	if areEqual {
+		t.Error("It's a shallow clone !")
+		t.Fail()
+	}
+	assert.Equal(t, "gpu", ct.ByName["gpu"].Labels["accelerator"])
+	assert.Equal(t, pool, ct.FindNode("abcdef"))
+	assert.Nil(t, ct.FindNode("ghijkl"))
+}
@@ -0,0 +1,87 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package propertiesv1
+
+import (
+	"time"
+
+	"github.com/CS-SI/SafeScale/lib/server/cluster/enums/nodestate"
+	"github.com/CS-SI/SafeScale/lib/server/cluster/enums/property"
+	"github.com/CS-SI/SafeScale/lib/utils/data"
+	"github.com/CS-SI/SafeScale/lib/utils/serialize"
+)
+
+// NodeHealth describes the result of the last health checks of a master or a node
+// not FROZEN yet
+// Note: if tagged as FROZEN, must not be changed ever.
+//       Create a new version instead with updated/additional fields
+type NodeHealth struct {
+	ID        string         `json:"id"`               // ID of the host
+	Name      string         `json:"name"`             // Name of the host
+	Master    bool           `json:"master,omitempty"` // Master tells if the host is a master
+	State     nodestate.Enum `json:"state"`            // State of the node deduced from the checks
+	Failures  int            `json:"failures"`         // Failures is the number of consecutive failed checks
+	Reason    string         `json:"reason,omitempty"` // Reason of the last failure
+	LastCheck time.Time      `json:"last_check"`       // LastCheck is the date of the last check
+}
+
+// Clone returns a copy of the node health
+func (nh *NodeHealth) Clone() *NodeHealth {
+	out := *nh
+	return &out
+}
+
+// NodesHealth contains the health of the masters and the nodes of the cluster
+// not FROZEN yet
+// Note: if tagged as FROZEN, must not be changed ever.
+//       Create a new version instead with updated/additional fields
+type NodesHealth struct {
+	ByID map[string]*NodeHealth `json:"by_id"` // ByID contains the health of the hosts indexed by their ID
+}
+
+func newNodesHealth() *NodesHealth {
+	return &NodesHealth{
+		ByID: map[string]*NodeHealth{},
+	}
+}
+
+// Content ...
+// satisfies interface data.Clonable
+func (nh *NodesHealth) Content() data.Clonable {
+	return nh
+}
+
+// Clone ...
+// satisfies interface data.Clonable
+func (nh *NodesHealth) Clone() data.Clonable {
+	return newNodesHealth().Replace(nh)
+}
+
+// Replace ...
+// satisfies interface data.Clonable
+func (nh *NodesHealth) Replace(p data.Clonable) data.Clonable {
+	src := p.(*NodesHealth)
+	nh.ByID = make(map[string]*NodeHealth, len(src.ByID))
+	for k, v := range src.ByID {
+		nh.ByID[k] = v.Clone()
+	}
+	return nh
+}
+
+func init() {
+	serialize.PropertyTypeRegistry.Register("clusters", property.NodesHealthV1, newNodesHealth())
+}
@@ -0,0 +1,38 @@
+package propertiesv1
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/CS-SI/SafeScale/lib/server/cluster/enums/nodestate"
+)
+
+func TestNodesHealth_Clone(t *testing.T) {
+	health := &NodeHealth{
+		ID:       "abcdef",
+		Name:     "node-1",
+		State:    nodestate.Disabled,
+		Failures: 2,
+		Reason:   "unreachable by SSH",
+	}
+	ct := newNodesHealth()
+	ct.ByID[health.ID] = health
+
+	clonedCt, ok := ct.Clone().(*NodesHealth)
+	if !ok {
+		t.Fail()
+	}
+
+	assert.Equal(t, ct, clonedCt)
+	clonedCt.ByID["abcdef"].State = nodestate.Started
+	clonedCt.ByID["abcdef"].Failures = 0
+
+	areEqual := reflect.DeepEqual(ct, clonedCt)
+	if areEqual {
+		t.Error("It's a shallow clone !")
+		t.Fail()
+	}
+	assert.Equal(t, nodestate.Disabled, ct.ByID["abcdef"].State)
+}
@@ -0,0 +1,61 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package propertiesv1
+
+import (
+	"github.com/CS-SI/SafeScale/lib/server/cluster/enums/property"
+	"github.com/CS-SI/SafeScale/lib/utils/data"
+	"github.com/CS-SI/SafeScale/lib/utils/serialize"
+)
+
+// Nomad contains the secrets of the Consul and Nomad agents of a cluster of flavor NOMAD
+// not FROZEN yet
+// Note: if tagged as FROZEN, must not be changed ever.
+//       Create a new version instead with needed supplemental fields
+type Nomad struct {
+	ConsulGossipKey string `json:"consul_gossip_key,omitempty"` // base64 key encrypting the gossip traffic of Consul
+	NomadGossipKey  string `json:"nomad_gossip_key,omitempty"`  // base64 key encrypting the gossip traffic of the Nomad servers
+	ACLAccessorID   string `json:"acl_accessor_id,omitempty"`   // accessor ID of the bootstrap token of the ACL system of Nomad
+	ACLSecretID     string `json:"acl_secret_id,omitempty"`     // secret ID of the bootstrap token of the ACL system of Nomad (management token)
+}
+
+func newNomad() *Nomad {
+	return &Nomad{}
+}
+
+// Content ...
+// satisfies interface data.Clonable
+func (n *Nomad) Content() data.Clonable {
+	return n
+}
+
+// Clone ...
+// satisfies interface data.Clonable
+func (n *Nomad) Clone() data.Clonable {
+	return newNomad().Replace(n)
+}
+
+// Replace ...
+// satisfies interface data.Clonable
+func (n *Nomad) Replace(p data.Clonable) data.Clonable {
+	*n = *p.(*Nomad)
+	return n
+}
+
+func init() {
+	serialize.PropertyTypeRegistry.Register("clusters", property.NomadV1, &Nomad{})
+}
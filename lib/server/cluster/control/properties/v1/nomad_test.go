@@ -0,0 +1,23 @@
+package propertiesv1
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNomad_Clone(t *testing.T) {
+	ct := newNomad()
+	ct.ConsulGossipKey = "cg8StVXbQJ0gPvMd9o7yrg=="
+	ct.NomadGossipKey = "Q2K7pYh1a2Jf4m6Sb3nA0w=="
+	ct.ACLSecretID = "9a0d8b3c-5a4f-4d6e-8f2a-1b3c5d7e9f00"
+
+	clonedCt, ok := ct.Clone().(*Nomad)
+	if !ok {
+		t.Fail()
+	}
+
+	assert.Equal(t, ct, clonedCt)
+	clonedCt.ACLSecretID = "Test"
+	assert.NotEqual(t, ct.ACLSecretID, clonedCt.ACLSecretID)
+}
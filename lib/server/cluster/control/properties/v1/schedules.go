@@ -0,0 +1,130 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package propertiesv1
+
+import (
+	"time"
+
+	"github.com/CS-SI/SafeScale/lib/server/cluster/enums/property"
+	"github.com/CS-SI/SafeScale/lib/utils/data"
+	"github.com/CS-SI/SafeScale/lib/utils/serialize"
+)
+
+// AutoscaleSchedule describes the autoscaling policy of a node pool
+// not FROZEN yet
+// Note: if tagged as FROZEN, must not be changed ever.
+//       Create a new version instead with updated/additional fields
+type AutoscaleSchedule struct {
+	MinNodes      int           `json:"min_nodes"`       // MinNodes is the minimum number of nodes in the pool
+	MaxNodes      int           `json:"max_nodes"`       // MaxNodes is the maximum number of nodes in the pool
+	Cooldown      time.Duration `json:"cooldown"`        // Cooldown is the minimum delay between 2 scaling actions
+	Interval      time.Duration `json:"interval"`        // Interval is the delay between 2 evaluations of the policy
+	ScaleUpLoad   float64       `json:"scale_up_load"`   // ScaleUpLoad is the average load above which a node is added
+	ScaleDownLoad float64       `json:"scale_down_load"` // ScaleDownLoad is the average load under which a node is removed
+	Created       time.Time     `json:"created"`         // Created is the date the policy was enabled
+}
+
+// Clone returns a copy of the autoscale schedule
+func (as *AutoscaleSchedule) Clone() *AutoscaleSchedule {
+	out := *as
+	return &out
+}
+
+// HealthCheckSchedule describes the periodic health check of the cluster
+// not FROZEN yet
+// Note: if tagged as FROZEN, must not be changed ever.
+//       Create a new version instead with updated/additional fields
+type HealthCheckSchedule struct {
+	Interval    time.Duration `json:"interval"`     // Interval is the delay between 2 checks
+	Repair      bool          `json:"repair"`       // Repair tells if the nodes failing MaxFailures checks are replaced
+	MaxFailures int           `json:"max_failures"` // MaxFailures is the number of consecutive failed checks before repair
+	Created     time.Time     `json:"created"`      // Created is the date the health check was enabled
+}
+
+// Clone returns a copy of the health check schedule
+func (hc *HealthCheckSchedule) Clone() *HealthCheckSchedule {
+	out := *hc
+	return &out
+}
+
+// BackupSchedule describes the periodic backup of the control plane of the cluster
+// not FROZEN yet
+// Note: if tagged as FROZEN, must not be changed ever.
+//       Create a new version instead with updated/additional fields
+type BackupSchedule struct {
+	Interval time.Duration `json:"interval"`         // Interval is the delay between 2 backups
+	Keep     int           `json:"keep"`             // Keep is the number of backups kept
+	Bucket   string        `json:"bucket,omitempty"` // Bucket is the bucket receiving the backups
+	Created  time.Time     `json:"created"`          // Created is the date the periodic backup was enabled
+}
+
+// Clone returns a copy of the backup schedule
+func (bs *BackupSchedule) Clone() *BackupSchedule {
+	out := *bs
+	return &out
+}
+
+// Schedules contains the periodic tasks run by safescaled on the cluster, restarted when safescaled starts
+// not FROZEN yet
+// Note: if tagged as FROZEN, must not be changed ever.
+//       Create a new version instead with updated/additional fields
+type Schedules struct {
+	Autoscalers map[string]*AutoscaleSchedule `json:"autoscalers,omitempty"`  // Autoscalers contains the autoscaling policies indexed by node pool
+	HealthCheck *HealthCheckSchedule          `json:"health_check,omitempty"` // HealthCheck is the periodic health check, nil if disabled
+	Backup      *BackupSchedule               `json:"backup,omitempty"`       // Backup is the periodic backup, nil if disabled
+}
+
+func newSchedules() *Schedules {
+	return &Schedules{
+		Autoscalers: map[string]*AutoscaleSchedule{},
+	}
+}
+
+// Content ...
+// satisfies interface data.Clonable
+func (s *Schedules) Content() data.Clonable {
+	return s
+}
+
+// Clone ...
+// satisfies interface data.Clonable
+func (s *Schedules) Clone() data.Clonable {
+	return newSchedules().Replace(s)
+}
+
+// Replace ...
+// satisfies interface data.Clonable
+func (s *Schedules) Replace(p data.Clonable) data.Clonable {
+	src := p.(*Schedules)
+	s.Autoscalers = make(map[string]*AutoscaleSchedule, len(src.Autoscalers))
+	for k, v := range src.Autoscalers {
+		s.Autoscalers[k] = v.Clone()
+	}
+	s.HealthCheck = nil
+	if src.HealthCheck != nil {
+		s.HealthCheck = src.HealthCheck.Clone()
+	}
+	s.Backup = nil
+	if src.Backup != nil {
+		s.Backup = src.Backup.Clone()
+	}
+	return s
+}
+
+func init() {
+	serialize.PropertyTypeRegistry.Register("clusters", property.SchedulesV1, newSchedules())
+}
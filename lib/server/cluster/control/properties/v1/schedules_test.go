@@ -0,0 +1,35 @@
+package propertiesv1
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSchedules_Clone(t *testing.T) {
+	ct := newSchedules()
+	ct.Autoscalers["gpu"] = &AutoscaleSchedule{MinNodes: 1, MaxNodes: 5, Interval: time.Minute, ScaleUpLoad: 0.8, ScaleDownLoad: 0.2}
+	ct.HealthCheck = &HealthCheckSchedule{Interval: time.Minute, Repair: true, MaxFailures: 3}
+	ct.Backup = &BackupSchedule{Interval: 24 * time.Hour, Keep: 7, Bucket: "backups"}
+
+	clonedCt, ok := ct.Clone().(*Schedules)
+	if !ok {
+		t.Fail()
+	}
+
+	assert.Equal(t, ct, clonedCt)
+	clonedCt.Autoscalers["gpu"].MaxNodes = 10
+	clonedCt.HealthCheck.Repair = false
+	clonedCt.Backup.Keep = 3
+
+	areEqual := reflect.DeepEqual(ct, clonedCt)
+	if areEqual {
+		t.Error("It's a shallow clone !")
+		t.Fail()
+	}
+	assert.Equal(t, 5, ct.Autoscalers["gpu"].MaxNodes)
+	assert.True(t, ct.HealthCheck.Repair)
+	assert.Equal(t, 7, ct.Backup.Keep)
+}
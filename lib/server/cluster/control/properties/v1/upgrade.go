@@ -0,0 +1,104 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package propertiesv1
+
+import (
+	"time"
+
+	"github.com/CS-SI/SafeScale/lib/server/cluster/enums/property"
+	"github.com/CS-SI/SafeScale/lib/server/cluster/enums/upgradestate"
+	"github.com/CS-SI/SafeScale/lib/utils/data"
+	"github.com/CS-SI/SafeScale/lib/utils/serialize"
+)
+
+// HostUpgrade describes the progress of the upgrade of a master or a node
+// not FROZEN yet
+// Note: if tagged as FROZEN, must not be changed ever.
+//       Create a new version instead with updated/additional fields
+type HostUpgrade struct {
+	ID              string            `json:"id"`                         // ID of the host
+	Name            string            `json:"name"`                       // Name of the host
+	Master          bool              `json:"master,omitempty"`           // Master tells if the host is a master
+	State           upgradestate.Enum `json:"state"`                      // State of the upgrade of the host
+	PreviousVersion string            `json:"previous_version,omitempty"` // PreviousVersion is the version of the platform before the upgrade
+	Error           string            `json:"error,omitempty"`            // Error is the reason of the failure of the upgrade
+	Updated         time.Time         `json:"updated"`                    // Updated is the date of the last change of State
+}
+
+// Clone returns a copy of the host upgrade
+func (hu *HostUpgrade) Clone() *HostUpgrade {
+	out := *hu
+	return &out
+}
+
+// Upgrade describes the last rolling upgrade of the cluster, allowing to resume it if interrupted
+// not FROZEN yet
+// Note: if tagged as FROZEN, must not be changed ever.
+//       Create a new version instead with updated/additional fields
+type Upgrade struct {
+	OSPatches bool              `json:"os_patches,omitempty"` // OSPatches tells if the OS packages are upgraded
+	Version   string            `json:"version,omitempty"`    // Version is the version of the platform (Kubernetes, Docker, ...) wanted
+	BatchSize int               `json:"batch_size"`           // BatchSize is the number of nodes upgraded at the same time
+	State     upgradestate.Enum `json:"state"`                // State of the upgrade (Upgrading while in progress, Done or Failed)
+	Started   time.Time         `json:"started"`              // Started is the date of the start of the upgrade
+	Ended     time.Time         `json:"ended,omitempty"`      // Ended is the date of the end of the upgrade
+	Hosts     []*HostUpgrade    `json:"hosts"`                // Hosts contains the progress of each host, in upgrade order
+}
+
+func newUpgrade() *Upgrade {
+	return &Upgrade{
+		Hosts: []*HostUpgrade{},
+	}
+}
+
+// Content ...
+// satisfies interface data.Clonable
+func (u *Upgrade) Content() data.Clonable {
+	return u
+}
+
+// Clone ...
+// satisfies interface data.Clonable
+func (u *Upgrade) Clone() data.Clonable {
+	return newUpgrade().Replace(u)
+}
+
+// Replace ...
+// satisfies interface data.Clonable
+func (u *Upgrade) Replace(p data.Clonable) data.Clonable {
+	src := p.(*Upgrade)
+	*u = *src
+	u.Hosts = make([]*HostUpgrade, 0, len(src.Hosts))
+	for _, v := range src.Hosts {
+		u.Hosts = append(u.Hosts, v.Clone())
+	}
+	return u
+}
+
+// FindHost returns the progress of the host identified by hostID, or nil if not found
+func (u *Upgrade) FindHost(hostID string) *HostUpgrade {
+	for _, v := range u.Hosts {
+		if v.ID == hostID {
+			return v
+		}
+	}
+	return nil
+}
+
+func init() {
+	serialize.PropertyTypeRegistry.Register("clusters", property.UpgradeV1, newUpgrade())
+}
@@ -0,0 +1,33 @@
+package propertiesv1
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/CS-SI/SafeScale/lib/server/cluster/enums/upgradestate"
+)
+
+func TestUpgrade_Clone(t *testing.T) {
+	ct := newUpgrade()
+	ct.Version = "1.17.4"
+	ct.BatchSize = 2
+	ct.State = upgradestate.Upgrading
+	ct.Hosts = append(ct.Hosts, &HostUpgrade{ID: "abcdef", Name: "master-1", Master: true, State: upgradestate.Done})
+
+	clonedCt, ok := ct.Clone().(*Upgrade)
+	if !ok {
+		t.Fail()
+	}
+
+	assert.Equal(t, ct, clonedCt)
+	clonedCt.FindHost("abcdef").State = upgradestate.Failed
+
+	areEqual := reflect.DeepEqual(ct, clonedCt)
+	if areEqual {
+		t.Error("It's a shallow clone !")
+		t.Fail()
+	}
+	assert.Equal(t, upgradestate.Done, ct.FindHost("abcdef").State)
+}
@@ -46,4 +46,24 @@ type Request struct {
 	NodesDef *pb.HostDefinition
 	// DisabledDefaultFeatures contains the list of features that should be installed by default but we don't want actually
 	DisabledDefaultFeatures map[string]struct{}
+	// NodePools contains the pools of nodes to add to the cluster once created, besides the default pool built from NodesDef
+	NodePools []NodePoolRequest
+}
+
+// NodePoolRequest defines what kind of node pool is wanted
+type NodePoolRequest struct {
+	// Name is the name of the pool
+	Name string
+	// Count is the number of nodes wanted in the pool
+	Count int
+	// NodeDef defines the sizing, the image and the public access of the nodes; missing values are taken from cluster defaults
+	NodeDef *pb.HostDefinition
+	// Labels contains the labels to set on the nodes (used only by flavors supporting it, like K8S)
+	Labels map[string]string
+	// Taints contains the taints to set on the nodes, in format 'key=value:effect' (used only by flavors supporting it, like K8S)
+	Taints []string
+	// Tenant is the tenant hosting the nodes, if not the tenant of the cluster
+	Tenant string
+	// CIDR is the network of the site hosting the nodes in Tenant, if the site doesn't exist yet
+	CIDR string
 }
@@ -0,0 +1,63 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package control
+
+import (
+	clusterpropsv1 "github.com/CS-SI/SafeScale/lib/server/cluster/control/properties/v1"
+	"github.com/CS-SI/SafeScale/lib/server/cluster/enums/property"
+	"github.com/CS-SI/SafeScale/lib/utils/concurrency"
+	"github.com/CS-SI/SafeScale/lib/utils/data"
+	"github.com/CS-SI/SafeScale/lib/utils/scerr"
+)
+
+// GetSchedules returns a copy of the periodic tasks recorded for the cluster
+func (c *Controller) GetSchedules(task concurrency.Task) (schedules *clusterpropsv1.Schedules, err error) {
+	if c == nil {
+		return nil, scerr.InvalidInstanceError()
+	}
+	if task == nil {
+		task = concurrency.RootTask()
+	}
+
+	c.RLock(task)
+	defer c.RUnlock(task)
+	err = c.Properties.LockForRead(property.SchedulesV1).ThenUse(func(clonable data.Clonable) error {
+		schedules = clonable.Clone().(*clusterpropsv1.Schedules)
+		return nil
+	})
+	return schedules, err
+}
+
+// UpdateSchedules applies updatefn to the periodic tasks recorded for the cluster and saves cluster metadata
+func (c *Controller) UpdateSchedules(task concurrency.Task, updatefn func(*clusterpropsv1.Schedules)) error {
+	if c == nil {
+		return scerr.InvalidInstanceError()
+	}
+	if updatefn == nil {
+		return scerr.InvalidParameterError("updatefn", "cannot be nil")
+	}
+	if task == nil {
+		task = concurrency.RootTask()
+	}
+
+	return c.UpdateMetadata(task, func() error {
+		return c.Properties.LockForWrite(property.SchedulesV1).ThenUse(func(clonable data.Clonable) error {
+			updatefn(clonable.(*clusterpropsv1.Schedules))
+			return nil
+		})
+	})
+}
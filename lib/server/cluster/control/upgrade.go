@@ -0,0 +1,503 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package control
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	pb "github.com/CS-SI/SafeScale/lib"
+	"github.com/CS-SI/SafeScale/lib/client"
+	clusterpropsv1 "github.com/CS-SI/SafeScale/lib/server/cluster/control/properties/v1"
+	"github.com/CS-SI/SafeScale/lib/server/cluster/enums/clusterstate"
+	"github.com/CS-SI/SafeScale/lib/server/cluster/enums/property"
+	"github.com/CS-SI/SafeScale/lib/server/cluster/enums/upgradestate"
+	"github.com/CS-SI/SafeScale/lib/utils/cli/enums/outputs"
+	"github.com/CS-SI/SafeScale/lib/utils/concurrency"
+	"github.com/CS-SI/SafeScale/lib/utils/data"
+	"github.com/CS-SI/SafeScale/lib/utils/retry"
+	"github.com/CS-SI/SafeScale/lib/utils/scerr"
+	"github.com/CS-SI/SafeScale/lib/utils/temporal"
+)
+
+const (
+	// DefaultUpgradeBatchSize is the number of nodes upgraded at the same time if not set
+	DefaultUpgradeBatchSize = 1
+
+	// osPatchesScript upgrades the packages of the OS; packages held (like the ones of Kubernetes) are left untouched
+	osPatchesScript = `sudo bash -c '
+if which apt-get >/dev/null 2>&1; then
+	apt-get update && \
+	DEBIAN_FRONTEND=noninteractive apt-get -y -o Dpkg::Options::=--force-confdef -o Dpkg::Options::=--force-confold dist-upgrade || exit 1
+else
+	yum -y update || exit 1
+fi'`
+)
+
+// Upgrade upgrades the masters then the nodes of the cluster, one host at a time for the masters and by batches of
+// batchSize hosts for the nodes: each host is drained, its OS packages are upgraded if osPatches is set and its
+// cluster manager (Kubernetes, Docker, ...) is upgraded to version if not empty, then the host is rebooted (if OS
+// patches have been applied), checked and allowed again to run workload.
+// On failure of a node, its previous version of the cluster manager is restored if possible and the upgrade stops.
+// The progress is recorded in the metadata of the cluster; calling Upgrade with the same parameters after an
+// interruption or a failure resumes the upgrade, skipping the hosts already upgraded.
+func (c *Controller) Upgrade(task concurrency.Task, osPatches bool, version string, batchSize int) (upgrade *clusterpropsv1.Upgrade, err error) {
+	if c == nil {
+		return nil, scerr.InvalidInstanceError()
+	}
+	if !osPatches && version == "" {
+		return nil, scerr.InvalidParameterError("version", "cannot be empty string if OS patches are not applied")
+	}
+	if batchSize < 0 {
+		return nil, scerr.InvalidParameterError("batchSize", "cannot be negative")
+	}
+	if batchSize == 0 {
+		batchSize = DefaultUpgradeBatchSize
+	}
+	if task == nil {
+		task = concurrency.RootTask()
+	}
+
+	tracer := concurrency.NewTracer(task, fmt.Sprintf("(%v, '%s', %d)", osPatches, version, batchSize), true).GoingIn()
+	defer tracer.OnExitTrace()()
+	defer scerr.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	if version != "" && !c.foreman.canUpgradePlatform() {
+		return nil, scerr.NotAvailableError(fmt.Sprintf("flavor '%s' of cluster '%s' does not support upgrade of its cluster manager", c.GetIdentity(task).Flavor.String(), c.Name))
+	}
+	state, err := c.GetState(task)
+	if err != nil {
+		return nil, err
+	}
+	if state != clusterstate.Nominal && state != clusterstate.Degraded {
+		return nil, scerr.NotAvailableError(fmt.Sprintf("cluster '%s' is %s, it cannot be upgraded", c.Name, state.String()))
+	}
+
+	err = c.prepareUpgrade(task, osPatches, version, batchSize)
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.runUpgrade(task)
+	endState := upgradestate.Done
+	if err != nil {
+		endState = upgradestate.Failed
+	}
+	uerr := c.updateUpgrade(task, func(u *clusterpropsv1.Upgrade) {
+		u.State = endState
+		u.Ended = time.Now()
+		upgrade = u.Clone().(*clusterpropsv1.Upgrade)
+	})
+	if uerr != nil {
+		if err == nil {
+			return nil, uerr
+		}
+		return nil, scerr.AddConsequence(err, uerr)
+	}
+	return upgrade, err
+}
+
+// prepareUpgrade records the hosts to upgrade, or reuses the progress of an unfinished upgrade with the same parameters
+func (c *Controller) prepareUpgrade(task concurrency.Task, osPatches bool, version string, batchSize int) error {
+	var hosts []*clusterpropsv1.HostUpgrade
+	for _, m := range c.ListMasters(task) {
+		hosts = append(hosts, &clusterpropsv1.HostUpgrade{ID: m.ID, Name: m.Name, Master: true, State: upgradestate.Pending})
+	}
+	for _, n := range c.ListNodes(task) {
+		hosts = append(hosts, &clusterpropsv1.HostUpgrade{ID: n.ID, Name: n.Name, State: upgradestate.Pending})
+	}
+
+	return c.UpdateMetadata(task, func() error {
+		return c.Properties.LockForWrite(property.UpgradeV1).ThenUse(func(clonable data.Clonable) error {
+			upgradeV1 := clonable.(*clusterpropsv1.Upgrade)
+			if upgradeV1.State == upgradestate.Upgrading || upgradeV1.State == upgradestate.Failed {
+				if upgradeV1.OSPatches == osPatches && upgradeV1.Version == version {
+					log.Infof("[cluster %s] resuming upgrade started on %s", c.Name, upgradeV1.Started.Format(time.RFC3339))
+					// Hosts added since the start of the upgrade are upgraded last; the ones removed are forgotten
+					var resumed []*clusterpropsv1.HostUpgrade
+					for _, h := range hosts {
+						if previous := upgradeV1.FindHost(h.ID); previous != nil {
+							h = previous
+						}
+						resumed = append(resumed, h)
+					}
+					upgradeV1.Hosts = resumed
+					upgradeV1.BatchSize = batchSize
+					upgradeV1.State = upgradestate.Upgrading
+					upgradeV1.Ended = time.Time{}
+					return nil
+				}
+				if upgradeV1.State == upgradestate.Upgrading {
+					return scerr.NotAvailableError(fmt.Sprintf("an upgrade of cluster '%s' with other parameters is in progress", c.Name))
+				}
+			}
+			*upgradeV1 = clusterpropsv1.Upgrade{
+				OSPatches: osPatches,
+				Version:   version,
+				BatchSize: batchSize,
+				State:     upgradestate.Upgrading,
+				Started:   time.Now(),
+				Hosts:     hosts,
+			}
+			return nil
+		})
+	})
+}
+
+// runUpgrade upgrades the hosts not upgraded yet, the masters one after the other then the nodes by batches
+func (c *Controller) runUpgrade(task concurrency.Task) error {
+	var upgrade *clusterpropsv1.Upgrade
+	c.RLock(task)
+	err := c.Properties.LockForRead(property.UpgradeV1).ThenUse(func(clonable data.Clonable) error {
+		upgrade = clonable.(*clusterpropsv1.Upgrade).Clone().(*clusterpropsv1.Upgrade)
+		return nil
+	})
+	c.RUnlock(task)
+	if err != nil {
+		return err
+	}
+
+	var (
+		batch       []*clusterpropsv1.HostUpgrade
+		masterIndex int
+	)
+	for _, h := range upgrade.Hosts {
+		if h.Master {
+			// Only the first master upgrades the control plane
+			first := masterIndex == 0
+			masterIndex++
+			if h.State == upgradestate.Done {
+				continue
+			}
+			err = c.upgradeHost(task, upgrade, h, first)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if h.State == upgradestate.Done {
+			continue
+		}
+		batch = append(batch, h)
+		if len(batch) == upgrade.BatchSize {
+			err = c.upgradeBatch(task, upgrade, batch)
+			if err != nil {
+				return err
+			}
+			batch = nil
+		}
+	}
+	if len(batch) > 0 {
+		return c.upgradeBatch(task, upgrade, batch)
+	}
+	return nil
+}
+
+// upgradeBatch upgrades the nodes of the batch in parallel
+func (c *Controller) upgradeBatch(task concurrency.Task, upgrade *clusterpropsv1.Upgrade, batch []*clusterpropsv1.HostUpgrade) error {
+	taskGroup, err := concurrency.NewTaskGroup(task)
+	if err != nil {
+		return err
+	}
+	for _, h := range batch {
+		_, err = taskGroup.Start(c.taskUpgradeHost, data.Map{
+			"upgrade": upgrade,
+			"host":    h,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	_, err = taskGroup.WaitGroup()
+	return err
+}
+
+// taskUpgradeHost upgrades a node in a subtask
+func (c *Controller) taskUpgradeHost(task concurrency.Task, params concurrency.TaskParameters) (concurrency.TaskResult, error) {
+	p := params.(data.Map)
+	return nil, c.upgradeHost(task, p["upgrade"].(*clusterpropsv1.Upgrade), p["host"].(*clusterpropsv1.HostUpgrade), false)
+}
+
+// upgradeHost drains, upgrades, reboots, checks and uncordons the host, recording its progress
+// On failure, the previous version of the cluster manager of a node is restored if known.
+func (c *Controller) upgradeHost(task concurrency.Task, upgrade *clusterpropsv1.Upgrade, h *clusterpropsv1.HostUpgrade, first bool) (err error) {
+	tracer := concurrency.NewTracer(task, fmt.Sprintf("('%s')", h.Name), true).GoingIn()
+	defer tracer.OnExitTrace()()
+	defer scerr.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	pbHost, err := client.New().Host.Inspect(h.ID, temporal.GetExecutionTimeout())
+	if err != nil {
+		return err
+	}
+
+	previousVersion := h.PreviousVersion
+	if upgrade.Version != "" && previousVersion == "" {
+		previousVersion, err = c.foreman.getPlatformVersion(task, pbHost)
+		if err != nil {
+			return c.failHostUpgrade(task, h, err, upgradestate.Failed)
+		}
+		err = c.setHostUpgradeState(task, h.ID, upgradestate.Pending, func(hu *clusterpropsv1.HostUpgrade) {
+			hu.PreviousVersion = previousVersion
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	err = c.setHostUpgradeState(task, h.ID, upgradestate.Draining, nil)
+	if err != nil {
+		return err
+	}
+	log.Infof("[cluster %s] draining host '%s'...", c.Name, h.Name)
+	err = c.foreman.drainNode(task, pbHost)
+	if err != nil {
+		return c.failHostUpgrade(task, h, err, upgradestate.Failed, func() error {
+			return c.foreman.uncordonNode(task, pbHost)
+		})
+	}
+
+	err = c.setHostUpgradeState(task, h.ID, upgradestate.Upgrading, nil)
+	if err != nil {
+		return err
+	}
+	err = c.applyHostUpgrade(task, upgrade, pbHost, h.Master, first)
+	if err != nil {
+		// The control plane cannot be downgraded; restoring the previous version is only done on nodes
+		if h.Master || previousVersion == "" || upgrade.Version == "" || !c.foreman.canRollbackPlatform() {
+			return c.failHostUpgrade(task, h, err, upgradestate.Failed, func() error {
+				return c.foreman.uncordonNode(task, pbHost)
+			})
+		}
+		return c.failHostUpgrade(task, h, err, upgradestate.RolledBack, func() error {
+			return c.foreman.rollbackPlatform(task, pbHost, previousVersion)
+		}, func() error {
+			return c.foreman.uncordonNode(task, pbHost)
+		})
+	}
+
+	if upgrade.OSPatches {
+		err = c.setHostUpgradeState(task, h.ID, upgradestate.Rebooting, nil)
+		if err != nil {
+			return err
+		}
+		log.Infof("[cluster %s] rebooting host '%s'...", c.Name, h.Name)
+		err = client.New().Host.Reboot(h.ID, temporal.GetHostTimeout())
+		if err != nil {
+			return c.failHostUpgrade(task, h, err, upgradestate.Failed)
+		}
+	}
+
+	err = c.setHostUpgradeState(task, h.ID, upgradestate.Checking, nil)
+	if err != nil {
+		return err
+	}
+	err = c.waitHostHealthy(task, h)
+	if err != nil {
+		return c.failHostUpgrade(task, h, err, upgradestate.Failed)
+	}
+
+	err = c.foreman.uncordonNode(task, pbHost)
+	if err != nil {
+		return c.failHostUpgrade(task, h, err, upgradestate.Failed)
+	}
+	log.Infof("[cluster %s] host '%s' upgraded", c.Name, h.Name)
+	return c.setHostUpgradeState(task, h.ID, upgradestate.Done, func(hu *clusterpropsv1.HostUpgrade) {
+		hu.Error = ""
+	})
+}
+
+// applyHostUpgrade applies the OS patches and upgrades the cluster manager on the host
+func (c *Controller) applyHostUpgrade(task concurrency.Task, upgrade *clusterpropsv1.Upgrade, pbHost *pb.Host, master bool, first bool) error {
+	if upgrade.OSPatches {
+		log.Infof("[cluster %s] applying OS patches on host '%s'...", c.Name, pbHost.Name)
+		retcode, _, stderr, err := client.New().SSH.Run(pbHost.Id, osPatchesScript, outputs.COLLECT, client.DefaultConnectionTimeout, temporal.GetLongOperationTimeout())
+		if err != nil {
+			return err
+		}
+		if retcode != 0 {
+			return fmt.Errorf("error applying OS patches on host '%s': errorcode %d, %s", pbHost.Name, retcode, strings.TrimSpace(stderr))
+		}
+	}
+	if upgrade.Version != "" {
+		log.Infof("[cluster %s] upgrading cluster manager to version %s on host '%s'...", c.Name, upgrade.Version, pbHost.Name)
+		return c.foreman.upgradePlatform(task, pbHost, upgrade.Version, master, first)
+	}
+	return nil
+}
+
+// waitHostHealthy waits until the host is reachable and ready in the cluster manager
+func (c *Controller) waitHostHealthy(task concurrency.Task, h *clusterpropsv1.HostUpgrade) error {
+	err := client.New().SSH.WaitReady(h.ID, temporal.GetHostTimeout())
+	if err != nil {
+		return err
+	}
+	return retry.WhileUnsuccessfulDelay5SecondsTimeout(
+		func() error {
+			readiness, err := c.foreman.getNodesReadiness(task)
+			if err != nil {
+				return err
+			}
+			if reason := c.probeHost(task, h.ID, h.Name, readiness); reason != "" {
+				return fmt.Errorf("host '%s' is not healthy: %s", h.Name, reason)
+			}
+			return nil
+		},
+		temporal.GetHostTimeout(),
+	)
+}
+
+// failHostUpgrade runs the recovery actions, records the failure of the upgrade of the host and returns the error
+// If a recovery action fails, the host is marked as failed whatever state is asked.
+func (c *Controller) failHostUpgrade(task concurrency.Task, h *clusterpropsv1.HostUpgrade, cause error, state upgradestate.Enum, recoveries ...func() error) error {
+	log.Errorf("[cluster %s] failed to upgrade host '%s': %v", c.Name, h.Name, cause)
+	var recoveryErrs []error
+	for _, r := range recoveries {
+		if rerr := r(); rerr != nil {
+			log.Errorf("[cluster %s] failed to recover host '%s' from failed upgrade: %v", c.Name, h.Name, rerr)
+			recoveryErrs = append(recoveryErrs, rerr)
+			state = upgradestate.Failed
+		}
+	}
+	uerr := c.setHostUpgradeState(task, h.ID, state, func(hu *clusterpropsv1.HostUpgrade) {
+		hu.Error = cause.Error()
+	})
+	if uerr != nil {
+		recoveryErrs = append(recoveryErrs, uerr)
+	}
+	err := scerr.Wrap(cause, fmt.Sprintf("failed to upgrade host '%s'", h.Name))
+	if len(recoveryErrs) > 0 {
+		return scerr.AddConsequence(err, scerr.ErrListError(recoveryErrs))
+	}
+	return err
+}
+
+// setHostUpgradeState records the state of the upgrade of the host, after applying fn if not nil
+func (c *Controller) setHostUpgradeState(task concurrency.Task, hostID string, state upgradestate.Enum, fn func(*clusterpropsv1.HostUpgrade)) error {
+	return c.updateUpgrade(task, func(u *clusterpropsv1.Upgrade) {
+		if hu := u.FindHost(hostID); hu != nil {
+			hu.State = state
+			hu.Updated = time.Now()
+			if fn != nil {
+				fn(hu)
+			}
+		}
+	})
+}
+
+// updateUpgrade applies fn to the upgrade recorded in metadata
+func (c *Controller) updateUpgrade(task concurrency.Task, fn func(*clusterpropsv1.Upgrade)) error {
+	return c.UpdateMetadata(task, func() error {
+		return c.Properties.LockForWrite(property.UpgradeV1).ThenUse(func(clonable data.Clonable) error {
+			fn(clonable.(*clusterpropsv1.Upgrade))
+			return nil
+		})
+	})
+}
+
+// isUpgrading tells if an upgrade of the cluster is in progress (or has been interrupted)
+func (c *Controller) isUpgrading(task concurrency.Task) bool {
+	if !c.Properties.Lookup(property.UpgradeV1) {
+		return false
+	}
+	upgrading := false
+	c.RLock(task)
+	err := c.Properties.LockForRead(property.UpgradeV1).ThenUse(func(clonable data.Clonable) error {
+		upgrading = clonable.(*clusterpropsv1.Upgrade).State == upgradestate.Upgrading
+		return nil
+	})
+	c.RUnlock(task)
+	return err == nil && upgrading
+}
+
+// GetUpgrade returns the progress of the last upgrade of the cluster, or nil if the cluster has never been upgraded
+func (c *Controller) GetUpgrade(task concurrency.Task) (upgrade *clusterpropsv1.Upgrade, err error) {
+	if c == nil {
+		return nil, scerr.InvalidInstanceError()
+	}
+	if task == nil {
+		task = concurrency.RootTask()
+	}
+	if !c.Properties.Lookup(property.UpgradeV1) {
+		return nil, nil
+	}
+
+	c.RLock(task)
+	defer c.RUnlock(task)
+	err = c.Properties.LockForRead(property.UpgradeV1).ThenUse(func(clonable data.Clonable) error {
+		upgrade = clonable.(*clusterpropsv1.Upgrade).Clone().(*clusterpropsv1.Upgrade)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return upgrade, nil
+}
+
+// drainNode moves the workload away from the host; flavors without maker have no workload to move
+func (b *foreman) drainNode(task concurrency.Task, pbHost *pb.Host) error {
+	if b.makers.DrainNode != nil {
+		return b.makers.DrainNode(task, b, pbHost)
+	}
+	// Not finding a callback isn't an error, so return nil in this case
+	return nil
+}
+
+// uncordonNode allows again workload on the host
+func (b *foreman) uncordonNode(task concurrency.Task, pbHost *pb.Host) error {
+	if b.makers.UncordonNode != nil {
+		return b.makers.UncordonNode(task, b, pbHost)
+	}
+	// Not finding a callback isn't an error, so return nil in this case
+	return nil
+}
+
+// getPlatformVersion returns the version of the cluster manager on the host, or empty string if the flavor cannot tell
+func (b *foreman) getPlatformVersion(task concurrency.Task, pbHost *pb.Host) (string, error) {
+	if b.makers.GetPlatformVersion != nil {
+		return b.makers.GetPlatformVersion(task, b, pbHost)
+	}
+	return "", nil
+}
+
+// canUpgradePlatform tells if the flavor knows how to upgrade its cluster manager
+func (b *foreman) canUpgradePlatform() bool {
+	return b.makers.UpgradePlatform != nil
+}
+
+// upgradePlatform upgrades the cluster manager on the host
+func (b *foreman) upgradePlatform(task concurrency.Task, pbHost *pb.Host, version string, master bool, first bool) error {
+	if b.makers.UpgradePlatform != nil {
+		return b.makers.UpgradePlatform(task, b, pbHost, version, master, first)
+	}
+	return fmt.Errorf("no maker defined for 'UpgradePlatform'")
+}
+
+// canRollbackPlatform tells if the flavor knows how to restore the previous version of its cluster manager
+func (b *foreman) canRollbackPlatform() bool {
+	return b.makers.RollbackPlatform != nil
+}
+
+// rollbackPlatform restores the previous version of the cluster manager on the host
+func (b *foreman) rollbackPlatform(task concurrency.Task, pbHost *pb.Host, version string) error {
+	if b.makers.RollbackPlatform != nil {
+		return b.makers.RollbackPlatform(task, b, pbHost, version)
+	}
+	return fmt.Errorf("no maker defined for 'RollbackPlatform'")
+}
@@ -0,0 +1,104 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package control
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/CS-SI/SafeScale/lib/client"
+	"github.com/CS-SI/SafeScale/lib/server/cluster/api"
+	"github.com/CS-SI/SafeScale/lib/utils/cli/enums/outputs"
+	"github.com/CS-SI/SafeScale/lib/utils/concurrency"
+	"github.com/CS-SI/SafeScale/lib/utils/scerr"
+)
+
+// GetWorkload returns the current workload of the nodes of the node pool <poolName> (the default pool if empty string)
+// Nodes that cannot be reached are not part of the loads returned.
+func (c *Controller) GetWorkload(task concurrency.Task, poolName string) (workload *api.Workload, err error) {
+	if c == nil {
+		return nil, scerr.InvalidInstanceError()
+	}
+	if task == nil {
+		task = concurrency.RootTask()
+	}
+	if poolName == "" {
+		poolName = DefaultNodePoolName
+	}
+
+	tracer := concurrency.NewTracer(task, fmt.Sprintf("('%s')", poolName), true).GoingIn()
+	defer tracer.OnExitTrace()()
+	defer scerr.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	pool, err := c.getNodePool(task, poolName)
+	if err != nil {
+		return nil, err
+	}
+
+	workload = &api.Workload{Loads: map[string]float64{}}
+	for _, hostID := range pool.Nodes {
+		load, err := c.foreman.getNodeLoad(task, hostID)
+		if err != nil {
+			log.Warnf("[cluster %s] failed to get load of node '%s': %v", c.Name, hostID, err)
+			continue
+		}
+		workload.Loads[hostID] = load
+	}
+
+	workload.Pending, err = c.foreman.countPendingWork(task)
+	if err != nil {
+		return nil, err
+	}
+	return workload, nil
+}
+
+// getNodeLoad returns the load average on 1 minute of the host divided by its number of cpus
+func (b *foreman) getNodeLoad(task concurrency.Task, hostID string) (float64, error) {
+	cmd := "echo $(cut -d' ' -f1 /proc/loadavg) $(nproc)"
+	retcode, stdout, stderr, err := client.New().SSH.Run(hostID, cmd, outputs.COLLECT, client.DefaultConnectionTimeout, client.DefaultExecutionTimeout)
+	if err != nil {
+		return 0, err
+	}
+	if retcode != 0 {
+		return 0, fmt.Errorf("failed to read load average: errorcode %d, %s", retcode, stderr)
+	}
+	fields := strings.Fields(stdout)
+	if len(fields) != 2 {
+		return 0, fmt.Errorf("unexpected output '%s' when reading load average", strings.TrimSpace(stdout))
+	}
+	load, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, err
+	}
+	cpus, err := strconv.Atoi(fields[1])
+	if err != nil || cpus <= 0 {
+		return 0, fmt.Errorf("unexpected number of cpus '%s'", fields[1])
+	}
+	return load / float64(cpus), nil
+}
+
+// countPendingWork returns the number of units of work waiting for resources in the cluster,
+// or -1 if the flavor cannot tell
+func (b *foreman) countPendingWork(task concurrency.Task) (int, error) {
+	if b.makers.CountPendingWork == nil {
+		return -1, nil
+	}
+	return b.makers.CountPendingWork(task, b)
+}
@@ -0,0 +1,77 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exportformat
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Enum represents the format of the access configuration of a cluster exported by 'safescale cluster export'
+type Enum int
+
+const (
+	_ Enum = iota
+	// Kubeconfig for a kubectl configuration (Kubernetes flavors only)
+	Kubeconfig
+	// SSHConfig for an OpenSSH client configuration
+	SSHConfig
+	// AnsibleInventory for an Ansible inventory in INI format
+	AnsibleInventory
+	// JSON for a description of the hosts of the cluster and how to reach them
+	JSON
+)
+
+var (
+	stringMap = map[string]Enum{
+		"kubeconfig":        Kubeconfig,
+		"ssh-config":        SSHConfig,
+		"ansible-inventory": AnsibleInventory,
+		"json":              JSON,
+	}
+
+	enumMap = map[Enum]string{
+		Kubeconfig:       "kubeconfig",
+		SSHConfig:        "ssh-config",
+		AnsibleInventory: "ansible-inventory",
+		JSON:             "json",
+	}
+)
+
+// Parse returns a Enum corresponding to the string parameter
+// If the string doesn't correspond to any Enum, returns an error (nil otherwise)
+// This function is intended to be used to parse user input.
+func Parse(v string) (Enum, error) {
+	var (
+		e  Enum
+		ok bool
+	)
+	lowered := strings.ToLower(v)
+	if e, ok = stringMap[lowered]; !ok {
+		return e, fmt.Errorf("failed to find an export format matching with '%s'", v)
+	}
+	return e, nil
+
+}
+
+// String returns a string representation of an Enum
+func (e Enum) String() string {
+	if str, found := enumMap[e]; found {
+		return str
+	}
+	panic(fmt.Sprintf("failed to find a string matching with export format '%d'!", e))
+}
@@ -36,6 +36,10 @@ const (
 	BOH
 	// OHPC for a OpenHPC cluster
 	OHPC
+	// K3S for a lightweight Kubernetes cluster based on K3s
+	K3S
+	// NOMAD for a HashiCorp Nomad cluster, with Consul
+	NOMAD
 )
 
 var (
@@ -45,6 +49,8 @@ var (
 		"swarm": SWARM,
 		"boh":   BOH,
 		"ohpc":  OHPC,
+		"k3s":   K3S,
+		"nomad": NOMAD,
 	}
 
 	enumMap = map[Enum]string{
@@ -53,6 +59,8 @@ var (
 		SWARM: "SWARM",
 		BOH:   "BOH",
 		OHPC:  "OHPC",
+		K3S:   "K3S",
+		NOMAD: "NOMAD",
 	}
 )
 
@@ -40,4 +40,16 @@ const (
 	NetworkV2 = "10"
 	// ControlPlaneV1 contains optional additional info about Control Plane of the cluster
 	ControlPlaneV1 = "11"
+	// NodePoolsV1 contains optional additional info about the pools of nodes of the cluster
+	NodePoolsV1 = "12"
+	// NodesHealthV1 contains optional additional info about the health of the masters and nodes of the cluster
+	NodesHealthV1 = "13"
+	// UpgradeV1 contains optional additional info about the progress of the last rolling upgrade of the cluster
+	UpgradeV1 = "14"
+	// BackupsV1 contains optional additional info about the backups of the control plane of the cluster
+	BackupsV1 = "15"
+	// NomadV1 contains optional additional info about the secrets of Nomad and Consul (flavor NOMAD)
+	NomadV1 = "16"
+	// SchedulesV1 contains optional additional info about the periodic tasks run by safescaled on the cluster
+	SchedulesV1 = "17"
 )
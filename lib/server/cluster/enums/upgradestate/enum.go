@@ -0,0 +1,41 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package upgradestate
+
+//go:generate stringer -type=Enum
+
+//Enum represents the progress of the upgrade of a cluster or of one of its hosts
+type Enum int
+
+const (
+	//Pending the upgrade has not started yet
+	Pending Enum = iota
+	//Draining the workload is moved away from the host
+	Draining
+	//Upgrading the OS and/or the platform are upgraded
+	Upgrading
+	//Rebooting the host is rebooting
+	Rebooting
+	//Checking the health of the host is checked before taking load again
+	Checking
+	//Done the upgrade is successful
+	Done
+	//Failed the upgrade failed
+	Failed
+	//RolledBack the upgrade failed and the previous version of the platform has been restored
+	RolledBack
+)
@@ -27,7 +27,9 @@ import (
 	"github.com/CS-SI/SafeScale/lib/server/cluster/enums/flavor"
 	"github.com/CS-SI/SafeScale/lib/server/cluster/flavors/boh"
 	"github.com/CS-SI/SafeScale/lib/server/cluster/flavors/dcos"
+	"github.com/CS-SI/SafeScale/lib/server/cluster/flavors/k3s"
 	"github.com/CS-SI/SafeScale/lib/server/cluster/flavors/k8s"
+	"github.com/CS-SI/SafeScale/lib/server/cluster/flavors/nomad"
 	"github.com/CS-SI/SafeScale/lib/server/cluster/flavors/swarm"
 	"github.com/CS-SI/SafeScale/lib/server/iaas"
 	"github.com/CS-SI/SafeScale/lib/utils/concurrency"
@@ -45,7 +47,11 @@ func Load(task concurrency.Task, name string) (api.Cluster, error) {
 	if err != nil {
 		return nil, err
 	}
+	return LoadFromService(task, svc, name)
+}
 
+// LoadFromService loads the cluster named 'name' from the metadata of the service, whatever the current tenant
+func LoadFromService(task concurrency.Task, svc iaas.Service, name string) (api.Cluster, error) {
 	m, err := control.NewMetadata(svc)
 	if err != nil {
 		return nil, err
@@ -79,6 +85,10 @@ func setForeman(task concurrency.Task, controller *control.Controller) error {
 	// 	controller.Restore(task, control.NewForeman(controller, ohpc.Makers))
 	case flavor.K8S:
 		return controller.Restore(task, control.NewForeman(controller, k8s.Makers))
+	case flavor.K3S:
+		return controller.Restore(task, control.NewForeman(controller, k3s.Makers))
+	case flavor.NOMAD:
+		return controller.Restore(task, control.NewForeman(controller, nomad.Makers))
 	case flavor.SWARM:
 		return controller.Restore(task, control.NewForeman(controller, swarm.Makers))
 	default:
@@ -132,6 +142,16 @@ func Create(task concurrency.Task, req control.Request) (_ api.Cluster, err erro
 		if err != nil {
 			return nil, err
 		}
+	case flavor.K3S:
+		err = controller.Create(task, req, control.NewForeman(controller, k3s.Makers))
+		if err != nil {
+			return nil, err
+		}
+	case flavor.NOMAD:
+		err = controller.Create(task, req, control.NewForeman(controller, nomad.Makers))
+		if err != nil {
+			return nil, err
+		}
 	// case flavor.OHPC:
 	// 	err = control.Create(task, req, control.NewForema(controller, ohpc.Makers))
 	// 	if err != nil {
@@ -171,7 +191,11 @@ func List() (clusterList []api.Cluster, err error) {
 	if err != nil {
 		return nil, err
 	}
+	return ListFromService(svc)
+}
 
+// ListFromService lists the clusters found in the metadata of the service, whatever the current tenant
+func ListFromService(svc iaas.Service) (clusterList []api.Cluster, err error) {
 	m, err := control.NewMetadata(svc)
 	if err != nil {
 		return clusterList, err
@@ -0,0 +1,398 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package k3s
+
+/*
+ * Implements a lightweight Kubernetes cluster based on K3s: masters run 'k3s server' (with embedded etcd
+ * if there are several masters), nodes run 'k3s agent'
+ */
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	rice "github.com/GeertJohan/go.rice"
+	"github.com/sirupsen/logrus"
+
+	pb "github.com/CS-SI/SafeScale/lib"
+	"github.com/CS-SI/SafeScale/lib/client"
+	"github.com/CS-SI/SafeScale/lib/server/cluster/control"
+	clusterpropsv1 "github.com/CS-SI/SafeScale/lib/server/cluster/control/properties/v1"
+	"github.com/CS-SI/SafeScale/lib/server/cluster/enums/complexity"
+	"github.com/CS-SI/SafeScale/lib/server/cluster/enums/nodetype"
+	"github.com/CS-SI/SafeScale/lib/server/cluster/enums/property"
+	"github.com/CS-SI/SafeScale/lib/server/cluster/flavors/kubectl"
+	"github.com/CS-SI/SafeScale/lib/server/iaas/resources"
+	"github.com/CS-SI/SafeScale/lib/server/install"
+	"github.com/CS-SI/SafeScale/lib/utils/cli/enums/outputs"
+	"github.com/CS-SI/SafeScale/lib/utils/concurrency"
+	"github.com/CS-SI/SafeScale/lib/utils/data"
+	"github.com/CS-SI/SafeScale/lib/utils/scerr"
+	"github.com/CS-SI/SafeScale/lib/utils/template"
+	"github.com/CS-SI/SafeScale/lib/utils/temporal"
+)
+
+//go:generate rice embed-go
+
+var (
+	templateBox                     atomic.Value
+	globalSystemRequirementsContent atomic.Value
+
+	// kube runs kubectl on the masters (provided by k3s)
+	kube = kubectl.New("sudo -u cladm -i kubectl")
+
+	// Makers initializes a control.Makers struct to construct a K3S Cluster
+	Makers = control.Makers{
+		MinimumRequiredServers:      minimumRequiredServers,
+		DefaultGatewaySizing:        gatewaySizing,
+		DefaultMasterSizing:         nodeSizing,
+		DefaultNodeSizing:           nodeSizing,
+		DefaultImage:                defaultImage,
+		GetTemplateBox:              getTemplateBox,
+		GetGlobalSystemRequirements: getGlobalSystemRequirements,
+		GetNodeInstallationScript:   getNodeInstallationScript,
+		ConfigureCluster:            configureCluster,
+		UnconfigureCluster:          unconfigureCluster,
+		LeaveNodeFromCluster:        leaveNodeFromCluster,
+		LabelNode:                   kube.LabelNode,
+		CountPendingWork:            kube.CountPendingPods,
+		GetNodesReadiness:           kube.GetNodesReadiness,
+		DrainNode:                   kube.DrainNode,
+		UncordonNode:                kube.UncordonNode,
+		GetPlatformVersion:          getPlatformVersion,
+		UpgradePlatform:             upgradePlatform,
+		RollbackPlatform:            rollbackPlatform,
+	}
+)
+
+func minimumRequiredServers(task concurrency.Task, foreman control.Foreman) (int, int, int) {
+	masterCount := 0
+	privateNodeCount := 0
+	publicNodeCount := 0
+
+	// embedded etcd needs an odd number of masters
+	switch foreman.Cluster().GetIdentity(task).Complexity {
+	case complexity.Small:
+		masterCount = 1
+		privateNodeCount = 1
+	case complexity.Normal:
+		masterCount = 3
+		privateNodeCount = 3
+	case complexity.Large:
+		masterCount = 5
+		privateNodeCount = 6
+	}
+	return masterCount, privateNodeCount, publicNodeCount
+}
+
+func gatewaySizing(task concurrency.Task, foreman control.Foreman) pb.HostDefinition {
+	return pb.HostDefinition{
+		Sizing: &pb.HostSizing{
+			MinCpuCount: 1,
+			MaxCpuCount: 2,
+			MinRamSize:  2.0,
+			MaxRamSize:  4.0,
+			MinDiskSize: 20,
+			GpuCount:    -1,
+		},
+	}
+}
+
+func nodeSizing(task concurrency.Task, foreman control.Foreman) pb.HostDefinition {
+	return pb.HostDefinition{
+		Sizing: &pb.HostSizing{
+			MinCpuCount: 2,
+			MaxCpuCount: 4,
+			MinRamSize:  4.0,
+			MaxRamSize:  8.0,
+			MinDiskSize: 40,
+			GpuCount:    -1,
+		},
+	}
+}
+
+func defaultImage(task concurrency.Task, foreman control.Foreman) string {
+	return "Ubuntu 18.04"
+}
+
+// configureCluster installs the features 'k3s' and 'k8s.helm2' (unless disabled) on the cluster
+func configureCluster(task concurrency.Task, foreman control.Foreman, req control.Request) error {
+	cluster := foreman.Cluster().(*control.Controller)
+	clusterName := cluster.GetIdentity(task).Name
+
+	err := createControlPlaneVIP(task, cluster)
+	if err != nil {
+		return err
+	}
+
+	target, err := install.NewClusterTarget(task, foreman.Cluster())
+	if err != nil {
+		return err
+	}
+
+	features := []string{"k3s"}
+	if _, ok := req.DisabledDefaultFeatures["helm"]; !ok {
+		features = append(features, "k8s.helm2")
+	}
+	for _, name := range features {
+		logrus.Println(fmt.Sprintf("[cluster %s] adding feature '%s'...", clusterName, name))
+		feature, err := install.NewFeature(task, name)
+		if err != nil {
+			logrus.Errorf("[cluster %s] failed to instantiate feature '%s': %v", clusterName, name, err)
+			return fmt.Errorf("failed to prepare feature '%s': %s", name, err.Error())
+		}
+		results, err := feature.Add(target, install.Variables{}, install.Settings{})
+		if err != nil {
+			logrus.Errorf("[cluster %s] failed to add feature '%s': %s", clusterName, name, err.Error())
+			return err
+		}
+		if !results.Successful() {
+			err = fmt.Errorf(results.AllErrorMessages())
+			logrus.Errorf("[cluster %s] failed to add feature '%s': %s", clusterName, name, err.Error())
+			return err
+		}
+		logrus.Println(fmt.Sprintf("[cluster %s] feature '%s' addition successful.", clusterName, name))
+	}
+	return nil
+}
+
+// createControlPlaneVIP creates the VIP used as endpoint of the API server if the cluster has several masters and
+// the cloud provider supports VIP; otherwise, the API server is reached through the gateway
+func createControlPlaneVIP(task concurrency.Task, cluster *control.Controller) (err error) {
+	identity := cluster.GetIdentity(task)
+	svc := cluster.GetService(task)
+	if identity.Complexity == complexity.Small || !svc.GetCapabilities().PrivateVirtualIP {
+		return nil
+	}
+
+	var vip *resources.VirtualIP
+	err = cluster.GetProperties(task).LockForRead(property.ControlPlaneV1).ThenUse(func(clonable data.Clonable) error {
+		vip = clonable.(*clusterpropsv1.ControlPlane).VirtualIP
+		return nil
+	})
+	if err != nil || vip != nil {
+		return err
+	}
+
+	netCfg, err := cluster.GetNetworkConfig(task)
+	if err != nil {
+		return err
+	}
+	vip, err = svc.CreateVIP(netCfg.NetworkID, identity.Name+"-ControlPlaneVIP")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			derr := svc.DeleteVIP(vip)
+			if derr != nil {
+				logrus.Errorf("Cleaning up on failure, failed to delete VirtualIP: %v", derr)
+			}
+		}
+	}()
+
+	masterIDs := cluster.ListMasterIDs(task)
+	for _, id := range masterIDs {
+		err = svc.BindHostToVIP(vip, id)
+		if err != nil {
+			return err
+		}
+	}
+
+	return cluster.UpdateMetadata(task, func() error {
+		return cluster.GetProperties(task).LockForWrite(property.ControlPlaneV1).ThenUse(func(clonable data.Clonable) error {
+			controlPlaneV1 := clonable.(*clusterpropsv1.ControlPlane)
+			controlPlaneV1.VirtualIP = vip
+			controlPlaneV1.VirtualIP.Hosts = masterIDs
+			return nil
+		})
+	})
+}
+
+func unconfigureCluster(task concurrency.Task, foreman control.Foreman) error {
+	clusterName := foreman.Cluster().GetIdentity(task).Name
+	logrus.Println(fmt.Sprintf("[cluster %s] removing control plane virtual IP...", clusterName))
+
+	return foreman.Cluster().GetProperties(task).LockForWrite(property.ControlPlaneV1).ThenUse(func(clonable data.Clonable) error {
+		controlPlaneV1, ok := clonable.(*clusterpropsv1.ControlPlane)
+		if !ok {
+			return scerr.InconsistentError("property ControlPlaneV1 doesn't contain valid data")
+		}
+		if controlPlaneV1.VirtualIP != nil {
+			return foreman.Cluster().GetService(task).DeleteVIP(controlPlaneV1.VirtualIP)
+		}
+		return nil
+	})
+}
+
+func getNodeInstallationScript(task concurrency.Task, foreman control.Foreman, nodeType nodetype.Enum) (string, map[string]interface{}) {
+	script := ""
+	theData := map[string]interface{}{}
+
+	switch nodeType {
+	case nodetype.Master:
+		script = "k3s_install_master.sh"
+	case nodetype.Node, nodetype.Gateway:
+		script = "k3s_install_node.sh"
+	}
+	return script, theData
+}
+
+func getTemplateBox() (*rice.Box, error) {
+	anon := templateBox.Load()
+	if anon == nil {
+		// Note: path MUST be literal for rice to work
+		b, err := rice.FindBox("../k3s/scripts")
+		if err != nil {
+			return nil, err
+		}
+		templateBox.Store(b)
+		anon = templateBox.Load()
+	}
+	return anon.(*rice.Box), nil
+}
+
+func getGlobalSystemRequirements(task concurrency.Task, foreman control.Foreman) (string, error) {
+	anon := globalSystemRequirementsContent.Load()
+	if anon == nil {
+		// find the rice.Box
+		box, err := getTemplateBox()
+		if err != nil {
+			return "", err
+		}
+
+		// We will need information from cluster network
+		cluster := foreman.Cluster()
+		netCfg, err := cluster.GetNetworkConfig(task)
+		if err != nil {
+			return "", err
+		}
+
+		// get file contents as string
+		tmplString, err := box.String("k3s_install_requirements.sh")
+		if err != nil {
+			return "", fmt.Errorf("error loading script template: %s", err.Error())
+		}
+
+		// parse then execute the template
+		tmplPrepared, err := template.Parse("install_requirements", tmplString, nil)
+		if err != nil {
+			return "", fmt.Errorf("error parsing script template: %s", err.Error())
+		}
+		dataBuffer := bytes.NewBufferString("")
+		identity := cluster.GetIdentity(task)
+		err = tmplPrepared.Execute(dataBuffer, map[string]interface{}{
+			"CIDR":                 netCfg.CIDR,
+			"ClusterAdminUsername": "cladm",
+			"ClusterAdminPassword": identity.AdminPassword,
+			"SSHPublicKey":         identity.Keypair.PublicKey,
+			"SSHPrivateKey":        identity.Keypair.PrivateKey,
+		})
+		if err != nil {
+			return "", fmt.Errorf("error realizing script template: %s", err.Error())
+		}
+		globalSystemRequirementsContent.Store(dataBuffer.String())
+		anon = globalSystemRequirementsContent.Load()
+	}
+	return anon.(string), nil
+}
+
+// leaveNodeFromCluster drains the node and removes it from k3s
+func leaveNodeFromCluster(task concurrency.Task, b control.Foreman, pbHost *pb.Host, selectedMaster string) error {
+	out, err := kube.Run(task, b, "get nodes --no-headers -o custom-columns=NAME:.metadata.name", client.DefaultExecutionTimeout)
+	if err != nil {
+		return err
+	}
+	found := false
+	for _, name := range strings.Fields(out) {
+		if name == pbHost.Name {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil // not there, nothing to do
+	}
+
+	err = kube.DrainNode(task, b, pbHost)
+	if err != nil {
+		return err
+	}
+	_, err = kube.Run(task, b, "delete node "+pbHost.Name, client.DefaultExecutionTimeout)
+	return err
+}
+
+// getPlatformVersion returns the version of k3s installed on the host
+func getPlatformVersion(task concurrency.Task, b control.Foreman, pbHost *pb.Host) (string, error) {
+	cmd := "k3s --version"
+	retcode, stdout, stderr, err := client.New().SSH.Run(pbHost.Id, cmd, outputs.COLLECT, client.DefaultConnectionTimeout, client.DefaultExecutionTimeout)
+	if err != nil {
+		return "", err
+	}
+	if retcode != 0 {
+		return "", fmt.Errorf("error getting version of k3s on host '%s': errorcode %d, %s", pbHost.Name, retcode, stderr)
+	}
+	// Output is 'k3s version v1.19.5+k3s2 (746cf403)'
+	fields := strings.Fields(stdout)
+	if len(fields) < 3 {
+		return "", fmt.Errorf("unexpected output of k3s on host '%s': %s", pbHost.Name, stdout)
+	}
+	return strings.TrimPrefix(fields[2], "v"), nil
+}
+
+// installK3sScript returns the script replacing the binary of k3s by the one of version, then restarting k3s
+// (server on masters, agent on nodes)
+func installK3sScript(version string) string {
+	tag := strings.Replace("v"+strings.TrimPrefix(version, "v"), "+", "%2B", -1)
+	return fmt.Sprintf(`curl -fsSL -o /usr/local/bin/k3s.new https://github.com/rancher/k3s/releases/download/%s/k3s && \
+chmod 0755 /usr/local/bin/k3s.new && \
+mv -f /usr/local/bin/k3s.new /usr/local/bin/k3s || exit 1
+if systemctl cat k3s >/dev/null 2>&1; then
+	systemctl restart k3s
+else
+	systemctl restart k3s-agent
+fi`, tag)
+}
+
+// upgradePlatform replaces the binary of k3s on the host by the one of version and restarts it
+// k3s upgrades the control plane itself when the servers are restarted, masters being upgraded first.
+func upgradePlatform(task concurrency.Task, b control.Foreman, pbHost *pb.Host, version string, master bool, first bool) error {
+	cmd := fmt.Sprintf("sudo bash -c '%s'", installK3sScript(version))
+	retcode, _, stderr, err := client.New().SSH.Run(pbHost.Id, cmd, outputs.COLLECT, client.DefaultConnectionTimeout, temporal.GetLongOperationTimeout())
+	if err != nil {
+		return err
+	}
+	if retcode != 0 {
+		return fmt.Errorf("error upgrading k3s to version %s on host '%s': errorcode %d, %s", version, pbHost.Name, retcode, stderr)
+	}
+	return nil
+}
+
+// rollbackPlatform restores the previous binary of k3s on a node
+func rollbackPlatform(task concurrency.Task, b control.Foreman, pbHost *pb.Host, version string) error {
+	cmd := fmt.Sprintf("sudo bash -c '%s'", installK3sScript(version))
+	retcode, _, stderr, err := client.New().SSH.Run(pbHost.Id, cmd, outputs.COLLECT, client.DefaultConnectionTimeout, temporal.GetLongOperationTimeout())
+	if err != nil {
+		return err
+	}
+	if retcode != 0 {
+		return fmt.Errorf("error restoring k3s version %s on host '%s': errorcode %d, %s", version, pbHost.Name, retcode, stderr)
+	}
+	return nil
+}
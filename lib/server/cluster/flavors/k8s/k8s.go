@@ -19,6 +19,8 @@ package k8s
 import (
 	"bytes"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"strconv"
 	"strings"
 	"sync/atomic"
@@ -33,12 +35,15 @@ import (
 	"github.com/CS-SI/SafeScale/lib/server/cluster/enums/complexity"
 	"github.com/CS-SI/SafeScale/lib/server/cluster/enums/nodetype"
 	"github.com/CS-SI/SafeScale/lib/server/cluster/enums/property"
+	"github.com/CS-SI/SafeScale/lib/server/cluster/flavors/kubectl"
+	"github.com/CS-SI/SafeScale/lib/server/iaas/resources"
 	"github.com/CS-SI/SafeScale/lib/server/install"
 	"github.com/CS-SI/SafeScale/lib/utils/cli/enums/outputs"
 	"github.com/CS-SI/SafeScale/lib/utils/concurrency"
 	"github.com/CS-SI/SafeScale/lib/utils/data"
 	"github.com/CS-SI/SafeScale/lib/utils/scerr"
 	"github.com/CS-SI/SafeScale/lib/utils/template"
+	"github.com/CS-SI/SafeScale/lib/utils/temporal"
 )
 
 //go:generate rice embed-go
@@ -47,6 +52,9 @@ var (
 	templateBox                     atomic.Value
 	globalSystemRequirementsContent atomic.Value
 
+	// kube runs kubectl on the masters
+	kube = kubectl.New("sudo -u cladm -i kubectl")
+
 	// Makers initializes a control.Makers struct to construct a BOH Cluster
 	Makers = control.Makers{
 		MinimumRequiredServers:      minimumRequiredServers,
@@ -60,6 +68,16 @@ var (
 		ConfigureCluster:            configureCluster,
 		UnconfigureCluster:          unconfigureCluster,
 		LeaveNodeFromCluster:        leaveNodeFromCluster,
+		LabelNode:                   kube.LabelNode,
+		CountPendingWork:            kube.CountPendingPods,
+		GetNodesReadiness:           kube.GetNodesReadiness,
+		DrainNode:                   kube.DrainNode,
+		UncordonNode:                kube.UncordonNode,
+		GetPlatformVersion:          getPlatformVersion,
+		UpgradePlatform:             upgradePlatform,
+		RollbackPlatform:            rollbackPlatform,
+		BackupControlPlane:          backupControlPlane,
+		RestoreControlPlane:         restoreControlPlane,
 	}
 )
 
@@ -113,6 +131,13 @@ func defaultImage(task concurrency.Task, foreman control.Foreman) string {
 }
 
 func configureCluster(task concurrency.Task, foreman control.Foreman, req control.Request) error {
+	return addKubernetes(task, foreman, req, "", false)
+}
+
+// addKubernetes installs the features 'kubernetes' and 'k8s.helm2' (unless disabled) on the cluster
+// If version is not empty, this version of Kubernetes is installed instead of the default one.
+// If restore is set, the state of etcd restored on the first master is used instead of initializing a new one.
+func addKubernetes(task concurrency.Task, foreman control.Foreman, req control.Request, version string, restore bool) error {
 	cluster := foreman.Cluster().(*control.Controller)
 	identity := cluster.GetIdentity(task)
 	clusterName := identity.Name
@@ -198,6 +223,10 @@ func configureCluster(task concurrency.Task, foreman control.Foreman, req contro
 	// Disable dashboard if requested
 	_, ok = req.DisabledDefaultFeatures["dashboard"]
 	v["Dashboard"] = strconv.FormatBool(!ok)
+	v["RestoreEtcd"] = strconv.FormatBool(restore)
+	if version != "" {
+		v["KubeVersion"] = version
+	}
 
 	// Installs kubernetes feature
 	results, err := feature.Add(target, v, install.Settings{})
@@ -392,3 +421,229 @@ func leaveNodeFromCluster(task concurrency.Task, b control.Foreman, pbHost *pb.H
 
 	return nil
 }
+
+// getPlatformVersion returns the version of kubelet installed on the host
+func getPlatformVersion(task concurrency.Task, b control.Foreman, pbHost *pb.Host) (string, error) {
+	cmd := "kubelet --version"
+	retcode, stdout, stderr, err := client.New().SSH.Run(pbHost.Id, cmd, outputs.COLLECT, client.DefaultConnectionTimeout, client.DefaultExecutionTimeout)
+	if err != nil {
+		return "", err
+	}
+	if retcode != 0 {
+		return "", fmt.Errorf("error getting version of kubelet on host '%s': errorcode %d, %s", pbHost.Name, retcode, stderr)
+	}
+	// Output is 'Kubernetes v1.14.1'
+	fields := strings.Fields(stdout)
+	if len(fields) == 0 {
+		return "", fmt.Errorf("unexpected output of kubelet on host '%s': %s", pbHost.Name, stdout)
+	}
+	return strings.TrimPrefix(fields[len(fields)-1], "v"), nil
+}
+
+// installKubeletScript returns the script installing kubelet and kubectl in version
+func installKubeletScript(version string) string {
+	return fmt.Sprintf(`if which apt-get >/dev/null 2>&1; then
+	apt-mark unhold kubelet kubectl && \
+	DEBIAN_FRONTEND=noninteractive apt-get install -y --allow-downgrades kubelet=%[1]s-00 kubectl=%[1]s-00 && \
+	apt-mark hold kubelet kubectl || exit 1
+else
+	yum -y install kubelet-%[1]s kubectl-%[1]s --disableexcludes=kubernetes || exit 1
+fi
+systemctl daemon-reload && systemctl restart kubelet`, version)
+}
+
+// upgradePlatform upgrades kubeadm, then the control plane (with 'kubeadm upgrade apply' on the first master)
+// or the configuration of kubelet (with 'kubeadm upgrade node'), then kubelet and kubectl
+func upgradePlatform(task concurrency.Task, b control.Foreman, pbHost *pb.Host, version string, master bool, first bool) error {
+	action := "node"
+	if master && first {
+		action = "apply -y v" + version
+	}
+	cmd := fmt.Sprintf(`sudo bash -c '
+if which apt-get >/dev/null 2>&1; then
+	apt-get update && apt-mark unhold kubeadm && \
+	DEBIAN_FRONTEND=noninteractive apt-get install -y kubeadm=%[1]s-00 && \
+	apt-mark hold kubeadm || exit 1
+else
+	yum -y install kubeadm-%[1]s --disableexcludes=kubernetes || exit 1
+fi
+kubeadm upgrade %[2]s || exit 2
+%[3]s || exit 3
+'`, version, action, installKubeletScript(version))
+	retcode, _, stderr, err := client.New().SSH.Run(pbHost.Id, cmd, outputs.COLLECT, client.DefaultConnectionTimeout, temporal.GetLongOperationTimeout())
+	if err != nil {
+		return err
+	}
+	if retcode != 0 {
+		return fmt.Errorf("error upgrading Kubernetes to version %s on host '%s': errorcode %d, %s", version, pbHost.Name, retcode, stderr)
+	}
+	return nil
+}
+
+// rollbackPlatform restores the version of kubelet and kubectl of a node
+// The control plane is not downgraded; kubelet may lag behind it, so restoring the previous kubelet is safe.
+func rollbackPlatform(task concurrency.Task, b control.Foreman, pbHost *pb.Host, version string) error {
+	cmd := fmt.Sprintf("sudo bash -c '%s'", installKubeletScript(version))
+	retcode, _, stderr, err := client.New().SSH.Run(pbHost.Id, cmd, outputs.COLLECT, client.DefaultConnectionTimeout, temporal.GetLongOperationTimeout())
+	if err != nil {
+		return err
+	}
+	if retcode != 0 {
+		return fmt.Errorf("error restoring Kubernetes version %s on host '%s': errorcode %d, %s", version, pbHost.Name, retcode, stderr)
+	}
+	return nil
+}
+
+// backupControlPlane writes to w a gzipped tar archive containing a snapshot of etcd (taken with the etcdctl of the
+// etcd image used by the cluster), the certificate authorities and the service account keys of the PKI, and the
+// kubeadm configuration of the master. The leaf certificates are not saved: kubeadm generates them again from the
+// authorities when the control plane is restored on new masters, with their names and addresses.
+func backupControlPlane(task concurrency.Task, b control.Foreman, pbHost *pb.Host, w io.Writer) (string, error) {
+	version, err := getPlatformVersion(task, b, pbHost)
+	if err != nil {
+		return "", err
+	}
+
+	// Only the archive is written on stdout
+	cmd := `sudo bash -c '
+set -e
+WORKDIR=$(mktemp -d)
+trap "rm -rf $WORKDIR" EXIT
+IMAGE=$(grep "image:" /etc/kubernetes/manifests/etcd.yaml | awk "{ print \$2 }")
+docker run --rm --network host -e ETCDCTL_API=3 -v /etc/kubernetes/pki/etcd:/etc/kubernetes/pki/etcd:ro -v $WORKDIR:/backup $IMAGE \
+	etcdctl --endpoints=https://127.0.0.1:2379 --cacert=/etc/kubernetes/pki/etcd/ca.crt \
+	--cert=/etc/kubernetes/pki/etcd/healthcheck-client.crt --key=/etc/kubernetes/pki/etcd/healthcheck-client.key \
+	snapshot save /backup/etcd-snapshot.db >&2
+echo $IMAGE >$WORKDIR/etcd-image
+mkdir -p $WORKDIR/pki/etcd
+cp -a /etc/kubernetes/pki/ca.* /etc/kubernetes/pki/sa.* /etc/kubernetes/pki/front-proxy-ca.* $WORKDIR/pki/
+cp -a /etc/kubernetes/pki/etcd/ca.* $WORKDIR/pki/etcd/
+cp /etc/kubernetes/kubeadm/kubeadm-config.yaml $WORKDIR/ 2>/dev/null || true
+tar czf - -C $WORKDIR .
+'`
+	var stderr bytes.Buffer
+	retcode, err := client.New().SSH.Exec(pbHost.Id, cmd, nil, w, &stderr, temporal.GetLongOperationTimeout())
+	if err != nil {
+		return "", err
+	}
+	if retcode != 0 {
+		return "", fmt.Errorf("error saving control plane of master '%s': errorcode %d, %s", pbHost.Name, retcode, strings.TrimSpace(stderr.String()))
+	}
+	return version, nil
+}
+
+// restoreControlPlane restores the authorities of the PKI and etcd from the archive on the first master, makes the gateways and the
+// nodes leave the former control plane, then installs again Kubernetes using the restored state, and finally
+// removes the former masters from the k8s nodes
+func restoreControlPlane(task concurrency.Task, b control.Foreman, r io.Reader, version string, formerMasters []string) error {
+	cluster := b.Cluster().(*control.Controller)
+	clusterName := cluster.GetIdentity(task).Name
+	masters := cluster.ListMasters(task)
+	if len(masters) == 0 {
+		return scerr.InconsistentError(fmt.Sprintf("no master in cluster '%s' to restore control plane on", clusterName))
+	}
+	first := masters[0]
+
+	// etcd is restored as a single member cluster, the other masters joining it as during creation
+	cmd := fmt.Sprintf(`sudo bash -c '
+set -e
+WORKDIR=/var/tmp/safescale-restore
+rm -rf $WORKDIR && mkdir -p $WORKDIR
+tar xzf - -C $WORKDIR
+mkdir -p /etc/kubernetes
+rm -rf /etc/kubernetes/pki /var/lib/etcd
+mkdir -p /etc/kubernetes/pki/etcd
+cp -a $WORKDIR/pki/ca.* $WORKDIR/pki/sa.* $WORKDIR/pki/front-proxy-ca.* /etc/kubernetes/pki/
+cp -a $WORKDIR/pki/etcd/ca.* /etc/kubernetes/pki/etcd/
+NAME=$(hostname)
+docker run --rm -e ETCDCTL_API=3 -v $WORKDIR:/backup -v /var/lib:/var/lib $(cat $WORKDIR/etcd-image) \
+	etcdctl snapshot restore /backup/etcd-snapshot.db --data-dir=/var/lib/etcd --name=$NAME \
+	--initial-cluster=$NAME=https://%[1]s:2380 --initial-advertise-peer-urls=https://%[1]s:2380 >&2
+rm -rf $WORKDIR
+'`, first.PrivateIP)
+	var stderr bytes.Buffer
+	retcode, err := client.New().SSH.Exec(first.ID, cmd, r, ioutil.Discard, &stderr, temporal.GetLongOperationTimeout())
+	if err != nil {
+		return err
+	}
+	if retcode != 0 {
+		return fmt.Errorf("error restoring control plane on master '%s': errorcode %d, %s", first.Name, retcode, strings.TrimSpace(stderr.String()))
+	}
+
+	// Gateways and nodes are reset to join again the restored control plane
+	netCfg, err := cluster.GetNetworkConfig(task)
+	if err != nil {
+		return err
+	}
+	hosts := cluster.ListNodeIDs(task)
+	hosts = append(hosts, netCfg.GatewayID)
+	if netCfg.SecondaryGatewayID != "" {
+		hosts = append(hosts, netCfg.SecondaryGatewayID)
+	}
+	for _, id := range hosts {
+		cmd := "sudo bash -c 'kubeadm reset -f >/dev/null 2>&1; rm -f /etc/kubernetes/.joined'"
+		retcode, _, stderr, err := client.New().SSH.Run(id, cmd, outputs.COLLECT, client.DefaultConnectionTimeout, client.DefaultExecutionTimeout)
+		if err != nil {
+			return err
+		}
+		if retcode != 0 {
+			return fmt.Errorf("error resetting k8s on host '%s': errorcode %d, %s", id, retcode, stderr)
+		}
+	}
+
+	// The new masters replace the former ones behind the control plane VIP
+	err = rebindControlPlaneVIP(task, cluster)
+	if err != nil {
+		return err
+	}
+
+	req := control.Request{DisabledDefaultFeatures: map[string]struct{}{}}
+	err = cluster.GetProperties(task).LockForRead(property.FeaturesV1).ThenUse(func(clonable data.Clonable) error {
+		for k := range clonable.(*clusterpropsv1.Features).Disabled {
+			req.DisabledDefaultFeatures[k] = struct{}{}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	err = addKubernetes(task, b, req, version, true)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range formerMasters {
+		_, err = kube.Run(task, b, "delete node --ignore-not-found "+name, client.DefaultExecutionTimeout)
+		if err != nil {
+			logrus.Warnf("[cluster %s] failed to remove former master '%s' from k8s nodes: %v", clusterName, name, err)
+		}
+	}
+	return nil
+}
+
+// rebindControlPlaneVIP binds the current masters to the control plane VIP, if the cluster uses one
+func rebindControlPlaneVIP(task concurrency.Task, cluster *control.Controller) error {
+	var vip *resources.VirtualIP
+	err := cluster.GetProperties(task).LockForRead(property.ControlPlaneV1).ThenUse(func(clonable data.Clonable) error {
+		vip = clonable.(*clusterpropsv1.ControlPlane).VirtualIP
+		return nil
+	})
+	if err != nil || vip == nil {
+		return err
+	}
+
+	svc := cluster.GetService(task)
+	masterIDs := cluster.ListMasterIDs(task)
+	for _, id := range masterIDs {
+		err = svc.BindHostToVIP(vip, id)
+		if err != nil {
+			return err
+		}
+	}
+	return cluster.UpdateMetadata(task, func() error {
+		return cluster.GetProperties(task).LockForWrite(property.ControlPlaneV1).ThenUse(func(clonable data.Clonable) error {
+			clonable.(*clusterpropsv1.ControlPlane).VirtualIP.Hosts = masterIDs
+			return nil
+		})
+	})
+}
@@ -0,0 +1,130 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package kubectl contains the operations on nodes shared by the flavors managed with kubectl (K8S, K3S)
+package kubectl
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	pb "github.com/CS-SI/SafeScale/lib"
+	"github.com/CS-SI/SafeScale/lib/client"
+	"github.com/CS-SI/SafeScale/lib/server/cluster/control"
+	"github.com/CS-SI/SafeScale/lib/utils/cli/enums/outputs"
+	"github.com/CS-SI/SafeScale/lib/utils/concurrency"
+	"github.com/CS-SI/SafeScale/lib/utils/temporal"
+)
+
+// Kubectl runs kubectl on an available master of the cluster
+// Its methods have the signatures of the corresponding fields of control.Makers.
+type Kubectl struct {
+	// command is the command line running kubectl on a master, like "sudo -u cladm -i kubectl"
+	command string
+}
+
+// New creates a Kubectl running kubectl on the masters with command
+func New(command string) *Kubectl {
+	return &Kubectl{command: command}
+}
+
+// Run runs kubectl with args on an available master and returns its output
+func (k *Kubectl) Run(task concurrency.Task, b control.Foreman, args string, timeout time.Duration) (string, error) {
+	selectedMaster, err := b.Cluster().FindAvailableMaster(task)
+	if err != nil {
+		return "", err
+	}
+
+	cmd := k.command + " " + args
+	retcode, stdout, stderr, err := client.New().SSH.Run(selectedMaster, cmd, outputs.COLLECT, client.DefaultConnectionTimeout, timeout)
+	if err != nil {
+		return "", err
+	}
+	if retcode != 0 {
+		return "", fmt.Errorf("error running 'kubectl %s': errorcode %d, %s", args, retcode, stderr)
+	}
+	return stdout, nil
+}
+
+// LabelNode sets the labels and the taints of the node
+func (k *Kubectl) LabelNode(task concurrency.Task, b control.Foreman, pbHost *pb.Host, labels map[string]string, taints []string) error {
+	if len(labels) > 0 {
+		var list []string
+		for key, value := range labels {
+			list = append(list, fmt.Sprintf("'%s=%s'", key, value))
+		}
+		sort.Strings(list)
+		_, err := k.Run(task, b, fmt.Sprintf("label node %s %s --overwrite", pbHost.Name, strings.Join(list, " ")), client.DefaultExecutionTimeout)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(taints) > 0 {
+		var list []string
+		for _, v := range taints {
+			list = append(list, fmt.Sprintf("'%s'", v))
+		}
+		_, err := k.Run(task, b, fmt.Sprintf("taint node %s %s --overwrite", pbHost.Name, strings.Join(list, " ")), client.DefaultExecutionTimeout)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CountPendingPods returns the number of pods waiting to be scheduled
+func (k *Kubectl) CountPendingPods(task concurrency.Task, b control.Foreman) (int, error) {
+	out, err := k.Run(task, b, "get pods --all-namespaces --field-selector=status.phase=Pending --no-headers | wc -l", client.DefaultExecutionTimeout)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(out))
+}
+
+// GetNodesReadiness returns the readiness of the nodes, indexed by hostname
+func (k *Kubectl) GetNodesReadiness(task concurrency.Task, b control.Foreman) (map[string]bool, error) {
+	out, err := k.Run(task, b, "get nodes --no-headers", client.DefaultExecutionTimeout)
+	if err != nil {
+		return nil, err
+	}
+	readiness := map[string]bool{}
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 {
+			// STATUS may be 'Ready', 'NotReady' or 'Ready,SchedulingDisabled'
+			readiness[fields[0]] = strings.Split(fields[1], ",")[0] == "Ready"
+		}
+	}
+	return readiness, nil
+}
+
+// DrainNode evicts the pods of the node and marks it unschedulable
+func (k *Kubectl) DrainNode(task concurrency.Task, b control.Foreman, pbHost *pb.Host) error {
+	args := fmt.Sprintf("drain %s --ignore-daemonsets --delete-local-data --force --timeout=%ds", pbHost.Name, int(temporal.GetLongOperationTimeout().Seconds()))
+	_, err := k.Run(task, b, args, temporal.GetLongOperationTimeout())
+	return err
+}
+
+// UncordonNode allows again pods to be scheduled on the node
+func (k *Kubectl) UncordonNode(task concurrency.Task, b control.Foreman, pbHost *pb.Host) error {
+	_, err := k.Run(task, b, "uncordon "+pbHost.Name, client.DefaultExecutionTimeout)
+	return err
+}
@@ -0,0 +1,572 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package nomad
+
+/*
+ * Implements a HashiCorp Nomad cluster: masters run Consul and Nomad servers, nodes run Consul and Nomad clients
+ * using the docker driver
+ */
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	rice "github.com/GeertJohan/go.rice"
+	"github.com/sirupsen/logrus"
+
+	pb "github.com/CS-SI/SafeScale/lib"
+	"github.com/CS-SI/SafeScale/lib/client"
+	"github.com/CS-SI/SafeScale/lib/server/cluster/api"
+	"github.com/CS-SI/SafeScale/lib/server/cluster/control"
+	clusterpropsv1 "github.com/CS-SI/SafeScale/lib/server/cluster/control/properties/v1"
+	"github.com/CS-SI/SafeScale/lib/server/cluster/enums/clusterstate"
+	"github.com/CS-SI/SafeScale/lib/server/cluster/enums/complexity"
+	"github.com/CS-SI/SafeScale/lib/server/cluster/enums/nodetype"
+	"github.com/CS-SI/SafeScale/lib/server/cluster/enums/property"
+	"github.com/CS-SI/SafeScale/lib/server/install"
+	"github.com/CS-SI/SafeScale/lib/utils/cli/enums/outputs"
+	"github.com/CS-SI/SafeScale/lib/utils/concurrency"
+	"github.com/CS-SI/SafeScale/lib/utils/data"
+	"github.com/CS-SI/SafeScale/lib/utils/scerr"
+	"github.com/CS-SI/SafeScale/lib/utils/template"
+	"github.com/CS-SI/SafeScale/lib/utils/temporal"
+)
+
+//go:generate rice embed-go
+
+var (
+	templateBox                     atomic.Value
+	globalSystemRequirementsContent atomic.Value
+
+	// Makers initializes a control.Makers struct to construct a Nomad Cluster
+	Makers = control.Makers{
+		MinimumRequiredServers:      minimumRequiredServers,
+		DefaultGatewaySizing:        gatewaySizing,
+		DefaultMasterSizing:         masterSizing,
+		DefaultNodeSizing:           nodeSizing,
+		DefaultImage:                defaultImage,
+		GetTemplateBox:              getTemplateBox,
+		GetGlobalSystemRequirements: getGlobalSystemRequirements,
+		GetNodeInstallationScript:   getNodeInstallationScript,
+		ConfigureCluster:            configureCluster,
+		LeaveNodeFromCluster:        leaveNodeFromCluster,
+		GetNodesReadiness:           getNodesReadiness,
+		DrainNode:                   drainNode,
+		UncordonNode:                uncordonNode,
+		GetPlatformVersion:          getPlatformVersion,
+		UpgradePlatform:             upgradePlatform,
+		RollbackPlatform:            rollbackPlatform,
+		GetState:                    getState,
+	}
+)
+
+func minimumRequiredServers(task concurrency.Task, foreman control.Foreman) (int, int, int) {
+	var masterCount, privateNodeCount int
+
+	// Raft of Consul and Nomad servers needs an odd number of masters
+	switch foreman.Cluster().GetIdentity(task).Complexity {
+	case complexity.Small:
+		masterCount = 1
+		privateNodeCount = 1
+	case complexity.Normal:
+		masterCount = 3
+		privateNodeCount = 3
+	case complexity.Large:
+		masterCount = 5
+		privateNodeCount = 6
+	}
+	return masterCount, privateNodeCount, 0
+}
+
+func gatewaySizing(task concurrency.Task, foreman control.Foreman) pb.HostDefinition {
+	return pb.HostDefinition{
+		Sizing: &pb.HostSizing{
+			MinCpuCount: 1,
+			MaxCpuCount: 2,
+			MinRamSize:  2.0,
+			MaxRamSize:  4.0,
+			MinDiskSize: 20,
+			GpuCount:    -1,
+		},
+	}
+}
+
+func masterSizing(task concurrency.Task, foreman control.Foreman) pb.HostDefinition {
+	return pb.HostDefinition{
+		Sizing: &pb.HostSizing{
+			MinCpuCount: 2,
+			MaxCpuCount: 4,
+			MinRamSize:  4.0,
+			MaxRamSize:  8.0,
+			MinDiskSize: 40,
+			GpuCount:    -1,
+		},
+	}
+}
+
+func nodeSizing(task concurrency.Task, foreman control.Foreman) pb.HostDefinition {
+	return pb.HostDefinition{
+		Sizing: &pb.HostSizing{
+			MinCpuCount: 4,
+			MaxCpuCount: 8,
+			MinRamSize:  7.0,
+			MaxRamSize:  16.0,
+			MinDiskSize: 80,
+			GpuCount:    -1,
+		},
+	}
+}
+
+func defaultImage(task concurrency.Task, foreman control.Foreman) string {
+	return "Ubuntu 18.04"
+}
+
+func getTemplateBox() (*rice.Box, error) {
+	anon := templateBox.Load()
+	if anon == nil {
+		// Note: path MUST be literal for rice to work
+		b, err := rice.FindBox("../nomad/scripts")
+		if err != nil {
+			return nil, err
+		}
+		templateBox.Store(b)
+		anon = templateBox.Load()
+	}
+	return anon.(*rice.Box), nil
+}
+
+func getGlobalSystemRequirements(task concurrency.Task, foreman control.Foreman) (string, error) {
+	anon := globalSystemRequirementsContent.Load()
+	if anon == nil {
+		// find the rice.Box
+		box, err := getTemplateBox()
+		if err != nil {
+			return "", err
+		}
+
+		// We will need information from cluster network
+		cluster := foreman.Cluster()
+		netCfg, err := cluster.GetNetworkConfig(task)
+		if err != nil {
+			return "", err
+		}
+
+		// get file contents as string
+		tmplString, err := box.String("nomad_install_requirements.sh")
+		if err != nil {
+			return "", fmt.Errorf("error loading script template: %s", err.Error())
+		}
+
+		// parse then execute the template
+		tmplPrepared, err := template.Parse("install_requirements", tmplString, nil)
+		if err != nil {
+			return "", fmt.Errorf("error parsing script template: %s", err.Error())
+		}
+		dataBuffer := bytes.NewBufferString("")
+		identity := cluster.GetIdentity(task)
+		err = tmplPrepared.Execute(dataBuffer, map[string]interface{}{
+			"CIDR":                 netCfg.CIDR,
+			"ClusterAdminUsername": "cladm",
+			"ClusterAdminPassword": identity.AdminPassword,
+			"SSHPublicKey":         identity.Keypair.PublicKey,
+			"SSHPrivateKey":        identity.Keypair.PrivateKey,
+		})
+		if err != nil {
+			return "", fmt.Errorf("error realizing script template: %s", err.Error())
+		}
+		globalSystemRequirementsContent.Store(dataBuffer.String())
+		anon = globalSystemRequirementsContent.Load()
+	}
+	return anon.(string), nil
+}
+
+func getNodeInstallationScript(task concurrency.Task, foreman control.Foreman, nodeType nodetype.Enum) (string, map[string]interface{}) {
+	script := ""
+	theData := map[string]interface{}{}
+
+	switch nodeType {
+	case nodetype.Gateway:
+		script = "nomad_install_gateway.sh"
+	case nodetype.Master:
+		script = "nomad_install_master.sh"
+	case nodetype.Node:
+		script = "nomad_install_node.sh"
+	}
+	return script, theData
+}
+
+// configureCluster installs Consul and Nomad with the feature 'nomad', using the gossip keys of the cluster
+// (generated at first call), then bootstraps the ACL system of Nomad and gives its management token to
+// the cluster admin on the masters
+// As this function is called again when masters or nodes are added, every step is idempotent.
+func configureCluster(task concurrency.Task, foreman control.Foreman, req control.Request) (err error) {
+	cluster := foreman.Cluster().(*control.Controller)
+	clusterName := cluster.GetIdentity(task).Name
+
+	secrets, err := generateGossipKeys(task, cluster)
+	if err != nil {
+		return err
+	}
+
+	logrus.Println(fmt.Sprintf("[cluster %s] adding feature 'nomad'...", clusterName))
+	target, err := install.NewClusterTarget(task, foreman.Cluster())
+	if err != nil {
+		return err
+	}
+	feature, err := install.NewFeature(task, "nomad")
+	if err != nil {
+		logrus.Errorf("[cluster %s] failed to instantiate feature 'nomad': %v", clusterName, err)
+		return fmt.Errorf("failed to prepare feature 'nomad': %s", err.Error())
+	}
+	results, err := feature.Add(target, install.Variables{
+		"ConsulGossipKey": secrets.ConsulGossipKey,
+		"NomadGossipKey":  secrets.NomadGossipKey,
+	}, install.Settings{})
+	if err != nil {
+		logrus.Errorf("[cluster %s] failed to add feature 'nomad': %s", clusterName, err.Error())
+		return err
+	}
+	if !results.Successful() {
+		err = fmt.Errorf(results.AllErrorMessages())
+		logrus.Errorf("[cluster %s] failed to add feature 'nomad': %s", clusterName, err.Error())
+		return err
+	}
+	logrus.Println(fmt.Sprintf("[cluster %s] feature 'nomad' addition successful.", clusterName))
+
+	secrets, err = bootstrapACL(task, cluster, secrets)
+	if err != nil {
+		return err
+	}
+	return distributeACLToken(task, cluster, secrets.ACLSecretID)
+}
+
+// generateGossipKeys returns the secrets of the cluster, generating the gossip keys of Consul and Nomad if not
+// already done
+func generateGossipKeys(task concurrency.Task, cluster *control.Controller) (*clusterpropsv1.Nomad, error) {
+	secrets := &clusterpropsv1.Nomad{}
+	err := cluster.GetProperties(task).LockForRead(property.NomadV1).ThenUse(func(clonable data.Clonable) error {
+		secrets = clonable.(*clusterpropsv1.Nomad).Clone().(*clusterpropsv1.Nomad)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if secrets.ConsulGossipKey != "" && secrets.NomadGossipKey != "" {
+		return secrets, nil
+	}
+
+	consulKey, err := newGossipKey()
+	if err != nil {
+		return nil, err
+	}
+	nomadKey, err := newGossipKey()
+	if err != nil {
+		return nil, err
+	}
+	err = cluster.UpdateMetadata(task, func() error {
+		return cluster.GetProperties(task).LockForWrite(property.NomadV1).ThenUse(func(clonable data.Clonable) error {
+			nomadV1 := clonable.(*clusterpropsv1.Nomad)
+			nomadV1.ConsulGossipKey = consulKey
+			nomadV1.NomadGossipKey = nomadKey
+			secrets = nomadV1.Clone().(*clusterpropsv1.Nomad)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return secrets, nil
+}
+
+// newGossipKey returns a random 32-bytes key encoded in base64, as expected by Consul and Nomad
+func newGossipKey() (string, error) {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate gossip key: %s", err.Error())
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// bootstrapACL bootstraps the ACL system of Nomad if not already done, and records the management token
+func bootstrapACL(task concurrency.Task, cluster *control.Controller, secrets *clusterpropsv1.Nomad) (*clusterpropsv1.Nomad, error) {
+	if secrets.ACLSecretID != "" {
+		return secrets, nil
+	}
+
+	out, err := runNomad(task, cluster, "acl bootstrap", client.DefaultExecutionTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to bootstrap ACL system of Nomad (if already done, the management token is lost and has to be reset): %s", err.Error())
+	}
+	// Output contains lines like 'Secret ID    = 1d2b3c4e-...'
+	var accessorID, secretID string
+	for _, line := range strings.Split(out, "\n") {
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		switch strings.TrimSpace(parts[0]) {
+		case "Accessor ID":
+			accessorID = strings.TrimSpace(parts[1])
+		case "Secret ID":
+			secretID = strings.TrimSpace(parts[1])
+		}
+	}
+	if secretID == "" {
+		return nil, scerr.InconsistentError(fmt.Sprintf("failed to find secret ID of bootstrap token in output of 'nomad acl bootstrap': %s", out))
+	}
+
+	err = cluster.UpdateMetadata(task, func() error {
+		return cluster.GetProperties(task).LockForWrite(property.NomadV1).ThenUse(func(clonable data.Clonable) error {
+			nomadV1 := clonable.(*clusterpropsv1.Nomad)
+			nomadV1.ACLAccessorID = accessorID
+			nomadV1.ACLSecretID = secretID
+			secrets = nomadV1.Clone().(*clusterpropsv1.Nomad)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return secrets, nil
+}
+
+// distributeACLToken writes the management token of Nomad in ~cladm/.nomad-token on the masters, making
+// 'sudo -u cladm -i nomad' authenticated
+func distributeACLToken(task concurrency.Task, cluster *control.Controller, token string) error {
+	cmd := fmt.Sprintf("sudo bash -c \"echo '%s' >~cladm/.nomad-token && chown cladm:cladm ~cladm/.nomad-token && chmod 0400 ~cladm/.nomad-token\"", token)
+	for _, id := range cluster.ListMasterIDs(task) {
+		retcode, _, stderr, err := client.New().SSH.Run(id, cmd, outputs.COLLECT, client.DefaultConnectionTimeout, client.DefaultExecutionTimeout)
+		if err != nil {
+			return err
+		}
+		if retcode != 0 {
+			return fmt.Errorf("error writing Nomad token on master '%s': errorcode %d, %s", id, retcode, stderr)
+		}
+	}
+	return nil
+}
+
+// runNomad runs nomad with args as cluster admin on an available master
+func runNomad(task concurrency.Task, cluster api.Cluster, args string, timeout time.Duration) (string, error) {
+	selectedMaster, err := cluster.FindAvailableMaster(task)
+	if err != nil {
+		return "", err
+	}
+
+	cmd := "sudo -u cladm -i nomad " + args
+	retcode, stdout, stderr, err := client.New().SSH.Run(selectedMaster, cmd, outputs.COLLECT, client.DefaultConnectionTimeout, timeout)
+	if err != nil {
+		return "", err
+	}
+	if retcode != 0 {
+		return "", fmt.Errorf("error running 'nomad %s': errorcode %d, %s", args, retcode, stderr)
+	}
+	return stdout, nil
+}
+
+// getNodeID returns the ID of the Nomad client running on the host, or an empty string if Nomad doesn't know it
+func getNodeID(task concurrency.Task, b control.Foreman, pbHost *pb.Host) (string, error) {
+	args := fmt.Sprintf(`node status -t '{{range .}}{{if eq .Name "%s"}}{{.ID}}{{end}}{{end}}'`, pbHost.Name)
+	out, err := runNomad(task, b.Cluster(), args, client.DefaultExecutionTimeout)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// leaveNodeFromCluster drains the Nomad client of the host, then stops Nomad and makes Consul leave
+func leaveNodeFromCluster(task concurrency.Task, b control.Foreman, pbHost *pb.Host, selectedMaster string) error {
+	nodeID, err := getNodeID(task, b, pbHost)
+	if err != nil {
+		return err
+	}
+	if nodeID == "" {
+		return nil // not there, nothing to do
+	}
+
+	err = drainNode(task, b, pbHost)
+	if err != nil {
+		return err
+	}
+
+	cmd := "sudo systemctl stop nomad && consul leave"
+	retcode, _, stderr, err := client.New().SSH.Run(pbHost.Id, cmd, outputs.COLLECT, client.DefaultConnectionTimeout, client.DefaultExecutionTimeout)
+	if err != nil {
+		return err
+	}
+	if retcode != 0 {
+		return fmt.Errorf("error stopping Nomad and Consul on host '%s': errorcode %d, %s", pbHost.Name, retcode, stderr)
+	}
+	return nil
+}
+
+// getNodesReadiness returns the readiness of the Nomad clients, indexed by hostname
+func getNodesReadiness(task concurrency.Task, b control.Foreman) (map[string]bool, error) {
+	out, err := runNomad(task, b.Cluster(), `node status -t '{{range .}}{{.Name}} {{.Status}}{{"\n"}}{{end}}'`, client.DefaultExecutionTimeout)
+	if err != nil {
+		return nil, err
+	}
+	readiness := map[string]bool{}
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 {
+			// Status may be 'initializing', 'ready' or 'down'
+			readiness[fields[0]] = fields[1] == "ready"
+		}
+	}
+	return readiness, nil
+}
+
+// drainNode migrates the allocations of the Nomad client and marks it ineligible
+func drainNode(task concurrency.Task, b control.Foreman, pbHost *pb.Host) error {
+	nodeID, err := getNodeID(task, b, pbHost)
+	if err != nil {
+		return err
+	}
+	if nodeID == "" {
+		return scerr.NotFoundError(fmt.Sprintf("host '%s' is not a Nomad client", pbHost.Name))
+	}
+	args := fmt.Sprintf("node drain -enable -yes -deadline %ds %s", int(temporal.GetLongOperationTimeout().Seconds()), nodeID)
+	_, err = runNomad(task, b.Cluster(), args, temporal.GetLongOperationTimeout())
+	return err
+}
+
+// uncordonNode allows again allocations to be scheduled on the Nomad client
+func uncordonNode(task concurrency.Task, b control.Foreman, pbHost *pb.Host) error {
+	nodeID, err := getNodeID(task, b, pbHost)
+	if err != nil {
+		return err
+	}
+	if nodeID == "" {
+		return scerr.NotFoundError(fmt.Sprintf("host '%s' is not a Nomad client", pbHost.Name))
+	}
+	_, err = runNomad(task, b.Cluster(), "node drain -disable -yes "+nodeID, client.DefaultExecutionTimeout)
+	if err != nil {
+		return err
+	}
+	_, err = runNomad(task, b.Cluster(), "node eligibility -enable "+nodeID, client.DefaultExecutionTimeout)
+	return err
+}
+
+// getPlatformVersion returns the version of Nomad installed on the host
+func getPlatformVersion(task concurrency.Task, b control.Foreman, pbHost *pb.Host) (string, error) {
+	cmd := "nomad version"
+	retcode, stdout, stderr, err := client.New().SSH.Run(pbHost.Id, cmd, outputs.COLLECT, client.DefaultConnectionTimeout, client.DefaultExecutionTimeout)
+	if err != nil {
+		return "", err
+	}
+	if retcode != 0 {
+		return "", fmt.Errorf("error getting version of Nomad on host '%s': errorcode %d, %s", pbHost.Name, retcode, stderr)
+	}
+	// Output is 'Nomad v1.0.1 (c9c68aa55a7275f22d2338f2df53e67ebfcb9238)'
+	fields := strings.Fields(stdout)
+	if len(fields) < 2 {
+		return "", fmt.Errorf("unexpected output of nomad on host '%s': %s", pbHost.Name, stdout)
+	}
+	return strings.TrimPrefix(fields[1], "v"), nil
+}
+
+// installNomad replaces the binary of Nomad on the host by the one of version, then restarts Nomad
+func installNomad(pbHost *pb.Host, version string) error {
+	version = strings.TrimPrefix(version, "v")
+	cmd := fmt.Sprintf(`sudo bash -c '
+cd /tmp && \
+curl -fsSL -o nomad_%[1]s.zip https://releases.hashicorp.com/nomad/%[1]s/nomad_%[1]s_linux_amd64.zip && \
+unzip -o nomad_%[1]s.zip nomad -d /tmp/nomad_%[1]s && \
+mv -f /tmp/nomad_%[1]s/nomad /usr/local/bin/nomad && \
+chmod 0755 /usr/local/bin/nomad || exit 1
+rm -rf /tmp/nomad_%[1]s*
+systemctl restart nomad'`, version)
+	retcode, _, stderr, err := client.New().SSH.Run(pbHost.Id, cmd, outputs.COLLECT, client.DefaultConnectionTimeout, temporal.GetLongOperationTimeout())
+	if err != nil {
+		return err
+	}
+	if retcode != 0 {
+		return fmt.Errorf("error installing Nomad %s on host '%s': errorcode %d, %s", version, pbHost.Name, retcode, stderr)
+	}
+	return nil
+}
+
+// upgradePlatform upgrades Nomad on the host; servers and clients are upgraded the same way, servers first
+func upgradePlatform(task concurrency.Task, b control.Foreman, pbHost *pb.Host, version string, master bool, first bool) error {
+	return installNomad(pbHost, version)
+}
+
+// rollbackPlatform restores the previous version of Nomad on a node
+func rollbackPlatform(task concurrency.Task, b control.Foreman, pbHost *pb.Host, version string) error {
+	return installNomad(pbHost, version)
+}
+
+// getState tells the state of the cluster from the Nomad servers and the Consul agents:
+// - Nominal if Nomad has a leader and every Nomad server and Consul agent is alive
+// - Degraded if Nomad has a leader but some servers or agents are failing
+// - Error otherwise
+func getState(task concurrency.Task, foreman control.Foreman) (clusterstate.Enum, error) {
+	out, err := runNomad(task, foreman.Cluster(), `server members`, client.DefaultExecutionTimeout)
+	if err != nil {
+		return clusterstate.Error, err
+	}
+	// Columns are 'Name Address Port Status Leader Protocol Build Datacenter Region'
+	leader := false
+	failing := 0
+	for _, line := range strings.Split(out, "\n")[1:] {
+		fields := strings.Fields(line)
+		if len(fields) < 5 {
+			continue
+		}
+		if fields[3] != "alive" {
+			failing++
+		}
+		if fields[4] == "true" {
+			leader = true
+		}
+	}
+	if !leader {
+		return clusterstate.Error, nil
+	}
+
+	selectedMaster, err := foreman.Cluster().FindAvailableMaster(task)
+	if err != nil {
+		return clusterstate.Error, err
+	}
+	// Agents that left (removed nodes) are not failing
+	cmd := "consul members -status failed"
+	retcode, stdout, stderr, err := client.New().SSH.Run(selectedMaster, cmd, outputs.COLLECT, client.DefaultConnectionTimeout, client.DefaultExecutionTimeout)
+	if err != nil {
+		return clusterstate.Error, err
+	}
+	switch retcode {
+	case 0:
+		// lists the failing agents after a header line
+		failing += len(strings.Split(strings.TrimSpace(stdout), "\n")) - 1
+	case 2:
+		// no agent matching status
+	default:
+		return clusterstate.Error, fmt.Errorf("error running 'consul members': errorcode %d, %s", retcode, stderr)
+	}
+
+	if failing > 0 {
+		return clusterstate.Degraded, nil
+	}
+	return clusterstate.Nominal, nil
+}
@@ -23,6 +23,8 @@ package ohpc
 import (
 	"bytes"
 	"fmt"
+	"strconv"
+	"strings"
 	"sync/atomic"
 	txttmpl "text/template"
 
@@ -30,10 +32,12 @@ import (
 	rice "github.com/GeertJohan/go.rice"
 
 	pb "github.com/CS-SI/SafeScale/lib"
+	"github.com/CS-SI/SafeScale/lib/client"
 	"github.com/CS-SI/SafeScale/lib/server/cluster/control"
 	"github.com/CS-SI/SafeScale/lib/server/cluster/enums/complexity"
 	"github.com/CS-SI/SafeScale/lib/server/cluster/enums/nodetype"
 	"github.com/CS-SI/SafeScale/lib/server/cluster/flavors/ohpc/enums/errorcode"
+	"github.com/CS-SI/SafeScale/lib/utils/cli/enums/outputs"
 	"github.com/CS-SI/SafeScale/lib/utils/concurrency"
 	"github.com/CS-SI/SafeScale/lib/utils/template"
 )
@@ -74,6 +78,7 @@ var (
 		GetTemplateBox:              getTemplateBox,
 		GetGlobalSystemRequirements: getGlobalSystemRequirements,
 		GetNodeInstallationScript:   getNodeInstallationScript,
+		CountPendingWork:            countPendingJobs,
 		// ConfigureCluster:            configureCluster,
 	}
 )
@@ -238,3 +243,21 @@ func getGlobalSystemRequirements(task concurrency.Task, foreman control.Foreman)
 	}
 	return anon.(string), nil
 }
+
+// countPendingJobs returns the number of Slurm jobs waiting for resources
+func countPendingJobs(task concurrency.Task, foreman control.Foreman) (int, error) {
+	selectedMaster, err := foreman.Cluster().FindAvailableMaster(task)
+	if err != nil {
+		return 0, err
+	}
+
+	cmd := "squeue --noheader --states=PENDING | wc -l"
+	retcode, stdout, stderr, err := client.New().SSH.Run(selectedMaster, cmd, outputs.COLLECT, client.DefaultConnectionTimeout, client.DefaultExecutionTimeout)
+	if err != nil {
+		return 0, err
+	}
+	if retcode != 0 {
+		return 0, fmt.Errorf("error listing pending slurm jobs: errorcode %d, %s", retcode, stderr)
+	}
+	return strconv.Atoi(strings.TrimSpace(stdout))
+}
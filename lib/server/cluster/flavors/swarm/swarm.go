@@ -23,17 +23,21 @@ package swarm
 import (
 	"bytes"
 	"fmt"
+	"strings"
 	"sync/atomic"
 
 	rice "github.com/GeertJohan/go.rice"
 	// log "github.com/sirupsen/logrus"
 
 	pb "github.com/CS-SI/SafeScale/lib"
+	"github.com/CS-SI/SafeScale/lib/client"
 	"github.com/CS-SI/SafeScale/lib/server/cluster/control"
 	"github.com/CS-SI/SafeScale/lib/server/cluster/enums/complexity"
 	"github.com/CS-SI/SafeScale/lib/server/cluster/enums/nodetype"
+	"github.com/CS-SI/SafeScale/lib/utils/cli/enums/outputs"
 	"github.com/CS-SI/SafeScale/lib/utils/concurrency"
 	"github.com/CS-SI/SafeScale/lib/utils/template"
+	"github.com/CS-SI/SafeScale/lib/utils/temporal"
 )
 
 //go:generate rice embed-go
@@ -54,6 +58,11 @@ var (
 		GetTemplateBox:              getTemplateBox,
 		GetGlobalSystemRequirements: getGlobalSystemRequirements,
 		GetNodeInstallationScript:   getNodeInstallationScript,
+		DrainNode:                   drainNode,
+		UncordonNode:                uncordonNode,
+		GetPlatformVersion:          getPlatformVersion,
+		UpgradePlatform:             upgradePlatform,
+		RollbackPlatform:            rollbackPlatform,
 	}
 )
 
@@ -194,3 +203,77 @@ func getNodeInstallationScript(task concurrency.Task, foreman control.Foreman, h
 	}
 	return script, data
 }
+
+// setNodeAvailability changes the availability of the swarm node from an available master
+func setNodeAvailability(task concurrency.Task, b control.Foreman, pbHost *pb.Host, availability string) error {
+	selectedMaster, err := b.Cluster().FindAvailableMaster(task)
+	if err != nil {
+		return err
+	}
+
+	cmd := fmt.Sprintf("sudo docker node update --availability %s %s", availability, pbHost.Name)
+	retcode, _, stderr, err := client.New().SSH.Run(selectedMaster, cmd, outputs.COLLECT, client.DefaultConnectionTimeout, client.DefaultExecutionTimeout)
+	if err != nil {
+		return err
+	}
+	if retcode != 0 {
+		return fmt.Errorf("error setting availability of swarm node %s to %s: errorcode %d, %s", pbHost.Name, availability, retcode, stderr)
+	}
+	return nil
+}
+
+// drainNode moves the tasks of the swarm node to the other nodes
+func drainNode(task concurrency.Task, b control.Foreman, pbHost *pb.Host) error {
+	return setNodeAvailability(task, b, pbHost, "drain")
+}
+
+// uncordonNode allows again the scheduling of tasks on the swarm node
+func uncordonNode(task concurrency.Task, b control.Foreman, pbHost *pb.Host) error {
+	return setNodeAvailability(task, b, pbHost, "active")
+}
+
+// getPlatformVersion returns the version of the docker engine of the host
+func getPlatformVersion(task concurrency.Task, b control.Foreman, pbHost *pb.Host) (string, error) {
+	cmd := "sudo docker version --format '{{.Server.Version}}'"
+	retcode, stdout, stderr, err := client.New().SSH.Run(pbHost.Id, cmd, outputs.COLLECT, client.DefaultConnectionTimeout, client.DefaultExecutionTimeout)
+	if err != nil {
+		return "", err
+	}
+	if retcode != 0 {
+		return "", fmt.Errorf("error getting version of docker on host '%s': errorcode %d, %s", pbHost.Name, retcode, stderr)
+	}
+	return strings.TrimSpace(stdout), nil
+}
+
+// installDocker installs the version of docker engine on the host
+// The version of the package is looked up, as packages are named like '5:19.03.8~3-0~ubuntu-bionic'.
+func installDocker(pbHost *pb.Host, version string) error {
+	cmd := fmt.Sprintf(`sudo bash -c '
+if which apt-get >/dev/null 2>&1; then
+	apt-get update || exit 1
+	PKG=$(apt-cache madison docker-ce | awk "{ print \$3 }" | grep -F ":%[1]s~" | head -n 1)
+	[ -z "$PKG" ] && echo "docker-ce %[1]s not found" >&2 && exit 2
+	DEBIAN_FRONTEND=noninteractive apt-get install -y --allow-downgrades docker-ce=$PKG docker-ce-cli=$PKG || exit 3
+else
+	yum -y install docker-ce-%[1]s docker-ce-cli-%[1]s || yum -y downgrade docker-ce-%[1]s docker-ce-cli-%[1]s || exit 3
+fi
+systemctl restart docker'`, version)
+	retcode, _, stderr, err := client.New().SSH.Run(pbHost.Id, cmd, outputs.COLLECT, client.DefaultConnectionTimeout, temporal.GetLongOperationTimeout())
+	if err != nil {
+		return err
+	}
+	if retcode != 0 {
+		return fmt.Errorf("error installing docker %s on host '%s': errorcode %d, %s", version, pbHost.Name, retcode, stderr)
+	}
+	return nil
+}
+
+// upgradePlatform upgrades the docker engine of the host; swarm managers and workers are upgraded the same way
+func upgradePlatform(task concurrency.Task, b control.Foreman, pbHost *pb.Host, version string, master bool, first bool) error {
+	return installDocker(pbHost, version)
+}
+
+// rollbackPlatform restores the previous version of the docker engine of the host
+func rollbackPlatform(task concurrency.Task, b control.Foreman, pbHost *pb.Host, version string) error {
+	return installDocker(pbHost, version)
+}
@@ -0,0 +1,349 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package profile
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/CS-SI/SafeScale/lib/client"
+	"github.com/CS-SI/SafeScale/lib/server/cluster/api"
+	clusterpropsv1 "github.com/CS-SI/SafeScale/lib/server/cluster/control/properties/v1"
+	clusterpropsv2 "github.com/CS-SI/SafeScale/lib/server/cluster/control/properties/v2"
+	"github.com/CS-SI/SafeScale/lib/server/cluster/enums/complexity"
+	"github.com/CS-SI/SafeScale/lib/server/cluster/enums/flavor"
+	"github.com/CS-SI/SafeScale/lib/server/cluster/enums/property"
+	"github.com/CS-SI/SafeScale/lib/server/iaas"
+	"github.com/CS-SI/SafeScale/lib/server/iaas/resources"
+	"github.com/CS-SI/SafeScale/lib/utils/concurrency"
+	"github.com/CS-SI/SafeScale/lib/utils/data"
+	"github.com/CS-SI/SafeScale/lib/utils/metadata"
+	"github.com/CS-SI/SafeScale/lib/utils/scerr"
+	"github.com/CS-SI/SafeScale/lib/utils/temporal"
+)
+
+const (
+	// profilesFolderName is the technical name of the container used to store cluster profiles
+	profilesFolderName = "cluster-profiles"
+)
+
+// Pool describes a node pool to create with the cluster
+type Pool struct {
+	Name   string            `json:"name"`             // Name of the pool
+	Count  int               `json:"count"`            // Count is the number of nodes wanted in the pool
+	Sizing string            `json:"sizing,omitempty"` // Sizing of the nodes, in the format of 'cluster create --sizing'
+	Image  string            `json:"image,omitempty"`  // Image used to create the nodes of the pool
+	Public bool              `json:"public,omitempty"` // Public tells if the nodes of the pool have a public IP
+	Labels map[string]string `json:"labels,omitempty"` // Labels to set on the nodes (if the flavor supports it)
+	Taints []string          `json:"taints,omitempty"` // Taints to set on the nodes (if the flavor supports it)
+}
+
+// Feature describes a feature to add to the cluster once created
+type Feature struct {
+	Name   string            `json:"name"`             // Name of the feature
+	Params map[string]string `json:"params,omitempty"` // Params contains the values of the parameters of the feature
+}
+
+// Profile describes a reusable configuration of cluster
+type Profile struct {
+	Name          string    `json:"name"`
+	Description   string    `json:"description,omitempty"`
+	Flavor        string    `json:"flavor,omitempty"`         // Flavor of the cluster (K8S, SWARM, ...)
+	Complexity    string    `json:"complexity,omitempty"`     // Complexity of the cluster (Small, Normal, Large)
+	CIDR          string    `json:"cidr,omitempty"`           // CIDR of the network of the cluster
+	Image         string    `json:"image,omitempty"`          // Image used to create the hosts
+	GatewaySizing string    `json:"gateway_sizing,omitempty"` // Sizing of the gateways, in the format of 'cluster create --sizing'
+	MasterSizing  string    `json:"master_sizing,omitempty"`  // Sizing of the masters, in the format of 'cluster create --sizing'
+	NodeSizing    string    `json:"node_sizing,omitempty"`    // Sizing of the nodes, in the format of 'cluster create --sizing'
+	Disabled      []string  `json:"disabled,omitempty"`       // Disabled lists the default features not to install
+	Pools         []Pool    `json:"pools,omitempty"`          // Pools lists the node pools to create once the cluster is created
+	Features      []Feature `json:"features,omitempty"`       // Features lists the features to add once the cluster is created
+}
+
+// Validate checks the content of the profile
+func (p *Profile) Validate() error {
+	if p == nil {
+		return scerr.InvalidInstanceError()
+	}
+	if p.Name == "" {
+		return scerr.InvalidParameterError("Name", "cannot be empty string")
+	}
+	if strings.ContainsAny(p.Name, "/\\") {
+		return scerr.InvalidParameterError("Name", "cannot contain '/' or '\\'")
+	}
+	if p.Flavor != "" {
+		if _, err := flavor.Parse(p.Flavor); err != nil {
+			return scerr.InvalidParameterError("Flavor", err.Error())
+		}
+	}
+	if p.Complexity != "" {
+		if _, err := complexity.Parse(p.Complexity); err != nil {
+			return scerr.InvalidParameterError("Complexity", err.Error())
+		}
+	}
+	pools := map[string]struct{}{}
+	for _, v := range p.Pools {
+		if v.Name == "" {
+			return scerr.InvalidParameterError("Pools", "name of pool cannot be empty string")
+		}
+		if _, ok := pools[v.Name]; ok {
+			return scerr.InvalidParameterError("Pools", fmt.Sprintf("pool '%s' is defined more than once", v.Name))
+		}
+		if v.Count < 0 {
+			return scerr.InvalidParameterError("Pools", fmt.Sprintf("count of pool '%s' must be an int >= 0", v.Name))
+		}
+		pools[v.Name] = struct{}{}
+	}
+	for _, v := range p.Features {
+		if v.Name == "" {
+			return scerr.InvalidParameterError("Features", "name of feature cannot be empty string")
+		}
+	}
+	return nil
+}
+
+// FormatSizing converts sizing requirements to the format of 'cluster create --sizing'
+func FormatSizing(s resources.SizingRequirements) string {
+	var parts []string
+	switch {
+	case s.MinCores > 0 && s.MaxCores > 0:
+		parts = append(parts, fmt.Sprintf("cpu=[%d-%d]", s.MinCores, s.MaxCores))
+	case s.MinCores > 0:
+		parts = append(parts, fmt.Sprintf("cpu>=%d", s.MinCores))
+	case s.MaxCores > 0:
+		parts = append(parts, fmt.Sprintf("cpu<=%d", s.MaxCores))
+	}
+	if s.MinFreq > 0 {
+		parts = append(parts, fmt.Sprintf("cpufreq>=%.01f", s.MinFreq))
+	}
+	if s.MinGPU > 0 {
+		parts = append(parts, fmt.Sprintf("gpu=%d", s.MinGPU))
+	}
+	switch {
+	case s.MinRAMSize > 0 && s.MaxRAMSize > 0:
+		parts = append(parts, fmt.Sprintf("ram=[%.01f-%.01f]", s.MinRAMSize, s.MaxRAMSize))
+	case s.MinRAMSize > 0:
+		parts = append(parts, fmt.Sprintf("ram>=%.01f", s.MinRAMSize))
+	case s.MaxRAMSize > 0:
+		parts = append(parts, fmt.Sprintf("ram<=%.01f", s.MaxRAMSize))
+	}
+	if s.MinDiskSize > 0 {
+		parts = append(parts, fmt.Sprintf("disk>=%d", s.MinDiskSize))
+	}
+	return strings.Join(parts, ",")
+}
+
+// FromCluster builds a profile reproducing the configuration of the cluster: flavor, complexity,
+// sizings, node pools and installed features
+func FromCluster(task concurrency.Task, c api.Cluster) (_ *Profile, err error) {
+	if c == nil {
+		return nil, scerr.InvalidParameterError("c", "cannot be nil")
+	}
+	if task == nil {
+		task = concurrency.RootTask()
+	}
+
+	tracer := concurrency.NewTracer(task, "", true).GoingIn()
+	defer tracer.OnExitTrace()()
+	defer scerr.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	identity := c.GetIdentity(task)
+	p := &Profile{
+		Name:        identity.Name,
+		Description: fmt.Sprintf("built from cluster '%s'", identity.Name),
+		Flavor:      identity.Flavor.String(),
+		Complexity:  identity.Complexity.String(),
+	}
+
+	netCfg, err := c.GetNetworkConfig(task)
+	if err != nil {
+		return nil, err
+	}
+	p.CIDR = netCfg.CIDR
+
+	properties := c.GetProperties(task)
+	if properties.Lookup(property.DefaultsV2) {
+		err = properties.LockForRead(property.DefaultsV2).ThenUse(func(clonable data.Clonable) error {
+			defaultsV2 := clonable.(*clusterpropsv2.Defaults)
+			p.Image = defaultsV2.Image
+			p.GatewaySizing = FormatSizing(defaultsV2.GatewaySizing)
+			p.MasterSizing = FormatSizing(defaultsV2.MasterSizing)
+			p.NodeSizing = FormatSizing(defaultsV2.NodeSizing)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = properties.LockForRead(property.FeaturesV1).ThenUse(func(clonable data.Clonable) error {
+		featuresV1 := clonable.(*clusterpropsv1.Features)
+		for k := range featuresV1.Disabled {
+			p.Disabled = append(p.Disabled, k)
+		}
+		for k := range featuresV1.Installed {
+			p.Features = append(p.Features, Feature{Name: k})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(p.Disabled)
+	sort.Slice(p.Features, func(i, j int) bool { return p.Features[i].Name < p.Features[j].Name })
+
+	pools, err := c.ListNodePools(task)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range pools {
+		if v.Tenant != "" {
+			log.Warnf("node pool '%s' is hosted by tenant '%s' and is not kept in profile", v.Name, v.Tenant)
+			continue
+		}
+		np := Pool{
+			Name:   v.Name,
+			Count:  v.Count,
+			Sizing: FormatSizing(v.Sizing),
+			Image:  v.Image,
+			Public: v.Public,
+			Taints: v.Taints,
+		}
+		if len(v.Labels) > 0 {
+			np.Labels = v.Labels
+		}
+		p.Pools = append(p.Pools, np)
+	}
+	return p, nil
+}
+
+// LoadFile reads a profile from a local JSON file
+func LoadFile(path string) (*Profile, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	p := &Profile{}
+	err = json.Unmarshal(content, p)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode profile file '%s': %s", path, err.Error())
+	}
+	err = p.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// getFolder returns the metadata folder of the profiles of the current tenant
+func getFolder() (*metadata.Folder, error) {
+	tenant, err := client.New().Tenant.Get(temporal.GetExecutionTimeout())
+	if err != nil {
+		return nil, err
+	}
+	svc, err := iaas.UseService(tenant.Name)
+	if err != nil {
+		return nil, err
+	}
+	return metadata.NewFolder(svc, profilesFolderName)
+}
+
+// Save stores the profile in the metadata of the current tenant, replacing the profile of the same name if any
+func Save(p *Profile) error {
+	err := p.Validate()
+	if err != nil {
+		return err
+	}
+	content, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	folder, err := getFolder()
+	if err != nil {
+		return err
+	}
+	return folder.Write("", p.Name, content)
+}
+
+// Load reads the profile named 'name' from the metadata of the current tenant
+func Load(name string) (*Profile, error) {
+	if name == "" {
+		return nil, scerr.InvalidParameterError("name", "cannot be empty string")
+	}
+	folder, err := getFolder()
+	if err != nil {
+		return nil, err
+	}
+	p := &Profile{}
+	err = folder.Read("", name, func(buf []byte) error {
+		return json.Unmarshal(buf, p)
+	})
+	if err != nil {
+		if _, ok := err.(scerr.ErrNotFound); ok {
+			return nil, scerr.NotFoundError(fmt.Sprintf("cluster profile '%s' not found", name))
+		}
+		return nil, err
+	}
+	return p, nil
+}
+
+// List returns the profiles stored in the metadata of the current tenant
+func List() ([]*Profile, error) {
+	folder, err := getFolder()
+	if err != nil {
+		return nil, err
+	}
+	var list []*Profile
+	err = folder.Browse("", func(buf []byte) error {
+		p := &Profile{}
+		err := json.Unmarshal(buf, p)
+		if err != nil {
+			return err
+		}
+		list = append(list, p)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+// Delete removes the profile named 'name' from the metadata of the current tenant
+func Delete(name string) error {
+	if name == "" {
+		return scerr.InvalidParameterError("name", "cannot be empty string")
+	}
+	folder, err := getFolder()
+	if err != nil {
+		return err
+	}
+	err = folder.Search("", name)
+	if err != nil {
+		if _, ok := err.(scerr.ErrNotFound); ok {
+			return scerr.NotFoundError(fmt.Sprintf("cluster profile '%s' not found", name))
+		}
+		return err
+	}
+	return folder.Delete("", name)
+}
@@ -0,0 +1,84 @@
+package profile
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/CS-SI/SafeScale/lib/server/iaas/resources"
+)
+
+func TestFormatSizing(t *testing.T) {
+	s := resources.SizingRequirements{
+		MinCores:    2,
+		MaxCores:    4,
+		MinRAMSize:  7.5,
+		MaxRAMSize:  16,
+		MinDiskSize: 100,
+		MinGPU:      -1,
+	}
+	assert.Equal(t, "cpu=[2-4],ram=[7.5-16.0],disk>=100", FormatSizing(s))
+
+	s = resources.SizingRequirements{MinCores: 8, MinFreq: 2.4, MinGPU: 1}
+	assert.Equal(t, "cpu>=8,cpufreq>=2.4,gpu=1", FormatSizing(s))
+
+	assert.Equal(t, "", FormatSizing(resources.SizingRequirements{}))
+}
+
+func TestProfile_Validate(t *testing.T) {
+	p := &Profile{Name: "analytics", Flavor: "K8S", Complexity: "Normal"}
+	assert.NoError(t, p.Validate())
+
+	p.Name = ""
+	assert.Error(t, p.Validate())
+
+	p = &Profile{Name: "analytics", Flavor: "unknown"}
+	assert.Error(t, p.Validate())
+
+	p = &Profile{Name: "analytics", Pools: []Pool{{Name: "gpu", Count: 1}, {Name: "gpu", Count: 2}}}
+	assert.Error(t, p.Validate())
+
+	p = &Profile{Name: "analytics", Features: []Feature{{}}}
+	assert.Error(t, p.Validate())
+}
+
+func TestLoadFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "profile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	path := filepath.Join(dir, "analytics.json")
+	content := `{
+	"name": "analytics",
+	"flavor": "K8S",
+	"node_sizing": "cpu=[4-8],ram=[15-32]",
+	"pools": [{"name": "gpu", "count": 2, "sizing": "gpu=1", "labels": {"accelerator": "gpu"}}],
+	"features": [{"name": "spark", "params": {"Version": "3.0.1"}}]
+}`
+	err = ioutil.WriteFile(path, []byte(content), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := LoadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "analytics", p.Name)
+	assert.Equal(t, "K8S", p.Flavor)
+	assert.Equal(t, 1, len(p.Pools))
+	assert.Equal(t, "gpu", p.Pools[0].Labels["accelerator"])
+	assert.Equal(t, "3.0.1", p.Features[0].Params["Version"])
+
+	err = ioutil.WriteFile(path, []byte(`{"flavor": "K8S"}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = LoadFile(path)
+	assert.Error(t, err)
+}
@@ -0,0 +1,313 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/CS-SI/SafeScale/lib/server/cluster"
+	"github.com/CS-SI/SafeScale/lib/server/cluster/api"
+	"github.com/CS-SI/SafeScale/lib/server/cluster/autoscale"
+	"github.com/CS-SI/SafeScale/lib/server/cluster/control"
+	clusterpropsv1 "github.com/CS-SI/SafeScale/lib/server/cluster/control/properties/v1"
+	"github.com/CS-SI/SafeScale/lib/server/iaas"
+	srvutils "github.com/CS-SI/SafeScale/lib/server/utils"
+	"github.com/CS-SI/SafeScale/lib/utils/concurrency"
+	"github.com/CS-SI/SafeScale/lib/utils/scerr"
+)
+
+//go:generate mockgen -destination=../mocks/mock_autoscalerapi.go -package=mocks github.com/CS-SI/SafeScale/lib/server/handlers AutoscalerAPI
+
+// AutoscalerAPI defines API to manage the autoscalers of cluster node pools run by safescaled
+type AutoscalerAPI interface {
+	Enable(ctx context.Context, tenant, clusterName, poolName string, policy autoscale.Policy) (*Autoscaler, error)
+	Disable(ctx context.Context, tenant, clusterName, poolName string) error
+	List(ctx context.Context, tenant string) ([]*Autoscaler, error)
+}
+
+// Autoscaler applies periodically an autoscaling policy to a node pool of a cluster
+// Its decisions are recorded as events of the job identified by ID.
+type Autoscaler struct {
+	*schedule
+	Pool   string
+	Policy autoscale.Policy
+
+	lastScaling time.Time
+}
+
+// LastScaling returns the date of the last scaling action done by the autoscaler
+func (a *Autoscaler) LastScaling() time.Time {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return a.lastScaling
+}
+
+// autoscalers runs the autoscalers in safescaled, indexed by tenant, cluster and pool
+var autoscalers = newScheduler("Autoscaler")
+
+func autoscalerKey(tenant, clusterName, poolName string) string {
+	return tenant + "/" + clusterName + "/" + poolName
+}
+
+func init() {
+	scheduleRestorers = append(scheduleRestorers, restoreAutoscalers)
+}
+
+// AutoscalerHandler autoscaler service
+type AutoscalerHandler struct {
+	service iaas.Service
+}
+
+// NewAutoscalerHandler creates an AutoscalerHandler
+func NewAutoscalerHandler(svc iaas.Service) AutoscalerAPI {
+	return &AutoscalerHandler{
+		service: svc,
+	}
+}
+
+// Enable starts the autoscaling of the node pool of the cluster following the policy, and records the policy in
+// the metadata of the cluster to restart the autoscaler with safescaled.
+// If an autoscaler is already running for this node pool, it is replaced (keeping the date of its last scaling).
+func (handler *AutoscalerHandler) Enable(
+	ctx context.Context, tenant, clusterName, poolName string, policy autoscale.Policy,
+) (as *Autoscaler, err error) {
+	if handler == nil {
+		return nil, scerr.InvalidInstanceError()
+	}
+	if tenant == "" {
+		return nil, scerr.InvalidParameterError("tenant", "cannot be empty string")
+	}
+	if clusterName == "" {
+		return nil, scerr.InvalidParameterError("clusterName", "cannot be empty string")
+	}
+	if poolName == "" {
+		poolName = control.DefaultNodePoolName
+	}
+
+	tracer := concurrency.NewTracer(nil, fmt.Sprintf("('%s', '%s', '%s')", tenant, clusterName, poolName), true).WithStopwatch().GoingIn()
+	defer tracer.OnExitTrace()()
+	defer scerr.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	err = policy.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	task := concurrency.RootTask()
+	instance, err := cluster.LoadFromService(task, handler.service, clusterName)
+	if err != nil {
+		return nil, err
+	}
+	pools, err := instance.ListNodePools(task)
+	if err != nil {
+		return nil, err
+	}
+	found := false
+	for _, p := range pools {
+		if p.Name == poolName {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, scerr.NotFoundError(fmt.Sprintf("failed to find node pool '%s' in cluster '%s'", poolName, clusterName))
+	}
+
+	created := time.Now()
+	err = instance.UpdateSchedules(task, func(schedules *clusterpropsv1.Schedules) {
+		schedules.Autoscalers[poolName] = &clusterpropsv1.AutoscaleSchedule{
+			MinNodes:      policy.MinNodes,
+			MaxNodes:      policy.MaxNodes,
+			Cooldown:      policy.Cooldown,
+			Interval:      policy.Interval,
+			ScaleUpLoad:   policy.ScaleUpLoad,
+			ScaleDownLoad: policy.ScaleDownLoad,
+			Created:       created,
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to record autoscaling policy: %s", err.Error())
+	}
+	return startAutoscaler(tenant, clusterName, poolName, policy, created)
+}
+
+// startAutoscaler runs the autoscaler of the node pool in background, replacing the one already running
+func startAutoscaler(tenant, clusterName, poolName string, policy autoscale.Policy, created time.Time) (*Autoscaler, error) {
+	sc, err := newSchedule(tenant, clusterName, created)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create autoscaler: %s", err.Error())
+	}
+	as := &Autoscaler{
+		schedule: sc,
+		Pool:     poolName,
+		Policy:   policy,
+	}
+
+	key := autoscalerKey(tenant, clusterName, poolName)
+	if previous := autoscalers.get(key); previous != nil {
+		as.lastScaling = previous.(*Autoscaler).LastScaling()
+	}
+	autoscalers.start(key, as, policy.Interval, fmt.Sprintf("Autoscale node pool '%s' of cluster '%s'", poolName, clusterName))
+	srvutils.JobEvent(as.ID, "enabled with %d to %d nodes, load thresholds %.2f/%.2f, cooldown %s",
+		policy.MinNodes, policy.MaxNodes, policy.ScaleDownLoad, policy.ScaleUpLoad, policy.Cooldown)
+	return as, nil
+}
+
+// restoreAutoscalers restarts the autoscalers recorded in the schedules of a cluster
+func restoreAutoscalers(tenant, clusterName string, schedules *clusterpropsv1.Schedules) {
+	for poolName, recorded := range schedules.Autoscalers {
+		policy := autoscale.Policy{
+			MinNodes:      recorded.MinNodes,
+			MaxNodes:      recorded.MaxNodes,
+			Cooldown:      recorded.Cooldown,
+			Interval:      recorded.Interval,
+			ScaleUpLoad:   recorded.ScaleUpLoad,
+			ScaleDownLoad: recorded.ScaleDownLoad,
+		}
+		err := policy.Validate()
+		if err == nil {
+			_, err = startAutoscaler(tenant, clusterName, poolName, policy, recorded.Created)
+		}
+		if err != nil {
+			logrus.Warnf("failed to restore autoscaler of node pool '%s' of cluster '%s': %v", poolName, clusterName, err)
+			continue
+		}
+		logrus.Infof("Autoscaler of node pool '%s' of cluster '%s' restored", poolName, clusterName)
+	}
+}
+
+// tick reads the workload of the node pool and applies the decision of the policy
+func (a *Autoscaler) tick(task concurrency.Task, instance api.Cluster) {
+	pools, err := instance.ListNodePools(task)
+	if err != nil {
+		a.event("skipped: failed to list node pools: %v", err)
+		return
+	}
+	nodes := -1
+	for _, p := range pools {
+		if p.Name == a.Pool {
+			nodes = len(p.Nodes)
+			break
+		}
+	}
+	if nodes < 0 {
+		a.event("skipped: node pool not found")
+		return
+	}
+	workload, err := instance.GetWorkload(task, a.Pool)
+	if err != nil {
+		a.event("skipped: failed to get workload: %v", err)
+		return
+	}
+
+	decision := autoscale.Decide(a.Policy, nodes, workload, a.LastScaling(), time.Now())
+	switch decision.Action {
+	case autoscale.ScaleUp:
+		a.event("scaling up by %d node(s): %s", decision.Count, decision.Reason)
+		hosts, err := instance.AddNodes(task, a.Pool, decision.Count, nil)
+		a.scaled()
+		if err != nil {
+			a.event("failed to scale up: %v", err)
+			return
+		}
+		a.event("added node(s) %v", hosts)
+	case autoscale.ScaleDown:
+		a.event("scaling down, removing least loaded node '%s': %s", decision.NodeID, decision.Reason)
+		err = instance.DeleteSpecificNode(task, decision.NodeID, "")
+		a.scaled()
+		if err != nil {
+			a.event("failed to scale down: %v", err)
+			return
+		}
+		a.event("removed node '%s'", decision.NodeID)
+	default:
+		a.event("%s", decision.Reason)
+	}
+}
+
+// scaled records the date of a scaling action
+func (a *Autoscaler) scaled() {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.lastScaling = time.Now()
+}
+
+// Disable stops the autoscaling of the node pool of the cluster and forgets its policy
+func (handler *AutoscalerHandler) Disable(ctx context.Context, tenant, clusterName, poolName string) (err error) {
+	if handler == nil {
+		return scerr.InvalidInstanceError()
+	}
+	if clusterName == "" {
+		return scerr.InvalidParameterError("clusterName", "cannot be empty string")
+	}
+	if poolName == "" {
+		poolName = control.DefaultNodePoolName
+	}
+
+	tracer := concurrency.NewTracer(nil, fmt.Sprintf("('%s', '%s', '%s')", tenant, clusterName, poolName), true).WithStopwatch().GoingIn()
+	defer tracer.OnExitTrace()()
+	defer scerr.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	stopped := autoscalers.stop(autoscalerKey(tenant, clusterName, poolName))
+
+	task := concurrency.RootTask()
+	instance, err := cluster.LoadFromService(task, handler.service, clusterName)
+	if err != nil {
+		if _, ok := err.(scerr.ErrNotFound); ok && stopped {
+			return nil
+		}
+		return err
+	}
+	schedules, err := instance.GetSchedules(task)
+	if err != nil {
+		return err
+	}
+	if _, ok := schedules.Autoscalers[poolName]; !ok {
+		if !stopped {
+			return scerr.NotFoundError(fmt.Sprintf("no autoscaler for node pool '%s' of cluster '%s'", poolName, clusterName))
+		}
+		return nil
+	}
+	return instance.UpdateSchedules(task, func(schedules *clusterpropsv1.Schedules) {
+		delete(schedules.Autoscalers, poolName)
+	})
+}
+
+// List returns the autoscalers running on the clusters of tenant, sorted by creation date
+func (handler *AutoscalerHandler) List(ctx context.Context, tenant string) (list []*Autoscaler, err error) {
+	if handler == nil {
+		return nil, scerr.InvalidInstanceError()
+	}
+	if tenant == "" {
+		return nil, scerr.InvalidParameterError("tenant", "cannot be empty string")
+	}
+
+	tracer := concurrency.NewTracer(nil, fmt.Sprintf("('%s')", tenant), true).WithStopwatch().GoingIn()
+	defer tracer.OnExitTrace()()
+	defer scerr.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	for _, a := range autoscalers.list() {
+		if autoscaler := a.(*Autoscaler); autoscaler.Tenant == tenant {
+			list = append(list, autoscaler)
+		}
+	}
+	return list, nil
+}
@@ -0,0 +1,260 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/CS-SI/SafeScale/lib/server/cluster"
+	"github.com/CS-SI/SafeScale/lib/server/cluster/api"
+	clusterpropsv1 "github.com/CS-SI/SafeScale/lib/server/cluster/control/properties/v1"
+	"github.com/CS-SI/SafeScale/lib/server/iaas"
+	srvutils "github.com/CS-SI/SafeScale/lib/server/utils"
+	"github.com/CS-SI/SafeScale/lib/utils/concurrency"
+	"github.com/CS-SI/SafeScale/lib/utils/scerr"
+)
+
+//go:generate mockgen -destination=../mocks/mock_backupschedulerapi.go -package=mocks github.com/CS-SI/SafeScale/lib/server/handlers BackupSchedulerAPI
+
+const (
+	// DefaultBackupInterval is the delay between 2 scheduled backups if not set
+	DefaultBackupInterval = 24 * time.Hour
+	// DefaultBackupKeep is the number of scheduled backups kept if not set
+	DefaultBackupKeep = 7
+)
+
+// BackupSchedulerAPI defines API to manage the scheduled backups of clusters run by safescaled
+type BackupSchedulerAPI interface {
+	Enable(ctx context.Context, tenant, clusterName string, interval time.Duration, keep int, bucket string) (*BackupScheduler, error)
+	Disable(ctx context.Context, tenant, clusterName string) error
+	List(ctx context.Context) ([]*BackupScheduler, error)
+}
+
+// BackupScheduler backs up periodically the control plane of a cluster in Bucket
+// and deletes the oldest backups to keep only the Keep most recent ones
+// Its results are recorded as events of the job identified by ID.
+type BackupScheduler struct {
+	*schedule
+	Interval time.Duration
+	Keep     int
+	Bucket   string
+
+	lastBackup time.Time
+}
+
+// LastBackup returns the date of the last successful backup
+func (b *BackupScheduler) LastBackup() time.Time {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.lastBackup
+}
+
+// backupSchedulers runs the backup schedulers in safescaled, indexed by tenant and cluster
+var backupSchedulers = newScheduler("Backup scheduler")
+
+func init() {
+	scheduleRestorers = append(scheduleRestorers, restoreBackupScheduler)
+}
+
+// BackupSchedulerHandler backup scheduler service
+type BackupSchedulerHandler struct {
+	service iaas.Service
+}
+
+// NewBackupSchedulerHandler creates a BackupSchedulerHandler
+func NewBackupSchedulerHandler(svc iaas.Service) BackupSchedulerAPI {
+	return &BackupSchedulerHandler{
+		service: svc,
+	}
+}
+
+// Enable starts the periodic backup of the cluster, and records it in the metadata of the cluster to restart
+// the backup scheduler with safescaled.
+// If interval is 0, DefaultBackupInterval is used; if keep is 0, DefaultBackupKeep is used.
+// If a backup scheduler is already running for the cluster, it is replaced.
+func (handler *BackupSchedulerHandler) Enable(
+	ctx context.Context, tenant, clusterName string, interval time.Duration, keep int, bucket string,
+) (bs *BackupScheduler, err error) {
+	if handler == nil {
+		return nil, scerr.InvalidInstanceError()
+	}
+	if tenant == "" {
+		return nil, scerr.InvalidParameterError("tenant", "cannot be empty string")
+	}
+	if clusterName == "" {
+		return nil, scerr.InvalidParameterError("clusterName", "cannot be empty string")
+	}
+	if interval < 0 {
+		return nil, scerr.InvalidParameterError("interval", "cannot be negative")
+	}
+	if keep < 0 {
+		return nil, scerr.InvalidParameterError("keep", "cannot be negative")
+	}
+	if bucket == "" {
+		return nil, scerr.InvalidParameterError("bucket", "cannot be empty string")
+	}
+	if bucket == handler.service.GetMetadataBucket().GetName() {
+		return nil, scerr.InvalidRequestError(fmt.Sprintf("cannot store backups of cluster '%s' in the metadata bucket '%s', which contains their encryption key", clusterName, bucket))
+	}
+	if interval == 0 {
+		interval = DefaultBackupInterval
+	}
+	if keep == 0 {
+		keep = DefaultBackupKeep
+	}
+
+	tracer := concurrency.NewTracer(nil, fmt.Sprintf("('%s', '%s', %s, %d, '%s')", tenant, clusterName, interval, keep, bucket), true).WithStopwatch().GoingIn()
+	defer tracer.OnExitTrace()()
+	defer scerr.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	task := concurrency.RootTask()
+	instance, err := cluster.LoadFromService(task, handler.service, clusterName)
+	if err != nil {
+		return nil, err
+	}
+
+	created := time.Now()
+	err = instance.UpdateSchedules(task, func(schedules *clusterpropsv1.Schedules) {
+		schedules.Backup = &clusterpropsv1.BackupSchedule{
+			Interval: interval,
+			Keep:     keep,
+			Bucket:   bucket,
+			Created:  created,
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to record backup schedule: %s", err.Error())
+	}
+	return startBackupScheduler(tenant, clusterName, interval, keep, bucket, created)
+}
+
+// startBackupScheduler runs the backup scheduler of the cluster in background, replacing the one already running
+func startBackupScheduler(tenant, clusterName string, interval time.Duration, keep int, bucket string, created time.Time) (*BackupScheduler, error) {
+	sc, err := newSchedule(tenant, clusterName, created)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create backup scheduler: %s", err.Error())
+	}
+	bs := &BackupScheduler{
+		schedule: sc,
+		Interval: interval,
+		Keep:     keep,
+		Bucket:   bucket,
+	}
+	backupSchedulers.start(tenant+"/"+clusterName, bs, interval, fmt.Sprintf("Back up cluster '%s'", clusterName))
+	srvutils.JobEvent(bs.ID, "enabled every %s, keeping %d backups", interval, keep)
+	return bs, nil
+}
+
+// restoreBackupScheduler restarts the backup scheduler recorded in the schedules of a cluster
+func restoreBackupScheduler(tenant, clusterName string, schedules *clusterpropsv1.Schedules) {
+	recorded := schedules.Backup
+	if recorded == nil {
+		return
+	}
+	if recorded.Interval <= 0 {
+		recorded.Interval = DefaultBackupInterval
+	}
+	if recorded.Keep <= 0 {
+		recorded.Keep = DefaultBackupKeep
+	}
+	_, err := startBackupScheduler(tenant, clusterName, recorded.Interval, recorded.Keep, recorded.Bucket, recorded.Created)
+	if err != nil {
+		logrus.Warnf("failed to restore backup scheduler of cluster '%s': %v", clusterName, err)
+		return
+	}
+	logrus.Infof("Backup scheduler of cluster '%s' restored", clusterName)
+}
+
+// tick saves the control plane of the cluster, then deletes the backups in excess
+func (b *BackupScheduler) tick(task concurrency.Task, instance api.Cluster) {
+	backup, err := instance.Backup(task, b.Bucket)
+	if err != nil {
+		b.event("backup failed: %v", err)
+		return
+	}
+	b.lock.Lock()
+	b.lastBackup = backup.Created
+	b.lock.Unlock()
+	b.event("backup '%s' done (%d bytes)", backup.ID, backup.Size)
+
+	deleted, err := instance.PruneBackups(task, b.Keep)
+	if len(deleted) > 0 {
+		b.event("deleted backups: %s", strings.Join(deleted, ", "))
+	}
+	if err != nil {
+		b.event("failed to delete old backups: %v", err)
+	}
+}
+
+// Disable stops the periodic backup of the cluster and forgets it
+func (handler *BackupSchedulerHandler) Disable(ctx context.Context, tenant, clusterName string) (err error) {
+	if handler == nil {
+		return scerr.InvalidInstanceError()
+	}
+	if clusterName == "" {
+		return scerr.InvalidParameterError("clusterName", "cannot be empty string")
+	}
+
+	tracer := concurrency.NewTracer(nil, fmt.Sprintf("('%s', '%s')", tenant, clusterName), true).WithStopwatch().GoingIn()
+	defer tracer.OnExitTrace()()
+	defer scerr.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	stopped := backupSchedulers.stop(tenant + "/" + clusterName)
+
+	task := concurrency.RootTask()
+	instance, err := cluster.LoadFromService(task, handler.service, clusterName)
+	if err != nil {
+		if _, ok := err.(scerr.ErrNotFound); ok && stopped {
+			return nil
+		}
+		return err
+	}
+	schedules, err := instance.GetSchedules(task)
+	if err != nil {
+		return err
+	}
+	if schedules.Backup == nil {
+		if !stopped {
+			return scerr.NotFoundError(fmt.Sprintf("no backup scheduler for cluster '%s'", clusterName))
+		}
+		return nil
+	}
+	return instance.UpdateSchedules(task, func(schedules *clusterpropsv1.Schedules) {
+		schedules.Backup = nil
+	})
+}
+
+// List returns the backup schedulers running, sorted by creation date
+func (handler *BackupSchedulerHandler) List(ctx context.Context) (list []*BackupScheduler, err error) {
+	if handler == nil {
+		return nil, scerr.InvalidInstanceError()
+	}
+
+	tracer := concurrency.NewTracer(nil, "", true).WithStopwatch().GoingIn()
+	defer tracer.OnExitTrace()()
+	defer scerr.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	for _, bs := range backupSchedulers.list() {
+		list = append(list, bs.(*BackupScheduler))
+	}
+	return list, nil
+}
@@ -0,0 +1,298 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/CS-SI/SafeScale/lib/server/cluster"
+	"github.com/CS-SI/SafeScale/lib/server/cluster/api"
+	clusterpropsv1 "github.com/CS-SI/SafeScale/lib/server/cluster/control/properties/v1"
+	"github.com/CS-SI/SafeScale/lib/server/cluster/enums/clusterstate"
+	"github.com/CS-SI/SafeScale/lib/server/cluster/enums/property"
+	"github.com/CS-SI/SafeScale/lib/server/iaas"
+	srvutils "github.com/CS-SI/SafeScale/lib/server/utils"
+	"github.com/CS-SI/SafeScale/lib/utils/concurrency"
+	"github.com/CS-SI/SafeScale/lib/utils/data"
+	"github.com/CS-SI/SafeScale/lib/utils/scerr"
+)
+
+//go:generate mockgen -destination=../mocks/mock_healthcheckerapi.go -package=mocks github.com/CS-SI/SafeScale/lib/server/handlers HealthCheckerAPI
+
+const (
+	// DefaultHealthCheckInterval is the delay between 2 health checks if the cluster does not define a state collect interval
+	DefaultHealthCheckInterval = time.Minute
+	// DefaultHealthCheckMaxFailures is the number of consecutive failed checks after which a node is replaced
+	DefaultHealthCheckMaxFailures = 3
+)
+
+// HealthCheckerAPI defines API to manage the health checkers of clusters run by safescaled
+type HealthCheckerAPI interface {
+	Enable(ctx context.Context, tenant, clusterName string, interval time.Duration, repair bool, maxFailures int) (*HealthChecker, error)
+	Disable(ctx context.Context, tenant, clusterName string) error
+	List(ctx context.Context) ([]*HealthChecker, error)
+}
+
+// HealthChecker checks periodically the health of the masters and nodes of a cluster and, if Repair is set,
+// replaces the nodes failing MaxFailures consecutive checks
+// Its results are recorded as events of the job identified by ID.
+type HealthChecker struct {
+	*schedule
+	Interval    time.Duration
+	Repair      bool
+	MaxFailures int
+
+	lastCheck time.Time
+	lastState clusterstate.Enum
+}
+
+// LastCheck returns the date of the last check and the state of the cluster found
+func (h *HealthChecker) LastCheck() (time.Time, clusterstate.Enum) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return h.lastCheck, h.lastState
+}
+
+// healthCheckers runs the health checkers in safescaled, indexed by tenant and cluster
+var healthCheckers = newScheduler("Health checker")
+
+func init() {
+	scheduleRestorers = append(scheduleRestorers, restoreHealthChecker)
+}
+
+// HealthCheckerHandler health checker service
+type HealthCheckerHandler struct {
+	service iaas.Service
+}
+
+// NewHealthCheckerHandler creates a HealthCheckerHandler
+func NewHealthCheckerHandler(svc iaas.Service) HealthCheckerAPI {
+	return &HealthCheckerHandler{
+		service: svc,
+	}
+}
+
+// Enable starts the periodic health check of the cluster, and records it in the metadata of the cluster to restart
+// the health checker with safescaled.
+// If interval is 0, the state collect interval of the cluster is used (or DefaultHealthCheckInterval if not set).
+// If a health checker is already running for the cluster, it is replaced.
+func (handler *HealthCheckerHandler) Enable(
+	ctx context.Context, tenant, clusterName string, interval time.Duration, repair bool, maxFailures int,
+) (hc *HealthChecker, err error) {
+	if handler == nil {
+		return nil, scerr.InvalidInstanceError()
+	}
+	if tenant == "" {
+		return nil, scerr.InvalidParameterError("tenant", "cannot be empty string")
+	}
+	if clusterName == "" {
+		return nil, scerr.InvalidParameterError("clusterName", "cannot be empty string")
+	}
+	if interval < 0 {
+		return nil, scerr.InvalidParameterError("interval", "cannot be negative")
+	}
+	if maxFailures < 0 {
+		return nil, scerr.InvalidParameterError("maxFailures", "cannot be negative")
+	}
+	if maxFailures == 0 {
+		maxFailures = DefaultHealthCheckMaxFailures
+	}
+
+	tracer := concurrency.NewTracer(nil, fmt.Sprintf("('%s', '%s', %s, %v)", tenant, clusterName, interval, repair), true).WithStopwatch().GoingIn()
+	defer tracer.OnExitTrace()()
+	defer scerr.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	task := concurrency.RootTask()
+	instance, err := cluster.LoadFromService(task, handler.service, clusterName)
+	if err != nil {
+		return nil, err
+	}
+	if interval == 0 {
+		err = instance.GetProperties(task).LockForRead(property.StateV1).ThenUse(func(clonable data.Clonable) error {
+			interval = clonable.(*clusterpropsv1.State).StateCollectInterval
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if interval <= 0 {
+			interval = DefaultHealthCheckInterval
+		}
+	}
+
+	created := time.Now()
+	err = instance.UpdateSchedules(task, func(schedules *clusterpropsv1.Schedules) {
+		schedules.HealthCheck = &clusterpropsv1.HealthCheckSchedule{
+			Interval:    interval,
+			Repair:      repair,
+			MaxFailures: maxFailures,
+			Created:     created,
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to record health check: %s", err.Error())
+	}
+	return startHealthChecker(tenant, clusterName, interval, repair, maxFailures, created)
+}
+
+// startHealthChecker runs the health checker of the cluster in background, replacing the one already running
+func startHealthChecker(tenant, clusterName string, interval time.Duration, repair bool, maxFailures int, created time.Time) (*HealthChecker, error) {
+	sc, err := newSchedule(tenant, clusterName, created)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create health checker: %s", err.Error())
+	}
+	hc := &HealthChecker{
+		schedule:    sc,
+		Interval:    interval,
+		Repair:      repair,
+		MaxFailures: maxFailures,
+	}
+	healthCheckers.start(tenant+"/"+clusterName, hc, interval, fmt.Sprintf("Check health of cluster '%s'", clusterName))
+	srvutils.JobEvent(hc.ID, "enabled every %s, repair %v after %d failed checks", interval, repair, maxFailures)
+	return hc, nil
+}
+
+// restoreHealthChecker restarts the health checker recorded in the schedules of a cluster
+func restoreHealthChecker(tenant, clusterName string, schedules *clusterpropsv1.Schedules) {
+	recorded := schedules.HealthCheck
+	if recorded == nil {
+		return
+	}
+	if recorded.Interval <= 0 {
+		recorded.Interval = DefaultHealthCheckInterval
+	}
+	if recorded.MaxFailures <= 0 {
+		recorded.MaxFailures = DefaultHealthCheckMaxFailures
+	}
+	_, err := startHealthChecker(tenant, clusterName, recorded.Interval, recorded.Repair, recorded.MaxFailures, recorded.Created)
+	if err != nil {
+		logrus.Warnf("failed to restore health checker of cluster '%s': %v", clusterName, err)
+		return
+	}
+	logrus.Infof("Health checker of cluster '%s' restored", clusterName)
+}
+
+// tick probes the cluster and replaces the dead nodes if asked for
+func (h *HealthChecker) tick(task concurrency.Task, instance api.Cluster) {
+	list, err := instance.CheckHealth(task)
+	if err != nil {
+		h.event("skipped: %v", err)
+		return
+	}
+
+	state := clusterstate.Nominal
+	var (
+		failing []string
+		dead    []*clusterpropsv1.NodeHealth
+	)
+	for _, nh := range list {
+		if nh.Reason == "" {
+			continue
+		}
+		state = clusterstate.Degraded
+		failing = append(failing, fmt.Sprintf("%s (%s, %d failed checks)", nh.Name, nh.Reason, nh.Failures))
+		if !nh.Master && nh.Failures >= h.MaxFailures {
+			dead = append(dead, nh)
+		}
+	}
+	h.lock.Lock()
+	h.lastCheck, h.lastState = time.Now(), state
+	h.lock.Unlock()
+	if len(failing) == 0 {
+		h.event("cluster is %s", state.String())
+	} else {
+		h.event("cluster is %s, failing: %s", state.String(), strings.Join(failing, ", "))
+	}
+
+	if !h.Repair || len(dead) == 0 {
+		return
+	}
+	// If no host passes the probes, the cluster is more likely unreachable (provider, network, tenant) than all its
+	// hosts dead: replacing the nodes would destroy it
+	if len(failing) == len(list) {
+		h.event("repair skipped: no host of the cluster is healthy")
+		return
+	}
+	for _, nh := range dead {
+		h.event("replacing node '%s'", nh.Name)
+		newID, err := instance.RepairNode(task, nh.ID)
+		if err != nil {
+			h.event("failed to replace node '%s': %v", nh.Name, err)
+			continue
+		}
+		h.event("node '%s' replaced by node '%s'", nh.Name, newID)
+	}
+}
+
+// Disable stops the periodic health check of the cluster and forgets it
+func (handler *HealthCheckerHandler) Disable(ctx context.Context, tenant, clusterName string) (err error) {
+	if handler == nil {
+		return scerr.InvalidInstanceError()
+	}
+	if clusterName == "" {
+		return scerr.InvalidParameterError("clusterName", "cannot be empty string")
+	}
+
+	tracer := concurrency.NewTracer(nil, fmt.Sprintf("('%s', '%s')", tenant, clusterName), true).WithStopwatch().GoingIn()
+	defer tracer.OnExitTrace()()
+	defer scerr.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	stopped := healthCheckers.stop(tenant + "/" + clusterName)
+
+	task := concurrency.RootTask()
+	instance, err := cluster.LoadFromService(task, handler.service, clusterName)
+	if err != nil {
+		if _, ok := err.(scerr.ErrNotFound); ok && stopped {
+			return nil
+		}
+		return err
+	}
+	schedules, err := instance.GetSchedules(task)
+	if err != nil {
+		return err
+	}
+	if schedules.HealthCheck == nil {
+		if !stopped {
+			return scerr.NotFoundError(fmt.Sprintf("no health checker for cluster '%s'", clusterName))
+		}
+		return nil
+	}
+	return instance.UpdateSchedules(task, func(schedules *clusterpropsv1.Schedules) {
+		schedules.HealthCheck = nil
+	})
+}
+
+// List returns the health checkers running, sorted by creation date
+func (handler *HealthCheckerHandler) List(ctx context.Context) (list []*HealthChecker, err error) {
+	if handler == nil {
+		return nil, scerr.InvalidInstanceError()
+	}
+
+	tracer := concurrency.NewTracer(nil, "", true).WithStopwatch().GoingIn()
+	defer tracer.OnExitTrace()()
+	defer scerr.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	for _, hc := range healthCheckers.list() {
+		list = append(list, hc.(*HealthChecker))
+	}
+	return list, nil
+}
@@ -0,0 +1,71 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package handlers
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/CS-SI/SafeScale/lib/server/cluster/api"
+	clusterpropsv1 "github.com/CS-SI/SafeScale/lib/server/cluster/control/properties/v1"
+	srvutils "github.com/CS-SI/SafeScale/lib/server/utils"
+	"github.com/CS-SI/SafeScale/lib/utils/concurrency"
+)
+
+// testCluster is a cluster whose health is given; its other methods are never called
+type testCluster struct {
+	api.Cluster
+	health   []*clusterpropsv1.NodeHealth
+	repaired []string
+}
+
+func (c *testCluster) CheckHealth(concurrency.Task) ([]*clusterpropsv1.NodeHealth, error) {
+	return c.health, nil
+}
+
+func (c *testCluster) RepairNode(task concurrency.Task, hostID string) (string, error) {
+	c.repaired = append(c.repaired, hostID)
+	return hostID + "-new", nil
+}
+
+func TestHealthChecker_Tick(t *testing.T) {
+	sc, err := newSchedule("tenant", "test", time.Time{})
+	require.NoError(t, err)
+	hc := &HealthChecker{schedule: sc, Repair: true, MaxFailures: 3}
+
+	// A node failing too many checks is replaced
+	instance := &testCluster{health: []*clusterpropsv1.NodeHealth{
+		{ID: "master-1", Name: "test-master-1", Master: true},
+		{ID: "node-1", Name: "test-node-1", Reason: "unreachable by SSH", Failures: 3},
+		{ID: "node-2", Name: "test-node-2", Reason: "unreachable by SSH", Failures: 2},
+	}}
+	hc.tick(concurrency.RootTask(), instance)
+	assert.Equal(t, []string{"node-1"}, instance.repaired)
+
+	// If no host is healthy, the cluster is probably unreachable: nothing is replaced
+	instance.health[0].Reason = "unreachable by SSH"
+	instance.repaired = nil
+	hc.tick(concurrency.RootTask(), instance)
+	assert.Empty(t, instance.repaired)
+	events := srvutils.JobEvents(sc.ID)
+	require.NotEmpty(t, events)
+	assert.True(t, strings.HasSuffix(events[len(events)-1], "repair skipped: no host of the cluster is healthy"))
+}
@@ -662,6 +662,10 @@ func (handler *HostHandler) Create(
 
 		return nil, scerr.Wrap(derr, fmt.Sprintf("failed to wait host '%s' to become ready", host.Name))
 	}
+	err = sshHandler.pinHostKey(host, sshCfg)
+	if err != nil {
+		return nil, err
+	}
 
 	// Updates host link with networks
 	for _, i := range networks {
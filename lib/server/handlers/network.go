@@ -634,6 +634,10 @@ func (handler *NetworkHandler) waitForInstallPhase1OnGateway(
 	}
 	logrus.Infof("SSH service of gateway '%s' started.", gw.Name)
 
+	err = sshHandler.pinHostKey(gw, ssh)
+	if err != nil {
+		return nil, err
+	}
 	return nil, nil
 }
 
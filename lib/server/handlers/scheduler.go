@@ -0,0 +1,269 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/CS-SI/SafeScale/lib/server/cluster"
+	"github.com/CS-SI/SafeScale/lib/server/cluster/api"
+	"github.com/CS-SI/SafeScale/lib/server/cluster/control"
+	clusterpropsv1 "github.com/CS-SI/SafeScale/lib/server/cluster/control/properties/v1"
+	"github.com/CS-SI/SafeScale/lib/server/cluster/enums/property"
+	"github.com/CS-SI/SafeScale/lib/server/iaas"
+	srvutils "github.com/CS-SI/SafeScale/lib/server/utils"
+	"github.com/CS-SI/SafeScale/lib/utils/concurrency"
+	"github.com/CS-SI/SafeScale/lib/utils/data"
+)
+
+// schedule contains what is common to the tasks run periodically by safescaled on a cluster (autoscalers, ...)
+// The events of the task are recorded in the job identified by ID.
+type schedule struct {
+	ID      string
+	Tenant  string
+	Cluster string
+	Created time.Time
+
+	lock      sync.Mutex
+	lastEvent string
+	cancel    func()
+}
+
+// newSchedule creates the schedule of a task run on the cluster of the tenant
+func newSchedule(tenant, clusterName string, created time.Time) (*schedule, error) {
+	id, err := uuid.NewV4()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate id: %s", err.Error())
+	}
+	if created.IsZero() {
+		created = time.Now()
+	}
+	return &schedule{
+		ID:      id.String(),
+		Tenant:  tenant,
+		Cluster: clusterName,
+		Created: created,
+	}, nil
+}
+
+// getSchedule returns the schedule; satisfies interface scheduled
+func (s *schedule) getSchedule() *schedule {
+	return s
+}
+
+// event records an event in the job of the task, unless it is the same as the previous one
+func (s *schedule) event(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	s.lock.Lock()
+	same := msg == s.lastEvent
+	s.lastEvent = msg
+	s.lock.Unlock()
+	if !same {
+		srvutils.JobEvent(s.ID, "%s", msg)
+	}
+}
+
+// scheduled is a task run periodically by a scheduler
+type scheduled interface {
+	getSchedule() *schedule
+	// tick runs the task once on the cluster, loaded with the service of the tenant of the schedule
+	tick(task concurrency.Task, instance api.Cluster)
+}
+
+// scheduler runs in background the tasks of one kind, at most one task per key
+type scheduler struct {
+	kind    string
+	lock    sync.Mutex
+	running map[string]scheduled
+}
+
+// newScheduler creates a scheduler; kind names the tasks in the logs
+func newScheduler(kind string) *scheduler {
+	return &scheduler{
+		kind:    kind,
+		running: map[string]scheduled{},
+	}
+}
+
+// start runs the task every interval until stopped, in the background job described by description.
+// The task previously registered with the same key, if any, is stopped and returned.
+func (r *scheduler) start(key string, s scheduled, interval time.Duration, description string) scheduled {
+	sc := s.getSchedule()
+	ctx, cancel := context.WithCancel(context.Background())
+	sc.cancel = cancel
+
+	r.lock.Lock()
+	previous := r.running[key]
+	if previous != nil {
+		previous.getSchedule().cancel()
+	}
+	r.running[key] = s
+	r.lock.Unlock()
+
+	srvutils.JobRegisterBackground(ctx, cancel, sc.ID, description)
+	go r.run(ctx, key, s, interval)
+	return previous
+}
+
+// run calls the task every interval until ctx is cancelled
+func (r *scheduler) run(ctx context.Context, key string, s scheduled, interval time.Duration) {
+	sc := s.getSchedule()
+	defer func() {
+		r.lock.Lock()
+		if r.running[key] == s {
+			delete(r.running, key)
+		}
+		r.lock.Unlock()
+		srvutils.JobDeregisterUUID(sc.ID)
+		logrus.Infof("%s '%s' of cluster '%s' stopped", r.kind, sc.ID, sc.Cluster)
+	}()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			task := concurrency.RootTask()
+			instance, err := loadTenantCluster(task, sc.Tenant, sc.Cluster)
+			if err != nil {
+				sc.event("skipped: failed to load cluster: %v", err)
+				continue
+			}
+			s.tick(task, instance)
+		}
+	}
+}
+
+// get returns the task registered with key, or nil if there is none
+func (r *scheduler) get(key string) scheduled {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.running[key]
+}
+
+// stop stops the task registered with key and returns false if there is none
+func (r *scheduler) stop(key string) bool {
+	r.lock.Lock()
+	s, ok := r.running[key]
+	if ok {
+		delete(r.running, key)
+	}
+	r.lock.Unlock()
+	if ok {
+		s.getSchedule().cancel()
+	}
+	return ok
+}
+
+// list returns the tasks running, sorted by creation date
+func (r *scheduler) list() []scheduled {
+	r.lock.Lock()
+	list := make([]scheduled, 0, len(r.running))
+	for _, s := range r.running {
+		list = append(list, s)
+	}
+	r.lock.Unlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].getSchedule().Created.Before(list[j].getSchedule().Created)
+	})
+	return list
+}
+
+// loadTenantCluster loads the cluster with the service of its tenant, and pins it to this tenant so that the
+// operations on its hosts don't depend on the current tenant of safescaled
+func loadTenantCluster(task concurrency.Task, tenant, clusterName string) (api.Cluster, error) {
+	svc, err := control.GetTenantService(tenant)
+	if err != nil {
+		return nil, err
+	}
+	instance, err := cluster.LoadFromService(task, svc, clusterName)
+	if err != nil {
+		return nil, err
+	}
+	err = instance.PinTenant(task, tenant)
+	if err != nil {
+		return nil, err
+	}
+	return instance, nil
+}
+
+// scheduleRestorers restart the tasks of one kind recorded in the schedules of a cluster of a tenant
+var scheduleRestorers []func(tenant, clusterName string, schedules *clusterpropsv1.Schedules)
+
+// RestoreSchedules restarts the periodic tasks recorded in the metadata of the clusters of all the tenants;
+// called when safescaled starts
+func RestoreSchedules() {
+	tenants, err := iaas.GetTenantNames()
+	if err != nil {
+		logrus.Errorf("failed to restore scheduled tasks of clusters: %v", err)
+		return
+	}
+
+	task := concurrency.RootTask()
+	for tenant := range tenants {
+		svc, err := control.GetTenantService(tenant)
+		if err != nil {
+			logrus.Warnf("failed to restore scheduled tasks of clusters of tenant '%s': %v", tenant, err)
+			continue
+		}
+		list, err := cluster.ListFromService(svc)
+		if err != nil {
+			logrus.Warnf("failed to restore scheduled tasks of clusters of tenant '%s': %v", tenant, err)
+			continue
+		}
+		for _, instance := range list {
+			clusterName := instance.GetIdentity(task).Name
+			// The metadata of a multi-tenant cluster is replicated in all its tenants; only the main one runs its tasks
+			if main := clusterMainTenant(task, instance); main != "" && main != tenant {
+				continue
+			}
+			schedules, err := instance.GetSchedules(task)
+			if err != nil {
+				logrus.Warnf("failed to restore scheduled tasks of cluster '%s': %v", clusterName, err)
+				continue
+			}
+			for _, restore := range scheduleRestorers {
+				restore(tenant, clusterName, schedules)
+			}
+		}
+	}
+}
+
+// clusterMainTenant returns the main tenant of a multi-tenant cluster, or an empty string
+func clusterMainTenant(task concurrency.Task, instance api.Cluster) (tenant string) {
+	props := instance.GetProperties(task)
+	if !props.Lookup(property.CompositeV1) {
+		return ""
+	}
+	_ = props.LockForRead(property.CompositeV1).ThenUse(func(clonable data.Clonable) error {
+		if tenants := clonable.(*clusterpropsv1.Composite).Tenants; len(tenants) > 0 {
+			tenant = tenants[0]
+		}
+		return nil
+	})
+	return tenant
+}
@@ -19,6 +19,9 @@ package handlers
 import (
 	"context"
 	"fmt"
+	"io"
+	"os"
+	"path"
 	"strings"
 	"time"
 
@@ -119,6 +122,10 @@ func (handler *SSHHandler) GetConfig(ctx context.Context, hostParam interface{})
 		Host:       host.GetAccessIP(),
 		User:       user,
 	}
+	sshConfig.HostKey, err = getHostKey(host)
+	if err != nil {
+		return nil, err
+	}
 
 	err = host.Properties.LockForRead(hostproperty.NetworkV1).ThenUse(func(clonable data.Clonable) error {
 		hostNetworkV1 := clonable.(*propsv1.HostNetwork)
@@ -134,6 +141,10 @@ func (handler *SSHHandler) GetConfig(ctx context.Context, hostParam interface{})
 				Host:       gw.GetAccessIP(),
 				User:       user,
 			}
+			GatewayConfig.HostKey, err = getHostKey(gw)
+			if err != nil {
+				return err
+			}
 			sshConfig.GatewayConfig = &GatewayConfig
 		}
 		return nil
@@ -147,6 +158,48 @@ func (handler *SSHHandler) GetConfig(ctx context.Context, hostParam interface{})
 	return sshConfig, nil
 }
 
+// getHostKey returns the SSH host key pinned in the properties of the host, if any
+func getHostKey(host *resources.Host) (hostKey string, err error) {
+	err = host.Properties.LockForRead(hostproperty.SSHV1).ThenUse(func(clonable data.Clonable) error {
+		hostKey = clonable.(*propsv1.HostSSH).HostKey
+		return nil
+	})
+	return hostKey, err
+}
+
+// pinHostKey stores in host metadata the SSH host key captured during the first connection to the host,
+// to refuse later connections to a host presenting another key
+func (handler *SSHHandler) pinHostKey(host *resources.Host, sshConfig *system.SSHConfig) (err error) {
+	if host == nil {
+		return scerr.InvalidParameterError("host", "cannot be nil")
+	}
+	if sshConfig == nil {
+		return scerr.InvalidParameterError("sshConfig", "cannot be nil")
+	}
+	if sshConfig.HostKey == "" {
+		return nil
+	}
+
+	tracer := concurrency.NewTracer(nil, fmt.Sprintf("(%s)", host.Name), true).GoingIn()
+	defer tracer.OnExitTrace()()
+	defer scerr.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	changed := false
+	err = host.Properties.LockForWrite(hostproperty.SSHV1).ThenUse(func(clonable data.Clonable) error {
+		hostSSHV1 := clonable.(*propsv1.HostSSH)
+		if hostSSHV1.HostKey != sshConfig.HostKey {
+			hostSSHV1.HostKey = sshConfig.HostKey
+			changed = true
+		}
+		return nil
+	})
+	if err != nil || !changed {
+		return err
+	}
+	_, err = metadata.SaveHost(handler.service, host)
+	return err
+}
+
 // WaitServerReady waits for remote SSH server to be ready. After timeout, fails
 func (handler *SSHHandler) WaitServerReady(ctx context.Context, hostParam interface{}, timeout time.Duration) (err error) {
 	if handler == nil {
@@ -332,3 +385,378 @@ func (handler *SSHHandler) Copy(ctx context.Context, from, to string) (retCode i
 	cRc, cStcOut, cStdErr, cErr := ssh.Copy(remotePath, localPath, upload)
 	return cRc, cStcOut, cStdErr, cErr
 }
+
+// GetHostConfig returns the SSH configuration to reach the host referenced by hostRef
+// Resolving it inspects the host with the provider: the streams operating on several files of a host resolve it once
+// and use the methods *WithConfig.
+func (handler *SSHHandler) GetHostConfig(ctx context.Context, hostRef string) (*system.SSHConfig, error) {
+	if handler == nil {
+		return nil, scerr.InvalidInstanceError()
+	}
+	if hostRef == "" {
+		return nil, scerr.InvalidParameterError("hostRef", "cannot be empty string")
+	}
+
+	hostSvc := NewHostHandler(handler.service)
+	host, err := hostSvc.ForceInspect(ctx, hostRef)
+	if err != nil {
+		return nil, err
+	}
+	return handler.GetConfig(ctx, host)
+}
+
+// Stat lists the files under remotePath on the host (or remotePath itself if it is a file), with their checksum if asked
+func (handler *SSHHandler) Stat(ctx context.Context, hostRef, remotePath string, withChecksum bool) (files []system.FileInfo, isDir bool, err error) {
+	if handler == nil {
+		return nil, false, scerr.InvalidInstanceError()
+	}
+	if hostRef == "" {
+		return nil, false, scerr.InvalidParameterError("hostRef", "cannot be empty string")
+	}
+	if remotePath == "" {
+		return nil, false, scerr.InvalidParameterError("remotePath", "cannot be empty string")
+	}
+
+	tracer := concurrency.NewTracer(nil, fmt.Sprintf("('%s', '%s', %v)", hostRef, remotePath, withChecksum), true).WithStopwatch().GoingIn()
+	defer tracer.OnExitTrace()()
+	defer scerr.OnExitLogErrorWithLevel(tracer.TraceMessage(""), &err, logrus.DebugLevel)()
+
+	ssh, err := handler.GetHostConfig(ctx, hostRef)
+	if err != nil {
+		return nil, false, err
+	}
+	return handler.StatWithConfig(ssh, remotePath, withChecksum)
+}
+
+// StatWithConfig is Stat on the host reached with the SSH configuration ssh
+func (handler *SSHHandler) StatWithConfig(ssh *system.SSHConfig, remotePath string, withChecksum bool) ([]system.FileInfo, bool, error) {
+	if handler == nil {
+		return nil, false, scerr.InvalidInstanceError()
+	}
+	if ssh == nil {
+		return nil, false, scerr.InvalidParameterError("ssh", "cannot be nil")
+	}
+	if remotePath == "" {
+		return nil, false, scerr.InvalidParameterError("remotePath", "cannot be empty string")
+	}
+	return ssh.ListRemoteFiles(remotePath, withChecksum)
+}
+
+// OpenWriter returns a stream writing in the file remotePath of the host, starting at offset
+func (handler *SSHHandler) OpenWriter(ctx context.Context, hostRef, remotePath string, offset int64, mode os.FileMode) (_ io.WriteCloser, err error) {
+	if handler == nil {
+		return nil, scerr.InvalidInstanceError()
+	}
+	if hostRef == "" {
+		return nil, scerr.InvalidParameterError("hostRef", "cannot be empty string")
+	}
+
+	tracer := concurrency.NewTracer(nil, fmt.Sprintf("('%s', '%s', %d)", hostRef, remotePath, offset), true).GoingIn()
+	defer tracer.OnExitTrace()()
+	defer scerr.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	ssh, err := handler.GetHostConfig(ctx, hostRef)
+	if err != nil {
+		return nil, err
+	}
+	return handler.OpenWriterWithConfig(ssh, remotePath, offset, mode)
+}
+
+// OpenWriterWithConfig is OpenWriter on the host reached with the SSH configuration ssh
+func (handler *SSHHandler) OpenWriterWithConfig(ssh *system.SSHConfig, remotePath string, offset int64, mode os.FileMode) (io.WriteCloser, error) {
+	if handler == nil {
+		return nil, scerr.InvalidInstanceError()
+	}
+	if ssh == nil {
+		return nil, scerr.InvalidParameterError("ssh", "cannot be nil")
+	}
+	return ssh.OpenRemoteWriter(remotePath, offset, mode)
+}
+
+// OpenReader returns a stream reading the file remotePath of the host, starting at offset
+func (handler *SSHHandler) OpenReader(ctx context.Context, hostRef, remotePath string, offset int64) (_ io.ReadCloser, err error) {
+	if handler == nil {
+		return nil, scerr.InvalidInstanceError()
+	}
+	if hostRef == "" {
+		return nil, scerr.InvalidParameterError("hostRef", "cannot be empty string")
+	}
+
+	tracer := concurrency.NewTracer(nil, fmt.Sprintf("('%s', '%s', %d)", hostRef, remotePath, offset), true).GoingIn()
+	defer tracer.OnExitTrace()()
+	defer scerr.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	ssh, err := handler.GetHostConfig(ctx, hostRef)
+	if err != nil {
+		return nil, err
+	}
+	return handler.OpenReaderWithConfig(ssh, remotePath, offset)
+}
+
+// OpenReaderWithConfig is OpenReader on the host reached with the SSH configuration ssh
+func (handler *SSHHandler) OpenReaderWithConfig(ssh *system.SSHConfig, remotePath string, offset int64) (io.ReadCloser, error) {
+	if handler == nil {
+		return nil, scerr.InvalidInstanceError()
+	}
+	if ssh == nil {
+		return nil, scerr.InvalidParameterError("ssh", "cannot be nil")
+	}
+	return ssh.OpenRemoteReader(remotePath, offset)
+}
+
+// VerifyChecksum checks the file remotePath of the host has the expected checksum; on mismatch, the file is removed
+// from the host, so that a new upload starts from the beginning instead of resuming on corrupted content
+func (handler *SSHHandler) VerifyChecksum(ctx context.Context, hostRef, remotePath, checksum string) (err error) {
+	if checksum == "" {
+		return nil
+	}
+	ssh, err := handler.GetHostConfig(ctx, hostRef)
+	if err != nil {
+		return err
+	}
+	err = handler.VerifyChecksumWithConfig(ssh, remotePath, checksum)
+	if err != nil {
+		return fmt.Errorf("%s on host '%s'", err.Error(), hostRef)
+	}
+	return nil
+}
+
+// VerifyChecksumWithConfig is VerifyChecksum on the host reached with the SSH configuration ssh
+func (handler *SSHHandler) VerifyChecksumWithConfig(ssh *system.SSHConfig, remotePath, checksum string) error {
+	if handler == nil {
+		return scerr.InvalidInstanceError()
+	}
+	if checksum == "" {
+		return nil
+	}
+	if ssh == nil {
+		return scerr.InvalidParameterError("ssh", "cannot be nil")
+	}
+	return ssh.VerifyRemoteFile(remotePath, checksum)
+}
+
+// Transfer copies recursively source from the host srcRef to destination on the host dstRef, without going through
+// safescaled host more than needed: data flow between the SSH connections opened by safescaled to both hosts.
+// dstHandler gives access to the destination host when it belongs to another tenant (handler is used if nil).
+// Files already present on destination are skipped, files partially transferred are resumed; progress is notified
+// through the callback notify.
+func (handler *SSHHandler) Transfer(
+	ctx context.Context, srcRef, source string, dstHandler *SSHHandler, dstRef, destination string, notify func(system.TransferProgress),
+) (files []system.FileInfo, transferred int64, err error) {
+	if handler == nil {
+		return nil, 0, scerr.InvalidInstanceError()
+	}
+	if srcRef == "" {
+		return nil, 0, scerr.InvalidParameterError("srcRef", "cannot be empty string")
+	}
+	if dstRef == "" {
+		return nil, 0, scerr.InvalidParameterError("dstRef", "cannot be empty string")
+	}
+	if source == "" {
+		return nil, 0, scerr.InvalidParameterError("source", "cannot be empty string")
+	}
+	if destination == "" {
+		return nil, 0, scerr.InvalidParameterError("destination", "cannot be empty string")
+	}
+
+	tracer := concurrency.NewTracer(nil, fmt.Sprintf("('%s:%s', '%s:%s')", srcRef, source, dstRef, destination), true).WithStopwatch().GoingIn()
+	defer tracer.OnExitTrace()()
+	defer scerr.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	srcSSH, err := handler.GetHostConfig(ctx, srcRef)
+	if err != nil {
+		return nil, 0, err
+	}
+	if dstHandler == nil {
+		dstHandler = handler
+	}
+	dstSSH, err := dstHandler.GetHostConfig(ctx, dstRef)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	files, srcIsDir, err := srcSSH.ListRemoteFiles(source, true)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Resolves destination like cp does: an existing folder receives the source inside it
+	root := destination
+	existing, dstIsDir, err := dstSSH.ListRemoteFiles(destination, true)
+	if err != nil {
+		if _, ok := err.(scerr.ErrNotFound); !ok {
+			return nil, 0, err
+		}
+		existing = nil
+	}
+	if dstIsDir {
+		root = path.Join(destination, path.Base(source))
+		existing, dstIsDir, err = dstSSH.ListRemoteFiles(root, true)
+		if err != nil {
+			if _, ok := err.(scerr.ErrNotFound); !ok {
+				return nil, 0, err
+			}
+			existing = nil
+		}
+	}
+	if srcIsDir != dstIsDir && existing != nil {
+		return nil, 0, fmt.Errorf("cannot copy '%s' on '%s': one is a folder, the other is a file", source, root)
+	}
+	existingByPath := map[string]system.FileInfo{}
+	for _, f := range existing {
+		existingByPath[f.Path] = f
+	}
+
+	progress := system.TransferProgress{}
+	for _, f := range files {
+		progress.TotalSize += f.Size
+	}
+
+	for _, f := range files {
+		if ctx.Err() != nil {
+			return files, transferred, ctx.Err()
+		}
+
+		srcPath, dstPath := source, root
+		if f.Path != "" {
+			srcPath = path.Join(source, f.Path)
+			dstPath = path.Join(root, f.Path)
+		}
+		var offset int64
+		if e, ok := existingByPath[f.Path]; ok {
+			offset = system.ResumeOffset(f, &e)
+		}
+
+		// A resumed file whose checksum doesn't match is transferred again from the beginning
+		for attempt := 0; ; attempt++ {
+			progress.Path, progress.Size, progress.Done = f.Path, f.Size, offset
+			base := progress.TotalDone
+			if offset < f.Size {
+				n, err := handler.transferFile(ctx, srcSSH, srcPath, dstSSH, dstPath, offset, f.Mode, func(count int64) {
+					progress.Done = offset + count
+					progress.TotalDone = base + offset + count
+					if notify != nil {
+						notify(progress)
+					}
+				})
+				transferred += n
+				if err != nil {
+					return files, transferred, err
+				}
+			}
+			progress.Done = f.Size
+			progress.TotalDone = base + f.Size
+
+			done, _, err := dstSSH.ListRemoteFiles(dstPath, true)
+			if err != nil {
+				return files, transferred, err
+			}
+			if len(done) == 1 && done[0].Checksum == f.Checksum {
+				break
+			}
+			if attempt > 0 || offset == 0 {
+				return files, transferred, fmt.Errorf("checksum mismatch for '%s' on host '%s'", dstPath, dstRef)
+			}
+			logrus.Warnf("checksum mismatch for resumed file '%s' on host '%s', transferring it again", dstPath, dstRef)
+			progress.TotalDone = base
+			offset = 0
+		}
+		if notify != nil {
+			notify(progress)
+		}
+	}
+	return files, transferred, nil
+}
+
+// transferFile copies a file between 2 hosts from offset, and returns the number of bytes transferred
+func (handler *SSHHandler) transferFile(
+	ctx context.Context, srcSSH *system.SSHConfig, srcPath string, dstSSH *system.SSHConfig, dstPath string,
+	offset int64, mode os.FileMode, notify func(int64),
+) (int64, error) {
+	reader, err := srcSSH.OpenRemoteReader(srcPath, offset)
+	if err != nil {
+		return 0, err
+	}
+	writer, err := dstSSH.OpenRemoteWriter(dstPath, offset, mode)
+	if err != nil {
+		system.AbortRemoteStream(reader)
+		return 0, err
+	}
+
+	// Interrupts the transfer if the context is cancelled
+	finished := make(chan struct{})
+	defer close(finished)
+	go func() {
+		select {
+		case <-ctx.Done():
+			system.AbortRemoteStream(reader)
+			system.AbortRemoteStream(writer)
+		case <-finished:
+		}
+	}()
+
+	pw := &system.ProgressWriter{Writer: writer, Notify: notify}
+	_, err = io.CopyBuffer(pw, reader, make([]byte, system.TransferChunkSize))
+	if err != nil {
+		system.AbortRemoteStream(reader)
+		system.AbortRemoteStream(writer)
+		return pw.Count, err
+	}
+	err = reader.Close()
+	if err != nil {
+		system.AbortRemoteStream(writer)
+		return pw.Count, err
+	}
+	return pw.Count, writer.Close()
+}
+
+// OpenSession starts command on the host with its standard streams available to the caller; if command is empty,
+// an interactive shell is started, as user username if set
+func (handler *SSHHandler) OpenSession(
+	ctx context.Context, hostRef, command, username, shell string, pty *system.PtyRequest,
+) (_ *system.SSHSession, err error) {
+	if handler == nil {
+		return nil, scerr.InvalidInstanceError()
+	}
+	if hostRef == "" {
+		return nil, scerr.InvalidParameterError("hostRef", "cannot be empty string")
+	}
+
+	tracer := concurrency.NewTracer(nil, fmt.Sprintf("('%s', <command>, '%s')", hostRef, username), true).WithStopwatch().GoingIn()
+	tracer.Trace(fmt.Sprintf("<command>=[%s]", command))
+	defer tracer.OnExitTrace()()
+	defer scerr.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	ssh, err := handler.GetHostConfig(ctx, hostRef)
+	if err != nil {
+		return nil, err
+	}
+	if command == "" && username != "" {
+		if shell == "" {
+			shell = "bash"
+		}
+		command = "sudo -u " + username + " -i " + shell
+	}
+
+	var session *system.SSHSession
+	retryErr := retry.WhileUnsuccessfulWhereRetcode255Delay5SecondsWithNotify(
+		func() error {
+			session, err = ssh.OpenSession(command, pty)
+			return err
+		},
+		temporal.GetConnectSSHTimeout(),
+		func(t retry.Try, v verdict.Enum) {
+			if v == verdict.Retry {
+				logrus.Infof("Remote SSH service on host '%s' isn't ready, retrying...", hostRef)
+			}
+		},
+	)
+	if retryErr != nil {
+		return nil, retryErr
+	}
+	// errors other than connection failures stop the retries without being returned by the retry loop
+	if err != nil {
+		return nil, err
+	}
+	return session, nil
+}
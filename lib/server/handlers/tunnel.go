@@ -0,0 +1,287 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/CS-SI/SafeScale/lib/server/iaas"
+	"github.com/CS-SI/SafeScale/lib/system"
+	"github.com/CS-SI/SafeScale/lib/utils/concurrency"
+	"github.com/CS-SI/SafeScale/lib/utils/retry"
+	"github.com/CS-SI/SafeScale/lib/utils/retry/enums/verdict"
+	"github.com/CS-SI/SafeScale/lib/utils/scerr"
+	"github.com/CS-SI/SafeScale/lib/utils/temporal"
+)
+
+//go:generate mockgen -destination=../mocks/mock_tunnelapi.go -package=mocks github.com/CS-SI/SafeScale/lib/server/handlers TunnelAPI
+
+// TunnelAPI defines API to manage the tunnels kept open by safescaled
+type TunnelAPI interface {
+	Create(ctx context.Context, name, hostRef string, mode system.ForwardMode, bind, target string) (*Tunnel, error)
+	List(ctx context.Context) ([]*Tunnel, error)
+	Inspect(ctx context.Context, ref string) (*Tunnel, error)
+	Close(ctx context.Context, ref string) error
+}
+
+// Tunnel is a port forwarding through an host, kept open by safescaled until closed
+type Tunnel struct {
+	ID       string
+	Name     string
+	Tenant   string
+	HostID   string
+	HostName string
+	Created  time.Time
+	Forward  *system.SSHForward
+}
+
+var (
+	// tunnels contains the tunnels opened by safescaled, indexed by tenant then by ID; a tenant sees only its own tunnels
+	tunnels     = map[string]map[string]*Tunnel{}
+	tunnelsLock sync.Mutex
+)
+
+// TunnelHandler tunnel service
+type TunnelHandler struct {
+	tenant  string
+	service iaas.Service
+}
+
+// NewTunnelHandler creates a TunnelHandler managing the tunnels of the tenant
+func NewTunnelHandler(tenant string, svc iaas.Service) TunnelAPI {
+	return &TunnelHandler{
+		tenant:  tenant,
+		service: svc,
+	}
+}
+
+// normalizeAddress completes addr with the default host if it only contains a port
+func normalizeAddress(addr, defaultHost string) (string, error) {
+	if addr == "" {
+		return "", nil
+	}
+	if _, err := strconv.Atoi(addr); err == nil {
+		addr = net.JoinHostPort(defaultHost, addr)
+	}
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", scerr.InvalidParameterError("addr", fmt.Sprintf("'%s' is not a valid address: %s", addr, err.Error()))
+	}
+	if num, err := strconv.Atoi(port); err != nil || num < 0 || num > 65535 {
+		return "", scerr.InvalidParameterError("addr", fmt.Sprintf("'%s' is not a valid port", port))
+	}
+	return addr, nil
+}
+
+// findTunnel returns the tunnel of the tenant whose ID or name is ref; must be called with tunnelsLock held
+func findTunnel(tenant, ref string) *Tunnel {
+	if t, ok := tunnels[tenant][ref]; ok {
+		return t
+	}
+	for _, t := range tunnels[tenant] {
+		if t.Name == ref {
+			return t
+		}
+	}
+	return nil
+}
+
+// Create opens a tunnel through the host and keeps it open until closed.
+// bind and target may be only a port; the host part defaults then to 127.0.0.1 (on the side where
+// the address is used). If name is empty, a name is built from the host, the mode and the port bound.
+func (handler *TunnelHandler) Create(
+	ctx context.Context, name, hostRef string, mode system.ForwardMode, bind, target string,
+) (tunnel *Tunnel, err error) {
+	if handler == nil {
+		return nil, scerr.InvalidInstanceError()
+	}
+	if hostRef == "" {
+		return nil, scerr.InvalidParameterError("hostRef", "cannot be empty string")
+	}
+	if mode == "" {
+		mode = system.ForwardLocal
+	}
+
+	tracer := concurrency.NewTracer(nil, fmt.Sprintf("('%s', '%s', '%s', '%s', '%s')", name, hostRef, mode, bind, target), true).WithStopwatch().GoingIn()
+	defer tracer.OnExitTrace()()
+	defer scerr.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	if bind == "" && mode != system.ForwardRemote {
+		bind = "0"
+	}
+	if bind, err = normalizeAddress(bind, "127.0.0.1"); err != nil {
+		return nil, err
+	}
+	if target, err = normalizeAddress(target, "127.0.0.1"); err != nil {
+		return nil, err
+	}
+
+	if name != "" {
+		tunnelsLock.Lock()
+		found := findTunnel(handler.tenant, name)
+		tunnelsLock.Unlock()
+		if found != nil {
+			return nil, scerr.DuplicateError(fmt.Sprintf("a tunnel named '%s' already exists", name))
+		}
+	}
+
+	host, err := NewHostHandler(handler.service).ForceInspect(ctx, hostRef)
+	if err != nil {
+		return nil, err
+	}
+	ssh, err := NewSSHHandler(handler.service).GetConfig(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	var forward *system.SSHForward
+	retryErr := retry.WhileUnsuccessfulWhereRetcode255Delay5SecondsWithNotify(
+		func() error {
+			forward, err = ssh.Forward(mode, bind, target)
+			return err
+		},
+		temporal.GetConnectSSHTimeout(),
+		func(t retry.Try, v verdict.Enum) {
+			if v == verdict.Retry {
+				logrus.Infof("Remote SSH service on host '%s' isn't ready, retrying...", host.Name)
+			}
+		},
+	)
+	if retryErr != nil {
+		return nil, retryErr
+	}
+	// errors other than connection failures stop the retries without being returned by the retry loop
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := uuid.NewV4()
+	if err != nil {
+		_ = forward.Close()
+		return nil, fmt.Errorf("failed to generate tunnel id: %s", err.Error())
+	}
+	if name == "" {
+		_, port, _ := net.SplitHostPort(forward.Bind())
+		name = fmt.Sprintf("%s-%s-%s", host.Name, mode, port)
+	}
+	tunnel = &Tunnel{
+		ID:       id.String(),
+		Name:     name,
+		Tenant:   handler.tenant,
+		HostID:   host.ID,
+		HostName: host.Name,
+		Created:  time.Now(),
+		Forward:  forward,
+	}
+
+	tunnelsLock.Lock()
+	defer tunnelsLock.Unlock()
+	if findTunnel(handler.tenant, name) != nil {
+		_ = forward.Close()
+		return nil, scerr.DuplicateError(fmt.Sprintf("a tunnel named '%s' already exists", name))
+	}
+	if _, ok := tunnels[handler.tenant]; !ok {
+		tunnels[handler.tenant] = map[string]*Tunnel{}
+	}
+	tunnels[handler.tenant][tunnel.ID] = tunnel
+	logrus.Infof("Tunnel '%s' opened: %s forward of '%s' through host '%s'", name, mode, forward.Bind(), host.Name)
+	return tunnel, nil
+}
+
+// List returns the tunnels opened in the tenant, sorted by creation date
+func (handler *TunnelHandler) List(ctx context.Context) (list []*Tunnel, err error) {
+	if handler == nil {
+		return nil, scerr.InvalidInstanceError()
+	}
+
+	tracer := concurrency.NewTracer(nil, "", true).WithStopwatch().GoingIn()
+	defer tracer.OnExitTrace()()
+	defer scerr.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	tunnelsLock.Lock()
+	for _, t := range tunnels[handler.tenant] {
+		list = append(list, t)
+	}
+	tunnelsLock.Unlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Created.Before(list[j].Created)
+	})
+	return list, nil
+}
+
+// Inspect returns the tunnel of the tenant whose ID or name is ref
+func (handler *TunnelHandler) Inspect(ctx context.Context, ref string) (tunnel *Tunnel, err error) {
+	if handler == nil {
+		return nil, scerr.InvalidInstanceError()
+	}
+	if ref == "" {
+		return nil, scerr.InvalidParameterError("ref", "cannot be empty string")
+	}
+
+	tracer := concurrency.NewTracer(nil, fmt.Sprintf("('%s')", ref), true).WithStopwatch().GoingIn()
+	defer tracer.OnExitTrace()()
+	defer scerr.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	tunnelsLock.Lock()
+	defer tunnelsLock.Unlock()
+	tunnel = findTunnel(handler.tenant, ref)
+	if tunnel == nil {
+		return nil, scerr.NotFoundError(fmt.Sprintf("no tunnel named '%s'", ref))
+	}
+	return tunnel, nil
+}
+
+// Close closes the tunnel of the tenant whose ID or name is ref
+func (handler *TunnelHandler) Close(ctx context.Context, ref string) (err error) {
+	if handler == nil {
+		return scerr.InvalidInstanceError()
+	}
+	if ref == "" {
+		return scerr.InvalidParameterError("ref", "cannot be empty string")
+	}
+
+	tracer := concurrency.NewTracer(nil, fmt.Sprintf("('%s')", ref), true).WithStopwatch().GoingIn()
+	defer tracer.OnExitTrace()()
+	defer scerr.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	tunnelsLock.Lock()
+	tunnel := findTunnel(handler.tenant, ref)
+	if tunnel != nil {
+		delete(tunnels[handler.tenant], tunnel.ID)
+	}
+	tunnelsLock.Unlock()
+	if tunnel == nil {
+		return scerr.NotFoundError(fmt.Sprintf("no tunnel named '%s'", ref))
+	}
+
+	err = tunnel.Forward.Close()
+	if err != nil {
+		return err
+	}
+	logrus.Infof("Tunnel '%s' closed", tunnel.Name)
+	return nil
+}
@@ -31,4 +31,8 @@ const (
 	SharesV1 = "6"
 	// MountsV1 contains optional additional info about mounted devices (locally attached or remote filesystem)
 	MountsV1 = "7"
+	// SSHV1 contains information about the SSH server of the host (public host key, ...)
+	SSHV1 = "8"
+	// FeatureLogsV1 contains the index of the logs of the steps of feature actions run on the host
+	FeatureLogsV1 = "9"
 )
@@ -21,4 +21,6 @@ const (
 	DescriptionV1 = "1"
 	// HostsV1 contains list of hosts attached to the network
 	HostsV1 = "2"
+	// ReverseProxyV1 contains the kind of reverse proxy of the gateways and the rules published by features
+	ReverseProxyV1 = "3"
 )
@@ -575,9 +575,11 @@ func (hs *HostShares) Replace(p data.Clonable) data.Clonable {
 // Note: if tagged as FROZEN, must not be changed ever.
 //       Create a new version instead with updated/additional fields
 type HostInstalledFeature struct {
-	HostContext bool     `json:"host_context,omitempty"` // tells if the feature has been explicitly installed for host (opposed to for cluster)
-	RequiredBy  []string `json:"required_by,omitempty"`  // tells what feature(s) needs this one
-	Requires    []string `json:"requires,omitempty"`
+	HostContext bool              `json:"host_context,omitempty"` // tells if the feature has been explicitly installed for host (opposed to for cluster)
+	RequiredBy  []string          `json:"required_by,omitempty"`  // tells what feature(s) needs this one
+	Requires    []string          `json:"requires,omitempty"`
+	Version     string            `json:"version,omitempty"`    // version of the feature installed
+	Parameters  map[string]string `json:"parameters,omitempty"` // values of the parameters used at installation (or last upgrade)
 }
 
 // NewHostInstalledFeature ...
@@ -612,10 +614,21 @@ func (hif *HostInstalledFeature) Clone() data.Clonable {
 // satisfies interface data.Clonable
 func (hif *HostInstalledFeature) Replace(p data.Clonable) data.Clonable {
 	src := p.(*HostInstalledFeature)
-	hif.RequiredBy = make([]string, len(src.RequiredBy))
-	copy(hif.RequiredBy, src.RequiredBy)
-	hif.Requires = make([]string, len(src.Requires))
-	copy(hif.Requires, src.Requires)
+	*hif = *src
+	if src.RequiredBy != nil {
+		hif.RequiredBy = make([]string, len(src.RequiredBy))
+		copy(hif.RequiredBy, src.RequiredBy)
+	}
+	if src.Requires != nil {
+		hif.Requires = make([]string, len(src.Requires))
+		copy(hif.Requires, src.Requires)
+	}
+	if src.Parameters != nil {
+		hif.Parameters = make(map[string]string, len(src.Parameters))
+		for k, v := range src.Parameters {
+			hif.Parameters[k] = v
+		}
+	}
 	return hif
 }
 
@@ -661,6 +674,136 @@ func (hf *HostFeatures) Replace(p data.Clonable) data.Clonable {
 	return hf
 }
 
+// HostSSH contains information about the SSH server of the host
+// not FROZEN yet
+// Note: if tagged as FROZEN, must not be changed ever.
+//       Create a new version instead with updated/additional fields
+type HostSSH struct {
+	HostKey string `json:"host_key,omitempty"` // public key of the SSH server, in authorized_keys format, captured on first connection
+}
+
+// NewHostSSH ...
+func NewHostSSH() *HostSSH {
+	return &HostSSH{}
+}
+
+// Reset returns a blank HostSSH
+func (hs *HostSSH) Reset() {
+	*hs = HostSSH{}
+}
+
+// Content ...
+// satisfies interface data.Clonable
+func (hs *HostSSH) Content() data.Clonable {
+	return hs
+}
+
+// Clone ...
+// satisfies interface data.Clonable
+func (hs *HostSSH) Clone() data.Clonable {
+	return NewHostSSH().Replace(hs)
+}
+
+// Replace ...
+// satisfies interface data.Clonable
+func (hs *HostSSH) Replace(p data.Clonable) data.Clonable {
+	*hs = *p.(*HostSSH)
+	return hs
+}
+
+// HostFeatureStepLog describes the execution of a step of a feature action on the host
+// not FROZEN yet
+// Note: if tagged as FROZEN, must not be changed ever.
+//       Create a new version instead with updated/additional fields
+type HostFeatureStepLog struct {
+	Feature    string        `json:"feature"`               // name of the feature
+	Action     string        `json:"action"`                // action of the feature (check, add, remove, upgrade)
+	Step       string        `json:"step"`                  // name of the step
+	Run        string        `json:"run"`                   // identifies the execution of the action, shared by all its steps
+	TargetType string        `json:"target_type,omitempty"` // type of the target of the action (host, node, cluster)
+	Target     string        `json:"target,omitempty"`      // name of the target of the action
+	StartedAt  time.Time     `json:"started_at"`            // when the step started on the host
+	Duration   time.Duration `json:"duration"`              // duration of the step on the host
+	ExitCode   int           `json:"exit_code"`             // exit code of the script of the step (-1 if it couldn't be run)
+	Error      string        `json:"error,omitempty"`       // error occurred, if any
+	LogFile    string        `json:"log_file,omitempty"`    // path on the host of the file containing the outputs of the script
+}
+
+// NewHostFeatureStepLog ...
+func NewHostFeatureStepLog() *HostFeatureStepLog {
+	return &HostFeatureStepLog{}
+}
+
+// Reset resets the content of the property
+func (hfsl *HostFeatureStepLog) Reset() {
+	*hfsl = HostFeatureStepLog{}
+}
+
+// Content ...
+// satisfies interface data.Clonable
+func (hfsl *HostFeatureStepLog) Content() data.Clonable {
+	return hfsl
+}
+
+// Clone ...
+// satisfies interface data.Clonable
+func (hfsl *HostFeatureStepLog) Clone() data.Clonable {
+	return NewHostFeatureStepLog().Replace(hfsl)
+}
+
+// Replace ...
+// satisfies interface data.Clonable
+func (hfsl *HostFeatureStepLog) Replace(p data.Clonable) data.Clonable {
+	*hfsl = *p.(*HostFeatureStepLog)
+	return hfsl
+}
+
+// HostFeatureLogs contains the index of the logs of the steps of feature actions run on the host, from the
+// oldest to the most recent
+// not FROZEN yet
+// Note: if tagged as FROZEN, must not be changed ever.
+//       Create a new version instead with updated/additional fields
+type HostFeatureLogs struct {
+	Entries []*HostFeatureStepLog `json:"entries,omitempty"`
+}
+
+// NewHostFeatureLogs ...
+func NewHostFeatureLogs() *HostFeatureLogs {
+	return &HostFeatureLogs{
+		Entries: []*HostFeatureStepLog{},
+	}
+}
+
+// Reset resets the content of the property
+func (hfl *HostFeatureLogs) Reset() {
+	*hfl = HostFeatureLogs{
+		Entries: []*HostFeatureStepLog{},
+	}
+}
+
+// Content ...
+// satisfies interface data.Clonable
+func (hfl *HostFeatureLogs) Content() data.Clonable {
+	return hfl
+}
+
+// Clone ...
+// satisfies interface data.Clonable
+func (hfl *HostFeatureLogs) Clone() data.Clonable {
+	return NewHostFeatureLogs().Replace(hfl)
+}
+
+// Replace ...
+// satisfies interface data.Clonable
+func (hfl *HostFeatureLogs) Replace(p data.Clonable) data.Clonable {
+	src := p.(*HostFeatureLogs)
+	hfl.Entries = make([]*HostFeatureStepLog, 0, len(src.Entries))
+	for _, v := range src.Entries {
+		hfl.Entries = append(hfl.Entries, v.Clone().(*HostFeatureStepLog))
+	}
+	return hfl
+}
+
 func init() {
 	serialize.PropertyTypeRegistry.Register("resources.host", hostproperty.DescriptionV1, NewHostDescription())
 	serialize.PropertyTypeRegistry.Register("resources.host", hostproperty.NetworkV1, NewHostNetwork())
@@ -669,4 +812,6 @@ func init() {
 	serialize.PropertyTypeRegistry.Register("resources.host", hostproperty.VolumesV1, NewHostVolumes())
 	serialize.PropertyTypeRegistry.Register("resources.host", hostproperty.MountsV1, NewHostMounts())
 	serialize.PropertyTypeRegistry.Register("resources.host", hostproperty.FeaturesV1, NewHostFeatures())
+	serialize.PropertyTypeRegistry.Register("resources.host", hostproperty.SSHV1, NewHostSSH())
+	serialize.PropertyTypeRegistry.Register("resources.host", hostproperty.FeatureLogsV1, NewHostFeatureLogs())
 }
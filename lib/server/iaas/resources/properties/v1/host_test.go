@@ -151,6 +151,8 @@ func TestHostShares_Clone(t *testing.T) {
 func TestHostInstalledFeature_Clone(t *testing.T) {
 	ct := NewHostInstalledFeature()
 	ct.Requires = append(ct.Requires, "DarkestRoads")
+	ct.Version = "19.03"
+	ct.Parameters = map[string]string{"Port": "2376"}
 
 	clonedCt, ok := ct.Clone().(*HostInstalledFeature)
 	if !ok {
@@ -159,6 +161,7 @@ func TestHostInstalledFeature_Clone(t *testing.T) {
 
 	assert.Equal(t, ct, clonedCt)
 	clonedCt.Requires[0] = "mistake"
+	clonedCt.Parameters["Port"] = "2377"
 
 	areEqual := reflect.DeepEqual(ct, clonedCt)
 	if areEqual {
@@ -187,3 +190,46 @@ func TestHostFeatures_Clone(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestHostSSH_Clone(t *testing.T) {
+	ct := NewHostSSH()
+	ct.HostKey = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIHostKey"
+
+	clonedCt, ok := ct.Clone().(*HostSSH)
+	if !ok {
+		t.Fail()
+	}
+
+	assert.Equal(t, ct, clonedCt)
+	clonedCt.HostKey = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIOther"
+
+	areEqual := reflect.DeepEqual(ct, clonedCt)
+	if areEqual {
+		t.Error("It's a shallow clone !")
+		t.Fail()
+	}
+}
+
+func TestHostFeatureLogs_Clone(t *testing.T) {
+	ct := NewHostFeatureLogs()
+	ct.Entries = append(ct.Entries, &HostFeatureStepLog{
+		Feature:  "docker",
+		Action:   "add",
+		Step:     "docker-ce",
+		ExitCode: 0,
+	})
+
+	clonedCt, ok := ct.Clone().(*HostFeatureLogs)
+	if !ok {
+		t.Fail()
+	}
+
+	assert.Equal(t, ct, clonedCt)
+	clonedCt.Entries[0].ExitCode = 1
+
+	areEqual := reflect.DeepEqual(ct, clonedCt)
+	if areEqual {
+		t.Error("It's a shallow clone !")
+		t.Fail()
+	}
+}
@@ -109,7 +109,95 @@ func (nh *NetworkHosts) Replace(p data.Clonable) data.Clonable {
 	return nh
 }
 
+// NetworkProxyRule describes a rule published by a feature in the reverse proxy of a gateway
+// not FROZEN yet
+// Note: if tagged as FROZEN, must not be changed ever.
+//       Create a new version instead with needed supplemental/overriding fields
+type NetworkProxyRule struct {
+	Feature   string    `json:"feature"`        // name of the feature having published the rule
+	Gateway   string    `json:"gateway"`        // name of the gateway running the reverse proxy
+	Kind      string    `json:"kind"`           // kind of reverse proxy the rule has been applied to
+	Type      string    `json:"type"`           // type of the rule (service, route, upstream)
+	Name      string    `json:"name"`           // name of the rule
+	Host      string    `json:"host,omitempty"` // name of the host the rule has been applied for
+	Content   string    `json:"content"`        // content of the rule, after replacement of the variables
+	AppliedAt time.Time `json:"applied_at"`     // when the rule has been applied
+}
+
+// NewNetworkProxyRule ...
+func NewNetworkProxyRule() *NetworkProxyRule {
+	return &NetworkProxyRule{}
+}
+
+// Content ...
+// satisfies interface data.Clonable
+func (npr *NetworkProxyRule) Content() data.Clonable {
+	return npr
+}
+
+// Clone ...
+// satisfies interface data.Clonable
+func (npr *NetworkProxyRule) Clone() data.Clonable {
+	return NewNetworkProxyRule().Replace(npr)
+}
+
+// Replace ...
+// satisfies interface data.Clonable
+func (npr *NetworkProxyRule) Replace(p data.Clonable) data.Clonable {
+	*npr = *p.(*NetworkProxyRule)
+	return npr
+}
+
+// NetworkReverseProxy contains the kind of reverse proxy running on the gateways of the network, and the rules
+// published in it by features
+// not FROZEN yet
+// Note: if tagged as FROZEN, must not be changed ever.
+//       Create a new version instead with needed supplemental/overriding fields
+type NetworkReverseProxy struct {
+	Kind  string              `json:"kind,omitempty"`  // kind of reverse proxy (kong, traefik, haproxy, nginx); kong if empty
+	Rules []*NetworkProxyRule `json:"rules,omitempty"` // rules published by features
+}
+
+// NewNetworkReverseProxy ...
+func NewNetworkReverseProxy() *NetworkReverseProxy {
+	return &NetworkReverseProxy{
+		Rules: []*NetworkProxyRule{},
+	}
+}
+
+// Reset resets the content of the property
+func (nrp *NetworkReverseProxy) Reset() {
+	*nrp = NetworkReverseProxy{
+		Rules: []*NetworkProxyRule{},
+	}
+}
+
+// Content ...
+// satisfies interface data.Clonable
+func (nrp *NetworkReverseProxy) Content() data.Clonable {
+	return nrp
+}
+
+// Clone ...
+// satisfies interface data.Clonable
+func (nrp *NetworkReverseProxy) Clone() data.Clonable {
+	return NewNetworkReverseProxy().Replace(nrp)
+}
+
+// Replace ...
+// satisfies interface data.Clonable
+func (nrp *NetworkReverseProxy) Replace(p data.Clonable) data.Clonable {
+	src := p.(*NetworkReverseProxy)
+	nrp.Kind = src.Kind
+	nrp.Rules = make([]*NetworkProxyRule, 0, len(src.Rules))
+	for _, v := range src.Rules {
+		nrp.Rules = append(nrp.Rules, v.Clone().(*NetworkProxyRule))
+	}
+	return nrp
+}
+
 func init() {
 	serialize.PropertyTypeRegistry.Register("resources.network", networkproperty.HostsV1, NewNetworkHosts())
 	serialize.PropertyTypeRegistry.Register("resources.network", networkproperty.DescriptionV1, NewNetworkDescription())
+	serialize.PropertyTypeRegistry.Register("resources.network", networkproperty.ReverseProxyV1, NewNetworkReverseProxy())
 }
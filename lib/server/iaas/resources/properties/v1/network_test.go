@@ -43,3 +43,23 @@ func TestNetworkHosts_Clone(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestNetworkReverseProxy_Clone(t *testing.T) {
+	ct := NewNetworkReverseProxy()
+	ct.Kind = "haproxy"
+	ct.Rules = append(ct.Rules, &NetworkProxyRule{Feature: "kibana", Type: "route", Name: "kibana_route"})
+
+	clonedCt, ok := ct.Clone().(*NetworkReverseProxy)
+	if !ok {
+		t.Fail()
+	}
+
+	assert.Equal(t, ct, clonedCt)
+	clonedCt.Rules[0].Name = "Other"
+
+	areEqual := reflect.DeepEqual(ct, clonedCt)
+	if areEqual {
+		t.Error("It's a shallow clone !")
+		t.Fail()
+	}
+}
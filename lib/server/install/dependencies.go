@@ -0,0 +1,413 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package install
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/CS-SI/SafeScale/lib/server/install/repository"
+	"github.com/CS-SI/SafeScale/lib/utils/concurrency"
+	"github.com/CS-SI/SafeScale/lib/utils/data"
+	"github.com/CS-SI/SafeScale/lib/utils/scerr"
+)
+
+const (
+	yamlRequirementsKey = "feature.requirements.features"
+	yamlConflictsKey    = "feature.conflicts"
+)
+
+// Plan is the graph of the requirements of a set of features (key 'feature.requirements.features' of the
+// specification files), resolved recursively
+type Plan struct {
+	task     concurrency.Task
+	features map[string]*Feature
+	requires map[string][]string
+	skip     map[string]struct{}
+	levels   [][]string
+	// containerMode is the way containers are deployed on the target, deciding if docker is required
+	containerMode string
+}
+
+// NewPlan resolves the requirements of the features 'refs' (in format <name>[@<version>]), to be installed on
+// hosts (the features installed with containers require docker);
+// returns an error if a feature cannot be found, if requirements form a cycle, or if a feature is required
+// in different versions
+func NewPlan(task concurrency.Task, refs ...string) (*Plan, error) {
+	if task == nil {
+		return nil, scerr.InvalidParameterError("task", "cannot be nil")
+	}
+	p := newEmptyPlan(task)
+	visiting := map[string]struct{}{}
+	for _, ref := range refs {
+		_, err := p.load(ref, nil, visiting)
+		if err != nil {
+			return nil, err
+		}
+	}
+	p.sort()
+	return p, nil
+}
+
+// planFor resolves the requirements of the feature 'f' to install on the target 't'; 'f' itself is part of the plan
+// but isn't installed by it
+func planFor(f *Feature, t Target) (*Plan, error) {
+	p := newEmptyPlan(f.task)
+	p.containerMode = containerModeOf(f, t)
+	name := f.DisplayName()
+	p.features[name] = f
+	p.skip[name] = struct{}{}
+	visiting := map[string]struct{}{name: {}}
+	for _, ref := range f.requirements(p.containerMode) {
+		required, err := p.load(ref, []string{name}, visiting)
+		if err != nil {
+			return nil, err
+		}
+		p.requires[name] = append(p.requires[name], required)
+	}
+	p.sort()
+	return p, nil
+}
+
+func newEmptyPlan(task concurrency.Task) *Plan {
+	return &Plan{
+		task:          task,
+		features:      map[string]*Feature{},
+		requires:      map[string][]string{},
+		skip:          map[string]struct{}{},
+		containerMode: containerModeHost,
+	}
+}
+
+// load adds to the plan the feature 'ref' and its requirements (depth first); 'path' is the chain of features
+// requiring it, used to report cycles. Returns the name of the feature.
+func (p *Plan) load(ref string, path []string, visiting map[string]struct{}) (string, error) {
+	name, version := repository.SplitReference(ref)
+	if _, ok := visiting[name]; ok {
+		cycle := append(path, name)
+		for i, v := range cycle {
+			if v == name {
+				cycle = cycle[i:]
+				break
+			}
+		}
+		return "", scerr.InvalidRequestError(fmt.Sprintf("cycle in requirements of features: %s", strings.Join(cycle, " -> ")))
+	}
+	if f, ok := p.features[name]; ok {
+		if version != "" && f.Version() != version {
+			return "", scerr.InvalidRequestError(fmt.Sprintf("feature '%s' is required in different versions ('%s' and '%s')", name, f.Version(), version))
+		}
+		return name, nil
+	}
+
+	f, err := NewFeature(p.task, ref)
+	if err != nil {
+		if len(path) > 0 {
+			return "", fmt.Errorf("failed to find feature '%s' required by '%s': %s", ref, path[len(path)-1], err.Error())
+		}
+		return "", err
+	}
+
+	visiting[name] = struct{}{}
+	var requires []string
+	for _, r := range f.requirements(p.containerMode) {
+		required, err := p.load(r, append(path, name), visiting)
+		if err != nil {
+			return "", err
+		}
+		requires = append(requires, required)
+	}
+	delete(visiting, name)
+
+	p.features[name] = f
+	p.requires[name] = requires
+	return name, nil
+}
+
+// requirements returns the features required by the feature; a feature installed with containers requires
+// the feature 'docker', unless the containers are deployed on Kubernetes ('mode' is containerModeKubernetes)
+func (f *Feature) requirements(mode string) []string {
+	requirements := f.specs.GetStringSlice(yamlRequirementsKey)
+	if !f.usesContainers() || f.DisplayName() == containerRequiredFeature || mode == containerModeKubernetes {
+		return requirements
+	}
+	for _, r := range requirements {
+		if name, _ := repository.SplitReference(r); name == containerRequiredFeature {
+			return requirements
+		}
+	}
+	return append(requirements, containerRequiredFeature)
+}
+
+// sort orders the features in levels: the features of a level only require features of the previous levels
+func (p *Plan) sort() {
+	p.levels = nil
+	done := map[string]struct{}{}
+	for len(done) < len(p.features) {
+		var level []string
+		for name := range p.features {
+			if _, ok := done[name]; ok {
+				continue
+			}
+			ready := true
+			for _, r := range p.requires[name] {
+				if _, ok := done[r]; !ok {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				level = append(level, name)
+			}
+		}
+		if len(level) == 0 {
+			// cannot happen, cycles are detected when loading
+			return
+		}
+		sort.Strings(level)
+		for _, name := range level {
+			done[name] = struct{}{}
+		}
+		p.levels = append(p.levels, level)
+	}
+}
+
+// Levels returns the names of the features of the plan, ordered in levels: the features of a level only require
+// features of the previous levels, and can be installed in parallel
+func (p *Plan) Levels() [][]string {
+	out := make([][]string, 0, len(p.levels))
+	for _, l := range p.levels {
+		out = append(out, append([]string{}, l...))
+	}
+	return out
+}
+
+// CheckConflicts checks that the features of the plan (key 'feature.conflicts' of the specification files) don't
+// conflict between them, nor with the features 'installed'
+func (p *Plan) CheckConflicts(installed []string) error {
+	var errs []string
+	for _, name := range p.names() {
+		for _, c := range p.features[name].specs.GetStringSlice(yamlConflictsKey) {
+			c, _ = repository.SplitReference(c)
+			if _, ok := p.features[c]; ok {
+				errs = append(errs, fmt.Sprintf("feature '%s' conflicts with feature '%s'", name, c))
+				continue
+			}
+			for _, i := range installed {
+				if i == c {
+					errs = append(errs, fmt.Sprintf("feature '%s' conflicts with feature '%s' installed", name, c))
+					break
+				}
+			}
+		}
+	}
+
+	// Conflicts may be declared by installed features only
+	for _, i := range installed {
+		if _, ok := p.features[i]; ok {
+			continue
+		}
+		f, err := NewFeature(p.task, i)
+		if err != nil {
+			logrus.Warnf("failed to load installed feature '%s' to check conflicts: %v", i, err)
+			continue
+		}
+		for _, c := range f.specs.GetStringSlice(yamlConflictsKey) {
+			c, _ = repository.SplitReference(c)
+			if _, ok := p.features[c]; ok {
+				errs = append(errs, fmt.Sprintf("feature '%s' installed conflicts with feature '%s'", i, c))
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return scerr.InvalidRequestError(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// Add installs the features of the plan on the target, level by level; the features of a level are installed in
+// parallel. Features already installed are left as is.
+func (p *Plan) Add(t Target, v Variables, s Settings) error {
+	// Requirements are handled by the plan
+	s.SkipFeatureRequirements = true
+
+	for _, level := range p.levels {
+		subtasks := map[string]concurrency.Task{}
+		for _, name := range level {
+			if _, ok := p.skip[name]; ok {
+				continue
+			}
+			subtask, err := concurrency.NewTask(p.task)
+			if err != nil {
+				return err
+			}
+			subtask, err = subtask.Start(p.taskAdd, data.Map{
+				"feature":   p.features[name],
+				"target":    t,
+				"variables": v.Clone(),
+				"settings":  s,
+			})
+			if err != nil {
+				return err
+			}
+			subtasks[name] = subtask
+		}
+
+		var errs []string
+		for name, subtask := range subtasks {
+			_, err := subtask.Wait()
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("failed to install required feature '%s': %s", name, err.Error()))
+			}
+		}
+		if len(errs) > 0 {
+			sort.Strings(errs)
+			return fmt.Errorf("%s", strings.Join(errs, "\n"))
+		}
+	}
+	return nil
+}
+
+// taskAdd checks a feature of the plan and installs it if needed, in a subtask
+func (p *Plan) taskAdd(task concurrency.Task, params concurrency.TaskParameters) (concurrency.TaskResult, error) {
+	m := params.(data.Map)
+	f := m["feature"].(*Feature)
+	t := m["target"].(Target)
+	v := m["variables"].(Variables)
+	s := m["settings"].(Settings)
+	results, err := f.Check(t, v, s)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check: %s", err.Error())
+	}
+	if results.Successful() {
+		return nil, nil
+	}
+	results, err = f.Add(t, v, s)
+	if err != nil {
+		return nil, err
+	}
+	if !results.Successful() {
+		return nil, fmt.Errorf("%s", results.AllErrorMessages())
+	}
+	return nil, nil
+}
+
+// names returns the names of the features of the plan, sorted
+func (p *Plan) names() []string {
+	var out []string
+	for k := range p.features {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Dependents returns the features installed on the target that require the feature, directly or not, ordered
+// so that each one can be removed before the ones it requires
+func (f *Feature) Dependents(t Target) ([]string, error) {
+	if f == nil {
+		return nil, scerr.InvalidInstanceError()
+	}
+	installed := t.Installed()
+	if len(installed) == 0 {
+		return nil, nil
+	}
+
+	var refs []string
+	for _, i := range installed {
+		if i != f.DisplayName() {
+			refs = append(refs, i)
+		}
+	}
+	p := newEmptyPlan(f.task)
+	p.containerMode = containerModeOf(f, t)
+	for _, ref := range refs {
+		_, err := p.load(ref, nil, map[string]struct{}{})
+		if err != nil {
+			logrus.Warnf("failed to resolve requirements of installed feature '%s': %v", ref, err)
+		}
+	}
+	p.sort()
+
+	// Walks the levels upward: a feature is a dependent if it requires the feature or a dependent
+	dependents := map[string]struct{}{}
+	var out []string
+	for _, level := range p.levels {
+		for _, name := range level {
+			for _, r := range p.requires[name] {
+				_, isDependent := dependents[r]
+				if r == f.DisplayName() || isDependent {
+					dependents[name] = struct{}{}
+					break
+				}
+			}
+		}
+	}
+	for i := len(p.levels) - 1; i >= 0; i-- {
+		for _, name := range p.levels[i] {
+			if _, ok := dependents[name]; !ok {
+				continue
+			}
+			for _, inst := range installed {
+				if inst == name {
+					out = append(out, name)
+					break
+				}
+			}
+		}
+	}
+	return out, nil
+}
+
+// removeDependents removes the installed features requiring the feature, if allowed by 's.Cascade'
+func (f *Feature) removeDependents(t Target, v Variables, s Settings) error {
+	if s.skipDependents {
+		return nil
+	}
+	dependents, err := f.Dependents(t)
+	if err != nil {
+		return err
+	}
+	if len(dependents) == 0 {
+		return nil
+	}
+	if !s.Cascade {
+		return scerr.NotAvailableError(fmt.Sprintf("feature '%s' is required by installed features '%s' on %s '%s' (use cascade to remove them too)",
+			f.DisplayName(), strings.Join(dependents, "', '"), t.Type(), t.Name()))
+	}
+
+	// Dependents are ordered, each one is removed before the ones it requires
+	s.skipDependents = true
+	for _, name := range dependents {
+		dependent, err := NewFeature(f.task, name)
+		if err != nil {
+			return err
+		}
+		results, err := dependent.Remove(t, v, s)
+		if err != nil {
+			return fmt.Errorf("failed to remove feature '%s' requiring '%s': %s", name, f.DisplayName(), err.Error())
+		}
+		if !results.Successful() {
+			return fmt.Errorf("failed to remove feature '%s' requiring '%s':\n%s", name, f.DisplayName(), results.AllErrorMessages())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,261 @@
+package install
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/CS-SI/SafeScale/lib/server/install/enums/method"
+	"github.com/CS-SI/SafeScale/lib/utils/concurrency"
+)
+
+// useTestFeatures writes the specification files of features requiring each other in a temporary directory and
+// makes it the current directory, where NewFeature looks first; returns the function restoring the current directory
+func useTestFeatures(t *testing.T, requires map[string][]string) func() {
+	dir, err := ioutil.TempDir("", "safescale-features")
+	require.NoError(t, err)
+	for name, required := range requires {
+		content := "feature:\n    suitableFor:\n        host: yes\n"
+		if len(required) > 0 {
+			content += "    requirements:\n        features:\n            - " + strings.Join(required, "\n            - ") + "\n"
+		}
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name+".yml"), []byte(content), 0600))
+	}
+	cwd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	return func() {
+		_ = os.Chdir(cwd)
+		_ = os.RemoveAll(dir)
+	}
+}
+
+// testTarget is a Target on which the features 'installed' are installed
+type testTarget struct {
+	installed []string
+}
+
+func (t testTarget) Name() string                   { return "test-host" }
+func (t testTarget) Type() string                   { return "host" }
+func (t testTarget) Methods() map[uint8]method.Enum { return nil }
+func (t testTarget) Installed() []string            { return t.installed }
+
+func TestPlan_Levels(t *testing.T) {
+	p := newEmptyPlan(nil)
+	for _, name := range []string{"docker", "kubernetes", "helm", "nvidiadocker", "proxycache-client"} {
+		p.features[name] = newTestFeature(t, "feature:\n    suitableFor:\n        host: yes\n")
+	}
+	p.requires["kubernetes"] = []string{"docker"}
+	p.requires["helm"] = []string{"kubernetes"}
+	p.requires["nvidiadocker"] = []string{"docker"}
+	p.sort()
+
+	assert.Equal(t, [][]string{
+		{"docker", "proxycache-client"},
+		{"kubernetes", "nvidiadocker"},
+		{"helm"},
+	}, p.Levels())
+}
+
+func TestPlan_CheckConflicts(t *testing.T) {
+	p := newEmptyPlan(nil)
+	p.features["mpich-build"] = newTestFeature(t, "feature:\n    conflicts:\n        - openmpi\n")
+	p.features["docker"] = newTestFeature(t, "feature:\n    suitableFor:\n        host: yes\n")
+	p.sort()
+	assert.NoError(t, p.CheckConflicts(nil))
+
+	p.features["openmpi"] = newTestFeature(t, "feature:\n    suitableFor:\n        host: yes\n")
+	p.sort()
+	err := p.CheckConflicts(nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "'mpich-build' conflicts with feature 'openmpi'")
+}
+
+func TestPlan_Pending(t *testing.T) {
+	p := newEmptyPlan(nil)
+	for _, name := range []string{"docker", "kubernetes", "helm"} {
+		p.features[name] = newTestFeature(t, "feature:\n    suitableFor:\n        host: yes\n")
+	}
+	p.requires["kubernetes"] = []string{"docker"}
+	p.requires["helm"] = []string{"kubernetes"}
+	p.skip["helm"] = struct{}{}
+	p.sort()
+
+	assert.Equal(t, [][]string{{"docker"}, {"kubernetes"}}, p.pending(nil))
+	assert.Equal(t, [][]string{{"kubernetes"}}, p.pending([]string{"docker"}))
+}
+
+func TestNewPlan_Cycles(t *testing.T) {
+	tests := []struct {
+		name     string
+		requires map[string][]string
+		refs     []string
+		cycle    string
+	}{
+		{
+			name:     "self",
+			requires: map[string][]string{"cyc-a": {"cyc-a"}},
+			refs:     []string{"cyc-a"},
+			cycle:    "cyc-a -> cyc-a",
+		},
+		{
+			name:     "two features",
+			requires: map[string][]string{"cyc-a": {"cyc-b"}, "cyc-b": {"cyc-a"}},
+			refs:     []string{"cyc-a"},
+			cycle:    "cyc-a -> cyc-b -> cyc-a",
+		},
+		{
+			name:     "cycle below the feature",
+			requires: map[string][]string{"cyc-top": {"cyc-a"}, "cyc-a": {"cyc-b"}, "cyc-b": {"cyc-c"}, "cyc-c": {"cyc-a"}},
+			refs:     []string{"cyc-top"},
+			cycle:    "cyc-a -> cyc-b -> cyc-c -> cyc-a",
+		},
+		{
+			name:     "diamond",
+			requires: map[string][]string{"cyc-a": {"cyc-b", "cyc-c"}, "cyc-b": {"cyc-d"}, "cyc-c": {"cyc-d"}, "cyc-d": nil},
+			refs:     []string{"cyc-a", "cyc-d"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer useTestFeatures(t, tt.requires)()
+
+			p, err := NewPlan(concurrency.RootTask(), tt.refs...)
+			if tt.cycle == "" {
+				require.NoError(t, err)
+				assert.Equal(t, [][]string{{"cyc-d"}, {"cyc-b", "cyc-c"}, {"cyc-a"}}, p.Levels())
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "cycle in requirements of features: "+tt.cycle)
+		})
+	}
+}
+
+func TestFeature_Dependents(t *testing.T) {
+	requires := map[string][]string{
+		"dep-base":      nil,
+		"dep-mid":       {"dep-base"},
+		"dep-top":       {"dep-mid"},
+		"dep-other":     {"dep-base"},
+		"dep-unrelated": nil,
+	}
+	tests := []struct {
+		name       string
+		installed  []string
+		dependents []string
+	}{
+		{
+			name:       "all installed",
+			installed:  []string{"dep-base", "dep-mid", "dep-top", "dep-other", "dep-unrelated"},
+			dependents: []string{"dep-top", "dep-mid", "dep-other"},
+		},
+		{
+			name:       "direct dependent only",
+			installed:  []string{"dep-base", "dep-mid"},
+			dependents: []string{"dep-mid"},
+		},
+		{
+			name:       "intermediate not installed",
+			installed:  []string{"dep-base", "dep-top"},
+			dependents: []string{"dep-top"},
+		},
+		{
+			name:      "no dependent",
+			installed: []string{"dep-base", "dep-unrelated"},
+		},
+		{
+			name: "nothing installed",
+		},
+	}
+	defer useTestFeatures(t, requires)()
+	f, err := NewFeature(concurrency.RootTask(), "dep-base")
+	require.NoError(t, err)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := testTarget{installed: tt.installed}
+			dependents, err := f.Dependents(target)
+			require.NoError(t, err)
+			assert.Equal(t, tt.dependents, dependents)
+
+			// Without cascade, the removal is refused listing the dependents in the order they would be removed
+			err = f.removeDependents(target, Variables{}, Settings{})
+			if len(tt.dependents) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "required by installed features '"+strings.Join(tt.dependents, "', '")+"'")
+		})
+	}
+}
+
+func TestFeature_Requirements(t *testing.T) {
+	tests := []struct {
+		name         string
+		feature      string
+		mode         string
+		specs        string
+		requirements []string
+	}{
+		{
+			name:         "no container",
+			specs:        "feature:\n    requirements:\n        features:\n            - kubernetes\n",
+			requirements: []string{"kubernetes"},
+		},
+		{
+			name:         "container",
+			specs:        "feature:\n    install:\n        docker:\n            image: nginx\n",
+			requirements: []string{"docker"},
+		},
+		{
+			name:         "container on Swarm",
+			mode:         containerModeSwarm,
+			specs:        "feature:\n    install:\n        docker:\n            image: nginx\n",
+			requirements: []string{"docker"},
+		},
+		{
+			name:  "container on Kubernetes",
+			mode:  containerModeKubernetes,
+			specs: "feature:\n    install:\n        docker:\n            image: nginx\n",
+		},
+		{
+			name:         "compose with other requirements",
+			specs:        "feature:\n    requirements:\n        features:\n            - proxycache-client\n    install:\n        compose:\n            file: |\n                version: '3'\n",
+			requirements: []string{"proxycache-client", "docker"},
+		},
+		{
+			name:         "docker already required in a version",
+			specs:        "feature:\n    requirements:\n        features:\n            - docker@19.03\n    install:\n        docker:\n            image: nginx\n",
+			requirements: []string{"docker@19.03"},
+		},
+		{
+			name:    "docker itself",
+			feature: "docker",
+			specs:   "feature:\n    install:\n        docker:\n            image: docker:dind\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newTestFeature(t, tt.specs)
+			if tt.feature != "" {
+				f.displayName = tt.feature
+			}
+			mode := tt.mode
+			if mode == "" {
+				mode = containerModeHost
+			}
+			requirements := f.requirements(mode)
+			if len(tt.requirements) == 0 {
+				assert.Empty(t, requirements)
+				return
+			}
+			assert.Equal(t, tt.requirements, requirements)
+		})
+	}
+}
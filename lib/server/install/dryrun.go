@@ -0,0 +1,244 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package install
+
+import (
+	"fmt"
+	"strings"
+
+	pb "github.com/CS-SI/SafeScale/lib"
+	"github.com/CS-SI/SafeScale/lib/utils/concurrency"
+	"github.com/CS-SI/SafeScale/lib/utils/scerr"
+	"github.com/CS-SI/SafeScale/lib/utils/temporal"
+)
+
+// DryRun describes what the addition of a feature on a target would do
+type DryRun struct {
+	Feature    string            `json:"feature"`
+	Version    string            `json:"version,omitempty"`
+	Target     string            `json:"target"`
+	TargetType string            `json:"target_type"`
+	Method     string            `json:"method"`
+	Variables  map[string]string `json:"variables"`
+	// Requirements contains the required features that would be installed, in levels (the features of a level
+	// are installed in parallel, after the ones of the previous level)
+	Requirements [][]string        `json:"requirements,omitempty"`
+	ProxyRules   []DryRunProxyRule `json:"proxy_rules,omitempty"`
+	Steps        []DryRunStep      `json:"steps"`
+}
+
+// DryRunStep describes a step, in the order of the pace
+type DryRunStep struct {
+	Order      int    `json:"order"`
+	Name       string `json:"name"`
+	Serialized bool   `json:"serialized"`
+	Timeout    string `json:"timeout"`
+	// Hosts contains the names of the hosts the step would run on
+	Hosts []string `json:"hosts"`
+	// Scripts contains the script that would be run, indexed by host name
+	Scripts map[string]string `json:"scripts"`
+	Options string            `json:"options,omitempty"`
+}
+
+// DryRunProxyRule describes a reverse proxy rule that would be applied
+type DryRunProxyRule struct {
+	Gateway string `json:"gateway"`
+	Host    string `json:"host"`
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	Content string `json:"content"`
+}
+
+// DryRun resolves what the addition of the feature on the target would do (requirements to install, hosts
+// concerned by each step, scripts, reverse proxy rules) without running anything on the hosts.
+// As the feature isn't checked on the hosts, all the running hosts targeted by a step are listed.
+// The values of secret parameters, and of implicit secrets like ClusterAdminPassword, are masked.
+func (f *Feature) DryRun(t Target, v Variables, s Settings) (_ *DryRun, err error) {
+	if f == nil {
+		return nil, scerr.InvalidInstanceError()
+	}
+
+	tracer := concurrency.NewTracer(f.task, fmt.Sprintf("(): '%s' on %s '%s'", f.DisplayName(), t.Type(), t.Name()), true).WithStopwatch().GoingIn()
+	defer tracer.OnExitTrace()()
+	defer scerr.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	installer, meth, err := f.installerFor(t)
+	if err != nil {
+		return nil, err
+	}
+
+	myV := v.Clone()
+
+	// Inits implicit parameters
+	err = f.setImplicitParameters(t, myV)
+	if err != nil {
+		return nil, err
+	}
+
+	// Checks parameters against their schema and sets default values
+	err = validateParameters(f, myV)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &DryRun{
+		Feature:    f.DisplayName(),
+		Version:    f.Version(),
+		Target:     t.Name(),
+		TargetType: t.Type(),
+		Method:     strings.ToLower(meth.String()),
+		Variables:  f.maskedVariables(myV),
+	}
+
+	if !s.SkipFeatureRequirements {
+		plan, err := planFor(f, t)
+		if err != nil {
+			return nil, err
+		}
+		installed := t.Installed()
+		err = plan.CheckConflicts(installed)
+		if err != nil {
+			return nil, err
+		}
+		report.Requirements = plan.pending(installed)
+	}
+
+	s.dryRun = report
+	_, err = installer.Add(f, t, myV, s)
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// maskedVariables returns the values of the variables converted to string, with the secrets masked
+func (f *Feature) maskedVariables(v Variables) map[string]string {
+	secrets := f.secretValues(v)
+	out := map[string]string{}
+	for k, value := range v.Strings() {
+		out[k] = maskSecrets(value, secrets)
+	}
+	return out
+}
+
+// pending returns the levels of the plan without the features skipped and the ones in 'installed'
+func (p *Plan) pending(installed []string) [][]string {
+	ignored := map[string]struct{}{}
+	for k := range p.skip {
+		ignored[k] = struct{}{}
+	}
+	for _, k := range installed {
+		ignored[k] = struct{}{}
+	}
+	var out [][]string
+	for _, level := range p.levels {
+		var names []string
+		for _, name := range level {
+			if _, ok := ignored[name]; !ok {
+				names = append(names, name)
+			}
+		}
+		if len(names) > 0 {
+			out = append(out, names)
+		}
+	}
+	return out
+}
+
+// Render adds to the report the scripts the step would run on the hosts
+func (is *step) Render(hosts []*pb.Host, v Variables, report *DryRun) error {
+	secrets := is.Worker.feature.secretValues(v)
+	rendered := DryRunStep{
+		Order:      len(report.Steps) + 1,
+		Name:       is.Name,
+		Serialized: is.Serial || is.Worker.settings.Serialize,
+		Timeout:    temporal.FormatDuration(is.WallTime),
+		Scripts:    map[string]string{},
+		Options:    maskSecrets(is.OptionsFileContent, secrets),
+	}
+	for _, h := range hosts {
+		cloneV := v.Clone()
+		cloneV["HostIP"] = h.PrivateIp
+		cloneV["Hostname"] = h.Name
+		cloneV, err := realizeVariables(cloneV)
+		if err != nil {
+			return err
+		}
+		// Secrets may be set from templates, their realized values are masked
+		hostSecrets := is.Worker.feature.secretValues(cloneV)
+		command, err := replaceVariablesInString(is.Script, cloneV)
+		if err != nil {
+			return fmt.Errorf("failed to finalize installer script for step '%s': %s", is.Name, maskSecrets(err.Error(), hostSecrets))
+		}
+		rendered.Hosts = append(rendered.Hosts, h.Name)
+		rendered.Scripts[h.Name] = maskSecrets(command, hostSecrets)
+	}
+	report.Steps = append(report.Steps, rendered)
+	return nil
+}
+
+// renderReverseProxy adds to the report the reverse proxy rules that setReverseProxy would apply
+func (w *worker) renderReverseProxy(report *DryRun) error {
+	rules, ok := w.feature.specs.Get("feature.proxy.rules").([]interface{})
+	if !ok || len(rules) == 0 {
+		return nil
+	}
+
+	primaryController, secondaryController, err := w.proxyControllers(false)
+	if err != nil {
+		return err
+	}
+	if primaryController == nil {
+		return nil
+	}
+	controllers := []ReverseProxyController{primaryController}
+	if secondaryController != nil {
+		controllers = append(controllers, secondaryController)
+	}
+
+	secrets := w.feature.secretValues(w.variables)
+	for _, r := range rules {
+		rule := r.(map[interface{}]interface{})
+		targets, ok := w.proxyRuleTargets(rule)
+		if !ok {
+			continue
+		}
+		hosts, err := w.identifyHosts(targets)
+		if err != nil {
+			return scerr.InvalidRequestError(fmt.Sprintf("failed to identify hosts of proxy rules: %s", err.Error()))
+		}
+		for _, h := range hosts {
+			for _, ctrl := range controllers {
+				vars := w.variables.Clone()
+				vars["HostIP"] = h.PrivateIp
+				vars["Hostname"] = h.Name
+				ruleType, ruleName, content, err := ctrl.Render(rule, &vars)
+				if err != nil {
+					return fmt.Errorf("failed to render proxy rule '%s' for host '%s': %s", ruleName, h.Name, maskSecrets(err.Error(), secrets))
+				}
+				report.ProxyRules = append(report.ProxyRules, DryRunProxyRule{
+					Gateway: ctrl.Gateway().Name,
+					Host:    h.Name,
+					Type:    ruleType,
+					Name:    ruleName,
+					Content: maskSecrets(content, secrets),
+				})
+			}
+		}
+	}
+	return nil
+}
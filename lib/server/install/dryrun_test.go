@@ -0,0 +1,38 @@
+package install
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	pb "github.com/CS-SI/SafeScale/lib"
+)
+
+func TestDryRun_MaskSecrets(t *testing.T) {
+	f := newTestFeature(t, testParametersSpecs)
+	v := Variables{
+		"Username":             "admin",
+		"Password":             "s3cr3tPassw0rd",
+		"ClusterAdminUsername": "cladm",
+		"ClusterAdminPassword": "cl4dmPassw0rd",
+	}
+
+	// Secret parameters of the feature and implicit secrets are masked in the variables of the report...
+	variables := f.maskedVariables(v)
+	assert.Equal(t, "admin", variables["Username"])
+	assert.Equal(t, maskedValue, variables["Password"])
+	assert.Equal(t, "cladm", variables["ClusterAdminUsername"])
+	assert.Equal(t, maskedValue, variables["ClusterAdminPassword"])
+
+	// ... and in the rendered scripts
+	is := &step{
+		Worker: &worker{feature: f},
+		Name:   "install",
+		Script: "login {{ .Username }}:{{ .Password }}@{{ .Hostname }}\nchpasswd <<<'{{ .ClusterAdminUsername }}:{{ .ClusterAdminPassword }}'",
+	}
+	report := &DryRun{}
+	require.NoError(t, is.Render([]*pb.Host{{Name: "host-1", PrivateIp: "10.0.0.1"}}, v, report))
+	require.Len(t, report.Steps, 1)
+	assert.Equal(t, "login admin:"+maskedValue+"@host-1\nchpasswd <<<'cladm:"+maskedValue+"'", report.Steps[0].Scripts["host-1"])
+}
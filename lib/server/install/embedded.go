@@ -209,6 +209,36 @@ func kubernetesFeature() *Feature {
 	}
 }
 
+// k3sFeature ...
+func k3sFeature() *Feature {
+	name := "k3s"
+	filename, specs, err := loadSpecFile(name)
+	if err != nil {
+		panic(err.Error())
+	}
+	return &Feature{
+		displayName: name,
+		fileName:    filename,
+		embedded:    true,
+		specs:       specs,
+	}
+}
+
+// nomadFeature ...
+func nomadFeature() *Feature {
+	name := "nomad"
+	filename, specs, err := loadSpecFile(name)
+	if err != nil {
+		panic(err.Error())
+	}
+	return &Feature{
+		displayName: name,
+		fileName:    filename,
+		embedded:    true,
+		specs:       specs,
+	}
+}
+
 // // nexusFeature ...
 // func nexusFeature() *Feature {
 // 	name := "nexus3"
@@ -40,6 +40,10 @@ const (
 	DCOS
 	// Helm is supported by cluster target
 	Helm
+	// Docker runs a container image, supported by target
+	Docker
+	// Compose runs a docker-compose file, supported by target
+	Compose
 
 	// NextEnum marks the next value (or the max, depending the use)
 	NextEnum
@@ -54,6 +58,8 @@ var (
 		"ansible": Ansible,
 		"dcos":    DCOS,
 		"helm":    Helm,
+		"docker":  Docker,
+		"compose": Compose,
 	}
 
 	enumMap = map[Enum]string{
@@ -64,6 +70,8 @@ var (
 		Ansible: "Ansible",
 		DCOS:    "DCOS",
 		Helm:    "Helm",
+		Docker:  "Docker",
+		Compose: "Compose",
 	}
 )
 
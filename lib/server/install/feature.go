@@ -17,8 +17,11 @@
 package install
 
 import (
+	"bytes"
 	"fmt"
 	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/sirupsen/logrus"
@@ -29,6 +32,7 @@ import (
 	clusterpropsv1 "github.com/CS-SI/SafeScale/lib/server/cluster/control/properties/v1"
 	"github.com/CS-SI/SafeScale/lib/server/cluster/enums/property"
 	"github.com/CS-SI/SafeScale/lib/server/install/enums/method"
+	"github.com/CS-SI/SafeScale/lib/server/install/repository"
 	"github.com/CS-SI/SafeScale/lib/utils"
 	"github.com/CS-SI/SafeScale/lib/utils/concurrency"
 	"github.com/CS-SI/SafeScale/lib/utils/data"
@@ -54,6 +58,15 @@ func (v Variables) Clone() Variables {
 	return clone
 }
 
+// Strings returns the content of a Variables with values converted to string, as recorded in metadata
+func (v Variables) Strings() map[string]string {
+	out := make(map[string]string, len(v))
+	for k, value := range v {
+		out[k] = fmt.Sprintf("%v", value)
+	}
+	return out
+}
+
 // Settings are used to tune the feature
 type Settings struct {
 	// SkipProxy to tell not to try to set reverse proxy
@@ -66,6 +79,16 @@ type Settings struct {
 	SkipSizingRequirements bool
 	// AddUnconditionally tells to not check before addition (no effect for check or removal)
 	AddUnconditionally bool
+	// Cascade tells to remove also the installed features requiring the feature (no effect for check or addition)
+	Cascade bool
+	// Reconcile tells to re-apply the steps whose configuration drifted (used by verification only)
+	Reconcile bool
+	// skipDependents tells not to look for installed features requiring the feature on removal
+	skipDependents bool
+	// dryRun, if set, receives the description of the steps instead of running them
+	dryRun *DryRun
+	// verify, if set, receives the drift of the configuration instead of running the steps
+	verify *Verification
 }
 
 // Feature contains the information about an installable feature
@@ -76,6 +99,10 @@ type Feature struct {
 	fileName string
 	// embedded tells if the feature is embedded in deploy
 	embedded bool
+	// repository is the name of the feature repository providing the feature, if any
+	repository string
+	// version is the version of the feature, if provided by a feature repository
+	version string
 	// Installers defines the installers available for the feature
 	installers map[method.Enum]Installer
 	// Dependencies lists other feature(s) (by name) needed by this one
@@ -116,6 +143,22 @@ func ListFeatures(suitableFor string) ([]interface{}, error) {
 		}
 	}
 
+	entries, err := repository.Search("")
+	if err != nil {
+		logrus.Error(err)
+	}
+	for _, e := range entries {
+		if _, ok := allEmbeddedMap[e.Name]; ok {
+			continue
+		}
+		feature, err := newRepositoryFeature(concurrency.RootTask(), e.Name, "")
+		if err != nil {
+			logrus.Error(err)
+			continue
+		}
+		allEmbeddedMap[feature.displayName] = feature
+	}
+
 	for _, feature := range features {
 		switch suitableFor {
 		case "host":
@@ -130,7 +173,7 @@ func ListFeatures(suitableFor string) ([]interface{}, error) {
 			yamlKey := "feature.suitableFor.cluster"
 			if feature.Specs().IsSet(yamlKey) {
 				values := strings.Split(strings.ToLower(feature.Specs().GetString(yamlKey)), ",")
-				if values[0] == "all" || values[0] == "dcos" || values[0] == "k8s" || values[0] == "boh" || values[0] == "swarm" || values[0] == "ohpc" {
+				if values[0] == "all" || values[0] == "dcos" || values[0] == "k8s" || values[0] == "k3s" || values[0] == "nomad" || values[0] == "boh" || values[0] == "swarm" || values[0] == "ohpc" {
 					cfg := struct {
 						FeatureName    string   `json:"feature"`
 						ClusterFlavors []string `json:"available-cluster-flavors"`
@@ -150,8 +193,37 @@ func ListFeatures(suitableFor string) ([]interface{}, error) {
 	return cfgFiles, nil
 }
 
+// LatestVersion returns the highest version of the feature available, either in feature repositories
+// or in local and embedded features; returns empty string if no version is known
+func LatestVersion(task concurrency.Task, name string) (string, error) {
+	var latest string
+	entry, _, err := repository.Find(name, "")
+	if err != nil {
+		if _, ok := err.(scerr.ErrNotFound); !ok {
+			return "", err
+		}
+	} else {
+		latest = entry.Version
+	}
+
+	feature, err := NewFeature(task, name)
+	if err != nil {
+		if _, ok := err.(scerr.ErrNotFound); !ok || latest == "" {
+			return "", err
+		}
+		return latest, nil
+	}
+	if repository.CompareVersions(feature.Version(), latest) > 0 {
+		latest = feature.Version()
+	}
+	return latest, nil
+}
+
 // NewFeature searches for a spec file name 'name' and initializes a new Feature object
 // with its content
+// 'name' may be in format <name>@<version> to use a specific version of the feature provided by
+// a feature repository; otherwise the feature is searched in local folders, then in embedded features,
+// then in feature repositories
 // error contains :
 //    - *scerr.ErrNotFound if no feature is found by its name
 //    - *scerr.ErrSyntax if feature found contains syntax error
@@ -167,6 +239,11 @@ func NewFeature(task concurrency.Task, name string) (_ *Feature, err error) {
 	defer tracer.OnExitTrace()()
 	defer scerr.OnExitLogError(tracer.TraceMessage(""), &err)()
 
+	name, version := repository.SplitReference(name)
+	if version != "" {
+		return newRepositoryFeature(task, name, version)
+	}
+
 	v := viper.New()
 	v.AddConfigPath(".")
 	v.AddConfigPath("$HOME/.safescale/features")
@@ -183,7 +260,8 @@ func NewFeature(task concurrency.Task, name string) (_ *Feature, err error) {
 			err = nil
 			var ok bool
 			if _, ok = allEmbeddedMap[name]; !ok {
-				err = scerr.NotFoundError(fmt.Sprintf("failed to find a feature named '%s'", name))
+				// Not embedded either, trying with feature repositories
+				return newRepositoryFeature(task, name, "")
 			} else {
 				feat = *allEmbeddedMap[name]
 				feat.task = task
@@ -202,6 +280,74 @@ func NewFeature(task concurrency.Task, name string) (_ *Feature, err error) {
 	return &feat, err
 }
 
+// NewFeatureFromFile initializes a new Feature object with the content of the specification file 'path';
+// the name of the feature is the name of the file without extension
+func NewFeatureFromFile(task concurrency.Task, path string) (_ *Feature, err error) {
+	if task == nil {
+		return nil, scerr.InvalidParameterError("task", "cannot be nil")
+	}
+	if path == "" {
+		return nil, scerr.InvalidParameterError("path", "cannot be empty string")
+	}
+
+	tracer := concurrency.NewTracer(task, "('"+path+"')", true).WithStopwatch().GoingIn()
+	defer tracer.OnExitTrace()()
+	defer scerr.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	if _, err = os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			return nil, scerr.NotFoundError(fmt.Sprintf("failed to find specification file '%s'", path))
+		}
+		return nil, err
+	}
+	v := viper.New()
+	v.SetConfigFile(path)
+	v.SetConfigType("yaml")
+	err = v.ReadInConfig()
+	if err != nil {
+		return nil, scerr.SyntaxError(fmt.Sprintf("failed to read the specification file '%s': %s", path, err.Error()))
+	}
+	if !v.IsSet("feature") {
+		return nil, scerr.SyntaxError(fmt.Sprintf("specification file '%s' has no 'feature' section", path))
+	}
+	base := filepath.Base(path)
+	return &Feature{
+		fileName:    path,
+		displayName: strings.TrimSuffix(base, filepath.Ext(base)),
+		specs:       v,
+		task:        task,
+	}, nil
+}
+
+// newRepositoryFeature initializes a new Feature object with the spec file of the version 'version'
+// (the highest one if empty) of the feature 'name' found in feature repositories
+func newRepositoryFeature(task concurrency.Task, name, version string) (*Feature, error) {
+	entry, content, err := repository.Find(name, version)
+	if err != nil {
+		if _, ok := err.(scerr.ErrNotFound); ok && version == "" {
+			return nil, scerr.NotFoundError(fmt.Sprintf("failed to find a feature named '%s'", name))
+		}
+		return nil, err
+	}
+	v := viper.New()
+	v.SetConfigType("yaml")
+	err = v.ReadConfig(bytes.NewReader(content))
+	if err != nil {
+		return nil, scerr.SyntaxError(fmt.Sprintf("failed to read the specification file of feature '%s@%s' from repository '%s': %s", name, entry.Version, entry.Repository, err.Error()))
+	}
+	if !v.IsSet("feature") {
+		return nil, scerr.SyntaxError(fmt.Sprintf("specification file of feature '%s@%s' from repository '%s' has no 'feature' section", name, entry.Version, entry.Repository))
+	}
+	return &Feature{
+		fileName:    entry.File,
+		displayName: name,
+		repository:  entry.Repository,
+		version:     entry.Version,
+		specs:       v,
+		task:        task,
+	}, nil
+}
+
 // NewEmbeddedFeature searches for an embedded featured named 'name' and initializes a new Feature object
 // with its content
 func NewEmbeddedFeature(task concurrency.Task, name string) (_ *Feature, err error) {
@@ -240,6 +386,10 @@ func (f *Feature) installerOfMethod(m method.Enum) Installer {
 		installer = NewDnfInstaller()
 	case method.DCOS:
 		installer = NewDcosInstaller()
+	case method.Docker:
+		installer = NewDockerInstaller()
+	case method.Compose:
+		installer = NewComposeInstaller()
 		//	case method.Ansible:
 		//		installer = NewAnsibleInstaller()
 		//	case method.Helm:
@@ -265,15 +415,74 @@ func (f *Feature) DisplayFilename() string {
 	if f.embedded {
 		filename += " [embedded]"
 	}
+	if f.repository != "" {
+		filename += fmt.Sprintf(" [%s@%s from repository %s]", f.displayName, f.version, f.repository)
+	}
 	return filename
 }
 
+// Version returns the version of the feature: the one of the feature repository it comes from, or the one
+// declared by key 'feature.version' of the specification file; empty string if none is known
+func (f *Feature) Version() string {
+	if f.version != "" {
+		return f.version
+	}
+	return f.specs.GetString("feature.version")
+}
+
 // Specs returns a copy of the spec file (we don't want external use to modify Feature.specs)
 func (f *Feature) Specs() *viper.Viper {
 	roSpecs := *f.specs
 	return &roSpecs
 }
 
+// FeatureEndpoint is a network service provided by a feature, that can be reached through a tunnel
+type FeatureEndpoint struct {
+	Name   string // name of the endpoint
+	Target string // kind of host running the service: "gateway", "master" or "node" (for clusters), or "host"
+	Port   int    // port of the service on the host
+}
+
+// Endpoints returns the endpoints declared in the section 'feature.tunnels' of the specification file
+func (f *Feature) Endpoints() ([]FeatureEndpoint, error) {
+	anon := f.specs.Get("feature.tunnels")
+	if anon == nil {
+		return nil, nil
+	}
+	items, ok := anon.([]interface{})
+	if !ok {
+		return nil, scerr.SyntaxError(fmt.Sprintf("syntax error in feature '%s' specification file (%s): 'feature.tunnels' must be a list", f.DisplayName(), f.DisplayFilename()))
+	}
+
+	var list []FeatureEndpoint
+	for i, item := range items {
+		entry, ok := item.(map[interface{}]interface{})
+		if !ok {
+			return nil, scerr.SyntaxError(fmt.Sprintf("syntax error in feature '%s' specification file (%s): entry #%d of 'feature.tunnels' is invalid", f.DisplayName(), f.DisplayFilename(), i+1))
+		}
+		endpoint := FeatureEndpoint{Target: "host"}
+		if name, ok := entry["name"].(string); ok {
+			endpoint.Name = name
+		}
+		if target, ok := entry["target"].(string); ok {
+			endpoint.Target = strings.ToLower(target)
+		}
+		if port, ok := entry["port"].(int); ok {
+			endpoint.Port = port
+		}
+		if endpoint.Name == "" || endpoint.Port <= 0 || endpoint.Port > 65535 {
+			return nil, scerr.SyntaxError(fmt.Sprintf("syntax error in feature '%s' specification file (%s): entry #%d of 'feature.tunnels' needs a 'name' and a valid 'port'", f.DisplayName(), f.DisplayFilename(), i+1))
+		}
+		switch endpoint.Target {
+		case "host", "gateway", "master", "node":
+		default:
+			return nil, scerr.SyntaxError(fmt.Sprintf("syntax error in feature '%s' specification file (%s): invalid target '%s' for tunnel '%s'", f.DisplayName(), f.DisplayFilename(), endpoint.Target, endpoint.Name))
+		}
+		list = append(list, endpoint)
+	}
+	return list, nil
+}
+
 // Applyable tells if the feature is installable on the target
 func (f *Feature) Applyable(t Target) bool {
 	methods := t.Methods()
@@ -330,8 +539,8 @@ func (f *Feature) Check(t Target, v Variables, s Settings) (_ Results, err error
 		return nil, err
 	}
 
-	// Checks required parameters have value
-	err = checkParameters(f, myV)
+	// Checks parameters against their schema and sets default values
+	err = validateParameters(f, myV)
 	if err != nil {
 		return nil, err
 	}
@@ -387,8 +596,8 @@ func (f *Feature) Add(t Target, v Variables, s Settings) (_ Results, err error)
 		return nil, err
 	}
 
-	// Checks required parameters have value
-	err = checkParameters(f, myV)
+	// Checks parameters against their schema and sets default values
+	err = validateParameters(f, myV)
 	if err != nil {
 		return nil, err
 	}
@@ -446,6 +655,11 @@ func (f *Feature) Remove(t Target, v Variables, s Settings) (_ Results, err erro
 		return nil, fmt.Errorf("failed to find a way to uninstall '%s'", f.DisplayName())
 	}
 
+	err = f.removeDependents(t, v, s)
+	if err != nil {
+		return nil, err
+	}
+
 	defer temporal.NewStopwatch().OnExitLogInfo(
 		fmt.Sprintf("Starting removal of feature '%s' from %s '%s'", f.DisplayName(), t.Type(), t.Name()),
 		fmt.Sprintf("Ending removal of feature '%s' from %s '%s'", f.DisplayName(), t.Type(), t.Name()),
@@ -463,8 +677,8 @@ func (f *Feature) Remove(t Target, v Variables, s Settings) (_ Results, err erro
 		return nil, err
 	}
 
-	// Checks required parameters have value
-	err = checkParameters(f, myV)
+	// Checks parameters against their schema and sets default values
+	err = validateParameters(f, myV)
 	if err != nil {
 		return nil, err
 	}
@@ -474,46 +688,87 @@ func (f *Feature) Remove(t Target, v Variables, s Settings) (_ Results, err erro
 	return results, err
 }
 
-// installRequirements walks through requirements and installs them if needed
-func (f *Feature) installRequirements(t Target, v Variables, s Settings) error {
-	yamlKey := "feature.requirements.features"
-	if f.specs.IsSet(yamlKey) {
-		{
-			hostInstance, clusterInstance, nodeInstance := determineContext(t)
-			msgHead := fmt.Sprintf("Checking requirements of feature '%s'", f.DisplayName())
-			var msgTail string
-			if hostInstance != nil {
-				msgTail = fmt.Sprintf("on host '%s'", hostInstance.host.Name)
-			}
-			if nodeInstance != nil {
-				msgTail = fmt.Sprintf("on cluster node '%s'", nodeInstance.host.Name)
-			}
-			if clusterInstance != nil {
-				msgTail = fmt.Sprintf("on cluster '%s'", clusterInstance.cluster.GetIdentity(f.task).Name)
+// Upgrade upgrades the feature on the target, using the action 'upgrade' of the specification file
+func (f *Feature) Upgrade(t Target, v Variables, s Settings) (_ Results, err error) {
+	if f == nil {
+		return nil, scerr.InvalidInstanceError()
+	}
+
+	tracer := concurrency.NewTracer(f.task, fmt.Sprintf("(): '%s' on %s '%s'", f.DisplayName(), t.Type(), t.Name()), true).WithStopwatch().GoingIn()
+	defer tracer.OnExitTrace()()
+	defer scerr.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	var (
+		results   Results
+		installer Installer
+	)
+	methods := t.Methods()
+	for _, meth := range methods {
+		if f.specs.IsSet(fmt.Sprintf("feature.install.%s.upgrade", strings.ToLower(meth.String()))) {
+			installer = f.installerOfMethod(meth)
+			if installer != nil {
+				break
 			}
-			logrus.Debugf("%s %s...\n", msgHead, msgTail)
 		}
-		for _, requirement := range f.specs.GetStringSlice(yamlKey) {
-			needed, err := NewFeature(f.task, requirement)
-			if err != nil {
-				return fmt.Errorf("failed to find required feature '%s': %s", requirement, err.Error())
-			}
-			results, err := needed.Check(t, v, s)
-			if err != nil {
-				return fmt.Errorf("failed to check required feature '%s' for feature '%s': %s", requirement, f.DisplayName(), err.Error())
-			}
-			if !results.Successful() {
-				results, err := needed.Add(t, v, s)
-				if err != nil {
-					return fmt.Errorf("failed to install required feature '%s': %s", requirement, err.Error())
-				}
-				if !results.Successful() {
-					return fmt.Errorf("failed to install required feature '%s':\n%s", requirement, results.AllErrorMessages())
-				}
-			}
+	}
+	if installer == nil {
+		return nil, scerr.NotAvailableError(fmt.Sprintf("feature '%s' doesn't support upgrade on %s '%s'", f.DisplayName(), t.Type(), t.Name()))
+	}
+
+	defer temporal.NewStopwatch().OnExitLogInfo(
+		fmt.Sprintf("Starting upgrade of feature '%s' on %s '%s'", f.DisplayName(), t.Type(), t.Name()),
+		fmt.Sprintf("Ending upgrade of feature '%s' on %s '%s'", f.DisplayName(), t.Type(), t.Name()),
+	)()
+
+	// 'v' may be updated by parallel tasks, so use copy of it
+	myV := make(Variables)
+	for key, value := range v {
+		myV[key] = value
+	}
+
+	// Inits implicit parameters
+	err = f.setImplicitParameters(t, myV)
+	if err != nil {
+		return nil, err
+	}
+
+	// Checks parameters against their schema and sets default values
+	err = validateParameters(f, myV)
+	if err != nil {
+		return nil, err
+	}
+
+	return installer.Upgrade(f, t, myV, s)
+}
+
+// installRequirements resolves the requirements of the feature and installs them if needed; requirements
+// independent of each other are installed in parallel
+func (f *Feature) installRequirements(t Target, v Variables, s Settings) error {
+	{
+		hostInstance, clusterInstance, nodeInstance := determineContext(t)
+		msgHead := fmt.Sprintf("Checking requirements of feature '%s'", f.DisplayName())
+		var msgTail string
+		if hostInstance != nil {
+			msgTail = fmt.Sprintf("on host '%s'", hostInstance.host.Name)
 		}
+		if nodeInstance != nil {
+			msgTail = fmt.Sprintf("on cluster node '%s'", nodeInstance.host.Name)
+		}
+		if clusterInstance != nil {
+			msgTail = fmt.Sprintf("on cluster '%s'", clusterInstance.cluster.GetIdentity(f.task).Name)
+		}
+		logrus.Debugf("%s %s...\n", msgHead, msgTail)
 	}
-	return nil
+
+	plan, err := planFor(f, t)
+	if err != nil {
+		return err
+	}
+	err = plan.CheckConflicts(t.Installed())
+	if err != nil {
+		return err
+	}
+	return plan.Add(t, v, s)
 }
 
 // setImplicitParameters configures parameters that are implicitly defined, based on target
@@ -0,0 +1,205 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package install
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	pb "github.com/CS-SI/SafeScale/lib"
+	"github.com/CS-SI/SafeScale/lib/client"
+	"github.com/CS-SI/SafeScale/lib/server/iaas/resources/enums/hostproperty"
+	propsv1 "github.com/CS-SI/SafeScale/lib/server/iaas/resources/properties/v1"
+	"github.com/CS-SI/SafeScale/lib/server/metadata"
+	"github.com/CS-SI/SafeScale/lib/utils"
+	"github.com/CS-SI/SafeScale/lib/utils/cli/enums/outputs"
+	"github.com/CS-SI/SafeScale/lib/utils/data"
+	"github.com/CS-SI/SafeScale/lib/utils/scerr"
+	"github.com/CS-SI/SafeScale/lib/utils/temporal"
+)
+
+const (
+	// featureLogFolder is the folder on the hosts where the logs of the steps of the feature actions are kept
+	featureLogFolder = utils.LogFolder + "/features"
+	// featureLogRunFormat is the format of the identifier of an execution of an action
+	featureLogRunFormat = "20060102-150405.000"
+	// maxFeatureLogEntries is the maximum number of step logs kept for a host; the oldest ones are removed first
+	maxFeatureLogEntries = 200
+)
+
+// FeatureLogFilter selects step logs
+type FeatureLogFilter struct {
+	// Feature is the name of the feature (mandatory)
+	Feature string
+	// Action, if set, selects the logs of this action only (check, add, remove, upgrade)
+	Action string
+	// Step, if set, selects the logs of this step only
+	Step string
+	// AllRuns tells to select the logs of all the executions kept, not only the ones of the most recent one
+	AllRuns bool
+}
+
+// featureLogPath returns the path on the host of the kept log of a step
+func featureLogPath(feature, run, action, step string) string {
+	return fmt.Sprintf("%s/%s/%s.%s_%s.log", featureLogFolder, feature, run, action, step)
+}
+
+// recordFeatureStepLog adds the entry to the index of step logs of the host, in its metadata; when there are too
+// many entries, the oldest ones are removed (with their log file)
+func recordFeatureStepLog(host *pb.Host, entry *propsv1.HostFeatureStepLog) error {
+	if host == nil {
+		return scerr.InvalidParameterError("host", "cannot be nil")
+	}
+	if entry == nil {
+		return scerr.InvalidParameterError("entry", "cannot be nil")
+	}
+	svc, err := currentService()
+	if err != nil {
+		return err
+	}
+	mh, err := metadata.LoadHost(svc, host.Id)
+	if err != nil {
+		return err
+	}
+	mh.Acquire()
+	defer mh.Release()
+
+	rh, err := mh.Get()
+	if err != nil {
+		return err
+	}
+	var dropped []string
+	err = rh.Properties.LockForWrite(hostproperty.FeatureLogsV1).ThenUse(func(clonable data.Clonable) error {
+		logsV1 := clonable.(*propsv1.HostFeatureLogs)
+		logsV1.Entries = append(logsV1.Entries, entry)
+		if over := len(logsV1.Entries) - maxFeatureLogEntries; over > 0 {
+			for _, e := range logsV1.Entries[:over] {
+				if e.LogFile != "" {
+					dropped = append(dropped, e.LogFile)
+				}
+			}
+			logsV1.Entries = logsV1.Entries[over:]
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	err = mh.Write()
+	if err != nil {
+		return err
+	}
+
+	if len(dropped) > 0 {
+		cmd := "sudo rm -f " + strings.Join(dropped, " ")
+		_, _, _, err = client.New().SSH.Run(host.Name, cmd, outputs.COLLECT, temporal.GetConnectionTimeout(), temporal.GetExecutionTimeout())
+		if err != nil {
+			logrus.Warnf("failed to remove old feature logs on host '%s': %v", host.Name, err)
+		}
+	}
+	return nil
+}
+
+// HostFeatureLogs returns the step logs of the host selected by the filter, from the oldest to the most recent
+func HostFeatureLogs(host *pb.Host, filter FeatureLogFilter) ([]*propsv1.HostFeatureStepLog, error) {
+	if host == nil {
+		return nil, scerr.InvalidParameterError("host", "cannot be nil")
+	}
+	if filter.Feature == "" {
+		return nil, scerr.InvalidParameterError("filter.Feature", "cannot be empty string")
+	}
+	svc, err := currentService()
+	if err != nil {
+		return nil, err
+	}
+	mh, err := metadata.LoadHost(svc, host.Id)
+	if err != nil {
+		return nil, err
+	}
+	rh, err := mh.Get()
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*propsv1.HostFeatureStepLog
+	err = rh.Properties.LockForRead(hostproperty.FeatureLogsV1).ThenUse(func(clonable data.Clonable) error {
+		entries = clonable.(*propsv1.HostFeatureLogs).Clone().(*propsv1.HostFeatureLogs).Entries
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return filterFeatureLogs(entries, filter), nil
+}
+
+// filterFeatureLogs returns the entries selected by the filter
+func filterFeatureLogs(entries []*propsv1.HostFeatureStepLog, filter FeatureLogFilter) []*propsv1.HostFeatureStepLog {
+	var (
+		selected []*propsv1.HostFeatureStepLog
+		lastRun  string
+	)
+	for _, e := range entries {
+		if e.Feature != filter.Feature {
+			continue
+		}
+		if filter.Action != "" && !strings.EqualFold(e.Action, filter.Action) {
+			continue
+		}
+		if filter.Step != "" && e.Step != filter.Step {
+			continue
+		}
+		selected = append(selected, e)
+		if e.Run > lastRun {
+			lastRun = e.Run
+		}
+	}
+	if filter.AllRuns {
+		return selected
+	}
+
+	var out []*propsv1.HostFeatureStepLog
+	for _, e := range selected {
+		if e.Run == lastRun {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// ReadFeatureLog returns the content of the log file of the step log, read on the host
+func ReadFeatureLog(host *pb.Host, entry *propsv1.HostFeatureStepLog) (string, error) {
+	if host == nil {
+		return "", scerr.InvalidParameterError("host", "cannot be nil")
+	}
+	if entry == nil {
+		return "", scerr.InvalidParameterError("entry", "cannot be nil")
+	}
+	if entry.LogFile == "" {
+		return "", scerr.NotFoundError(fmt.Sprintf("no log file kept for step '%s' of feature '%s' on host '%s'", entry.Step, entry.Feature, host.Name))
+	}
+
+	retcode, stdout, stderr, err := client.New().SSH.Run(host.Name, "sudo cat "+entry.LogFile, outputs.COLLECT, temporal.GetConnectionTimeout(), temporal.GetExecutionTimeout())
+	if err != nil {
+		return "", err
+	}
+	if retcode != 0 {
+		return "", scerr.NotFoundError(fmt.Sprintf("failed to read '%s' on host '%s': %s", entry.LogFile, host.Name, strings.TrimSpace(stderr)))
+	}
+	return stdout, nil
+}
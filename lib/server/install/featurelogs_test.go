@@ -0,0 +1,34 @@
+package install
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	propsv1 "github.com/CS-SI/SafeScale/lib/server/iaas/resources/properties/v1"
+)
+
+func TestFilterFeatureLogs(t *testing.T) {
+	entries := []*propsv1.HostFeatureStepLog{
+		{Feature: "docker", Action: "check", Step: "docker", Run: "20200601-101010.000"},
+		{Feature: "docker", Action: "add", Step: "docker-ce", Run: "20200601-101011.000", ExitCode: 1},
+		{Feature: "kubernetes", Action: "add", Step: "init", Run: "20200601-101500.000"},
+		{Feature: "docker", Action: "add", Step: "docker-ce", Run: "20200602-080000.000"},
+		{Feature: "docker", Action: "add", Step: "docker-compose", Run: "20200602-080000.000"},
+	}
+
+	// Most recent execution only by default
+	logs := filterFeatureLogs(entries, FeatureLogFilter{Feature: "docker"})
+	assert.Equal(t, 2, len(logs))
+	assert.Equal(t, "docker-ce", logs[0].Step)
+	assert.Equal(t, "docker-compose", logs[1].Step)
+
+	logs = filterFeatureLogs(entries, FeatureLogFilter{Feature: "docker", Step: "docker-ce", AllRuns: true})
+	assert.Equal(t, 2, len(logs))
+	assert.Equal(t, 1, logs[0].ExitCode)
+
+	logs = filterFeatureLogs(entries, FeatureLogFilter{Feature: "docker", Action: "Check"})
+	assert.Equal(t, 1, len(logs))
+
+	assert.Empty(t, filterFeatureLogs(entries, FeatureLogFilter{Feature: "helm"}))
+}
@@ -0,0 +1,326 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package install
+
+import (
+	"fmt"
+	"strings"
+
+	pb "github.com/CS-SI/SafeScale/lib"
+	"github.com/CS-SI/SafeScale/lib/client"
+	"github.com/CS-SI/SafeScale/lib/server/install/enums/action"
+	"github.com/CS-SI/SafeScale/lib/utils/cli/enums/outputs"
+	"github.com/CS-SI/SafeScale/lib/utils/concurrency"
+	"github.com/CS-SI/SafeScale/lib/utils/scerr"
+	"github.com/CS-SI/SafeScale/lib/utils/temporal"
+)
+
+const (
+	yamlTestsKey = "feature.tests"
+
+	// AssertAfterAdd designates the assertions to evaluate once the feature is added
+	AssertAfterAdd = "add"
+	// AssertAfterRemove designates the assertions to evaluate once the feature is removed
+	AssertAfterRemove = "remove"
+)
+
+// FeatureTests describes how to test a feature on throwaway hosts or clusters, as declared in the section
+// 'feature.tests' of its specification file
+type FeatureTests struct {
+	// Target is the kind of target to create, "host" or "cluster"
+	Target string `json:"target"`
+	// Flavor is the flavor of the cluster to create (if Target is "cluster")
+	Flavor string `json:"flavor,omitempty"`
+	// OS lists the images to test the feature on
+	OS []string `json:"os"`
+	// Sizing is the sizing of the hosts to create, in format "<component><operator><value>[,...]"
+	Sizing string `json:"sizing,omitempty"`
+	// Parameters contains the values of the parameters of the feature, in format "<name>=<value>"
+	Parameters []string `json:"parameters,omitempty"`
+	// Assertions are evaluated once the feature is added or removed
+	Assertions []Assertion `json:"assertions,omitempty"`
+}
+
+// Assertion is a command run on the hosts of the target, whose exit code and output are checked
+type Assertion struct {
+	Name    string            `json:"name"`
+	Run     string            `json:"run"`
+	Targets map[string]string `json:"targets,omitempty"`
+	// After is AssertAfterAdd (default) or AssertAfterRemove
+	After string `json:"after"`
+	// ExitCode is the exit code expected (default: 0)
+	ExitCode int `json:"exitcode"`
+	// Contains, if not empty, must be found in the output of the command
+	Contains string `json:"contains,omitempty"`
+}
+
+// AssertionResult is the result of an assertion on a host
+type AssertionResult struct {
+	Name    string `json:"name"`
+	Host    string `json:"host"`
+	After   string `json:"after"`
+	Success bool   `json:"success"`
+	Message string `json:"message,omitempty"`
+}
+
+// Tests returns the tests declared in the specification file of the feature; if the section 'feature.tests'
+// is missing, returns defaults deduced from 'feature.suitableFor'
+func (f *Feature) Tests() (*FeatureTests, error) {
+	if f == nil {
+		return nil, scerr.InvalidInstanceError()
+	}
+
+	tests := &FeatureTests{}
+	specs := f.specs.GetStringMap(yamlTestsKey)
+	if anon, ok := specs["target"]; ok {
+		tests.Target = strings.ToLower(fmt.Sprintf("%v", anon))
+	}
+	if anon, ok := specs["flavor"]; ok {
+		tests.Flavor = strings.ToLower(fmt.Sprintf("%v", anon))
+	}
+	if anon, ok := specs["sizing"]; ok {
+		tests.Sizing = fmt.Sprintf("%v", anon)
+	}
+	tests.OS = testStrings(specs["os"])
+	tests.Parameters = testStrings(specs["parameters"])
+
+	if tests.Target == "" {
+		tests.Target = "host"
+		value := strings.ToLower(f.specs.GetString("feature.suitableFor.host"))
+		if value != "ok" && value != "yes" && value != "true" && value != "1" {
+			tests.Target = "cluster"
+		}
+	}
+	switch tests.Target {
+	case "host":
+	case "cluster":
+		if tests.Flavor == "" {
+			tests.Flavor = "boh"
+			for _, k := range strings.Split(strings.ToLower(f.specs.GetString("feature.suitableFor.cluster")), ",") {
+				k = strings.TrimSpace(k)
+				if k != "" && k != "all" && k != "no" && k != "false" {
+					tests.Flavor = k
+					break
+				}
+			}
+		}
+	default:
+		return nil, scerr.SyntaxError(fmt.Sprintf("invalid value '%s' for key '%s.target' in specification file of feature '%s': must be 'host' or 'cluster'", tests.Target, yamlTestsKey, f.DisplayName()))
+	}
+	if len(tests.OS) == 0 {
+		tests.OS = []string{"Ubuntu 18.04"}
+	}
+
+	if anon, ok := specs["assertions"]; ok {
+		list, ok := anon.([]interface{})
+		if !ok {
+			return nil, scerr.SyntaxError(fmt.Sprintf("key '%s.assertions' in specification file of feature '%s' must be a list", yamlTestsKey, f.DisplayName()))
+		}
+		for i, item := range list {
+			a, err := parseAssertion(item)
+			if err != nil {
+				return nil, scerr.SyntaxError(fmt.Sprintf("invalid assertion #%d in specification file of feature '%s': %s", i+1, f.DisplayName(), err.Error()))
+			}
+			tests.Assertions = append(tests.Assertions, a)
+		}
+	}
+	return tests, nil
+}
+
+// parseAssertion converts an item of the list 'assertions' read from the specification file
+func parseAssertion(item interface{}) (Assertion, error) {
+	a := Assertion{After: AssertAfterAdd}
+	m, ok := item.(map[interface{}]interface{})
+	if !ok {
+		return a, fmt.Errorf("must be a map")
+	}
+	for k, v := range m {
+		key := strings.ToLower(fmt.Sprintf("%v", k))
+		switch key {
+		case "name":
+			a.Name = fmt.Sprintf("%v", v)
+		case "run":
+			a.Run = fmt.Sprintf("%v", v)
+		case "after":
+			a.After = strings.ToLower(fmt.Sprintf("%v", v))
+		case "contains":
+			a.Contains = fmt.Sprintf("%v", v)
+		case "exitcode":
+			code, ok := v.(int)
+			if !ok {
+				return a, fmt.Errorf("'exitcode' must be an integer")
+			}
+			a.ExitCode = code
+		case "targets":
+			targets, ok := v.(map[interface{}]interface{})
+			if !ok {
+				return a, fmt.Errorf("'targets' must be a map")
+			}
+			a.Targets = map[string]string{}
+			for tk, tv := range targets {
+				switch tv := tv.(type) {
+				case bool:
+					if tv {
+						a.Targets[fmt.Sprintf("%v", tk)] = "yes"
+					} else {
+						a.Targets[fmt.Sprintf("%v", tk)] = "no"
+					}
+				default:
+					a.Targets[fmt.Sprintf("%v", tk)] = fmt.Sprintf("%v", tv)
+				}
+			}
+		default:
+			return a, fmt.Errorf("unknown key '%s'", key)
+		}
+	}
+	if strings.TrimSpace(a.Run) == "" {
+		return a, fmt.Errorf("missing 'run'")
+	}
+	if a.After != AssertAfterAdd && a.After != AssertAfterRemove {
+		return a, fmt.Errorf("'after' must be '%s' or '%s'", AssertAfterAdd, AssertAfterRemove)
+	}
+	if a.Name == "" {
+		a.Name = strings.Split(strings.TrimSpace(a.Run), "\n")[0]
+	}
+	return a, nil
+}
+
+// testStrings converts a list (or a comma-separated string) read from the specification file
+func testStrings(anon interface{}) []string {
+	var out []string
+	switch anon := anon.(type) {
+	case string:
+		for _, s := range strings.Split(anon, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				out = append(out, s)
+			}
+		}
+	case []interface{}:
+		for _, i := range anon {
+			if s := strings.TrimSpace(fmt.Sprintf("%v", i)); s != "" {
+				out = append(out, s)
+			}
+		}
+	}
+	return out
+}
+
+// evaluate checks the exit code and the output of the command of the assertion
+func (a Assertion) evaluate(retcode int, stdout string) error {
+	if retcode != a.ExitCode {
+		return fmt.Errorf("exit code %d, %d expected", retcode, a.ExitCode)
+	}
+	if a.Contains != "" && !strings.Contains(stdout, a.Contains) {
+		return fmt.Errorf("output doesn't contain '%s'", a.Contains)
+	}
+	return nil
+}
+
+// Assert evaluates on the hosts of the target the assertions of the tests of the feature to evaluate after
+// action 'after' (AssertAfterAdd or AssertAfterRemove)
+func (f *Feature) Assert(t Target, v Variables, after string) (_ []AssertionResult, err error) {
+	if f == nil {
+		return nil, scerr.InvalidInstanceError()
+	}
+	if t == nil {
+		return nil, scerr.InvalidParameterError("t", "cannot be nil")
+	}
+
+	tracer := concurrency.NewTracer(f.task, fmt.Sprintf("(): '%s' on %s '%s' after %s", f.DisplayName(), t.Type(), t.Name(), after), true).WithStopwatch().GoingIn()
+	defer tracer.OnExitTrace()()
+	defer scerr.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	tests, err := f.Tests()
+	if err != nil {
+		return nil, err
+	}
+
+	myV := v.Clone()
+	err = f.setImplicitParameters(t, myV)
+	if err != nil {
+		return nil, err
+	}
+	err = validateParameters(f, myV)
+	if err != nil {
+		return nil, err
+	}
+	secrets := f.secretValues(myV)
+
+	// A worker is used only to identify the hosts concerned by the assertions
+	w := &worker{feature: f, target: t, action: action.Check}
+	hT, cT, nT := determineContext(t)
+	if cT != nil {
+		w.cluster = cT.cluster
+	}
+	if hT != nil {
+		w.host = hT.host
+	}
+	if nT != nil {
+		w.host = nT.host
+		w.node = true
+	}
+
+	var results []AssertionResult
+	for _, a := range tests.Assertions {
+		if a.After != after {
+			continue
+		}
+		targets := stepTargets{}
+		for k, v := range a.Targets {
+			targets[k] = v
+		}
+		if len(targets) == 0 {
+			if w.cluster != nil {
+				targets[targetMasters] = "all"
+			} else {
+				targets[targetHosts] = "yes"
+			}
+		}
+		hosts, err := w.identifyHosts(targets)
+		if err != nil {
+			return results, fmt.Errorf("failed to identify hosts of assertion '%s': %s", a.Name, err.Error())
+		}
+		for _, h := range hosts {
+			result := AssertionResult{Name: a.Name, Host: h.Name, After: after}
+			err := a.runOnHost(h, myV)
+			if err != nil {
+				result.Message = maskSecrets(err.Error(), secrets)
+			} else {
+				result.Success = true
+			}
+			results = append(results, result)
+		}
+	}
+	return results, nil
+}
+
+// runOnHost runs the command of the assertion on the host and evaluates the result
+func (a Assertion) runOnHost(host *pb.Host, v Variables) error {
+	vars := v.Clone()
+	vars["Hostname"] = host.Name
+	vars["HostIP"] = host.PrivateIp
+	script, err := replaceVariablesInString(a.Run, vars)
+	if err != nil {
+		return err
+	}
+	command := fmt.Sprintf("sudo bash <<'SAFESCALE_ASSERTION'\n%s\nSAFESCALE_ASSERTION\n", strings.TrimRight(script, "\n"))
+	retcode, stdout, _, err := client.New().SSH.Run(host.Name, command, outputs.COLLECT, temporal.GetConnectionTimeout(), temporal.GetExecutionTimeout())
+	if err != nil {
+		return err
+	}
+	return a.evaluate(retcode, stdout)
+}
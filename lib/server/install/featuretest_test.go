@@ -0,0 +1,63 @@
+package install
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testTestsSpecs = `
+feature:
+    suitableFor:
+        host: no
+        cluster: k8s,swarm
+    tests:
+        os: ubuntu18.04, centos7
+        parameters:
+            - Port=9200
+        assertions:
+            - name: listening
+              run: ss -ltn | grep -q ':{{ .Port }}'
+              targets:
+                  masters: all
+                  nodes: yes
+            - run: test ! -d /etc/elasticsearch
+              after: remove
+              exitcode: 0
+            - name: version
+              run: curl -s http://localhost:9200/
+              contains: '"number"'
+`
+
+func TestFeatureTests(t *testing.T) {
+	tests, err := newTestFeature(t, testTestsSpecs).Tests()
+	require.NoError(t, err)
+	assert.Equal(t, "cluster", tests.Target)
+	assert.Equal(t, "k8s", tests.Flavor)
+	assert.Equal(t, []string{"ubuntu18.04", "centos7"}, tests.OS)
+	assert.Equal(t, []string{"Port=9200"}, tests.Parameters)
+	require.Len(t, tests.Assertions, 3)
+	assert.Equal(t, map[string]string{"masters": "all", "nodes": "yes"}, tests.Assertions[0].Targets)
+	assert.Equal(t, AssertAfterAdd, tests.Assertions[0].After)
+	assert.Equal(t, AssertAfterRemove, tests.Assertions[1].After)
+	assert.Equal(t, "test ! -d /etc/elasticsearch", tests.Assertions[1].Name)
+
+	tests, err = newTestFeature(t, "feature:\n    suitableFor:\n        host: yes\n").Tests()
+	require.NoError(t, err)
+	assert.Equal(t, "host", tests.Target)
+	assert.Equal(t, []string{"Ubuntu 18.04"}, tests.OS)
+
+	_, err = newTestFeature(t, "feature:\n    tests:\n        assertions:\n            - after: add\n").Tests()
+	assert.Error(t, err)
+	_, err = newTestFeature(t, "feature:\n    tests:\n        target: node\n").Tests()
+	assert.Error(t, err)
+}
+
+func TestAssertion_Evaluate(t *testing.T) {
+	a := Assertion{Contains: `"number"`}
+	assert.NoError(t, a.evaluate(0, `{"version": {"number": "7.6.0"}}`))
+	assert.Error(t, a.evaluate(0, "{}"))
+	assert.Error(t, a.evaluate(7, `"number"`))
+	assert.NoError(t, Assertion{ExitCode: 1}.evaluate(1, ""))
+}
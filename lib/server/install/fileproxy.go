@@ -0,0 +1,688 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package install
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	safescale "github.com/CS-SI/SafeScale/lib/client"
+	"github.com/CS-SI/SafeScale/lib/server/iaas"
+	"github.com/CS-SI/SafeScale/lib/server/iaas/resources"
+	propsv1 "github.com/CS-SI/SafeScale/lib/server/iaas/resources/properties/v1"
+	"github.com/CS-SI/SafeScale/lib/utils/cli/enums/outputs"
+	"github.com/CS-SI/SafeScale/lib/utils/scerr"
+	"github.com/CS-SI/SafeScale/lib/utils/temporal"
+)
+
+const (
+	// fileProxyCertFolder is the folder on the gateway containing the certificate used by the reverse proxies
+	// configured by file (self-signed, generated if missing)
+	fileProxyCertFolder = "/opt/safescale/etc/reverseproxy"
+	// fileProxyHeader is the header of the generated configuration files
+	fileProxyHeader = "Generated by SafeScale from the proxy rules published by features, do not edit"
+	// fileProxyOverride is the name of the systemd drop-in installed for the service of the reverse proxy, if needed
+	fileProxyOverride = "safescale.conf"
+)
+
+// fileProxyKind describes how a kind of reverse proxy is configured by file
+type fileProxyKind struct {
+	// service is the name of the systemd service of the reverse proxy
+	service string
+	// file is the path of the configuration file generated
+	file string
+	// check is the command validating the configuration, if any
+	check string
+	// reload is the command applying the configuration, if any (Traefik watches its file)
+	reload string
+	// override is the content of the systemd drop-in of the service, if any, making it load the file; the drop-in
+	// is installed once the file is validated, and the service is then restarted instead of reloaded
+	override string
+	// render generates the content of the configuration file
+	render func(*proxyConfig) (string, error)
+}
+
+var fileProxyKinds = map[string]fileProxyKind{
+	ReverseProxyTraefik: {
+		service: "traefik",
+		file:    "/etc/traefik/dynamic/safescale.yml",
+		render:  renderTraefikConfig,
+	},
+	ReverseProxyHAProxy: {
+		service: "haproxy",
+		file:    "/etc/haproxy/conf.d/safescale.cfg",
+		check:   "haproxy -c -q -f /etc/haproxy/haproxy.cfg -f /etc/haproxy/conf.d",
+		reload:  "systemctl reload haproxy",
+		// The main configuration file, which may be managed by others, is left untouched; the folder conf.d is
+		// loaded after it
+		override: strings.Join([]string{
+			"[Service]",
+			"ExecStartPre=",
+			"ExecStartPre=/usr/sbin/haproxy -c -q -f /etc/haproxy/haproxy.cfg -f /etc/haproxy/conf.d",
+			"ExecStart=",
+			"ExecStart=/usr/sbin/haproxy -Ws -f /etc/haproxy/haproxy.cfg -f /etc/haproxy/conf.d -p /run/haproxy.pid",
+			"ExecReload=",
+			"ExecReload=/usr/sbin/haproxy -c -q -f /etc/haproxy/haproxy.cfg -f /etc/haproxy/conf.d",
+			"ExecReload=/bin/kill -USR2 $MAINPID",
+		}, "\n"),
+		render: renderHAProxyConfig,
+	},
+	ReverseProxyNginx: {
+		service: "nginx",
+		file:    "/etc/nginx/conf.d/safescale.conf",
+		check:   "nginx -t",
+		reload:  "systemctl reload nginx",
+		render:  renderNginxConfig,
+	},
+}
+
+// fileProxyController controls a reverse proxy configured by a file on the gateway (Traefik, HAProxy or Nginx).
+// The file is generated from all the rules recorded for the gateway in network metadata, each time a rule is
+// applied or deleted.
+// satisfies interface ReverseProxyController
+type fileProxyController struct {
+	proxyGateway
+	kind string
+}
+
+// newFileProxyController creates a fileProxyController; if 'checkPresence' is false, doesn't check (on the gateway)
+// that the reverse proxy is running
+func newFileProxyController(svc iaas.Service, network *resources.Network, kind string, addressPrimaryGateway bool, checkPresence bool) (*fileProxyController, error) {
+	desc, ok := fileProxyKinds[kind]
+	if !ok {
+		return nil, scerr.InvalidParameterError("kind", fmt.Sprintf("'%s' isn't a kind of reverse proxy configured by file", kind))
+	}
+	gw, err := loadProxyGateway(svc, network, addressPrimaryGateway)
+	if err != nil {
+		return nil, err
+	}
+
+	if checkPresence {
+		key := kind + ":" + gw.gateway.Name
+		if anon, ok := kongProxyCheckedCache.Get(key); !ok || !anon.(bool) {
+			cmd := fmt.Sprintf("systemctl is-active --quiet %s", desc.service)
+			retcode, _, _, err := safescale.New().SSH.Run(gw.gateway.Name, cmd, outputs.COLLECT, temporal.GetConnectionTimeout(), temporal.GetExecutionTimeout())
+			if err != nil {
+				return nil, scerr.NotAvailableError(fmt.Sprintf("failed to check if reverse proxy '%s' is running on gateway '%s': %s", kind, gw.gateway.Name, err.Error()))
+			}
+			if retcode != 0 {
+				return nil, scerr.NotFoundError(fmt.Sprintf("reverse proxy '%s' is not running on gateway '%s'", kind, gw.gateway.Name))
+			}
+			_ = kongProxyCheckedCache.ForceSet(key, true)
+		}
+	}
+
+	return &fileProxyController{proxyGateway: *gw, kind: kind}, nil
+}
+
+// Kind returns the kind of reverse proxy controlled
+func (c *fileProxyController) Kind() string {
+	return c.kind
+}
+
+// Render returns the type, the name and the content of the rule as they would be recorded by Apply
+func (c *fileProxyController) Render(rule map[interface{}]interface{}, values *Variables) (string, string, string, error) {
+	ruleType, ruleName, content, err := c.realizeRule(rule, values)
+	if err != nil {
+		return ruleType, ruleName, content, err
+	}
+	// Rules refer to the services by name
+	(*values)[ruleName] = ruleName
+	return ruleType, ruleName, content, nil
+}
+
+// Apply records the rule published by the feature, then regenerates the configuration of the reverse proxy
+// Returns rule name and error
+func (c *fileProxyController) Apply(feature string, rule map[interface{}]interface{}, values *Variables) (string, error) {
+	ruleType, ruleName, content, err := c.Render(rule, values)
+	if err != nil {
+		return ruleName, err
+	}
+	record := c.newProxyRule(c.kind, feature, ruleType, ruleName, content, values)
+	_, err = buildProxyConfig([]*propsv1.NetworkProxyRule{record}, false)
+	if err != nil {
+		return ruleName, err
+	}
+
+	err = recordProxyRule(c.svc, c.network.ID, record)
+	if err != nil {
+		return ruleName, fmt.Errorf("failed to record proxy rule '%s' in metadata of network '%s': %s", ruleName, c.network.Name, err.Error())
+	}
+	err = c.reconfigure()
+	if err != nil {
+		if derr := forgetProxyRule(c.svc, c.network.ID, record); derr != nil {
+			logrus.Warnf("failed to forget proxy rule '%s' after failure: %v", ruleName, derr)
+		}
+		return ruleName, fmt.Errorf("failed to apply proxy rule '%s': %s", ruleName, err.Error())
+	}
+	logrus.Debugf("successfully applied proxy rule '%s': %v", ruleName, content)
+	return ruleName, nil
+}
+
+// Delete removes the rule from network metadata, then regenerates the configuration of the reverse proxy
+func (c *fileProxyController) Delete(rule *propsv1.NetworkProxyRule) error {
+	err := forgetProxyRule(c.svc, c.network.ID, rule)
+	if err != nil {
+		return err
+	}
+	return c.reconfigure()
+}
+
+// reconfigure generates the configuration file from the rules recorded for the gateway, then applies it
+func (c *fileProxyController) reconfigure() error {
+	desc := fileProxyKinds[c.kind]
+	rules, err := gatewayProxyRules(c.svc, c.network.ID, c.gateway.Name)
+	if err != nil {
+		return err
+	}
+	config, err := buildProxyConfig(rules, true)
+	if err != nil {
+		return err
+	}
+	content, err := desc.render(config)
+	if err != nil {
+		return err
+	}
+
+	cmd := fmt.Sprintf("sudo bash <<'SAFESCALE_SCRIPT'\n%s\nSAFESCALE_SCRIPT\n", fileProxyScript(desc, content, c.gatewayPublicIP))
+	retcode, stdout, stderr, err := safescale.New().SSH.Run(c.gateway.Name, cmd, outputs.COLLECT, temporal.GetConnectionTimeout(), temporal.GetExecutionTimeout())
+	if err != nil {
+		return err
+	}
+	if retcode != 0 {
+		logrus.Debugf("configuration of reverse proxy '%s' failed on gateway '%s': retcode=%d, stdout=>>%s<<, stderr=>>%s<<", c.kind, c.gateway.Name, retcode, stdout, stderr)
+		return fmt.Errorf("configuration of reverse proxy '%s' failed on gateway '%s': retcode=%d: %s", c.kind, c.gateway.Name, retcode, strings.TrimSpace(stderr))
+	}
+	return nil
+}
+
+// fileProxyScript returns the script writing the configuration file on the gateway, validating it (the previous
+// file is restored if invalid), then applying it; the systemd drop-in of the service, if any, is installed or updated
+// before applying, in which case the service is restarted
+func fileProxyScript(desc fileProxyKind, content, publicIP string) string {
+	lines := []string{
+		fmt.Sprintf("mkdir -p %s $(dirname %s)", fileProxyCertFolder, desc.file),
+		fmt.Sprintf("if [ ! -f %s/proxy.pem ]; then", fileProxyCertFolder),
+		fmt.Sprintf("    openssl req -x509 -nodes -newkey rsa:2048 -days 3650 -subj \"/CN=%s\" -keyout %s/key.pem -out %s/cert.pem || exit 1", publicIP, fileProxyCertFolder, fileProxyCertFolder),
+		fmt.Sprintf("    cat %s/cert.pem %s/key.pem >%s/proxy.pem", fileProxyCertFolder, fileProxyCertFolder, fileProxyCertFolder),
+		fmt.Sprintf("    chmod 0600 %s/*.pem", fileProxyCertFolder),
+		"fi",
+		fmt.Sprintf("[ -f %s ] && cp -f %s %s.previous", desc.file, desc.file, desc.file),
+		fmt.Sprintf("cat >%s <<'SAFESCALE_EOF'\n%s\nSAFESCALE_EOF", desc.file, strings.TrimRight(content, "\n")),
+	}
+	if desc.check != "" {
+		lines = append(lines,
+			fmt.Sprintf("if ! %s; then", desc.check),
+			fmt.Sprintf("    if [ -f %s.previous ]; then mv -f %s.previous %s; else rm -f %s; fi", desc.file, desc.file, desc.file, desc.file),
+			"    exit 1",
+			"fi",
+		)
+	}
+	if desc.override != "" {
+		folder := fmt.Sprintf("/etc/systemd/system/%s.service.d", desc.service)
+		lines = append(lines,
+			fmt.Sprintf("mkdir -p %s", folder),
+			fmt.Sprintf("cat >%s/%s.new <<'SAFESCALE_EOF'\n%s\nSAFESCALE_EOF", folder, fileProxyOverride, desc.override),
+			fmt.Sprintf("if ! cmp -s %s/%s.new %s/%s; then", folder, fileProxyOverride, folder, fileProxyOverride),
+			fmt.Sprintf("    mv -f %s/%s.new %s/%s", folder, fileProxyOverride, folder, fileProxyOverride),
+			"    systemctl daemon-reload || exit 1",
+			fmt.Sprintf("    systemctl restart %s || exit 1", desc.service),
+		)
+		if desc.reload != "" {
+			lines = append(lines,
+				"else",
+				fmt.Sprintf("    rm -f %s/%s.new", folder, fileProxyOverride),
+				"    "+desc.reload+" || exit 1",
+			)
+		}
+		lines = append(lines, "fi")
+	} else if desc.reload != "" {
+		lines = append(lines, desc.reload+" || exit 1")
+	}
+	lines = append(lines, fmt.Sprintf("rm -f %s.previous", desc.file))
+	return strings.Join(lines, "\n")
+}
+
+// proxyConfig is the configuration of a reverse proxy, independent of its kind, built from the rules
+type proxyConfig struct {
+	Services map[string]*proxyService
+	Routes   []*proxyRoute
+}
+
+// proxyService is a destination of the reverse proxy (rules of type service, with the targets of the rules of type
+// upstream it refers to)
+type proxyService struct {
+	Name      string
+	Protocol  string
+	Servers   []string
+	Path      string
+	Whitelist []string
+}
+
+// proxyRoute tells which requests are sent to a service (rules of type route)
+type proxyRoute struct {
+	Name      string
+	Paths     []string
+	Hosts     []string
+	StripPath bool
+	Service   string
+	Whitelist []string
+}
+
+// buildProxyConfig builds the configuration from the rules; the most recent rule of a name wins, except for the
+// rules of type upstream whose targets are merged. If 'resolve' is false, the references between rules aren't
+// checked (used to validate a single rule).
+func buildProxyConfig(rules []*propsv1.NetworkProxyRule, resolve bool) (*proxyConfig, error) {
+	sorted := append([]*propsv1.NetworkProxyRule{}, rules...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].AppliedAt.Before(sorted[j].AppliedAt)
+	})
+
+	type serviceRule struct {
+		service *proxyService
+		host    string
+		port    string
+	}
+	upstreams := map[string][]string{}
+	services := map[string]serviceRule{}
+	routes := map[string]*proxyRoute{}
+	for _, r := range sorted {
+		content := map[string]interface{}{}
+		err := json.Unmarshal([]byte(r.Content), &content)
+		if err != nil {
+			return nil, fmt.Errorf("syntax error in rule '%s': %s", r.Name, err.Error())
+		}
+		switch r.Type {
+		case "upstream":
+			target, ok := content["target"].(string)
+			if !ok || target == "" {
+				return nil, fmt.Errorf("syntax error in rule '%s': missing 'target'", r.Name)
+			}
+			found := false
+			for _, t := range upstreams[r.Name] {
+				if t == target {
+					found = true
+					break
+				}
+			}
+			if !found {
+				upstreams[r.Name] = append(upstreams[r.Name], target)
+			}
+
+		case "service":
+			s := &proxyService{Name: r.Name, Protocol: "http", Whitelist: whitelistOf(content)}
+			var host, port string
+			if u, ok := content["url"].(string); ok {
+				parsed, err := url.Parse(u)
+				if err != nil {
+					return nil, fmt.Errorf("syntax error in rule '%s': invalid url '%s': %s", r.Name, u, err.Error())
+				}
+				s.Protocol = parsed.Scheme
+				host, port, s.Path = parsed.Hostname(), parsed.Port(), parsed.Path
+			} else {
+				if p, ok := content["protocol"].(string); ok {
+					s.Protocol = p
+				}
+				host, _ = content["host"].(string)
+				if p, ok := content["port"].(float64); ok {
+					port = strconv.Itoa(int(p))
+				}
+				s.Path, _ = content["path"].(string)
+			}
+			if host == "" {
+				return nil, fmt.Errorf("syntax error in rule '%s': missing 'url' or 'host'", r.Name)
+			}
+			if s.Protocol != "http" && s.Protocol != "https" {
+				return nil, fmt.Errorf("syntax error in rule '%s': protocol '%s' not supported", r.Name, s.Protocol)
+			}
+			services[r.Name] = serviceRule{service: s, host: host, port: port}
+
+		case "route":
+			rt := &proxyRoute{Name: r.Name, StripPath: true, Whitelist: whitelistOf(content)}
+			rt.Paths = stringsOf(content["paths"])
+			rt.Hosts = stringsOf(content["hosts"])
+			if strip, ok := content["strip_path"].(bool); ok {
+				rt.StripPath = strip
+			}
+			if ref, ok := content["service"].(map[string]interface{}); ok {
+				if id, ok := ref["id"].(string); ok {
+					rt.Service = id
+				} else if name, ok := ref["name"].(string); ok {
+					rt.Service = name
+				}
+			}
+			if rt.Service == "" {
+				return nil, fmt.Errorf("syntax error in rule '%s': missing 'service'", r.Name)
+			}
+			routes[r.Name] = rt
+
+		default:
+			return nil, fmt.Errorf("syntax error in rule '%s': '%s' isn't a valid type", r.Name, r.Type)
+		}
+	}
+
+	config := &proxyConfig{Services: map[string]*proxyService{}}
+	for name, sr := range services {
+		if targets, ok := upstreams[sr.host]; ok {
+			sr.service.Servers = targets
+		} else {
+			port := sr.port
+			if port == "" {
+				port = "80"
+				if sr.service.Protocol == "https" {
+					port = "443"
+				}
+			}
+			sr.service.Servers = []string{sr.host + ":" + port}
+		}
+		config.Services[name] = sr.service
+	}
+	for _, rt := range routes {
+		if _, ok := config.Services[rt.Service]; !ok && resolve {
+			return nil, fmt.Errorf("route '%s' refers to unknown service '%s'", rt.Name, rt.Service)
+		}
+		config.Routes = append(config.Routes, rt)
+	}
+	// Routes with the longest paths first, as the first route matching is used by some reverse proxies
+	sort.Slice(config.Routes, func(i, j int) bool {
+		li, lj := longestPath(config.Routes[i]), longestPath(config.Routes[j])
+		if li != lj {
+			return li > lj
+		}
+		return config.Routes[i].Name < config.Routes[j].Name
+	})
+	return config, nil
+}
+
+// whitelistOf returns the CIDRs allowed by the key 'source-control' of the content of a rule
+func whitelistOf(content map[string]interface{}) []string {
+	sc, ok := content["source-control"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	return stringsOf(sc["whitelist"])
+}
+
+// stringsOf converts a list read from JSON
+func stringsOf(anon interface{}) []string {
+	items, ok := anon.([]interface{})
+	if !ok {
+		return nil
+	}
+	var out []string
+	for _, i := range items {
+		if s, ok := i.(string); ok && s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func longestPath(rt *proxyRoute) int {
+	l := 0
+	for _, p := range rt.Paths {
+		if len(p) > l {
+			l = len(p)
+		}
+	}
+	return l
+}
+
+// whitelist returns the CIDRs allowed to use the route: the ones of the route if any, the ones of its service otherwise
+func (c *proxyConfig) whitelist(rt *proxyRoute) []string {
+	if len(rt.Whitelist) > 0 {
+		return rt.Whitelist
+	}
+	return c.Services[rt.Service].Whitelist
+}
+
+// serviceNames returns the names of the services, sorted
+func (c *proxyConfig) serviceNames() []string {
+	var names []string
+	for k := range c.Services {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+var proxyIdentifierRegexp = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// proxyIdentifier returns a name usable as identifier in the configuration files
+func proxyIdentifier(name string) string {
+	return proxyIdentifierRegexp.ReplaceAllString(name, "_")
+}
+
+// renderNginxConfig generates a file of the folder conf.d of Nginx (included in context 'http')
+func renderNginxConfig(c *proxyConfig) (string, error) {
+	b := bytes.NewBufferString("# " + fileProxyHeader + "\n\n")
+	b.WriteString("map $http_upgrade $safescale_connection_upgrade {\n    default upgrade;\n    '' close;\n}\n\n")
+	for _, name := range c.serviceNames() {
+		s := c.Services[name]
+		fmt.Fprintf(b, "upstream %s {\n", proxyIdentifier(name))
+		for _, server := range s.Servers {
+			fmt.Fprintf(b, "    server %s;\n", server)
+		}
+		b.WriteString("}\n\n")
+	}
+
+	// A server by set of hosts; routes without hosts go to the default server
+	byHosts := map[string][]*proxyRoute{}
+	for _, rt := range c.Routes {
+		key := strings.Join(rt.Hosts, " ")
+		byHosts[key] = append(byHosts[key], rt)
+	}
+	var keys []string
+	for k := range byHosts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	if _, ok := byHosts[""]; !ok {
+		keys = append([]string{""}, keys...)
+	}
+	for _, k := range keys {
+		b.WriteString("server {\n")
+		if k == "" {
+			b.WriteString("    listen 443 ssl default_server;\n    server_name _;\n")
+		} else {
+			fmt.Fprintf(b, "    listen 443 ssl;\n    server_name %s;\n", k)
+		}
+		fmt.Fprintf(b, "    ssl_certificate %s/cert.pem;\n    ssl_certificate_key %s/key.pem;\n", fileProxyCertFolder, fileProxyCertFolder)
+		for _, rt := range byHosts[k] {
+			s := c.Services[rt.Service]
+			paths := rt.Paths
+			if len(paths) == 0 {
+				paths = []string{"/"}
+			}
+			for _, p := range paths {
+				fmt.Fprintf(b, "\n    # route %s\n    location %s {\n", rt.Name, p)
+				for _, cidr := range c.whitelist(rt) {
+					fmt.Fprintf(b, "        allow %s;\n", cidr)
+				}
+				if len(c.whitelist(rt)) > 0 {
+					b.WriteString("        deny all;\n")
+				}
+				pass := fmt.Sprintf("%s://%s", s.Protocol, proxyIdentifier(s.Name))
+				if rt.StripPath {
+					if s.Path != "" {
+						pass += s.Path
+					} else {
+						pass += "/"
+					}
+				} else if s.Path != "" {
+					fmt.Fprintf(b, "        rewrite ^ %s$uri break;\n", strings.TrimRight(s.Path, "/"))
+				}
+				fmt.Fprintf(b, "        proxy_pass %s;\n", pass)
+				b.WriteString("        proxy_http_version 1.1;\n")
+				b.WriteString("        proxy_set_header Host $host;\n")
+				b.WriteString("        proxy_set_header X-Forwarded-For $proxy_add_x_forwarded_for;\n")
+				b.WriteString("        proxy_set_header X-Forwarded-Proto $scheme;\n")
+				b.WriteString("        proxy_set_header Upgrade $http_upgrade;\n")
+				b.WriteString("        proxy_set_header Connection $safescale_connection_upgrade;\n")
+				b.WriteString("    }\n")
+			}
+		}
+		b.WriteString("}\n\n")
+	}
+	return b.String(), nil
+}
+
+// renderHAProxyConfig generates a file of the folder conf.d of HAProxy (version 2.1 or later), loaded after the main
+// configuration file; its section 'global' is left to the main file, the section 'defaults' applies to the sections
+// of the file only
+func renderHAProxyConfig(c *proxyConfig) (string, error) {
+	b := bytes.NewBufferString("# " + fileProxyHeader + "\n\n")
+	b.WriteString("defaults\n    mode http\n    log global\n    option httplog\n    option forwardfor\n")
+	b.WriteString("    timeout connect 10s\n    timeout client 1m\n    timeout server 1m\n    timeout tunnel 1h\n\n")
+
+	b.WriteString("frontend safescale\n")
+	fmt.Fprintf(b, "    bind *:443 ssl crt %s/proxy.pem\n", fileProxyCertFolder)
+	b.WriteString("    http-request set-header X-Forwarded-Proto https\n")
+	for _, rt := range c.Routes {
+		id := proxyIdentifier(rt.Name)
+		var conditions []string
+		if len(rt.Paths) > 0 {
+			fmt.Fprintf(b, "    acl route_%s_path path_beg %s\n", id, strings.Join(rt.Paths, " "))
+			conditions = append(conditions, "route_"+id+"_path")
+		}
+		if len(rt.Hosts) > 0 {
+			fmt.Fprintf(b, "    acl route_%s_host hdr(host),field(1,:) -i %s\n", id, strings.Join(rt.Hosts, " "))
+			conditions = append(conditions, "route_"+id+"_host")
+		}
+		if len(conditions) == 0 {
+			conditions = append(conditions, "TRUE")
+		}
+		fmt.Fprintf(b, "    use_backend route_%s if %s\n", id, strings.Join(conditions, " "))
+	}
+
+	for _, rt := range c.Routes {
+		s := c.Services[rt.Service]
+		fmt.Fprintf(b, "\nbackend route_%s\n", proxyIdentifier(rt.Name))
+		if wl := c.whitelist(rt); len(wl) > 0 {
+			fmt.Fprintf(b, "    acl allowed src %s\n", strings.Join(wl, " "))
+			b.WriteString("    http-request deny unless allowed\n")
+		}
+		prefix := strings.TrimRight(s.Path, "/")
+		if rt.StripPath && len(rt.Paths) > 0 {
+			var quoted []string
+			for _, p := range rt.Paths {
+				quoted = append(quoted, regexp.QuoteMeta(strings.TrimRight(p, "/")))
+			}
+			fmt.Fprintf(b, "    http-request replace-path ^(?:%s)/?(.*) %s/\\1\n", strings.Join(quoted, "|"), prefix)
+		} else if prefix != "" {
+			fmt.Fprintf(b, "    http-request replace-path ^(.*) %s\\1\n", prefix)
+		}
+		options := ""
+		if s.Protocol == "https" {
+			options = " ssl verify none"
+		}
+		for i, server := range s.Servers {
+			fmt.Fprintf(b, "    server %s_%d %s%s\n", proxyIdentifier(s.Name), i, server, options)
+		}
+	}
+	return b.String(), nil
+}
+
+// renderTraefikConfig generates a file of the file provider of Traefik (version 2); the routers use the entrypoint
+// 'websecure'. The content is JSON, which is valid YAML.
+func renderTraefikConfig(c *proxyConfig) (string, error) {
+	routers := map[string]interface{}{}
+	middlewares := map[string]interface{}{}
+	services := map[string]interface{}{}
+
+	for _, name := range c.serviceNames() {
+		s := c.Services[name]
+		var servers []interface{}
+		for _, server := range s.Servers {
+			servers = append(servers, map[string]string{"url": s.Protocol + "://" + server})
+		}
+		services[proxyIdentifier(name)] = map[string]interface{}{
+			"loadBalancer": map[string]interface{}{
+				"servers":        servers,
+				"passHostHeader": true,
+			},
+		}
+	}
+
+	for _, rt := range c.Routes {
+		s := c.Services[rt.Service]
+		id := proxyIdentifier(rt.Name)
+		var matchers []string
+		if len(rt.Paths) > 0 {
+			var prefixes []string
+			for _, p := range rt.Paths {
+				prefixes = append(prefixes, fmt.Sprintf("PathPrefix(`%s`)", p))
+			}
+			matchers = append(matchers, "("+strings.Join(prefixes, " || ")+")")
+		}
+		if len(rt.Hosts) > 0 {
+			matchers = append(matchers, fmt.Sprintf("Host(`%s`)", strings.Join(rt.Hosts, "`, `")))
+		}
+		if len(matchers) == 0 {
+			matchers = append(matchers, "PathPrefix(`/`)")
+		}
+
+		var chain []string
+		if wl := c.whitelist(rt); len(wl) > 0 {
+			middlewares[id+"-whitelist"] = map[string]interface{}{"ipWhiteList": map[string]interface{}{"sourceRange": wl}}
+			chain = append(chain, id+"-whitelist")
+		}
+		if rt.StripPath && len(rt.Paths) > 0 {
+			middlewares[id+"-strip"] = map[string]interface{}{"stripPrefix": map[string]interface{}{"prefixes": rt.Paths}}
+			chain = append(chain, id+"-strip")
+		}
+		if prefix := strings.TrimRight(s.Path, "/"); prefix != "" {
+			middlewares[id+"-prefix"] = map[string]interface{}{"addPrefix": map[string]interface{}{"prefix": prefix}}
+			chain = append(chain, id+"-prefix")
+		}
+
+		router := map[string]interface{}{
+			"rule":        strings.Join(matchers, " && "),
+			"service":     proxyIdentifier(s.Name),
+			"entryPoints": []string{"websecure"},
+			"tls":         map[string]interface{}{},
+		}
+		if len(chain) > 0 {
+			router["middlewares"] = chain
+		}
+		routers[id] = router
+	}
+
+	httpConfig := map[string]interface{}{}
+	if len(routers) > 0 {
+		httpConfig["routers"] = routers
+	}
+	if len(middlewares) > 0 {
+		httpConfig["middlewares"] = middlewares
+	}
+	if len(services) > 0 {
+		httpConfig["services"] = services
+	}
+	jsoned, err := json.MarshalIndent(map[string]interface{}{"http": httpConfig}, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return "# " + fileProxyHeader + "\n" + string(jsoned) + "\n", nil
+}
@@ -0,0 +1,84 @@
+package install
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	propsv1 "github.com/CS-SI/SafeScale/lib/server/iaas/resources/properties/v1"
+)
+
+func testProxyRules() []*propsv1.NetworkProxyRule {
+	now := time.Now()
+	return []*propsv1.NetworkProxyRule{
+		{Type: "upstream", Name: "kibana_up", Content: `{"target": "10.0.0.11:5601"}`, AppliedAt: now},
+		{Type: "upstream", Name: "kibana_up", Content: `{"target": "10.0.0.12:5601"}`, AppliedAt: now.Add(time.Second)},
+		{Type: "service", Name: "kibana_svc", Content: `{"protocol": "http", "host": "kibana_up", "source-control": {"whitelist": ["10.0.0.0/16"]}}`, AppliedAt: now},
+		{Type: "service", Name: "guacamole_svc", Content: `{"url": "http://10.0.0.13:8080/guacamole/"}`, AppliedAt: now},
+		{Type: "route", Name: "kibana_route", Content: `{"paths": ["/monitoring/kibana/"], "service": {"id": "kibana_svc"}}`, AppliedAt: now},
+		{Type: "route", Name: "guacamole_route", Content: `{"paths": ["/remotedesktop/"], "hosts": ["desktop.example.com"], "strip_path": true, "service": {"name": "guacamole_svc"}}`, AppliedAt: now},
+	}
+}
+
+func TestBuildProxyConfig(t *testing.T) {
+	config, err := buildProxyConfig(testProxyRules(), true)
+	require.NoError(t, err)
+	require.Len(t, config.Services, 2)
+	assert.Equal(t, []string{"10.0.0.11:5601", "10.0.0.12:5601"}, config.Services["kibana_svc"].Servers)
+	assert.Equal(t, []string{"10.0.0.13:8080"}, config.Services["guacamole_svc"].Servers)
+	assert.Equal(t, "/guacamole/", config.Services["guacamole_svc"].Path)
+	require.Len(t, config.Routes, 2)
+	assert.Equal(t, "kibana_route", config.Routes[0].Name)
+	assert.True(t, config.Routes[0].StripPath)
+	assert.Equal(t, []string{"10.0.0.0/16"}, config.whitelist(config.Routes[0]))
+
+	rules := append(testProxyRules(), &propsv1.NetworkProxyRule{Type: "route", Name: "orphan", Content: `{"service": {"id": "unknown"}}`})
+	_, err = buildProxyConfig(rules, true)
+	assert.Error(t, err)
+	_, err = buildProxyConfig(rules, false)
+	assert.NoError(t, err)
+}
+
+func TestRenderProxyConfigs(t *testing.T) {
+	config, err := buildProxyConfig(testProxyRules(), true)
+	require.NoError(t, err)
+
+	nginx, err := renderNginxConfig(config)
+	require.NoError(t, err)
+	assert.Contains(t, nginx, "upstream kibana_svc {\n    server 10.0.0.11:5601;\n    server 10.0.0.12:5601;\n}")
+	assert.Contains(t, nginx, "server_name desktop.example.com;")
+	assert.Contains(t, nginx, "location /monitoring/kibana/ {\n        allow 10.0.0.0/16;\n        deny all;\n        proxy_pass http://kibana_svc/;")
+	assert.Contains(t, nginx, "proxy_pass http://guacamole_svc/guacamole/;")
+
+	haproxy, err := renderHAProxyConfig(config)
+	require.NoError(t, err)
+	assert.Contains(t, haproxy, "use_backend route_guacamole_route if route_guacamole_route_path route_guacamole_route_host")
+	assert.Contains(t, haproxy, `http-request replace-path ^(?:/remotedesktop)/?(.*) /guacamole/\1`)
+	assert.Contains(t, haproxy, "server kibana_svc_1 10.0.0.12:5601")
+	assert.NotContains(t, haproxy, "global\n")
+
+	traefik, err := renderTraefikConfig(config)
+	require.NoError(t, err)
+	decoded := map[string]map[string]map[string]interface{}{}
+	require.NoError(t, json.Unmarshal([]byte(traefik[len(fileProxyHeader)+3:]), &decoded))
+	router := decoded["http"]["routers"]["guacamole_route"].(map[string]interface{})
+	assert.Equal(t, "(PathPrefix(`/remotedesktop/`)) && Host(`desktop.example.com`)", router["rule"])
+	assert.Equal(t, []interface{}{"guacamole_route-strip", "guacamole_route-prefix"}, router["middlewares"])
+}
+
+func TestFileProxyScript(t *testing.T) {
+	// The main configuration file of HAProxy is left untouched, the generated one is loaded by a systemd drop-in
+	script := fileProxyScript(fileProxyKinds[ReverseProxyHAProxy], "frontend safescale", "1.2.3.4")
+	assert.NotContains(t, script, "cat >/etc/haproxy/haproxy.cfg")
+	assert.Contains(t, script, "cat >/etc/haproxy/conf.d/safescale.cfg <<'SAFESCALE_EOF'\nfrontend safescale\nSAFESCALE_EOF")
+	assert.Contains(t, script, "ExecStart=/usr/sbin/haproxy -Ws -f /etc/haproxy/haproxy.cfg -f /etc/haproxy/conf.d -p /run/haproxy.pid")
+	assert.Contains(t, script, "cat >/etc/systemd/system/haproxy.service.d/safescale.conf.new")
+	assert.Contains(t, script, "    systemctl restart haproxy || exit 1\nelse\n")
+
+	script = fileProxyScript(fileProxyKinds[ReverseProxyNginx], "server {}", "1.2.3.4")
+	assert.NotContains(t, script, "systemd")
+	assert.Contains(t, script, "\nsystemctl reload nginx || exit 1\n")
+}
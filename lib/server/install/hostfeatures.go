@@ -0,0 +1,138 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package install
+
+import (
+	"fmt"
+
+	pb "github.com/CS-SI/SafeScale/lib"
+	"github.com/CS-SI/SafeScale/lib/client"
+	"github.com/CS-SI/SafeScale/lib/server/iaas"
+	"github.com/CS-SI/SafeScale/lib/server/iaas/resources/enums/hostproperty"
+	propsv1 "github.com/CS-SI/SafeScale/lib/server/iaas/resources/properties/v1"
+	"github.com/CS-SI/SafeScale/lib/server/metadata"
+	"github.com/CS-SI/SafeScale/lib/utils/data"
+	"github.com/CS-SI/SafeScale/lib/utils/scerr"
+	"github.com/CS-SI/SafeScale/lib/utils/temporal"
+)
+
+// RegisterHostFeature records in the metadata of the host that the feature has been explicitly installed
+// (or upgraded) on it, with its version and the values of its parameters (secrets being encrypted)
+func RegisterHostFeature(host *pb.Host, f *Feature, v Variables) error {
+	if f == nil {
+		return scerr.InvalidParameterError("f", "cannot be nil")
+	}
+	return updateHostFeatures(host, func(svc iaas.Service, featuresV1 *propsv1.HostFeatures) {
+		item, ok := featuresV1.Installed[f.DisplayName()]
+		if !ok {
+			item = propsv1.NewHostInstalledFeature()
+			featuresV1.Installed[f.DisplayName()] = item
+		}
+		item.HostContext = true
+		item.Version = f.Version()
+		item.Parameters = f.SealParameters(svc, v)
+	})
+}
+
+// UnregisterHostFeature removes from the metadata of the host the record of the feature
+func UnregisterHostFeature(host *pb.Host, name string) error {
+	if name == "" {
+		return scerr.InvalidParameterError("name", "cannot be empty string")
+	}
+	return updateHostFeatures(host, func(_ iaas.Service, featuresV1 *propsv1.HostFeatures) {
+		delete(featuresV1.Installed, name)
+	})
+}
+
+// HostInstalledFeatures returns the features recorded as installed on the host, indexed by name, with the values
+// of their secret parameters decrypted
+func HostInstalledFeatures(host *pb.Host) (map[string]*propsv1.HostInstalledFeature, error) {
+	if host == nil {
+		return nil, scerr.InvalidParameterError("host", "cannot be nil")
+	}
+	svc, err := currentService()
+	if err != nil {
+		return nil, err
+	}
+	mh, err := metadata.LoadHost(svc, host.Id)
+	if err != nil {
+		return nil, err
+	}
+	rh, err := mh.Get()
+	if err != nil {
+		return nil, err
+	}
+
+	var installed map[string]*propsv1.HostInstalledFeature
+	err = rh.Properties.LockForRead(hostproperty.FeaturesV1).ThenUse(func(clonable data.Clonable) error {
+		installed = clonable.(*propsv1.HostFeatures).Clone().(*propsv1.HostFeatures).Installed
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range installed {
+		v.Parameters, err = UnsealParameters(svc, v.Parameters)
+		if err != nil {
+			return nil, fmt.Errorf("feature '%s': %s", k, err.Error())
+		}
+	}
+	return installed, nil
+}
+
+// updateHostFeatures applies 'update' on the property FeaturesV1 of the host, and saves the metadata
+func updateHostFeatures(host *pb.Host, update func(iaas.Service, *propsv1.HostFeatures)) error {
+	if host == nil {
+		return scerr.InvalidParameterError("host", "cannot be nil")
+	}
+	svc, err := currentService()
+	if err != nil {
+		return err
+	}
+	mh, err := metadata.LoadHost(svc, host.Id)
+	if err != nil {
+		return err
+	}
+	mh.Acquire()
+	defer mh.Release()
+
+	rh, err := mh.Get()
+	if err != nil {
+		return err
+	}
+	err = rh.Properties.LockForWrite(hostproperty.FeaturesV1).ThenUse(func(clonable data.Clonable) error {
+		featuresV1 := clonable.(*propsv1.HostFeatures)
+		if featuresV1.Installed == nil {
+			featuresV1.Installed = map[string]*propsv1.HostInstalledFeature{}
+		}
+		update(svc, featuresV1)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return mh.Write()
+}
+
+// currentService returns the service of the tenant currently selected on the daemon
+func currentService() (iaas.Service, error) {
+	tenant, err := client.New().Tenant.Get(temporal.GetExecutionTimeout())
+	if err != nil {
+		return nil, err
+	}
+	return iaas.UseService(tenant.Name)
+}
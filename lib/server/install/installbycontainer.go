@@ -0,0 +1,687 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package install
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+
+	"github.com/CS-SI/SafeScale/lib/server/cluster/enums/flavor"
+	"github.com/CS-SI/SafeScale/lib/server/install/enums/action"
+	"github.com/CS-SI/SafeScale/lib/server/install/enums/method"
+	"github.com/CS-SI/SafeScale/lib/utils/scerr"
+)
+
+const (
+	// containerModeHost runs the containers with docker (or docker-compose) on the hosts targeted
+	containerModeHost = "host"
+	// containerModeSwarm deploys a service (or a stack) on a Swarm cluster
+	containerModeSwarm = "swarm"
+	// containerModeKubernetes deploys a deployment on a Kubernetes cluster
+	containerModeKubernetes = "kubernetes"
+
+	// containerRequiredFeature is the feature required by the features installed with containers, except in mode
+	// containerModeKubernetes where the container runtime is part of the cluster
+	containerRequiredFeature = "docker"
+)
+
+// containerInstaller is an installer using container images, described by the key 'feature.install.docker'
+// (a container) or 'feature.install.compose' (a docker-compose file) of the specification file.
+// The steps of the actions are generated, then run as bash steps.
+type containerInstaller struct {
+	method method.Enum
+}
+
+// NewDockerInstaller creates a new instance of Installer using a container image
+func NewDockerInstaller() Installer {
+	return &containerInstaller{method: method.Docker}
+}
+
+// NewComposeInstaller creates a new instance of Installer using a docker-compose file
+func NewComposeInstaller() Installer {
+	return &containerInstaller{method: method.Compose}
+}
+
+// Check checks if the container (or the service, the stack, the deployment) is running
+func (i *containerInstaller) Check(f *Feature, t Target, v Variables, s Settings) (Results, error) {
+	return i.proceed(f, t, action.Check, v, s)
+}
+
+// Add runs the container (or deploys the service, the stack, the deployment)
+func (i *containerInstaller) Add(f *Feature, t Target, v Variables, s Settings) (Results, error) {
+	return i.proceed(f, t, action.Add, v, s)
+}
+
+// Remove removes the container (or the service, the stack, the deployment)
+func (i *containerInstaller) Remove(f *Feature, t Target, v Variables, s Settings) (Results, error) {
+	return i.proceed(f, t, action.Remove, v, s)
+}
+
+// Upgrade pulls the image and recreates the container (or updates the service, the stack, the deployment)
+func (i *containerInstaller) Upgrade(f *Feature, t Target, v Variables, s Settings) (Results, error) {
+	return i.proceed(f, t, action.Upgrade, v, s)
+}
+
+// proceed generates the steps of the action, then runs them
+func (i *containerInstaller) proceed(f *Feature, t Target, a action.Enum, v Variables, s Settings) (Results, error) {
+	mode := containerModeOf(f, t)
+	var (
+		pace  string
+		steps map[string]interface{}
+		err   error
+	)
+	switch i.method {
+	case method.Docker:
+		var spec *containerSpec
+		spec, err = parseContainerSpec(f)
+		if err == nil {
+			pace, steps, err = spec.steps(a, mode)
+		}
+	case method.Compose:
+		var spec *composeSpec
+		spec, err = parseComposeSpec(f)
+		if err == nil {
+			pace, steps, err = spec.steps(a, mode)
+		}
+	default:
+		err = scerr.InvalidParameterError("method", fmt.Sprintf("'%s' is not a container method", i.method.String()))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	derived := f.withActionSteps(i.method, a, pace, steps)
+	worker, err := newWorker(derived, t, i.method, a, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = worker.CanProceed(s)
+	if err != nil {
+		log.Println(err.Error())
+		return nil, err
+	}
+	return worker.Proceed(v, s)
+}
+
+// withActionSteps returns a copy of the feature whose specification contains the pace and the steps of the
+// action for the method
+func (f *Feature) withActionSteps(m method.Enum, a action.Enum, pace string, steps map[string]interface{}) *Feature {
+	specs := viper.New()
+	for _, k := range f.specs.AllKeys() {
+		specs.Set(k, f.specs.Get(k))
+	}
+	rootKey := fmt.Sprintf("feature.install.%s.%s", strings.ToLower(m.String()), strings.ToLower(a.String()))
+	specs.Set(rootKey+"."+yamlPaceKeyword, pace)
+	specs.Set(rootKey+"."+yamlStepsKeyword, steps)
+
+	derived := *f
+	derived.specs = specs
+	return &derived
+}
+
+// containerModeOf determines how containers are deployed on the target
+func containerModeOf(f *Feature, t Target) string {
+	_, clusterTarget, _ := determineContext(t)
+	if clusterTarget == nil {
+		return containerModeHost
+	}
+	return containerModeOfFlavor(clusterTarget.cluster.GetIdentity(f.task).Flavor)
+}
+
+// containerModeOfFlavor determines how containers are deployed on a cluster of flavor 'fl'
+func containerModeOfFlavor(fl flavor.Enum) string {
+	switch fl {
+	case flavor.K8S, flavor.K3S:
+		return containerModeKubernetes
+	case flavor.SWARM:
+		return containerModeSwarm
+	}
+	return containerModeHost
+}
+
+// usesContainers tells if the feature is installed with containers
+func (f *Feature) usesContainers() bool {
+	return f.specs.IsSet("feature.install.docker") || f.specs.IsSet("feature.install.compose")
+}
+
+// containerHealthCheck describes how to check the health of a container
+type containerHealthCheck struct {
+	Command     string
+	Interval    string
+	Timeout     string
+	Retries     int
+	StartPeriod string
+}
+
+// containerSpec is the content of the key 'feature.install.docker' of the specification file
+type containerSpec struct {
+	feature   string
+	Name      string
+	Image     string
+	Command   string
+	Ports     []string
+	Volumes   []string
+	Env       []string
+	Restart   string
+	Replicas  int
+	Namespace string
+	Health    *containerHealthCheck
+	Targets   map[string]interface{}
+}
+
+// parseContainerSpec reads the key 'feature.install.docker' of the specification file
+func parseContainerSpec(f *Feature) (*containerSpec, error) {
+	const key = "feature.install.docker"
+	specs := f.specs
+	spec := containerSpec{
+		feature:   f.DisplayName(),
+		Name:      specs.GetString(key + ".name"),
+		Image:     specs.GetString(key + ".image"),
+		Command:   specs.GetString(key + ".command"),
+		Ports:     specs.GetStringSlice(key + ".ports"),
+		Volumes:   specs.GetStringSlice(key + ".volumes"),
+		Env:       specs.GetStringSlice(key + ".env"),
+		Restart:   specs.GetString(key + ".restart"),
+		Replicas:  specs.GetInt(key + ".replicas"),
+		Namespace: specs.GetString(key + ".namespace"),
+		Targets:   specs.GetStringMap(key + ".targets"),
+	}
+	if spec.Image == "" {
+		return nil, scerr.SyntaxError(fmt.Sprintf("syntax error in feature '%s' specification file (%s): no key '%s.image' found", f.DisplayName(), f.DisplayFilename(), key))
+	}
+	if spec.Name == "" {
+		spec.Name = f.DisplayName()
+	}
+	if spec.Restart == "" {
+		spec.Restart = "unless-stopped"
+	}
+	if spec.Replicas <= 0 {
+		spec.Replicas = 1
+	}
+	if spec.Namespace == "" {
+		spec.Namespace = "default"
+	}
+	for _, e := range spec.Env {
+		if !strings.Contains(e, "=") {
+			return nil, scerr.SyntaxError(fmt.Sprintf("syntax error in feature '%s' specification file (%s): invalid item '%s' in '%s.env', must be NAME=value", f.DisplayName(), f.DisplayFilename(), e, key))
+		}
+	}
+	if specs.IsSet(key + ".healthcheck") {
+		spec.Health = &containerHealthCheck{
+			Command:     specs.GetString(key + ".healthcheck.command"),
+			Interval:    specs.GetString(key + ".healthcheck.interval"),
+			Timeout:     specs.GetString(key + ".healthcheck.timeout"),
+			Retries:     specs.GetInt(key + ".healthcheck.retries"),
+			StartPeriod: specs.GetString(key + ".healthcheck.start_period"),
+		}
+		if spec.Health.Command == "" {
+			return nil, scerr.SyntaxError(fmt.Sprintf("syntax error in feature '%s' specification file (%s): no key '%s.healthcheck.command' found", f.DisplayName(), f.DisplayFilename(), key))
+		}
+	}
+	return &spec, nil
+}
+
+// steps returns the pace and the steps of the action
+func (cs *containerSpec) steps(a action.Enum, mode string) (string, map[string]interface{}, error) {
+	switch mode {
+	case containerModeSwarm:
+		return cs.swarmSteps(a)
+	case containerModeKubernetes:
+		return cs.kubernetesSteps(a)
+	default:
+		return cs.hostSteps(a)
+	}
+}
+
+// envFile returns the path of the file containing the environment of the container, on the hosts
+func (cs *containerSpec) envFile() string {
+	return fmt.Sprintf("${SF_ETCDIR}/%s/%s.env", cs.feature, cs.Name)
+}
+
+// writeEnvFile returns the commands writing the environment of the container on the host
+func (cs *containerSpec) writeEnvFile() string {
+	return fmt.Sprintf("mkdir -p ${SF_ETCDIR}/%s\ncat >%s <<'EOF'\n%s\nEOF\nchmod 0600 %s\n", cs.feature, cs.envFile(), strings.Join(cs.Env, "\n"), cs.envFile())
+}
+
+// healthOptions returns the options of 'docker run' or 'docker service create' defining the health check
+func (cs *containerSpec) healthOptions() string {
+	if cs.Health == nil {
+		return ""
+	}
+	options := " --health-cmd " + shellQuote(cs.Health.Command)
+	if cs.Health.Interval != "" {
+		options += " --health-interval " + cs.Health.Interval
+	}
+	if cs.Health.Timeout != "" {
+		options += " --health-timeout " + cs.Health.Timeout
+	}
+	if cs.Health.Retries > 0 {
+		options += " --health-retries " + strconv.Itoa(cs.Health.Retries)
+	}
+	if cs.Health.StartPeriod != "" {
+		options += " --health-start-period " + cs.Health.StartPeriod
+	}
+	return options
+}
+
+// hostTargets returns the targets of the steps run on the hosts
+func (cs *containerSpec) hostTargets() map[string]interface{} {
+	return containerHostTargets(cs.Targets)
+}
+
+// hostSteps returns the pace and the steps of the action, running the container with docker on the hosts
+func (cs *containerSpec) hostSteps(a action.Enum) (string, map[string]interface{}, error) {
+	running := fmt.Sprintf("docker ps -q --filter name=^/%s$ --filter status=running", cs.Name)
+	healthy := fmt.Sprintf("docker ps -q --filter name=^/%s$ --filter health=healthy", cs.Name)
+
+	switch a {
+	case action.Check:
+		script := fmt.Sprintf("[ -n \"$(%s)\" ] || sfFail 191 \"container '%s' is not running\"\n", running, cs.Name)
+		if cs.Health != nil {
+			script += fmt.Sprintf("[ -n \"$(%s)\" ] || sfFail 192 \"container '%s' is not healthy\"\n", healthy, cs.Name)
+		}
+		return "running", map[string]interface{}{
+			"running": containerStep(cs.hostTargets(), script+"sfExit\n"),
+		}, nil
+
+	case action.Add, action.Upgrade:
+		run := fmt.Sprintf("docker run -d --name %s --restart %s --env-file %s", cs.Name, cs.Restart, cs.envFile())
+		for _, p := range cs.Ports {
+			run += " -p " + shellQuote(p)
+		}
+		for _, v := range cs.Volumes {
+			run += " -v " + shellQuote(v)
+		}
+		run += cs.healthOptions() + " " + shellQuote(cs.Image)
+		if cs.Command != "" {
+			run += " " + cs.Command
+		}
+
+		filter, state := running, "running"
+		if cs.Health != nil {
+			filter, state = healthy, "healthy"
+		}
+		ready := fmt.Sprintf("for i in $(seq 60); do\n    [ -n \"$(%s)\" ] && sfExit\n    sleep 5\ndone\nsfFail 195 \"container '%s' is not %s\"\n", filter, cs.Name, state)
+		return "image,container,ready", map[string]interface{}{
+			"image": containerStep(cs.hostTargets(), fmt.Sprintf("docker pull %s || sfFail 192\nsfExit\n", shellQuote(cs.Image))),
+			"container": containerStep(cs.hostTargets(), fmt.Sprintf("%sdocker rm -f %s &>/dev/null || true\n%s || sfFail 193\nsfExit\n",
+				cs.writeEnvFile(), cs.Name, run)),
+			"ready": containerStep(cs.hostTargets(), ready),
+		}, nil
+
+	case action.Remove:
+		return "container", map[string]interface{}{
+			"container": containerStep(cs.hostTargets(), fmt.Sprintf("docker rm -f %s &>/dev/null || true\nrm -f %s\nsfRemoveDockerImage %s || true\nsfExit\n",
+				cs.Name, cs.envFile(), shellQuote(cs.Image))),
+		}, nil
+	}
+	return "", nil, scerr.NotAvailableError(fmt.Sprintf("action '%s' not supported by containers", a.String()))
+}
+
+// swarmRestartCondition converts the restart policy of docker to the restart condition of Swarm
+func swarmRestartCondition(restart string) string {
+	switch restart {
+	case "no":
+		return "none"
+	case "on-failure":
+		return "on-failure"
+	default:
+		return "any"
+	}
+}
+
+// swarmSteps returns the pace and the steps of the action, deploying a service on the Swarm cluster
+func (cs *containerSpec) swarmSteps(a action.Enum) (string, map[string]interface{}, error) {
+	manager := map[string]interface{}{"masters": "one"}
+
+	switch a {
+	case action.Check:
+		return "service", map[string]interface{}{
+			"service": containerStep(manager, fmt.Sprintf("docker service inspect %s &>/dev/null || sfFail 191 \"service '%s' not found\"\nsfDoesDockerRunService %s %s || sfFail 192 \"service '%s' is not running\"\nsfExit\n",
+				cs.Name, cs.Name, shellQuote(cs.Image), cs.Name, cs.Name)),
+		}, nil
+
+	case action.Add:
+		create := fmt.Sprintf("docker service create --detach --name %s --replicas %d --restart-condition %s --env-file %s",
+			cs.Name, cs.Replicas, swarmRestartCondition(cs.Restart), cs.envFile())
+		for _, p := range cs.Ports {
+			create += " -p " + shellQuote(p)
+		}
+		for _, v := range cs.Volumes {
+			mount, err := swarmMount(v)
+			if err != nil {
+				return "", nil, err
+			}
+			create += " --mount " + shellQuote(mount)
+		}
+		create += cs.healthOptions() + " " + shellQuote(cs.Image)
+		if cs.Command != "" {
+			create += " " + cs.Command
+		}
+		return "service,ready", map[string]interface{}{
+			"service": containerStep(manager, fmt.Sprintf("%s%s || sfFail 193\nsfExit\n", cs.writeEnvFile(), create)),
+			"ready":   containerStep(manager, fmt.Sprintf("sfRetry 5m 5 sfDoesDockerRunService %s %s || sfFail 195 \"service '%s' is not running\"\nsfExit\n", shellQuote(cs.Image), cs.Name, cs.Name)),
+		}, nil
+
+	case action.Upgrade:
+		return "service,ready", map[string]interface{}{
+			"service": containerStep(manager, fmt.Sprintf("docker service update --detach --with-registry-auth --image %s %s || sfFail 193\nsfExit\n", shellQuote(cs.Image), cs.Name)),
+			"ready":   containerStep(manager, fmt.Sprintf("sfRetry 5m 5 sfDoesDockerRunService %s %s || sfFail 195 \"service '%s' is not running\"\nsfExit\n", shellQuote(cs.Image), cs.Name, cs.Name)),
+		}, nil
+
+	case action.Remove:
+		return "service", map[string]interface{}{
+			"service": containerStep(manager, fmt.Sprintf("docker service rm %s &>/dev/null || true\nrm -f %s\nsfExit\n", cs.Name, cs.envFile())),
+		}, nil
+	}
+	return "", nil, scerr.NotAvailableError(fmt.Sprintf("action '%s' not supported by containers", a.String()))
+}
+
+// swarmMount converts a volume in docker format (<source>:<target>[:ro]) to a mount of Swarm
+func swarmMount(volume string) (string, error) {
+	parts := strings.Split(volume, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return "", scerr.SyntaxError(fmt.Sprintf("invalid volume '%s', must be <source>:<target>[:ro]", volume))
+	}
+	kind := "volume"
+	if strings.HasPrefix(parts[0], "/") {
+		kind = "bind"
+	}
+	mount := fmt.Sprintf("type=%s,source=%s,target=%s", kind, parts[0], parts[1])
+	if len(parts) == 3 && parts[2] == "ro" {
+		mount += ",readonly"
+	}
+	return mount, nil
+}
+
+// kubernetesSteps returns the pace and the steps of the action, deploying a deployment on the Kubernetes cluster
+func (cs *containerSpec) kubernetesSteps(a action.Enum) (string, map[string]interface{}, error) {
+	master := map[string]interface{}{"masters": "one"}
+	manifest := fmt.Sprintf("${SF_ETCDIR}/%s/%s.deployment.json", cs.feature, cs.Name)
+	secret := cs.Name + "-env"
+
+	switch a {
+	case action.Check:
+		return "deployment", map[string]interface{}{
+			"deployment": containerStep(master, fmt.Sprintf("sfKubectl -n %s get deployment %s &>/dev/null || sfFail 191 \"deployment '%s' not found\"\nsfKubectl -n %s rollout status deployment/%s --timeout=5s || sfFail 192 \"deployment '%s' is not ready\"\nsfExit\n",
+				cs.Namespace, cs.Name, cs.Name, cs.Namespace, cs.Name, cs.Name)),
+		}, nil
+
+	case action.Add, action.Upgrade:
+		content, err := cs.kubernetesManifest(secret)
+		if err != nil {
+			return "", nil, err
+		}
+		script := cs.writeEnvFile()
+		script += fmt.Sprintf("cat %s | sfKubectl -n %s create secret generic %s --from-env-file=/dev/stdin --dry-run -o json | sfKubectl apply -f - || sfFail 192\n", cs.envFile(), cs.Namespace, secret)
+		script += fmt.Sprintf("cat >%s <<'EOF'\n%s\nEOF\n", manifest, content)
+		script += fmt.Sprintf("sfKubectl apply -f - <%s || sfFail 193\n", manifest)
+		if a == action.Upgrade {
+			script += fmt.Sprintf("sfKubectl -n %s rollout restart deployment/%s || sfFail 194\n", cs.Namespace, cs.Name)
+		}
+		return "deployment,ready", map[string]interface{}{
+			"deployment": containerStep(master, script+"sfExit\n"),
+			"ready":      containerStep(master, fmt.Sprintf("sfKubectl -n %s rollout status deployment/%s --timeout=300s || sfFail 195 \"deployment '%s' is not ready\"\nsfExit\n", cs.Namespace, cs.Name, cs.Name)),
+		}, nil
+
+	case action.Remove:
+		return "deployment", map[string]interface{}{
+			"deployment": containerStep(master, fmt.Sprintf("sfKubectl -n %s delete deployment %s --ignore-not-found || sfFail 192\nsfKubectl -n %s delete secret %s --ignore-not-found || true\nrm -f %s %s\nsfExit\n",
+				cs.Namespace, cs.Name, cs.Namespace, secret, manifest, cs.envFile())),
+		}, nil
+	}
+	return "", nil, scerr.NotAvailableError(fmt.Sprintf("action '%s' not supported by containers", a.String()))
+}
+
+// kubernetesManifest returns the manifest (in JSON) of the deployment
+func (cs *containerSpec) kubernetesManifest(secret string) (string, error) {
+	labels := map[string]string{
+		"app.kubernetes.io/name":       cs.Name,
+		"app.kubernetes.io/managed-by": "safescale",
+	}
+	container := map[string]interface{}{
+		"name":            cs.Name,
+		"image":           cs.Image,
+		"imagePullPolicy": "Always",
+		"envFrom":         []interface{}{map[string]interface{}{"secretRef": map[string]interface{}{"name": secret}}},
+	}
+	if cs.Command != "" {
+		container["command"] = []string{"sh", "-c", cs.Command}
+	}
+
+	var ports []interface{}
+	for _, p := range cs.Ports {
+		port, err := kubernetesPort(p)
+		if err != nil {
+			return "", err
+		}
+		ports = append(ports, port)
+	}
+	if len(ports) > 0 {
+		container["ports"] = ports
+	}
+
+	var (
+		mounts  []interface{}
+		volumes []interface{}
+	)
+	for i, v := range cs.Volumes {
+		parts := strings.Split(v, ":")
+		if len(parts) < 2 || len(parts) > 3 || !strings.HasPrefix(parts[0], "/") {
+			return "", scerr.SyntaxError(fmt.Sprintf("invalid volume '%s', must be <host path>:<target>[:ro] on Kubernetes", v))
+		}
+		name := fmt.Sprintf("volume-%d", i)
+		mount := map[string]interface{}{"name": name, "mountPath": parts[1]}
+		if len(parts) == 3 && parts[2] == "ro" {
+			mount["readOnly"] = true
+		}
+		mounts = append(mounts, mount)
+		volumes = append(volumes, map[string]interface{}{"name": name, "hostPath": map[string]interface{}{"path": parts[0]}})
+	}
+	if len(mounts) > 0 {
+		container["volumeMounts"] = mounts
+	}
+
+	if cs.Health != nil {
+		probe := map[string]interface{}{
+			"exec": map[string]interface{}{"command": []string{"sh", "-c", cs.Health.Command}},
+		}
+		for k, d := range map[string]string{"periodSeconds": cs.Health.Interval, "timeoutSeconds": cs.Health.Timeout, "initialDelaySeconds": cs.Health.StartPeriod} {
+			if d == "" {
+				continue
+			}
+			duration, err := time.ParseDuration(d)
+			if err != nil {
+				return "", scerr.SyntaxError(fmt.Sprintf("invalid duration '%s' in healthcheck: %s", d, err.Error()))
+			}
+			probe[k] = int(duration.Seconds())
+		}
+		if cs.Health.Retries > 0 {
+			probe["failureThreshold"] = cs.Health.Retries
+		}
+		container["livenessProbe"] = probe
+		container["readinessProbe"] = probe
+	}
+
+	podSpec := map[string]interface{}{
+		"containers": []interface{}{container},
+	}
+	if len(volumes) > 0 {
+		podSpec["volumes"] = volumes
+	}
+	deployment := map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata": map[string]interface{}{
+			"name":      cs.Name,
+			"namespace": cs.Namespace,
+			"labels":    labels,
+		},
+		"spec": map[string]interface{}{
+			"replicas": cs.Replicas,
+			"selector": map[string]interface{}{"matchLabels": map[string]string{"app.kubernetes.io/name": cs.Name}},
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{"labels": labels},
+				"spec":     podSpec,
+			},
+		},
+	}
+
+	buffer := bytes.NewBuffer(nil)
+	encoder := json.NewEncoder(buffer)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "    ")
+	err := encoder.Encode(deployment)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buffer.String()), nil
+}
+
+// kubernetesPort converts a port in docker format ([<host port>:]<container port>[/<protocol>]) to a container
+// port of Kubernetes (the host port being published on the node running the pod)
+func kubernetesPort(p string) (map[string]interface{}, error) {
+	protocol := "TCP"
+	if idx := strings.Index(p, "/"); idx >= 0 {
+		protocol = strings.ToUpper(p[idx+1:])
+		p = p[:idx]
+	}
+	parts := strings.Split(p, ":")
+	port := map[string]interface{}{"protocol": protocol}
+	var err error
+	switch len(parts) {
+	case 1:
+		port["containerPort"], err = strconv.Atoi(parts[0])
+	case 2:
+		port["hostPort"], err = strconv.Atoi(parts[0])
+		if err == nil {
+			port["containerPort"], err = strconv.Atoi(parts[1])
+		}
+	default:
+		err = fmt.Errorf("too many ':'")
+	}
+	if err != nil {
+		return nil, scerr.SyntaxError(fmt.Sprintf("invalid port '%s' on Kubernetes: %s", p, err.Error()))
+	}
+	return port, nil
+}
+
+// composeSpec is the content of the key 'feature.install.compose' of the specification file
+type composeSpec struct {
+	feature string
+	Project string
+	File    string
+	Targets map[string]interface{}
+}
+
+// parseComposeSpec reads the key 'feature.install.compose' of the specification file
+func parseComposeSpec(f *Feature) (*composeSpec, error) {
+	const key = "feature.install.compose"
+	spec := composeSpec{
+		feature: f.DisplayName(),
+		Project: f.specs.GetString(key + ".project"),
+		File:    f.specs.GetString(key + ".file"),
+		Targets: f.specs.GetStringMap(key + ".targets"),
+	}
+	if spec.File == "" {
+		return nil, scerr.SyntaxError(fmt.Sprintf("syntax error in feature '%s' specification file (%s): no key '%s.file' found", f.DisplayName(), f.DisplayFilename(), key))
+	}
+	if spec.Project == "" {
+		spec.Project = f.DisplayName()
+	}
+	return &spec, nil
+}
+
+// steps returns the pace and the steps of the action
+func (cs *composeSpec) steps(a action.Enum, mode string) (string, map[string]interface{}, error) {
+	file := fmt.Sprintf("${SF_ETCDIR}/%s/docker-compose.yml", cs.feature)
+	write := fmt.Sprintf("mkdir -p ${SF_ETCDIR}/%s\ncat >%s <<'EOF'\n%s\nEOF\n", cs.feature, file, strings.TrimRight(cs.File, "\n"))
+	compose := fmt.Sprintf("docker-compose -f %s -p %s", file, cs.Project)
+
+	switch mode {
+	case containerModeKubernetes:
+		return "", nil, scerr.NotAvailableError("docker-compose files cannot be deployed on a Kubernetes cluster, use the key 'docker' instead")
+
+	case containerModeSwarm:
+		manager := map[string]interface{}{"masters": "one"}
+		switch a {
+		case action.Check:
+			return "stack", map[string]interface{}{
+				"stack": containerStep(manager, fmt.Sprintf("sfDoesDockerRunStack %s || sfFail 191 \"stack '%s' is not running\"\nsfExit\n", cs.Project, cs.Project)),
+			}, nil
+		case action.Add, action.Upgrade:
+			return "stack", map[string]interface{}{
+				"stack": containerStep(manager, fmt.Sprintf("%sdocker stack deploy --with-registry-auth -c %s %s || sfFail 193\nsfRetry 5m 5 sfDoesDockerRunStack %s || sfFail 195\nsfExit\n", write, file, cs.Project, cs.Project)),
+			}, nil
+		case action.Remove:
+			return "stack", map[string]interface{}{
+				"stack": containerStep(manager, fmt.Sprintf("docker stack rm %s || sfFail 192\nrm -f %s\nsfExit\n", cs.Project, file)),
+			}, nil
+		}
+
+	default:
+		targets := containerHostTargets(cs.Targets)
+		switch a {
+		case action.Check:
+			return "running", map[string]interface{}{
+				"running": containerStep(targets, fmt.Sprintf("[ -f %s ] || sfFail 191 \"project '%s' not found\"\n[ -n \"$(%s ps -q)\" ] || sfFail 192 \"project '%s' is not running\"\nsfExit\n", file, cs.Project, compose, cs.Project)),
+			}, nil
+		case action.Add, action.Upgrade:
+			return "images,up", map[string]interface{}{
+				"images": containerStep(targets, fmt.Sprintf("%s%s pull || sfFail 192\nsfExit\n", write, compose)),
+				"up":     containerStep(targets, fmt.Sprintf("%s up -d || sfFail 193\nsfExit\n", compose)),
+			}, nil
+		case action.Remove:
+			return "down", map[string]interface{}{
+				"down": containerStep(targets, fmt.Sprintf("[ -f %s ] && { %s down || sfFail 192; }\nrm -f %s\nsfExit\n", file, compose, file)),
+			}, nil
+		}
+	}
+	return "", nil, scerr.NotAvailableError(fmt.Sprintf("action '%s' not supported by containers", a.String()))
+}
+
+// containerHostTargets returns the targets of the steps run on the hosts: the ones of the specification file,
+// or by default the host (for a host) or all the masters (for a cluster)
+func containerHostTargets(targets map[string]interface{}) map[string]interface{} {
+	if len(targets) > 0 {
+		return targets
+	}
+	return map[string]interface{}{
+		targetHosts:   "yes",
+		targetMasters: "all",
+	}
+}
+
+// containerStep returns the description of a step, in the format of the specification files
+func containerStep(targets map[string]interface{}, script string) map[string]interface{} {
+	return map[string]interface{}{
+		yamlTargetsKeyword: targets,
+		yamlRunKeyword:     script,
+	}
+}
+
+// shellQuote quotes the string for bash
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'"'"'`, -1) + "'"
+}
@@ -0,0 +1,87 @@
+package install
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/CS-SI/SafeScale/lib/server/cluster/enums/flavor"
+	"github.com/CS-SI/SafeScale/lib/server/install/enums/action"
+)
+
+const testDockerSpecs = `
+feature:
+    install:
+        docker:
+            image: nginx:1.17
+            ports:
+                - "8080:80"
+            volumes:
+                - /data/www:/usr/share/nginx/html:ro
+            env:
+                - "ADMIN_PASSWORD={{ .Password }}"
+            healthcheck:
+                command: curl -f http://localhost/
+                interval: 10s
+`
+
+func TestContainerSpec_HostSteps(t *testing.T) {
+	f := newTestFeature(t, testDockerSpecs)
+	spec, err := parseContainerSpec(f)
+	require.NoError(t, err)
+	assert.Equal(t, "test", spec.Name)
+	assert.Equal(t, "unless-stopped", spec.Restart)
+
+	pace, steps, err := spec.steps(action.Add, containerModeHost)
+	require.NoError(t, err)
+	assert.Equal(t, "image,container,ready", pace)
+	script := steps["container"].(map[string]interface{})[yamlRunKeyword].(string)
+	assert.Contains(t, script, "ADMIN_PASSWORD={{ .Password }}")
+	assert.Contains(t, script, "docker run -d --name test --restart unless-stopped")
+	assert.Contains(t, script, "-p '8080:80' -v '/data/www:/usr/share/nginx/html:ro'")
+	assert.Contains(t, script, "--health-cmd 'curl -f http://localhost/' --health-interval 10s 'nginx:1.17'")
+
+	_, steps, err = spec.steps(action.Check, containerModeHost)
+	require.NoError(t, err)
+	script = steps["running"].(map[string]interface{})[yamlRunKeyword].(string)
+	assert.Contains(t, script, "--filter health=healthy")
+}
+
+func TestContainerSpec_KubernetesManifest(t *testing.T) {
+	spec, err := parseContainerSpec(newTestFeature(t, testDockerSpecs))
+	require.NoError(t, err)
+
+	manifest, err := spec.kubernetesManifest("test-env")
+	require.NoError(t, err)
+	assert.Contains(t, manifest, `"hostPort": 8080`)
+	assert.Contains(t, manifest, `"containerPort": 80`)
+	assert.Contains(t, manifest, `"periodSeconds": 10`)
+	assert.NotContains(t, manifest, "{{")
+	assert.NotContains(t, manifest, "}}")
+}
+
+func TestContainerSpec_Errors(t *testing.T) {
+	_, err := parseContainerSpec(newTestFeature(t, "feature:\n    install:\n        docker:\n            name: web\n"))
+	assert.Error(t, err)
+
+	_, err = parseContainerSpec(newTestFeature(t, "feature:\n    install:\n        docker:\n            image: nginx\n            env:\n                - DEBUG\n"))
+	assert.Error(t, err)
+
+	spec, err := parseComposeSpec(newTestFeature(t, "feature:\n    install:\n        compose:\n            file: |\n                version: '3'\n"))
+	require.NoError(t, err)
+	_, _, err = spec.steps(action.Add, containerModeKubernetes)
+	assert.Error(t, err)
+}
+
+func TestShellQuote(t *testing.T) {
+	assert.Equal(t, `'it'"'"'s'`, shellQuote("it's"))
+}
+
+func TestContainerModeOfFlavor(t *testing.T) {
+	assert.Equal(t, containerModeKubernetes, containerModeOfFlavor(flavor.K8S))
+	assert.Equal(t, containerModeKubernetes, containerModeOfFlavor(flavor.K3S))
+	assert.Equal(t, containerModeSwarm, containerModeOfFlavor(flavor.SWARM))
+	assert.Equal(t, containerModeHost, containerModeOfFlavor(flavor.BOH))
+	assert.Equal(t, containerModeHost, containerModeOfFlavor(flavor.NOMAD))
+}
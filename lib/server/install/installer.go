@@ -26,6 +26,8 @@ type Installer interface {
 	Add(*Feature, Target, Variables, Settings) (Results, error)
 	// Remove executes deletion of feature
 	Remove(*Feature, Target, Variables, Settings) (Results, error)
+	// Upgrade executes upgrade of feature
+	Upgrade(*Feature, Target, Variables, Settings) (Results, error)
 }
 
 // // installerMap keeps a map of available installers sorted by Method
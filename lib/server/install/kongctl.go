@@ -17,18 +17,16 @@
 package install
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
 	"strings"
-	"text/template"
 
 	"github.com/sirupsen/logrus"
 
 	safescale "github.com/CS-SI/SafeScale/lib/client"
 	"github.com/CS-SI/SafeScale/lib/server/iaas"
 	"github.com/CS-SI/SafeScale/lib/server/iaas/resources"
-	"github.com/CS-SI/SafeScale/lib/server/metadata"
+	propsv1 "github.com/CS-SI/SafeScale/lib/server/iaas/resources/properties/v1"
 	srvutils "github.com/CS-SI/SafeScale/lib/server/utils"
 	"github.com/CS-SI/SafeScale/lib/utils"
 	"github.com/CS-SI/SafeScale/lib/utils/cli/enums/outputs"
@@ -43,19 +41,17 @@ const (
 	curlPost  = "curl -kSsl -X POST --url https://localhost:8444/%s -H \"Content-Type:application/json\" -w \"\\n%%{http_code}\" -d @- <<'EOF'\n%s\nEOF\n"
 	curlPut   = "curl -kSsl -X PUT --url https://localhost:8444/%s -H \"Content-Type:application/json\" -w \"\\n%%{http_code}\" -d @- <<'EOF'\n%s\nEOF\n"
 	curlPatch = "curl -kSsl -X PATCH --url https://localhost:8444/%s -H \"Content-Type:application/json\" -w \"\\n%%{http_code}\" -d @- <<'EOF'\n%s\nEOF\n"
+	curlDelete = "curl -kSsl -X DELETE --url https://localhost:8444/%s -w \"\\n%%{http_code}\""
 )
 
 var kongProxyCheckedCache = utils.NewMapCache()
 
 // KongController allows to control Kong, installed on a host
+// satisfies interface ReverseProxyController
 type KongController struct {
-	network *resources.Network
+	proxyGateway
 	// host      *pb.Host
 	safescale safescale.Client
-
-	gateway          *resources.Host
-	gatewayPrivateIP string
-	gatewayPublicIP  string
 }
 
 // NewKongController ...
@@ -64,6 +60,12 @@ type KongController struct {
 //    nil, scerr.ErrNotFound if reverseproxy is not installed
 //    nil, scerr.ErrNotAvailable if cannot check if reverseproxy is installed
 func NewKongController(svc iaas.Service, network *resources.Network, addressPrimaryGateway bool) (*KongController, error) {
+	return newKongController(svc, network, addressPrimaryGateway, true)
+}
+
+// newKongController creates a KongController; if 'checkPresence' is false, doesn't check (on the gateway) that
+// the reverseproxy is installed
+func newKongController(svc iaas.Service, network *resources.Network, addressPrimaryGateway bool, checkPresence bool) (*KongController, error) {
 	if svc == nil {
 		return nil, scerr.InvalidParameterError("svc", "cannot be nil")
 	}
@@ -80,41 +82,16 @@ func NewKongController(svc iaas.Service, network *resources.Network, addressPrim
 	if err != nil {
 		return nil, err
 	}
-	var (
-		addressedGateway *resources.Host
-	)
-	if addressPrimaryGateway {
-		mh, err := metadata.LoadHost(svc, network.GatewayID)
-		if err != nil {
-			return nil, err
-		}
-		addressedGateway, err = mh.Get()
-		if err != nil {
-			return nil, err
-		}
-		if addressedGateway == nil {
-			return nil, fmt.Errorf("error getting data of primary gateway")
-		}
-	} else {
-		if network.SecondaryGatewayID == "" {
-			return nil, fmt.Errorf("cannot address secondary gateway, doesn't exist")
-		}
-		mh, err := metadata.LoadHost(svc, network.SecondaryGatewayID)
-		if err != nil {
-			return nil, err
-		}
-		addressedGateway, err = mh.Get()
-		if err != nil {
-			return nil, err
-		}
-
-		if addressedGateway == nil {
-			return nil, fmt.Errorf("error getting data of secondary gateway")
-		}
+	gw, err := loadProxyGateway(svc, network, addressPrimaryGateway)
+	if err != nil {
+		return nil, err
 	}
+	addressedGateway := gw.gateway
 
 	present := false
-	if anon, ok := kongProxyCheckedCache.Get(network.Name); ok {
+	if !checkPresence {
+		present = true
+	} else if anon, ok := kongProxyCheckedCache.Get(network.Name); ok {
 		present = anon.(bool)
 	} else {
 		setErr := kongProxyCheckedCache.SetBy(network.Name, func() (interface{}, error) {
@@ -142,47 +119,44 @@ func NewKongController(svc iaas.Service, network *resources.Network, addressPrim
 	}
 
 	ctrl := KongController{
-		network: network,
+		proxyGateway: *gw,
 		// host:      host,
-		safescale:        safescale.New(),
-		gateway:          addressedGateway,
-		gatewayPrivateIP: addressedGateway.GetPrivateIP(),
-		gatewayPublicIP:  addressedGateway.GetPublicIP(),
+		safescale: safescale.New(),
 	}
 
 	return &ctrl, nil
 }
 
-// Apply applies the rule to Kong proxy
+// Kind returns the kind of reverse proxy controlled
+func (k *KongController) Kind() string {
+	return ReverseProxyKong
+}
+
+// Apply applies the rule published by the feature to Kong proxy, then records it in network metadata
 // Currently, support rule types service, route and upstream
 // Returns rule name and error
-func (k *KongController) Apply(rule map[interface{}]interface{}, values *Variables) (string, error) {
-	ruleType := rule["type"].(string)
-
-	ruleName, err := k.realizeRuleData(strings.Trim(rule["name"].(string), "\n"), *values)
+func (k *KongController) Apply(feature string, rule map[interface{}]interface{}, values *Variables) (string, error) {
+	ruleType, ruleName, content, err := k.realizeRule(rule, values)
 	if err != nil {
-		return rule["name"].(string), err
+		return ruleName, err
 	}
-	content, err := k.realizeRuleData(strings.Trim(rule["content"].(string), "\n"), *values)
+	err = k.submit(ruleType, ruleName, content, values)
 	if err != nil {
 		return ruleName, err
 	}
-
-	var sourceControl map[string]interface{}
-
-	// Sets the values usable in all cases
-	if k.network.VIP != nil {
-		// VPL: for now, no public IP on VIP, so uses the IP of the first Gateway
-		// (*values)["EndpointIP"] = k.network.VIP.PublicIP
-		(*values)["EndpointIP"] = k.gatewayPublicIP
-		(*values)["DefaultRouteIP"] = k.network.VIP.PrivateIP
-	} else {
-		(*values)["EndpointIP"] = k.gatewayPublicIP
-		(*values)["DefaultRouteIP"] = k.gatewayPrivateIP
+	err = recordProxyRule(k.svc, k.network.ID, k.newProxyRule(ReverseProxyKong, feature, ruleType, ruleName, content, values))
+	if err != nil {
+		return ruleName, fmt.Errorf("failed to record proxy rule '%s' in metadata of network '%s': %s", ruleName, k.network.Name, err.Error())
 	}
-	// Legacy...
-	(*values)["PublicIP"] = (*values)["EndpointIP"]
-	(*values)["GatewayIP"] = (*values)["DefaultRouteIP"]
+	return ruleName, nil
+}
+
+// submit submits the rule to Kong
+func (k *KongController) submit(ruleType, ruleName, content string, values *Variables) error {
+	var (
+		err           error
+		sourceControl map[string]interface{}
+	)
 
 	// Analyzes the rule...
 	switch ruleType {
@@ -190,7 +164,7 @@ func (k *KongController) Apply(rule map[interface{}]interface{}, values *Variabl
 		unjsoned := map[string]interface{}{}
 		err = json.Unmarshal([]byte(content), &unjsoned)
 		if err != nil {
-			return ruleName, fmt.Errorf("syntax error in rule '%s': %s", ruleName, err.Error())
+			return fmt.Errorf("syntax error in rule '%s': %s", ruleName, err.Error())
 		}
 		if _, ok := unjsoned["source-control"]; ok {
 			sourceControl = unjsoned["source-control"].(map[string]interface{})
@@ -205,16 +179,16 @@ func (k *KongController) Apply(rule map[interface{}]interface{}, values *Variabl
 		url := "services/" + ruleName
 		response, _, err := k.put(ruleName, url, content, values, true)
 		if err != nil {
-			return ruleName, fmt.Errorf("failed to apply proxy rule '%s': %s", ruleName, err.Error())
+			return fmt.Errorf("failed to apply proxy rule '%s': %s", ruleName, err.Error())
 		}
 		logrus.Debugf("successfully applied proxy rule '%s': %v", ruleName, content)
-		return ruleName, k.addSourceControl(ruleName, url, ruleType, response["id"].(string), sourceControl, values)
+		return k.addSourceControl(ruleName, url, ruleType, response["id"].(string), sourceControl, values)
 
 	case "route":
 		unjsoned := map[string]interface{}{}
 		err = json.Unmarshal([]byte(content), &unjsoned)
 		if err != nil {
-			return ruleName, fmt.Errorf("syntax error in rule '%s': %s", ruleName, err.Error())
+			return fmt.Errorf("syntax error in rule '%s': %s", ruleName, err.Error())
 		}
 		if _, ok := unjsoned["source-control"]; ok {
 			sourceControl = unjsoned["source-control"].(map[string]interface{})
@@ -229,17 +203,17 @@ func (k *KongController) Apply(rule map[interface{}]interface{}, values *Variabl
 		url := "routes/" + ruleName
 		response, _, err := k.put(ruleName, url, content, values, true)
 		if err != nil {
-			return ruleName, fmt.Errorf("failed to apply proxy rule '%s': %s", ruleName, err.Error())
+			return fmt.Errorf("failed to apply proxy rule '%s': %s", ruleName, err.Error())
 		}
 		logrus.Debugf("successfully applied proxy rule '%s': %v", ruleName, content)
-		return ruleName, k.addSourceControl(ruleName, url, ruleType, response["id"].(string), sourceControl, values)
+		return k.addSourceControl(ruleName, url, ruleType, response["id"].(string), sourceControl, values)
 
 	case "upstream":
 		// Separate upstream options from target settings
 		unjsoned := data.Map{}
 		err = json.Unmarshal([]byte(content), &unjsoned)
 		if err != nil {
-			return ruleName, fmt.Errorf("syntax error in rule '%s': %s", ruleName, err.Error())
+			return fmt.Errorf("syntax error in rule '%s': %s", ruleName, err.Error())
 		}
 		options := data.Map{}
 		target := data.Map{}
@@ -257,7 +231,7 @@ func (k *KongController) Apply(rule map[interface{}]interface{}, values *Variabl
 		// if create {
 		err = k.createUpstream(ruleName, options, values)
 		if err != nil {
-			return ruleName, err
+			return err
 		}
 		// }
 
@@ -267,27 +241,48 @@ func (k *KongController) Apply(rule map[interface{}]interface{}, values *Variabl
 		url := "upstreams/" + ruleName + "/targets"
 		_, _, err = k.post(ruleName, url, content, values, false)
 		if err != nil {
-			return ruleName, fmt.Errorf("failed to apply proxy rule '%s': %s", ruleName, err.Error())
+			return fmt.Errorf("failed to apply proxy rule '%s': %s", ruleName, err.Error())
 		}
 		logrus.Debugf("successfully applied proxy rule '%s': %v", ruleName, content)
-		return ruleName, nil
+		return nil
 
 	default:
-		return ruleName, fmt.Errorf("syntax error in rule '%s': '%s' isn't a valid type", ruleName, ruleType)
+		return fmt.Errorf("syntax error in rule '%s': '%s' isn't a valid type", ruleName, ruleType)
 	}
 }
 
-func (k *KongController) realizeRuleData(content string, v Variables) (string, error) {
-	contentTmpl, err := template.New("proxy_content").Parse(content)
-	if err != nil {
-		return "", fmt.Errorf("error preparing rule: %s", err.Error())
+// Render returns the type, the name and the content of the rule as they would be submitted by Apply, without
+// submitting anything to Kong
+func (k *KongController) Render(rule map[interface{}]interface{}, values *Variables) (string, string, string, error) {
+	return k.realizeRule(rule, values)
+}
+
+// Delete removes the rule from Kong proxy, then from network metadata
+func (k *KongController) Delete(rule *propsv1.NetworkProxyRule) error {
+	var url string
+	switch rule.Type {
+	case "service":
+		url = "services/" + rule.Name
+	case "route":
+		url = "routes/" + rule.Name
+	case "upstream":
+		// Each host has added its own target to the upstream
+		target := map[string]interface{}{}
+		err := json.Unmarshal([]byte(rule.Content), &target)
+		if err != nil {
+			return fmt.Errorf("syntax error in rule '%s': %s", rule.Name, err.Error())
+		}
+		url = fmt.Sprintf("upstreams/%s/targets/%v", rule.Name, target["target"])
+	default:
+		return fmt.Errorf("syntax error in rule '%s': '%s' isn't a valid type", rule.Name, rule.Type)
 	}
-	dataBuffer := bytes.NewBufferString("")
-	err = contentTmpl.Execute(dataBuffer, v)
+	_, err := k.delete(rule.Name, url)
 	if err != nil {
-		return "", err
+		if _, ok := err.(scerr.ErrNotFound); !ok {
+			return err
+		}
 	}
-	return dataBuffer.String(), nil
+	return forgetProxyRule(k.svc, k.network.ID, rule)
 }
 
 func (k *KongController) createUpstream(name string, options data.Map, v *Variables) error {
@@ -448,6 +443,31 @@ func (k *KongController) patch(name, url, data string, v *Variables, propagate b
 	return response, httpcode, nil
 }
 
+// delete removes a rule
+func (k *KongController) delete(name, url string) (string, error) {
+	cmd := fmt.Sprintf(curlDelete, url)
+	retcode, stdout, stderr, err := safescale.New().SSH.Run(k.gateway.Name, cmd, outputs.COLLECT, temporal.GetConnectionTimeout(), temporal.GetExecutionTimeout())
+	if err != nil {
+		return "", err
+	}
+	if retcode != 0 {
+		logrus.Debugf("deletion of rule '%s' failed: retcode=%d, stdout=>>%s<<, stderr=>>%s<<", name, retcode, stdout, stderr)
+		return "", fmt.Errorf("deletion of rule '%s' failed: retcode=%d", name, retcode)
+	}
+
+	// Kong answers to a successful deletion with an empty body
+	output := strings.Split(strings.TrimSpace(stdout), "\n")
+	httpcode := output[len(output)-1]
+	switch httpcode {
+	case "200", "204":
+		return httpcode, nil
+	case "404":
+		return httpcode, scerr.NotFoundError(fmt.Sprintf("rule '%s' not found", name))
+	default:
+		return httpcode, fmt.Errorf("deletion of rule '%s' failed with HTTP error code '%s'", name, httpcode)
+	}
+}
+
 func (k *KongController) parseResult(result string) (map[string]interface{}, string, error) {
 	output := strings.Split(result, "\n")
 	httpcode := output[1]
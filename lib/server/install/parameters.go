@@ -0,0 +1,380 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package install
+
+import (
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/CS-SI/SafeScale/lib/server/iaas"
+	"github.com/CS-SI/SafeScale/lib/utils/crypt"
+	"github.com/CS-SI/SafeScale/lib/utils/scerr"
+)
+
+const (
+	// ParameterTypeString is the type of a parameter accepting any value (default)
+	ParameterTypeString = "string"
+	// ParameterTypeInt is the type of a parameter accepting an integer
+	ParameterTypeInt = "int"
+	// ParameterTypeFloat is the type of a parameter accepting a decimal number
+	ParameterTypeFloat = "float"
+	// ParameterTypeBool is the type of a parameter accepting a boolean (true/false, yes/no, 1/0)
+	ParameterTypeBool = "bool"
+
+	// maskedValue replaces the value of secret parameters in logs and results
+	maskedValue = "********"
+	// sealedPrefix prefixes the values of secret parameters encrypted in metadata
+	sealedPrefix = "sealed:"
+)
+
+// Parameter describes a parameter of a feature, as declared in the key 'feature.parameters' of the specification file.
+// An item of this list is either a string, '<name>' or '<name>=<default value>', or a map with the keys
+// 'name', 'type', 'description', 'default', 'enum', 'regex', 'required' and 'secret'.
+type Parameter struct {
+	Name        string   `json:"name"`
+	Type        string   `json:"type"`
+	Description string   `json:"description,omitempty"`
+	Default     *string  `json:"default,omitempty"`
+	Enum        []string `json:"enum,omitempty"`
+	Regex       string   `json:"regex,omitempty"`
+	Required    bool     `json:"required"`
+	Secret      bool     `json:"secret,omitempty"`
+
+	regex *regexp.Regexp
+}
+
+// Parameters returns the schema of the parameters of the feature
+func (f *Feature) Parameters() ([]*Parameter, error) {
+	if f == nil {
+		return nil, scerr.InvalidInstanceError()
+	}
+	if !f.specs.IsSet("feature.parameters") {
+		return nil, nil
+	}
+
+	items, ok := f.specs.Get("feature.parameters").([]interface{})
+	if !ok {
+		return nil, scerr.SyntaxError(fmt.Sprintf("feature '%s' specification file (%s): key 'feature.parameters' must be a list", f.DisplayName(), f.DisplayFilename()))
+	}
+	var (
+		list []*Parameter
+		seen = map[string]struct{}{}
+	)
+	for i, item := range items {
+		p, err := parseParameter(item)
+		if err != nil {
+			return nil, scerr.SyntaxError(fmt.Sprintf("feature '%s' specification file (%s): item #%d of 'feature.parameters': %s", f.DisplayName(), f.DisplayFilename(), i+1, err.Error()))
+		}
+		if _, ok := seen[p.Name]; ok {
+			return nil, scerr.SyntaxError(fmt.Sprintf("feature '%s' specification file (%s): parameter '%s' declared more than once", f.DisplayName(), f.DisplayFilename(), p.Name))
+		}
+		seen[p.Name] = struct{}{}
+		list = append(list, p)
+	}
+	return list, nil
+}
+
+// parseParameter converts an item of 'feature.parameters' to Parameter
+func parseParameter(item interface{}) (*Parameter, error) {
+	p := &Parameter{Type: ParameterTypeString}
+
+	var fields map[string]interface{}
+	switch content := item.(type) {
+	case string:
+		splitted := strings.Split(content, "=")
+		p.Name = strings.TrimSpace(splitted[0])
+		if len(splitted) > 1 {
+			value := strings.Join(splitted[1:], "=")
+			p.Default = &value
+		}
+	case map[string]interface{}:
+		fields = content
+	case map[interface{}]interface{}:
+		fields = make(map[string]interface{}, len(content))
+		for k, v := range content {
+			fields[fmt.Sprintf("%v", k)] = v
+		}
+	default:
+		return nil, fmt.Errorf("must be a string or a map")
+	}
+
+	required := p.Default == nil
+	for k, v := range fields {
+		switch strings.ToLower(k) {
+		case "name":
+			p.Name = strings.TrimSpace(fmt.Sprintf("%v", v))
+		case "type":
+			p.Type = strings.ToLower(fmt.Sprintf("%v", v))
+		case "description":
+			p.Description = fmt.Sprintf("%v", v)
+		case "default":
+			value := fmt.Sprintf("%v", v)
+			p.Default = &value
+			if _, ok := fields["required"]; !ok {
+				required = false
+			}
+		case "enum":
+			values, ok := v.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("'enum' must be a list")
+			}
+			for _, e := range values {
+				p.Enum = append(p.Enum, fmt.Sprintf("%v", e))
+			}
+		case "regex":
+			p.Regex = fmt.Sprintf("%v", v)
+		case "required":
+			required = isTrue(v)
+		case "secret":
+			p.Secret = isTrue(v)
+		default:
+			return nil, fmt.Errorf("unknown key '%s'", k)
+		}
+	}
+	p.Required = required
+
+	if p.Name == "" {
+		return nil, fmt.Errorf("missing name")
+	}
+	switch p.Type {
+	case ParameterTypeString, ParameterTypeInt, ParameterTypeFloat, ParameterTypeBool:
+	default:
+		return nil, fmt.Errorf("unknown type '%s' for parameter '%s'", p.Type, p.Name)
+	}
+	if p.Regex != "" {
+		var err error
+		p.regex, err = regexp.Compile("^(?:" + p.Regex + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex for parameter '%s': %s", p.Name, err.Error())
+		}
+	}
+	if p.Default != nil && !isTemplate(*p.Default) && !(*p.Default == "" && !p.Required) {
+		if _, err := p.normalize(*p.Default); err != nil {
+			return nil, fmt.Errorf("invalid default value: %s", err.Error())
+		}
+	}
+	return p, nil
+}
+
+// normalize checks 'value' against the type, the enum and the regex of the parameter, and returns the value in
+// its canonical form
+func (p *Parameter) normalize(value string) (string, error) {
+	switch p.Type {
+	case ParameterTypeInt:
+		i, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err != nil {
+			return "", fmt.Errorf("parameter '%s' must be an integer", p.Name)
+		}
+		value = strconv.FormatInt(i, 10)
+	case ParameterTypeFloat:
+		f, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return "", fmt.Errorf("parameter '%s' must be a number", p.Name)
+		}
+		value = strconv.FormatFloat(f, 'f', -1, 64)
+	case ParameterTypeBool:
+		switch strings.ToLower(strings.TrimSpace(value)) {
+		case "true", "yes", "on", "1":
+			value = "true"
+		case "false", "no", "off", "0":
+			value = "false"
+		default:
+			return "", fmt.Errorf("parameter '%s' must be a boolean", p.Name)
+		}
+	}
+	if len(p.Enum) > 0 {
+		found := false
+		for _, e := range p.Enum {
+			if e == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return "", fmt.Errorf("parameter '%s' must be one of '%s'", p.Name, strings.Join(p.Enum, "', '"))
+		}
+	}
+	if p.regex != nil && !p.regex.MatchString(value) {
+		return "", fmt.Errorf("parameter '%s' must match '%s'", p.Name, p.Regex)
+	}
+	return value, nil
+}
+
+// validateParameters checks the values of the parameters of the feature against its schema, and sets the default
+// value of the parameters without value; all the problems found are reported at once, before any step is run
+func validateParameters(f *Feature, v Variables) error {
+	params, err := f.Parameters()
+	if err != nil {
+		return err
+	}
+
+	var errs []string
+	for _, p := range params {
+		raw, ok := v[p.Name]
+		if !ok {
+			switch {
+			case p.Default != nil:
+				v[p.Name] = *p.Default
+			case p.Required:
+				errs = append(errs, fmt.Sprintf("missing value for parameter '%s'", p.Name))
+			default:
+				v[p.Name] = ""
+			}
+			continue
+		}
+
+		value := fmt.Sprintf("%v", raw)
+		// Templates are realized later, their result cannot be checked here
+		if isTemplate(value) || (value == "" && !p.Required) {
+			continue
+		}
+		normalized, err := p.normalize(value)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		v[p.Name] = normalized
+	}
+	if len(errs) > 0 {
+		return scerr.InvalidRequestError(fmt.Sprintf("invalid parameters for feature '%s': %s", f.DisplayName(), strings.Join(errs, "; ")))
+	}
+	return nil
+}
+
+// implicitSecrets lists the implicit parameters (set from the target) containing secrets
+var implicitSecrets = []string{"ClusterAdminPassword"}
+
+// secretValues returns the non-empty values of the secret parameters of the feature and of the implicit secrets
+// found in 'v'
+func (f *Feature) secretValues(v Variables) []string {
+	names := append([]string{}, implicitSecrets...)
+	params, err := f.Parameters()
+	if err == nil {
+		for _, p := range params {
+			if p.Secret {
+				names = append(names, p.Name)
+			}
+		}
+	}
+	var out []string
+	for _, name := range names {
+		if value, ok := v[name]; ok {
+			if s := fmt.Sprintf("%v", value); s != "" {
+				out = append(out, s)
+			}
+		}
+	}
+	// Longest first, in case a secret contains another one
+	sort.Slice(out, func(i, j int) bool { return len(out[i]) > len(out[j]) })
+	return out
+}
+
+// maskSecrets replaces in 'text' the values of secrets by a mask
+func maskSecrets(text string, secrets []string) string {
+	for _, s := range secrets {
+		text = strings.Replace(text, s, maskedValue, -1)
+	}
+	return text
+}
+
+// SealParameters returns the values of the parameters to record in metadata, converted to string; the values of
+// secret parameters are encrypted with the metadata key of the tenant, or left out if the tenant has no metadata key
+func (f *Feature) SealParameters(svc iaas.Service, v Variables) map[string]string {
+	out := v.Strings()
+	params, err := f.Parameters()
+	if err != nil {
+		return out
+	}
+
+	var key *crypt.Key
+	if svc != nil {
+		key = svc.GetMetadataKey()
+	}
+	for _, p := range params {
+		value, ok := out[p.Name]
+		if !ok || !p.Secret {
+			continue
+		}
+		if key == nil {
+			logrus.Warnf("no metadata key defined for the tenant, secret parameter '%s' of feature '%s' not recorded", p.Name, f.DisplayName())
+			delete(out, p.Name)
+			continue
+		}
+		encrypted, err := crypt.Encrypt([]byte(value), key)
+		if err != nil {
+			logrus.Warnf("failed to encrypt secret parameter '%s' of feature '%s', not recorded: %v", p.Name, f.DisplayName(), err)
+			delete(out, p.Name)
+			continue
+		}
+		out[p.Name] = sealedPrefix + base64.StdEncoding.EncodeToString(encrypted)
+	}
+	return out
+}
+
+// UnsealParameters returns the values of parameters recorded in metadata, with the values sealed by SealParameters
+// decrypted
+func UnsealParameters(svc iaas.Service, params map[string]string) (map[string]string, error) {
+	out := make(map[string]string, len(params))
+	for k, v := range params {
+		if !strings.HasPrefix(v, sealedPrefix) {
+			out[k] = v
+			continue
+		}
+		var key *crypt.Key
+		if svc != nil {
+			key = svc.GetMetadataKey()
+		}
+		if key == nil {
+			return nil, fmt.Errorf("cannot decrypt parameter '%s': no metadata key defined for the tenant", k)
+		}
+		encrypted, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(v, sealedPrefix))
+		if err != nil {
+			return nil, fmt.Errorf("cannot decrypt parameter '%s': %s", k, err.Error())
+		}
+		decrypted, err := crypt.Decrypt(encrypted, key)
+		if err != nil {
+			return nil, fmt.Errorf("cannot decrypt parameter '%s': %s", k, err.Error())
+		}
+		out[k] = string(decrypted)
+	}
+	return out, nil
+}
+
+// isTemplate tells if 'value' contains a template, realized when steps are run
+func isTemplate(value string) bool {
+	return strings.Contains(value, "{{")
+}
+
+// isTrue tells if the value of a YAML key means true
+func isTrue(v interface{}) bool {
+	switch value := v.(type) {
+	case bool:
+		return value
+	default:
+		switch strings.ToLower(fmt.Sprintf("%v", value)) {
+		case "true", "yes", "on", "1":
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,104 @@
+package install
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestFeature(t *testing.T, content string) *Feature {
+	specs := viper.New()
+	specs.SetConfigType("yaml")
+	require.NoError(t, specs.ReadConfig(bytes.NewBufferString(content)))
+	return &Feature{displayName: "test", fileName: "test.yml", specs: specs}
+}
+
+const testParametersSpecs = `
+feature:
+    parameters:
+        - Username
+        - RootURL=/app/
+        - name: Port
+          type: int
+          default: 8080
+        - name: Mode
+          enum: [standalone, cluster]
+          default: standalone
+        - name: Password
+          secret: yes
+          regex: "[a-zA-Z0-9]{8,}"
+        - name: Debug
+          type: bool
+          required: no
+`
+
+func TestFeature_Parameters(t *testing.T) {
+	f := newTestFeature(t, testParametersSpecs)
+	params, err := f.Parameters()
+	require.NoError(t, err)
+	require.Equal(t, 6, len(params))
+
+	assert.Equal(t, "Username", params[0].Name)
+	assert.True(t, params[0].Required)
+	assert.Nil(t, params[0].Default)
+
+	assert.False(t, params[1].Required)
+	assert.Equal(t, "/app/", *params[1].Default)
+
+	assert.Equal(t, ParameterTypeInt, params[2].Type)
+	assert.Equal(t, "8080", *params[2].Default)
+	assert.True(t, params[4].Secret)
+	assert.False(t, params[5].Required)
+
+	f = newTestFeature(t, "feature:\n    parameters:\n        - name: Port\n          type: int\n          default: http\n")
+	_, err = f.Parameters()
+	assert.Error(t, err)
+
+	f = newTestFeature(t, "feature:\n    parameters:\n        - name: Port\n          type: integer\n")
+	_, err = f.Parameters()
+	assert.Error(t, err)
+}
+
+func TestValidateParameters(t *testing.T) {
+	f := newTestFeature(t, testParametersSpecs)
+
+	v := Variables{"Username": "admin", "Password": "s3cr3tPassw0rd", "Debug": "yes"}
+	require.NoError(t, validateParameters(f, v))
+	assert.Equal(t, "/app/", v["RootURL"])
+	assert.Equal(t, "8080", v["Port"])
+	assert.Equal(t, "standalone", v["Mode"])
+	assert.Equal(t, "true", v["Debug"])
+
+	// All the problems are reported at once
+	v = Variables{"Port": "http", "Mode": "ha", "Password": "short"}
+	err := validateParameters(f, v)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "'Username'")
+	assert.Contains(t, err.Error(), "'Port'")
+	assert.Contains(t, err.Error(), "'Mode'")
+	assert.Contains(t, err.Error(), "'Password'")
+
+	// Templates are not checked
+	v = Variables{"Username": "admin", "Password": "s3cr3tPassw0rd", "Port": "{{ .DefaultPort }}"}
+	assert.NoError(t, validateParameters(f, v))
+}
+
+func TestMaskSecrets(t *testing.T) {
+	f := newTestFeature(t, testParametersSpecs)
+	secrets := f.secretValues(Variables{"Username": "admin", "Password": "s3cr3tPassw0rd"})
+	assert.Equal(t, []string{"s3cr3tPassw0rd"}, secrets)
+	assert.Equal(t, "echo -n "+maskedValue+" | docker secret create", maskSecrets("echo -n s3cr3tPassw0rd | docker secret create", secrets))
+}
+
+func TestUnsealParameters(t *testing.T) {
+	// Values not sealed are returned as is, and sealed values cannot be decrypted without key
+	params, err := UnsealParameters(nil, map[string]string{"Port": "8080"})
+	require.NoError(t, err)
+	assert.Equal(t, "8080", params["Port"])
+
+	_, err = UnsealParameters(nil, map[string]string{"Password": sealedPrefix + "AAAA"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,202 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package repository
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/CS-SI/SafeScale/lib/client"
+	"github.com/CS-SI/SafeScale/lib/server/iaas"
+	"github.com/CS-SI/SafeScale/lib/utils/scerr"
+	"github.com/CS-SI/SafeScale/lib/utils/temporal"
+)
+
+// maxFileSize is the maximum size of a file read from a repository
+const maxFileSize = 10 * 1024 * 1024
+
+// fetcher reads the files of a repository
+type fetcher interface {
+	// Fetch returns the content of the file 'path', relative to the root of the repository
+	Fetch(path string) ([]byte, error)
+	// Close releases the resources used by the fetcher
+	Close() error
+}
+
+func newFetcher(r *Repository) (fetcher, error) {
+	switch r.Kind {
+	case KindHTTPS:
+		return &httpsFetcher{
+			baseURL: strings.TrimSuffix(r.URL, "/"),
+			client:  &http.Client{Timeout: temporal.GetExecutionTimeout()},
+		}, nil
+	case KindBucket:
+		return newBucketFetcher(r.URL)
+	case KindGit:
+		return newGitFetcher(r.URL, r.Ref)
+	}
+	return nil, scerr.InvalidParameterError("r.Kind", fmt.Sprintf("unknown kind '%s'", r.Kind))
+}
+
+// httpsFetcher reads the files of a repository served by a web server
+type httpsFetcher struct {
+	baseURL string
+	client  *http.Client
+}
+
+func (f *httpsFetcher) Fetch(path string) ([]byte, error) {
+	resp, err := f.client.Get(f.baseURL + "/" + path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, scerr.NotFoundError(fmt.Sprintf("'%s' not found", path))
+	default:
+		return nil, fmt.Errorf("failed to get '%s': %s", path, resp.Status)
+	}
+	return readAll(resp.Body)
+}
+
+func (f *httpsFetcher) Close() error {
+	return nil
+}
+
+// bucketFetcher reads the files of a repository stored in a bucket of the Object Storage of the current tenant
+type bucketFetcher struct {
+	svc    iaas.Service
+	bucket string
+	prefix string
+}
+
+func newBucketFetcher(rawURL string) (*bucketFetcher, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "bucket" || u.Host == "" {
+		return nil, scerr.InvalidParameterError("url", "must be in format bucket://<bucket name>[/<path>]")
+	}
+	tenant, err := client.New().Tenant.Get(temporal.GetExecutionTimeout())
+	if err != nil {
+		return nil, err
+	}
+	svc, err := iaas.UseService(tenant.Name)
+	if err != nil {
+		return nil, err
+	}
+	return &bucketFetcher{
+		svc:    svc,
+		bucket: u.Host,
+		prefix: strings.Trim(u.Path, "/"),
+	}, nil
+}
+
+func (f *bucketFetcher) Fetch(name string) ([]byte, error) {
+	var buffer bytes.Buffer
+	err := f.svc.ReadObject(f.bucket, path.Join(f.prefix, name), &buffer, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	if buffer.Len() > maxFileSize {
+		return nil, fmt.Errorf("'%s' is bigger than %d bytes", name, maxFileSize)
+	}
+	return buffer.Bytes(), nil
+}
+
+func (f *bucketFetcher) Close() error {
+	return nil
+}
+
+// gitFetcher reads the files of a repository from a shallow clone of a git repository
+type gitFetcher struct {
+	dir string
+}
+
+func newGitFetcher(url, ref string) (*gitFetcher, error) {
+	dir, err := ioutil.TempDir("", "safescale-features-")
+	if err != nil {
+		return nil, err
+	}
+	args := []string{"clone", "--quiet", "--depth", "1"}
+	if ref != "" {
+		args = append(args, "--branch", ref)
+	}
+	args = append(args, "--", url, dir)
+	cmd := exec.Command("git", args...)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	done := make(chan error, 1)
+	err = cmd.Start()
+	if err == nil {
+		go func() { done <- cmd.Wait() }()
+		select {
+		case err = <-done:
+		case <-time.After(temporal.GetLongOperationTimeout()):
+			_ = cmd.Process.Kill()
+			err = fmt.Errorf("timeout")
+		}
+	}
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, fmt.Errorf("failed to clone '%s': %s %s", url, err.Error(), strings.TrimSpace(stderr.String()))
+	}
+	return &gitFetcher{dir: dir}, nil
+}
+
+func (f *gitFetcher) Fetch(name string) ([]byte, error) {
+	file, err := os.Open(filepath.Join(f.dir, filepath.FromSlash(path.Clean("/"+name))))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, scerr.NotFoundError(fmt.Sprintf("'%s' not found", name))
+		}
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+	return readAll(file)
+}
+
+func (f *gitFetcher) Close() error {
+	return os.RemoveAll(f.dir)
+}
+
+// readAll reads the content of 'r', up to maxFileSize bytes
+func readAll(r io.Reader) ([]byte, error) {
+	data, err := ioutil.ReadAll(io.LimitReader(r, maxFileSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxFileSize {
+		return nil, fmt.Errorf("file is bigger than %d bytes", maxFileSize)
+	}
+	return data, nil
+}
@@ -0,0 +1,532 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package repository manages the remote repositories of features.
+//
+// A repository contains a file 'index.json' listing the features it provides:
+//
+//	{"features": [{"name": "docker", "version": "19.03", "file": "docker/19.03.yml", "sha256": "<hex digest>"}]}
+//
+// If a public key is associated to the repository, the index must be signed: the file 'index.json.sig'
+// contains the Ed25519 signature of 'index.json', encoded in base64. The content of the repositories is
+// copied in a local cache by Update, after verification of the signature and of the checksums; the
+// verifications are done again each time a feature is read from the cache.
+package repository
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/ed25519"
+
+	"github.com/CS-SI/SafeScale/lib/utils"
+	"github.com/CS-SI/SafeScale/lib/utils/scerr"
+)
+
+const (
+	// KindGit designates a repository in a git repository
+	KindGit = "git"
+	// KindHTTPS designates a repository served by a web server
+	KindHTTPS = "https"
+	// KindBucket designates a repository in a bucket of the Object Storage of the current tenant
+	KindBucket = "bucket"
+
+	indexFileName     = "index.json"
+	signatureFileName = "index.json.sig"
+)
+
+var (
+	// configFile is the file containing the list of repositories
+	configFile = "$HOME/.safescale/feature-repositories.json"
+	// cacheDir is the folder containing the local copies of the repositories
+	cacheDir = "$HOME/.safescale/cache/features"
+
+	nameRegexp = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+)
+
+// Repository describes a repository of features
+type Repository struct {
+	Name      string    `json:"name"`                 // Name of the repository
+	Kind      string    `json:"kind"`                 // Kind of the repository: git, https or bucket
+	URL       string    `json:"url"`                  // URL of the repository (bucket://<bucket>/<path> for kind bucket)
+	Ref       string    `json:"ref,omitempty"`        // Ref is the branch or tag to use (kind git only)
+	PublicKey string    `json:"public_key,omitempty"` // PublicKey is the Ed25519 key verifying the index, encoded in base64
+	UpdatedAt time.Time `json:"updated_at,omitempty"` // UpdatedAt is the date of the last update of the cache
+}
+
+// Entry describes a version of a feature provided by a repository
+type Entry struct {
+	Name        string `json:"name"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+	File        string `json:"file"`                 // File is the path of the specification file, relative to the root of the repository
+	SHA256      string `json:"sha256"`               // SHA256 is the checksum of the specification file, in hexadecimal
+	Repository  string `json:"repository,omitempty"` // Repository is the name of the repository providing the entry (not stored in index)
+}
+
+// Index is the content of the file 'index.json' of a repository
+type Index struct {
+	Features []Entry `json:"features"`
+}
+
+// GuessKind returns the kind of repository corresponding to the URL
+func GuessKind(url string) string {
+	switch {
+	case strings.HasPrefix(url, "bucket://"):
+		return KindBucket
+	case strings.HasPrefix(url, "git@"), strings.HasPrefix(url, "git://"), strings.HasPrefix(url, "ssh://"), strings.HasSuffix(url, ".git"):
+		return KindGit
+	case strings.HasPrefix(url, "https://"), strings.HasPrefix(url, "http://"):
+		return KindHTTPS
+	}
+	return ""
+}
+
+// Validate checks the content of the repository
+func (r *Repository) Validate() error {
+	if r == nil {
+		return scerr.InvalidInstanceError()
+	}
+	if !nameRegexp.MatchString(r.Name) {
+		return scerr.InvalidParameterError("Name", fmt.Sprintf("'%s' is not a valid repository name", r.Name))
+	}
+	if r.URL == "" {
+		return scerr.InvalidParameterError("URL", "cannot be empty string")
+	}
+	switch r.Kind {
+	case KindGit, KindHTTPS, KindBucket:
+	default:
+		return scerr.InvalidParameterError("Kind", fmt.Sprintf("unknown kind '%s' (must be git, https or bucket)", r.Kind))
+	}
+	if r.Ref != "" && r.Kind != KindGit {
+		return scerr.InvalidParameterError("Ref", "can only be used with repositories of kind git")
+	}
+	if r.PublicKey != "" {
+		if _, err := decodePublicKey(r.PublicKey); err != nil {
+			return scerr.InvalidParameterError("PublicKey", err.Error())
+		}
+	}
+	return nil
+}
+
+// List returns the repositories configured, in the order they are searched
+func List() ([]*Repository, error) {
+	content, err := ioutil.ReadFile(utils.AbsPathify(configFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var list []*Repository
+	err = json.Unmarshal(content, &list)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode '%s': %s", configFile, err.Error())
+	}
+	return list, nil
+}
+
+func save(list []*Repository) error {
+	content, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := utils.AbsPathify(configFile)
+	err = os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, content, 0600)
+}
+
+// Add adds a repository at the end of the list of repositories
+func Add(r *Repository) error {
+	if r != nil && r.Kind == "" {
+		r.Kind = GuessKind(r.URL)
+	}
+	err := r.Validate()
+	if err != nil {
+		return err
+	}
+	list, err := List()
+	if err != nil {
+		return err
+	}
+	for _, v := range list {
+		if v.Name == r.Name {
+			return scerr.DuplicateError(fmt.Sprintf("a feature repository named '%s' already exists", r.Name))
+		}
+	}
+	if r.Kind == KindHTTPS && strings.HasPrefix(r.URL, "http://") && r.PublicKey == "" {
+		logrus.Warnf("feature repository '%s' is reached without TLS and without signature: its content cannot be trusted", r.Name)
+	}
+	return save(append(list, r))
+}
+
+// Remove removes a repository and its cache
+func Remove(name string) error {
+	list, err := List()
+	if err != nil {
+		return err
+	}
+	for i, v := range list {
+		if v.Name == name {
+			err = save(append(list[:i], list[i+1:]...))
+			if err != nil {
+				return err
+			}
+			return os.RemoveAll(cachePath(name))
+		}
+	}
+	return scerr.NotFoundError(fmt.Sprintf("failed to find a feature repository named '%s'", name))
+}
+
+// Update refreshes the cache of the repository 'name' and returns its new index
+func Update(name string) (*Index, error) {
+	list, err := List()
+	if err != nil {
+		return nil, err
+	}
+	var r *Repository
+	for _, v := range list {
+		if v.Name == name {
+			r = v
+			break
+		}
+	}
+	if r == nil {
+		return nil, scerr.NotFoundError(fmt.Sprintf("failed to find a feature repository named '%s'", name))
+	}
+
+	f, err := newFetcher(r)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if derr := f.Close(); derr != nil {
+			logrus.Warnf("failed to clean up after fetching feature repository '%s': %v", r.Name, derr)
+		}
+	}()
+
+	content, err := f.Fetch(indexFileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch index of feature repository '%s': %s", r.Name, err.Error())
+	}
+	var signature []byte
+	if r.PublicKey != "" {
+		signature, err = f.Fetch(signatureFileName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch signature of the index of feature repository '%s': %s", r.Name, err.Error())
+		}
+	}
+	index, err := decodeIndex(r, content, signature)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fills a new cache aside, and replaces the current one only when everything has been verified
+	final := cachePath(r.Name)
+	staging := final + ".new"
+	err = os.RemoveAll(staging)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = os.RemoveAll(staging) }()
+
+	for _, e := range index.Features {
+		data, err := f.Fetch(e.File)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch feature '%s@%s' from repository '%s': %s", e.Name, e.Version, r.Name, err.Error())
+		}
+		err = verifyChecksum(&e, data)
+		if err != nil {
+			return nil, err
+		}
+		err = writeFile(filepath.Join(staging, filepath.FromSlash(e.File)), data)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = writeFile(filepath.Join(staging, indexFileName), content)
+	if err != nil {
+		return nil, err
+	}
+	if signature != nil {
+		err = writeFile(filepath.Join(staging, signatureFileName), signature)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = os.RemoveAll(final)
+	if err != nil {
+		return nil, err
+	}
+	err = os.Rename(staging, final)
+	if err != nil {
+		return nil, err
+	}
+
+	r.UpdatedAt = time.Now()
+	err = save(list)
+	if err != nil {
+		return nil, err
+	}
+	return index, nil
+}
+
+// Find looks for the feature 'name' in the caches of the repositories, in their order, and returns its entry and
+// the content of its specification file.
+// If 'version' is empty, the highest version found in the first repository providing the feature is returned.
+func Find(name, version string) (*Entry, []byte, error) {
+	list, err := List()
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, r := range list {
+		index, err := readCachedIndex(r)
+		if err != nil {
+			logrus.Warnf("ignoring feature repository '%s': %v", r.Name, err)
+			continue
+		}
+		if index == nil {
+			continue
+		}
+		var found *Entry
+		for i, e := range index.Features {
+			if e.Name != name {
+				continue
+			}
+			if version != "" {
+				if e.Version == version {
+					found = &index.Features[i]
+					break
+				}
+				continue
+			}
+			if found == nil || CompareVersions(e.Version, found.Version) > 0 {
+				found = &index.Features[i]
+			}
+		}
+		if found == nil {
+			continue
+		}
+
+		data, err := ioutil.ReadFile(filepath.Join(cachePath(r.Name), filepath.FromSlash(found.File)))
+		if err != nil {
+			return nil, nil, err
+		}
+		err = verifyChecksum(found, data)
+		if err != nil {
+			return nil, nil, err
+		}
+		found.Repository = r.Name
+		return found, data, nil
+	}
+
+	ref := name
+	if version != "" {
+		ref += "@" + version
+	}
+	return nil, nil, scerr.NotFoundError(fmt.Sprintf("failed to find feature '%s' in feature repositories", ref))
+}
+
+// Search returns the entries of the repositories whose name or description contains 'pattern' (all entries if
+// 'pattern' is empty)
+func Search(pattern string) ([]Entry, error) {
+	list, err := List()
+	if err != nil {
+		return nil, err
+	}
+	pattern = strings.ToLower(pattern)
+	var out []Entry
+	for _, r := range list {
+		index, err := readCachedIndex(r)
+		if err != nil {
+			logrus.Warnf("ignoring feature repository '%s': %v", r.Name, err)
+			continue
+		}
+		if index == nil {
+			continue
+		}
+		for _, e := range index.Features {
+			if pattern == "" || strings.Contains(strings.ToLower(e.Name), pattern) || strings.Contains(strings.ToLower(e.Description), pattern) {
+				e.Repository = r.Name
+				out = append(out, e)
+			}
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Name != out[j].Name {
+			return out[i].Name < out[j].Name
+		}
+		return CompareVersions(out[i].Version, out[j].Version) > 0
+	})
+	return out, nil
+}
+
+// SplitReference splits a reference of feature in format <name>[@<version>]
+func SplitReference(ref string) (string, string) {
+	parts := strings.SplitN(ref, "@", 2)
+	if len(parts) == 1 {
+		return parts[0], ""
+	}
+	return parts[0], parts[1]
+}
+
+// CompareVersions compares 2 versions, part by part (separated by '.' or '-'), numerically when possible;
+// returns -1 if a < b, 0 if a == b, 1 if a > b
+func CompareVersions(a, b string) int {
+	split := func(s string) []string {
+		return strings.FieldsFunc(s, func(r rune) bool { return r == '.' || r == '-' })
+	}
+	pa, pb := split(a), split(b)
+	for i := 0; i < len(pa) && i < len(pb); i++ {
+		na, erra := strconv.Atoi(pa[i])
+		nb, errb := strconv.Atoi(pb[i])
+		switch {
+		case erra == nil && errb == nil:
+			if na != nb {
+				if na < nb {
+					return -1
+				}
+				return 1
+			}
+		case pa[i] != pb[i]:
+			if pa[i] < pb[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	switch {
+	case len(pa) < len(pb):
+		return -1
+	case len(pa) > len(pb):
+		return 1
+	}
+	return 0
+}
+
+// cachePath returns the folder containing the copy of the repository 'name'
+func cachePath(name string) string {
+	return filepath.Join(utils.AbsPathify(cacheDir), name)
+}
+
+// readCachedIndex reads and verifies the index in the cache of the repository; returns nil, nil if the
+// repository has not been fetched yet
+func readCachedIndex(r *Repository) (*Index, error) {
+	content, err := ioutil.ReadFile(filepath.Join(cachePath(r.Name), indexFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var signature []byte
+	if r.PublicKey != "" {
+		signature, err = ioutil.ReadFile(filepath.Join(cachePath(r.Name), signatureFileName))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read signature of the index: %s", err.Error())
+		}
+	}
+	return decodeIndex(r, content, signature)
+}
+
+// decodeIndex verifies the signature of the index if the repository has a public key, then decodes and
+// validates it
+func decodeIndex(r *Repository, content, signature []byte) (*Index, error) {
+	if r.PublicKey != "" {
+		err := verifySignature(r.PublicKey, content, signature)
+		if err != nil {
+			return nil, fmt.Errorf("index of feature repository '%s' cannot be trusted: %s", r.Name, err.Error())
+		}
+	}
+	index := &Index{}
+	err := json.Unmarshal(content, index)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode index of feature repository '%s': %s", r.Name, err.Error())
+	}
+	for i, e := range index.Features {
+		if !nameRegexp.MatchString(e.Name) || e.Version == "" || strings.ContainsAny(e.Version, "@/ ") {
+			return nil, scerr.SyntaxError(fmt.Sprintf("entry #%d of index of feature repository '%s' needs a valid 'name' and 'version'", i+1, r.Name))
+		}
+		clean := filepath.ToSlash(filepath.Clean(filepath.FromSlash(e.File)))
+		if e.File == "" || filepath.IsAbs(e.File) || clean == ".." || strings.HasPrefix(clean, "../") || clean == indexFileName || clean == signatureFileName {
+			return nil, scerr.SyntaxError(fmt.Sprintf("entry '%s@%s' of index of feature repository '%s' has an invalid 'file'", e.Name, e.Version, r.Name))
+		}
+		if e.SHA256 == "" {
+			return nil, scerr.SyntaxError(fmt.Sprintf("entry '%s@%s' of index of feature repository '%s' has no 'sha256'", e.Name, e.Version, r.Name))
+		}
+		index.Features[i].File = clean
+	}
+	return index, nil
+}
+
+func decodePublicKey(key string) (ed25519.PublicKey, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(key))
+	if err != nil {
+		return nil, fmt.Errorf("public key is not encoded in base64: %s", err.Error())
+	}
+	if len(raw) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("public key must be an Ed25519 key of %d bytes", ed25519.PublicKeySize)
+	}
+	return ed25519.PublicKey(raw), nil
+}
+
+// verifySignature checks that 'signature' (Ed25519 signature encoded in base64) is the one of 'content'
+func verifySignature(key string, content, signature []byte) error {
+	pubKey, err := decodePublicKey(key)
+	if err != nil {
+		return err
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature)))
+	if err != nil {
+		return fmt.Errorf("signature is not encoded in base64: %s", err.Error())
+	}
+	if !ed25519.Verify(pubKey, content, raw) {
+		return fmt.Errorf("invalid signature")
+	}
+	return nil
+}
+
+// verifyChecksum checks that the SHA-256 checksum of 'data' is the one announced by the entry
+func verifyChecksum(e *Entry, data []byte) error {
+	sum := sha256.Sum256(data)
+	if !strings.EqualFold(hex.EncodeToString(sum[:]), e.SHA256) {
+		return fmt.Errorf("checksum of feature '%s@%s' does not match the one of the index", e.Name, e.Version)
+	}
+	return nil
+}
+
+func writeFile(path string, data []byte) error {
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0600)
+}
@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestCompareVersions(t *testing.T) {
+	assert.Equal(t, 0, CompareVersions("19.03", "19.03"))
+	assert.Equal(t, 1, CompareVersions("19.10", "19.9"))
+	assert.Equal(t, -1, CompareVersions("1.2", "1.2.1"))
+	assert.Equal(t, 1, CompareVersions("2.0-rc2", "2.0-rc1"))
+}
+
+func TestGuessKind(t *testing.T) {
+	assert.Equal(t, KindGit, GuessKind("git@github.com:team/features.git"))
+	assert.Equal(t, KindGit, GuessKind("https://github.com/team/features.git"))
+	assert.Equal(t, KindHTTPS, GuessKind("https://features.example.com/stable"))
+	assert.Equal(t, KindBucket, GuessKind("bucket://features/stable"))
+	assert.Equal(t, "", GuessKind("/tmp/features"))
+}
+
+func TestSplitReference(t *testing.T) {
+	name, version := SplitReference("docker@19.03")
+	assert.Equal(t, "docker", name)
+	assert.Equal(t, "19.03", version)
+
+	name, version = SplitReference("docker")
+	assert.Equal(t, "docker", name)
+	assert.Equal(t, "", version)
+}
+
+func TestUpdateAndFind(t *testing.T) {
+	home, err := ioutil.TempDir("", "repository")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(home) }()
+	configFile = filepath.Join(home, "repositories.json")
+	cacheDir = filepath.Join(home, "cache")
+
+	pubKey, privKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	files := map[string][]byte{
+		"docker/19.03.yml": []byte("feature:\n  suitableFor:\n    host: yes\n"),
+		"docker/20.10.yml": []byte("feature:\n  suitableFor:\n    host: yes\n    cluster: all\n"),
+	}
+	index := Index{}
+	for _, v := range []string{"19.03", "20.10"} {
+		sum := sha256.Sum256(files["docker/"+v+".yml"])
+		index.Features = append(index.Features, Entry{Name: "docker", Version: v, File: "docker/" + v + ".yml", SHA256: hex.EncodeToString(sum[:])})
+	}
+	files[indexFileName], err = json.Marshal(index)
+	require.NoError(t, err)
+	files[signatureFileName] = []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(privKey, files[indexFileName])))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, ok := files[r.URL.Path[1:]]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(content)
+	}))
+	defer server.Close()
+
+	err = Add(&Repository{Name: "team", URL: server.URL, PublicKey: base64.StdEncoding.EncodeToString(pubKey)})
+	require.NoError(t, err)
+	assert.Error(t, Add(&Repository{Name: "team", URL: server.URL}))
+
+	_, err = Update("team")
+	require.NoError(t, err)
+
+	e, content, err := Find("docker", "")
+	require.NoError(t, err)
+	assert.Equal(t, "20.10", e.Version)
+	assert.Equal(t, "team", e.Repository)
+	assert.Equal(t, files["docker/20.10.yml"], content)
+
+	e, _, err = Find("docker", "19.03")
+	require.NoError(t, err)
+	assert.Equal(t, "19.03", e.Version)
+
+	_, _, err = Find("docker", "18.09")
+	assert.Error(t, err)
+
+	entries, err := Search("dock")
+	require.NoError(t, err)
+	assert.Equal(t, 2, len(entries))
+
+	// A file modified in cache is rejected
+	err = ioutil.WriteFile(filepath.Join(cachePath("team"), "docker", "20.10.yml"), []byte("feature: {}\n"), 0600)
+	require.NoError(t, err)
+	_, _, err = Find("docker", "20.10")
+	assert.Error(t, err)
+
+	// An index not matching the signature is rejected, and the cache is kept as is
+	files[indexFileName] = append(files[indexFileName], ' ')
+	_, err = Update("team")
+	assert.Error(t, err)
+	_, _, err = Find("docker", "19.03")
+	assert.NoError(t, err)
+
+	require.NoError(t, Remove("team"))
+	_, err = os.Stat(cachePath("team"))
+	assert.True(t, os.IsNotExist(err))
+}
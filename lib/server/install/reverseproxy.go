@@ -0,0 +1,462 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package install
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/CS-SI/SafeScale/lib/server/iaas"
+	"github.com/CS-SI/SafeScale/lib/server/iaas/resources"
+	"github.com/CS-SI/SafeScale/lib/server/iaas/resources/enums/networkproperty"
+	propsv1 "github.com/CS-SI/SafeScale/lib/server/iaas/resources/properties/v1"
+	"github.com/CS-SI/SafeScale/lib/server/metadata"
+	"github.com/CS-SI/SafeScale/lib/utils/data"
+	"github.com/CS-SI/SafeScale/lib/utils/scerr"
+)
+
+const (
+	// ReverseProxyKong is the kind of reverse proxy installed by the feature 'edgeproxy4network', controlled by its admin API
+	ReverseProxyKong = "kong"
+	// ReverseProxyTraefik is the kind of reverse proxy Traefik, controlled by a file of its file provider
+	ReverseProxyTraefik = "traefik"
+	// ReverseProxyHAProxy is the kind of reverse proxy HAProxy, controlled by its configuration file
+	ReverseProxyHAProxy = "haproxy"
+	// ReverseProxyNginx is the kind of reverse proxy Nginx, controlled by a configuration file
+	ReverseProxyNginx = "nginx"
+)
+
+// ReverseProxyKinds lists the kinds of reverse proxy supported
+var ReverseProxyKinds = []string{ReverseProxyKong, ReverseProxyTraefik, ReverseProxyHAProxy, ReverseProxyNginx}
+
+// proxyRulesLock serializes the updates of the rules recorded in network metadata
+var proxyRulesLock sync.Mutex
+
+// ReverseProxyController allows to control the reverse proxy running on a gateway of a network.
+// Whatever the kind of reverse proxy, the rules have the syntax of the key 'feature.proxy.rules' of the
+// specification files: a type (service, route or upstream), a name and a content in JSON.
+type ReverseProxyController interface {
+	// Kind returns the kind of reverse proxy controlled
+	Kind() string
+	// Gateway returns the gateway running the reverse proxy
+	Gateway() *resources.Host
+	// Apply applies the rule published by the feature; returns the name of the rule
+	Apply(feature string, rule map[interface{}]interface{}, values *Variables) (string, error)
+	// Render returns the type, the name and the content of the rule as they would be submitted by Apply, without
+	// submitting anything
+	Render(rule map[interface{}]interface{}, values *Variables) (string, string, string, error)
+	// Delete removes a rule recorded in network metadata
+	Delete(rule *propsv1.NetworkProxyRule) error
+}
+
+// NewReverseProxyController returns the controller of the reverse proxy of the primary (or secondary) gateway of the
+// network, of the kind selected for the network
+// returns:
+//    ReverseProxyController, nil if successful
+//    nil, scerr.ErrNotFound if reverseproxy is not installed
+//    nil, scerr.ErrNotAvailable if cannot check if reverseproxy is installed
+func NewReverseProxyController(svc iaas.Service, network *resources.Network, addressPrimaryGateway bool) (ReverseProxyController, error) {
+	return newReverseProxyController(svc, network, addressPrimaryGateway, true)
+}
+
+func newReverseProxyController(svc iaas.Service, network *resources.Network, addressPrimaryGateway bool, checkPresence bool) (ReverseProxyController, error) {
+	if svc == nil {
+		return nil, scerr.InvalidParameterError("svc", "cannot be nil")
+	}
+	if network == nil {
+		return nil, scerr.InvalidParameterError("network", "cannot be nil")
+	}
+
+	kind, err := reverseProxyKind(network)
+	if err != nil {
+		return nil, err
+	}
+	switch kind {
+	case ReverseProxyKong:
+		ctrl, err := newKongController(svc, network, addressPrimaryGateway, checkPresence)
+		if err != nil {
+			return nil, err
+		}
+		return ctrl, nil
+	default:
+		ctrl, err := newFileProxyController(svc, network, kind, addressPrimaryGateway, checkPresence)
+		if err != nil {
+			return nil, err
+		}
+		return ctrl, nil
+	}
+}
+
+// proxyGateway contains what the controllers know about the gateway running the reverse proxy
+type proxyGateway struct {
+	svc     iaas.Service
+	network *resources.Network
+
+	gateway          *resources.Host
+	gatewayPrivateIP string
+	gatewayPublicIP  string
+}
+
+// loadProxyGateway loads the primary (or secondary) gateway of the network
+func loadProxyGateway(svc iaas.Service, network *resources.Network, addressPrimaryGateway bool) (*proxyGateway, error) {
+	var gatewayID string
+	if addressPrimaryGateway {
+		gatewayID = network.GatewayID
+	} else {
+		if network.SecondaryGatewayID == "" {
+			return nil, fmt.Errorf("cannot address secondary gateway, doesn't exist")
+		}
+		gatewayID = network.SecondaryGatewayID
+	}
+	mh, err := metadata.LoadHost(svc, gatewayID)
+	if err != nil {
+		return nil, err
+	}
+	addressedGateway, err := mh.Get()
+	if err != nil {
+		return nil, err
+	}
+	if addressedGateway == nil {
+		if addressPrimaryGateway {
+			return nil, fmt.Errorf("error getting data of primary gateway")
+		}
+		return nil, fmt.Errorf("error getting data of secondary gateway")
+	}
+	return &proxyGateway{
+		svc:              svc,
+		network:          network,
+		gateway:          addressedGateway,
+		gatewayPrivateIP: addressedGateway.GetPrivateIP(),
+		gatewayPublicIP:  addressedGateway.GetPublicIP(),
+	}, nil
+}
+
+// Gateway returns the gateway running the reverse proxy
+func (g *proxyGateway) Gateway() *resources.Host {
+	return g.gateway
+}
+
+// realizeRule replaces the variables in the name and the content of the rule, and sets in 'values' the ones
+// usable in all cases
+func (g *proxyGateway) realizeRule(rule map[interface{}]interface{}, values *Variables) (string, string, string, error) {
+	ruleType := rule["type"].(string)
+
+	ruleName, err := g.realizeRuleData(strings.Trim(rule["name"].(string), "\n"), *values)
+	if err != nil {
+		return ruleType, rule["name"].(string), "", err
+	}
+	content, err := g.realizeRuleData(strings.Trim(rule["content"].(string), "\n"), *values)
+	if err != nil {
+		return ruleType, ruleName, "", err
+	}
+
+	// Sets the values usable in all cases
+	if g.network.VIP != nil {
+		// VPL: for now, no public IP on VIP, so uses the IP of the first Gateway
+		// (*values)["EndpointIP"] = g.network.VIP.PublicIP
+		(*values)["EndpointIP"] = g.gatewayPublicIP
+		(*values)["DefaultRouteIP"] = g.network.VIP.PrivateIP
+	} else {
+		(*values)["EndpointIP"] = g.gatewayPublicIP
+		(*values)["DefaultRouteIP"] = g.gatewayPrivateIP
+	}
+	// Legacy...
+	(*values)["PublicIP"] = (*values)["EndpointIP"]
+	(*values)["GatewayIP"] = (*values)["DefaultRouteIP"]
+
+	return ruleType, ruleName, content, nil
+}
+
+func (g *proxyGateway) realizeRuleData(content string, v Variables) (string, error) {
+	contentTmpl, err := template.New("proxy_content").Parse(content)
+	if err != nil {
+		return "", fmt.Errorf("error preparing rule: %s", err.Error())
+	}
+	dataBuffer := bytes.NewBufferString("")
+	err = contentTmpl.Execute(dataBuffer, v)
+	if err != nil {
+		return "", err
+	}
+	return dataBuffer.String(), nil
+}
+
+// newProxyRule returns the record of a rule applied on the gateway
+func (g *proxyGateway) newProxyRule(kind, feature, ruleType, ruleName, content string, values *Variables) *propsv1.NetworkProxyRule {
+	host, _ := (*values)["Hostname"].(string)
+	return &propsv1.NetworkProxyRule{
+		Feature:   feature,
+		Gateway:   g.gateway.Name,
+		Kind:      kind,
+		Type:      ruleType,
+		Name:      ruleName,
+		Host:      host,
+		Content:   content,
+		AppliedAt: time.Now(),
+	}
+}
+
+// reverseProxyKind returns the kind of reverse proxy selected for the network (kong by default)
+func reverseProxyKind(network *resources.Network) (string, error) {
+	kind := ReverseProxyKong
+	err := network.Properties.LockForRead(networkproperty.ReverseProxyV1).ThenUse(func(clonable data.Clonable) error {
+		if k := clonable.(*propsv1.NetworkReverseProxy).Kind; k != "" {
+			kind = k
+		}
+		return nil
+	})
+	return kind, err
+}
+
+// sameProxyRule tells if the records concern the same rule, applied on the same gateway for the same host
+func sameProxyRule(a, b *propsv1.NetworkProxyRule) bool {
+	return a.Gateway == b.Gateway && a.Type == b.Type && a.Name == b.Name && a.Host == b.Host
+}
+
+// updateProxyRules updates the rules recorded in the metadata of the network, with 'update'
+func updateProxyRules(svc iaas.Service, networkID string, update func(*propsv1.NetworkReverseProxy) error) error {
+	proxyRulesLock.Lock()
+	defer proxyRulesLock.Unlock()
+
+	mn, err := metadata.LoadNetwork(svc, networkID)
+	if err != nil {
+		return err
+	}
+	mn.Acquire()
+	defer mn.Release()
+	network, err := mn.Get()
+	if err != nil {
+		return err
+	}
+	err = network.Properties.LockForWrite(networkproperty.ReverseProxyV1).ThenUse(func(clonable data.Clonable) error {
+		return update(clonable.(*propsv1.NetworkReverseProxy))
+	})
+	if err != nil {
+		return err
+	}
+	return mn.Write()
+}
+
+// recordProxyRule records in the metadata of the network the rule applied, replacing a previous record of the same rule
+func recordProxyRule(svc iaas.Service, networkID string, rule *propsv1.NetworkProxyRule) error {
+	return updateProxyRules(svc, networkID, func(rpV1 *propsv1.NetworkReverseProxy) error {
+		for i, r := range rpV1.Rules {
+			if sameProxyRule(r, rule) {
+				rpV1.Rules[i] = rule
+				return nil
+			}
+		}
+		rpV1.Rules = append(rpV1.Rules, rule)
+		return nil
+	})
+}
+
+// forgetProxyRule removes the record of the rule from the metadata of the network
+func forgetProxyRule(svc iaas.Service, networkID string, rule *propsv1.NetworkProxyRule) error {
+	return updateProxyRules(svc, networkID, func(rpV1 *propsv1.NetworkReverseProxy) error {
+		var kept []*propsv1.NetworkProxyRule
+		for _, r := range rpV1.Rules {
+			if !sameProxyRule(r, rule) {
+				kept = append(kept, r)
+			}
+		}
+		rpV1.Rules = kept
+		return nil
+	})
+}
+
+// gatewayProxyRules returns the rules recorded for the gateway, sorted by type then name then host
+func gatewayProxyRules(svc iaas.Service, networkID, gateway string) ([]*propsv1.NetworkProxyRule, error) {
+	rules, _, err := loadProxyRules(svc, networkID)
+	if err != nil {
+		return nil, err
+	}
+	var out []*propsv1.NetworkProxyRule
+	for _, r := range rules {
+		if r.Gateway == gateway {
+			out = append(out, r)
+		}
+	}
+	return out, nil
+}
+
+// loadProxyRules returns the rules recorded in the metadata of the network, and the network
+func loadProxyRules(svc iaas.Service, networkRef string) ([]*propsv1.NetworkProxyRule, *resources.Network, error) {
+	mn, err := metadata.LoadNetwork(svc, networkRef)
+	if err != nil {
+		return nil, nil, err
+	}
+	network, err := mn.Get()
+	if err != nil {
+		return nil, nil, err
+	}
+	var rules []*propsv1.NetworkProxyRule
+	err = network.Properties.LockForRead(networkproperty.ReverseProxyV1).ThenUse(func(clonable data.Clonable) error {
+		rules = clonable.(*propsv1.NetworkReverseProxy).Clone().(*propsv1.NetworkReverseProxy).Rules
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		if rules[i].Type != rules[j].Type {
+			return rules[i].Type < rules[j].Type
+		}
+		if rules[i].Name != rules[j].Name {
+			return rules[i].Name < rules[j].Name
+		}
+		return rules[i].Host < rules[j].Host
+	})
+	return rules, network, nil
+}
+
+// ProxyRules returns the rules published by features in the reverse proxy of the network, and the kind of reverse
+// proxy; if 'feature' isn't empty, returns only the rules published by this feature
+func ProxyRules(networkRef, feature string) ([]*propsv1.NetworkProxyRule, string, error) {
+	svc, err := currentService()
+	if err != nil {
+		return nil, "", err
+	}
+	rules, network, err := loadProxyRules(svc, networkRef)
+	if err != nil {
+		return nil, "", err
+	}
+	kind, err := reverseProxyKind(network)
+	if err != nil {
+		return nil, "", err
+	}
+	if feature == "" {
+		return rules, kind, nil
+	}
+	var out []*propsv1.NetworkProxyRule
+	for _, r := range rules {
+		if r.Feature == feature {
+			out = append(out, r)
+		}
+	}
+	return out, kind, nil
+}
+
+// DeleteProxyRules removes from the reverse proxy of the network the rules named 'name' (applied for the host
+// 'host' only if not empty); returns the rules removed
+func DeleteProxyRules(networkRef, name, host string) ([]*propsv1.NetworkProxyRule, error) {
+	svc, err := currentService()
+	if err != nil {
+		return nil, err
+	}
+	rules, network, err := loadProxyRules(svc, networkRef)
+	if err != nil {
+		return nil, err
+	}
+
+	controllers := map[string]ReverseProxyController{}
+	var deleted []*propsv1.NetworkProxyRule
+	for _, r := range rules {
+		if r.Name != name || (host != "" && r.Host != host) {
+			continue
+		}
+		ctrl, ok := controllers[r.Gateway]
+		if !ok {
+			primary, err := loadProxyGateway(svc, network, true)
+			if err != nil {
+				return deleted, err
+			}
+			ctrl, err = newReverseProxyController(svc, network, primary.gateway.Name == r.Gateway, false)
+			if err != nil {
+				return deleted, err
+			}
+			if ctrl.Gateway().Name != r.Gateway {
+				return deleted, scerr.InconsistentError(fmt.Sprintf("rule '%s' recorded for gateway '%s', which isn't a gateway of network '%s' anymore", r.Name, r.Gateway, network.Name))
+			}
+			controllers[r.Gateway] = ctrl
+		}
+		if r.Kind != ctrl.Kind() {
+			return deleted, scerr.InconsistentError(fmt.Sprintf("rule '%s' has been applied to reverse proxy '%s', but the reverse proxy of network '%s' is '%s'", r.Name, r.Kind, network.Name, ctrl.Kind()))
+		}
+		err = ctrl.Delete(r)
+		if err != nil {
+			return deleted, fmt.Errorf("failed to delete proxy rule '%s' from gateway '%s': %s", r.Name, r.Gateway, err.Error())
+		}
+		deleted = append(deleted, r)
+	}
+	if len(deleted) == 0 {
+		return nil, scerr.NotFoundError(fmt.Sprintf("no proxy rule named '%s' found in network '%s'", name, network.Name))
+	}
+	return deleted, nil
+}
+
+// ReverseProxyKind returns the kind of reverse proxy selected for the network
+func ReverseProxyKind(networkRef string) (string, error) {
+	svc, err := currentService()
+	if err != nil {
+		return "", err
+	}
+	mn, err := metadata.LoadNetwork(svc, networkRef)
+	if err != nil {
+		return "", err
+	}
+	network, err := mn.Get()
+	if err != nil {
+		return "", err
+	}
+	return reverseProxyKind(network)
+}
+
+// SetReverseProxyKind selects the kind of reverse proxy of the network; the reverse proxy itself has to be
+// installed on the gateways. Fails if rules are recorded for another kind.
+func SetReverseProxyKind(networkRef, kind string) error {
+	found := false
+	for _, k := range ReverseProxyKinds {
+		if k == kind {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return scerr.InvalidParameterError("kind", fmt.Sprintf("must be one of '%s'", strings.Join(ReverseProxyKinds, "', '")))
+	}
+	svc, err := currentService()
+	if err != nil {
+		return err
+	}
+	mn, err := metadata.LoadNetwork(svc, networkRef)
+	if err != nil {
+		return err
+	}
+	network, err := mn.Get()
+	if err != nil {
+		return err
+	}
+	err = updateProxyRules(svc, network.ID, func(rpV1 *propsv1.NetworkReverseProxy) error {
+		for _, r := range rpV1.Rules {
+			if r.Kind != kind {
+				return scerr.NotAvailableError(fmt.Sprintf("proxy rules have been published in the reverse proxy '%s' of network '%s', delete them first", r.Kind, network.Name))
+			}
+		}
+		rpV1.Kind = kind
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	kongProxyCheckedCache.Reset(network.Name)
+	return nil
+}
@@ -18,6 +18,7 @@ package install
 
 import (
 	"fmt"
+	"path"
 	"strings"
 	"time"
 
@@ -25,6 +26,7 @@ import (
 
 	pb "github.com/CS-SI/SafeScale/lib"
 	"github.com/CS-SI/SafeScale/lib/client"
+	propsv1 "github.com/CS-SI/SafeScale/lib/server/iaas/resources/properties/v1"
 	"github.com/CS-SI/SafeScale/lib/server/install/enums/action"
 	"github.com/CS-SI/SafeScale/lib/utils"
 	"github.com/CS-SI/SafeScale/lib/utils/cli/enums/outputs"
@@ -389,10 +391,13 @@ func (is *step) taskRunOnHost(t concurrency.Task, params concurrency.TaskParamet
 	host := p["host"].(*pb.Host)
 	variables := p["variables"].(Variables)
 
+	// Values of secret parameters must not appear in results and forensics
+	secrets := is.Worker.feature.secretValues(variables)
+
 	// Updates variables in step script
 	command, err := replaceVariablesInString(is.Script, variables)
 	if err != nil {
-		return stepResult{err: fmt.Errorf("failed to finalize installer script for step '%s': %s", is.Name, err.Error())}, nil
+		return stepResult{err: fmt.Errorf("failed to finalize installer script for step '%s': %s", is.Name, maskSecrets(err.Error(), secrets))}, nil
 	}
 
 	// If options file is defined, upload it to the remote host
@@ -405,23 +410,57 @@ func (is *step) taskRunOnHost(t concurrency.Task, params concurrency.TaskParamet
 
 	// Uploads then executes command
 	filename := fmt.Sprintf("%s/feature.%s.%s_%s.sh", utils.TempFolder, is.Worker.feature.DisplayName(), strings.ToLower(is.Action.String()), is.Name)
-	err = UploadStringToRemoteFile(command, host, filename, "", "", "")
+	err = uploadStringToRemoteFile(command, maskSecrets(command, secrets), host, filename, "", "", "")
 	if err != nil {
 		return stepResult{err: err}, nil
 	}
 
+	// The log of the script is kept on the host, to be consulted later
+	action := strings.ToLower(is.Action.String())
+	logFile := fmt.Sprintf("%s/feature.%s.%s_%s.log", utils.LogFolder, is.Worker.feature.DisplayName(), action, is.Name)
+	keptLogFile := featureLogPath(is.Worker.feature.DisplayName(), is.Worker.run, action, is.Name)
+	keepLog := fmt.Sprintf("sudo mkdir -p %s && sudo cp -f %s %s", path.Dir(keptLogFile), logFile, keptLogFile)
+
 	//command = fmt.Sprintf("sudo bash %s; rc=$?; if [[ rc -eq 0 ]]; then sudo rm -f %s %s/options.json; fi; exit $rc", filename, filename, srvutils.TempFolder)
-	command = fmt.Sprintf("sudo bash %s; rc=$?; exit $rc", filename)
+	if len(secrets) > 0 {
+		// The script contains the values of secrets, it is not kept on the host
+		command = fmt.Sprintf("sudo bash %s; rc=$?; sudo rm -f %s; %s; exit $rc", filename, filename, keepLog)
+	} else {
+		command = fmt.Sprintf("sudo bash %s; rc=$?; %s; exit $rc", filename, keepLog)
+	}
 
 	// Executes the script on the remote host
+	entry := &propsv1.HostFeatureStepLog{
+		Feature:    is.Worker.feature.DisplayName(),
+		Action:     action,
+		Step:       is.Name,
+		Run:        is.Worker.run,
+		TargetType: is.Worker.target.Type(),
+		Target:     is.Worker.target.Name(),
+		StartedAt:  time.Now(),
+		ExitCode:   -1,
+		LogFile:    keptLogFile,
+	}
+	defer func() {
+		entry.Duration = time.Since(entry.StartedAt)
+		if recErr := recordFeatureStepLog(host, entry); recErr != nil {
+			log.Warnf("failed to record log of step '%s' of feature '%s' on host '%s': %v", is.Name, entry.Feature, host.Name, recErr)
+		}
+	}()
 	retcode, _, _, err := client.New().SSH.Run(host.Name, command, outputs.COLLECT, temporal.GetConnectionTimeout(), is.WallTime)
 	if err != nil {
+		if len(secrets) > 0 {
+			err = fmt.Errorf("%s", maskSecrets(err.Error(), secrets))
+		}
+		entry.Error = err.Error()
 		return stepResult{err: err}, nil
 	}
 	err = nil
+	entry.ExitCode = retcode
 	ok = retcode == 0
 	if !ok {
 		err = fmt.Errorf("failure: retcode=%d", retcode)
+		entry.Error = err.Error()
 	}
 	return stepResult{success: ok, completed: true, err: err}, nil
 }
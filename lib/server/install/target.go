@@ -17,12 +17,19 @@
 package install
 
 import (
+	"sort"
+
+	"github.com/sirupsen/logrus"
+
 	"github.com/CS-SI/SafeScale/lib/server/install/enums/method"
 	"github.com/CS-SI/SafeScale/lib/utils/concurrency"
+	"github.com/CS-SI/SafeScale/lib/utils/data"
 	"github.com/CS-SI/SafeScale/lib/utils/scerr"
 
 	clusterapi "github.com/CS-SI/SafeScale/lib/server/cluster/api"
+	clusterpropsv1 "github.com/CS-SI/SafeScale/lib/server/cluster/control/properties/v1"
 	"github.com/CS-SI/SafeScale/lib/server/cluster/enums/flavor"
+	"github.com/CS-SI/SafeScale/lib/server/cluster/enums/property"
 
 	pb "github.com/CS-SI/SafeScale/lib"
 )
@@ -80,6 +87,10 @@ func createHostTarget(host *pb.Host) (*HostTarget, error) {
 	//	methods[index] = method.Dnf
 	//}
 	index++
+	methods[index] = method.Docker
+	index++
+	methods[index] = method.Compose
+	index++
 	methods[index] = method.Bash
 	return &HostTarget{
 		host:    host,
@@ -103,9 +114,18 @@ func (t *HostTarget) Methods() map[uint8]method.Enum {
 	return t.methods
 }
 
-// Installed returns a list of installed features
+// Installed returns the list of the features recorded as installed on the host
 func (t *HostTarget) Installed() []string {
 	var list []string
+	installed, err := HostInstalledFeatures(t.host)
+	if err != nil {
+		logrus.Warnf("failed to read features installed on host '%s': %v", t.name, err)
+		return list
+	}
+	for k := range installed {
+		list = append(list, k)
+	}
+	sort.Strings(list)
 	return list
 }
 
@@ -131,6 +151,10 @@ func NewClusterTarget(task concurrency.Task, cluster clusterapi.Cluster) (Target
 		methods[index] = method.DCOS
 	}
 	index++
+	methods[index] = method.Docker
+	index++
+	methods[index] = method.Compose
+	index++
 	methods[index] = method.Bash
 	return &ClusterTarget{
 		cluster: cluster,
@@ -154,9 +178,19 @@ func (t *ClusterTarget) Methods() map[uint8]method.Enum {
 	return t.methods
 }
 
-// Installed returns a list of installed feature
+// Installed returns the list of the features recorded as installed on the cluster
 func (t *ClusterTarget) Installed() []string {
 	var list []string
+	err := t.cluster.GetProperties(concurrency.RootTask()).LockForRead(property.FeaturesV1).ThenUse(func(clonable data.Clonable) error {
+		for k := range clonable.(*clusterpropsv1.Features).Installed {
+			list = append(list, k)
+		}
+		return nil
+	})
+	if err != nil {
+		logrus.Warnf("failed to read features installed on cluster '%s': %v", t.name, err)
+	}
+	sort.Strings(list)
 	return list
 }
 
@@ -55,7 +55,7 @@ exec 1<&-
 exec 2<&-
 exec 1<>%s/feature.{{.reserved_Name}}.{{.reserved_Action}}_{{.reserved_Step}}.log
 exec 2>&1
-set -x
+{{ if .reserved_Secret }}set +x{{ else }}set -x{{ end }}
 
 {{ .reserved_BashLibrary }}
 
@@ -261,6 +261,12 @@ func UploadFile(localpath string, host *pb.Host, remotepath, owner, group, right
 
 // UploadStringToRemoteFile creates a file 'filename' on remote 'host' with the content 'content'
 func UploadStringToRemoteFile(content string, host *pb.Host, filename string, owner, group, rights string) error {
+	return uploadStringToRemoteFile(content, content, host, filename, owner, group, rights)
+}
+
+// uploadStringToRemoteFile uploads 'content' to the remote file; 'dump' is the content written in forensics folder
+// (without the values of secrets)
+func uploadStringToRemoteFile(content, dump string, host *pb.Host, filename string, owner, group, rights string) error {
 	if content == "" {
 		return scerr.InvalidParameterError("content", "cannot be empty string")
 	}
@@ -276,7 +282,7 @@ func UploadStringToRemoteFile(content string, host *pb.Host, filename string, ow
 		partials := strings.Split(filename, "/")
 		dumpName := utils.AbsPathify(fmt.Sprintf("$HOME/.safescale/forensics/%s/%s", host.Name, partials[len(partials)-1]))
 
-		err := ioutil.WriteFile(dumpName, []byte(content), 0644)
+		err := ioutil.WriteFile(dumpName, []byte(dump), 0644)
 		if err != nil {
 			logrus.Warnf("[TRACE] Forensics error creating %s", dumpName)
 		}
@@ -397,23 +403,6 @@ func determineContext(t Target) (hT *HostTarget, cT *ClusterTarget, nT *NodeTarg
 	return
 }
 
-// Check if required parameters defined in specification file have been set in 'v'
-func checkParameters(f *Feature, v Variables) error {
-	if f.specs.IsSet("feature.parameters") {
-		params := f.specs.GetStringSlice("feature.parameters")
-		for _, k := range params {
-			splitted := strings.Split(k, "=")
-			if _, ok := v[splitted[0]]; !ok {
-				if len(splitted) == 1 {
-					return fmt.Errorf("missing value for parameter '%s'", k)
-				}
-				v[splitted[0]] = strings.Join(splitted[1:], "=")
-			}
-		}
-	}
-	return nil
-}
-
 func gatewayFromHost(host *pb.Host) *pb.Host {
 	gwID := host.GetGatewayId()
 	// If host has no gateway, host is gateway
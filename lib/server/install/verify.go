@@ -0,0 +1,519 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package install
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	pb "github.com/CS-SI/SafeScale/lib"
+	"github.com/CS-SI/SafeScale/lib/client"
+	"github.com/CS-SI/SafeScale/lib/server/install/enums/method"
+	"github.com/CS-SI/SafeScale/lib/utils"
+	"github.com/CS-SI/SafeScale/lib/utils/cli/enums/outputs"
+	"github.com/CS-SI/SafeScale/lib/utils/concurrency"
+	"github.com/CS-SI/SafeScale/lib/utils/data"
+	"github.com/CS-SI/SafeScale/lib/utils/scerr"
+	"github.com/CS-SI/SafeScale/lib/utils/temporal"
+)
+
+const (
+	yamlVerifyKeyword = "verify"
+
+	// verifyDriftPrefix prefixes the lines of the output of the verification scripts reporting a drift
+	verifyDriftPrefix = "DRIFT|"
+)
+
+// Verification reports the drift of the configuration of a feature on the hosts of a target, compared to what
+// the steps of its addition declare (key 'verify' of the steps)
+type Verification struct {
+	Feature    string `json:"feature"`
+	Target     string `json:"target"`
+	TargetType string `json:"target_type"`
+	// Drifts contains the drifts found, indexed by host name
+	Drifts map[string][]Drift `json:"drifts"`
+	// Verified contains the steps whose declarations have been verified
+	Verified []string `json:"verified"`
+	// Unverified contains the steps declaring nothing to verify
+	Unverified []string `json:"unverified,omitempty"`
+	// Errors contains the errors met, indexed by host name
+	Errors map[string]string `json:"errors,omitempty"`
+
+	lock sync.Mutex
+}
+
+// Drift describes an item of a step that doesn't match its declaration on a host
+type Drift struct {
+	Step string `json:"step"`
+	// Kind is the kind of item: file, service or port
+	Kind    string `json:"kind"`
+	Item    string `json:"item"`
+	Message string `json:"message"`
+	// Reconciled tells if the step has been re-applied successfully on the host
+	Reconciled bool `json:"reconciled,omitempty"`
+}
+
+// Drifting tells if drifts remain (not reconciled) or if errors occurred
+func (vr *Verification) Drifting() bool {
+	if len(vr.Errors) > 0 {
+		return true
+	}
+	for _, drifts := range vr.Drifts {
+		for _, d := range drifts {
+			if !d.Reconciled {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Summary returns the drifts not reconciled and the errors, one per line
+func (vr *Verification) Summary() string {
+	var lines []string
+	for h, drifts := range vr.Drifts {
+		for _, d := range drifts {
+			if !d.Reconciled {
+				lines = append(lines, fmt.Sprintf("%s: step '%s': %s '%s' %s", h, d.Step, d.Kind, d.Item, d.Message))
+			}
+		}
+	}
+	for h, e := range vr.Errors {
+		lines = append(lines, fmt.Sprintf("%s: %s", h, e))
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
+
+func (vr *Verification) addDrifts(host string, drifts []Drift) {
+	vr.lock.Lock()
+	defer vr.lock.Unlock()
+	vr.Drifts[host] = append(vr.Drifts[host], drifts...)
+}
+
+func (vr *Verification) addError(host string, err error) {
+	vr.lock.Lock()
+	defer vr.lock.Unlock()
+	if previous, ok := vr.Errors[host]; ok {
+		vr.Errors[host] = previous + "; " + err.Error()
+	} else {
+		vr.Errors[host] = err.Error()
+	}
+}
+
+// markReconciled marks the drifts of the step on the host as reconciled
+func (vr *Verification) markReconciled(host, step string) {
+	vr.lock.Lock()
+	defer vr.lock.Unlock()
+	for i := range vr.Drifts[host] {
+		if vr.Drifts[host][i].Step == step {
+			vr.Drifts[host][i].Reconciled = true
+		}
+	}
+}
+
+// Verify compares the configuration of the feature on the hosts of the target with what the steps of its addition
+// declare (files with their templated content, services running, ports listening), and reports the drift per host.
+// If 's.Reconcile' is set, the steps drifting are re-applied on the hosts drifting, and only there.
+// The feature is expected to be installed; its requirements are not verified.
+// Returns an error if none of the steps declares something to verify, as nothing could drift.
+func (f *Feature) Verify(t Target, v Variables, s Settings) (_ *Verification, err error) {
+	if f == nil {
+		return nil, scerr.InvalidInstanceError()
+	}
+
+	tracer := concurrency.NewTracer(f.task, fmt.Sprintf("(): '%s' on %s '%s'", f.DisplayName(), t.Type(), t.Name()), true).WithStopwatch().GoingIn()
+	defer tracer.OnExitTrace()()
+	defer scerr.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	installer, _, err := f.installerFor(t)
+	if err != nil {
+		return nil, err
+	}
+
+	myV := v.Clone()
+
+	// Inits implicit parameters
+	err = f.setImplicitParameters(t, myV)
+	if err != nil {
+		return nil, err
+	}
+
+	// Checks parameters against their schema and sets default values
+	err = validateParameters(f, myV)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Verification{
+		Feature:    f.DisplayName(),
+		Target:     t.Name(),
+		TargetType: t.Type(),
+		Drifts:     map[string][]Drift{},
+		Errors:     map[string]string{},
+	}
+	s.verify = report
+	_, err = installer.Add(f, t, myV, s)
+	if err != nil {
+		return nil, err
+	}
+	if len(report.Verified) == 0 {
+		return nil, scerr.NotAvailableError(fmt.Sprintf("feature '%s' declares nothing to verify on %s '%s' (no key '%s' in the steps of its addition)", f.DisplayName(), t.Type(), t.Name(), yamlVerifyKeyword))
+	}
+	return report, nil
+}
+
+// installerFor returns the installer (and its method) used to install the feature on the target
+func (f *Feature) installerFor(t Target) (Installer, method.Enum, error) {
+	methods := t.Methods()
+	var i uint8
+	for i = 1; i <= uint8(len(methods)); i++ {
+		meth := methods[i]
+		if f.specs.IsSet(fmt.Sprintf("feature.install.%s", strings.ToLower(meth.String()))) {
+			installer := f.installerOfMethod(meth)
+			if installer != nil {
+				return installer, meth, nil
+			}
+		}
+	}
+	return nil, method.NextEnum, fmt.Errorf("failed to find a way to install '%s'", f.DisplayName())
+}
+
+// verifySpec is the content of the key 'verify' of a step: what the step leaves on the hosts
+type verifySpec struct {
+	Files    []verifyFile
+	Services []string
+	Ports    []string
+}
+
+// verifyFile describes a file expected on the hosts; 'Mode' and 'Owner' (in format <user>:<group>) are optional
+type verifyFile struct {
+	Path    string
+	Content string
+	Mode    string
+	Owner   string
+}
+
+// parseVerifySpec reads the content of the key 'verify' of a step
+func parseVerifySpec(anon interface{}) (*verifySpec, error) {
+	content, ok := toStringMap(anon)
+	if !ok {
+		return nil, fmt.Errorf("must be a map")
+	}
+	spec := verifySpec{}
+	if files, ok := content["files"]; ok {
+		items, ok := files.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("'files' must be a list")
+		}
+		for _, item := range items {
+			m, ok := toStringMap(item)
+			if !ok {
+				return nil, fmt.Errorf("items of 'files' must be maps")
+			}
+			file := verifyFile{}
+			for k, v := range m {
+				value := fmt.Sprintf("%v", v)
+				switch strings.ToLower(k) {
+				case "path":
+					file.Path = value
+				case "content":
+					file.Content = value
+				case "mode":
+					// YAML reads an unquoted mode like 0644 as an octal integer
+					if i, ok := v.(int); ok {
+						value = strconv.FormatInt(int64(i), 8)
+					}
+					file.Mode = value
+				case "owner":
+					file.Owner = value
+				default:
+					return nil, fmt.Errorf("unknown key '%s' in items of 'files'", k)
+				}
+			}
+			if file.Path == "" {
+				return nil, fmt.Errorf("items of 'files' must define 'path'")
+			}
+			if file.Mode != "" {
+				mode, err := strconv.ParseUint(file.Mode, 8, 32)
+				if err != nil {
+					return nil, fmt.Errorf("invalid mode '%s' for file '%s'", file.Mode, file.Path)
+				}
+				file.Mode = strconv.FormatUint(mode, 8)
+			}
+			spec.Files = append(spec.Files, file)
+		}
+	}
+	for _, key := range []string{"services", "ports"} {
+		anon, ok := content[key]
+		if !ok {
+			continue
+		}
+		items, ok := anon.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("'%s' must be a list", key)
+		}
+		for _, item := range items {
+			value := fmt.Sprintf("%v", item)
+			if key == "services" {
+				spec.Services = append(spec.Services, value)
+			} else {
+				spec.Ports = append(spec.Ports, value)
+			}
+		}
+	}
+	return &spec, nil
+}
+
+// toStringMap converts the maps read from specification files
+func toStringMap(anon interface{}) (map[string]interface{}, bool) {
+	switch m := anon.(type) {
+	case map[string]interface{}:
+		return m, true
+	case map[interface{}]interface{}:
+		out := map[string]interface{}{}
+		for k, v := range m {
+			out[fmt.Sprintf("%v", k)] = v
+		}
+		return out, true
+	}
+	return nil, false
+}
+
+// render replaces the variables in the declarations
+func (vs *verifySpec) render(v Variables) (*verifySpec, error) {
+	var err error
+	out := verifySpec{}
+	for _, f := range vs.Files {
+		rf := verifyFile{Mode: f.Mode, Owner: f.Owner}
+		rf.Path, err = replaceVariablesInString(f.Path, v)
+		if err != nil {
+			return nil, err
+		}
+		if f.Content != "" {
+			rf.Content, err = replaceVariablesInString(f.Content, v)
+			if err != nil {
+				return nil, err
+			}
+		}
+		out.Files = append(out.Files, rf)
+	}
+	for _, s := range vs.Services {
+		rs, err := replaceVariablesInString(s, v)
+		if err != nil {
+			return nil, err
+		}
+		out.Services = append(out.Services, rs)
+	}
+	for _, p := range vs.Ports {
+		rp, err := replaceVariablesInString(p, v)
+		if err != nil {
+			return nil, err
+		}
+		out.Ports = append(out.Ports, rp)
+	}
+	return &out, nil
+}
+
+// script returns the bash script verifying the declarations on a host; each drift is reported on a line of the
+// standard output, in format DRIFT|<kind>|<item>|<message>. The expected content of the files is compared by
+// checksum, so it isn't sent to the host.
+func (vs *verifySpec) script() (string, error) {
+	lines := []string{
+		"drift() { echo \"" + verifyDriftPrefix + "$1|$2|$3\"; }",
+	}
+	for _, f := range vs.Files {
+		path := shellQuote(f.Path)
+		lines = append(lines, fmt.Sprintf("if [ ! -f %s ]; then", path))
+		lines = append(lines, fmt.Sprintf("    drift file %s \"is missing\"", path))
+		lines = append(lines, "else")
+		if f.Content != "" {
+			sum := fmt.Sprintf("%x", sha256.Sum256([]byte(f.Content)))
+			lines = append(lines, fmt.Sprintf("    [ \"$(sha256sum %s | cut -d' ' -f1)\" = \"%s\" ] || drift file %s \"has a content different from the one declared\"", path, sum, path))
+		}
+		if f.Mode != "" {
+			lines = append(lines, fmt.Sprintf("    [ \"$(stat -c %%a %s)\" = \"%s\" ] || drift file %s \"has mode $(stat -c %%a %s) instead of %s\"", path, f.Mode, path, path, f.Mode))
+		}
+		if f.Owner != "" {
+			owner := shellQuote(f.Owner)
+			lines = append(lines, fmt.Sprintf("    [ \"$(stat -c %%U:%%G %s)\" = %s ] || drift file %s \"is owned by $(stat -c %%U:%%G %s) instead of %s\"", path, owner, path, path, f.Owner))
+		}
+		lines = append(lines, "fi")
+	}
+	for _, s := range vs.Services {
+		lines = append(lines, fmt.Sprintf("systemctl is-active --quiet %s || drift service %s \"is not running\"", shellQuote(s), shellQuote(s)))
+	}
+	for _, p := range vs.Ports {
+		port, protocol := p, "tcp"
+		if idx := strings.Index(p, "/"); idx >= 0 {
+			port, protocol = p[:idx], strings.ToLower(p[idx+1:])
+		}
+		if _, err := strconv.Atoi(port); err != nil {
+			return "", fmt.Errorf("invalid port '%s'", p)
+		}
+		var flags string
+		switch protocol {
+		case "tcp":
+			flags = "-ltn"
+		case "udp":
+			flags = "-lun"
+		default:
+			return "", fmt.Errorf("invalid protocol of port '%s'", p)
+		}
+		lines = append(lines, fmt.Sprintf("ss %s | awk 'NR>1 {print $4}' | grep -qE ':%s$' || drift port %s/%s \"is not listening\"", flags, port, port, protocol))
+	}
+	lines = append(lines, "exit 0")
+	return strings.Join(lines, "\n") + "\n", nil
+}
+
+// parseDrifts reads the drifts reported in the output of a verification script
+func parseDrifts(step, stdout string) []Drift {
+	var drifts []Drift
+	for _, line := range strings.Split(stdout, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, verifyDriftPrefix) {
+			continue
+		}
+		parts := strings.SplitN(strings.TrimPrefix(line, verifyDriftPrefix), "|", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		drifts = append(drifts, Drift{Step: step, Kind: parts[0], Item: parts[1], Message: parts[2]})
+	}
+	return drifts
+}
+
+// Verify runs the verification declared by the step on the hosts, then re-applies the step on the hosts drifting
+// if 'Reconcile' is set in the settings of the worker
+func (is *step) Verify(hosts []*pb.Host, v Variables, anon interface{}, report *Verification) error {
+	if anon == nil {
+		report.lock.Lock()
+		report.Unverified = append(report.Unverified, is.Name)
+		report.lock.Unlock()
+		return nil
+	}
+	spec, err := parseVerifySpec(anon)
+	if err != nil {
+		msg := `syntax error in feature '%s' specification file (%s): invalid key '%s.%s': %s`
+		return fmt.Errorf(msg, is.Worker.feature.DisplayName(), is.Worker.feature.DisplayFilename(), is.YamlKey, yamlVerifyKeyword, err.Error())
+	}
+	report.lock.Lock()
+	report.Verified = append(report.Verified, is.Name)
+	report.lock.Unlock()
+
+	subtasks := map[*pb.Host]concurrency.Task{}
+	for _, h := range hosts {
+		cloneV := v.Clone()
+		cloneV["HostIP"] = h.PrivateIp
+		cloneV["Hostname"] = h.Name
+		cloneV, err = realizeVariables(cloneV)
+		if err != nil {
+			return err
+		}
+		subtask, err := concurrency.NewTask(is.Worker.feature.task)
+		if err != nil {
+			return err
+		}
+		subtask, err = subtask.Start(is.taskVerifyOnHost, data.Map{
+			"host":      h,
+			"variables": cloneV,
+			"spec":      spec,
+		})
+		if err != nil {
+			return err
+		}
+		subtasks[h] = subtask
+	}
+
+	var drifting []*pb.Host
+	for h, subtask := range subtasks {
+		result, err := subtask.Wait()
+		if err != nil {
+			report.addError(h.Name, fmt.Errorf("failed to verify step '%s': %s", is.Name, err.Error()))
+			continue
+		}
+		drifts, _ := result.([]Drift)
+		if len(drifts) > 0 {
+			report.addDrifts(h.Name, drifts)
+			drifting = append(drifting, h)
+		}
+	}
+
+	if !is.Worker.settings.Reconcile || len(drifting) == 0 {
+		return nil
+	}
+	results, err := is.Run(drifting, v, is.Worker.settings)
+	if err != nil {
+		return err
+	}
+	for _, h := range drifting {
+		r, ok := results[h.Name]
+		if ok && r.Successful() {
+			report.markReconciled(h.Name, is.Name)
+			continue
+		}
+		msg := "no result"
+		if ok && r.ErrorMessage() != "" {
+			msg = r.ErrorMessage()
+		}
+		report.addError(h.Name, fmt.Errorf("failed to re-apply step '%s': %s", is.Name, msg))
+	}
+	return nil
+}
+
+// taskVerifyOnHost runs the verification script of the step on a host, and returns the drifts found
+// Respects interface concurrency.TaskFunc
+func (is *step) taskVerifyOnHost(t concurrency.Task, params concurrency.TaskParameters) (concurrency.TaskResult, error) {
+	p, ok := params.(data.Map)
+	if !ok {
+		return nil, scerr.InvalidParameterError("params", "must be a data.Map")
+	}
+	host := p["host"].(*pb.Host)
+	variables := p["variables"].(Variables)
+	spec := p["spec"].(*verifySpec)
+
+	// Values of secret parameters must not appear in errors and forensics
+	secrets := is.Worker.feature.secretValues(variables)
+
+	rendered, err := spec.render(variables)
+	if err != nil {
+		return nil, fmt.Errorf("failed to finalize verification of step '%s': %s", is.Name, maskSecrets(err.Error(), secrets))
+	}
+	script, err := rendered.script()
+	if err != nil {
+		return nil, err
+	}
+
+	filename := fmt.Sprintf("%s/feature.%s.verify_%s.sh", utils.TempFolder, is.Worker.feature.DisplayName(), is.Name)
+	err = uploadStringToRemoteFile(script, maskSecrets(script, secrets), host, filename, "", "", "")
+	if err != nil {
+		return nil, err
+	}
+	command := fmt.Sprintf("sudo bash %s; rc=$?; sudo rm -f %s; exit $rc", filename, filename)
+	retcode, stdout, stderr, err := client.New().SSH.Run(host.Name, command, outputs.COLLECT, temporal.GetConnectionTimeout(), temporal.GetExecutionTimeout())
+	if err != nil {
+		return nil, err
+	}
+	if retcode != 0 {
+		return nil, fmt.Errorf("failure: retcode=%d: %s", retcode, maskSecrets(strings.TrimSpace(stderr), secrets))
+	}
+	return parseDrifts(is.Name, stdout), nil
+}
@@ -0,0 +1,114 @@
+package install
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testVerifySpecs = `
+feature:
+    install:
+        bash:
+            add:
+                pace: config
+                steps:
+                    config:
+                        targets:
+                            hosts: yes
+                        run: echo
+                        verify:
+                            files:
+                                - path: /etc/{{ .Hostname }}.conf
+                                  content: "listen={{ .Port }}\n"
+                                  mode: 0640
+                                  owner: root:root
+                            services:
+                                - nginx
+                            ports:
+                                - "{{ .Port }}"
+                                - 53/udp
+`
+
+func TestVerifySpec_Script(t *testing.T) {
+	f := newTestFeature(t, testVerifySpecs)
+	steps := f.specs.GetStringMap("feature.install.bash.add.steps")
+	stepMap, ok := steps["config"].(map[string]interface{})
+	require.True(t, ok)
+
+	spec, err := parseVerifySpec(stepMap[yamlVerifyKeyword])
+	require.NoError(t, err)
+	require.Len(t, spec.Files, 1)
+	assert.Equal(t, "640", spec.Files[0].Mode)
+
+	rendered, err := spec.render(Variables{"Hostname": "web", "Port": 8080})
+	require.NoError(t, err)
+	assert.Equal(t, "/etc/web.conf", rendered.Files[0].Path)
+
+	script, err := rendered.script()
+	require.NoError(t, err)
+	assert.Contains(t, script, fmt.Sprintf("%x", sha256.Sum256([]byte("listen=8080\n"))))
+	assert.NotContains(t, script, "listen=8080")
+	assert.Contains(t, script, `[ "$(stat -c %a '/etc/web.conf')" = "640" ]`)
+	assert.Contains(t, script, "systemctl is-active --quiet 'nginx'")
+	assert.Contains(t, script, "ss -ltn | awk 'NR>1 {print $4}' | grep -qE ':8080$'")
+	assert.Contains(t, script, "ss -lun | awk 'NR>1 {print $4}' | grep -qE ':53$'")
+
+	rendered.Ports = []string{"http"}
+	_, err = rendered.script()
+	assert.Error(t, err)
+}
+
+func TestParseDrifts(t *testing.T) {
+	stdout := "some output\nDRIFT|file|/etc/web.conf|is missing\nDRIFT|port|8080/tcp|is not listening\n"
+	drifts := parseDrifts("config", stdout)
+	require.Len(t, drifts, 2)
+	assert.Equal(t, Drift{Step: "config", Kind: "file", Item: "/etc/web.conf", Message: "is missing"}, drifts[0])
+
+	report := &Verification{Drifts: map[string][]Drift{"web": drifts}, Errors: map[string]string{}}
+	assert.True(t, report.Drifting())
+	report.markReconciled("web", "config")
+	assert.False(t, report.Drifting())
+}
+
+func TestStep_Verify_Verifiable(t *testing.T) {
+	f := newTestFeature(t, testVerifySpecs)
+	stepMap, ok := f.specs.GetStringMap("feature.install.bash.add.steps")["config"].(map[string]interface{})
+	require.True(t, ok)
+	report := &Verification{Drifts: map[string][]Drift{}, Errors: map[string]string{}}
+
+	is := &step{Worker: &worker{feature: f}, Name: "install"}
+	require.NoError(t, is.Verify(nil, Variables{}, nil, report))
+	assert.Equal(t, []string{"install"}, report.Unverified)
+	assert.Empty(t, report.Verified)
+
+	is = &step{Worker: &worker{feature: f}, Name: "config"}
+	require.NoError(t, is.Verify(nil, Variables{}, stepMap[yamlVerifyKeyword], report))
+	assert.Equal(t, []string{"config"}, report.Verified)
+}
+
+func TestCoreFeatures_Verify(t *testing.T) {
+	for _, name := range []string{"docker", "kubernetes"} {
+		_, specs, err := loadSpecFile(name)
+		require.NoError(t, err)
+		verified := 0
+		for stepName, anon := range specs.GetStringMap("feature.install.bash.add.steps") {
+			stepMap, ok := anon.(map[string]interface{})
+			require.True(t, ok)
+			if _, ok := stepMap[yamlVerifyKeyword]; !ok {
+				continue
+			}
+			spec, err := parseVerifySpec(stepMap[yamlVerifyKeyword])
+			require.NoError(t, err, "step '%s' of feature '%s'", stepName, name)
+			rendered, err := spec.render(Variables{"Hostname": "test-host", "ControlplaneUsesVIP": true})
+			require.NoError(t, err, "step '%s' of feature '%s'", stepName, name)
+			_, err = rendered.script()
+			require.NoError(t, err, "step '%s' of feature '%s'", stepName, name)
+			verified++
+		}
+		assert.NotZero(t, verified, "feature '%s' declares nothing to verify", name)
+	}
+}
@@ -81,6 +81,8 @@ type worker struct {
 	concernedGateways []*pb.Host
 
 	rootKey string
+	// run identifies the execution of the action, in the logs of the steps
+	run string
 	// function to alter the content of 'run' key of specification file
 	commandCB alterCommandCB
 }
@@ -398,6 +400,7 @@ func (w *worker) identifyAllGateways() ([]*pb.Host, error) {
 func (w *worker) Proceed(v Variables, s Settings) (results Results, err error) {
 	w.variables = v
 	w.settings = s
+	w.run = time.Now().Format(featureLogRunFormat)
 
 	results = Results{}
 
@@ -416,9 +419,14 @@ func (w *worker) Proceed(v Variables, s Settings) (results Results, err error) {
 	order := strings.Split(pace, ",")
 
 	// Applies reverseproxy rules to make it functional (feature may need it during the install)
-	if w.action == action.Add && !s.SkipProxy {
+	if w.action == action.Add && !s.SkipProxy && s.verify == nil {
 		if w.cluster != nil {
-			err := w.setReverseProxy()
+			var err error
+			if s.dryRun != nil {
+				err = w.renderReverseProxy(s.dryRun)
+			} else {
+				err = w.setReverseProxy()
+			}
 			if err != nil {
 				return nil, err
 			}
@@ -637,6 +645,7 @@ func (w *worker) taskLaunchStep(task concurrency.Task, params concurrency.TaskPa
 		"reserved_Content": runContent,
 		"reserved_Action":  strings.ToLower(w.action.String()),
 		"reserved_Step":    stepName,
+		"reserved_Secret":  len(w.feature.secretValues(vars)) > 0,
 	})
 	if err != nil {
 		return nil, err
@@ -665,6 +674,12 @@ func (w *worker) taskLaunchStep(task concurrency.Task, params concurrency.TaskPa
 		YamlKey:            stepKey,
 		Serial:             serial,
 	}
+	if w.settings.dryRun != nil {
+		return nil, stepInstance.Render(hostsList, vars, w.settings.dryRun)
+	}
+	if w.settings.verify != nil {
+		return nil, stepInstance.Verify(hostsList, vars, stepMap[yamlVerifyKeyword], w.settings.verify)
+	}
 	r, err := stepInstance.Run(hostsList, vars, w.settings)
 	// If an error occurred, don't do the remaining steps, fail immediately
 	if err != nil {
@@ -783,71 +798,25 @@ func (w *worker) setReverseProxy() (err error) {
 		return scerr.InvalidParameterError("w.feature.task", "nil task in setReverseProxy, cannot be nil")
 	}
 
-	svc := w.cluster.GetService(w.feature.task)
-	netprops, err := w.cluster.GetNetworkConfig(w.feature.task)
-	if err != nil {
-		return err
-	}
-	mn, err := metadata.LoadNetwork(svc, netprops.NetworkID)
+	primaryController, secondaryController, err := w.proxyControllers(true)
 	if err != nil {
 		return err
 	}
-
-	network, err := mn.Get()
-	if err != nil {
-		return err
-	}
-	primaryKongController, err := NewKongController(svc, network, true)
-	if err != nil {
-		switch err.(type) {
-		case scerr.ErrNotFound:
-			return nil
-		default:
-			return scerr.InvalidRequestError(fmt.Sprintf("failed to apply reverse proxy rules: %s", err.Error()))
-		}
-	}
-	var secondaryKongController *KongController
-	if network.SecondaryGatewayID != "" {
-		secondaryKongController, err = NewKongController(svc, network, false)
-		if err != nil {
-			switch err.(type) {
-			case scerr.ErrNotFound:
-				return scerr.InconsistentError("reverseproxy found on primary gateway but not on secondary gateway")
-			default:
-				return scerr.InvalidRequestError(fmt.Sprintf("failed to apply reverse proxy rules: %s", err.Error()))
-			}
-		}
+	if primaryController == nil {
+		return nil
 	}
 
 	// Now submits all the rules to reverse proxy
 	primaryGatewayVariables := w.variables.Clone()
 	var secondaryGatewayVariables Variables
-	if secondaryKongController != nil {
+	if secondaryController != nil {
 		secondaryGatewayVariables = w.variables.Clone()
 	}
 	for _, r := range rules {
-		targets := stepTargets{}
 		rule := r.(map[interface{}]interface{})
-		anon, ok := rule["targets"].(map[interface{}]interface{})
+		targets, ok := w.proxyRuleTargets(rule)
 		if !ok {
-			// If no 'targets' key found, applies on host only
-			if w.cluster != nil {
-				continue
-			}
-			targets[targetHosts] = "yes"
-		} else {
-			for i, j := range anon {
-				switch j := j.(type) {
-				case bool:
-					if j {
-						targets[i.(string)] = "yes"
-					} else {
-						targets[i.(string)] = "no"
-					}
-				case string:
-					targets[i.(string)] = j
-				}
-			}
+			continue
 		}
 		hosts, err := w.identifyHosts(targets)
 		if err != nil {
@@ -859,20 +828,22 @@ func (w *worker) setReverseProxy() (err error) {
 			primaryGatewayVariables["HostIP"] = h.PrivateIp
 			primaryGatewayVariables["Hostname"] = h.Name
 			_, _ = tP.Start(taskApplyProxyRule, data.Map{ // FIXME Later
-				"ctrl": primaryKongController,
-				"rule": rule,
-				"vars": &primaryGatewayVariables,
+				"ctrl":    primaryController,
+				"feature": w.feature.DisplayName(),
+				"rule":    rule,
+				"vars":    &primaryGatewayVariables,
 			})
 
 			var errS error
-			if secondaryKongController != nil {
+			if secondaryController != nil {
 				tS, _ := w.feature.task.New() // FIXME Later
 				secondaryGatewayVariables["HostIP"] = h.PrivateIp
 				secondaryGatewayVariables["Hostname"] = h.Name
 				_, _ = tS.Start(taskApplyProxyRule, data.Map{ // FIXME Later
-					"ctrl": secondaryKongController,
-					"rule": rule,
-					"vars": &secondaryGatewayVariables,
+					"ctrl":    secondaryController,
+					"feature": w.feature.DisplayName(),
+					"rule":    rule,
+					"vars":    &secondaryGatewayVariables,
 				})
 				_, errS = tS.Wait()
 			}
@@ -889,13 +860,83 @@ func (w *worker) setReverseProxy() (err error) {
 	return nil
 }
 
+// proxyControllers returns the controllers of the reverse proxy of the primary gateway and of the secondary
+// gateway (if any); returns nil controllers if the reverse proxy isn't installed.
+// If 'checkPresence' is false, the presence of the reverse proxy isn't checked on the gateways.
+func (w *worker) proxyControllers(checkPresence bool) (ReverseProxyController, ReverseProxyController, error) {
+	svc := w.cluster.GetService(w.feature.task)
+	netprops, err := w.cluster.GetNetworkConfig(w.feature.task)
+	if err != nil {
+		return nil, nil, err
+	}
+	mn, err := metadata.LoadNetwork(svc, netprops.NetworkID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	network, err := mn.Get()
+	if err != nil {
+		return nil, nil, err
+	}
+	primaryController, err := newReverseProxyController(svc, network, true, checkPresence)
+	if err != nil {
+		switch err.(type) {
+		case scerr.ErrNotFound:
+			return nil, nil, nil
+		default:
+			return nil, nil, scerr.InvalidRequestError(fmt.Sprintf("failed to apply reverse proxy rules: %s", err.Error()))
+		}
+	}
+	var secondaryController ReverseProxyController
+	if network.SecondaryGatewayID != "" {
+		secondaryController, err = newReverseProxyController(svc, network, false, checkPresence)
+		if err != nil {
+			switch err.(type) {
+			case scerr.ErrNotFound:
+				return nil, nil, scerr.InconsistentError("reverseproxy found on primary gateway but not on secondary gateway")
+			default:
+				return nil, nil, scerr.InvalidRequestError(fmt.Sprintf("failed to apply reverse proxy rules: %s", err.Error()))
+			}
+		}
+	}
+	return primaryController, secondaryController, nil
+}
+
+// proxyRuleTargets returns the targets of the reverse proxy rule; returns false if the rule doesn't apply
+func (w *worker) proxyRuleTargets(rule map[interface{}]interface{}) (stepTargets, bool) {
+	targets := stepTargets{}
+	anon, ok := rule["targets"].(map[interface{}]interface{})
+	if !ok {
+		// If no 'targets' key found, applies on host only
+		if w.cluster != nil {
+			return nil, false
+		}
+		targets[targetHosts] = "yes"
+		return targets, true
+	}
+	for i, j := range anon {
+		switch j := j.(type) {
+		case bool:
+			if j {
+				targets[i.(string)] = "yes"
+			} else {
+				targets[i.(string)] = "no"
+			}
+		case string:
+			targets[i.(string)] = j
+		}
+	}
+	return targets, true
+}
+
 func taskApplyProxyRule(task concurrency.Task, params concurrency.TaskParameters) (concurrency.TaskResult, error) {
-	ctrl := params.(data.Map)["ctrl"].(*KongController)
+	ctrl := params.(data.Map)["ctrl"].(ReverseProxyController)
 	rule := params.(data.Map)["rule"].(map[interface{}]interface{})
 	vars := params.(data.Map)["vars"].(*Variables)
+	feature := params.(data.Map)["feature"].(string)
 
 	hostName := (*vars)["Hostname"].(string)
-	ruleName, err := ctrl.Apply(rule, vars)
+	ruleName, err := ctrl.Apply(feature, rule, vars)
 
 	// FIXME Check this later
 	if err != nil {
@@ -938,7 +979,8 @@ func (w *worker) identifyHosts(targets stepTargets) ([]*pb.Host, error) {
 		}
 		hostsList = append(hostsList, host)
 	case "*":
-		if w.action == action.Add {
+		// In dry run and in verification, hosts aren't checked
+		if w.action == action.Add && w.settings.dryRun == nil && w.settings.verify == nil {
 			all, err = w.identifyConcernedMasters()
 		} else {
 			all, err = w.identifyAllRunningMasters()
@@ -957,7 +999,8 @@ func (w *worker) identifyHosts(targets stepTargets) ([]*pb.Host, error) {
 		}
 		hostsList = append(hostsList, host)
 	case "*":
-		if w.action == action.Add {
+		// In dry run and in verification, hosts aren't checked
+		if w.action == action.Add && w.settings.dryRun == nil && w.settings.verify == nil {
 			all, err = w.identifyConcernedNodes()
 		} else {
 			all, err = w.identifyAllRunningNodes()
@@ -976,7 +1019,8 @@ func (w *worker) identifyHosts(targets stepTargets) ([]*pb.Host, error) {
 		}
 		hostsList = append(hostsList, host)
 	case "*":
-		if w.action == action.Add {
+		// In dry run and in verification, hosts aren't checked
+		if w.action == action.Add && w.settings.dryRun == nil && w.settings.verify == nil {
 			all, err = w.identifyConcernedGateways()
 		} else {
 			all, err = w.identifyAllGateways()
@@ -42,6 +42,8 @@ func init() {
 		edgeproxy4networkFeature(),
 		keycloak4platformFeature(),
 		kubernetesFeature(),
+		k3sFeature(),
+		nomadFeature(),
 		proxycacheServerFeature(),
 		proxycacheClientFeature(),
 		apacheIgniteFeature(),
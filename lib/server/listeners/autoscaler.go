@@ -0,0 +1,174 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package listeners
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	googleprotobuf "github.com/golang/protobuf/ptypes/empty"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/CS-SI/SafeScale/lib"
+	"github.com/CS-SI/SafeScale/lib/server/cluster/autoscale"
+	"github.com/CS-SI/SafeScale/lib/server/handlers"
+	srvutils "github.com/CS-SI/SafeScale/lib/server/utils"
+	"github.com/CS-SI/SafeScale/lib/utils/concurrency"
+	"github.com/CS-SI/SafeScale/lib/utils/scerr"
+)
+
+// AutoscalerHandler ...
+var AutoscalerHandler = handlers.NewAutoscalerHandler
+
+// safescale cluster autoscale enable cluster1 --pool gpu --min 1 --max 5
+// safescale cluster autoscale disable cluster1 --pool gpu
+// safescale cluster autoscale list
+
+// AutoscalerListener autoscaler service server grpc
+type AutoscalerListener struct{}
+
+// toPBAutoscaler converts an autoscaler to its protobuf message
+func toPBAutoscaler(in *handlers.Autoscaler) *pb.Autoscaler {
+	out := &pb.Autoscaler{
+		Id:     in.ID,
+		Tenant: in.Tenant,
+		Policy: &pb.AutoscalePolicy{
+			Cluster:       in.Cluster,
+			Pool:          in.Pool,
+			MinNodes:      int32(in.Policy.MinNodes),
+			MaxNodes:      int32(in.Policy.MaxNodes),
+			Cooldown:      int32(in.Policy.Cooldown.Seconds()),
+			Interval:      int32(in.Policy.Interval.Seconds()),
+			ScaleUpLoad:   float32(in.Policy.ScaleUpLoad),
+			ScaleDownLoad: float32(in.Policy.ScaleDownLoad),
+		},
+		Created: in.Created.Unix(),
+		Events:  srvutils.JobEvents(in.ID),
+	}
+	if last := in.LastScaling(); !last.IsZero() {
+		out.LastScaling = last.Unix()
+	}
+	return out
+}
+
+// Enable starts the autoscaling of a node pool of a cluster
+func (s *AutoscalerListener) Enable(ctx context.Context, in *pb.AutoscalePolicy) (a *pb.Autoscaler, err error) {
+	if s == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, scerr.InvalidInstanceError().Error())
+	}
+	if in == nil {
+		return nil, status.Errorf(codes.InvalidArgument, scerr.InvalidParameterError("in", "cannot be nil").Error())
+	}
+	clusterName := in.GetCluster()
+	if clusterName == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "cannot enable autoscaling: cluster name not set")
+	}
+
+	tracer := concurrency.NewTracer(nil, fmt.Sprintf("('%s', '%s')", clusterName, in.GetPool()), true).WithStopwatch().GoingIn()
+	defer tracer.OnExitTrace()()
+	defer scerr.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	ctx, cancelFunc := context.WithCancel(ctx)
+	if err := srvutils.JobRegister(ctx, cancelFunc, "Enable autoscaling of cluster "+clusterName); err == nil {
+		defer srvutils.JobDeregister(ctx)
+	}
+
+	tenant := GetCurrentTenant()
+	if tenant == nil {
+		log.Info("Can't enable autoscaling: no tenant set")
+		return nil, status.Errorf(codes.FailedPrecondition, "cannot enable autoscaling: no tenant set")
+	}
+
+	policy := autoscale.Policy{
+		MinNodes:      int(in.GetMinNodes()),
+		MaxNodes:      int(in.GetMaxNodes()),
+		Cooldown:      time.Duration(in.GetCooldown()) * time.Second,
+		Interval:      time.Duration(in.GetInterval()) * time.Second,
+		ScaleUpLoad:   float64(in.GetScaleUpLoad()),
+		ScaleDownLoad: float64(in.GetScaleDownLoad()),
+	}
+	handler := AutoscalerHandler(tenant.Service)
+	autoscaler, err := handler.Enable(ctx, tenant.name, clusterName, in.GetPool(), policy)
+	if err != nil {
+		return nil, status.Errorf(schedulerErrorCode(err), scerr.Wrap(err, "cannot enable autoscaling").Error())
+	}
+	return toPBAutoscaler(autoscaler), nil
+}
+
+// Disable stops the autoscaling of a node pool of a cluster
+func (s *AutoscalerListener) Disable(ctx context.Context, in *pb.AutoscalePolicy) (empty *googleprotobuf.Empty, err error) {
+	empty = &googleprotobuf.Empty{}
+	if s == nil {
+		return empty, status.Errorf(codes.FailedPrecondition, scerr.InvalidInstanceError().Error())
+	}
+	if in == nil {
+		return empty, status.Errorf(codes.InvalidArgument, scerr.InvalidParameterError("in", "cannot be nil").Error())
+	}
+	clusterName := in.GetCluster()
+	if clusterName == "" {
+		return empty, status.Errorf(codes.InvalidArgument, "cannot disable autoscaling: cluster name not set")
+	}
+
+	tracer := concurrency.NewTracer(nil, fmt.Sprintf("('%s', '%s')", clusterName, in.GetPool()), true).WithStopwatch().GoingIn()
+	defer tracer.OnExitTrace()()
+	defer scerr.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	tenant := GetCurrentTenant()
+	if tenant == nil {
+		log.Info("Can't disable autoscaling: no tenant set")
+		return empty, status.Errorf(codes.FailedPrecondition, "cannot disable autoscaling: no tenant set")
+	}
+
+	handler := AutoscalerHandler(tenant.Service)
+	err = handler.Disable(ctx, tenant.name, clusterName, in.GetPool())
+	if err != nil {
+		return empty, status.Errorf(schedulerErrorCode(err), scerr.Wrap(err, "cannot disable autoscaling").Error())
+	}
+	return empty, nil
+}
+
+// List returns the autoscalers running in safescaled on the clusters of the current tenant
+func (s *AutoscalerListener) List(ctx context.Context, in *googleprotobuf.Empty) (al *pb.AutoscalerList, err error) {
+	if s == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, scerr.InvalidInstanceError().Error())
+	}
+
+	tracer := concurrency.NewTracer(nil, "", true).WithStopwatch().GoingIn()
+	defer tracer.OnExitTrace()()
+	defer scerr.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	tenant := GetCurrentTenant()
+	if tenant == nil {
+		log.Info("Can't list autoscalers: no tenant set")
+		return nil, status.Errorf(codes.FailedPrecondition, "cannot list autoscalers: no tenant set")
+	}
+
+	handler := AutoscalerHandler(tenant.Service)
+	list, err := handler.List(ctx, tenant.name)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, scerr.Wrap(err, "cannot list autoscalers").Error())
+	}
+
+	var pbAutoscalers []*pb.Autoscaler
+	for _, a := range list {
+		pbAutoscalers = append(pbAutoscalers, toPBAutoscaler(a))
+	}
+	return &pb.AutoscalerList{Autoscalers: pbAutoscalers}, nil
+}
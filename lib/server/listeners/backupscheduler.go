@@ -0,0 +1,157 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package listeners
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	googleprotobuf "github.com/golang/protobuf/ptypes/empty"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/CS-SI/SafeScale/lib"
+	"github.com/CS-SI/SafeScale/lib/server/handlers"
+	srvutils "github.com/CS-SI/SafeScale/lib/server/utils"
+	"github.com/CS-SI/SafeScale/lib/utils/concurrency"
+	"github.com/CS-SI/SafeScale/lib/utils/scerr"
+)
+
+// BackupSchedulerHandler ...
+var BackupSchedulerHandler = handlers.NewBackupSchedulerHandler
+
+// safescale cluster backup schedule enable cluster1 --interval 86400 --keep 7
+// safescale cluster backup schedule disable cluster1
+// safescale cluster backup schedule list
+
+// BackupSchedulerListener backup scheduler service server grpc
+type BackupSchedulerListener struct{}
+
+// toPBBackupScheduler converts a backup scheduler to its protobuf message
+func toPBBackupScheduler(in *handlers.BackupScheduler) *pb.BackupScheduler {
+	out := &pb.BackupScheduler{
+		Id:     in.ID,
+		Tenant: in.Tenant,
+		Policy: &pb.BackupPolicy{
+			Cluster:  in.Cluster,
+			Interval: int32(in.Interval.Seconds()),
+			Keep:     int32(in.Keep),
+			Bucket:   in.Bucket,
+		},
+		Created: in.Created.Unix(),
+		Events:  srvutils.JobEvents(in.ID),
+	}
+	if last := in.LastBackup(); !last.IsZero() {
+		out.LastBackup = last.Unix()
+	}
+	return out
+}
+
+// Enable starts the periodic backup of a cluster
+func (s *BackupSchedulerListener) Enable(ctx context.Context, in *pb.BackupPolicy) (bs *pb.BackupScheduler, err error) {
+	if s == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, scerr.InvalidInstanceError().Error())
+	}
+	if in == nil {
+		return nil, status.Errorf(codes.InvalidArgument, scerr.InvalidParameterError("in", "cannot be nil").Error())
+	}
+	clusterName := in.GetCluster()
+	if clusterName == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "cannot enable scheduled backups: cluster name not set")
+	}
+
+	tracer := concurrency.NewTracer(nil, fmt.Sprintf("('%s')", clusterName), true).WithStopwatch().GoingIn()
+	defer tracer.OnExitTrace()()
+	defer scerr.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	ctx, cancelFunc := context.WithCancel(ctx)
+	if err := srvutils.JobRegister(ctx, cancelFunc, "Enable scheduled backups of cluster "+clusterName); err == nil {
+		defer srvutils.JobDeregister(ctx)
+	}
+
+	tenant := GetCurrentTenant()
+	if tenant == nil {
+		log.Info("Can't enable scheduled backups: no tenant set")
+		return nil, status.Errorf(codes.FailedPrecondition, "cannot enable scheduled backups: no tenant set")
+	}
+
+	handler := BackupSchedulerHandler(tenant.Service)
+	interval := time.Duration(in.GetInterval()) * time.Second
+	scheduler, err := handler.Enable(ctx, tenant.name, clusterName, interval, int(in.GetKeep()), in.GetBucket())
+	if err != nil {
+		return nil, status.Errorf(schedulerErrorCode(err), scerr.Wrap(err, "cannot enable scheduled backups").Error())
+	}
+	return toPBBackupScheduler(scheduler), nil
+}
+
+// Disable stops the periodic backup of a cluster
+func (s *BackupSchedulerListener) Disable(ctx context.Context, in *pb.BackupPolicy) (empty *googleprotobuf.Empty, err error) {
+	empty = &googleprotobuf.Empty{}
+	if s == nil {
+		return empty, status.Errorf(codes.FailedPrecondition, scerr.InvalidInstanceError().Error())
+	}
+	if in == nil {
+		return empty, status.Errorf(codes.InvalidArgument, scerr.InvalidParameterError("in", "cannot be nil").Error())
+	}
+	clusterName := in.GetCluster()
+	if clusterName == "" {
+		return empty, status.Errorf(codes.InvalidArgument, "cannot disable scheduled backups: cluster name not set")
+	}
+
+	tracer := concurrency.NewTracer(nil, fmt.Sprintf("('%s')", clusterName), true).WithStopwatch().GoingIn()
+	defer tracer.OnExitTrace()()
+	defer scerr.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	tenant := GetCurrentTenant()
+	if tenant == nil {
+		log.Info("Can't disable scheduled backups: no tenant set")
+		return empty, status.Errorf(codes.FailedPrecondition, "cannot disable scheduled backups: no tenant set")
+	}
+
+	handler := BackupSchedulerHandler(tenant.Service)
+	err = handler.Disable(ctx, tenant.name, clusterName)
+	if err != nil {
+		return empty, status.Errorf(schedulerErrorCode(err), scerr.Wrap(err, "cannot disable scheduled backups").Error())
+	}
+	return empty, nil
+}
+
+// List returns the backup schedulers running in safescaled
+func (s *BackupSchedulerListener) List(ctx context.Context, in *googleprotobuf.Empty) (bl *pb.BackupSchedulerList, err error) {
+	if s == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, scerr.InvalidInstanceError().Error())
+	}
+
+	tracer := concurrency.NewTracer(nil, "", true).WithStopwatch().GoingIn()
+	defer tracer.OnExitTrace()()
+	defer scerr.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	// Backup schedulers of all the tenants are listed, no tenant needed
+	handler := BackupSchedulerHandler(nil)
+	list, err := handler.List(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, scerr.Wrap(err, "cannot list backup schedulers").Error())
+	}
+
+	var pbSchedulers []*pb.BackupScheduler
+	for _, bs := range list {
+		pbSchedulers = append(pbSchedulers, toPBBackupScheduler(bs))
+	}
+	return &pb.BackupSchedulerList{BackupSchedulers: pbSchedulers}, nil
+}
@@ -0,0 +1,158 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package listeners
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	googleprotobuf "github.com/golang/protobuf/ptypes/empty"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/CS-SI/SafeScale/lib"
+	"github.com/CS-SI/SafeScale/lib/server/handlers"
+	srvutils "github.com/CS-SI/SafeScale/lib/server/utils"
+	"github.com/CS-SI/SafeScale/lib/utils/concurrency"
+	"github.com/CS-SI/SafeScale/lib/utils/scerr"
+)
+
+// HealthCheckerHandler ...
+var HealthCheckerHandler = handlers.NewHealthCheckerHandler
+
+// safescale cluster health enable cluster1 --interval 60 --repair
+// safescale cluster health disable cluster1
+// safescale cluster health list
+
+// HealthCheckerListener health checker service server grpc
+type HealthCheckerListener struct{}
+
+// toPBHealthChecker converts a health checker to its protobuf message
+func toPBHealthChecker(in *handlers.HealthChecker) *pb.HealthChecker {
+	out := &pb.HealthChecker{
+		Id:     in.ID,
+		Tenant: in.Tenant,
+		Policy: &pb.HealthCheckPolicy{
+			Cluster:     in.Cluster,
+			Interval:    int32(in.Interval.Seconds()),
+			Repair:      in.Repair,
+			MaxFailures: int32(in.MaxFailures),
+		},
+		Created: in.Created.Unix(),
+		Events:  srvutils.JobEvents(in.ID),
+	}
+	if last, state := in.LastCheck(); !last.IsZero() {
+		out.LastCheck = last.Unix()
+		out.State = state.String()
+	}
+	return out
+}
+
+// Enable starts the periodic health check of a cluster
+func (s *HealthCheckerListener) Enable(ctx context.Context, in *pb.HealthCheckPolicy) (hc *pb.HealthChecker, err error) {
+	if s == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, scerr.InvalidInstanceError().Error())
+	}
+	if in == nil {
+		return nil, status.Errorf(codes.InvalidArgument, scerr.InvalidParameterError("in", "cannot be nil").Error())
+	}
+	clusterName := in.GetCluster()
+	if clusterName == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "cannot enable health check: cluster name not set")
+	}
+
+	tracer := concurrency.NewTracer(nil, fmt.Sprintf("('%s')", clusterName), true).WithStopwatch().GoingIn()
+	defer tracer.OnExitTrace()()
+	defer scerr.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	ctx, cancelFunc := context.WithCancel(ctx)
+	if err := srvutils.JobRegister(ctx, cancelFunc, "Enable health check of cluster "+clusterName); err == nil {
+		defer srvutils.JobDeregister(ctx)
+	}
+
+	tenant := GetCurrentTenant()
+	if tenant == nil {
+		log.Info("Can't enable health check: no tenant set")
+		return nil, status.Errorf(codes.FailedPrecondition, "cannot enable health check: no tenant set")
+	}
+
+	handler := HealthCheckerHandler(tenant.Service)
+	interval := time.Duration(in.GetInterval()) * time.Second
+	checker, err := handler.Enable(ctx, tenant.name, clusterName, interval, in.GetRepair(), int(in.GetMaxFailures()))
+	if err != nil {
+		return nil, status.Errorf(schedulerErrorCode(err), scerr.Wrap(err, "cannot enable health check").Error())
+	}
+	return toPBHealthChecker(checker), nil
+}
+
+// Disable stops the periodic health check of a cluster
+func (s *HealthCheckerListener) Disable(ctx context.Context, in *pb.HealthCheckPolicy) (empty *googleprotobuf.Empty, err error) {
+	empty = &googleprotobuf.Empty{}
+	if s == nil {
+		return empty, status.Errorf(codes.FailedPrecondition, scerr.InvalidInstanceError().Error())
+	}
+	if in == nil {
+		return empty, status.Errorf(codes.InvalidArgument, scerr.InvalidParameterError("in", "cannot be nil").Error())
+	}
+	clusterName := in.GetCluster()
+	if clusterName == "" {
+		return empty, status.Errorf(codes.InvalidArgument, "cannot disable health check: cluster name not set")
+	}
+
+	tracer := concurrency.NewTracer(nil, fmt.Sprintf("('%s')", clusterName), true).WithStopwatch().GoingIn()
+	defer tracer.OnExitTrace()()
+	defer scerr.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	tenant := GetCurrentTenant()
+	if tenant == nil {
+		log.Info("Can't disable health check: no tenant set")
+		return empty, status.Errorf(codes.FailedPrecondition, "cannot disable health check: no tenant set")
+	}
+
+	handler := HealthCheckerHandler(tenant.Service)
+	err = handler.Disable(ctx, tenant.name, clusterName)
+	if err != nil {
+		return empty, status.Errorf(schedulerErrorCode(err), scerr.Wrap(err, "cannot disable health check").Error())
+	}
+	return empty, nil
+}
+
+// List returns the health checkers running in safescaled
+func (s *HealthCheckerListener) List(ctx context.Context, in *googleprotobuf.Empty) (hl *pb.HealthCheckerList, err error) {
+	if s == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, scerr.InvalidInstanceError().Error())
+	}
+
+	tracer := concurrency.NewTracer(nil, "", true).WithStopwatch().GoingIn()
+	defer tracer.OnExitTrace()()
+	defer scerr.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	// Health checkers of all the tenants are listed, no tenant needed
+	handler := HealthCheckerHandler(nil)
+	list, err := handler.List(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, scerr.Wrap(err, "cannot list health checkers").Error())
+	}
+
+	var pbCheckers []*pb.HealthChecker
+	for _, hc := range list {
+		pbCheckers = append(pbCheckers, toPBHealthChecker(hc))
+	}
+	return &pb.HealthCheckerList{HealthCheckers: pbCheckers}, nil
+}
@@ -84,7 +84,7 @@ func (s *HostListener) Start(ctx context.Context, in *pb.Reference) (empty *goog
 		return empty, status.Errorf(codes.FailedPrecondition, fmt.Errorf("failed to register the process : %s", err.Error()).Error())
 	}
 
-	tenant := GetCurrentTenant()
+	tenant := GetReferencedTenant(in.GetTenantId())
 	if tenant == nil {
 		log.Info("Can't start host: no tenant set")
 		return empty, status.Errorf(codes.FailedPrecondition, "cannot start host: no tenant set")
@@ -120,7 +120,7 @@ func (s *HostListener) Stop(ctx context.Context, in *pb.Reference) (empty *googl
 		defer srvutils.JobDeregister(ctx)
 	}
 
-	tenant := GetCurrentTenant()
+	tenant := GetReferencedTenant(in.GetTenantId())
 	if tenant == nil {
 		log.Info("Can't stop host: no tenant set")
 		return empty, status.Errorf(codes.FailedPrecondition, "cannot stop host: no tenant set")
@@ -157,7 +157,7 @@ func (s *HostListener) Reboot(ctx context.Context, in *pb.Reference) (empty *goo
 		defer srvutils.JobDeregister(ctx)
 	}
 
-	tenant := GetCurrentTenant()
+	tenant := GetReferencedTenant(in.GetTenantId())
 	if tenant == nil {
 		log.Info("Can't reboot host: no tenant set")
 		return empty, status.Errorf(codes.FailedPrecondition, "cannot reboot host: no tenant set")
@@ -230,7 +230,7 @@ func (s *HostListener) Create(ctx context.Context, in *pb.HostDefinition) (h *pb
 		defer srvutils.JobDeregister(ctx)
 	}
 
-	tenant := GetCurrentTenant()
+	tenant := GetReferencedTenant(in.GetTenant())
 	if tenant == nil {
 		log.Info("Can't create host: no tenant set")
 		return nil, status.Errorf(codes.FailedPrecondition, "cannot create host: no tenant set")
@@ -288,7 +288,7 @@ func (s *HostListener) Resize(ctx context.Context, in *pb.HostDefinition) (h *pb
 		defer srvutils.JobDeregister(ctx)
 	}
 
-	tenant := GetCurrentTenant()
+	tenant := GetReferencedTenant(in.GetTenant())
 	if tenant == nil {
 		log.Info("Can't resize host: no tenant set")
 		return nil, status.Errorf(codes.FailedPrecondition, "cannot resize host: no tenant set")
@@ -332,7 +332,7 @@ func (s *HostListener) Status(ctx context.Context, in *pb.Reference) (ht *pb.Hos
 		defer srvutils.JobDeregister(ctx)
 	}
 
-	tenant := GetCurrentTenant()
+	tenant := GetReferencedTenant(in.GetTenantId())
 	if tenant == nil {
 		log.Info("Can't get host status: no tenant set")
 		return nil, status.Errorf(codes.FailedPrecondition, "cannot get host status: no tenant set")
@@ -368,7 +368,7 @@ func (s *HostListener) Inspect(ctx context.Context, in *pb.Reference) (h *pb.Hos
 		defer srvutils.JobDeregister(ctx)
 	}
 
-	tenant := GetCurrentTenant()
+	tenant := GetReferencedTenant(in.GetTenantId())
 	if tenant == nil {
 		log.Info("Can't inspect host: no tenant set")
 		return nil, status.Errorf(codes.FailedPrecondition, "cannot inspect host: no tenant set")
@@ -405,7 +405,7 @@ func (s *HostListener) Delete(ctx context.Context, in *pb.Reference) (empty *goo
 		defer srvutils.JobDeregister(ctx)
 	}
 
-	tenant := GetCurrentTenant()
+	tenant := GetReferencedTenant(in.GetTenantId())
 	if tenant == nil {
 		log.Info("Can't delete host: no tenant set")
 		return empty, status.Errorf(codes.FailedPrecondition, "cannot delete host: no tenant set")
@@ -442,13 +442,13 @@ func (s *HostListener) SSH(ctx context.Context, in *pb.Reference) (sc *pb.SshCon
 		defer srvutils.JobDeregister(ctx)
 	}
 
-	tenant := GetCurrentTenant()
+	tenant := GetReferencedTenant(in.GetTenantId())
 	if tenant == nil {
 		log.Info("cannot delete host: no tenant set")
 		return nil, status.Errorf(codes.FailedPrecondition, "cannot ssh host: no tenant set")
 	}
 
-	handler := HostHandler(currentTenant.Service)
+	handler := HostHandler(tenant.Service)
 	sshConfig, err := handler.SSH(ctx, ref)
 	if err != nil {
 		return nil, err
@@ -104,7 +104,7 @@ func (s *JobManagerListener) List(ctx context.Context, in *googleprotobuf.Empty)
 	}
 	var pbProcessList []*pb.JobDefinition
 	for uuid, info := range processMap {
-		pbProcessList = append(pbProcessList, &pb.JobDefinition{Uuid: uuid, Info: info})
+		pbProcessList = append(pbProcessList, &pb.JobDefinition{Uuid: uuid, Info: info, Events: srvutils.JobEvents(uuid)})
 	}
 
 	return &pb.JobList{List: pbProcessList}, nil
@@ -65,7 +65,7 @@ func (s *NetworkListener) Create(ctx context.Context, in *pb.NetworkDefinition)
 		defer srvutils.JobDeregister(ctx)
 	}
 
-	tenant := GetCurrentTenant()
+	tenant := GetReferencedTenant(in.GetTenant())
 	if tenant == nil {
 		// log.Info("Can't create network: no tenant set")
 		return nil, status.Errorf(codes.FailedPrecondition, "cannot create network: no tenant set")
@@ -176,13 +176,13 @@ func (s *NetworkListener) Inspect(ctx context.Context, in *pb.Reference) (net *p
 		defer srvutils.JobDeregister(ctx)
 	}
 
-	tenant := GetCurrentTenant()
+	tenant := GetReferencedTenant(in.GetTenantId())
 	if tenant == nil {
 		log.Info("Can't inspect network: no tenant set")
 		return nil, status.Errorf(codes.FailedPrecondition, "cannot inspect network: no tenant set")
 	}
 
-	handler := NetworkHandler(currentTenant.Service)
+	handler := NetworkHandler(tenant.Service)
 	network, err := handler.Inspect(ctx, ref)
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, err.Error())
@@ -216,13 +216,13 @@ func (s *NetworkListener) Delete(ctx context.Context, in *pb.Reference) (buf *go
 		defer srvutils.JobDeregister(ctx)
 	}
 
-	tenant := GetCurrentTenant()
+	tenant := GetReferencedTenant(in.GetTenantId())
 	if tenant == nil {
 		// log.Info("Can't delete network: no tenant set")
 		return nil, status.Errorf(codes.FailedPrecondition, "cannot delete network: no tenant set")
 	}
 
-	handler := NetworkHandler(currentTenant.Service)
+	handler := NetworkHandler(tenant.Service)
 	err = handler.Delete(ctx, ref)
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, err.Error())
@@ -254,13 +254,13 @@ func (s *NetworkListener) Destroy(ctx context.Context, in *pb.Reference) (buf *g
 		defer srvutils.JobDeregister(ctx)
 	}
 
-	tenant := GetCurrentTenant()
+	tenant := GetReferencedTenant(in.GetTenantId())
 	if tenant == nil {
 		// log.Info("Can't delete network: no tenant set")
 		return nil, status.Errorf(codes.FailedPrecondition, "cannot delete network: no tenant set")
 	}
 
-	handler := NetworkHandler(currentTenant.Service)
+	handler := NetworkHandler(tenant.Service)
 	err = handler.Destroy(ctx, ref)
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, err.Error())
@@ -19,6 +19,10 @@ package listeners
 import (
 	"context"
 	"fmt"
+	"io"
+	"os"
+	"path"
+	"sync"
 
 	log "github.com/sirupsen/logrus"
 	"google.golang.org/grpc/codes"
@@ -67,7 +71,7 @@ func (s *SSHListener) Run(ctx context.Context, in *pb.SshCommand) (sr *pb.SshRes
 		defer srvutils.JobDeregister(ctx)
 	}
 
-	tenant := GetCurrentTenant()
+	tenant := GetReferencedTenant(in.GetHost().GetTenantId())
 	if tenant == nil {
 		// log.Info("Can't execute ssh command: no tenant set")
 		return nil, status.Errorf(codes.FailedPrecondition, "cannot execute ssh command: no tenant set")
@@ -107,7 +111,7 @@ func (s *SSHListener) Copy(ctx context.Context, in *pb.SshCopyCommand) (sr *pb.S
 		defer srvutils.JobDeregister(ctx)
 	}
 
-	tenant := GetCurrentTenant()
+	tenant := GetReferencedTenant(in.GetTenantId())
 	if tenant == nil {
 		// log.Info("Can't copy by ssh command: no tenant set")
 		return nil, status.Errorf(codes.FailedPrecondition, "cannot copy by ssh: no tenant set")
@@ -128,3 +132,463 @@ func (s *SSHListener) Copy(ctx context.Context, in *pb.SshCopyCommand) (sr *pb.S
 		OutputErr: stderr,
 	}, nil
 }
+
+// Stat lists the files of a path on an host
+func (s *SSHListener) Stat(ctx context.Context, in *pb.SshStatRequest) (sr *pb.SshStatResponse, err error) {
+	if s == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, scerr.InvalidInstanceError().Error())
+	}
+	if in == nil {
+		return nil, status.Errorf(codes.InvalidArgument, scerr.InvalidParameterError("in", "cannot be nil").Error())
+	}
+	host := srvutils.GetReference(in.GetHost())
+	if host == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "cannot stat: no host reference provided")
+	}
+
+	tracer := concurrency.NewTracer(nil, fmt.Sprintf("('%s', '%s')", host, in.GetPath()), true).WithStopwatch().GoingIn()
+	defer tracer.OnExitTrace()()
+	defer scerr.OnExitLogErrorWithLevel(tracer.TraceMessage(""), &err, log.DebugLevel)()
+
+	tenant := GetReferencedTenant(in.GetHost().GetTenantId())
+	if tenant == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "cannot stat: no tenant set")
+	}
+
+	handler := SSHHandler(tenant.Service)
+	files, isDir, err := handler.Stat(ctx, host, in.GetPath(), in.GetChecksum())
+	if err != nil {
+		if _, ok := err.(scerr.ErrNotFound); ok {
+			return nil, status.Errorf(codes.NotFound, err.Error())
+		}
+		return nil, status.Errorf(codes.Internal, err.Error())
+	}
+	resp := &pb.SshStatResponse{IsDir: isDir}
+	for _, f := range files {
+		resp.Files = append(resp.Files, srvutils.ToPBSshFileInfo(f))
+	}
+	return resp, nil
+}
+
+// Upload receives files from the client and writes them on an host
+func (s *SSHListener) Upload(stream pb.SshService_UploadServer) (err error) {
+	if s == nil {
+		return status.Errorf(codes.FailedPrecondition, scerr.InvalidInstanceError().Error())
+	}
+
+	tracer := concurrency.NewTracer(nil, "", true).WithStopwatch().GoingIn()
+	defer tracer.OnExitTrace()()
+	defer scerr.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	ctx, cancelFunc := context.WithCancel(stream.Context())
+	defer cancelFunc()
+
+	var (
+		handler                     *handlers.SSHHandler
+		sshConfig                   *system.SSHConfig
+		host, destination, filePath string
+		current                     *pb.SshFileInfo
+		writer                      io.WriteCloser
+		files                       []*pb.SshFileInfo
+		transferred                 int64
+		registered                  bool
+	)
+	defer func() {
+		if writer != nil {
+			system.AbortRemoteStream(writer)
+		}
+		if registered {
+			srvutils.JobDeregister(ctx)
+		}
+	}()
+
+	for {
+		msg, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if host == "" {
+			host = srvutils.GetReference(msg.GetHost())
+			destination = msg.GetDestination()
+			if host == "" || destination == "" {
+				return status.Errorf(codes.InvalidArgument, "cannot upload: host and destination must be set in first message")
+			}
+			tenant := GetReferencedTenant(msg.GetHost().GetTenantId())
+			if tenant == nil {
+				return status.Errorf(codes.FailedPrecondition, "cannot upload: no tenant set")
+			}
+			handler = SSHHandler(tenant.Service)
+			// Resolved once for all the files of the stream
+			sshConfig, err = handler.GetHostConfig(ctx, host)
+			if err != nil {
+				if _, ok := err.(scerr.ErrNotFound); ok {
+					return status.Errorf(codes.NotFound, err.Error())
+				}
+				return status.Errorf(codes.Internal, err.Error())
+			}
+			log.Infof("Listeners: ssh upload to %s:%s", host, destination)
+			if err := srvutils.JobRegister(ctx, cancelFunc, "SSH Upload to "+host+":"+destination); err == nil {
+				registered = true
+			}
+		}
+
+		chunk := msg.GetChunk()
+		if chunk == nil {
+			continue
+		}
+		if chunk.GetInfo() != nil {
+			if writer != nil {
+				return status.Errorf(codes.InvalidArgument, "cannot upload: file '%s' not terminated", current.GetPath())
+			}
+			current = chunk.GetInfo()
+			filePath = path.Join(destination, current.GetPath())
+			writer, err = handler.OpenWriterWithConfig(sshConfig, filePath, chunk.GetOffset(), os.FileMode(current.GetMode()))
+			if err != nil {
+				return status.Errorf(codes.Internal, err.Error())
+			}
+		}
+		if writer == nil {
+			return status.Errorf(codes.InvalidArgument, "cannot upload: received data without file information")
+		}
+
+		n, err := writer.Write(chunk.GetData())
+		transferred += int64(n)
+		if err != nil {
+			return status.Errorf(codes.Internal, err.Error())
+		}
+		if chunk.GetLast() {
+			err = writer.Close()
+			writer = nil
+			if err != nil {
+				return status.Errorf(codes.Internal, err.Error())
+			}
+			err = handler.VerifyChecksumWithConfig(sshConfig, filePath, current.GetChecksum())
+			if err != nil {
+				return status.Errorf(codes.DataLoss, "%s on host '%s'", err.Error(), host)
+			}
+			files = append(files, current)
+		}
+	}
+	if writer != nil {
+		return status.Errorf(codes.InvalidArgument, "cannot upload: file '%s' not terminated", current.GetPath())
+	}
+
+	return stream.SendAndClose(&pb.SshTransferResponse{Files: files, Transferred: transferred})
+}
+
+// Download sends to the client the files of a path on an host
+func (s *SSHListener) Download(in *pb.SshDownloadRequest, stream pb.SshService_DownloadServer) (err error) {
+	if s == nil {
+		return status.Errorf(codes.FailedPrecondition, scerr.InvalidInstanceError().Error())
+	}
+	if in == nil {
+		return status.Errorf(codes.InvalidArgument, scerr.InvalidParameterError("in", "cannot be nil").Error())
+	}
+	host := srvutils.GetReference(in.GetHost())
+	source := in.GetSource()
+	if host == "" || source == "" {
+		return status.Errorf(codes.InvalidArgument, "cannot download: host and source must be set")
+	}
+
+	tracer := concurrency.NewTracer(nil, fmt.Sprintf("('%s', '%s')", host, source), true).WithStopwatch().GoingIn()
+	defer tracer.OnExitTrace()()
+	defer scerr.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	log.Infof("Listeners: ssh download from %s:%s", host, source)
+
+	ctx, cancelFunc := context.WithCancel(stream.Context())
+	defer cancelFunc()
+	if err := srvutils.JobRegister(ctx, cancelFunc, "SSH Download from "+host+":"+source); err == nil {
+		defer srvutils.JobDeregister(ctx)
+	}
+
+	tenant := GetReferencedTenant(in.GetHost().GetTenantId())
+	if tenant == nil {
+		return status.Errorf(codes.FailedPrecondition, "cannot download: no tenant set")
+	}
+
+	handler := SSHHandler(tenant.Service)
+	// Resolved once for all the files to send
+	var files []system.FileInfo
+	sshConfig, err := handler.GetHostConfig(ctx, host)
+	if err == nil {
+		files, _, err = handler.StatWithConfig(sshConfig, source, true)
+	}
+	if err != nil {
+		if _, ok := err.(scerr.ErrNotFound); ok {
+			return status.Errorf(codes.NotFound, err.Error())
+		}
+		return status.Errorf(codes.Internal, err.Error())
+	}
+
+	buf := make([]byte, system.TransferChunkSize)
+	for _, f := range files {
+		offset := in.GetOffsets()[f.Path]
+		if offset < 0 || offset > f.Size {
+			offset = 0
+		}
+		filePath := source
+		if f.Path != "" {
+			filePath = path.Join(source, f.Path)
+		}
+
+		chunk := &pb.SshFileChunk{Info: srvutils.ToPBSshFileInfo(f), Offset: offset}
+		if offset < f.Size {
+			reader, err := handler.OpenReaderWithConfig(sshConfig, filePath, offset)
+			if err != nil {
+				return status.Errorf(codes.Internal, err.Error())
+			}
+			for {
+				n, rerr := io.ReadFull(reader, buf)
+				if n > 0 {
+					chunk.Data = buf[:n]
+					err = stream.Send(chunk)
+					if err != nil {
+						system.AbortRemoteStream(reader)
+						return err
+					}
+					offset += int64(n)
+					chunk = &pb.SshFileChunk{Offset: offset}
+				}
+				if rerr == io.EOF || rerr == io.ErrUnexpectedEOF {
+					break
+				}
+				if rerr != nil {
+					system.AbortRemoteStream(reader)
+					return status.Errorf(codes.Internal, rerr.Error())
+				}
+			}
+			err = reader.Close()
+			if err != nil {
+				return status.Errorf(codes.Internal, err.Error())
+			}
+		}
+		chunk.Data = nil
+		chunk.Last = true
+		err = stream.Send(chunk)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Transfer copies files from an host to another, streaming the progress to the client
+func (s *SSHListener) Transfer(in *pb.SshTransferRequest, stream pb.SshService_TransferServer) (err error) {
+	if s == nil {
+		return status.Errorf(codes.FailedPrecondition, scerr.InvalidInstanceError().Error())
+	}
+	if in == nil {
+		return status.Errorf(codes.InvalidArgument, scerr.InvalidParameterError("in", "cannot be nil").Error())
+	}
+	srcHost := srvutils.GetReference(in.GetSourceHost())
+	dstHost := srvutils.GetReference(in.GetDestinationHost())
+	source := in.GetSource()
+	destination := in.GetDestination()
+
+	tracer := concurrency.NewTracer(nil, fmt.Sprintf("('%s:%s', '%s:%s')", srcHost, source, dstHost, destination), true).WithStopwatch().GoingIn()
+	defer tracer.OnExitTrace()()
+	defer scerr.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	log.Infof("Listeners: ssh copy %s:%s %s:%s", srcHost, source, dstHost, destination)
+
+	ctx, cancelFunc := context.WithCancel(stream.Context())
+	defer cancelFunc()
+	if err := srvutils.JobRegister(ctx, cancelFunc, "SSH Copy "+srcHost+":"+source+" to "+dstHost+":"+destination); err == nil {
+		defer srvutils.JobDeregister(ctx)
+	}
+
+	srcTenant := GetReferencedTenant(in.GetSourceHost().GetTenantId())
+	dstTenant := GetReferencedTenant(in.GetDestinationHost().GetTenantId())
+	if srcTenant == nil || dstTenant == nil {
+		return status.Errorf(codes.FailedPrecondition, "cannot copy: no tenant set")
+	}
+
+	// Progress is sent at most every TransferChunkSize bytes, and at the end of each file
+	var (
+		lastSent int64 = -1
+		sendErr  error
+	)
+	notify := func(p system.TransferProgress) {
+		if sendErr != nil {
+			return
+		}
+		if p.Done != p.Size && p.TotalDone-lastSent < system.TransferChunkSize && lastSent >= 0 {
+			return
+		}
+		lastSent = p.TotalDone
+		sendErr = stream.Send(&pb.SshTransferProgress{
+			Path:      p.Path,
+			Done:      p.Done,
+			Size:      p.Size,
+			TotalDone: p.TotalDone,
+			TotalSize: p.TotalSize,
+		})
+		if sendErr != nil {
+			cancelFunc()
+		}
+	}
+
+	handler := SSHHandler(srcTenant.Service)
+	_, _, err = handler.Transfer(ctx, srcHost, source, SSHHandler(dstTenant.Service), dstHost, destination, notify)
+	if sendErr != nil {
+		return sendErr
+	}
+	if err != nil {
+		if _, ok := err.(scerr.ErrNotFound); ok {
+			return status.Errorf(codes.NotFound, err.Error())
+		}
+		return status.Errorf(codes.Internal, err.Error())
+	}
+	return nil
+}
+
+// Exec executes a command (or an interactive shell) on an host, streaming its standard input and outputs
+// with the client
+func (s *SSHListener) Exec(stream pb.SshService_ExecServer) (err error) {
+	if s == nil {
+		return status.Errorf(codes.FailedPrecondition, scerr.InvalidInstanceError().Error())
+	}
+
+	msg, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	start := msg.GetStart()
+	if start == nil {
+		return status.Errorf(codes.InvalidArgument, "cannot execute: first message must be 'start'")
+	}
+	host := srvutils.GetReference(start.GetHost())
+	if host == "" {
+		return status.Errorf(codes.InvalidArgument, "cannot execute: no host reference provided")
+	}
+
+	tracer := concurrency.NewTracer(nil, fmt.Sprintf("('%s', <command>)", host), true).WithStopwatch().GoingIn()
+	tracer.Trace(fmt.Sprintf("<command>=[%s]", start.GetCommand()))
+	defer tracer.OnExitTrace()()
+	defer scerr.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	log.Infof("Listeners: ssh exec on '%s'", host)
+
+	ctx, cancelFunc := context.WithCancel(stream.Context())
+	defer cancelFunc()
+	if err := srvutils.JobRegister(ctx, cancelFunc, "SSH Exec on host "+host); err == nil {
+		defer srvutils.JobDeregister(ctx)
+	}
+
+	tenant := GetReferencedTenant(start.GetHost().GetTenantId())
+	if tenant == nil {
+		return status.Errorf(codes.FailedPrecondition, "cannot execute: no tenant set")
+	}
+
+	var pty *system.PtyRequest
+	if start.GetTty() {
+		pty = &system.PtyRequest{
+			Term:   start.GetTerm(),
+			Width:  int(start.GetWindow().GetWidth()),
+			Height: int(start.GetWindow().GetHeight()),
+		}
+	}
+	handler := SSHHandler(tenant.Service)
+	session, err := handler.OpenSession(ctx, host, start.GetCommand(), start.GetUsername(), start.GetShell(), pty)
+	if err != nil {
+		return status.Errorf(codes.Internal, err.Error())
+	}
+	defer session.Close()
+
+	// grpc doesn't allow concurrent calls to Send
+	var sendLock sync.Mutex
+	send := func(resp *pb.SshExecResponse) error {
+		sendLock.Lock()
+		defer sendLock.Unlock()
+		return stream.Send(resp)
+	}
+
+	// Forwards outputs of the command to the client
+	var pumps sync.WaitGroup
+	pump := func(r io.Reader, wrap func([]byte) *pb.SshExecResponse) {
+		defer pumps.Done()
+		buf := make([]byte, 32*1024)
+		for {
+			n, rerr := r.Read(buf)
+			if n > 0 {
+				data := make([]byte, n)
+				copy(data, buf[:n])
+				if serr := send(wrap(data)); serr != nil {
+					cancelFunc()
+					return
+				}
+			}
+			if rerr != nil {
+				return
+			}
+		}
+	}
+	pumps.Add(2)
+	go pump(session.Stdout, func(data []byte) *pb.SshExecResponse {
+		return &pb.SshExecResponse{Payload: &pb.SshExecResponse_Stdout{Stdout: data}}
+	})
+	go pump(session.Stderr, func(data []byte) *pb.SshExecResponse {
+		return &pb.SshExecResponse{Payload: &pb.SshExecResponse_Stderr{Stderr: data}}
+	})
+
+	// Forwards input, window changes and signals from the client to the command
+	go func() {
+		for {
+			msg, rerr := stream.Recv()
+			if rerr == io.EOF {
+				_ = session.Stdin.Close()
+				return
+			}
+			if rerr != nil {
+				// Client is gone
+				cancelFunc()
+				return
+			}
+			switch payload := msg.GetPayload().(type) {
+			case *pb.SshExecRequest_Stdin:
+				_, werr := session.Stdin.Write(payload.Stdin)
+				if werr != nil {
+					log.Debugf("failed to write to stdin of remote command: %v", werr)
+				}
+			case *pb.SshExecRequest_StdinClose:
+				_ = session.Stdin.Close()
+			case *pb.SshExecRequest_Resize:
+				werr := session.Resize(int(payload.Resize.GetWidth()), int(payload.Resize.GetHeight()))
+				if werr != nil {
+					log.Debugf("failed to resize pseudo-terminal: %v", werr)
+				}
+			case *pb.SshExecRequest_Signal:
+				werr := session.Signal(payload.Signal)
+				if werr != nil {
+					log.Debugf("failed to send signal to remote command: %v", werr)
+				}
+			}
+		}
+	}()
+
+	// Kills the command if the job is cancelled or the client disconnects
+	finished := make(chan struct{})
+	defer close(finished)
+	go func() {
+		select {
+		case <-ctx.Done():
+			session.Close()
+		case <-finished:
+		}
+	}()
+
+	pumps.Wait()
+	retcode, err := session.Wait()
+	if err != nil {
+		if ctx.Err() != nil {
+			return status.Errorf(codes.Canceled, "execution aborted")
+		}
+		return status.Errorf(codes.Internal, err.Error())
+	}
+	return send(&pb.SshExecResponse{Payload: &pb.SshExecResponse_ExitStatus{ExitStatus: int32(retcode)}})
+}
@@ -0,0 +1,96 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package listeners_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/CS-SI/SafeScale/lib"
+	"github.com/CS-SI/SafeScale/lib/server/handlers"
+	"github.com/CS-SI/SafeScale/lib/server/iaas"
+	"github.com/CS-SI/SafeScale/lib/server/listeners"
+)
+
+// tenantService identifies the service of a tenant; its methods are never called
+type tenantService struct {
+	iaas.Service
+	name string
+}
+
+func TestSSHListener_Run_OtherTenant(t *testing.T) {
+	// ARRANGE
+	first := &listeners.Tenant{Service: &tenantService{name: "first"}}
+	second := &listeners.Tenant{Service: &tenantService{name: "second"}}
+
+	oldGetCurrentTenant := listeners.GetCurrentTenant
+	defer func() { listeners.GetCurrentTenant = oldGetCurrentTenant }()
+	listeners.GetCurrentTenant = func() *listeners.Tenant {
+		return first
+	}
+	oldGetReferencedTenant := listeners.GetReferencedTenant
+	defer func() { listeners.GetReferencedTenant = oldGetReferencedTenant }()
+	listeners.GetReferencedTenant = func(name string) *listeners.Tenant {
+		switch name {
+		case "":
+			return first
+		case "second":
+			return second
+		}
+		return nil
+	}
+	var used []iaas.Service
+	oldSSHHandler := listeners.SSHHandler
+	defer func() { listeners.SSHHandler = oldSSHHandler }()
+	listeners.SSHHandler = func(svc iaas.Service) *handlers.SSHHandler {
+		used = append(used, svc)
+		// Without service, the handler fails to find the host: nothing is run
+		return handlers.NewSSHHandler(nil)
+	}
+
+	underTest := &listeners.SSHListener{}
+
+	// ACT
+	_, err := underTest.Run(context.Background(), &pb.SshCommand{
+		Host:    &pb.Reference{Name: "mycluster-node-3", TenantId: "second"},
+		Command: "uname -a",
+	})
+
+	// ASSERT
+	assert.Error(t, err)
+	require.Len(t, used, 1)
+	assert.Equal(t, second.Service, used[0])
+
+	_, err = underTest.Run(context.Background(), &pb.SshCommand{
+		Host:    &pb.Reference{Name: "mycluster-master-1"},
+		Command: "uname -a",
+	})
+	assert.Error(t, err)
+	require.Len(t, used, 2)
+	assert.Equal(t, first.Service, used[1])
+
+	_, err = underTest.Run(context.Background(), &pb.SshCommand{
+		Host:    &pb.Reference{Name: "mycluster-node-3", TenantId: "unknown"},
+		Command: "uname -a",
+	})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	assert.Len(t, used, 2)
+}
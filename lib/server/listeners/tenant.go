@@ -19,6 +19,7 @@ package listeners
 import (
 	"context"
 	"fmt"
+	"sync"
 
 	googleprotobuf "github.com/golang/protobuf/ptypes/empty"
 	log "github.com/sirupsen/logrus"
@@ -40,6 +41,10 @@ type Tenant struct {
 
 var (
 	currentTenant *Tenant
+
+	// otherTenants contains the tenants, other than the current one, referenced by requests
+	otherTenants     = map[string]*Tenant{}
+	otherTenantsLock sync.Mutex
 )
 
 // GetCurrentTenant contains the current tenant
@@ -65,6 +70,33 @@ func getCurrentTenant() *Tenant {
 	return currentTenant
 }
 
+// GetReferencedTenant returns the tenant named 'name' or, if name is empty, the current tenant
+var GetReferencedTenant = getReferencedTenant
+
+// getReferencedTenant returns the tenant named 'name' without changing the current tenant, allowing
+// a request to target a resource of another tenant (used by multi-tenant clusters)
+func getReferencedTenant(name string) *Tenant {
+	current := GetCurrentTenant()
+	if name == "" || (current != nil && current.name == name) {
+		return current
+	}
+
+	otherTenantsLock.Lock()
+	defer otherTenantsLock.Unlock()
+
+	if tenant, ok := otherTenants[name]; ok {
+		return tenant
+	}
+	service, err := iaas.UseService(name)
+	if err != nil {
+		log.Errorf("failed to use tenant '%s': %v", name, err)
+		return nil
+	}
+	tenant := &Tenant{name: name, Service: service}
+	otherTenants[name] = tenant
+	return tenant
+}
+
 // TenantListener server is used to implement SafeScale.safescale.
 type TenantListener struct{}
 
@@ -0,0 +1,212 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package listeners
+
+import (
+	"context"
+	"fmt"
+
+	googleprotobuf "github.com/golang/protobuf/ptypes/empty"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/CS-SI/SafeScale/lib"
+	"github.com/CS-SI/SafeScale/lib/server/handlers"
+	srvutils "github.com/CS-SI/SafeScale/lib/server/utils"
+	"github.com/CS-SI/SafeScale/lib/system"
+	"github.com/CS-SI/SafeScale/lib/utils/concurrency"
+	"github.com/CS-SI/SafeScale/lib/utils/scerr"
+)
+
+// TunnelHandler ...
+var TunnelHandler = handlers.NewTunnelHandler
+
+// safescale ssh tunnel host1 --local 8080 --remote 80
+// safescale ssh tunnel host1 --socks 1080
+// safescale ssh tunnels
+// safescale ssh close tunnel1
+
+// TunnelListener tunnel service server grpc
+type TunnelListener struct{}
+
+// toPBTunnel converts a tunnel to its protobuf message
+func toPBTunnel(in *handlers.Tunnel) *pb.Tunnel {
+	state, reconnects, lastErr := in.Forward.Status()
+	out := &pb.Tunnel{
+		Id:         in.ID,
+		Name:       in.Name,
+		Host:       &pb.Reference{Id: in.HostID, Name: in.HostName},
+		Mode:       string(in.Forward.Mode()),
+		Bind:       in.Forward.Bind(),
+		Target:     in.Forward.Target(),
+		State:      string(state),
+		Reconnects: int32(reconnects),
+		Created:    in.Created.Unix(),
+	}
+	if lastErr != nil {
+		out.LastError = lastErr.Error()
+	}
+	return out
+}
+
+// tunnelErrorCode returns the grpc code corresponding to the error returned by the tunnel handler
+func tunnelErrorCode(err error) codes.Code {
+	switch err.(type) {
+	case scerr.ErrNotFound:
+		return codes.NotFound
+	case scerr.ErrDuplicate:
+		return codes.AlreadyExists
+	case scerr.ErrInvalidParameter:
+		return codes.InvalidArgument
+	default:
+		return codes.Internal
+	}
+}
+
+// Create opens a tunnel through an host
+func (s *TunnelListener) Create(ctx context.Context, in *pb.TunnelDefinition) (t *pb.Tunnel, err error) {
+	if s == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, scerr.InvalidInstanceError().Error())
+	}
+	if in == nil {
+		return nil, status.Errorf(codes.InvalidArgument, scerr.InvalidParameterError("in", "cannot be nil").Error())
+	}
+	hostRef := srvutils.GetReference(in.GetHost())
+	if hostRef == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "cannot create tunnel: neither name nor id of host has been provided")
+	}
+
+	tracer := concurrency.NewTracer(nil, fmt.Sprintf("('%s', '%s', '%s')", in.GetName(), hostRef, in.GetMode()), true).WithStopwatch().GoingIn()
+	defer tracer.OnExitTrace()()
+	defer scerr.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	ctx, cancelFunc := context.WithCancel(ctx)
+	if err := srvutils.JobRegister(ctx, cancelFunc, "Create tunnel through host "+hostRef); err == nil {
+		defer srvutils.JobDeregister(ctx)
+	}
+
+	tenant := GetCurrentTenant()
+	if tenant == nil {
+		log.Info("Can't create tunnel: no tenant set")
+		return nil, status.Errorf(codes.FailedPrecondition, "cannot create tunnel: no tenant set")
+	}
+
+	handler := TunnelHandler(tenant.name, tenant.Service)
+	tunnel, err := handler.Create(ctx, in.GetName(), hostRef, system.ForwardMode(in.GetMode()), in.GetBind(), in.GetTarget())
+	if err != nil {
+		return nil, status.Errorf(tunnelErrorCode(err), scerr.Wrap(err, "cannot create tunnel").Error())
+	}
+	return toPBTunnel(tunnel), nil
+}
+
+// List returns the tunnels opened by safescaled in the current tenant
+func (s *TunnelListener) List(ctx context.Context, in *googleprotobuf.Empty) (tl *pb.TunnelList, err error) {
+	if s == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, scerr.InvalidInstanceError().Error())
+	}
+
+	tracer := concurrency.NewTracer(nil, "", true).WithStopwatch().GoingIn()
+	defer tracer.OnExitTrace()()
+	defer scerr.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	tenant := GetCurrentTenant()
+	if tenant == nil {
+		log.Info("Can't list tunnels: no tenant set")
+		return nil, status.Errorf(codes.FailedPrecondition, "cannot list tunnels: no tenant set")
+	}
+
+	handler := TunnelHandler(tenant.name, tenant.Service)
+	list, err := handler.List(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, scerr.Wrap(err, "cannot list tunnels").Error())
+	}
+
+	var pbTunnels []*pb.Tunnel
+	for _, t := range list {
+		pbTunnels = append(pbTunnels, toPBTunnel(t))
+	}
+	return &pb.TunnelList{Tunnels: pbTunnels}, nil
+}
+
+// Inspect returns the tunnel whose name or id is given
+func (s *TunnelListener) Inspect(ctx context.Context, in *pb.Reference) (t *pb.Tunnel, err error) {
+	if s == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, scerr.InvalidInstanceError().Error())
+	}
+	if in == nil {
+		return nil, status.Errorf(codes.InvalidArgument, scerr.InvalidParameterError("in", "cannot be nil").Error())
+	}
+	ref := srvutils.GetReference(in)
+	if ref == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "cannot inspect tunnel: neither name nor id given as reference")
+	}
+
+	tracer := concurrency.NewTracer(nil, fmt.Sprintf("('%s')", ref), true).WithStopwatch().GoingIn()
+	defer tracer.OnExitTrace()()
+	defer scerr.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	tenant := GetCurrentTenant()
+	if tenant == nil {
+		log.Info("Can't inspect tunnel: no tenant set")
+		return nil, status.Errorf(codes.FailedPrecondition, "cannot inspect tunnel: no tenant set")
+	}
+
+	handler := TunnelHandler(tenant.name, tenant.Service)
+	tunnel, err := handler.Inspect(ctx, ref)
+	if err != nil {
+		return nil, status.Errorf(tunnelErrorCode(err), scerr.Wrap(err, fmt.Sprintf("cannot inspect tunnel '%s'", ref)).Error())
+	}
+	return toPBTunnel(tunnel), nil
+}
+
+// Close closes the tunnel whose name or id is given
+func (s *TunnelListener) Close(ctx context.Context, in *pb.Reference) (empty *googleprotobuf.Empty, err error) {
+	empty = &googleprotobuf.Empty{}
+	if s == nil {
+		return empty, status.Errorf(codes.FailedPrecondition, scerr.InvalidInstanceError().Error())
+	}
+	if in == nil {
+		return empty, status.Errorf(codes.InvalidArgument, scerr.InvalidParameterError("in", "cannot be nil").Error())
+	}
+	ref := srvutils.GetReference(in)
+	if ref == "" {
+		return empty, status.Errorf(codes.InvalidArgument, "cannot close tunnel: neither name nor id given as reference")
+	}
+
+	tracer := concurrency.NewTracer(nil, fmt.Sprintf("('%s')", ref), true).WithStopwatch().GoingIn()
+	defer tracer.OnExitTrace()()
+	defer scerr.OnExitLogError(tracer.TraceMessage(""), &err)()
+
+	ctx, cancelFunc := context.WithCancel(ctx)
+	if err := srvutils.JobRegister(ctx, cancelFunc, "Close tunnel "+ref); err == nil {
+		defer srvutils.JobDeregister(ctx)
+	}
+
+	tenant := GetCurrentTenant()
+	if tenant == nil {
+		log.Info("Can't close tunnel: no tenant set")
+		return empty, status.Errorf(codes.FailedPrecondition, "cannot close tunnel: no tenant set")
+	}
+
+	handler := TunnelHandler(tenant.name, tenant.Service)
+	err = handler.Close(ctx, ref)
+	if err != nil {
+		return empty, status.Errorf(tunnelErrorCode(err), scerr.Wrap(err, fmt.Sprintf("cannot close tunnel '%s'", ref)).Error())
+	}
+	return empty, nil
+}
@@ -18,6 +18,7 @@ package utils
 
 import (
 	"math"
+	"os"
 
 	pb "github.com/CS-SI/SafeScale/lib"
 	"github.com/CS-SI/SafeScale/lib/server/iaas/resources"
@@ -39,6 +40,7 @@ func ToPBSshConfig(from *system.SSHConfig) *pb.SshConfig {
 		Port:       int32(from.Port),
 		PrivateKey: from.PrivateKey,
 		User:       from.User,
+		HostKey:    from.HostKey,
 	}
 }
 
@@ -53,10 +55,31 @@ func ToSystemSSHConfig(from *pb.SshConfig) *system.SSHConfig {
 		Host:          from.Host,
 		PrivateKey:    from.PrivateKey,
 		Port:          int(from.Port),
+		HostKey:       from.HostKey,
 		GatewayConfig: gw,
 	}
 }
 
+// ToPBSshFileInfo converts a system.FileInfo into a SshFileInfo
+func ToPBSshFileInfo(from system.FileInfo) *pb.SshFileInfo {
+	return &pb.SshFileInfo{
+		Path:     from.Path,
+		Size:     from.Size,
+		Mode:     uint32(from.Mode),
+		Checksum: from.Checksum,
+	}
+}
+
+// ToSystemFileInfo converts a pb.SshFileInfo into a system.FileInfo
+func ToSystemFileInfo(from *pb.SshFileInfo) system.FileInfo {
+	return system.FileInfo{
+		Path:     from.GetPath(),
+		Size:     from.GetSize(),
+		Mode:     os.FileMode(from.GetMode()),
+		Checksum: from.GetChecksum(),
+	}
+}
+
 // ToPBVolume converts an api.Volume to a *Volume
 func ToPBVolume(in *resources.Volume) *pb.Volume {
 	return &pb.Volume{
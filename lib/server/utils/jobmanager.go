@@ -37,8 +37,12 @@ func (ji *jobInfo) toString() string {
 	return fmt.Sprintf("Task : %s\nCreation time : %s", ji.commandName, ji.launchTime.String())
 }
 
+// maxJobEvents is the number of events kept per job
+const maxJobEvents = 100
+
 var (
 	jobMap          = map[string]jobInfo{}
+	jobEvents       = map[string][]string{}
 	mutexJobManager sync.Mutex
 )
 
@@ -61,6 +65,46 @@ func JobRegister(ctx context.Context, cancelFunc func(), command string) error {
 	return nil
 }
 
+// JobRegisterBackground registers a job not bound to a grpc request (like a loop running in safescaled),
+// identified by uuid
+func JobRegisterBackground(ctx context.Context, cancelFunc func(), uuid, command string) {
+	mutexJobManager.Lock()
+	defer mutexJobManager.Unlock()
+
+	jobMap[uuid] = jobInfo{
+		commandName: command,
+		launchTime:  time.Now(),
+		context:     ctx,
+		cancelFunc:  cancelFunc,
+	}
+}
+
+// JobEvent records a timestamped event in the history of the job identified by uuid;
+// only the last events are kept
+func JobEvent(uuid string, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	logrus.Infof("[job %s] %s", uuid, msg)
+
+	mutexJobManager.Lock()
+	defer mutexJobManager.Unlock()
+
+	events := append(jobEvents[uuid], time.Now().Format(time.RFC3339)+" "+msg)
+	if len(events) > maxJobEvents {
+		events = events[len(events)-maxJobEvents:]
+	}
+	jobEvents[uuid] = events
+}
+
+// JobEvents returns the events recorded for the job identified by uuid, oldest first
+func JobEvents(uuid string) []string {
+	mutexJobManager.Lock()
+	defer mutexJobManager.Unlock()
+
+	events := make([]string, len(jobEvents[uuid]))
+	copy(events, jobEvents[uuid])
+	return events
+}
+
 // JobCancelUUID ...
 func JobCancelUUID(uuid string) {
 	mutexJobManager.Lock()
@@ -76,6 +120,7 @@ func JobDeregisterUUID(uuid string) {
 	defer mutexJobManager.Unlock()
 
 	delete(jobMap, uuid)
+	delete(jobEvents, uuid)
 }
 
 // JobDeregister ...
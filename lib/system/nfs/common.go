@@ -20,9 +20,7 @@ import (
 	"bytes"
 	"fmt"
 	"os"
-	"os/exec"
 	"strings"
-	"syscall"
 	"text/template"
 
 	rice "github.com/GeertJohan/go.rice"
@@ -173,11 +171,9 @@ func executeScript(sshconfig system.SSHConfig, name string, data map[string]inte
 			stderr = ""
 			retcode = 0
 			if err != nil {
-				if ee, ok := err.(*exec.ExitError); ok {
-					if status, ok := ee.Sys().(syscall.WaitStatus); ok {
-						retcode = status.ExitStatus()
-					}
-					stderr = string(ee.Stderr)
+				if msg, rc, erro := system.ExtractRetCode(err); erro == nil {
+					retcode = rc
+					stderr = msg
 				}
 			}
 			return err
@@ -24,16 +24,18 @@ import (
 	"io/ioutil"
 	"net"
 	"os"
-	"os/exec"
-	"reflect"
+	"os/signal"
+	"path"
+	"path/filepath"
 	"runtime"
-	"strconv"
 	"strings"
+	"sync"
 	"syscall"
-	"text/template"
 	"time"
 
 	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/terminal"
 
 	"github.com/CS-SI/SafeScale/lib/utils"
 	"github.com/CS-SI/SafeScale/lib/utils/cli"
@@ -45,12 +47,6 @@ import (
 	"github.com/CS-SI/SafeScale/lib/utils/temporal"
 )
 
-// VPL: SSH ControlMaster options: -oControlMaster=auto -oControlPath=/tmp/safescale-%C -oControlPersist=5m
-//      To make profit of this multiplexing functionality, we have to change the way we manage ports for tunnels: we have to always
-//      use the same port for all access to a same host (not the case currently)
-//      May not be used for interactive ssh connection...
-const sshOptions = "-q -oIdentitiesOnly=yes -oStrictHostKeyChecking=no -oUserKnownHostsFile=/dev/null -oPubkeyAuthentication=yes -oPasswordAuthentication=no"
-
 var (
 	sshErrorMap = map[int]string{
 		1:  "Malformed configuration or invalid cli options",
@@ -122,16 +118,16 @@ type SSHConfig struct {
 	PrivateKey    string
 	Port          int
 	LocalPort     int
+	HostKey       string // public key of the host in authorized_keys format; if empty, captured on first connection
 	GatewayConfig *SSHConfig
-	cmdTpl        string
 }
 
 // SSHTunnel a SSH tunnel
 type SSHTunnel struct {
-	port      int
-	cmd       *exec.Cmd
-	cmdString string
-	keyFile   *os.File
+	port     int
+	remote   string
+	listener net.Listener
+	client   *sshClient
 }
 
 // SSHErrorString returns if possible the string corresponding to SSH execution
@@ -150,56 +146,57 @@ func SCPErrorString(retcode int) string {
 	return "Unqualified error"
 }
 
+// Port returns the local port of the tunnel
+func (tunnel *SSHTunnel) Port() int {
+	return tunnel.port
+}
+
 // Close closes ssh tunnel
 func (tunnel *SSHTunnel) Close() error {
-	defer func() {
-		lazyErr := utils.LazyRemove(tunnel.keyFile.Name())
-		if lazyErr != nil {
-			logrus.Error(lazyErr)
-		}
-	}()
+	defer sshPool.release(tunnel.client)
 
-	// Kills the process of the tunnel
-	err := tunnel.cmd.Process.Kill()
+	err := tunnel.listener.Close()
 	if err != nil {
-		logrus.Errorf("tunnel.cmd.Process.Kill() failed: %s\n", reflect.TypeOf(err).String())
 		return fmt.Errorf("unable to close tunnel :%s", err.Error())
 	}
-	// Kills remaining processes if there are some
-	bytesCmd, err := exec.Command("pgrep", "-f", tunnel.cmdString).Output()
-	if err == nil {
-		portStr := strings.Trim(string(bytesCmd), "\n")
-		_, err = strconv.Atoi(portStr)
-		if err == nil {
-			err = exec.Command("kill", "-9", portStr).Run()
-			if err != nil {
-				logrus.Errorf("kill -9 failed: %s\n", reflect.TypeOf(err).String())
-				return fmt.Errorf("unable to close tunnel :%s", err.Error())
-			}
-		}
-	}
 	return nil
 }
 
-// GetFreePort get a free port
-func getFreePort() (int, error) {
-	listener, err := net.Listen("tcp", ":0")
-	defer func() {
-		clErr := listener.Close()
-		if clErr != nil {
-			logrus.Error(clErr)
+// serve accepts local connections and forwards them to the remote end of the tunnel
+func (tunnel *SSHTunnel) serve() {
+	for {
+		local, err := tunnel.listener.Accept()
+		if err != nil {
+			// listener closed
+			return
 		}
-	}()
-	if err != nil {
-		return 0, err
+		go tunnel.forward(local)
 	}
-	tcp, ok := listener.Addr().(*net.TCPAddr)
-	if !ok {
-		return 0, fmt.Errorf("invalid listener.Addr()")
+}
+
+// forward bridges a local connection with a new channel to the remote end of the tunnel
+func (tunnel *SSHTunnel) forward(local net.Conn) {
+	remote, err := tunnel.client.client.Dial("tcp", tunnel.remote)
+	if err != nil {
+		logrus.Warnf("tunnel to '%s' failed to forward connection: %v", tunnel.remote, err)
+		_ = local.Close()
+		return
 	}
+	bridgeConnections(local, remote)
+}
 
-	port := tcp.Port
-	return port, nil
+// bridgeConnections copies data between 2 connections until one of them is closed
+func bridgeConnections(a, b net.Conn) {
+	done := make(chan struct{}, 2)
+	copier := func(dst, src net.Conn) {
+		_, _ = io.Copy(dst, src)
+		done <- struct{}{}
+	}
+	go copier(a, b)
+	go copier(b, a)
+	<-done
+	_ = a.Close()
+	_ = b.Close()
 }
 
 // CreateTempFileFromString creates a temporary file containing 'content'
@@ -231,108 +228,81 @@ func CreateTempFileFromString(content string, filemode os.FileMode) (*os.File, e
 	return f, nil
 }
 
-func isTunnelReady(port int) bool {
-	// Try to create a server with the port
-	server, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
-	if err != nil {
-		return true
-	}
-	err = server.Close()
-	if err != nil {
-		logrus.Warnf("Error closing server: %v", err)
-	}
-	return false
-
-}
-
-// buildTunnel create SSH from local host to remote host through gateway
-// if localPort is set to 0 then it's  automatically choosed
+// buildTunnel creates a tunnel from local host to remote host through gateway
+// if cfg.LocalPort is set to 0 then it's automatically chosen
 func buildTunnel(cfg *SSHConfig) (*SSHTunnel, error) {
-	f, err := CreateTempFileFromString(cfg.GatewayConfig.PrivateKey, 0400)
+	client, err := sshPool.acquire(cfg.GatewayConfig)
 	if err != nil {
 		return nil, err
 	}
-	localPort := cfg.LocalPort
-	if localPort == 0 {
-		localPort, err = getFreePort()
-		if err != nil {
-			return nil, err
-		}
-	}
-
-	options := sshOptions + " -oServerAliveInterval=60"
-	cmdString := fmt.Sprintf("ssh -i %s -NL 127.0.0.1:%d:%s:%d %s@%s %s -p %d",
-		f.Name(),
-		localPort,
-		cfg.Host,
-		cfg.Port,
-		cfg.GatewayConfig.User,
-		cfg.GatewayConfig.Host,
-		options,
-		cfg.GatewayConfig.Port,
-	)
-	cmd := exec.Command("sh", "-c", cmdString)
-	err = cmd.Start()
-	//	err = cmd.Wait()
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", cfg.LocalPort))
 	if err != nil {
+		sshPool.release(client)
 		return nil, err
 	}
+	tcpAddr, ok := listener.Addr().(*net.TCPAddr)
+	if !ok {
+		_ = listener.Close()
+		sshPool.release(client)
+		return nil, fmt.Errorf("invalid listener.Addr()")
+	}
 
-	/*
-		if forensics := os.Getenv("SAFESCALE_FORENSICS"); forensics != "" {
-			if cmdString != "" {
-				logrus.Debugf("[TRACE] %s", cmdString)
-			}
-			_ = os.MkdirAll(utils.AbsPathify(fmt.Sprintf("$HOME/.safescale/forensics/%s", cfg.Host)), 0777)
-			partials := strings.Split(f.Name(), "/")
-			dumpName := utils.AbsPathify(fmt.Sprintf("$HOME/.safescale/forensics/%s/%s.sshkey", cfg.Host, partials[len(partials)-1]))
-			err = ioutil.WriteFile(dumpName, []byte(cfg.GatewayConfig.PrivateKey), 0644)
-			if err != nil {
-				logrus.Warnf("[TRACE] Failure storing key in %s", dumpName)
-			}
-		}
-	*/
-
-	for nbiter := 0; !isTunnelReady(localPort) && nbiter < 100; nbiter++ {
-		time.Sleep(10 * time.Millisecond)
+	tunnel := &SSHTunnel{
+		port:     tcpAddr.Port,
+		remote:   cfg.address(),
+		listener: listener,
+		client:   client,
 	}
-	return &SSHTunnel{
-		port:      localPort,
-		cmd:       cmd,
-		cmdString: cmdString,
-		keyFile:   f,
-	}, nil
+	go tunnel.serve()
+	return tunnel, nil
 }
 
 // SSHCommand defines a SSH command
 type SSHCommand struct {
-	cmd     *exec.Cmd
-	tunnels []*SSHTunnel
-	keyFile *os.File
+	cfg     *SSHConfig
+	client  *sshClient
+	session *ssh.Session
+	command string // command started on remote host; if empty, the login shell of the user is started
+	script  string // script fed to the remote command through its standard input
+
+	lock     sync.Mutex
+	released bool
+	done     chan struct{}
 }
 
-func (sc *SSHCommand) closeTunneling() error {
-	var err error
-	for _, t := range sc.tunnels {
-		err = t.Close()
-	}
-	sc.tunnels = []*SSHTunnel{}
+// open opens the session on the host, if not already done
+func (sc *SSHCommand) open() error {
+	sc.lock.Lock()
+	defer sc.lock.Unlock()
 
-	// Tunnels are imbricated only last error is significant
+	if sc.session != nil {
+		return nil
+	}
+	if sc.released {
+		return fmt.Errorf("command already terminated")
+	}
+	client, session, err := sc.cfg.newSession()
 	if err != nil {
-		logrus.Errorf("closeTunneling: %s\n", reflect.TypeOf(err).String())
+		return err
+	}
+	if sc.script != "" {
+		session.Stdin = strings.NewReader(sc.script + "\n")
 	}
-	return err
+	sc.client = client
+	sc.session = session
+	return nil
 }
 
 // Wait waits for the command to exit and waits for any copying to stdin or copying from stdout or stderr to complete.
 // The command must have been started by Start.
 // The returned error is nil if the command runs, has no problems copying stdin, stdout, and stderr, and exits with a zero exit status.
-// If the command fails to run or doesn't complete successfully, the error is of type *ExitError. Other error types may be returned for I/O problems.
-// Wait also waits for the I/O loop copying from c.Stdin into the process's standard input to complete.
-// Wait releases any resources associated with the cmd.
+// If the remote command fails or is killed by a signal, the error is of type *ssh.ExitError. Other error types may be returned for I/O problems.
+// Wait releases any resources associated with the command.
 func (sc *SSHCommand) Wait() error {
-	err := sc.cmd.Wait()
+	if sc.session == nil {
+		return fmt.Errorf("command not started")
+	}
+	err := sc.session.Wait()
 	nerr := sc.cleanup()
 	if err != nil {
 		return err
@@ -345,35 +315,66 @@ func (sc *SSHCommand) Wait() error {
 
 // Kill kills SSHCommand process and releases any resources associated with the SSHCommand.
 func (sc *SSHCommand) Kill() error {
-	return sc.cmd.Process.Kill()
+	if sc.session != nil {
+		err := sc.session.Signal(ssh.SIGKILL)
+		if err != nil {
+			logrus.Debugf("failed to send KILL signal to remote command: %v", err)
+		}
+	}
+	return sc.cleanup()
 }
 
 // StdoutPipe returns a pipe that will be connected to the command's standard output when the command starts.
 // Wait will close the pipe after seeing the command exit, so most callers need not close the pipe themselves; however, an implication is that it is incorrect to call Wait before all reads from the pipe have completed.
 // For the same reason, it is incorrect to call Run when using StdoutPipe.
 func (sc *SSHCommand) StdoutPipe() (io.ReadCloser, error) {
-	return sc.cmd.StdoutPipe()
+	if err := sc.open(); err != nil {
+		return nil, err
+	}
+	pipe, err := sc.session.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(pipe), nil
 }
 
 // StderrPipe returns a pipe that will be connected to the command's standard error when the command starts.
 // Wait will close the pipe after seeing the command exit, so most callers need not close the pipe themselves; however, an implication is that it is incorrect to call Wait before all reads from the pipe have completed. For the same reason, it is incorrect to use Run when using StderrPipe.
 func (sc *SSHCommand) StderrPipe() (io.ReadCloser, error) {
-	return sc.cmd.StderrPipe()
+	if err := sc.open(); err != nil {
+		return nil, err
+	}
+	pipe, err := sc.session.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(pipe), nil
 }
 
 // StdinPipe returns a pipe that will be connected to the command's standard input when the command starts.
 // The pipe will be closed automatically after Wait sees the command exit.
 // A caller need only call Close to force the pipe to close sooner.
 // For example, if the command being run will not exit until standard input is closed, the caller must close the pipe.
+// Fails if the command has been created with a script, which is already fed through standard input.
 func (sc *SSHCommand) StdinPipe() (io.WriteCloser, error) {
-	return sc.cmd.StdinPipe()
+	if err := sc.open(); err != nil {
+		return nil, err
+	}
+	return sc.session.StdinPipe()
 }
 
 // Output runs the command and returns its standard output.
-// Any returned error will usually be of type *ExitError.
-// If c.Stderr was nil, Output populates ExitError.Stderr.
+// Any returned error will usually be of type *ssh.ExitError.
 func (sc *SSHCommand) Output() ([]byte, error) {
-	content, err := sc.cmd.Output()
+	if err := sc.open(); err != nil {
+		return nil, err
+	}
+	var stdout bytes.Buffer
+	sc.session.Stdout = &stdout
+	err := sc.Start()
+	if err == nil {
+		err = sc.session.Wait()
+	}
 	nerr := sc.cleanup()
 	if err != nil {
 		return nil, err
@@ -381,13 +382,22 @@ func (sc *SSHCommand) Output() ([]byte, error) {
 	if nerr != nil {
 		logrus.Warnf("Error waiting for command cleanup: %v", nerr)
 	}
-	return content, err
+	return stdout.Bytes(), err
 }
 
 // CombinedOutput runs the command and returns its combined standard
 // output and standard error.
 func (sc *SSHCommand) CombinedOutput() ([]byte, error) {
-	content, err := sc.cmd.CombinedOutput()
+	if err := sc.open(); err != nil {
+		return nil, err
+	}
+	var output bytes.Buffer
+	sc.session.Stdout = &output
+	sc.session.Stderr = &output
+	err := sc.Start()
+	if err == nil {
+		err = sc.session.Wait()
+	}
 	nerr := sc.cleanup()
 	if err != nil {
 		return nil, err
@@ -395,7 +405,7 @@ func (sc *SSHCommand) CombinedOutput() ([]byte, error) {
 	if nerr != nil {
 		logrus.Warnf("Error waiting for command cleanup: %v", nerr)
 	}
-	return content, err
+	return output.Bytes(), err
 }
 
 // Start starts the specified command but does not wait for it to complete.
@@ -403,12 +413,22 @@ func (sc *SSHCommand) CombinedOutput() ([]byte, error) {
 // The Wait method will return the exit code and release associated resources
 // once the command exits.
 func (sc *SSHCommand) Start() error {
-	return sc.cmd.Start()
+	if err := sc.open(); err != nil {
+		return err
+	}
+	if sc.command == "" {
+		return sc.session.Shell()
+	}
+	return sc.session.Start(sc.command)
 }
 
 // Display ...
 func (sc *SSHCommand) Display() string {
-	return strings.Join(sc.cmd.Args, " ")
+	target := fmt.Sprintf("%s@%s", sc.cfg.User, sc.cfg.address())
+	for gw := sc.cfg.GatewayConfig; gw != nil; gw = gw.GatewayConfig {
+		target += fmt.Sprintf(" via %s@%s", gw.User, gw.address())
+	}
+	return fmt.Sprintf("ssh %s %s <<'ENDSSH'\n%s\nENDSSH", target, sc.command, sc.script)
 }
 
 // Run starts the specified command and waits for it to complete.
@@ -434,17 +454,13 @@ func (sc *SSHCommand) RunWithTimeout(task concurrency.Task, outs outputs.Enum, t
 	tracer.Trace("command=\n%s\n", sc.Display())
 	defer tracer.OnExitTrace()()
 
-	// if strings.Contains(sc.Display(), "ENDSSH") {
-	// 	defer utils.NewStopwatch().OnExitLogWithLevel(
-	// 		fmt.Sprintf("Running command with timeout of %s:\n%s", timeout, sc.Display()),
-	// 		fmt.Sprintf("Command run: [%s]", sc.Display()),
-	// 		logrus.DebugLevel,
-	// 	)()
-	// }
-
 	// Set up the outputs (std and err)
 	stdoutPipe, err := sc.StdoutPipe()
 	if err != nil {
+		if _, ok := err.(*ErrSSHConnection); ok {
+			// Behaves like ssh binary when the connection fails
+			return sshConnectionFailedRetCode, "", err.Error(), nil
+		}
 		return 0, "", "", err
 	}
 	stderrPipe, err := sc.StderrPipe()
@@ -452,61 +468,6 @@ func (sc *SSHCommand) RunWithTimeout(task concurrency.Task, outs outputs.Enum, t
 		return 0, "", "", err
 	}
 
-	// // Launch the command and wait for its execution
-	// if err = sc.Start(); err != nil {
-	// 	return 0, "", "", err
-	// }
-
-	// doneCh := make(chan bool)
-
-	// var msgOut []byte
-	// var msgErr []byte
-
-	// go func() {
-	// 	defer close(doneCh)
-
-	// 	clean := true
-	// 	var closeErr error
-
-	// 	msgOut, closeErr = ioutil.ReadAll(stdOut)
-	// 	if closeErr != nil {
-	// 		logrus.Debugf("error recovering standard output of command [%s]: %v", sc.Display(), closeErr)
-	// 		clean = false
-	// 	}
-
-	// 	msgErr, closeErr = ioutil.ReadAll(stderr)
-	// 	if closeErr != nil {
-	// 		logrus.Debugf("error recovering standard error of command [%s]: %v", sc.Display(), closeErr)
-	// 		clean = false
-	// 	}
-
-	// 	err = sc.Wait()
-	// 	if err != nil {
-	// 		logrus.Debugf("error waiting for command [%s]: %v", sc.Display(), err)
-	// 		clean = false
-	// 	}
-
-	// 	doneCh <- clean
-	// }()
-
-	// select {
-	// case issues := <-doneCh:
-	// 	if err != nil {
-	// 		msgError, retCode, erro := ExtractRetCode(err)
-	// 		if erro != nil {
-	// 			return 0, "", "", err
-	// 		}
-	// 		return retCode, string(msgOut[:]), fmt.Sprint(string(msgErr[:]), msgError), nil
-	// 	}
-	// 	if !issues {
-	// 		logrus.Warnf("there have been issues running this command [%s], please check daemon logs", sc.Display())
-	// 	}
-	// case <-time.After(timeout):
-	// 	errMsg := fmt.Sprintf("timeout of (%s) waiting for the command [%s] to end", timeout, sc.Display())
-	// 	logrus.Warnf(errMsg)
-	// 	return 0, "", "", fmt.Errorf(errMsg)
-	// }
-
 	subtask, err := concurrency.NewTask(task)
 	if err != nil {
 		return -1, "", "", err
@@ -522,6 +483,11 @@ func (sc *SSHCommand) RunWithTimeout(task concurrency.Task, outs outputs.Enum, t
 
 	r, err := subtask.Wait()
 	if err != nil {
+		// Timeout or abort: the remote command may still be running
+		kerr := sc.Kill()
+		if kerr != nil {
+			logrus.Warnf("failed to kill remote command: %v", kerr)
+		}
 		return -1, "", "", err
 	}
 	if result, ok := r.(data.Map); ok {
@@ -607,14 +573,23 @@ func (sc *SSHCommand) taskExecute(task concurrency.Task, p concurrency.TaskParam
 	}
 
 	if collectOutputs {
+		// Both outputs share the flow control of the SSH channel, so they have to be read concurrently
+		var (
+			wg     sync.WaitGroup
+			errErr error
+		)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			msgErr, errErr = ioutil.ReadAll(stderrPipe)
+		}()
 		msgOut, err = ioutil.ReadAll(stdoutPipe)
+		wg.Wait()
 		if err != nil {
 			return result, err
 		}
-
-		msgErr, err = ioutil.ReadAll(stderrPipe)
-		if err != nil {
-			return result, err
+		if errErr != nil {
+			return result, errErr
 		}
 	}
 
@@ -634,8 +609,10 @@ func (sc *SSHCommand) taskExecute(task concurrency.Task, p concurrency.TaskParam
 			}
 		}
 	} else {
-		// If error doesn't contain ouputs and return code of the process, stop the pipe bridges and return error
-		if _, ok := err.(*exec.ExitError); !ok {
+		// Extract execution information
+		msgError, retCode, erro := ExtractRetCode(err)
+		if erro != nil {
+			// If error doesn't contain the return code of the remote process, stop the pipe bridges and return error
 			if !collectOutputs {
 				derr := pipeBridgeCtrl.Stop()
 				if derr != nil {
@@ -653,11 +630,6 @@ func (sc *SSHCommand) taskExecute(task concurrency.Task, p concurrency.TaskParam
 			}
 		}
 
-		// Extract execution information
-		msgError, retCode, erro := ExtractRetCode(err)
-		if erro != nil {
-			return result, err
-		}
 		result["retcode"] = retCode
 		if collectOutputs {
 			result["stdout"] = string(msgOut)
@@ -670,156 +642,96 @@ func (sc *SSHCommand) taskExecute(task concurrency.Task, p concurrency.TaskParam
 	return result, nil
 }
 
+// cleanup closes the session and gives back the connection to the pool; may be called several times
 func (sc *SSHCommand) cleanup() error {
-	err1 := sc.closeTunneling()
-	err2 := utils.LazyRemove(sc.keyFile.Name())
-	if err1 != nil {
-		logrus.Errorf("closeTunneling() failed: %s\n", reflect.TypeOf(err1).String())
-		return fmt.Errorf("unable to close SSH tunnels: %s", err1.Error())
+	sc.lock.Lock()
+	defer sc.lock.Unlock()
+
+	if sc.released {
+		return nil
+	}
+	sc.released = true
+	close(sc.done)
+
+	if sc.session == nil {
+		return nil
 	}
-	if err2 != nil {
-		return fmt.Errorf("unable to close SSH tunnels: %s", err2.Error())
+	defer sshPool.release(sc.client)
+	err := sc.session.Close()
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("unable to close SSH session: %s", err.Error())
 	}
 	return nil
 }
 
-func recCreateTunnels(ssh *SSHConfig, tunnels *[]*SSHTunnel) (*SSHTunnel, error) {
-	if ssh != nil {
-		tunnel, err := recCreateTunnels(ssh.GatewayConfig, tunnels)
-		if err != nil {
-			return nil, err
-		}
-		cfg := ssh
-		if tunnel != nil {
-			gateway := *ssh.GatewayConfig
-			gateway.Port = tunnel.port
-			gateway.Host = "127.0.0.1"
-			cfg.GatewayConfig = &gateway
-		}
-		if cfg.GatewayConfig != nil {
-			tunnel, err = buildTunnel(cfg)
-			if err != nil {
-				return nil, err
-			}
-			*tunnels = append(*tunnels, tunnel)
-			return tunnel, err
-		}
+// CreateTunneling creates, if the host is behind a gateway, a tunnel from a local port to the host through the
+// chain of gateways, and returns a SSHConfig to reach the host using this local port
+func (sconf *SSHConfig) CreateTunneling() ([]*SSHTunnel, *SSHConfig, error) {
+	sshConfig := *sconf
+	if sconf.GatewayConfig == nil {
+		return nil, &sshConfig, nil
 	}
-	return nil, nil
-}
 
-// CreateTunneling ...
-func (ssh *SSHConfig) CreateTunneling() ([]*SSHTunnel, *SSHConfig, error) {
-	var tunnels []*SSHTunnel
-	tunnel, err := recCreateTunnels(ssh, &tunnels)
+	tunnel, err := buildTunnel(sconf)
 	if err != nil {
 		return nil, nil, fmt.Errorf("unable to create SSH Tunnels : %s", err.Error())
 	}
-	sshConfig := *ssh
-	if tunnel == nil {
-		return nil, &sshConfig, nil
-	}
-
-	if ssh.GatewayConfig != nil {
-		sshConfig.Port = tunnel.port
-		sshConfig.Host = "127.0.0.1"
-	}
-	return tunnels, &sshConfig, nil
+	sshConfig.Port = tunnel.port
+	sshConfig.Host = "127.0.0.1"
+	return []*SSHTunnel{tunnel}, &sshConfig, nil
 }
 
-func createSSHCmd(sshConfig *SSHConfig, cmdString, username, shell string, withTty, withSudo bool) (string, *os.File, error) {
-	f, err := CreateTempFileFromString(sshConfig.PrivateKey, 0400)
-	if err != nil {
-		return "", nil, fmt.Errorf("unable to create temporary key file: %s", err.Error())
-	}
-
-	options := sshOptions + " -oLogLevel=error"
-
-	sshCmdString := fmt.Sprintf("ssh -i %s %s -p %d %s@%s",
-		f.Name(),
-		options,
-		sshConfig.Port,
-		sshConfig.User,
-		sshConfig.Host,
-	)
-
-	if shell == "" {
-		shell = "bash"
-	}
-	cmd := ""
-	if username != "" {
-		cmd = "sudo -u " + username + " -i "
-	}
-
-	if withTty {
-		// tty option is required for some command like ls
-		sshCmdString += " -t"
-	}
-
-	if withSudo {
-		if cmd == "" {
-			// tty option is required for some command like ls
-			cmd = "sudo"
-		}
-	}
-
-	if cmd != "" {
-		sshCmdString += " " + cmd + " " + shell
-	}
-
-	if cmdString != "" {
-		sshCmdString += fmt.Sprintf(" <<'ENDSSH'\n%s\nENDSSH", cmdString)
-	}
-	return sshCmdString, f, nil
-
+// shellQuote quotes a string to be used as a single word by a shell
+func shellQuote(in string) string {
+	return "'" + strings.Replace(in, "'", `'"'"'`, -1) + "'"
 }
 
 // Command returns the cmd struct to execute cmdString remotely
-func (ssh *SSHConfig) Command(cmdString string) (*SSHCommand, error) {
-	return ssh.command(cmdString, false, false)
+func (sconf *SSHConfig) Command(cmdString string) (*SSHCommand, error) {
+	return sconf.command(cmdString, false)
 }
 
 // SudoCommand returns the cmd struct to execute cmdString remotely. Command is executed with sudo
-func (ssh *SSHConfig) SudoCommand(cmdString string, withSudo bool) (*SSHCommand, error) {
+func (sconf *SSHConfig) SudoCommand(cmdString string, withSudo bool) (*SSHCommand, error) {
 	// FIXME Add traces
-	return ssh.command(cmdString, false, true)
+	return sconf.command(cmdString, true)
 }
 
-func (ssh *SSHConfig) command(cmdString string, withTty, withSudo bool) (*SSHCommand, error) {
-	tunnels, sshConfig, err := ssh.CreateTunneling()
-	if err != nil {
-		return nil, fmt.Errorf("unable to create command : %s", err.Error())
-	}
-	sshCmdString, keyFile, err := createSSHCmd(sshConfig, cmdString, "", "", withTty, withSudo)
-	if err != nil {
-		return nil, fmt.Errorf("unable to create command : %s", err.Error())
+// command prepares the execution of cmdString on the host; the content of cmdString is fed as script to the login shell
+// of the user (or to bash run by sudo if withSudo is true). The session is opened on first use.
+func (sconf *SSHConfig) command(cmdString string, withSudo bool) (*SSHCommand, error) {
+	if sconf == nil {
+		return nil, scerr.InvalidInstanceError()
 	}
-	cmd := exec.Command("bash", "-c", sshCmdString)
+
 	sshCommand := SSHCommand{
-		cmd:     cmd,
-		tunnels: tunnels,
-		keyFile: keyFile,
+		cfg:    sconf,
+		script: cmdString,
+		done:   make(chan struct{}),
+	}
+	if withSudo {
+		sshCommand.command = "sudo bash"
 	}
 	return &sshCommand, nil
 }
 
 // WaitServerReady waits until the SSH server is ready
 // the 'timeout' parameter is in minutes
-func (ssh *SSHConfig) WaitServerReady(phase string, timeout time.Duration) (out string, err error) {
-	if ssh == nil {
+func (sconf *SSHConfig) WaitServerReady(phase string, timeout time.Duration) (out string, err error) {
+	if sconf == nil {
 		return "", scerr.InvalidInstanceError()
 	}
 	if phase == "" {
 		return "", scerr.InvalidParameterError("phase", "cannot be empty string")
 	}
-	if ssh.Host == "" {
-		return "", scerr.InvalidInstanceContentError("ssh.Host", "cannot be empty string")
+	if sconf.Host == "" {
+		return "", scerr.InvalidInstanceContentError("sconf.Host", "cannot be empty string")
 	}
 
 	defer concurrency.NewTracer(nil, fmt.Sprintf("('%s',%s)", phase, temporal.FormatDuration(timeout)), false).GoingIn().OnExitTrace()()
 
 	defer scerr.OnExitTraceError(
-		fmt.Sprintf("timeout waiting remote SSH phase '%s' of host '%s' for %s", phase, ssh.Host, temporal.FormatDuration(timeout)),
+		fmt.Sprintf("timeout waiting remote SSH phase '%s' of host '%s' for %s", phase, sconf.Host, temporal.FormatDuration(timeout)),
 		&err,
 	)()
 
@@ -835,7 +747,7 @@ func (ssh *SSHConfig) WaitServerReady(phase string, timeout time.Duration) (out
 	begins := time.Now()
 	retryErr := retry.WhileUnsuccessfulDelay5Seconds(
 		func() error {
-			cmd, err := ssh.Command(fmt.Sprintf("sudo cat %s/user_data.%s.done", utils.StateFolder, phase))
+			cmd, err := sconf.Command(fmt.Sprintf("sudo cat %s/user_data.%s.done", utils.StateFolder, phase))
 			if err != nil {
 				return err
 			}
@@ -845,7 +757,7 @@ func (ssh *SSHConfig) WaitServerReady(phase string, timeout time.Duration) (out
 				return err
 			}
 			if retcode != 0 {
-				if retcode == 255 {
+				if retcode == sshConnectionFailedRetCode {
 					return fmt.Errorf("remote SSH not ready: error code: 255; Output [%s]; Error [%s]", stdout, stderr)
 				}
 				return fmt.Errorf("remote SSH NOT ready: error code: %d; Output [%s]; Error [%s]", retcode, stdout, stderr)
@@ -857,199 +769,248 @@ func (ssh *SSHConfig) WaitServerReady(phase string, timeout time.Duration) (out
 	if retryErr != nil {
 		return stdout, retryErr
 	}
-	logrus.Debugf("host [%s] phase [%s] check successful in [%s]: host stdout is [%s]", ssh.Host, originalPhase, temporal.FormatDuration(time.Since(begins)), stdout)
+	logrus.Debugf("host [%s] phase [%s] check successful in [%s]: host stdout is [%s]", sconf.Host, originalPhase, temporal.FormatDuration(time.Since(begins)), stdout)
 	return stdout, nil
 }
 
-// Copy copies a file/directory from/to local to/from remote
-func (ssh *SSHConfig) Copy(remotePath, localPath string, isUpload bool) (int, string, string, error) {
-	tunnels, sshConfig, err := ssh.CreateTunneling()
-	if err != nil {
-		return 0, "", "", fmt.Errorf("unable to create tunnels : %s", err.Error())
-	}
-
-	identityfile, err := CreateTempFileFromString(sshConfig.PrivateKey, 0400)
+// Copy copies a file from/to local to/from remote
+// If the destination is an existing directory, the file is copied inside it with the same name.
+func (sconf *SSHConfig) Copy(remotePath, localPath string, isUpload bool) (int, string, string, error) {
+	client, session, err := sconf.newSession()
 	if err != nil {
-		return 0, "", "", fmt.Errorf("unable to create temporary key file: %s", err.Error())
+		if _, ok := err.(*ErrSSHConnection); ok {
+			return sshConnectionFailedRetCode, "", err.Error(), nil
+		}
+		return -1, "", "", err
 	}
+	defer func() {
+		_ = session.Close()
+		sshPool.release(client)
+	}()
 
-	cmdTemplate, err := template.New("Command").Parse(`scp -i {{.IdentityFile}} -P {{.Port}} {{.Options}} {{if .IsUpload}}"{{.LocalPath}}" {{.User}}@{{.Host}}:"{{.RemotePath}}"{{else}}{{.User}}@{{.Host}}:"{{.RemotePath}}" "{{.LocalPath}}"{{end}}`)
-	if err != nil {
-		return 0, "", "", fmt.Errorf("error parsing command template: %s", err.Error())
-	}
-
-	options := sshOptions + " -oLogLevel=error"
-	var copyCommand bytes.Buffer
-	if err := cmdTemplate.Execute(&copyCommand, struct {
-		IdentityFile string
-		Port         int
-		Options      string
-		User         string
-		Host         string
-		RemotePath   string
-		LocalPath    string
-		IsUpload     bool
-	}{
-		IdentityFile: identityfile.Name(),
-		Port:         sshConfig.Port,
-		Options:      options,
-		User:         sshConfig.User,
-		Host:         sshConfig.Host,
-		RemotePath:   remotePath,
-		LocalPath:    localPath,
-		IsUpload:     isUpload,
-	}); err != nil {
-		return 0, "", "", fmt.Errorf("error executing template: %s", err.Error())
-	}
-
-	sshCmdString := copyCommand.String()
-	cmd := exec.Command("bash", "-c", sshCmdString)
-	sshCommand := SSHCommand{
-		cmd:     cmd,
-		tunnels: tunnels,
-		keyFile: identityfile,
-	}
+	var stdout, stderr bytes.Buffer
+	session.Stderr = &stderr
 
-	return sshCommand.Run(nil, outputs.COLLECT) // FIXME: It CAN lock, use .RunWithTimeout instead
-}
+	if isUpload {
+		file, err := os.Open(localPath)
+		if err != nil {
+			return -1, "", "", err
+		}
+		defer func() {
+			_ = file.Close()
+		}()
+		info, err := file.Stat()
+		if err != nil {
+			return -1, "", "", err
+		}
+		if info.IsDir() {
+			return -1, "", "", fmt.Errorf("'%s' is a directory", localPath)
+		}
 
-// Exec executes the cmd using ssh
-func (ssh *SSHConfig) Exec(cmdString string) error {
-	tunnels, sshConfig, err := ssh.CreateTunneling()
-	if err != nil {
-		for _, t := range tunnels {
-			nerr := t.Close()
-			if nerr != nil {
-				logrus.Warnf("Error closing ssh tunnel: %v", nerr)
-			}
+		session.Stdin = file
+		session.Stdout = &stdout
+		err = session.Run(fmt.Sprintf(`f=%s; if [ -d "$f" ]; then f="$f"/%s; fi; cat >"$f" && chmod %04o "$f"`,
+			shellQuote(remotePath), shellQuote(filepath.Base(localPath)), info.Mode().Perm()))
+	} else {
+		target := localPath
+		if info, err := os.Stat(localPath); err == nil && info.IsDir() {
+			target = filepath.Join(localPath, path.Base(remotePath))
 		}
-		return fmt.Errorf("unable to create command : %s", err.Error())
+		file, err := os.Create(target)
+		if err != nil {
+			return -1, "", "", err
+		}
+		defer func() {
+			_ = file.Close()
+		}()
+
+		session.Stdout = file
+		err = session.Run("cat " + shellQuote(remotePath))
 	}
-	sshCmdString, keyFile, err := createSSHCmd(sshConfig, cmdString, "", "", false, false)
 	if err != nil {
-		for _, t := range tunnels {
-			nerr := t.Close()
-			if nerr != nil {
-				logrus.Warnf("Error closing ssh tunnel: %v", nerr)
+		msg, retcode, erro := ExtractRetCode(err)
+		if erro != nil {
+			return -1, stdout.String(), stderr.String(), err
+		}
+		return retcode, stdout.String(), fmt.Sprint(stderr.String(), msg), nil
+	}
+	return 0, stdout.String(), stderr.String(), nil
+}
+
+// forwardSignals sends to the remote command the interruption signals received by the local process,
+// until the returned function is called
+func forwardSignals(session *ssh.Session) func() {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case sig := <-sigs:
+				remoteSig := ssh.SIGINT
+				switch sig {
+				case syscall.SIGTERM:
+					remoteSig = ssh.SIGTERM
+				case syscall.SIGHUP:
+					remoteSig = ssh.SIGHUP
+				}
+				err := session.Signal(remoteSig)
+				if err != nil {
+					logrus.Debugf("failed to forward signal '%s' to remote command: %v", remoteSig, err)
+				}
+			case <-done:
+				return
 			}
 		}
-		if keyFile != nil {
-			nerr := utils.LazyRemove(keyFile.Name())
-			if nerr != nil {
-				logrus.Warnf("Error removing file %v", nerr)
+	}()
+	return func() {
+		signal.Stop(sigs)
+		close(done)
+	}
+}
+
+// followWindowSize propagates the size changes of the local terminal to the remote pseudo-terminal,
+// until the returned function is called
+func followWindowSize(fd int, session *ssh.Session) func() {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGWINCH)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-sigs:
+				width, height, err := terminal.GetSize(fd)
+				if err == nil {
+					err = session.WindowChange(height, width)
+				}
+				if err != nil {
+					logrus.Debugf("failed to propagate terminal size: %v", err)
+				}
+			case <-done:
+				return
 			}
 		}
-		return fmt.Errorf("unable to create command : %s", err.Error())
+	}()
+	return func() {
+		signal.Stop(sigs)
+		close(done)
+	}
+}
+
+// Exec executes the cmd using ssh, with remote outputs displayed on local outputs
+func (sconf *SSHConfig) Exec(cmdString string) error {
+	if cmdString == "" {
+		return sconf.Enter("", "")
 	}
-	bash, err := exec.LookPath("bash")
+
+	sshCmd, err := sconf.command(cmdString, false)
 	if err != nil {
-		for _, t := range tunnels {
-			nerr := t.Close()
-			if nerr != nil {
-				logrus.Warnf("Error closing ssh tunnel: %v", nerr)
-			}
-		}
-		if keyFile != nil {
-			nerr := utils.LazyRemove(keyFile.Name())
-			if nerr != nil {
-				logrus.Warnf("Error removing file %v", nerr)
-			}
-		}
 		return fmt.Errorf("unable to create command : %s", err.Error())
 	}
-	var args []string
-	if cmdString == "" {
-		args = []string{sshCmdString}
-	} else {
-		args = []string{"-c", sshCmdString}
+	err = sshCmd.open()
+	if err != nil {
+		return err
 	}
-	err = syscall.Exec(bash, args, nil)
-	nerr := utils.LazyRemove(keyFile.Name())
-	if nerr != nil {
-		logrus.Warnf("Error removing (lazy) file %v", nerr)
+	sshCmd.session.Stdout = os.Stdout
+	sshCmd.session.Stderr = os.Stderr
+
+	stopForward := forwardSignals(sshCmd.session)
+	defer stopForward()
+
+	err = sshCmd.Start()
+	if err != nil {
+		_ = sshCmd.cleanup()
+		return err
 	}
-	return err
+	return sshCmd.Wait()
 }
 
 // Enter Enter to interactive shell
-func (ssh *SSHConfig) Enter(username, shell string) error {
-	tunnels, sshConfig, err := ssh.CreateTunneling()
+func (sconf *SSHConfig) Enter(username, shell string) error {
+	client, session, err := sconf.newSession()
 	if err != nil {
-		for _, t := range tunnels {
-			nerr := t.Close()
-			if nerr != nil {
-				logrus.Warnf("Error closing ssh tunnel: %v", nerr)
-			}
-		}
-		return fmt.Errorf("unable to create command : %s", err.Error())
+		return err
 	}
+	defer func() {
+		_ = session.Close()
+		sshPool.release(client)
+	}()
 
-	sshCmdString, keyFile, err := createSSHCmd(sshConfig, "", username, shell, true, false)
-	if err != nil {
-		for _, t := range tunnels {
-			nerr := t.Close()
-			if nerr != nil {
-				logrus.Warnf("Error closing ssh tunnel: %v", nerr)
-			}
-		}
-		if keyFile != nil {
-			nerr := utils.LazyRemove(keyFile.Name())
-			if nerr != nil {
-				logrus.Warnf("Error removing file %v", nerr)
-			}
+	fd := int(os.Stdin.Fd())
+	if terminal.IsTerminal(fd) {
+		state, err := terminal.MakeRaw(fd)
+		if err != nil {
+			return fmt.Errorf("unable to set terminal in raw mode: %s", err.Error())
 		}
-		return fmt.Errorf("unable to create command : %s", err.Error())
-	}
+		defer func() {
+			_ = terminal.Restore(fd, state)
+		}()
 
-	bash, err := exec.LookPath("bash")
-	if err != nil {
-		for _, t := range tunnels {
-			nerr := t.Close()
-			if nerr != nil {
-				logrus.Warnf("Error closing ssh tunnel: %v", nerr)
-			}
+		width, height, err := terminal.GetSize(fd)
+		if err != nil {
+			width, height = 80, 24
 		}
-		if keyFile != nil {
-			nerr := utils.LazyRemove(keyFile.Name())
-			if nerr != nil {
-				logrus.Warnf("Error removing file %v", nerr)
-			}
+		term := os.Getenv("TERM")
+		if term == "" {
+			term = "xterm"
 		}
-		return fmt.Errorf("unable to create command : %s", err.Error())
+		modes := ssh.TerminalModes{
+			ssh.ECHO:          1,
+			ssh.TTY_OP_ISPEED: 14400,
+			ssh.TTY_OP_OSPEED: 14400,
+		}
+		err = session.RequestPty(term, height, width, modes)
+		if err != nil {
+			return fmt.Errorf("unable to request pseudo-terminal: %s", err.Error())
+		}
+		stopFollow := followWindowSize(fd, session)
+		defer stopFollow()
+	} else {
+		stopForward := forwardSignals(session)
+		defer stopForward()
 	}
 
-	proc := exec.Command(bash, "-c", sshCmdString)
-	proc.Stdin = os.Stdin
-	proc.Stdout = os.Stdout
-	proc.Stderr = os.Stderr
-	err = proc.Run()
-	nerr := utils.LazyRemove(keyFile.Name())
-	if nerr != nil {
-		logrus.Warnf("Error removing file %v", nerr)
+	session.Stdin = os.Stdin
+	session.Stdout = os.Stdout
+	session.Stderr = os.Stderr
+
+	if shell == "" {
+		shell = "bash"
+	}
+	if username != "" {
+		err = session.Start("sudo -u " + username + " -i " + shell)
+	} else {
+		err = session.Shell()
 	}
-	return err
+	if err != nil {
+		return err
+	}
+	return session.Wait()
 }
 
 // CommandContext is like Command but includes a context.
 //
-// The provided context is used to kill the process (by calling
-// os.Process.Kill) if the context becomes done before the command
-// completes on its own.
-func (ssh *SSHConfig) CommandContext(ctx context.Context, cmdString string) (*SSHCommand, error) {
-	tunnels, sshConfig, err := ssh.CreateTunneling()
-	if err != nil {
-		return nil, fmt.Errorf("unable to create command : %s", err.Error())
+// The provided context is used to kill the remote command if the context becomes done
+// before the command completes on its own.
+func (sconf *SSHConfig) CommandContext(ctx context.Context, cmdString string) (*SSHCommand, error) {
+	if ctx == nil {
+		return nil, scerr.InvalidParameterError("ctx", "cannot be nil")
 	}
-	sshCmdString, keyFile, err := createSSHCmd(sshConfig, cmdString, "", "", false, false)
+
+	sshCommand, err := sconf.command(cmdString, false)
 	if err != nil {
 		return nil, fmt.Errorf("unable to create command : %s", err.Error())
 	}
 
-	cmd := exec.CommandContext(ctx, "bash", "-c", sshCmdString)
-	sshCommand := SSHCommand{
-		cmd:     cmd,
-		tunnels: tunnels,
-		keyFile: keyFile,
-	}
+	go func() {
+		select {
+		case <-ctx.Done():
+			err := sshCommand.Kill()
+			if err != nil {
+				logrus.Warnf("failed to kill remote command: %v", err)
+			}
+		case <-sshCommand.done:
+		}
+	}()
 	return &sshCommand, nil
 }
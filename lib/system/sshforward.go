@@ -0,0 +1,440 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package system
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/CS-SI/SafeScale/lib/utils/retry"
+	"github.com/CS-SI/SafeScale/lib/utils/retry/enums/verdict"
+	"github.com/CS-SI/SafeScale/lib/utils/scerr"
+	"github.com/CS-SI/SafeScale/lib/utils/temporal"
+)
+
+// ForwardMode tells how a forward relays the connections
+type ForwardMode string
+
+const (
+	// ForwardLocal relays the connections accepted locally to an address reachable from the host
+	ForwardLocal ForwardMode = "local"
+	// ForwardRemote relays the connections accepted on the host to an address reachable locally
+	ForwardRemote ForwardMode = "remote"
+	// ForwardDynamic is a local SOCKS5 proxy relaying the connections from the host
+	ForwardDynamic ForwardMode = "dynamic"
+)
+
+// ForwardState is the state of the SSH connection of a forward
+type ForwardState string
+
+const (
+	// ForwardUp means the forward is relaying connections
+	ForwardUp ForwardState = "up"
+	// ForwardReconnecting means the SSH connection has been lost and is being restored
+	ForwardReconnecting ForwardState = "reconnecting"
+	// ForwardClosed means the forward has been closed
+	ForwardClosed ForwardState = "closed"
+)
+
+// forwardRetryDelay is the delay between 2 attempts to restore the SSH connection of a forward
+const forwardRetryDelay = 5 * time.Second
+
+// SSHForward is a port forwarding through a SSH connection to an host, restored automatically when the
+// connection drops
+type SSHForward struct {
+	cfg    *SSHConfig
+	mode   ForwardMode
+	bind   string // listening address; local for ForwardLocal and ForwardDynamic, on the host for ForwardRemote
+	target string // address the connections are relayed to; unused for ForwardDynamic
+
+	lock       sync.Mutex
+	client     *sshClient
+	listener   net.Listener
+	state      ForwardState
+	lastErr    error
+	reconnects int
+
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// Forward starts a port forwarding through the host. bind and target are addresses in the form "host:port".
+func (sconf *SSHConfig) Forward(mode ForwardMode, bind, target string) (*SSHForward, error) {
+	if sconf == nil {
+		return nil, scerr.InvalidInstanceError()
+	}
+	if bind == "" {
+		return nil, scerr.InvalidParameterError("bind", "cannot be empty string")
+	}
+	switch mode {
+	case ForwardLocal, ForwardRemote:
+		if target == "" {
+			return nil, scerr.InvalidParameterError("target", "cannot be empty string")
+		}
+	case ForwardDynamic:
+	default:
+		return nil, scerr.InvalidParameterError("mode", fmt.Sprintf("'%s' is not a valid forward mode", mode))
+	}
+
+	f := &SSHForward{
+		cfg:    sconf,
+		mode:   mode,
+		bind:   bind,
+		target: target,
+		closed: make(chan struct{}),
+	}
+	if mode != ForwardRemote {
+		listener, err := net.Listen("tcp", bind)
+		if err != nil {
+			return nil, err
+		}
+		f.listener = listener
+		f.bind = listener.Addr().String()
+	}
+
+	err := f.connect()
+	if err != nil {
+		if f.listener != nil {
+			_ = f.listener.Close()
+		}
+		return nil, err
+	}
+	if mode != ForwardRemote {
+		go f.serveLocal(f.listener)
+	}
+	go f.supervise()
+	return f, nil
+}
+
+// Mode returns the mode of the forward
+func (f *SSHForward) Mode() ForwardMode {
+	return f.mode
+}
+
+// Bind returns the listening address of the forward
+func (f *SSHForward) Bind() string {
+	return f.bind
+}
+
+// Target returns the address the connections are relayed to
+func (f *SSHForward) Target() string {
+	return f.target
+}
+
+// Status returns the state of the forward, the number of times its connection has been restored and the last
+// error encountered, if any
+func (f *SSHForward) Status() (ForwardState, int, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.state, f.reconnects, f.lastErr
+}
+
+// Close stops the forward
+func (f *SSHForward) Close() error {
+	f.closeOnce.Do(func() {
+		close(f.closed)
+
+		f.lock.Lock()
+		defer f.lock.Unlock()
+		f.state = ForwardClosed
+		if f.listener != nil {
+			_ = f.listener.Close()
+		}
+		if f.client != nil {
+			sshPool.release(f.client)
+			f.client = nil
+		}
+	})
+	return nil
+}
+
+// connect opens the SSH connection and, for a remote forward, the listener on the host
+func (f *SSHForward) connect() error {
+	client, err := sshPool.acquire(f.cfg)
+	if err != nil {
+		return err
+	}
+
+	var listener net.Listener
+	if f.mode == ForwardRemote {
+		listener, err = client.client.Listen("tcp", f.bind)
+		if err != nil {
+			sshPool.release(client)
+			return fmt.Errorf("failed to listen on '%s' on host '%s': %s", f.bind, f.cfg.Host, err.Error())
+		}
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	select {
+	case <-f.closed:
+		if listener != nil {
+			_ = listener.Close()
+		}
+		sshPool.release(client)
+		return retry.AbortedError("forward closed", nil)
+	default:
+	}
+	f.client = client
+	f.state = ForwardUp
+	if listener != nil {
+		f.listener = listener
+		go f.serveRemote(listener)
+	}
+	return nil
+}
+
+// supervise waits for the SSH connection to drop, and restores it until the forward is closed
+func (f *SSHForward) supervise() {
+	for {
+		f.lock.Lock()
+		client := f.client
+		f.lock.Unlock()
+		if client == nil {
+			return
+		}
+
+		lost := make(chan error, 1)
+		go func() {
+			lost <- client.client.Wait()
+		}()
+		select {
+		case <-f.closed:
+			return
+		case err := <-lost:
+			logrus.Warnf("SSH connection of forward '%s' lost: %v", f.bind, err)
+		}
+
+		f.lock.Lock()
+		if f.client == client {
+			sshPool.release(client)
+			f.client = nil
+		}
+		f.state = ForwardReconnecting
+		f.lock.Unlock()
+
+		for {
+			select {
+			case <-f.closed:
+				return
+			default:
+			}
+			err := retry.WhileUnsuccessfulWithNotify(
+				f.connect,
+				forwardRetryDelay,
+				temporal.GetHostTimeout(),
+				func(t retry.Try, v verdict.Enum) {
+					if t.Err != nil {
+						f.lock.Lock()
+						f.lastErr = t.Err
+						f.lock.Unlock()
+					}
+				},
+			)
+			if err == nil {
+				break
+			}
+			if _, ok := err.(retry.ErrAborted); ok {
+				return
+			}
+			logrus.Warnf("failed to restore SSH connection of forward '%s', still trying: %v", f.bind, err)
+		}
+
+		f.lock.Lock()
+		f.reconnects++
+		f.lock.Unlock()
+		logrus.Infof("SSH connection of forward '%s' restored", f.bind)
+	}
+}
+
+// dial opens a connection to address through the SSH connection
+func (f *SSHForward) dial(address string) (net.Conn, error) {
+	f.lock.Lock()
+	client := f.client
+	f.lock.Unlock()
+	if client == nil {
+		return nil, fmt.Errorf("SSH connection to '%s' unavailable", f.cfg.Host)
+	}
+	return client.client.Dial("tcp", address)
+}
+
+// serveLocal accepts local connections and relays them through the host
+func (f *SSHForward) serveLocal(listener net.Listener) {
+	for {
+		local, err := listener.Accept()
+		if err != nil {
+			// listener closed
+			return
+		}
+		go func() {
+			var err error
+			target := f.target
+			if f.mode == ForwardDynamic {
+				target, err = socks5Handshake(local)
+				if err != nil {
+					logrus.Debugf("forward '%s' rejected SOCKS request: %v", f.bind, err)
+					_ = local.Close()
+					return
+				}
+			}
+			remote, err := f.dial(target)
+			if f.mode == ForwardDynamic {
+				nerr := socks5Reply(local, err)
+				if nerr != nil && err == nil {
+					err = nerr
+					_ = remote.Close()
+				}
+			}
+			if err != nil {
+				logrus.Warnf("forward '%s' failed to relay connection to '%s': %v", f.bind, target, err)
+				_ = local.Close()
+				return
+			}
+			bridgeConnections(local, remote)
+		}()
+	}
+}
+
+// serveRemote accepts connections on the host and relays them to the target
+func (f *SSHForward) serveRemote(listener net.Listener) {
+	for {
+		remote, err := listener.Accept()
+		if err != nil {
+			// listener closed or SSH connection lost
+			return
+		}
+		go func() {
+			local, err := net.DialTimeout("tcp", f.target, temporal.GetConnectionTimeout())
+			if err != nil {
+				logrus.Warnf("forward '%s' failed to relay connection to '%s': %v", f.bind, f.target, err)
+				_ = remote.Close()
+				return
+			}
+			bridgeConnections(remote, local)
+		}()
+	}
+}
+
+// socks5 protocol constants (RFC 1928)
+const (
+	socks5Version         = 0x05
+	socks5NoAuth          = 0x00
+	socks5NoAcceptable    = 0xff
+	socks5CmdConnect      = 0x01
+	socks5AddrIPv4        = 0x01
+	socks5AddrDomain      = 0x03
+	socks5AddrIPv6        = 0x04
+	socks5Succeeded       = 0x00
+	socks5GeneralFailure  = 0x01
+	socks5CmdUnsupported  = 0x07
+	socks5AddrUnsupported = 0x08
+)
+
+// socks5Handshake negotiates a SOCKS5 CONNECT request without authentication and returns the requested address
+func socks5Handshake(conn io.ReadWriter) (string, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return "", err
+	}
+	if header[0] != socks5Version {
+		return "", fmt.Errorf("unsupported SOCKS version %d", header[0])
+	}
+	methods := make([]byte, header[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return "", err
+	}
+	accepted := false
+	for _, m := range methods {
+		if m == socks5NoAuth {
+			accepted = true
+			break
+		}
+	}
+	if !accepted {
+		_, _ = conn.Write([]byte{socks5Version, socks5NoAcceptable})
+		return "", fmt.Errorf("no supported authentication method")
+	}
+	if _, err := conn.Write([]byte{socks5Version, socks5NoAuth}); err != nil {
+		return "", err
+	}
+
+	request := make([]byte, 4)
+	if _, err := io.ReadFull(conn, request); err != nil {
+		return "", err
+	}
+	if request[0] != socks5Version {
+		return "", fmt.Errorf("unsupported SOCKS version %d", request[0])
+	}
+	if request[1] != socks5CmdConnect {
+		_ = socks5WriteReply(conn, socks5CmdUnsupported)
+		return "", fmt.Errorf("unsupported SOCKS command %d", request[1])
+	}
+
+	var host string
+	switch request[3] {
+	case socks5AddrIPv4, socks5AddrIPv6:
+		size := net.IPv4len
+		if request[3] == socks5AddrIPv6 {
+			size = net.IPv6len
+		}
+		ip := make([]byte, size)
+		if _, err := io.ReadFull(conn, ip); err != nil {
+			return "", err
+		}
+		host = net.IP(ip).String()
+	case socks5AddrDomain:
+		length := make([]byte, 1)
+		if _, err := io.ReadFull(conn, length); err != nil {
+			return "", err
+		}
+		name := make([]byte, length[0])
+		if _, err := io.ReadFull(conn, name); err != nil {
+			return "", err
+		}
+		host = string(name)
+	default:
+		_ = socks5WriteReply(conn, socks5AddrUnsupported)
+		return "", fmt.Errorf("unsupported SOCKS address type %d", request[3])
+	}
+
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(conn, port); err != nil {
+		return "", err
+	}
+	return net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port)))), nil
+}
+
+// socks5Reply tells the SOCKS5 client whether the connection to the requested address succeeded
+func socks5Reply(conn io.Writer, err error) error {
+	if err != nil {
+		return socks5WriteReply(conn, socks5GeneralFailure)
+	}
+	return socks5WriteReply(conn, socks5Succeeded)
+}
+
+// socks5WriteReply writes a SOCKS5 reply with the status code and an unspecified bound address
+func socks5WriteReply(conn io.Writer, code byte) error {
+	_, err := conn.Write([]byte{socks5Version, code, 0x00, socks5AddrIPv4, 0, 0, 0, 0, 0, 0})
+	return err
+}
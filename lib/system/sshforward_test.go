@@ -0,0 +1,68 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package system
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// socksConn replays a SOCKS request and records the replies
+type socksConn struct {
+	in  *bytes.Reader
+	out bytes.Buffer
+}
+
+func (c *socksConn) Read(p []byte) (int, error) {
+	return c.in.Read(p)
+}
+
+func (c *socksConn) Write(p []byte) (int, error) {
+	return c.out.Write(p)
+}
+
+func TestSocks5Handshake(t *testing.T) {
+	// CONNECT to example.org:443
+	request := []byte{0x05, 0x01, 0x00, 0x05, 0x01, 0x00, 0x03, 11}
+	request = append(request, []byte("example.org")...)
+	request = append(request, 0x01, 0xbb)
+	conn := &socksConn{in: bytes.NewReader(request)}
+	address, err := socks5Handshake(conn)
+	assert.Nil(t, err)
+	assert.Equal(t, "example.org:443", address)
+	assert.Equal(t, []byte{0x05, 0x00}, conn.out.Bytes())
+
+	// CONNECT to 10.0.0.3:22
+	conn = &socksConn{in: bytes.NewReader([]byte{0x05, 0x01, 0x00, 0x05, 0x01, 0x00, 0x01, 10, 0, 0, 3, 0x00, 0x16})}
+	address, err = socks5Handshake(conn)
+	assert.Nil(t, err)
+	assert.Equal(t, "10.0.0.3:22", address)
+
+	// Only username/password authentication offered
+	conn = &socksConn{in: bytes.NewReader([]byte{0x05, 0x01, 0x02})}
+	_, err = socks5Handshake(conn)
+	assert.NotNil(t, err)
+	assert.Equal(t, []byte{0x05, 0xff}, conn.out.Bytes())
+
+	// BIND is not supported
+	conn = &socksConn{in: bytes.NewReader([]byte{0x05, 0x01, 0x00, 0x05, 0x02, 0x00, 0x01, 10, 0, 0, 3, 0x00, 0x16})}
+	_, err = socks5Handshake(conn)
+	assert.NotNil(t, err)
+	assert.Equal(t, byte(0x07), conn.out.Bytes()[3])
+}
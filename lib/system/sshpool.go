@@ -0,0 +1,342 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package system
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/CS-SI/SafeScale/lib/utils/scerr"
+	"github.com/CS-SI/SafeScale/lib/utils/temporal"
+)
+
+const (
+	// sshMaxUsersPerClient limits the number of sessions and forwards multiplexed on a single connection;
+	// kept below the default MaxSessions (10) of OpenSSH server
+	sshMaxUsersPerClient = 8
+	// sshClientIdleTimeout is the duration after which an unused connection is closed
+	sshClientIdleTimeout = 5 * time.Minute
+	// sshConnectionFailedRetCode is the exit code of ssh binary when the connection fails
+	sshConnectionFailedRetCode = 255
+)
+
+// ErrSSHConnection is returned when a SSH connection to a host cannot be established.
+// Its exit status is the one returned by the ssh binary in the same situation, so retry policies
+// based on this code keep working.
+type ErrSSHConnection struct {
+	host string
+	err  error
+}
+
+// Error returns the message of the error
+func (e *ErrSSHConnection) Error() string {
+	return fmt.Sprintf("failed to connect to '%s': %s", e.host, e.err.Error())
+}
+
+// ExitStatus returns the exit code corresponding to a connection failure
+func (e *ErrSSHConnection) ExitStatus() int {
+	return sshConnectionFailedRetCode
+}
+
+// hostKeyLock protects the update of SSHConfig.HostKey when the key is captured on first connection
+var hostKeyLock sync.Mutex
+
+// getHostKey returns the host key pinned in the configuration
+func (sconf *SSHConfig) getHostKey() string {
+	hostKeyLock.Lock()
+	defer hostKeyLock.Unlock()
+	return sconf.HostKey
+}
+
+// setHostKey records the host key if none is pinned yet
+func (sconf *SSHConfig) setHostKey(key string) {
+	hostKeyLock.Lock()
+	defer hostKeyLock.Unlock()
+	if sconf.HostKey == "" {
+		sconf.HostKey = key
+	}
+}
+
+// hostKeyCallback returns the callback validating the key of the remote host.
+// If a host key is pinned in the configuration, only this key is accepted; otherwise the key presented
+// by the host is accepted and captured in the configuration (trust on first use).
+func (sconf *SSHConfig) hostKeyCallback() (ssh.HostKeyCallback, error) {
+	pinned := sconf.getHostKey()
+	if pinned != "" {
+		key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(pinned))
+		if err != nil {
+			return nil, fmt.Errorf("invalid host key pinned for '%s': %s", sconf.Host, err.Error())
+		}
+		return ssh.FixedHostKey(key), nil
+	}
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		sconf.setHostKey(strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key))))
+		return nil
+	}, nil
+}
+
+// clientConfig builds the configuration used by golang.org/x/crypto/ssh to connect to the host
+func (sconf *SSHConfig) clientConfig() (*ssh.ClientConfig, error) {
+	signer, err := ssh.ParsePrivateKey([]byte(sconf.PrivateKey))
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key for '%s': %s", sconf.Host, err.Error())
+	}
+	callback, err := sconf.hostKeyCallback()
+	if err != nil {
+		return nil, err
+	}
+	return &ssh.ClientConfig{
+		User:            sconf.User,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback: callback,
+		Timeout:         temporal.GetConnectionTimeout(),
+	}, nil
+}
+
+// address returns the network address of the SSH server
+func (sconf *SSHConfig) address() string {
+	return net.JoinHostPort(sconf.Host, strconv.Itoa(sconf.Port))
+}
+
+// poolKey identifies a connection in the pool; the gateways used to reach the host are part of the key,
+// because private IP addresses may be reused in different networks
+func (sconf *SSHConfig) poolKey() string {
+	sum := sha256.Sum256([]byte(sconf.PrivateKey))
+	key := fmt.Sprintf("%s@%s/%x", sconf.User, sconf.address(), sum[:8])
+	if sconf.GatewayConfig != nil {
+		key = sconf.GatewayConfig.poolKey() + "|" + key
+	}
+	return key
+}
+
+// sshClient is a SSH connection shared by several sessions and port forwards
+type sshClient struct {
+	key      string
+	client   *ssh.Client
+	gateway  *sshClient
+	hostKey  string
+	users    int
+	lastUsed time.Time
+	closed   bool
+}
+
+// sshClientPool keeps the SSH connections opened to the hosts, to multiplex commands over them
+type sshClientPool struct {
+	lock    sync.Mutex
+	clients map[string][]*sshClient
+	janitor sync.Once
+}
+
+var sshPool = &sshClientPool{clients: map[string][]*sshClient{}}
+
+// acquire returns a connection to the host described by cfg, reusing a pooled one when possible.
+// Each successful call must be balanced by a call to release.
+func (pool *sshClientPool) acquire(cfg *SSHConfig) (*sshClient, error) {
+	if cfg == nil {
+		return nil, scerr.InvalidParameterError("cfg", "cannot be nil")
+	}
+
+	pool.janitor.Do(func() {
+		go pool.expire()
+	})
+
+	key := cfg.poolKey()
+	pinned := cfg.getHostKey()
+
+	pool.lock.Lock()
+	for _, c := range pool.clients[key] {
+		if c.closed || c.users >= sshMaxUsersPerClient {
+			continue
+		}
+		if pinned != "" && pinned != c.hostKey {
+			continue
+		}
+		c.users++
+		c.lastUsed = time.Now()
+		pool.lock.Unlock()
+		cfg.setHostKey(c.hostKey)
+		return c, nil
+	}
+	pool.lock.Unlock()
+
+	c, err := pool.dial(cfg, key)
+	if err != nil {
+		return nil, err
+	}
+
+	pool.lock.Lock()
+	c.users = 1
+	c.lastUsed = time.Now()
+	pool.clients[key] = append(pool.clients[key], c)
+	pool.lock.Unlock()
+
+	go pool.watch(c)
+	return c, nil
+}
+
+// dial opens a new connection to the host, through the chain of gateways if needed
+func (pool *sshClientPool) dial(cfg *SSHConfig, key string) (*sshClient, error) {
+	clientConfig, err := cfg.clientConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	addr := cfg.address()
+	c := &sshClient{key: key}
+	if cfg.GatewayConfig == nil {
+		c.client, err = ssh.Dial("tcp", addr, clientConfig)
+		if err != nil {
+			return nil, &ErrSSHConnection{host: addr, err: err}
+		}
+	} else {
+		gw, err := pool.acquire(cfg.GatewayConfig)
+		if err != nil {
+			return nil, err
+		}
+		conn, err := gw.client.Dial("tcp", addr)
+		if err != nil {
+			pool.release(gw)
+			return nil, &ErrSSHConnection{host: addr, err: err}
+		}
+		sshConn, chans, reqs, err := ssh.NewClientConn(conn, addr, clientConfig)
+		if err != nil {
+			_ = conn.Close()
+			pool.release(gw)
+			return nil, &ErrSSHConnection{host: addr, err: err}
+		}
+		c.client = ssh.NewClient(sshConn, chans, reqs)
+		c.gateway = gw
+	}
+	c.hostKey = cfg.getHostKey()
+	return c, nil
+}
+
+// release tells the pool the connection is not used anymore by the caller
+func (pool *sshClientPool) release(c *sshClient) {
+	if c == nil {
+		return
+	}
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+	if c.users > 0 {
+		c.users--
+	}
+	c.lastUsed = time.Now()
+}
+
+// forget removes the connection from the pool; must be called with pool.lock held
+func (pool *sshClientPool) forget(c *sshClient) {
+	c.closed = true
+	list := pool.clients[c.key]
+	for i, v := range list {
+		if v == c {
+			list = append(list[:i], list[i+1:]...)
+			break
+		}
+	}
+	if len(list) == 0 {
+		delete(pool.clients, c.key)
+	} else {
+		pool.clients[c.key] = list
+	}
+}
+
+// watch waits for the connection to end, then removes it from the pool
+func (pool *sshClientPool) watch(c *sshClient) {
+	err := c.client.Wait()
+	if err != nil {
+		logrus.Debugf("SSH connection '%s' ended: %v", c.key, err)
+	}
+
+	pool.lock.Lock()
+	pool.forget(c)
+	pool.lock.Unlock()
+
+	if c.gateway != nil {
+		pool.release(c.gateway)
+	}
+}
+
+// invalidate closes a connection that appears broken, so next acquire dials a new one
+func (pool *sshClientPool) invalidate(c *sshClient) {
+	pool.lock.Lock()
+	pool.forget(c)
+	pool.lock.Unlock()
+
+	err := c.client.Close()
+	if err != nil {
+		logrus.Debugf("failed to close SSH connection '%s': %v", c.key, err)
+	}
+}
+
+// expire closes periodically the connections unused for sshClientIdleTimeout
+func (pool *sshClientPool) expire() {
+	for range time.Tick(time.Minute) {
+		var idle []*sshClient
+		pool.lock.Lock()
+		for _, list := range pool.clients {
+			for _, c := range list {
+				if c.users == 0 && time.Since(c.lastUsed) > sshClientIdleTimeout {
+					idle = append(idle, c)
+				}
+			}
+		}
+		for _, c := range idle {
+			pool.forget(c)
+		}
+		pool.lock.Unlock()
+
+		for _, c := range idle {
+			err := c.client.Close()
+			if err != nil {
+				logrus.Debugf("failed to close idle SSH connection '%s': %v", c.key, err)
+			}
+		}
+	}
+}
+
+// newSession opens a session on a pooled connection to the host
+func (sconf *SSHConfig) newSession() (*sshClient, *ssh.Session, error) {
+	var lastErr error
+	for attempt := 0; attempt < 2; attempt++ {
+		c, err := sshPool.acquire(sconf)
+		if err != nil {
+			return nil, nil, err
+		}
+		session, err := c.client.NewSession()
+		if err == nil {
+			return c, session, nil
+		}
+		sshPool.release(c)
+		if _, ok := err.(*ssh.OpenChannelError); ok {
+			// The connection is alive but refuses a new session; don't break the other sessions
+			return nil, nil, err
+		}
+		// The connection is broken; retry once on a new one
+		sshPool.invalidate(c)
+		lastErr = err
+	}
+	return nil, nil, &ErrSSHConnection{host: sconf.address(), err: lastErr}
+}
@@ -0,0 +1,168 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package system
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/CS-SI/SafeScale/lib/utils/scerr"
+)
+
+// PtyRequest describes the pseudo-terminal to allocate for an interactive session
+type PtyRequest struct {
+	Term   string
+	Width  int
+	Height int
+}
+
+// SSHSession is a command running on an host, with its standard streams available to the caller
+type SSHSession struct {
+	client  *sshClient
+	session *ssh.Session
+
+	Stdin  io.WriteCloser
+	Stdout io.Reader
+	Stderr io.Reader
+
+	once sync.Once
+}
+
+// signals contains the signals that can be sent to a remote command, indexed by their name
+var signals = map[string]ssh.Signal{
+	"ABRT": ssh.SIGABRT,
+	"ALRM": ssh.SIGALRM,
+	"FPE":  ssh.SIGFPE,
+	"HUP":  ssh.SIGHUP,
+	"ILL":  ssh.SIGILL,
+	"INT":  ssh.SIGINT,
+	"KILL": ssh.SIGKILL,
+	"PIPE": ssh.SIGPIPE,
+	"QUIT": ssh.SIGQUIT,
+	"SEGV": ssh.SIGSEGV,
+	"TERM": ssh.SIGTERM,
+	"USR1": ssh.SIGUSR1,
+	"USR2": ssh.SIGUSR2,
+}
+
+// OpenSession starts command on the host (the login shell of the user if command is empty), with a
+// pseudo-terminal if pty is not nil
+func (sconf *SSHConfig) OpenSession(command string, pty *PtyRequest) (*SSHSession, error) {
+	if sconf == nil {
+		return nil, scerr.InvalidInstanceError()
+	}
+
+	client, session, err := sconf.newSession()
+	if err != nil {
+		return nil, err
+	}
+	s := &SSHSession{client: client, session: session}
+
+	err = s.setup(command, pty)
+	if err != nil {
+		s.release()
+		return nil, err
+	}
+	return s, nil
+}
+
+// setup connects the standard streams, requests the pseudo-terminal if needed and starts the command
+func (s *SSHSession) setup(command string, pty *PtyRequest) (err error) {
+	if pty != nil {
+		term := pty.Term
+		if term == "" {
+			term = "xterm"
+		}
+		width, height := pty.Width, pty.Height
+		if width <= 0 || height <= 0 {
+			width, height = 80, 24
+		}
+		modes := ssh.TerminalModes{
+			ssh.ECHO:          1,
+			ssh.TTY_OP_ISPEED: 14400,
+			ssh.TTY_OP_OSPEED: 14400,
+		}
+		err = s.session.RequestPty(term, height, width, modes)
+		if err != nil {
+			return fmt.Errorf("unable to request pseudo-terminal: %s", err.Error())
+		}
+	}
+
+	if s.Stdin, err = s.session.StdinPipe(); err != nil {
+		return err
+	}
+	if s.Stdout, err = s.session.StdoutPipe(); err != nil {
+		return err
+	}
+	if s.Stderr, err = s.session.StderrPipe(); err != nil {
+		return err
+	}
+
+	if command == "" {
+		return s.session.Shell()
+	}
+	return s.session.Start(command)
+}
+
+// Resize changes the size of the pseudo-terminal of the session
+func (s *SSHSession) Resize(width, height int) error {
+	return s.session.WindowChange(height, width)
+}
+
+// Signal sends a signal to the remote command; name is the signal name without "SIG" prefix (ie "INT", "TERM", ...)
+func (s *SSHSession) Signal(name string) error {
+	sig, ok := signals[name]
+	if !ok {
+		return scerr.InvalidParameterError("name", fmt.Sprintf("'%s' is not a supported signal", name))
+	}
+	return s.session.Signal(sig)
+}
+
+// Wait waits for the command to exit and returns its exit status. Stdout and Stderr must have been read
+// until EOF before calling Wait. Releases the resources associated with the session.
+func (s *SSHSession) Wait() (int, error) {
+	err := s.session.Wait()
+	s.release()
+	if err != nil {
+		_, retcode, erro := ExtractRetCode(err)
+		if erro != nil {
+			return -1, err
+		}
+		return retcode, nil
+	}
+	return 0, nil
+}
+
+// Close terminates the command if still running and releases the resources associated with the session
+func (s *SSHSession) Close() {
+	s.once.Do(func() {
+		_ = s.session.Signal(ssh.SIGKILL)
+		_ = s.session.Close()
+		sshPool.release(s.client)
+	})
+}
+
+// release closes the session and gives back the connection to the pool
+func (s *SSHSession) release() {
+	s.once.Do(func() {
+		_ = s.session.Close()
+		sshPool.release(s.client)
+	})
+}
@@ -0,0 +1,395 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package system
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/CS-SI/SafeScale/lib/utils/scerr"
+)
+
+// TransferChunkSize is the size of the data blocks exchanged during file transfers
+const TransferChunkSize = 256 * 1024
+
+// FileInfo describes a file involved in a transfer
+type FileInfo struct {
+	Path     string      // path relative to the root of the transfer; empty if the root is the file itself
+	Size     int64       // size of the file in bytes
+	Mode     os.FileMode // permissions of the file
+	Checksum string      // hex-encoded SHA256 of the content; empty if not computed
+}
+
+// TransferProgress reports the advancement of a transfer
+type TransferProgress struct {
+	Path      string // relative path of the file being transferred
+	Done      int64  // bytes of the file already present on the destination
+	Size      int64  // size of the file
+	TotalDone int64  // bytes of all the files already present on the destination
+	TotalSize int64  // size of all the files
+}
+
+// Checksum computes the hex-encoded SHA256 of the content read from r
+func Checksum(r io.Reader) (string, int64, error) {
+	h := sha256.New()
+	n, err := io.Copy(h, r)
+	if err != nil {
+		return "", n, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
+
+// ListLocalFiles returns the files under localPath (or localPath itself if it is a file), with their checksum if asked
+func ListLocalFiles(localPath string, withChecksum bool) ([]FileInfo, bool, error) {
+	info, err := os.Stat(localPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, false, scerr.NotFoundError(fmt.Sprintf("'%s' not found", localPath))
+		}
+		return nil, false, err
+	}
+
+	describe := func(p, rel string, fi os.FileInfo) (FileInfo, error) {
+		item := FileInfo{Path: filepath.ToSlash(rel), Size: fi.Size(), Mode: fi.Mode().Perm()}
+		if withChecksum {
+			f, err := os.Open(p)
+			if err != nil {
+				return item, err
+			}
+			defer func() {
+				_ = f.Close()
+			}()
+			item.Checksum, _, err = Checksum(f)
+			if err != nil {
+				return item, err
+			}
+		}
+		return item, nil
+	}
+
+	if !info.IsDir() {
+		item, err := describe(localPath, "", info)
+		if err != nil {
+			return nil, false, err
+		}
+		return []FileInfo{item}, false, nil
+	}
+
+	var list []FileInfo
+	err = filepath.Walk(localPath, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(localPath, p)
+		if err != nil {
+			return err
+		}
+		item, err := describe(p, rel, fi)
+		if err != nil {
+			return err
+		}
+		list = append(list, item)
+		return nil
+	})
+	if err != nil {
+		return nil, true, err
+	}
+	return list, true, nil
+}
+
+// runOutput runs the command on the host and returns its standard output; standard error is added to the error
+func (sconf *SSHConfig) runOutput(cmd string) ([]byte, error) {
+	client, session, err := sconf.newSession()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = session.Close()
+		sshPool.release(client)
+	}()
+
+	var stdout, stderr bytes.Buffer
+	session.Stdout = &stdout
+	session.Stderr = &stderr
+	err = session.Run(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", err.Error(), strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
+
+// ListRemoteFiles returns the files under remotePath on the host (or remotePath itself if it is a file),
+// with their checksum if asked. Returns a scerr.ErrNotFound if remotePath doesn't exist.
+func (sconf *SSHConfig) ListRemoteFiles(remotePath string, withChecksum bool) ([]FileInfo, bool, error) {
+	if sconf == nil {
+		return nil, false, scerr.InvalidInstanceError()
+	}
+	if remotePath == "" {
+		return nil, false, scerr.InvalidParameterError("remotePath", "cannot be empty string")
+	}
+
+	sum := `sha256sum <"$f" | cut -d' ' -f1`
+	if !withChecksum {
+		sum = `echo -`
+	}
+	script := fmt.Sprintf(`p=%s
+describe() { printf '%%s\t%%s\t%%s\n' "$(stat -c '%%s:%%a' "$f")" "$(%s)" "$1"; }
+if [ -d "$p" ]; then
+	echo dir
+	cd "$p" || exit 1
+	find . -type f -print0 | while IFS= read -r -d '' f; do describe "${f#./}"; done
+elif [ -f "$p" ]; then
+	echo file
+	f="$p"; describe ""
+else
+	echo none
+fi`, shellQuote(remotePath), sum)
+
+	out, err := sconf.runOutput("bash -c " + shellQuote(script))
+	if err != nil {
+		return nil, false, err
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	if !scanner.Scan() {
+		return nil, false, scerr.InconsistentError("empty answer listing remote files")
+	}
+	var isDir bool
+	switch scanner.Text() {
+	case "none":
+		return nil, false, scerr.NotFoundError(fmt.Sprintf("'%s' not found on host '%s'", remotePath, sconf.Host))
+	case "dir":
+		isDir = true
+	}
+
+	var list []FileInfo
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), "\t", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		stat := strings.SplitN(parts[0], ":", 2)
+		if len(stat) != 2 {
+			continue
+		}
+		size, err := strconv.ParseInt(stat[0], 10, 64)
+		if err != nil {
+			return nil, isDir, err
+		}
+		mode, err := strconv.ParseUint(stat[1], 8, 32)
+		if err != nil {
+			return nil, isDir, err
+		}
+		item := FileInfo{Path: parts[2], Size: size, Mode: os.FileMode(mode)}
+		if parts[1] != "-" {
+			item.Checksum = parts[1]
+		}
+		list = append(list, item)
+	}
+	return list, isDir, scanner.Err()
+}
+
+// remoteStream is a pipe to (or from) a command running on the host
+type remoteStream struct {
+	client  *sshClient
+	session *ssh.Session
+	stderr  bytes.Buffer
+	reader  io.Reader
+	writer  io.WriteCloser
+
+	once sync.Once
+	err  error
+}
+
+// Read reads data produced by the remote command
+func (rs *remoteStream) Read(p []byte) (int, error) {
+	return rs.reader.Read(p)
+}
+
+// Write sends data to the remote command
+func (rs *remoteStream) Write(p []byte) (int, error) {
+	return rs.writer.Write(p)
+}
+
+// Close ends the stream, waits for the remote command to end and releases the session
+func (rs *remoteStream) Close() error {
+	rs.once.Do(func() {
+		if rs.writer != nil {
+			_ = rs.writer.Close()
+		}
+		err := rs.session.Wait()
+		if err != nil {
+			rs.err = fmt.Errorf("%s: %s", err.Error(), strings.TrimSpace(rs.stderr.String()))
+		}
+		_ = rs.session.Close()
+		sshPool.release(rs.client)
+	})
+	return rs.err
+}
+
+// abort interrupts the remote command and releases the session
+func (rs *remoteStream) abort() {
+	rs.once.Do(func() {
+		_ = rs.session.Signal(ssh.SIGKILL)
+		_ = rs.session.Close()
+		sshPool.release(rs.client)
+		rs.err = fmt.Errorf("transfer aborted")
+	})
+}
+
+// startStream starts the command on the host with its standard input or output connected to the returned stream
+func (sconf *SSHConfig) startStream(cmd string, write bool) (*remoteStream, error) {
+	client, session, err := sconf.newSession()
+	if err != nil {
+		return nil, err
+	}
+	rs := &remoteStream{client: client, session: session}
+	session.Stderr = &rs.stderr
+	if write {
+		rs.writer, err = session.StdinPipe()
+	} else {
+		rs.reader, err = session.StdoutPipe()
+	}
+	if err == nil {
+		err = session.Start(cmd)
+	}
+	if err != nil {
+		_ = session.Close()
+		sshPool.release(client)
+		return nil, err
+	}
+	return rs, nil
+}
+
+// OpenRemoteReader returns a stream reading the content of the file remotePath on the host, starting at offset
+func (sconf *SSHConfig) OpenRemoteReader(remotePath string, offset int64) (io.ReadCloser, error) {
+	if sconf == nil {
+		return nil, scerr.InvalidInstanceError()
+	}
+	if remotePath == "" {
+		return nil, scerr.InvalidParameterError("remotePath", "cannot be empty string")
+	}
+	if offset < 0 {
+		return nil, scerr.InvalidParameterError("offset", "cannot be negative")
+	}
+
+	return sconf.startStream(fmt.Sprintf("tail -c +%d %s", offset+1, shellQuote(remotePath)), false)
+}
+
+// OpenRemoteWriter returns a stream writing in the file remotePath on the host, starting at offset; the content
+// after offset is discarded. Missing parent folders are created, and mode is applied to the file when the stream
+// is closed.
+func (sconf *SSHConfig) OpenRemoteWriter(remotePath string, offset int64, mode os.FileMode) (io.WriteCloser, error) {
+	if sconf == nil {
+		return nil, scerr.InvalidInstanceError()
+	}
+	if remotePath == "" {
+		return nil, scerr.InvalidParameterError("remotePath", "cannot be empty string")
+	}
+	if offset < 0 {
+		return nil, scerr.InvalidParameterError("offset", "cannot be negative")
+	}
+
+	cmd := fmt.Sprintf(`f=%s; mkdir -p "$(dirname "$f")" && touch "$f" && truncate -s %d "$f" && cat >>"$f" && chmod %04o "$f"`,
+		shellQuote(remotePath), offset, mode.Perm())
+	return sconf.startStream(cmd, true)
+}
+
+// checkRemoteFileScript returns the script checking the checksum of the file remotePath; on mismatch, the file is
+// removed, so that a new attempt doesn't resume on corrupted content, and the script fails
+func checkRemoteFileScript(remotePath, checksum string) string {
+	return fmt.Sprintf(`f=%s
+[ -f "$f" ] && [ "$(sha256sum <"$f" | cut -d' ' -f1)" = %s ] && exit 0
+rm -f "$f"
+echo "checksum mismatch, file removed" >&2
+exit 1`, shellQuote(remotePath), shellQuote(checksum))
+}
+
+// VerifyRemoteFile checks the file remotePath on the host has the expected checksum; on mismatch, the file is removed
+func (sconf *SSHConfig) VerifyRemoteFile(remotePath, checksum string) error {
+	if sconf == nil {
+		return scerr.InvalidInstanceError()
+	}
+	if remotePath == "" {
+		return scerr.InvalidParameterError("remotePath", "cannot be empty string")
+	}
+	if checksum == "" {
+		return nil
+	}
+
+	_, err := sconf.runOutput("bash -c " + shellQuote(checkRemoteFileScript(remotePath, checksum)))
+	if err != nil {
+		return fmt.Errorf("checksum mismatch for '%s': %s", remotePath, err.Error())
+	}
+	return nil
+}
+
+// AbortRemoteStream interrupts a stream returned by OpenRemoteReader or OpenRemoteWriter without waiting for
+// the remote command
+func AbortRemoteStream(stream io.Closer) {
+	if rs, ok := stream.(*remoteStream); ok {
+		rs.abort()
+		return
+	}
+	_ = stream.Close()
+}
+
+// ProgressWriter counts the bytes written through it and calls Notify after each write
+type ProgressWriter struct {
+	Writer io.Writer
+	Count  int64
+	Notify func(count int64)
+}
+
+// Write writes p to the underlying writer
+func (pw *ProgressWriter) Write(p []byte) (int, error) {
+	n, err := pw.Writer.Write(p)
+	pw.Count += int64(n)
+	if pw.Notify != nil {
+		pw.Notify(pw.Count)
+	}
+	return n, err
+}
+
+// ResumeOffset returns the position from where a file can be resumed on the destination, given what is already
+// there; 0 means the whole file has to be transferred, src.Size means nothing has to be transferred
+func ResumeOffset(src FileInfo, dst *FileInfo) int64 {
+	if dst == nil {
+		return 0
+	}
+	if src.Checksum != "" && src.Checksum == dst.Checksum {
+		return src.Size
+	}
+	if dst.Size < src.Size {
+		return dst.Size
+	}
+	return 0
+}
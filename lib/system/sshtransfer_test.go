@@ -0,0 +1,110 @@
+/*
+ * Copyright 2018-2020, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package system_test
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/CS-SI/SafeScale/lib/system"
+)
+
+func TestChecksum(t *testing.T) {
+	sum, n, err := system.Checksum(strings.NewReader("hello"))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(5), n)
+	assert.Equal(t, "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", sum)
+}
+
+func TestListLocalFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "safescale-transfer")
+	assert.Nil(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	assert.Nil(t, os.MkdirAll(filepath.Join(dir, "sub"), 0755))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "a.txt"), []byte("hello"), 0644))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "sub", "b.txt"), []byte("world!"), 0600))
+
+	files, isDir, err := system.ListLocalFiles(dir, true)
+	assert.Nil(t, err)
+	assert.True(t, isDir)
+	assert.Equal(t, 2, len(files))
+	assert.Equal(t, "a.txt", files[0].Path)
+	assert.Equal(t, int64(5), files[0].Size)
+	assert.Equal(t, "sub/b.txt", files[1].Path)
+	assert.Equal(t, os.FileMode(0600), files[1].Mode)
+	assert.NotEmpty(t, files[1].Checksum)
+
+	files, isDir, err = system.ListLocalFiles(filepath.Join(dir, "a.txt"), false)
+	assert.Nil(t, err)
+	assert.False(t, isDir)
+	assert.Equal(t, 1, len(files))
+	assert.Equal(t, "", files[0].Path)
+	assert.Empty(t, files[0].Checksum)
+
+	_, _, err = system.ListLocalFiles(filepath.Join(dir, "missing"), false)
+	assert.NotNil(t, err)
+}
+
+func TestResumeOffset(t *testing.T) {
+	src := system.FileInfo{Size: 100, Checksum: "abc"}
+
+	assert.Equal(t, int64(0), system.ResumeOffset(src, nil))
+	assert.Equal(t, int64(100), system.ResumeOffset(src, &system.FileInfo{Size: 100, Checksum: "abc"}))
+	assert.Equal(t, int64(40), system.ResumeOffset(src, &system.FileInfo{Size: 40, Checksum: "def"}))
+	assert.Equal(t, int64(0), system.ResumeOffset(src, &system.FileInfo{Size: 100, Checksum: "def"}))
+	assert.Equal(t, int64(0), system.ResumeOffset(src, &system.FileInfo{Size: 120, Checksum: "def"}))
+}
+
+func TestCheckRemoteFileScript(t *testing.T) {
+	dir, err := ioutil.TempDir("", "safescale-transfer")
+	assert.Nil(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	file := filepath.Join(dir, "it's.txt")
+	sum, _, err := system.Checksum(strings.NewReader("hello"))
+	assert.Nil(t, err)
+
+	// Good content is kept
+	assert.Nil(t, ioutil.WriteFile(file, []byte("hello"), 0644))
+	assert.Nil(t, exec.Command("bash", "-c", system.CheckRemoteFileScript(file, sum)).Run())
+	_, err = os.Stat(file)
+	assert.Nil(t, err)
+
+	// Corrupted content is removed, so a retry restarts from offset 0 instead of appending to bad data
+	assert.Nil(t, ioutil.WriteFile(file, []byte("hellO"), 0644))
+	assert.NotNil(t, exec.Command("bash", "-c", system.CheckRemoteFileScript(file, sum)).Run())
+	_, err = os.Stat(file)
+	assert.True(t, os.IsNotExist(err))
+	files, _, err := system.ListLocalFiles(dir, true)
+	assert.Nil(t, err)
+	assert.Empty(t, files)
+	assert.Equal(t, int64(0), system.ResumeOffset(system.FileInfo{Size: 5, Checksum: sum}, nil))
+
+	// Missing file fails
+	assert.NotNil(t, exec.Command("bash", "-c", system.CheckRemoteFileScript(file, sum)).Run())
+}
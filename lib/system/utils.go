@@ -23,6 +23,7 @@ import (
 	"syscall"
 
 	rice "github.com/GeertJohan/go.rice"
+	"golang.org/x/crypto/ssh"
 )
 
 //go:generate rice embed-go
@@ -65,5 +66,16 @@ func ExtractRetCode(err error) (string, int, error) {
 		msg = ee.Error()
 		return msg, retCode, nil
 	}
+	switch ee := err.(type) {
+	case *ssh.ExitError:
+		// Remote command exited with a status (or killed by a signal, status is then 128+signal number)
+		return ee.Error(), ee.ExitStatus(), nil
+	case *ssh.ExitMissingError:
+		// Remote command exited without status (connection closed)
+		return ee.Error(), retCode, nil
+	case interface{ ExitStatus() int }:
+		// Covers connection failures, returning the same exit code as the ssh binary
+		return err.Error(), ee.ExitStatus(), nil
+	}
 	return msg, retCode, fmt.Errorf("error is not an 'ExitError'")
 }
@@ -100,6 +100,10 @@ func ExtractRetCode(err error) (string, int, error) {
 		msg = ee.Error()
 		return msg, retCode, nil
 	}
+	// Errors of remote commands, returned by the SSH client, carry their own exit status
+	if ee, ok := err.(interface{ ExitStatus() int }); ok {
+		return err.Error(), ee.ExitStatus(), nil
+	}
 	return msg, retCode, fmt.Errorf("error is not an 'ExitError'")
 }
 